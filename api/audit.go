@@ -0,0 +1,188 @@
+package api
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/peer"
+
+	"github.com/equinor/vds-slice/internal/audit"
+	"github.com/equinor/vds-slice/internal/clientip"
+	"github.com/equinor/vds-slice/internal/core"
+)
+
+/** The url of the cube, without the query or fragment
+ *
+ * The query may hold a sas, which must never reach the audit log.
+ */
+func auditedVds(vds string) string {
+	if i := strings.IndexAny(vds, "?#"); i >= 0 {
+		return vds[:i]
+	}
+	return vds
+}
+
+/** The subject, i.e. the "sub" claim, of a bearer token
+ *
+ * The token is not verified, as storage verifies it for the request itself.
+ * The subject is thus only as trustworthy as the outcome of the request, and
+ * an audit record of a request that failed with 401 may name anyone. Empty if
+ * the token is not a JWT.
+ */
+func tokenSubject(token string) string {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return ""
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return ""
+	}
+
+	var claims struct {
+		Subject string `json:"sub"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return ""
+	}
+	return claims.Subject
+}
+
+/* The requests that are audited, i.e. those of data and metadata */
+type auditable interface {
+	hash() (string, error)
+	credentials() (string, core.Credentials)
+}
+
+/** Record that client accessed the cube of request through endpoint
+ *
+ * Shared by the http and grpc servers. Hash is the request hash, if known,
+ * which is used in place of the request itself, as that holds both the sas
+ * and the surfaces.
+ */
+func (e *Endpoint) logAudit(
+	client string,
+	endpoint string,
+	request auditable,
+	hash string,
+	status int,
+	bytes int64,
+) {
+	if e.Audit == nil {
+		return
+	}
+
+	vds, credentials := request.credentials()
+	if hash == "" {
+		hash, _ = request.hash()
+	}
+
+	e.Audit.Log(audit.Record{
+		Time:        time.Now().UTC(),
+		Client:      client,
+		Subject:     tokenSubject(credentials.BearerToken),
+		Vds:         auditedVds(vds),
+		Endpoint:    endpoint,
+		RequestHash: hash,
+		Status:      status,
+		Bytes:       bytes,
+	})
+}
+
+/** Record who accessed which cube, once the response is written
+ *
+ * Meant to be deferred at the start of the handler, such that failed requests
+ * are also recorded.
+ */
+func (e *Endpoint) audit(ctx *gin.Context, request auditable, hash string) {
+	bytes := ctx.Writer.Size()
+	if bytes < 0 {
+		bytes = 0
+	}
+
+	e.logAudit(
+		clientip.Get(ctx),
+		ctx.FullPath(),
+		request,
+		hash,
+		ctx.Writer.Status(),
+		int64(bytes),
+	)
+}
+
+/* The status of a request that failed with err, in http terms */
+func auditStatus(err error) int {
+	if err == nil {
+		return http.StatusOK
+	}
+	return httpStatusCode(err)
+}
+
+/** Record who accessed which cube through the grpc server
+ *
+ * The client is the peer of the connection, and the endpoint the full name
+ * of the grpc method. The status is the http status the request would have
+ * gotten from the http server.
+ */
+func (e *Endpoint) auditGrpc(
+	ctx context.Context,
+	request auditable,
+	hash string,
+	err error,
+	bytes int64,
+) {
+	var client string
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		client = p.Addr.String()
+		if host, _, splitErr := net.SplitHostPort(client); splitErr == nil {
+			client = host
+		}
+	}
+	method, _ := grpc.Method(ctx)
+
+	e.logAudit(client, method, request, hash, auditStatus(err), bytes)
+}
+
+/** Record every sub-request of a batch that was meant to read the cube
+ *
+ * Sub-requests that could not be decoded are not recorded, as they never got
+ * as far as the cube. If the batch as a whole failed, so did all of them.
+ */
+func (e *Endpoint) auditBatch(ctx *gin.Context, items []*batchItem) {
+	for _, item := range items {
+		request, ok := item.request.(auditable)
+		if !ok {
+			continue
+		}
+
+		status := ctx.Writer.Status()
+		var bytes int64
+		if status == http.StatusOK {
+			status = auditStatus(item.err)
+		}
+		if status == http.StatusOK {
+			bytes = int64(len(item.metadata))
+			for _, data := range item.data {
+				bytes += int64(len(data))
+			}
+		}
+
+		e.logAudit(clientip.Get(ctx), ctx.FullPath(), request, "", status, bytes)
+	}
+}
+
+/* Record the access to a cube through a request that only names it */
+func (e *Endpoint) auditResource(
+	ctx *gin.Context,
+	resource RequestedResource,
+) {
+	e.audit(ctx, MetadataRequest{RequestedResource: resource}, "")
+}
@@ -0,0 +1,38 @@
+package api
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuditedVdsStripsQuery(t *testing.T) {
+	testCases := map[string]string{
+		"https://account.blob.core.windows.net/container/cube":            "https://account.blob.core.windows.net/container/cube",
+		"https://account.blob.core.windows.net/container/cube?sv=2&sig=x": "https://account.blob.core.windows.net/container/cube",
+		"https://account.blob.core.windows.net/container/cube#fragment":   "https://account.blob.core.windows.net/container/cube",
+	}
+	for vds, expected := range testCases {
+		require.Equal(t, expected, auditedVds(vds))
+	}
+}
+
+func TestTokenSubject(t *testing.T) {
+	encode := func(s string) string {
+		return base64.RawURLEncoding.EncodeToString([]byte(s))
+	}
+	header := encode(`{"alg":"RS256","typ":"JWT"}`)
+
+	testCases := map[string]string{
+		header + "." + encode(`{"sub":"user-42","aud":"storage"}`) + ".sig": "user-42",
+		header + "." + encode(`{"aud":"storage"}`) + ".sig":                 "",
+		header + ".not base64.sig":                                          "",
+		header + "." + encode(`not json`) + ".sig":                          "",
+		"opaque-token": "",
+		"":             "",
+	}
+	for token, expected := range testCases {
+		require.Equal(t, expected, tokenSubject(token), token)
+	}
+}
@@ -0,0 +1,500 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+
+	"github.com/equinor/vds-slice/internal/cache"
+	"github.com/equinor/vds-slice/internal/core"
+)
+
+// @Description A single request in a batch
+type BatchSubRequest struct {
+	// Type of the request. Valid options: metadata, slice and fence.
+	Type string `json:"type" binding:"required" example:"slice"`
+
+	// Name of the request, which the parts of its response are named by.
+	// Must be unique within the batch. Defaults to the index of the request
+	// in the batch.
+	Name string `json:"name" example:"inline-1000"`
+
+	// Parameters of the request, as given in the request body of the
+	// corresponding endpoint. vds, sas and s3 are given once for the whole
+	// batch, and must be left out.
+	Parameters json.RawMessage `json:"parameters" swaggertype:"object"`
+} //@name BatchSubRequest
+
+// Query for the batch endpoint
+// @Description Query payload for batch endpoint /batch.
+type BatchRequest struct {
+	RequestedResource
+
+	// The requests to execute against the VDS. They are executed in order,
+	// against a single handle to the VDS.
+	Requests []BatchSubRequest `json:"requests" binding:"required,min=1,dive"`
+} //@name BatchRequest
+
+func (b BatchRequest) toString() (string, error) {
+	requests := []string{}
+	for _, request := range b.Requests {
+		requests = append(requests, request.Type)
+	}
+
+	return fmt.Sprintf("{vds: %s, requests: [%s]}",
+		b.Vds,
+		strings.Join(requests, ", "),
+	), nil
+}
+
+// @Description Outcome of a single request in a batch
+type BatchStatus struct {
+	// Name of the request, as given in the request or defaulted to its index
+	Name string `json:"name" example:"inline-1000"`
+
+	// Type of the request
+	Type string `json:"type" example:"slice"`
+
+	// The http status the request would have gotten from its own endpoint
+	Status int `json:"status" example:"200"`
+
+	// The error, for requests that failed
+	Error *ErrorResponse `json:"error,omitempty"`
+} //@name BatchStatus
+
+// @Description Metadata part of the batch response
+type BatchMetadata struct {
+	// Outcome of every request in the batch, in the order they were given
+	Requests []BatchStatus `json:"requests"`
+} //@name BatchMetadata
+
+/* Header naming the request a part of the batch response belongs to */
+const batchRequestHeader = "Request"
+
+/* A decoded sub-request, and its outcome once executed */
+type batchItem struct {
+	name      string
+	kind      string
+	request   Stringable
+	metadata  []byte
+	data      [][]byte
+	checksums []uint32
+	err       error
+	/* Releases the memory reserved for the data, if any */
+	release func()
+}
+
+/** Decode the parameters of a sub-request
+ *
+ * The sub-request gets the already normalized resource of the batch, and the
+ * defaults of the deployment, such that it hashes and executes exactly like
+ * its standalone counterpart.
+ */
+func decodeSubRequest(
+	ctx *gin.Context,
+	resource RequestedResource,
+	sub BatchSubRequest,
+	defaults core.Defaults,
+) (Stringable, error) {
+	var request interface {
+		Normalizable
+		Stringable
+	}
+	var target *RequestedResource
+	switch strings.ToLower(sub.Type) {
+	case "metadata":
+		r := &MetadataRequest{}
+		request, target = r, &r.RequestedResource
+	case "slice":
+		r := &SliceRequest{}
+		request, target = r, &r.RequestedResource
+	case "fence":
+		r := &FenceRequest{}
+		request, target = r, &r.RequestedResource
+	default:
+		return nil, core.NewCodedInvalidArgument(
+			"invalid_request_type",
+			core.ErrorDetails{"type": sub.Type},
+			fmt.Sprintf(
+				"Invalid request type '%s', valid options are: metadata, "+
+					"slice, fence",
+				sub.Type,
+			),
+		)
+	}
+
+	parameters := sub.Parameters
+	if len(parameters) == 0 {
+		parameters = []byte("{}")
+	}
+	if err := decodeRequest(ctx, parameters, request); err != nil {
+		if _, ok := err.(*core.InvalidArgument); ok {
+			return nil, err
+		}
+		return nil, core.NewCodedInvalidArgument(
+			"malformed_request",
+			nil,
+			err.Error(),
+		)
+	}
+
+	if target.Vds != "" || target.Sas != "" || target.S3 != nil {
+		return nil, core.NewCodedInvalidArgument(
+			"resource_in_batch_request",
+			nil,
+			"vds, sas and s3 are given once for the whole batch, "+
+				"and must be left out of the requests in it",
+		)
+	}
+	*target = resource
+
+	if err := binding.Validator.ValidateStruct(request); err != nil {
+		return nil, validationError(err, request)
+	}
+	if r, ok := request.(defaultsRequest); ok {
+		r.resolveDefaults(defaults)
+	}
+
+	switch r := request.(type) {
+	case *MetadataRequest:
+		return *r, nil
+	case *SliceRequest:
+		return *r, nil
+	case *FenceRequest:
+		return *r, nil
+	}
+	return nil, core.NewInternalError("unexpected request type")
+}
+
+/** Decode all sub-requests of a batch
+ *
+ * Errors in a single sub-request are recorded on its item, such that the
+ * remaining ones still are executed. Errors that concern the batch as a
+ * whole are returned.
+ */
+func (e *Endpoint) decodeBatch(
+	ctx *gin.Context,
+	batch BatchRequest,
+) ([]*batchItem, error) {
+	limit := e.limits().BatchRequests
+	if limit > 0 && len(batch.Requests) > limit {
+		return nil, core.NewCodedInvalidArgument(
+			"too_many_batch_requests",
+			core.ErrorDetails{
+				"count": strconv.Itoa(len(batch.Requests)),
+				"limit": strconv.Itoa(limit),
+			},
+			fmt.Sprintf(
+				"Too many requests in batch: %d. The limit is %d",
+				len(batch.Requests),
+				limit,
+			),
+		)
+	}
+
+	names := map[string]bool{}
+	items := []*batchItem{}
+	for i, sub := range batch.Requests {
+		name := sub.Name
+		if name == "" {
+			name = strconv.Itoa(i)
+		}
+		if names[name] {
+			return nil, core.NewCodedInvalidArgument(
+				"duplicate_request_name",
+				core.ErrorDetails{"name": name},
+				fmt.Sprintf(
+					"Request name '%s' is given more than once in batch",
+					name,
+				),
+			)
+		}
+		names[name] = true
+
+		item := &batchItem{name: name, kind: strings.ToLower(sub.Type)}
+		item.request, item.err = decodeSubRequest(
+			ctx,
+			batch.RequestedResource,
+			sub,
+			e.Defaults,
+		)
+		if item.err == nil && !e.Endpoints.Enabled(item.kind) {
+			item.err = endpointDisabledError(item.kind)
+		}
+		if fence, ok := item.request.(FenceRequest); ok && item.err == nil {
+			item.err = fence.normalizeCoordinates(e.limits().FenceCoordinates)
+			item.request = fence
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+/** Execute a single sub-request against the shared handle
+ *
+ * The data of data requests is reserved against budget first, as for
+ * standalone requests.
+ */
+func executeBatchItem(
+	handle core.DSHandle,
+	budget *core.MemoryBudget,
+	item *batchItem,
+) {
+	switch request := item.request.(type) {
+	case MetadataRequest:
+		item.metadata, item.err = handle.GetMetadata(
+			request.IncludeImportInfo,
+			request.IncludeLayout,
+		)
+	case DataRequest:
+		item.release, item.err = budget.ReserveEstimate(func() (int64, error) {
+			return request.estimateSize(handle)
+		})
+		if item.err != nil {
+			return
+		}
+
+		item.data, item.metadata, item.err = request.execute(handle)
+		item.checksums = dataChecksums(item.data)
+	}
+}
+
+/* Release the memory reserved for the items, once the batch is written */
+func releaseBatchItems(items []*batchItem) {
+	for _, item := range items {
+		if item.release != nil {
+			item.release()
+			item.release = nil
+		}
+	}
+}
+
+/** Execute a batch against a single handle to the VDS
+ *
+ * Data requests are served from the cache when possible, and the results of
+ * the ones that are not are added to it. The VDS is only opened if anything
+ * is left to execute. Failing to open it fails the whole batch, while errors
+ * in the individual requests are recorded on their items.
+ */
+func (e *Endpoint) executeBatch(
+	ctx *gin.Context,
+	batch BatchRequest,
+	items []*batchItem,
+) error {
+	conn, err := e.MakeVdsConnection(batch.credentials())
+	if err != nil {
+		return err
+	}
+
+	pending := []*batchItem{}
+	for _, item := range items {
+		if item.err != nil {
+			continue
+		}
+
+		request, ok := item.request.(DataRequest)
+		if !ok {
+			pending = append(pending, item)
+			continue
+		}
+
+		cacheKey, err := request.hash()
+		if err != nil {
+			item.err = err
+			continue
+		}
+
+		cacheEntry, hit := e.Cache.Get(cacheKey)
+		if hit && conn.IsAuthorizedToRead() {
+			e.observeRequest(request, cacheEntry.Metadata())
+			item.metadata = cacheEntry.Metadata()
+			item.data = cacheEntry.Data()
+			item.checksums = cacheEntry.Checksums()
+			continue
+		}
+		pending = append(pending, item)
+	}
+
+	if len(pending) == 0 {
+		return nil
+	}
+
+	var storage core.RequestStats
+	err = e.Breaker.Do(core.StorageHost(batch.Vds), func() error {
+		handle, err := e.openHandle(ctx.Request.Context(), conn)
+		if err != nil {
+			return err
+		}
+		defer handle.Close()
+		handle = handle.WithWorkerPool(e.Workers)
+
+		for _, item := range pending {
+			if err := checkDeadline(ctx.Request.Context()); err != nil {
+				item.err = err
+				continue
+			}
+			executeBatchItem(handle, e.Budget, item)
+		}
+		storage = handleStats(handle)
+		return nil
+	})
+	setStorageStats(ctx, storage)
+	if err != nil {
+		return err
+	}
+
+	for _, item := range pending {
+		request, ok := item.request.(DataRequest)
+		if !ok || item.err != nil {
+			continue
+		}
+		e.observeRequest(request, item.metadata)
+
+		cacheKey, err := request.hash()
+		if err != nil {
+			continue
+		}
+		e.Cache.Set(
+			cacheKey,
+			cache.NewCacheEntry(item.data, item.metadata, item.checksums),
+		)
+	}
+	return nil
+}
+
+/** Write the batch response
+ *
+ * The leading part holds the outcome of every sub-request. It is followed by
+ * the parts of every successful sub-request, in order, each named by the
+ * Request header: the metadata part first, then the data parts, if any.
+ */
+func writeBatchResponse(ctx *gin.Context, items []*batchItem) {
+	var metadata BatchMetadata
+	for _, item := range items {
+		status := BatchStatus{
+			Name:   item.name,
+			Type:   item.kind,
+			Status: http.StatusOK,
+		}
+		if item.err != nil {
+			msg := sanitizeErrorMessage(item.err.Error())
+			code, details := classifyError(item.err)
+			status.Status = httpStatusCode(item.err)
+			status.Error = &ErrorResponse{
+				Error:   msg,
+				Code:    code,
+				Details: details,
+			}
+		}
+		metadata.Requests = append(metadata.Requests, status)
+	}
+
+	buffer, err := json.Marshal(metadata)
+	if abortOnError(ctx, err) {
+		return
+	}
+
+	response := &bytes.Buffer{}
+	writer := multipart.NewWriter(response)
+
+	err = writeData(ctx, writer, "application/json", buffer)
+	if abortOnError(ctx, err) {
+		return
+	}
+
+	for _, item := range items {
+		if item.err != nil {
+			continue
+		}
+
+		header := textproto.MIMEHeader{
+			"Content-Type":     {"application/json"},
+			batchRequestHeader: {item.name},
+		}
+		err = writePart(writer, header, item.metadata)
+		if abortOnError(ctx, err) {
+			return
+		}
+
+		for i, part := range item.data {
+			header := dataPartHeader(item.checksums[i])
+			header.Set(batchRequestHeader, item.name)
+			err = writePart(writer, header, part)
+			if abortOnError(ctx, err) {
+				return
+			}
+		}
+	}
+
+	err = writer.Close()
+	if abortOnError(ctx, err) {
+		return
+	}
+
+	ctx.Header("Content-Length", strconv.Itoa(response.Len()))
+	ctx.Data(
+		http.StatusOK,
+		"multipart/mixed; boundary="+writer.Boundary(),
+		response.Bytes(),
+	)
+}
+
+// BatchPost godoc
+// @Summary  Execute several requests against a single VDS
+// @description.markdown batch
+// @Tags     batch
+// @Param    body  body  BatchRequest  True  "Request parameters"
+// @Accept   application/json
+// @Produce  multipart/mixed
+// @Success  200 {object} BatchMetadata "(Example below only for the leading metadata part)"
+// @Failure  400 {object} ErrorResponse "Request is invalid"
+// @Failure  401 {object} ErrorResponse "Credentials are missing, invalid or expired"
+// @Failure  403 {object} ErrorResponse "Credentials do not grant read access to the VDS"
+// @Failure  404 {object} ErrorResponse "VDS not found"
+// @Failure  413 {object} ErrorResponse "Request body is too large"
+// @Failure  500 {object} ErrorResponse "openvds failed to process the request"
+// @Failure  503 {object} ErrorResponse "Storage account is unavailable, see Retry-After"
+// @Failure  504 {object} ErrorResponse "The deadline of the request passed, see X-Request-Deadline"
+// @Router   /batch  [post]
+func (e *Endpoint) BatchPost(ctx *gin.Context) {
+	var request BatchRequest
+	err := e.parsePostRequest(ctx, &request)
+	if abortOnError(ctx, err) {
+		return
+	}
+	prepareRequestLogging(ctx, request)
+
+	items, err := e.decodeBatch(ctx, request)
+	if abortOnError(ctx, err) {
+		return
+	}
+	defer releaseBatchItems(items)
+	defer e.auditBatch(ctx, items)
+
+	err = e.executeBatch(ctx, request, items)
+	if abortOnError(ctx, err) {
+		return
+	}
+
+	for _, item := range items {
+		data, ok := item.request.(DataRequest)
+		if !ok || item.err != nil {
+			continue
+		}
+		item.metadata, item.err = e.withEcho(
+			ctx.Request.Context(),
+			data,
+			item.metadata,
+		)
+	}
+
+	writeBatchResponse(ctx, items)
+}
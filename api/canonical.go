@@ -0,0 +1,218 @@
+package api
+
+import (
+	"strings"
+
+	"github.com/equinor/vds-slice/internal/core"
+)
+
+/** The canonical forms of the requests, from which the cache keys are computed
+ *
+ * Requests are decoded into structs before they are hashed, so the order of
+ * the keys and the formatting of numbers in the json never reach the hash.
+ * What remains are different spellings of the same request: options in
+ * another case, defaults left out or given explicitly, and the credentials,
+ * which grant access to the data but don't change it. The canonical form
+ * spells every such request the same, with options in lower case, defaults
+ * filled in and credentials stripped. The url of the VDS is normalized
+ * separately, by NormalizeConnection.
+ *
+ * Options that are not recognized are left as given, and fail the request
+ * later on.
+ */
+
+/** Default of the horizontal interpolation, see core.GetInterpolationMethod
+ *
+ * Parsed requests are already resolved against the defaults of the
+ * deployment, see core.Defaults, so this only spells requests that are not.
+ */
+const defaultInterpolation = "nearest"
+
+/* Default of the vertical interpolation, see core.GetVerticalInterpolationMethod */
+const defaultVerticalInterpolation = "cubic"
+
+/** The resource without credentials, which don't change the response
+ *
+ * Who an S3 VDS is read as is kept, see S3Options.withIdentity. Cached
+ * responses are only ever served to connections that are authorized to read
+ * the VDS, see core.Connection.IsAuthorizedToRead.
+ */
+func (r RequestedResource) canonical() RequestedResource {
+	r.Sas = ""
+	r.S3 = r.S3.withIdentity()
+	return r
+}
+
+/* An option as written by the canonical form, or fallback if not given */
+func canonicalOption(option string, fallback string) string {
+	option = strings.ToLower(strings.TrimSpace(option))
+	if option == "" {
+		return fallback
+	}
+	return option
+}
+
+/** The mode of a lineno or bound, filled in from the direction if not given
+ *
+ * Directions that refer to the axes of the cube, by position or annotation,
+ * can only be resolved with the cube at hand. Their mode is left as given.
+ */
+func canonicalLinenoMode(direction string, mode string) string {
+	if strings.TrimSpace(mode) != "" {
+		return canonicalOption(mode, "")
+	}
+
+	axis, err := core.GetAxis(direction)
+	if err != nil {
+		return mode
+	}
+	system, err := core.GetLinenoSystem(axis, "")
+	if err != nil {
+		return mode
+	}
+	if system == core.CoordinateSystemIndex {
+		return "index"
+	}
+	return "annotation"
+}
+
+func (m MetadataRequest) canonical() MetadataRequest {
+	m.RequestedResource = m.RequestedResource.canonical()
+	return m
+}
+
+func (s SliceRequest) canonical() SliceRequest {
+	s.RequestedResource = s.RequestedResource.canonical()
+	s.Direction = canonicalOption(s.Direction, "")
+	s.LinenoMode = canonicalLinenoMode(s.Direction, s.LinenoMode)
+	s.VerticalUnit = canonicalOption(s.VerticalUnit, "")
+	// The data is the same with or without the metadata part, and the echo
+	s.OmitMetadata = false
+	s.Echo = false
+	// Raw slices are not filled
+	if s.Raw {
+		s.FillValue = nil
+	}
+
+	if s.Bounds == nil {
+		return s
+	}
+	bounds := make([]core.Bound, len(s.Bounds))
+	for i, bound := range s.Bounds {
+		if bound.Direction != nil {
+			direction := canonicalOption(*bound.Direction, "")
+			bound.Direction = &direction
+			bound.Mode = canonicalLinenoMode(direction, bound.Mode)
+		}
+		bounds[i] = bound
+	}
+	s.Bounds = bounds
+	return s
+}
+
+func (f FenceRequest) canonical() FenceRequest {
+	f.RequestedResource = f.RequestedResource.canonical()
+	f.CoordinateSystem = canonicalOption(f.CoordinateSystem, "")
+	f.Interpolation = canonicalOption(f.Interpolation, defaultInterpolation)
+	f.VerticalUnit = canonicalOption(f.VerticalUnit, "")
+	// The data is the same with or without the metadata part, the echo and
+	// batches
+	f.OmitMetadata = false
+	f.Echo = false
+	f.Batched = false
+	return f
+}
+
+func (s SampleRequest) canonical() SampleRequest {
+	s.RequestedResource = s.RequestedResource.canonical()
+	s.CoordinateSystem = canonicalOption(s.CoordinateSystem, "")
+	s.Interpolation = canonicalOption(s.Interpolation, defaultInterpolation)
+	s.VerticalUnit = canonicalOption(s.VerticalUnit, "")
+	// The data is the same with or without the metadata part, and the echo
+	s.OmitMetadata = false
+	s.Echo = false
+	return s
+}
+
+func (a AttributeRequest) canonical() AttributeRequest {
+	a.RequestedResource = a.RequestedResource.canonical()
+	a.Interpolation = canonicalOption(a.Interpolation, defaultInterpolation)
+	a.VerticalInterpolation = canonicalOption(
+		a.VerticalInterpolation,
+		defaultVerticalInterpolation,
+	)
+	a.VerticalUnit = canonicalOption(a.VerticalUnit, "")
+	// The data is the same with or without the metadata part, and the echo
+	a.OmitMetadata = false
+	a.Echo = false
+	return a
+}
+
+func (h AttributeAlongSurfaceRequest) canonical() AttributeAlongSurfaceRequest {
+	h.AttributeRequest = h.AttributeRequest.canonical()
+	return h
+}
+
+func (h AttributeBetweenSurfacesRequest) canonical() AttributeBetweenSurfacesRequest {
+	h.AttributeRequest = h.AttributeRequest.canonical()
+	return h
+}
+
+func (t TraverseRequest) canonical() TraverseRequest {
+	t.RequestedResource = t.RequestedResource.canonical()
+	// The data is the same with or without the echo
+	t.Echo = false
+	return t
+}
+
+/** The path of the url with percent-encoding spelled one way only
+ *
+ * Escapes are written with upper case hex digits, and escaped unreserved
+ * characters (letters, digits, '-', '.', '_' and '~') are decoded, as by
+ * RFC 3986. Neither changes the resource the path names.
+ */
+func normalizeEscapes(path string) string {
+	var out strings.Builder
+	for i := 0; i < len(path); i++ {
+		if path[i] != '%' || i+2 >= len(path) {
+			out.WriteByte(path[i])
+			continue
+		}
+
+		hi, okHi := unhex(path[i+1])
+		lo, okLo := unhex(path[i+2])
+		if !okHi || !okLo {
+			out.WriteByte(path[i])
+			continue
+		}
+
+		c := hi<<4 | lo
+		if isUnreserved(c) {
+			out.WriteByte(c)
+		} else {
+			out.WriteString(strings.ToUpper(path[i : i+3]))
+		}
+		i += 2
+	}
+	return out.String()
+}
+
+func unhex(c byte) (byte, bool) {
+	switch {
+	case '0' <= c && c <= '9':
+		return c - '0', true
+	case 'a' <= c && c <= 'f':
+		return c - 'a' + 10, true
+	case 'A' <= c && c <= 'F':
+		return c - 'A' + 10, true
+	}
+	return 0, false
+}
+
+func isUnreserved(c byte) bool {
+	switch {
+	case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9':
+		return true
+	}
+	return c == '-' || c == '.' || c == '_' || c == '~'
+}
@@ -0,0 +1,312 @@
+package client
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"strings"
+)
+
+/** Error response of the server
+ *
+ * Code is stable and meant for programs, while Message is meant for humans.
+ * Responses that are not json, e.g. from a proxy in front of the server, give
+ * an Error with the status only and the body as Message.
+ */
+type Error struct {
+	Status  int               `json:"-"`
+	Message string            `json:"error"`
+	Code    string            `json:"code"`
+	Details map[string]string `json:"details,omitempty"`
+	ErrorId string            `json:"errorId,omitempty"`
+}
+
+func (e *Error) Error() string {
+	if e.Code == "" {
+		return fmt.Sprintf("vds-slice: status %d: %s", e.Status, e.Message)
+	}
+	return fmt.Sprintf(
+		"vds-slice: status %d (%s): %s",
+		e.Status,
+		e.Code,
+		e.Message,
+	)
+}
+
+/** Client of a vds-slice server
+ *
+ * The request and response types mirror those of the server, but the package
+ * depends on nothing but the standard library, such that it can be used
+ * without OpenVDS.
+ *
+ * BaseUrl is the root of the server, e.g. https://server.example.com/v1.
+ * BearerToken, if set, is sent in the Authorization header of every request
+ * and is an alternative to giving a sas in the requests themselves.
+ */
+type Client struct {
+	BaseUrl     string
+	HttpClient  *http.Client
+	BearerToken string
+}
+
+func NewClient(baseUrl string) *Client {
+	return &Client{
+		BaseUrl:    strings.TrimSuffix(baseUrl, "/"),
+		HttpClient: http.DefaultClient,
+	}
+}
+
+/** Get the metadata of a VDS */
+func (c *Client) Metadata(
+	ctx context.Context,
+	request MetadataRequest,
+) (*Metadata, error) {
+	body, _, err := c.post(ctx, "metadata", request)
+	if err != nil {
+		return nil, err
+	}
+
+	var metadata Metadata
+	if err := json.Unmarshal(body, &metadata); err != nil {
+		return nil, fmt.Errorf("vds-slice: invalid metadata: %v", err)
+	}
+	return &metadata, nil
+}
+
+/** Get a slice, as its metadata and its data
+ *
+ * There is a single data part, of the layout described by the metadata.
+ */
+func (c *Client) Slice(
+	ctx context.Context,
+	request SliceRequest,
+) (*SliceMetadata, [][]byte, error) {
+	var metadata SliceMetadata
+	data, err := c.fetchData(ctx, "slice", request, &metadata)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &metadata, data, nil
+}
+
+/** Get a fence, as its metadata and its data */
+func (c *Client) Fence(
+	ctx context.Context,
+	request FenceRequest,
+) (*FenceMetadata, [][]byte, error) {
+	var metadata FenceMetadata
+	data, err := c.fetchData(ctx, "fence", request, &metadata)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &metadata, data, nil
+}
+
+/** Sample the cube at scattered points
+ *
+ * The data is the values and their validity flags, in that order.
+ */
+func (c *Client) Sample(
+	ctx context.Context,
+	request SampleRequest,
+) (*SampleMetadata, [][]byte, error) {
+	var metadata SampleMetadata
+	data, err := c.fetchData(ctx, "sample", request, &metadata)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &metadata, data, nil
+}
+
+/** Calculate attributes along a surface
+ *
+ * There is one data part per attribute, in the order they were requested.
+ */
+func (c *Client) AttributesAlongSurface(
+	ctx context.Context,
+	request AttributeAlongSurfaceRequest,
+) (*AttributeMetadata, [][]byte, error) {
+	var metadata AttributeMetadata
+	data, err := c.fetchData(ctx, "attributes/surface/along", request, &metadata)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &metadata, data, nil
+}
+
+/** Calculate attributes between two surfaces
+ *
+ * There is one data part per attribute, in the order they were requested.
+ */
+func (c *Client) AttributesBetweenSurfaces(
+	ctx context.Context,
+	request AttributeBetweenSurfacesRequest,
+) (*AttributeMetadata, [][]byte, error) {
+	var metadata AttributeMetadata
+	data, err := c.fetchData(
+		ctx,
+		"attributes/surface/between",
+		request,
+		&metadata,
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &metadata, data, nil
+}
+
+/** Post a request for a multipart response and split it up
+ *
+ * The first part is the json metadata, which is unmarshalled into metadata,
+ * the rest are the binary data parts. Data parts are checked against their
+ * checksum, if the server gives one.
+ */
+func (c *Client) fetchData(
+	ctx context.Context,
+	path string,
+	request interface{},
+	metadata interface{},
+) ([][]byte, error) {
+	body, contentType, err := c.post(ctx, path, request)
+	if err != nil {
+		return nil, err
+	}
+
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil || !strings.HasPrefix(mediaType, "multipart/") {
+		return nil, fmt.Errorf(
+			"vds-slice: expected a multipart response, got '%s'",
+			contentType,
+		)
+	}
+
+	reader := multipart.NewReader(bytes.NewReader(body), params["boundary"])
+	parts, err := readParts(reader)
+	if err != nil {
+		return nil, err
+	}
+	if len(parts) == 0 {
+		return nil, fmt.Errorf("vds-slice: response has no metadata")
+	}
+
+	if err := json.Unmarshal(parts[0], metadata); err != nil {
+		return nil, fmt.Errorf("vds-slice: invalid metadata: %v", err)
+	}
+	return parts[1:], nil
+}
+
+var crc32cTable = crc32.MakeTable(crc32.Castagnoli)
+
+func readParts(reader *multipart.Reader) ([][]byte, error) {
+	parts := [][]byte{}
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return parts, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("vds-slice: invalid multipart response: %v", err)
+		}
+
+		data, err := io.ReadAll(part)
+		if err != nil {
+			return nil, fmt.Errorf("vds-slice: invalid multipart response: %v", err)
+		}
+
+		checksum := part.Header.Get("X-Content-Checksum")
+		if checksum != "" {
+			expected := fmt.Sprintf(
+				"crc32c=%08x",
+				crc32.Checksum(data, crc32cTable),
+			)
+			if checksum != expected {
+				return nil, fmt.Errorf(
+					"vds-slice: checksum mismatch in part %d",
+					len(parts),
+				)
+			}
+		}
+		parts = append(parts, data)
+	}
+}
+
+/** Post request as json to path and read the whole response
+ *
+ * Responses are requested gzipped and decompressed here, as net/http only
+ * does so transparently when it sets Accept-Encoding itself. Error responses
+ * are returned as *Error.
+ */
+func (c *Client) post(
+	ctx context.Context,
+	path string,
+	request interface{},
+) ([]byte, string, error) {
+	payload, err := json.Marshal(request)
+	if err != nil {
+		return nil, "", err
+	}
+
+	httpRequest, err := http.NewRequestWithContext(
+		ctx,
+		http.MethodPost,
+		c.BaseUrl+"/"+path,
+		bytes.NewReader(payload),
+	)
+	if err != nil {
+		return nil, "", err
+	}
+	httpRequest.Header.Set("Content-Type", "application/json")
+	httpRequest.Header.Set("Accept-Encoding", "gzip")
+	if c.BearerToken != "" {
+		httpRequest.Header.Set("Authorization", "Bearer "+c.BearerToken)
+	}
+
+	httpClient := c.HttpClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	response, err := httpClient.Do(httpRequest)
+	if err != nil {
+		return nil, "", err
+	}
+	defer response.Body.Close()
+
+	body, err := readBody(response)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if response.StatusCode != http.StatusOK {
+		return nil, "", decodeError(response.StatusCode, body)
+	}
+	return body, response.Header.Get("Content-Type"), nil
+}
+
+func readBody(response *http.Response) ([]byte, error) {
+	if response.Header.Get("Content-Encoding") != "gzip" {
+		return io.ReadAll(response.Body)
+	}
+
+	reader, err := gzip.NewReader(response.Body)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return io.ReadAll(reader)
+}
+
+func decodeError(status int, body []byte) error {
+	apiError := &Error{}
+	if err := json.Unmarshal(body, apiError); err != nil || apiError.Message == "" {
+		apiError = &Error{Message: strings.TrimSpace(string(body))}
+	}
+	apiError.Status = status
+	return apiError
+}
@@ -0,0 +1,191 @@
+package client
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"math"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func writeMultipart(
+	t *testing.T,
+	w http.ResponseWriter,
+	metadata string,
+	checksum string,
+	data ...[]byte,
+) {
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+
+	part, err := writer.CreatePart(
+		textproto.MIMEHeader{"Content-Type": {"application/json"}},
+	)
+	require.NoError(t, err)
+	_, err = part.Write([]byte(metadata))
+	require.NoError(t, err)
+
+	for _, buffer := range data {
+		if checksum == "" {
+			checksum = fmt.Sprintf(
+				"crc32c=%08x",
+				crc32.Checksum(buffer, crc32cTable),
+			)
+		}
+		part, err := writer.CreatePart(textproto.MIMEHeader{
+			"Content-Type":       {"application/octet-stream"},
+			"X-Content-Checksum": {checksum},
+		})
+		require.NoError(t, err)
+		_, err = part.Write(buffer)
+		require.NoError(t, err)
+	}
+	require.NoError(t, writer.Close())
+
+	w.Header().Set(
+		"Content-Type",
+		"multipart/mixed; boundary="+writer.Boundary(),
+	)
+	w.Header().Set("Content-Encoding", "gzip")
+	gz := gzip.NewWriter(w)
+	_, err = gz.Write(body.Bytes())
+	require.NoError(t, err)
+	require.NoError(t, gz.Close())
+}
+
+func TestSlice(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			require.Equal(t, "/v1/slice", r.URL.Path)
+			require.Equal(t, "gzip", r.Header.Get("Accept-Encoding"))
+			require.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+
+			var request map[string]interface{}
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+			require.Equal(t, "inline", request["direction"])
+			require.Equal(t, 0.0, request["lineno"])
+			require.Equal(t, "nan", request["fillValue"])
+
+			metadata := `{"format": "<f4", "shape": [1, 2], "fillValue": "nan"}`
+			writeMultipart(t, w, metadata, "", []byte{1, 2, 3, 4, 5, 6, 7, 8})
+		},
+	))
+	defer server.Close()
+
+	client := NewClient(server.URL + "/v1/")
+	client.BearerToken = "token"
+
+	fillValue := FillValue(math.NaN())
+	metadata, data, err := client.Slice(context.Background(), SliceRequest{
+		RequestedResource: RequestedResource{Vds: "survey"},
+		Direction:         "inline",
+		FillValue:         &fillValue,
+	})
+	require.NoError(t, err)
+	require.Equal(t, "<f4", metadata.Format)
+	require.Equal(t, []int{1, 2}, metadata.Shape)
+	require.True(t, math.IsNaN(float64(*metadata.FillValue)))
+	require.Equal(t, [][]byte{{1, 2, 3, 4, 5, 6, 7, 8}}, data)
+}
+
+func TestChecksumMismatch(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			writeMultipart(t, w, `{}`, "crc32c=00000000", []byte{1, 2, 3, 4})
+		},
+	))
+	defer server.Close()
+
+	client := NewClient(server.URL)
+	_, _, err := client.Fence(context.Background(), FenceRequest{})
+	require.ErrorContains(t, err, "checksum mismatch in part 1")
+}
+
+func TestErrorResponse(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/metadata":
+				w.WriteHeader(http.StatusBadRequest)
+				fmt.Fprint(w, `{
+					"error": "Invalid lineno: 10, valid range: [0:2:1]",
+					"code": "lineno_out_of_range",
+					"details": {"lineno": "10"}
+				}`)
+			default:
+				w.WriteHeader(http.StatusBadGateway)
+				fmt.Fprint(w, "bad gateway\n")
+			}
+		},
+	))
+	defer server.Close()
+
+	client := NewClient(server.URL)
+
+	_, err := client.Metadata(context.Background(), MetadataRequest{})
+	var apiError *Error
+	require.True(t, errors.As(err, &apiError))
+	require.Equal(t, &Error{
+		Status:  http.StatusBadRequest,
+		Message: "Invalid lineno: 10, valid range: [0:2:1]",
+		Code:    "lineno_out_of_range",
+		Details: map[string]string{"lineno": "10"},
+	}, apiError)
+
+	_, _, err = client.AttributesAlongSurface(
+		context.Background(),
+		AttributeAlongSurfaceRequest{},
+	)
+	require.True(t, errors.As(err, &apiError))
+	require.Equal(t, &Error{
+		Status:  http.StatusBadGateway,
+		Message: "bad gateway",
+	}, apiError)
+}
+
+func TestCancellation(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			<-release
+		},
+	))
+	defer server.Close()
+	defer close(release)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	client := NewClient(server.URL)
+	_, _, err := client.AttributesBetweenSurfaces(
+		ctx,
+		AttributeBetweenSurfacesRequest{},
+	)
+	require.ErrorIs(t, err, context.Canceled)
+}
+
+func TestUncompressedResponse(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprint(w, `{"crs": "utm", "axis": [{"annotation": "Inline"}]}`)
+		},
+	))
+	defer server.Close()
+
+	client := NewClient(server.URL)
+	metadata, err := client.Metadata(context.Background(), MetadataRequest{})
+	require.NoError(t, err)
+	require.Equal(t, "utm", metadata.Crs)
+	require.Equal(t, "Inline", metadata.Axis[0].Annotation)
+}
@@ -0,0 +1,296 @@
+package client
+
+import (
+	"encoding/json"
+	"math"
+	"strings"
+)
+
+/** The VDS a request reads from
+ *
+ * Vds is a url, or an alias if the server is set up with an alias resolver.
+ * Credentials are given either by Sas, by S3 or by a bearer token on the
+ * client, see Client.BearerToken.
+ */
+type RequestedResource struct {
+	Vds string     `json:"vds"`
+	Sas string     `json:"sas,omitempty"`
+	S3  *S3Options `json:"s3,omitempty"`
+}
+
+/** Options for VDSs in AWS S3 (s3://<bucket>/<key>) */
+type S3Options struct {
+	Region       string `json:"region,omitempty"`
+	AccessKeyId  string `json:"accessKeyId,omitempty"`
+	SecretKey    string `json:"secretKey,omitempty"`
+	SessionToken string `json:"sessionToken,omitempty"`
+}
+
+type MetadataRequest struct {
+	RequestedResource
+
+	IncludeImportInfo bool `json:"includeImportInfo,omitempty"`
+	IncludeLayout     bool `json:"includeLayout,omitempty"`
+}
+
+type SliceRequest struct {
+	RequestedResource
+
+	// One of i, j, k, inline, crossline, depth, time or sample
+	Direction string `json:"direction"`
+
+	// Line number of the slice, interpreted according to LinenoMode
+	Lineno int `json:"lineno"`
+
+	// Either index or annotation. Defaults to the mode of Direction.
+	LinenoMode string `json:"linenoMode,omitempty"`
+
+	Bounds []Bound `json:"bounds,omitempty"`
+
+	// The value absent data is replaced by. Left to OpenVDS if nil.
+	FillValue *FillValue `json:"fillValue,omitempty"`
+
+	VerticalUnit string `json:"verticalUnit,omitempty"`
+
+	// Return the samples as stored in the VDS rather than as float32, in the
+	// format given by the metadata
+	Raw bool `json:"raw,omitempty"`
+}
+
+/** Restricts a slice along one of its axes */
+type Bound struct {
+	Direction string `json:"direction"`
+	Lower     int    `json:"lower"`
+	Upper     int    `json:"upper"`
+}
+
+type FenceRequest struct {
+	RequestedResource
+
+	// One of ij, ilxl or cdp
+	CoordinateSystem string `json:"coordinateSystem"`
+
+	// The fence as [x, y] pairs
+	Coordinates [][]float32 `json:"coordinates,omitempty"`
+
+	// The fence as a flat list of x, y values. Mutually exclusive with
+	// Coordinates.
+	CoordinatesFlat []float32 `json:"coordinatesFlat,omitempty"`
+
+	Interpolation string `json:"interpolation,omitempty"`
+
+	FillValue *float32 `json:"fillValue,omitempty"`
+
+	// Resample the traces to this sample interval, in VerticalUnit
+	ResampleTo *float32 `json:"resampleTo,omitempty"`
+
+	VerticalUnit string `json:"verticalUnit,omitempty"`
+}
+
+type SampleRequest struct {
+	RequestedResource
+
+	// One of ij, ilxl or cdp, for x and y
+	CoordinateSystem string `json:"coordinateSystem"`
+
+	// The points as [x, y, z] triplets
+	Coordinates [][]float32 `json:"coordinates"`
+
+	Interpolation string `json:"interpolation,omitempty"`
+
+	FillValue *float32 `json:"fillValue,omitempty"`
+
+	VerticalUnit string `json:"verticalUnit,omitempty"`
+}
+
+/** Options shared by the attribute requests */
+type AttributeRequest struct {
+	RequestedResource
+
+	Interpolation         string   `json:"interpolation,omitempty"`
+	VerticalInterpolation string   `json:"verticalInterpolation,omitempty"`
+	Stepsize              float32  `json:"stepsize,omitempty"`
+	Attributes            []string `json:"attributes"`
+	VerticalUnit          string   `json:"verticalUnit,omitempty"`
+
+	/* Compute the valid attributes only, see AttributeMetadata.Attributes */
+	Partial bool `json:"partial,omitempty"`
+
+	/* Nodes with less data in their window than this get the fill value */
+	MinValidFraction float32 `json:"minValidFraction,omitempty"`
+}
+
+type AttributeAlongSurfaceRequest struct {
+	AttributeRequest
+
+	Surface RegularSurface `json:"surface"`
+	Above   float32        `json:"above"`
+	Below   float32        `json:"below"`
+
+	/* Only compute attributes inside the polygon, see the server docs */
+	Polygon                 [][]float32 `json:"polygon,omitempty"`
+	PolygonCoordinateSystem string      `json:"polygonCoordinateSystem,omitempty"`
+}
+
+type AttributeBetweenSurfacesRequest struct {
+	AttributeRequest
+
+	PrimarySurface   RegularSurface `json:"primarySurface"`
+	SecondarySurface RegularSurface `json:"secondarySurface"`
+}
+
+type RegularSurface struct {
+	Values    [][]float32 `json:"values"`
+	Rotation  float32     `json:"rotation"`
+	Xori      float32     `json:"xori"`
+	Yori      float32     `json:"yori"`
+	Xinc      float32     `json:"xinc"`
+	Yinc      float32     `json:"yinc"`
+	FillValue float32     `json:"fillValue"`
+
+	/* Use every n'th row or column only. Zero leaves it to the server */
+	RowStep int `json:"rowStep,omitempty"`
+	ColStep int `json:"colStep,omitempty"`
+}
+
+/** A fill value, which unlike a plain float32 can be NaN
+ *
+ * NaN is given as the string "nan" on the wire.
+ */
+type FillValue float32
+
+func (f FillValue) MarshalJSON() ([]byte, error) {
+	if math.IsNaN(float64(f)) {
+		return []byte(`"nan"`), nil
+	}
+	return json.Marshal(float32(f))
+}
+
+func (f *FillValue) UnmarshalJSON(data []byte) error {
+	var text string
+	if err := json.Unmarshal(data, &text); err == nil {
+		if strings.ToLower(text) != "nan" {
+			return &json.UnsupportedValueError{Str: text}
+		}
+		*f = FillValue(math.NaN())
+		return nil
+	}
+
+	var value float32
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	*f = FillValue(value)
+	return nil
+}
+
+type Axis struct {
+	Annotation string  `json:"annotation"`
+	Min        float64 `json:"min"`
+	Max        float64 `json:"max"`
+	Samples    int     `json:"samples"`
+	StepSize   float64 `json:"stepsize"`
+	Regular    bool    `json:"regular"`
+	Unit       string  `json:"unit"`
+	RawUnit    string  `json:"rawUnit"`
+}
+
+type BoundingBox struct {
+	Cdp  [][]float64 `json:"cdp"`
+	Ilxl [][]float64 `json:"ilxl"`
+	Ij   [][]float64 `json:"ij"`
+}
+
+/** Layout of the binary data parts of a response
+ *
+ * Format is a numpy style type string, e.g. <f4 for little endian float32.
+ */
+type Array struct {
+	Format string `json:"format"`
+	Shape  []int  `json:"shape"`
+}
+
+type Metadata struct {
+	Crs             string      `json:"crs"`
+	InputFileName   string      `json:"inputFileName"`
+	ImportTimeStamp string      `json:"importTimeStamp,omitempty"`
+	SegyTextHeader  string      `json:"segyTextHeader,omitempty"`
+	BoundingBox     BoundingBox `json:"boundingBox"`
+	Axis            []*Axis     `json:"axis"`
+
+	/* Only given with MetadataRequest.IncludeLayout */
+	Layout *Layout `json:"layout,omitempty"`
+}
+
+type Layout struct {
+	BrickSize            []int     `json:"brickSize"`
+	LodLevels            int       `json:"lodLevels"`
+	Compression          string    `json:"compression,omitempty"`
+	CompressionTolerance *float32  `json:"compressionTolerance,omitempty"`
+	Channels             []Channel `json:"channels"`
+}
+
+type Channel struct {
+	Name       string     `json:"name"`
+	Format     string     `json:"format,omitempty"`
+	Unit       string     `json:"unit,omitempty"`
+	ValueRange [2]float32 `json:"valueRange"`
+	NoValue    *float32   `json:"noValue,omitempty"`
+}
+
+type SliceMetadata struct {
+	Array
+
+	X          Axis        `json:"x"`
+	Y          Axis        `json:"y"`
+	Geospatial [][]float64 `json:"geospatial"`
+	FillValue  *FillValue  `json:"fillValue"`
+}
+
+type FenceMetadata struct {
+	Array
+
+	Indices [][]int `json:"indices,omitempty"`
+
+	/* Only given for resampled fences, see FenceRequest.ResampleTo */
+	SampleInterval *float32 `json:"sampleInterval,omitempty"`
+}
+
+type SampleMetadata struct {
+	/* The values, the first data part */
+	Array
+
+	/* The validity flags, the second data part */
+	Valid Array `json:"valid"`
+
+	FillValue *FillValue `json:"fillValue"`
+}
+
+type AttributeMetadata struct {
+	/* Deprecated: use the shape and format of every part in Parts */
+	Array
+
+	/* A descriptor of every attribute data part, in the order of the parts */
+	Parts []AttributePart `json:"parts,omitempty"`
+
+	/* The increments of a decimated surface, see RegularSurface.RowStep */
+	Xinc *float32 `json:"xinc,omitempty"`
+	Yinc *float32 `json:"yinc,omitempty"`
+
+	/* The status of every requested attribute, for partial requests only */
+	Attributes []AttributeStatus `json:"attributes,omitempty"`
+
+	/* The surface fill value, given for nodes without attributes */
+	FillValue *FillValue `json:"fillValue"`
+}
+
+type AttributePart struct {
+	Name string `json:"name"`
+	Array
+}
+
+type AttributeStatus struct {
+	Name string `json:"name"`
+	/* "ok", or why the attribute was rejected */
+	Status string `json:"status"`
+}
@@ -0,0 +1,160 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/equinor/vds-slice/internal/core"
+)
+
+/* Tolerance of the world transforms, if none is given in the request */
+const defaultCompatibilityTolerance = 0.01
+
+// Query for the compatibility endpoint
+// @Description Query payload for compatibility endpoint /compatibility.
+type CompatibilityRequest struct {
+	// The first of the two cubes to compare. Given as for any other request,
+	// i.e. by vds and, unless given in the request headers, sas.
+	A RequestedResource `json:"a" binding:"required"`
+
+	// The second of the two cubes to compare
+	B RequestedResource `json:"b" binding:"required"`
+
+	// The largest distance, in world coordinates, the corners of the
+	// bounding boxes of the two cubes can be apart, for their world
+	// transforms to be considered to agree. Defaults to 0.01.
+	Tolerance *float64 `json:"tolerance" example:"0.01"`
+} //@name CompatibilityRequest
+
+func (c *CompatibilityRequest) setBearerToken(token string) {
+	c.A.setBearerToken(token)
+	c.B.setBearerToken(token)
+}
+
+func (c *CompatibilityRequest) setHeaderSas(sas string) {
+	c.A.setHeaderSas(sas)
+	c.B.setHeaderSas(sas)
+}
+
+func (c *CompatibilityRequest) resolveAlias(resolver core.VdsResolver) error {
+	if err := c.A.resolveAlias(resolver); err != nil {
+		return err
+	}
+	return c.B.resolveAlias(resolver)
+}
+
+func (c *CompatibilityRequest) NormalizeConnection() error {
+	if err := c.A.NormalizeConnection(); err != nil {
+		return err
+	}
+	return c.B.NormalizeConnection()
+}
+
+func (c CompatibilityRequest) toString() (string, error) {
+	return fmt.Sprintf("{a: %s, b: %s, tolerance (optional): %v}",
+		c.A.Vds,
+		c.B.Vds,
+		c.tolerance(),
+	), nil
+}
+
+func (c CompatibilityRequest) tolerance() float64 {
+	if c.Tolerance == nil {
+		return defaultCompatibilityTolerance
+	}
+	return *c.Tolerance
+}
+
+/** Compare the two cubes of the request
+ *
+ * The circuit breaker is consulted for the storage host of both cubes.
+ */
+func (e *Endpoint) fetchCompatibility(
+	ctx context.Context,
+	request CompatibilityRequest,
+) ([]byte, error) {
+	if request.tolerance() < 0 {
+		return nil, core.NewInvalidArgument(fmt.Sprintf(
+			"Invalid tolerance: %g, must be non-negative",
+			request.tolerance(),
+		))
+	}
+
+	connA, err := e.MakeVdsConnection(request.A.credentials())
+	if err != nil {
+		return nil, err
+	}
+	connB, err := e.MakeVdsConnection(request.B.credentials())
+	if err != nil {
+		return nil, err
+	}
+
+	var buffer []byte
+	compare := func() error {
+		handleA, err := e.Retry.OpenVds(ctx, connA)
+		if err != nil {
+			return err
+		}
+		defer handleA.Close()
+
+		handleB, err := e.Retry.OpenVds(ctx, connB)
+		if err != nil {
+			return err
+		}
+		defer handleB.Close()
+
+		buffer, err = core.GetCompatibility(
+			handleA,
+			handleB,
+			request.tolerance(),
+		)
+		return err
+	}
+
+	hostA := core.StorageHost(request.A.Vds)
+	hostB := core.StorageHost(request.B.Vds)
+	err = e.Breaker.Do(hostA, func() error {
+		if hostA == hostB {
+			return compare()
+		}
+		return e.Breaker.Do(hostB, compare)
+	})
+	return buffer, err
+}
+
+// CompatibilityPost godoc
+// @Summary  Check whether two cubes are geometrically compatible
+// @description.markdown compatibility
+// @Tags     compatibility
+// @Param    body  body  CompatibilityRequest  True  "Request parameters"
+// @Produce  json
+// @Success  200 {object} core.Compatibility
+// @Failure  400 {object} ErrorResponse "Request is invalid"
+// @Failure  401 {object} ErrorResponse "Credentials are missing, invalid or expired"
+// @Failure  403 {object} ErrorResponse "Credentials do not grant read access to the VDS"
+// @Failure  404 {object} ErrorResponse "VDS not found"
+// @Failure  413 {object} ErrorResponse "Request body is too large"
+// @Failure  500 {object} ErrorResponse "openvds failed to process the request"
+// @Failure  503 {object} ErrorResponse "Storage account is unavailable, see Retry-After"
+// @Failure  504 {object} ErrorResponse "The deadline of the request passed, see X-Request-Deadline"
+// @Router   /compatibility  [post]
+func (e *Endpoint) CompatibilityPost(ctx *gin.Context) {
+	var request CompatibilityRequest
+	err := e.parsePostRequest(ctx, &request)
+	if abortOnError(ctx, err) {
+		return
+	}
+	prepareRequestLogging(ctx, request)
+	defer e.auditResource(ctx, request.A)
+	defer e.auditResource(ctx, request.B)
+
+	buffer, err := e.fetchCompatibility(ctx.Request.Context(), request)
+	if abortOnError(ctx, err) {
+		return
+	}
+
+	ctx.Data(http.StatusOK, "application/json", buffer)
+}
@@ -0,0 +1,175 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/equinor/vds-slice/internal/core"
+)
+
+/** Header with the deadline of the request, as given by the client
+ *
+ * Either an RFC3339 time, or a number of milliseconds from when the request
+ * is received. The latter is not affected by clock skew between the client
+ * and the server.
+ */
+const deadlineHeader = "X-Request-Deadline"
+
+/* Header with the timeout of the request, in the format of grpc-timeout */
+const grpcTimeoutHeader = "Grpc-Timeout"
+
+/* A grpc-timeout, at most 8 digits followed by the unit */
+var grpcTimeoutPattern = regexp.MustCompile(`^(\d{1,8})([HMSmun])$`)
+
+var grpcTimeoutUnits = map[string]time.Duration{
+	"H": time.Hour,
+	"M": time.Minute,
+	"S": time.Second,
+	"m": time.Millisecond,
+	"u": time.Microsecond,
+	"n": time.Nanosecond,
+}
+
+type deadlineKey struct{}
+
+/* Where the deadline of a request comes from, for the error message */
+type deadlineSource struct {
+	client  bool
+	timeout time.Duration
+}
+
+func parseDeadlineHeader(value string, now time.Time) (time.Time, error) {
+	milliseconds, err := strconv.ParseUint(value, 10, 32)
+	if err == nil {
+		return now.Add(time.Duration(milliseconds) * time.Millisecond), nil
+	}
+
+	deadline, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return time.Time{}, core.NewInvalidArgument(fmt.Sprintf(
+			"invalid %s '%s', expected an RFC3339 time or a number of "+
+				"milliseconds",
+			deadlineHeader,
+			value,
+		))
+	}
+	return deadline, nil
+}
+
+func parseGrpcTimeout(value string, now time.Time) (time.Time, error) {
+	match := grpcTimeoutPattern.FindStringSubmatch(value)
+	if match == nil {
+		return time.Time{}, core.NewInvalidArgument(fmt.Sprintf(
+			"invalid %s '%s', expected at most 8 digits followed by "+
+				"one of the units H, M, S, m, u and n",
+			grpcTimeoutHeader,
+			value,
+		))
+	}
+
+	count, _ := strconv.ParseInt(match[1], 10, 64)
+	return now.Add(time.Duration(count) * grpcTimeoutUnits[match[2]]), nil
+}
+
+/** The deadline given by the client, the earliest if both headers are given
+ *
+ * False if the request gives none.
+ */
+func clientDeadline(header http.Header, now time.Time) (time.Time, bool, error) {
+	var deadline time.Time
+	given := false
+
+	if value := strings.TrimSpace(header.Get(deadlineHeader)); value != "" {
+		parsed, err := parseDeadlineHeader(value, now)
+		if err != nil {
+			return time.Time{}, false, err
+		}
+		deadline, given = parsed, true
+	}
+
+	if value := strings.TrimSpace(header.Get(grpcTimeoutHeader)); value != "" {
+		parsed, err := parseGrpcTimeout(value, now)
+		if err != nil {
+			return time.Time{}, false, err
+		}
+		if !given || parsed.Before(deadline) {
+			deadline, given = parsed, true
+		}
+	}
+
+	return deadline, given, nil
+}
+
+/** Give the request the deadline of the client, or the timeout of the server
+ *
+ * The deadline of the client is honoured as long as it is before the request
+ * timeout of the server, which is the longest any request may take. Work
+ * against the VDS stops once the deadline passes, see checkDeadline, and the
+ * request fails with 504.
+ */
+func (e *Endpoint) Deadline(ctx *gin.Context) {
+	now := time.Now()
+	deadline, client, err := clientDeadline(ctx.Request.Header, now)
+	if abortOnError(ctx, err) {
+		return
+	}
+
+	if e.RequestTimeout > 0 {
+		timeout := now.Add(e.RequestTimeout)
+		if !client || timeout.Before(deadline) {
+			deadline, client = timeout, false
+		}
+	} else if !client {
+		ctx.Next()
+		return
+	}
+
+	source := deadlineSource{client: client, timeout: e.RequestTimeout}
+	requestCtx := context.WithValue(ctx.Request.Context(), deadlineKey{}, source)
+	requestCtx, cancel := context.WithDeadline(requestCtx, deadline)
+	defer cancel()
+
+	ctx.Request = ctx.Request.WithContext(requestCtx)
+	ctx.Next()
+}
+
+/** A DeadlineExceededError if the deadline of the request has passed
+ *
+ * Checked between the steps of a request, such as between retries, batches
+ * and the requests of a batch, as the reads of OpenVDS can not be
+ * interrupted. Deadlines that are not set by Deadline, i.e. those of grpc
+ * requests, are given by the client.
+ */
+func checkDeadline(ctx context.Context) error {
+	if ctx.Err() != context.DeadlineExceeded {
+		return nil
+	}
+
+	source, ok := ctx.Value(deadlineKey{}).(deadlineSource)
+	if ok && !source.client {
+		return core.NewCodedDeadlineExceededError(
+			"server_timeout",
+			core.ErrorDetails{"timeout": source.timeout.String()},
+			fmt.Sprintf(
+				"The request did not complete within the request timeout of "+
+					"the server, %v",
+				source.timeout,
+			),
+			false,
+		)
+	}
+	return core.NewCodedDeadlineExceededError(
+		"client_deadline_exceeded",
+		nil,
+		"The request did not complete before the deadline given by the "+
+			"client, e.g. in "+deadlineHeader,
+		true,
+	)
+}
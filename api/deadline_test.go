@@ -0,0 +1,189 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+
+	"github.com/equinor/vds-slice/internal/core"
+)
+
+func TestClientDeadline(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	testcases := []struct {
+		name     string
+		headers  map[string]string
+		deadline time.Time
+		given    bool
+	}{
+		{
+			name: "No deadline",
+		},
+		{
+			name:     "Milliseconds",
+			headers:  map[string]string{deadlineHeader: "1500"},
+			deadline: now.Add(1500 * time.Millisecond),
+			given:    true,
+		},
+		{
+			name:     "RFC3339",
+			headers:  map[string]string{deadlineHeader: "2024-05-01T12:00:15Z"},
+			deadline: now.Add(15 * time.Second),
+			given:    true,
+		},
+		{
+			name:     "RFC3339 with fractional seconds and offset",
+			headers:  map[string]string{deadlineHeader: "2024-05-01T14:00:00.5+02:00"},
+			deadline: now.Add(500 * time.Millisecond),
+			given:    true,
+		},
+		{
+			name:     "Grpc timeout",
+			headers:  map[string]string{grpcTimeoutHeader: "15S"},
+			deadline: now.Add(15 * time.Second),
+			given:    true,
+		},
+		{
+			name: "The earliest of both",
+			headers: map[string]string{
+				deadlineHeader:    "20000",
+				grpcTimeoutHeader: "100m",
+			},
+			deadline: now.Add(100 * time.Millisecond),
+			given:    true,
+		},
+	}
+
+	for _, testcase := range testcases {
+		header := http.Header{}
+		for key, value := range testcase.headers {
+			header.Set(key, value)
+		}
+
+		deadline, given, err := clientDeadline(header, now)
+		require.NoError(t, err, testcase.name)
+		require.Equalf(t, testcase.given, given, testcase.name)
+		require.Truef(
+			t,
+			testcase.deadline.Equal(deadline),
+			"[%s] expected %v, got %v",
+			testcase.name,
+			testcase.deadline,
+			deadline,
+		)
+	}
+}
+
+func TestClientDeadlineInvalid(t *testing.T) {
+	invalid := []map[string]string{
+		{deadlineHeader: "tomorrow"},
+		{deadlineHeader: "-100"},
+		{grpcTimeoutHeader: "15"},
+		{grpcTimeoutHeader: "123456789S"},
+		{grpcTimeoutHeader: "1s"},
+	}
+
+	for _, headers := range invalid {
+		header := http.Header{}
+		for key, value := range headers {
+			header.Set(key, value)
+		}
+
+		_, _, err := clientDeadline(header, time.Now())
+		require.IsTypef(t, &core.InvalidArgument{}, err, "%v", headers)
+	}
+}
+
+/* Serve a request that works until its deadline, and checks it */
+func serveUntilDeadline(
+	t *testing.T,
+	timeout time.Duration,
+	headers map[string]string,
+) *httptest.ResponseRecorder {
+	endpoint := Endpoint{RequestTimeout: timeout}
+
+	gin.SetMode(gin.TestMode)
+	app := gin.New()
+	app.Use(ErrorHandler)
+	app.GET("/slice", endpoint.Deadline, func(ctx *gin.Context) {
+		<-ctx.Request.Context().Done()
+		abortOnError(ctx, checkDeadline(ctx.Request.Context()))
+	})
+
+	request := httptest.NewRequest(http.MethodGet, "/slice", nil)
+	for key, value := range headers {
+		request.Header.Set(key, value)
+	}
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, request)
+	return w
+}
+
+func TestDeadlineExceeded(t *testing.T) {
+	testcases := []struct {
+		name    string
+		timeout time.Duration
+		headers map[string]string
+		code    string
+	}{
+		{
+			name:    "Deadline of the client",
+			headers: map[string]string{deadlineHeader: "10"},
+			code:    "client_deadline_exceeded",
+		},
+		{
+			name:    "Deadline of the client before the timeout of the server",
+			timeout: time.Hour,
+			headers: map[string]string{grpcTimeoutHeader: "10m"},
+			code:    "client_deadline_exceeded",
+		},
+		{
+			name:    "Timeout of the server before the deadline of the client",
+			timeout: 10 * time.Millisecond,
+			headers: map[string]string{deadlineHeader: "3600000"},
+			code:    "server_timeout",
+		},
+		{
+			name:    "Timeout of the server only",
+			timeout: 10 * time.Millisecond,
+			code:    "server_timeout",
+		},
+	}
+
+	for _, testcase := range testcases {
+		w := serveUntilDeadline(t, testcase.timeout, testcase.headers)
+		require.Equalf(t, http.StatusGatewayTimeout, w.Code, testcase.name)
+
+		response := ErrorResponse{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		require.Equalf(t, testcase.code, response.Code, testcase.name)
+	}
+}
+
+func TestDeadlineInvalidHeader(t *testing.T) {
+	w := serveUntilDeadline(t, 0, map[string]string{deadlineHeader: "soon"})
+	require.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestNoDeadline(t *testing.T) {
+	endpoint := Endpoint{}
+
+	gin.SetMode(gin.TestMode)
+	app := gin.New()
+	app.GET("/slice", endpoint.Deadline, func(ctx *gin.Context) {
+		_, ok := ctx.Request.Context().Deadline()
+		require.False(t, ok)
+		require.NoError(t, checkDeadline(ctx.Request.Context()))
+		ctx.Status(http.StatusOK)
+	})
+
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/slice", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+}
@@ -0,0 +1,334 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+
+	"github.com/equinor/vds-slice/internal/core"
+)
+
+// @Description The request a response was produced by, as resolved by the
+// @Description server. Defaults, including those of the deployment, are
+// @Description filled in, options are spelled in lower case and directions
+// @Description that refer to the axes of the cube are resolved. Credentials
+// @Description are never included.
+type RequestEcho struct {
+	// The route of the request
+	Endpoint string `json:"endpoint" example:"slice"`
+
+	// The VDS, without credentials
+	Vds string `json:"vds" example:"https://account.blob.core.windows.net/container/blob"`
+
+	// Direction, lineno and linenoMode of a slice
+	Direction  string `json:"direction,omitempty" example:"inline"`
+	Lineno     *int   `json:"lineno,omitempty" example:"10000"`
+	LinenoMode string `json:"linenoMode,omitempty" example:"annotation"`
+
+	// The bounds of a slice, or of the vertical window of a traverse
+	Bounds []core.Bound `json:"bounds,omitempty"`
+
+	// The number of segments of a traverse
+	Segments *int `json:"segments,omitempty" example:"2"`
+
+	// The coordinate system and number of coordinates of a fence or sample
+	// request
+	CoordinateSystem string `json:"coordinateSystem,omitempty" example:"cdp"`
+	Coordinates      *int   `json:"coordinates,omitempty" example:"100"`
+
+	// The number of points of the surfaces of an attribute request
+	SurfacePoints *int `json:"surfacePoints,omitempty" example:"5000"`
+
+	// The interpolation methods, including the default of the server
+	Interpolation         string `json:"interpolation,omitempty" example:"linear"`
+	VerticalInterpolation string `json:"verticalInterpolation,omitempty" example:"cubic"`
+
+	// The attributes, and the vertical window they are computed in
+	Attributes []string `json:"attributes,omitempty" swaggertype:"array,string" example:"min,max"`
+	Above      *float32 `json:"above,omitempty" example:"20"`
+	Below      *float32 `json:"below,omitempty" example:"20"`
+	Stepsize   *float32 `json:"stepsize,omitempty" example:"4"`
+
+	// The interval a fence is resampled to, and the trace headers it returns
+	ResampleTo   *float32 `json:"resampleTo,omitempty" example:"1"`
+	HeaderFields []string `json:"headerFields,omitempty" example:"CDP,Offset"`
+
+	// The vertical unit of the request, the unit of the cube if not given
+	VerticalUnit string `json:"verticalUnit,omitempty" example:"ms"`
+
+	// The fill value, including the default of the server
+	FillValue *core.FillValue `json:"fillValue,omitempty" swaggertype:"number" example:"-999.25"`
+
+	// Whether the samples are returned as stored
+	Raw bool `json:"raw,omitempty" example:"false"`
+} // @name RequestEcho
+
+/** Data requests that can echo themselves in the metadata part
+ *
+ * The echo is added after the request is executed, or served from the
+ * cache, so it changes neither the cache key nor the cached response.
+ */
+type echoRequest interface {
+	DataRequest
+	resource() RequestedResource
+	echoes() bool
+	/* The echo of the request, given the axes of the cube, if known */
+	echo(axes []*core.Axis) RequestEcho
+}
+
+func (r RequestedResource) resource() RequestedResource {
+	return r
+}
+
+/** The metadata of the response, with the echo of the request if it asks for it
+ *
+ * Directions and the vertical unit are resolved with the axes of the cube,
+ * from the metadata cache if possible. The echo is a debugging aid, so if
+ * the axes can not be read it is given with what can be resolved without
+ * them, rather than failing a request that is already served.
+ */
+func (e *Endpoint) withEcho(
+	ctx context.Context,
+	request DataRequest,
+	metadata []byte,
+) ([]byte, error) {
+	echoing, ok := request.(echoRequest)
+	if !ok || !echoing.echoes() {
+		return metadata, nil
+	}
+
+	var axes []*core.Axis
+	buffer, err := e.fetchMetadata(
+		ctx,
+		MetadataRequest{RequestedResource: echoing.resource()},
+	)
+	if err == nil {
+		var vds core.Metadata
+		if json.Unmarshal(buffer, &vds) == nil {
+			axes = vds.Axis
+		}
+	}
+
+	echo, err := json.Marshal(echoing.echo(axes))
+	if err != nil {
+		return nil, core.NewInternalError(err.Error())
+	}
+	return appendMember(metadata, "request", echo)
+}
+
+/* The json object with key: value added as its last member */
+func appendMember(object []byte, key string, value []byte) ([]byte, error) {
+	object = bytes.TrimSpace(object)
+	if len(object) < 2 || object[0] != '{' || object[len(object)-1] != '}' {
+		return nil, core.NewInternalError("expected metadata to be a json object")
+	}
+
+	name, err := json.Marshal(key)
+	if err != nil {
+		return nil, core.NewInternalError(err.Error())
+	}
+
+	out := make([]byte, 0, len(object)+len(name)+len(value)+2)
+	out = append(out, object[:len(object)-1]...)
+	if len(bytes.TrimSpace(object[1:len(object)-1])) > 0 {
+		out = append(out, ',')
+	}
+	out = append(out, name...)
+	out = append(out, ':')
+	out = append(out, value...)
+	out = append(out, '}')
+	return out, nil
+}
+
+/* The vertical unit of the request, or that of the cube if not given */
+func echoVerticalUnit(unit string, axes []*core.Axis) string {
+	if len(axes) == 0 {
+		return unit
+	}
+	return canonicalOption(unit, axes[len(axes)-1].Unit)
+}
+
+/* A direction as core reads it, if it can be resolved */
+func echoDirection(direction string, axes []*core.Axis) string {
+	axis, err := core.GetAxis(direction, axes...)
+	if err != nil {
+		return direction
+	}
+	return core.AxisName(axis)
+}
+
+func echoBounds(bounds []core.Bound, axes []*core.Axis) []core.Bound {
+	if bounds == nil {
+		return nil
+	}
+	resolved := make([]core.Bound, len(bounds))
+	for i, bound := range bounds {
+		if bound.Direction != nil {
+			direction := echoDirection(*bound.Direction, axes)
+			bound.Direction = &direction
+			bound.Mode = canonicalLinenoMode(direction, bound.Mode)
+		}
+		resolved[i] = bound
+	}
+	return resolved
+}
+
+func (s SliceRequest) echoes() bool {
+	return s.Echo
+}
+
+func (s SliceRequest) echo(axes []*core.Axis) RequestEcho {
+	s = s.canonical()
+	direction := echoDirection(s.Direction, axes)
+	return RequestEcho{
+		Endpoint:     "slice",
+		Vds:          s.Vds,
+		Direction:    direction,
+		Lineno:       s.Lineno,
+		LinenoMode:   canonicalLinenoMode(direction, s.LinenoMode),
+		Bounds:       echoBounds(s.Bounds, axes),
+		VerticalUnit: echoVerticalUnit(s.VerticalUnit, axes),
+		FillValue:    s.FillValue,
+		Raw:          s.Raw,
+	}
+}
+
+func (f FenceRequest) echoes() bool {
+	return f.Echo
+}
+
+func (f FenceRequest) echo(axes []*core.Axis) RequestEcho {
+	f = f.canonical()
+	coordinates := len(f.Coordinates)
+	var fillValue *core.FillValue
+	if f.FillValue != nil {
+		value := core.FillValue(*f.FillValue)
+		fillValue = &value
+	}
+	return RequestEcho{
+		Endpoint:         "fence",
+		Vds:              f.Vds,
+		CoordinateSystem: f.CoordinateSystem,
+		Coordinates:      &coordinates,
+		Interpolation:    f.Interpolation,
+		ResampleTo:       f.ResampleTo,
+		HeaderFields:     f.HeaderFields,
+		VerticalUnit:     echoVerticalUnit(f.VerticalUnit, axes),
+		FillValue:        fillValue,
+	}
+}
+
+func (s SampleRequest) echoes() bool {
+	return s.Echo
+}
+
+func (s SampleRequest) echo(axes []*core.Axis) RequestEcho {
+	s = s.canonical()
+	coordinates := len(s.Coordinates)
+	var fillValue *core.FillValue
+	if s.FillValue != nil {
+		value := core.FillValue(*s.FillValue)
+		fillValue = &value
+	}
+	return RequestEcho{
+		Endpoint:         "sample",
+		Vds:              s.Vds,
+		CoordinateSystem: s.CoordinateSystem,
+		Coordinates:      &coordinates,
+		Interpolation:    s.Interpolation,
+		VerticalUnit:     echoVerticalUnit(s.VerticalUnit, axes),
+		FillValue:        fillValue,
+	}
+}
+
+func (a AttributeRequest) echoes() bool {
+	return a.Echo
+}
+
+/** The echo shared by the attribute endpoints
+ *
+ * A stepsize of zero is the vertical stepsize of the cube, in the vertical
+ * unit of the request.
+ */
+func (a AttributeRequest) attributeEcho(
+	endpoint string,
+	points int,
+	above float32,
+	below float32,
+	axes []*core.Axis,
+) RequestEcho {
+	a = a.canonical()
+	unit := echoVerticalUnit(a.VerticalUnit, axes)
+
+	stepsize := a.Stepsize
+	if stepsize == 0 && len(axes) > 0 {
+		vertical := axes[len(axes)-1]
+		conversion, err := core.NewVerticalUnitConversion(vertical.Unit, unit)
+		if err == nil {
+			stepsize = float32(conversion.FromNative(vertical.StepSize))
+		}
+	}
+
+	return RequestEcho{
+		Endpoint:              endpoint,
+		Vds:                   a.Vds,
+		SurfacePoints:         &points,
+		Interpolation:         a.Interpolation,
+		VerticalInterpolation: a.VerticalInterpolation,
+		Attributes:            a.resolvedAttributes(),
+		Above:                 &above,
+		Below:                 &below,
+		Stepsize:              &stepsize,
+		VerticalUnit:          unit,
+	}
+}
+
+func (h AttributeAlongSurfaceRequest) echo(axes []*core.Axis) RequestEcho {
+	points := 0
+	if h.Surface != nil {
+		points = surfacePoints(*h.Surface)
+	}
+	return h.AttributeRequest.attributeEcho(
+		"attributes/surface/along",
+		points,
+		h.Above,
+		h.Below,
+		axes,
+	)
+}
+
+/* Between surfaces the window is given by the surfaces, not above and below */
+func (h AttributeBetweenSurfacesRequest) echo(axes []*core.Axis) RequestEcho {
+	echo := h.AttributeRequest.attributeEcho(
+		"attributes/surface/between",
+		surfacePoints(h.PrimarySurface),
+		0,
+		0,
+		axes,
+	)
+	echo.Above = nil
+	echo.Below = nil
+	return echo
+}
+
+func (t TraverseRequest) echoes() bool {
+	return t.Echo
+}
+
+/* The bounds of a traverse are those of its first segment, as all must agree */
+func (t TraverseRequest) echo(axes []*core.Axis) RequestEcho {
+	t = t.canonical()
+	segments := len(t.Segments)
+	var bounds []core.Bound
+	if segments > 0 {
+		bounds = echoBounds(t.Segments[0].Bounds, axes)
+	}
+	return RequestEcho{
+		Endpoint:     "traverse",
+		Vds:          t.Vds,
+		Segments:     &segments,
+		Bounds:       bounds,
+		VerticalUnit: echoVerticalUnit(t.VerticalUnit, axes),
+		FillValue:    t.FillValue,
+	}
+}
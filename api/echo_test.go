@@ -0,0 +1,120 @@
+package api
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/equinor/vds-slice/internal/core"
+)
+
+func TestAppendMember(t *testing.T) {
+	testcases := []struct {
+		name     string
+		object   string
+		expected string
+	}{
+		{
+			name:     "Object with members",
+			object:   `{"format":"<f4","shape":[2,3]}`,
+			expected: `{"format":"<f4","shape":[2,3],"request":{"a":1}}`,
+		},
+		{
+			name:     "Empty object",
+			object:   `{}`,
+			expected: `{"request":{"a":1}}`,
+		},
+		{
+			name:     "Trailing whitespace",
+			object:   "{\"format\":\"<f4\"}\n",
+			expected: `{"format":"<f4","request":{"a":1}}`,
+		},
+	}
+
+	for _, testcase := range testcases {
+		out, err := appendMember([]byte(testcase.object), "request", []byte(`{"a":1}`))
+		require.NoError(t, err, testcase.name)
+		require.JSONEq(t, testcase.expected, string(out), testcase.name)
+	}
+
+	_, err := appendMember([]byte(`[1, 2]`), "request", []byte(`{}`))
+	require.Error(t, err)
+}
+
+func TestSliceEcho(t *testing.T) {
+	axes := []*core.Axis{
+		{Annotation: "Inline", Min: 1, Max: 5, Samples: 3, StepSize: 2},
+		{Annotation: "Crossline", Min: 10, Max: 11, Samples: 2, StepSize: 1},
+		{Annotation: "Sample", Min: 4, Max: 16, Samples: 4, StepSize: 4, Unit: "ms"},
+	}
+
+	lineno := 1
+	lower, upper := 4, 8
+	boundDirection := "2"
+	fillValue := core.FillValue(-999.25)
+	request := SliceRequest{
+		RequestedResource: newRequestedResource("vds", "sas"),
+		Direction:         "0",
+		Lineno:            &lineno,
+		Bounds: []core.Bound{
+			{Direction: &boundDirection, Lower: &lower, Upper: &upper},
+		},
+		FillValue: &fillValue,
+		Echo:      true,
+	}
+
+	echo, err := json.Marshal(request.echo(axes))
+	require.NoError(t, err)
+	require.NotContains(t, string(echo), "sas")
+
+	expected := `{
+		"endpoint": "slice",
+		"vds": "vds",
+		"direction": "i",
+		"lineno": 1,
+		"linenoMode": "index",
+		"bounds": [
+			{"direction": "k", "lower": 4, "upper": 8, "mode": "index"}
+		],
+		"verticalUnit": "ms",
+		"fillValue": -999.25
+	}`
+	require.JSONEq(t, expected, string(echo))
+
+	/* Without the axes, only what can be resolved without them is */
+	unresolved := request.echo(nil)
+	require.Equal(t, "0", unresolved.Direction)
+	require.Equal(t, "", unresolved.VerticalUnit)
+}
+
+func TestAttributeEchoDefaults(t *testing.T) {
+	axes := []*core.Axis{
+		{Annotation: "Inline", Min: 1, Max: 5, Samples: 3, StepSize: 2},
+		{Annotation: "Crossline", Min: 10, Max: 11, Samples: 2, StepSize: 1},
+		{Annotation: "Depth", Min: 4, Max: 16, Samples: 4, StepSize: 4, Unit: "m"},
+	}
+
+	request := AttributeAlongSurfaceRequest{
+		AttributeRequest: AttributeRequest{
+			RequestedResource: newRequestedResource("vds", "sas"),
+			Attributes:        []string{"Min", "max"},
+			VerticalUnit:      "ft",
+			Echo:              true,
+		},
+		Surface: &core.RegularSurface{Values: [][]float32{{1, 2, 3}, {4, 5, 6}}},
+		Above:   8,
+		Below:   4,
+	}
+
+	echo := request.echo(axes)
+	require.Equal(t, "attributes/surface/along", echo.Endpoint)
+	require.Equal(t, 6, *echo.SurfacePoints)
+	require.Equal(t, []string{"min", "max"}, echo.Attributes)
+	require.Equal(t, "nearest", echo.Interpolation)
+	require.Equal(t, "cubic", echo.VerticalInterpolation)
+	require.Equal(t, "ft", echo.VerticalUnit)
+	require.InDelta(t, 4/0.3048, *echo.Stepsize, 1e-3)
+	require.Equal(t, float32(8), *echo.Above)
+	require.Equal(t, float32(4), *echo.Below)
+}
@@ -0,0 +1,110 @@
+package api
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/equinor/vds-slice/internal/core"
+)
+
+/*
+ * The seismic endpoints, by the names they are enabled by. A name covers
+ * every route and rpc of the endpoint, e.g. slice is both /slice and
+ * /slice/progressive, metadata is both /metadata and /metadata/ranges, and
+ * attributes is both of the attribute endpoints.
+ */
+const (
+	EndpointMetadata      = "metadata"
+	EndpointSlice         = "slice"
+	EndpointFence         = "fence"
+	EndpointSample        = "sample"
+	EndpointTraverse      = "traverse"
+	EndpointBatch         = "batch"
+	EndpointCompatibility = "compatibility"
+	EndpointAttributes    = "attributes"
+)
+
+var endpointNames = []string{
+	EndpointMetadata,
+	EndpointSlice,
+	EndpointFence,
+	EndpointSample,
+	EndpointTraverse,
+	EndpointBatch,
+	EndpointCompatibility,
+	EndpointAttributes,
+}
+
+/** The seismic endpoints that are enabled in a deployment
+ *
+ * Some deployments don't expose the expensive endpoints, e.g. the attributes,
+ * for capacity reasons. The zero value enables every endpoint.
+ */
+type EnabledEndpoints struct {
+	/* Nil if every endpoint is enabled */
+	enabled map[string]bool
+}
+
+/** Enable the named endpoints only, or all of them if names is empty */
+func NewEnabledEndpoints(names []string) (EnabledEndpoints, error) {
+	if len(names) == 0 {
+		return EnabledEndpoints{}, nil
+	}
+
+	valid := map[string]bool{}
+	for _, name := range endpointNames {
+		valid[name] = true
+	}
+
+	enabled := map[string]bool{}
+	for _, name := range names {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if !valid[name] {
+			return EnabledEndpoints{}, fmt.Errorf(
+				"invalid endpoint '%s', valid options are: %s",
+				name,
+				strings.Join(endpointNames, ", "),
+			)
+		}
+		enabled[name] = true
+	}
+	return EnabledEndpoints{enabled: enabled}, nil
+}
+
+func (e EnabledEndpoints) Enabled(name string) bool {
+	return e.enabled == nil || e.enabled[name]
+}
+
+/* The enabled endpoints, in the order of endpointNames */
+func (e EnabledEndpoints) Names() []string {
+	names := []string{}
+	for _, name := range endpointNames {
+		if e.Enabled(name) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+/* The error of requests to a disabled endpoint */
+func endpointDisabledError(name string) error {
+	return core.NewCodedNotFoundError(
+		"endpoint_disabled",
+		core.ErrorDetails{"endpoint": name},
+		fmt.Sprintf("The %s endpoint is disabled in this deployment", name),
+	)
+}
+
+/** Answer requests to a disabled endpoint with 404
+ *
+ * Registered in place of the handlers of the endpoint, such that clients are
+ * told why the route is missing, and the requests are still labeled by their
+ * route in the metrics.
+ */
+func EndpointDisabled(name string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		abortOnError(ctx, endpointDisabledError(name))
+	}
+}
@@ -1,14 +1,27 @@
 package api
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"log"
+	"math"
 	"net/http"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
 
+	"github.com/equinor/vds-slice/internal/audit"
 	"github.com/equinor/vds-slice/internal/cache"
 	"github.com/equinor/vds-slice/internal/core"
 )
@@ -17,6 +30,18 @@ func httpStatusCode(err error) int {
 	switch err.(type) {
 	case *core.InvalidArgument:
 		return http.StatusBadRequest
+	case *core.UnauthorizedError:
+		return http.StatusUnauthorized
+	case *core.ForbiddenError:
+		return http.StatusForbidden
+	case *core.NotFoundError:
+		return http.StatusNotFound
+	case *core.PayloadTooLargeError:
+		return http.StatusRequestEntityTooLarge
+	case *core.UnavailableError:
+		return http.StatusServiceUnavailable
+	case *core.DeadlineExceededError:
+		return http.StatusGatewayTimeout
 	case *core.InternalError:
 		return http.StatusInternalServerError
 	default:
@@ -47,6 +72,20 @@ func abortOnError(ctx *gin.Context, err error) bool {
 		return false
 	}
 
+	if unavailable, ok := err.(*core.UnavailableError); ok {
+		seconds := int(math.Ceil(unavailable.RetryAfter().Seconds()))
+		ctx.Header("Retry-After", strconv.Itoa(seconds))
+	}
+
+	/* Timeouts are counted by where the deadline came from, see metrics */
+	if deadline, ok := err.(*core.DeadlineExceededError); ok {
+		source := "server"
+		if deadline.Client() {
+			source = "client"
+		}
+		ctx.Set("deadline-exceeded", source)
+	}
+
 	ctx.AbortWithError(httpStatusCode(err), err)
 
 	return true
@@ -55,6 +94,51 @@ func abortOnError(ctx *gin.Context, err error) bool {
 type Endpoint struct {
 	MakeVdsConnection core.ConnectionMaker
 	Cache             cache.Cache
+	Version           string
+	Limits            Limits
+	Retry             core.RetryPolicy
+	Breaker           *core.CircuitBreaker
+	Warmup            *Warmup
+	Resolver          core.VdsResolver
+	Observer          RequestObserver
+	Budget            *core.MemoryBudget
+	FenceBatching     FenceBatching
+	// Interpolation method and fill value of requests that do not give them,
+	// see core.Defaults
+	Defaults core.Defaults
+	// Serve cached responses when storage is down, see StaleIfError
+	StaleIfError *StaleIfError
+	// The endpoints of the deployment, all of them if not set
+	Endpoints EnabledEndpoints
+	// Replaces Limits, such that they can be reloaded, if set
+	Config *LiveConfig
+	// The longest a request may take, also with a deadline from the client.
+	// No limit if zero, see Deadline
+	RequestTimeout time.Duration
+	// Computes the attributes of all requests, see core.WorkerPool
+	Workers *core.WorkerPool
+	// Rate limits /validate, on top of the rate limit of all requests
+	ValidateLimiter gin.HandlerFunc
+	// Records who accessed which cube, no audit log if nil
+	Audit *audit.Logger
+	// Opens the cubes requests are read from, core.OpenDSHandle if nil. See
+	// the vdstest package for cubes that live in memory
+	OpenHandle core.HandleOpener
+}
+
+func (e *Endpoint) opener() core.HandleOpener {
+	if e.OpenHandle == nil {
+		return core.OpenDSHandle
+	}
+	return e.OpenHandle
+}
+
+/** Open the cube conn points to, retrying transient failures of the reads */
+func (e *Endpoint) openHandle(
+	ctx context.Context,
+	conn core.Connection,
+) (core.DSHandle, error) {
+	return e.Retry.Open(ctx, e.opener(), conn)
 }
 
 func prepareRequestLogging(ctx *gin.Context, request Stringable) {
@@ -63,25 +147,255 @@ func prepareRequestLogging(ctx *gin.Context, request Stringable) {
 	ctx.Set("request", requestString)
 }
 
+/** Fetch the metadata of a VDS, or serve it from the cache
+ *
+ * Shared by the http and grpc servers, and the metadata list request.
+ */
+func (e *Endpoint) fetchMetadata(
+	ctx context.Context,
+	request MetadataRequest,
+) ([]byte, error) {
+	buffer, _, err := e.fetchMetadataOrStale(ctx, request)
+	return buffer, err
+}
+
+/* Like fetchMetadata, but also tells if the metadata is served stale */
+func (e *Endpoint) fetchMetadataOrStale(
+	ctx context.Context,
+	request MetadataRequest,
+) (buffer []byte, stale bool, err error) {
+	conn, err := e.MakeVdsConnection(request.credentials())
+	if err != nil {
+		return nil, false, err
+	}
+
+	cacheKey, err := request.hash()
+	if err != nil {
+		return nil, false, err
+	}
+
+	vds, credentials := request.credentials()
+	cacheEntry, hit := e.Cache.Get(cacheKey)
+	if hit && conn.IsAuthorizedToRead() {
+		e.StaleIfError.verify(vds, credentials)
+		return cacheEntry.Metadata(), false, nil
+	}
+
+	host := core.StorageHost(request.Vds)
+	err = e.Breaker.Do(host, func() error {
+		if err := checkDeadline(ctx); err != nil {
+			return err
+		}
+		handle, err := e.openHandle(ctx, conn)
+		if err != nil {
+			return err
+		}
+		defer handle.Close()
+
+		buffer, err = handle.GetMetadata(
+			request.IncludeImportInfo,
+			request.IncludeLayout,
+		)
+		return err
+	})
+	if err != nil {
+		if hit && e.StaleIfError.serves(vds, credentials, err) {
+			return cacheEntry.Metadata(), true, nil
+		}
+		return nil, false, err
+	}
+
+	e.StaleIfError.verify(vds, credentials)
+	e.Cache.Set(cacheKey, cache.NewCacheEntry(nil, buffer, nil))
+	return buffer, false, nil
+}
+
 func (e *Endpoint) metadata(ctx *gin.Context, request MetadataRequest) {
 	prepareRequestLogging(ctx, request)
-	conn, err := e.MakeVdsConnection(request.Vds, request.Sas)
+	defer e.audit(ctx, request, "")
+	buffer, stale, err := e.fetchMetadataOrStale(ctx.Request.Context(), request)
+	if abortOnError(ctx, err) {
+		return
+	}
+	if stale {
+		setStaleHeaders(ctx)
+	}
+
+	etag, err := cache.Hash(buffer)
 	if abortOnError(ctx, err) {
 		return
 	}
 
-	handle, err := core.NewDSHandle(conn)
+	ctx.Header("ETag", weakETag(etag))
+	ctx.Data(http.StatusOK, "application/json", buffer)
+}
+
+/** Answer a HEAD request for metadata with the headers a GET would give */
+func (e *Endpoint) metadataHead(ctx *gin.Context, request MetadataRequest) {
+	prepareRequestLogging(ctx, request)
+	defer e.audit(ctx, request, "")
+	buffer, stale, err := e.fetchMetadataOrStale(ctx.Request.Context(), request)
 	if abortOnError(ctx, err) {
 		return
 	}
-	defer handle.Close()
+	if stale {
+		setStaleHeaders(ctx)
+	}
 
-	buffer, err := handle.GetMetadata()
+	etag, err := cache.Hash(buffer)
 	if abortOnError(ctx, err) {
 		return
 	}
 
-	ctx.Data(http.StatusOK, "application/json", buffer)
+	ctx.Header("ETag", weakETag(etag))
+	ctx.Header("Content-Type", "application/json")
+	ctx.Header("Content-Length", strconv.Itoa(len(buffer)))
+	ctx.Status(http.StatusOK)
+}
+
+type dataResponse struct {
+	metadata  []byte
+	data      [][]byte
+	checksums []uint32
+	/* The request hash, which is also the cache key */
+	hash     string
+	cacheHit bool
+	/* Served from the cache as storage failed, see StaleIfError */
+	stale bool
+	/* The work done against storage, summed over all attempts */
+	storage core.RequestStats
+	/* Releases the memory reserved for the response, once it is written */
+	release func()
+}
+
+/** Execute a data request, or serve it from the cache
+ *
+ * Shared by the http and grpc servers. The size of the response is reserved
+ * against the memory budget before it is executed, and the caller must
+ * release it once the response is written.
+ */
+func (e *Endpoint) fetchData(
+	ctx context.Context,
+	request DataRequest,
+) (*dataResponse, error) {
+	conn, err := e.MakeVdsConnection(request.credentials())
+	if err != nil {
+		return nil, err
+	}
+
+	cacheKey, err := request.hash()
+	if err != nil {
+		return nil, err
+	}
+
+	vds, credentials := request.credentials()
+	cacheEntry, hit := e.Cache.Get(cacheKey)
+	if hit && conn.IsAuthorizedToRead() {
+		e.StaleIfError.verify(vds, credentials)
+		e.observeRequest(request, cacheEntry.Metadata())
+		return &dataResponse{
+			metadata:  cacheEntry.Metadata(),
+			data:      cacheEntry.Data(),
+			checksums: cacheEntry.Checksums(),
+			hash:      cacheKey,
+			cacheHit:  true,
+			release:   func() {},
+		}, nil
+	}
+
+	var data [][]byte
+	var metadata []byte
+	var storage core.RequestStats
+	var release func()
+	err = e.Breaker.Do(core.StorageHost(vds), func() error {
+		if err := checkDeadline(ctx); err != nil {
+			return err
+		}
+		handle, err := e.openHandle(ctx, conn)
+		if err != nil {
+			return err
+		}
+		defer handle.Close()
+		handle = handle.WithWorkerPool(e.Workers)
+
+		release, err = e.Budget.ReserveEstimate(func() (int64, error) {
+			return request.estimateSize(handle)
+		})
+		if err != nil {
+			return err
+		}
+		if err := checkDeadline(ctx); err != nil {
+			return err
+		}
+
+		data, metadata, err = request.execute(handle)
+		storage = handleStats(handle)
+		return err
+	})
+	if err != nil {
+		if release != nil {
+			release()
+		}
+		if hit && e.StaleIfError.serves(vds, credentials, err) {
+			e.observeRequest(request, cacheEntry.Metadata())
+			return &dataResponse{
+				metadata:  cacheEntry.Metadata(),
+				data:      cacheEntry.Data(),
+				checksums: cacheEntry.Checksums(),
+				hash:      cacheKey,
+				cacheHit:  true,
+				stale:     true,
+				release:   func() {},
+			}, nil
+		}
+		return nil, err
+	}
+
+	e.StaleIfError.verify(vds, credentials)
+	e.observeRequest(request, metadata)
+	checksums := dataChecksums(data)
+	e.Cache.Set(cacheKey, cache.NewCacheEntry(data, metadata, checksums))
+
+	return &dataResponse{
+		metadata:  metadata,
+		data:      data,
+		checksums: checksums,
+		hash:      cacheKey,
+		storage:   storage,
+		release:   release,
+	}, nil
+}
+
+/** The work done against storage through handle
+ *
+ * The statistics are only used for accounting, so failing to get them is not
+ * an error to the request.
+ */
+func handleStats(handle core.DSHandle) core.RequestStats {
+	stats, err := handle.Stats()
+	if err != nil {
+		return core.RequestStats{}
+	}
+	return stats
+}
+
+/** Make the work done against storage known to the metrics and logs
+ *
+ * The gin middlewares read the statistics from the context once the handler
+ * returns.
+ */
+func setStorageStats(ctx *gin.Context, stats core.RequestStats) {
+	ctx.Set("storage-bytes", stats.StorageBytes)
+	ctx.Set("storage-requests", stats.StorageRequests)
+	ctx.Set("storage-read-time", stats.ReadTime)
+}
+
+/* Report the size of a successful data request, if anyone is observing */
+func (e *Endpoint) observeRequest(request DataRequest, metadata []byte) {
+	if e.Observer == nil {
+		return
+	}
+	request.observe(e.Observer, metadata)
 }
 
 func (e *Endpoint) makeDataRequest(
@@ -89,6 +403,334 @@ func (e *Endpoint) makeDataRequest(
 	request DataRequest,
 ) {
 	prepareRequestLogging(ctx, request)
+	var hash string
+	defer func() { e.audit(ctx, request, hash) }()
+	hash = e.serveDataRequest(ctx, request)
+}
+
+/** makeDataRequest, for handlers that do the logging and auditing themselves
+ *
+ * Returns the request hash, if the request got as far as to know it.
+ */
+func (e *Endpoint) serveDataRequest(
+	ctx *gin.Context,
+	request DataRequest,
+) (hash string) {
+	response, err := e.fetchData(ctx.Request.Context(), request)
+	if abortOnError(ctx, err) {
+		return ""
+	}
+	defer response.release()
+	hash = response.hash
+
+	if response.cacheHit {
+		ctx.Set("cache-hit", true)
+	}
+	if response.stale {
+		setStaleHeaders(ctx)
+	}
+	setStorageStats(ctx, response.storage)
+	ctx.Header("ETag", weakETag(response.hash))
+
+	dataOnly, ok := request.(dataOnlyRequest)
+	if ok && dataOnly.omitsMetadata() {
+		names, err := dataOnly.partNames(response.metadata)
+		if abortOnError(ctx, err) {
+			return hash
+		}
+		writeDataOnlyResponse(ctx, names, response.data, response.checksums)
+		return hash
+	}
+
+	metadata, err := e.withEcho(ctx.Request.Context(), request, response.metadata)
+	if abortOnError(ctx, err) {
+		return hash
+	}
+	writeResponse(ctx, metadata, response.data, response.checksums)
+	return hash
+}
+
+/* Strides of the passes of a progressive slice, coarsest first */
+var progressiveStrides = []int{8, 4, 2, 1}
+
+/** Send a slice in progressively finer passes
+ *
+ * Every pass is read with only the rows of the slice it needs, see
+ * core.PassRows, and sent as soon as it is read, such that the coarse passes
+ * arrive long before the whole slice is read. Slices served from the cache
+ * are split into passes with core.InterlaceSlice instead. Once every pass is
+ * read the whole slice is, and it is cached like a regular slice.
+ */
+func (e *Endpoint) makeProgressiveSliceRequest(
+	ctx *gin.Context,
+	request SliceRequest,
+) {
+	prepareRequestLogging(ctx, request)
+	var hash string
+	defer func() { e.audit(ctx, request, hash) }()
+	if request.OmitMetadata {
+		abortOnError(ctx, core.NewInvalidArgument(
+			"omitMetadata is not supported for progressive slices, as the "+
+				"metadata describes the passes",
+		))
+		return
+	}
+
+	conn, err := e.MakeVdsConnection(request.credentials())
+	if abortOnError(ctx, err) {
+		return
+	}
+
+	hash, err = request.hash()
+	if abortOnError(ctx, err) {
+		return
+	}
+
+	vds, credentials := request.credentials()
+	cacheEntry, hit := e.Cache.Get(hash)
+	if hit && conn.IsAuthorizedToRead() {
+		e.StaleIfError.verify(vds, credentials)
+		e.observeRequest(request, cacheEntry.Metadata())
+		e.writeCachedProgressiveSlice(ctx, request, cacheEntry)
+		return
+	}
+
+	started, err := e.streamProgressiveSlice(ctx, request, conn, hash)
+	if err == nil {
+		return
+	}
+	if started {
+		/* The status is already sent, see progressiveResponse */
+		log.Println(err)
+		ctx.Abort()
+		return
+	}
+	if hit && e.StaleIfError.serves(vds, credentials, err) {
+		e.observeRequest(request, cacheEntry.Metadata())
+		setStaleHeaders(ctx)
+		e.writeCachedProgressiveSlice(ctx, request, cacheEntry)
+		return
+	}
+	abortOnError(ctx, err)
+}
+
+/** Read the passes of a progressive slice, and send each one once it is read
+ *
+ * Returns whether the response is started, after which errors can no longer
+ * be sent to the client. Failures to write to the client are not returned,
+ * as they are no fault of the storage.
+ */
+func (e *Endpoint) streamProgressiveSlice(
+	ctx *gin.Context,
+	request SliceRequest,
+	conn core.Connection,
+	hash string,
+) (started bool, err error) {
+	var storage core.RequestStats
+	defer func() { setStorageStats(ctx, storage) }()
+
+	vds, credentials := request.credentials()
+	var data []byte
+	var metadata []byte
+	var complete bool
+	err = e.Breaker.Do(core.StorageHost(vds), func() error {
+		if err := checkDeadline(ctx.Request.Context()); err != nil {
+			return err
+		}
+		handle, err := e.openHandle(ctx.Request.Context(), conn)
+		if err != nil {
+			return err
+		}
+		defer handle.Close()
+		handle = handle.WithWorkerPool(e.Workers)
+		defer func() { storage = handleStats(handle) }()
+
+		release, err := e.Budget.ReserveEstimate(func() (int64, error) {
+			return request.estimateSize(handle)
+		})
+		if err != nil {
+			return err
+		}
+		defer release()
+
+		metadata, err = request.executeMetadata(handle)
+		if err != nil {
+			return err
+		}
+
+		var array core.Array
+		err = json.Unmarshal(metadata, &array)
+		if err != nil {
+			return core.NewInternalError(err.Error())
+		}
+		if len(array.Shape) != 2 {
+			msg := fmt.Sprintf("Expected a 2D slice, got shape %v", array.Shape)
+			return core.NewInternalError(msg)
+		}
+		itemsize, err := itemSize(array.Format)
+		if err != nil {
+			return err
+		}
+
+		readRows, err := request.rowReader(handle)
+		if err != nil {
+			return err
+		}
+
+		rowsize := array.Shape[1] * itemsize
+		data = make([]byte, array.Shape[0]*rowsize)
+		var response *progressiveResponse
+		for n := range progressiveStrides {
+			if err := checkDeadline(ctx.Request.Context()); err != nil {
+				return err
+			}
+			for _, rows := range core.PassRows(progressiveStrides, n) {
+				first, stride := rows[0], rows[1]
+				if first >= array.Shape[0] {
+					continue
+				}
+				buf, err := readRows(first, stride)
+				if err != nil {
+					return err
+				}
+				err = copyRows(data, buf, rowsize, first, stride)
+				if err != nil {
+					return err
+				}
+			}
+
+			pass, err := core.SlicePass(
+				data,
+				array.Shape,
+				itemsize,
+				progressiveStrides,
+				n,
+			)
+			if err != nil {
+				return err
+			}
+
+			if response == nil {
+				echoed, err := e.withEcho(ctx.Request.Context(), request, metadata)
+				if err != nil {
+					return err
+				}
+
+				sizes := []int{}
+				for i := range progressiveStrides {
+					samples := core.PassSize(array.Shape, progressiveStrides, i)
+					sizes = append(sizes, samples*itemsize)
+				}
+				started = true
+				response = startProgressiveResponse(
+					ctx,
+					echoed,
+					sizes,
+					progressiveStrides,
+				)
+				if response == nil {
+					return nil
+				}
+			}
+			if response.writePass(pass) != nil {
+				return nil
+			}
+		}
+		response.close()
+		complete = true
+		return nil
+	})
+	if err != nil || !complete {
+		return started, err
+	}
+
+	e.StaleIfError.verify(vds, credentials)
+	e.observeRequest(request, metadata)
+	slice := [][]byte{data}
+	e.Cache.Set(hash, cache.NewCacheEntry(slice, metadata, dataChecksums(slice)))
+	return started, nil
+}
+
+/** Copy the rows read by GetSliceRows into their place in the slice
+ *
+ * rows holds every stride'th row of data, starting at row first, and rowsize
+ * is the size of a single row in bytes.
+ */
+func copyRows(data []byte, rows []byte, rowsize int, first int, stride int) error {
+	if rowsize == 0 {
+		return nil
+	}
+	for offset := first * rowsize; offset < len(data); offset += stride * rowsize {
+		if len(rows) < rowsize {
+			return core.NewInternalError("Read fewer rows of the slice than expected")
+		}
+		copy(data[offset:offset+rowsize], rows[:rowsize])
+		rows = rows[rowsize:]
+	}
+	if len(rows) != 0 {
+		return core.NewInternalError("Read more rows of the slice than expected")
+	}
+	return nil
+}
+
+/* A progressive slice from the cache, see makeProgressiveSliceRequest */
+func (e *Endpoint) writeCachedProgressiveSlice(
+	ctx *gin.Context,
+	request SliceRequest,
+	entry cache.CacheEntry,
+) {
+	var array core.Array
+	err := json.Unmarshal(entry.Metadata(), &array)
+	if err != nil {
+		abortOnError(ctx, core.NewInternalError(err.Error()))
+		return
+	}
+
+	itemsize, err := itemSize(array.Format)
+	if abortOnError(ctx, err) {
+		return
+	}
+
+	passes, err := core.InterlaceSlice(
+		entry.Data()[0],
+		array.Shape,
+		itemsize,
+		progressiveStrides,
+	)
+	if abortOnError(ctx, err) {
+		return
+	}
+
+	metadata, err := e.withEcho(ctx.Request.Context(), request, entry.Metadata())
+	if abortOnError(ctx, err) {
+		return
+	}
+
+	ctx.Set("cache-hit", true)
+	writeProgressiveResponse(ctx, metadata, passes, progressiveStrides)
+}
+
+/*
+ * Data requests that can answer HEAD requests, by doing the metadata half of
+ * the work only.
+ */
+type headRequest interface {
+	DataRequest
+	executeMetadata(handle core.DSHandle) ([]byte, error)
+}
+
+/** Answer a HEAD request for data with the headers a GET would give
+ *
+ * Only the metadata is fetched. The size of the data is computed from the
+ * shape in the metadata, such that Content-Length is that of the
+ * uncompressed response.
+ */
+func (e *Endpoint) makeDataHeadRequest(
+	ctx *gin.Context,
+	request headRequest,
+) {
+	prepareRequestLogging(ctx, request)
+	defer e.audit(ctx, request, "")
 	conn, err := e.MakeVdsConnection(request.credentials())
 	if abortOnError(ctx, err) {
 		return
@@ -99,208 +741,1716 @@ func (e *Endpoint) makeDataRequest(
 		return
 	}
 
+	vds, credentials := request.credentials()
 	cacheEntry, hit := e.Cache.Get(cacheKey)
-	if hit && conn.IsAuthorizedToRead() {
+	writeCached := func() {
 		ctx.Set("cache-hit", true)
-		writeResponse(ctx, cacheEntry.Metadata(), cacheEntry.Data())
+
+		sizes := []int{}
+		for _, part := range cacheEntry.Data() {
+			sizes = append(sizes, len(part))
+		}
+		metadata, err := e.withEcho(
+			ctx.Request.Context(),
+			request,
+			cacheEntry.Metadata(),
+		)
+		if abortOnError(ctx, err) {
+			return
+		}
+		ctx.Header("ETag", weakETag(cacheKey))
+		writeHeadResponseHeaders(ctx, request, metadata, sizes)
+	}
+
+	if hit && conn.IsAuthorizedToRead() {
+		e.StaleIfError.verify(vds, credentials)
+		writeCached()
 		return
 	}
 
-	handle, err := core.NewDSHandle(conn)
+	var metadata []byte
+	err = e.Breaker.Do(core.StorageHost(vds), func() error {
+		if err := checkDeadline(ctx.Request.Context()); err != nil {
+			return err
+		}
+		handle, err := e.openHandle(ctx.Request.Context(), conn)
+		if err != nil {
+			return err
+		}
+		defer handle.Close()
+
+		metadata, err = request.executeMetadata(handle)
+		return err
+	})
+	if err != nil && hit && e.StaleIfError.serves(vds, credentials, err) {
+		setStaleHeaders(ctx)
+		writeCached()
+		return
+	}
 	if abortOnError(ctx, err) {
 		return
 	}
-	defer handle.Close()
+	e.StaleIfError.verify(vds, credentials)
 
-	data, metadata, err := request.execute(handle)
+	size, err := dataSize(metadata)
 	if abortOnError(ctx, err) {
 		return
 	}
 
-	e.Cache.Set(cacheKey, cache.NewCacheEntry(data, metadata))
+	metadata, err = e.withEcho(ctx.Request.Context(), request, metadata)
+	if abortOnError(ctx, err) {
+		return
+	}
 
-	writeResponse(ctx, metadata, data)
+	ctx.Header("ETag", weakETag(cacheKey))
+	writeHeadResponseHeaders(ctx, request, metadata, []int{size})
 }
 
-func (request SliceRequest) execute(
+/* The headers of a GET of the request, with or without the metadata part */
+func writeHeadResponseHeaders(
+	ctx *gin.Context,
+	request headRequest,
+	metadata []byte,
+	sizes []int,
+) {
+	dataOnly, ok := request.(dataOnlyRequest)
+	if !ok || !dataOnly.omitsMetadata() {
+		writeResponseHeaders(ctx, metadata, sizes)
+		return
+	}
+	writeDataOnlyResponseHeaders(ctx, sizes[0])
+}
+
+/* Directions along the vertical axis */
+func isVertical(axis int) bool {
+	return axis == core.AxisK || isVerticalAnnotation(axis)
+}
+
+/* Directions that are given in the unit of the vertical axis */
+func isVerticalAnnotation(axis int) bool {
+	return axis == core.AxisDepth ||
+		axis == core.AxisTime ||
+		axis == core.AxisSample
+}
+
+/*
+ * Bounds along the vertical axis given in annotation, which are in the unit
+ * of the request. Bounds with an invalid direction or mode are not, and are
+ * left for core to report.
+ */
+func isVerticalAnnotationBound(bound core.Bound) bool {
+	axis, err := core.GetAxis(strings.ToLower(*bound.Direction))
+	if err != nil || !isVertical(axis) {
+		return false
+	}
+	system, err := core.GetLinenoSystem(axis, bound.Mode)
+	return err == nil && system == core.CoordinateSystemAnnotation
+}
+
+/** The request with directions that refer to the axes of the cube
+ *
+ * Directions such as "0" or "Inline", see core.GetAxis, are replaced by the
+ * fixed vocabulary that core reads. An invalid direction of the slice is
+ * reported with the axes of the cube, while bounds with invalid directions
+ * are left for core to report.
+ */
+func (request SliceRequest) resolveDirections(
 	handle core.DSHandle,
-) (data [][]byte, metadata []byte, err error) {
-	axis, err := core.GetAxis(strings.ToLower(request.Direction))
+) (SliceRequest, error) {
+	axes, err := handle.Axes()
 	if err != nil {
-		return
+		return request, err
 	}
 
-	metadata, err = handle.GetSliceMetadata(
-		*request.Lineno,
-		axis,
-		request.Bounds,
-	)
+	axis, err := core.GetAxis(strings.ToLower(request.Direction), axes...)
 	if err != nil {
-		return
+		return request, err
+	}
+	request.Direction = core.AxisName(axis)
+
+	bounds := make([]core.Bound, len(request.Bounds))
+	for i, bound := range request.Bounds {
+		if bound.Direction != nil {
+			axis, err := core.GetAxis(strings.ToLower(*bound.Direction), axes...)
+			if err == nil {
+				direction := core.AxisName(axis)
+				bound.Direction = &direction
+			}
+		}
+		bounds[i] = bound
 	}
+	request.Bounds = bounds
+	return request, nil
+}
 
-	res, err := handle.GetSlice(*request.Lineno, axis, request.Bounds)
+/* The direction of the slice, and how its lineno is interpreted */
+func (request SliceRequest) axis() (axis int, linenoSystem int, err error) {
+	axis, err = core.GetAxis(strings.ToLower(request.Direction))
 	if err != nil {
 		return
 	}
-	data = [][]byte{res}
+	linenoSystem, err = core.GetLinenoSystem(axis, request.LinenoMode)
+	return
+}
 
-	return data, metadata, nil
+/** The lineno and bounds of the request in the unit of the cube
+ *
+ * Only the lineno and bounds along the vertical axis, given in annotation,
+ * are converted. Bounds with invalid directions are left for core to
+ * report.
+ */
+func (request SliceRequest) toNative(
+	axis int,
+	linenoSystem int,
+	conversion core.VerticalUnitConversion,
+) (lineno int, bounds []core.Bound, err error) {
+	lineno = *request.Lineno
+	if conversion.IsIdentity() {
+		return lineno, request.Bounds, nil
+	}
+
+	if isVertical(axis) && linenoSystem == core.CoordinateSystemAnnotation {
+		lineno, err = conversion.ToNativeInt("lineno", lineno)
+		if err != nil {
+			return
+		}
+	}
+
+	for _, bound := range request.Bounds {
+		if isVerticalAnnotationBound(bound) {
+			lower, err := conversion.ToNativeInt("lower bound", *bound.Lower)
+			if err != nil {
+				return 0, nil, err
+			}
+			upper, err := conversion.ToNativeInt("upper bound", *bound.Upper)
+			if err != nil {
+				return 0, nil, err
+			}
+			bound.Lower = &lower
+			bound.Upper = &upper
+		}
+		bounds = append(bounds, bound)
+	}
+	return lineno, bounds, nil
 }
 
-func (request FenceRequest) execute(
+func (request SliceRequest) executeMetadata(
 	handle core.DSHandle,
-) (data [][]byte, metadata []byte, err error) {
-	coordinateSystem, err := core.GetCoordinateSystem(
-		strings.ToLower(request.CoordinateSystem),
-	)
+) ([]byte, error) {
+	request, err := request.resolveDirections(handle)
 	if err != nil {
-		return
+		return nil, err
 	}
 
-	interpolation, err := core.GetInterpolationMethod(request.Interpolation)
+	axis, linenoSystem, err := request.axis()
 	if err != nil {
-		return
+		return nil, err
 	}
 
-	metadata, err = handle.GetFenceMetadata(request.Coordinates)
+	conversion, err := handle.VerticalUnitConversion(request.VerticalUnit)
 	if err != nil {
-		return
+		return nil, err
 	}
 
-	res, err := handle.GetFence(
-		coordinateSystem,
-		request.Coordinates,
-		interpolation,
-		request.FillValue,
-	)
+	lineno, bounds, err := request.toNative(axis, linenoSystem, conversion)
 	if err != nil {
-		return
+		return nil, err
 	}
-	data = [][]byte{res}
 
-	return data, metadata, nil
-}
+	var metadata []byte
+	if request.Raw {
+		metadata, err = handle.GetStoredSliceMetadata(
+			lineno,
+			axis,
+			linenoSystem,
+			bounds,
+		)
+	} else {
+		metadata, err = handle.GetSliceMetadata(
+			lineno,
+			axis,
+			linenoSystem,
+			bounds,
+			(*float32)(request.FillValue),
+		)
+	}
+	if err != nil {
+		return nil, err
+	}
 
-func validateVerticalWindow(above float32, below float32, stepSize float32) error {
-	const lowerBound = 0
-	const upperBound = 250
+	return conversion.ConvertSliceMetadata(metadata)
+}
 
-	if above < lowerBound || above >= upperBound {
-		return core.NewInvalidArgument(fmt.Sprintf(
-			"'above' out of range! Must be within [%d, %d], was %f",
-			lowerBound,
-			upperBound,
-			above,
-		))
+func (request SliceRequest) estimateSize(handle core.DSHandle) (int64, error) {
+	metadata, err := request.executeMetadata(handle)
+	if err != nil {
+		return 0, err
 	}
 
-	if below < lowerBound || below >= upperBound {
-		return core.NewInvalidArgument(fmt.Sprintf(
-			"'below' out of range! Must be within [%d, %d], was %f",
-			lowerBound,
-			upperBound,
-			below,
-		))
+	size, err := dataSize(metadata)
+	return int64(size), err
+}
+
+func (request SliceRequest) observe(observer RequestObserver, metadata []byte) {
+	var array core.Array
+	if err := json.Unmarshal(metadata, &array); err != nil {
+		return
 	}
 
-	if stepSize < lowerBound {
-		return core.NewInvalidArgument(fmt.Sprintf(
-			"'stepsize' out of range! Must be bigger than %d, was %f",
-			lowerBound,
-			stepSize,
-		))
+	samples := 1
+	for _, length := range array.Shape {
+		samples *= length
 	}
+	observer.SliceSamples("slice", samples)
+}
 
-	return nil
+func (request SliceRequest) omitsMetadata() bool {
+	return request.OmitMetadata
+}
+
+func (request SliceRequest) partNames(metadata []byte) ([]string, error) {
+	return nil, nil
 }
 
-func (request AttributeAlongSurfaceRequest) execute(
-	handle core.DSHandle,
-) (data [][]byte, metadata []byte, err error) {
-	err = validateVerticalWindow(request.Above, request.Below, request.Stepsize)
-	if err != nil {
-		return
+func (request SliceRequest) execute(
+	handle core.DSHandle,
+) (data [][]byte, metadata []byte, err error) {
+	request, err = request.resolveDirections(handle)
+	if err != nil {
+		return
+	}
+
+	axis, linenoSystem, err := request.axis()
+	if err != nil {
+		return
+	}
+
+	conversion, err := handle.VerticalUnitConversion(request.VerticalUnit)
+	if err != nil {
+		return
+	}
+
+	lineno, bounds, err := request.toNative(axis, linenoSystem, conversion)
+	if err != nil {
+		return
+	}
+
+	var res []byte
+	if request.Raw {
+		res, metadata, err = handle.GetStoredSliceWithMetadata(
+			lineno,
+			axis,
+			linenoSystem,
+			bounds,
+		)
+	} else {
+		res, metadata, err = handle.GetSliceWithMetadata(
+			lineno,
+			axis,
+			linenoSystem,
+			bounds,
+			(*float32)(request.FillValue),
+		)
+	}
+	if err != nil {
+		return
+	}
+
+	metadata, err = conversion.ConvertSliceMetadata(metadata)
+	if err != nil {
+		return
+	}
+	data = [][]byte{res}
+
+	return data, metadata, nil
+}
+
+/** Read rows of the slice, see core.DSHandle.GetSliceRows
+ *
+ * The request is resolved against the cube once, rather than for every
+ * read of the returned function.
+ */
+func (request SliceRequest) rowReader(
+	handle core.DSHandle,
+) (func(first int, stride int) ([]byte, error), error) {
+	request, err := request.resolveDirections(handle)
+	if err != nil {
+		return nil, err
+	}
+
+	axis, linenoSystem, err := request.axis()
+	if err != nil {
+		return nil, err
+	}
+
+	conversion, err := handle.VerticalUnitConversion(request.VerticalUnit)
+	if err != nil {
+		return nil, err
+	}
+
+	lineno, bounds, err := request.toNative(axis, linenoSystem, conversion)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(first int, stride int) ([]byte, error) {
+		if request.Raw {
+			return handle.GetStoredSliceRows(
+				lineno,
+				axis,
+				linenoSystem,
+				bounds,
+				first,
+				stride,
+			)
+		}
+		return handle.GetSliceRows(
+			lineno,
+			axis,
+			linenoSystem,
+			bounds,
+			(*float32)(request.FillValue),
+			first,
+			stride,
+		)
+	}, nil
+}
+
+func (request FenceRequest) executeMetadata(
+	handle core.DSHandle,
+) ([]byte, error) {
+	/* The shape of resampled traces is only known after resampling */
+	if request.ResampleTo != nil {
+		_, metadata, err := request.execute(handle)
+		return metadata, err
+	}
+
+	coordinateSystem, err := core.GetCoordinateSystem(
+		strings.ToLower(request.CoordinateSystem),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	interpolation, err := core.GetFenceInterpolationMethod(request.Interpolation)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = handle.VerticalUnitConversion(request.VerticalUnit)
+	if err != nil {
+		return nil, err
+	}
+
+	metadata, err := handle.GetFenceMetadata(
+		coordinateSystem,
+		request.Coordinates,
+		interpolation,
+		request.FillValue,
+	)
+	if err != nil || len(request.HeaderFields) == 0 {
+		return metadata, err
+	}
+	return core.FenceHeadersMetadata(metadata, request.HeaderFields)
+}
+
+/*
+ * The size of the fence as sampled in the cube, scaled to the length of the
+ * resampled traces
+ */
+func (request FenceRequest) estimateSize(handle core.DSHandle) (int64, error) {
+	unresampled := request
+	unresampled.ResampleTo = nil
+	metadata, err := unresampled.executeMetadata(handle)
+	if err != nil {
+		return 0, err
+	}
+
+	var array core.Array
+	if err := json.Unmarshal(metadata, &array); err != nil {
+		return 0, core.NewInternalError(err.Error())
+	}
+	if len(array.Shape) != 2 {
+		return 0, core.NewInternalError(
+			fmt.Sprintf("expected 2 dimensions in fence shape, got %v", array.Shape),
+		)
+	}
+
+	/* Invalid intervals are rejected by execute */
+	if request.ResampleTo != nil && *request.ResampleTo > 0 {
+		conversion, err := handle.VerticalUnitConversion(request.VerticalUnit)
+		if err != nil {
+			return 0, err
+		}
+		array.Shape[1], err = handle.ResampledTraceLength(
+			*request.ResampleTo,
+			conversion,
+		)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	itemsize, err := itemSize(array.Format)
+	if err != nil {
+		return 0, err
+	}
+	traces := int64(array.Shape[0]) * int64(array.Shape[1]) * int64(itemsize)
+	headers := int64(array.Shape[0]) * int64(len(request.HeaderFields)) * 4
+	return traces + headers, nil
+}
+
+func (request FenceRequest) observe(observer RequestObserver, metadata []byte) {
+	observer.FenceCoordinates("fence", len(request.Coordinates))
+}
+
+func (request FenceRequest) omitsMetadata() bool {
+	return request.OmitMetadata
+}
+
+/* The traces, and the trace headers if any are asked for */
+func (request FenceRequest) partNames(metadata []byte) ([]string, error) {
+	if len(request.HeaderFields) == 0 {
+		return nil, nil
+	}
+	return []string{"traces", "headers"}, nil
+}
+
+func (request FenceRequest) execute(
+	handle core.DSHandle,
+) (data [][]byte, metadata []byte, err error) {
+	coordinateSystem, err := core.GetCoordinateSystem(
+		strings.ToLower(request.CoordinateSystem),
+	)
+	if err != nil {
+		return
+	}
+
+	interpolation, err := core.GetFenceInterpolationMethod(request.Interpolation)
+	if err != nil {
+		return
+	}
+
+	conversion, err := handle.VerticalUnitConversion(request.VerticalUnit)
+	if err != nil {
+		return
+	}
+
+	res, metadata, err := handle.GetFenceWithMetadata(
+		coordinateSystem,
+		request.Coordinates,
+		interpolation,
+		request.FillValue,
+	)
+	if err != nil {
+		return
+	}
+
+	if request.ResampleTo != nil {
+		res, metadata, err = handle.ResampleFence(
+			res,
+			metadata,
+			*request.ResampleTo,
+			interpolation,
+			conversion,
+		)
+		if err != nil {
+			return
+		}
+	}
+	data = [][]byte{res}
+
+	if len(request.HeaderFields) > 0 {
+		var headers []byte
+		headers, err = handle.GetFenceHeaders(
+			coordinateSystem,
+			request.Coordinates,
+			request.HeaderFields,
+			request.FillValue,
+		)
+		if err != nil {
+			return
+		}
+
+		metadata, err = core.FenceHeadersMetadata(metadata, request.HeaderFields)
+		if err != nil {
+			return
+		}
+		data = append(data, headers)
+	}
+
+	return data, metadata, nil
+}
+
+/* A float value and a byte validity flag per point */
+func (request SampleRequest) estimateSize(handle core.DSHandle) (int64, error) {
+	return int64(len(request.Coordinates)) * 5, nil
+}
+
+func (request SampleRequest) observe(observer RequestObserver, metadata []byte) {
+	observer.SamplePoints("sample", len(request.Coordinates))
+}
+
+func (request SampleRequest) omitsMetadata() bool {
+	return request.OmitMetadata
+}
+
+/* The values and their validity flags, in the order of the data parts */
+func (request SampleRequest) partNames(metadata []byte) ([]string, error) {
+	return []string{"values", "valid"}, nil
+}
+
+func (request SampleRequest) execute(
+	handle core.DSHandle,
+) (data [][]byte, metadata []byte, err error) {
+	coordinateSystem, err := core.GetCoordinateSystem(
+		strings.ToLower(request.CoordinateSystem),
+	)
+	if err != nil {
+		return
+	}
+
+	interpolation, err := core.GetInterpolationMethod(request.Interpolation)
+	if err != nil {
+		return
+	}
+
+	conversion, err := handle.VerticalUnitConversion(request.VerticalUnit)
+	if err != nil {
+		return
+	}
+
+	return handle.GetSamplesWithMetadata(
+		coordinateSystem,
+		request.Coordinates,
+		interpolation,
+		request.FillValue,
+		conversion,
+	)
+}
+
+/** Check fence coordinates before they are handed to core
+ *
+ * Rejects requests with more than limit coordinates, unless limit is zero,
+ * and coordinates that are not finite, which core has no sensible way of
+ * handling.
+ */
+func validateCoordinates(coordinates [][]float32, limit int) error {
+	if limit > 0 && len(coordinates) > limit {
+		return core.NewCodedInvalidArgument(
+			"too_many_coordinates",
+			core.ErrorDetails{
+				"count": strconv.Itoa(len(coordinates)),
+				"limit": strconv.Itoa(limit),
+			},
+			fmt.Sprintf(
+				"Too many coordinates in request: %d. The limit is %d",
+				len(coordinates),
+				limit,
+			),
+		)
+	}
+
+	for i, coordinate := range coordinates {
+		for _, value := range coordinate {
+			v := float64(value)
+			if math.IsNaN(v) || math.IsInf(v, 0) {
+				return core.NewCodedInvalidArgument(
+					"invalid_coordinate",
+					core.ErrorDetails{"position": strconv.Itoa(i)},
+					fmt.Sprintf(
+						"invalid coordinate %v at position %d, expected "+
+							"finite values",
+						coordinate,
+						i,
+					),
+				)
+			}
+		}
+	}
+	return nil
+}
+
+/** Move coordinatesFlat into coordinates, and validate them
+ *
+ * Flat and nested coordinates are hashed alike from then on, such that
+ * they share cache entries.
+ */
+func (f *FenceRequest) normalizeCoordinates(limit int) error {
+	if f.CoordinatesFlat != nil {
+		if f.Coordinates != nil {
+			return core.NewCodedInvalidArgument(
+				"conflicting_coordinates",
+				nil,
+				"coordinates and coordinatesFlat are mutually exclusive",
+			)
+		}
+
+		coordinates, err := f.CoordinatesFlat.pairs()
+		if err != nil {
+			return err
+		}
+		f.Coordinates = coordinates
+		f.CoordinatesFlat = nil
+	}
+	return validateCoordinates(f.Coordinates, limit)
+}
+
+/** Grid pointSurface into surface, and validate the surface
+ *
+ * Gridded and regular surfaces are hashed alike from then on, such that
+ * they share cache entries. The limit applies to both the points and the
+ * nodes of the grid, as the latter is what the request is computed on.
+ */
+func (h *AttributeAlongSurfaceRequest) normalizeSurface(limit int) error {
+	if h.PointSurface != nil {
+		if h.Surface != nil {
+			return core.NewInvalidArgument(
+				"surface and pointSurface are mutually exclusive",
+			)
+		}
+
+		points := h.PointSurface
+		if limit > 0 && len(points.Points) > limit {
+			return core.NewInvalidArgument(fmt.Sprintf(
+				"Too many points in pointSurface: %d. The limit is %d",
+				len(points.Points),
+				limit,
+			))
+		}
+		if err := points.Validate(); err != nil {
+			return err
+		}
+		if limit > 0 && points.Nodes() > limit {
+			return core.NewInvalidArgument(fmt.Sprintf(
+				"Too many nodes in the grid of pointSurface: %d x %d. The "+
+					"limit is %d",
+				points.Nrows,
+				points.Ncols,
+				limit,
+			))
+		}
+
+		surface, err := points.Grid()
+		if err != nil {
+			return err
+		}
+		h.Surface = &surface
+		h.PointSurface = nil
+	}
+	return h.Surface.Validate()
+}
+
+/*
+ * The valid above and below of the attribute endpoints, in the vertical unit
+ * of the cube. The upper bound is exclusive.
+ */
+const (
+	verticalWindowLowerBound = 0
+	verticalWindowUpperBound = 250
+)
+
+func validateVerticalWindow(above float32, below float32, stepSize float32) error {
+	const lowerBound = verticalWindowLowerBound
+	const upperBound = verticalWindowUpperBound
+
+	if above < lowerBound || above >= upperBound {
+		return core.NewCodedInvalidArgument(
+			"invalid_vertical_window",
+			core.ErrorDetails{"field": "above"},
+			fmt.Sprintf(
+				"'above' out of range! Must be within [%d, %d], was %f",
+				lowerBound,
+				upperBound,
+				above,
+			),
+		)
+	}
+
+	if below < lowerBound || below >= upperBound {
+		return core.NewCodedInvalidArgument(
+			"invalid_vertical_window",
+			core.ErrorDetails{"field": "below"},
+			fmt.Sprintf(
+				"'below' out of range! Must be within [%d, %d], was %f",
+				lowerBound,
+				upperBound,
+				below,
+			),
+		)
+	}
+
+	if stepSize < lowerBound {
+		return core.NewCodedInvalidArgument(
+			"invalid_vertical_window",
+			core.ErrorDetails{"field": "stepsize"},
+			fmt.Sprintf(
+				"'stepsize' out of range! Must be bigger than %d, was %f",
+				lowerBound,
+				stepSize,
+			),
+		)
+	}
+
+	return nil
+}
+
+/* Validate a surface, naming it in the error for requests with several */
+func validateSurface(name string, surface *core.RegularSurface) error {
+	if err := surface.Validate(); err != nil {
+		return rephrasedInvalidArgument(
+			err,
+			fmt.Sprintf("Invalid %s: %s", name, err.Error()),
+		)
+	}
+	return nil
+}
+
+/* Number of points of the surface, which need not be rectangular */
+func surfacePoints(surface core.RegularSurface) int {
+	points := 0
+	for _, row := range surface.Values {
+		points += len(row)
+	}
+	return points
+}
+
+func (request AttributeRequest) omitsMetadata() bool {
+	return request.OmitMetadata
+}
+
+/* The attributes, as described by the parts of the metadata */
+func (request AttributeRequest) partNames(metadata []byte) ([]string, error) {
+	var attributeMetadata core.AttributeMetadata
+	err := json.Unmarshal(metadata, &attributeMetadata)
+	if err != nil {
+		return nil, core.NewInternalError(err.Error())
+	}
+
+	names := make([]string, len(attributeMetadata.Parts))
+	for i, part := range attributeMetadata.Parts {
+		names[i] = part.Name
+	}
+	return names, nil
+}
+
+/* A float per point of the surface per attribute */
+func (request AttributeAlongSurfaceRequest) estimateSize(
+	handle core.DSHandle,
+) (int64, error) {
+	points := int64(surfacePoints(*request.Surface))
+	return points * int64(len(request.Attributes)) * 4, nil
+}
+
+func (request AttributeAlongSurfaceRequest) observe(
+	observer RequestObserver,
+	metadata []byte,
+) {
+	const endpoint = "attributes/surface/along"
+	observer.SurfacePoints(endpoint, surfacePoints(*request.Surface))
+	observer.AttributeWindow(endpoint, float64(request.Above+request.Below))
+}
+
+func (request AttributeAlongSurfaceRequest) execute(
+	handle core.DSHandle,
+) (data [][]byte, metadata []byte, err error) {
+	attributes, statuses, err := request.resolveAttributes()
+	if err != nil {
+		return
+	}
+
+	conversion, err := handle.VerticalUnitConversion(request.VerticalUnit)
+	if err != nil {
+		return
+	}
+
+	above := conversion.ToNative(request.Above)
+	below := conversion.ToNative(request.Below)
+	stepsize := conversion.ToNative(request.Stepsize)
+
+	/* The window is limited in the unit of the cube */
+	err = validateVerticalWindow(above, below, stepsize)
+	if err != nil {
+		return
+	}
+
+	interpolation, err := core.GetInterpolationMethod(request.Interpolation)
+	if err != nil {
+		return
+	}
+
+	verticalInterpolation, err := core.GetVerticalInterpolationMethod(
+		request.VerticalInterpolation,
+	)
+	if err != nil {
+		return
+	}
+
+	polygon, err := request.polygon()
+	if err != nil {
+		return
+	}
+
+	data, metadata, err = handle.GetAttributesAlongSurfaceWithMetadata(
+		conversion.SurfaceToNative(*request.Surface),
+		above,
+		below,
+		stepsize,
+		attributes,
+		interpolation,
+		verticalInterpolation,
+		polygon,
+		request.band(),
+		request.MinValidFraction,
+	)
+	if err != nil {
+		return
+	}
+
+	metadata, err = withAttributeStatuses(metadata, statuses)
+	return data, metadata, err
+}
+
+/*
+ * The window is given by the distance between the surfaces, which differs
+ * from point to point, so only the surface is observed.
+ */
+/* A float per point of the primary surface per attribute */
+func (request AttributeBetweenSurfacesRequest) estimateSize(
+	handle core.DSHandle,
+) (int64, error) {
+	points := int64(surfacePoints(request.PrimarySurface))
+	return points * int64(len(request.Attributes)) * 4, nil
+}
+
+func (request AttributeBetweenSurfacesRequest) observe(
+	observer RequestObserver,
+	metadata []byte,
+) {
+	observer.SurfacePoints(
+		"attributes/surface/between",
+		surfacePoints(request.PrimarySurface),
+	)
+}
+
+func (request AttributeBetweenSurfacesRequest) execute(
+	handle core.DSHandle,
+) (data [][]byte, metadata []byte, err error) {
+	attributes, statuses, err := request.resolveAttributes()
+	if err != nil {
+		return
+	}
+
+	conversion, err := handle.VerticalUnitConversion(request.VerticalUnit)
+	if err != nil {
+		return
+	}
+
+	interpolation, err := core.GetInterpolationMethod(request.Interpolation)
+	if err != nil {
+		return
+	}
+
+	verticalInterpolation, err := core.GetVerticalInterpolationMethod(
+		request.VerticalInterpolation,
+	)
+	if err != nil {
+		return
+	}
+
+	data, metadata, err = handle.GetAttributesBetweenSurfacesWithMetadata(
+		conversion.SurfaceToNative(request.PrimarySurface),
+		conversion.SurfaceToNative(request.SecondarySurface),
+		conversion.ToNative(request.Stepsize),
+		attributes,
+		interpolation,
+		verticalInterpolation,
+		request.MinValidFraction,
+	)
+	if err != nil {
+		return
+	}
+
+	metadata, err = withAttributeStatuses(metadata, statuses)
+	return data, metadata, err
+}
+
+/* Add the status of every attribute of a partial request to its metadata */
+func withAttributeStatuses(
+	metadata []byte,
+	statuses []core.AttributeStatus,
+) ([]byte, error) {
+	if statuses == nil {
+		return metadata, nil
+	}
+
+	var attributeMetadata core.AttributeMetadata
+	if err := json.Unmarshal(metadata, &attributeMetadata); err != nil {
+		return nil, core.NewInternalError(err.Error())
+	}
+	attributeMetadata.Attributes = statuses
+
+	out, err := json.Marshal(attributeMetadata)
+	if err != nil {
+		return nil, core.NewInternalError(err.Error())
+	}
+	return out, nil
+}
+
+/** Extract the bearer token from an Authorization header, if any */
+func bearerToken(authorization string) string {
+	const scheme = "bearer "
+
+	header := authorization
+	if len(header) < len(scheme) || !strings.EqualFold(header[:len(scheme)], scheme) {
+		return ""
+	}
+	return strings.TrimSpace(header[len(scheme):])
+}
+
+/** Header that carries the sas-token, as an alternative to the request */
+const sasHeader = "X-SAS-Token"
+
+/** Extract the sas-token from the request headers, if any
+ *
+ * The token is either given in the X-SAS-Token header, or in the
+ * Authorization header as 'SharedAccessSignature <sas>'. Giving both is an
+ * error, as we would have to guess which one the client meant.
+ */
+func headerSas(sasToken string, authorization string) (string, error) {
+	const scheme = "sharedaccesssignature "
+
+	sas := strings.TrimSpace(sasToken)
+
+	header := authorization
+	if len(header) < len(scheme) || !strings.EqualFold(header[:len(scheme)], scheme) {
+		return sas, nil
+	}
+	if sas != "" {
+		return "", core.NewCodedInvalidArgument(
+			"conflicting_credentials",
+			nil,
+			"A Sas token is found in both the X-SAS-Token and the "+
+				"Authorization header, only one of them is allowed",
+		)
+	}
+	return strings.TrimSpace(header[len(scheme):]), nil
+}
+
+/** Hand the credentials found in the request headers over to the request
+ *
+ * The headers are given by value, such that both http headers and grpc
+ * metadata can be handed over.
+ */
+func setHeaderCredentials(
+	v Normalizable,
+	sasToken string,
+	authorization string,
+) error {
+	sas, err := headerSas(sasToken, authorization)
+	if err != nil {
+		return err
+	}
+	v.setHeaderSas(sas)
+	v.setBearerToken(bearerToken(authorization))
+	return nil
+}
+
+/** InvalidArgument for a request that fails the binding tags
+ *
+ * The first failing field, and the tag it fails on, are handed back as
+ * details. Missing required fields are reported by their json path, as the
+ * message of the validator is phrased in terms of go types, e.g. "Key:
+ * 'SliceRequest.Lineno' Error:Field validation for 'Lineno' failed on the
+ * 'required' tag". request is the request that was validated.
+ */
+func validationError(err error, request interface{}) error {
+	var failed validator.ValidationErrors
+	if !errors.As(err, &failed) || len(failed) == 0 {
+		return core.NewInvalidArgument(err.Error())
+	}
+
+	msg := err.Error()
+	if failed[0].Tag() == "required" {
+		field := validationPath(reflect.TypeOf(request), failed[0].StructNamespace())
+		msg = fmt.Sprintf("field '%s' is required", field)
+		if isNil(failed[0].Value()) {
+			msg += ", got null"
+		}
+	}
+
+	return core.NewCodedInvalidArgument(
+		"validation_failed",
+		core.ErrorDetails{
+			"field": failed[0].Field(),
+			"tag":   failed[0].Tag(),
+		},
+		msg,
+	)
+}
+
+/* Whether value is null in json, i.e. nil or a nil pointer, slice or map */
+func isNil(value interface{}) bool {
+	if value == nil {
+		return true
+	}
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Slice, reflect.Map, reflect.Interface:
+		return v.IsNil()
+	default:
+		return false
+	}
+}
+
+/** The json path of a field in a validator namespace
+ *
+ * The namespace is of go names, e.g. SliceRequest.Bounds[0].Direction, which
+ * is written as bounds[0].direction instead. Embedded structs are left out,
+ * as encoding/json promotes their fields. Fields that can not be found in t
+ * keep their go names.
+ */
+func validationPath(t reflect.Type, namespace string) string {
+	elements := strings.Split(namespace, ".")
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	/* Anonymous structs have no name to start the namespace with */
+	if t != nil && t.Name() != "" && elements[0] == t.Name() {
+		elements = elements[1:]
+	}
+
+	path := []string{}
+	for _, element := range elements {
+		name, index := element, ""
+		if i := strings.IndexByte(element, '['); i >= 0 {
+			name, index = element[:i], element[i:]
+		}
+
+		for t != nil && t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		if t == nil || t.Kind() != reflect.Struct {
+			t = nil
+			path = append(path, element)
+			continue
+		}
+		field, ok := t.FieldByName(name)
+		if !ok {
+			t = nil
+			path = append(path, element)
+			continue
+		}
+
+		t = field.Type
+		if index != "" {
+			for t.Kind() == reflect.Ptr {
+				t = t.Elem()
+			}
+			if t.Kind() == reflect.Slice || t.Kind() == reflect.Map {
+				t = t.Elem()
+			}
+		}
+
+		jsonName := strings.Split(field.Tag.Get("json"), ",")[0]
+		if field.Anonymous && jsonName == "" {
+			continue
+		}
+		if jsonName == "" {
+			jsonName = field.Name
+		}
+		path = append(path, jsonName+index)
+	}
+	return strings.Join(path, ".")
+}
+
+/** Validate a decoded request and normalize its connection
+ *
+ * The last step of parsing a request, shared by the http and grpc servers.
+ */
+func (e *Endpoint) validateRequest(
+	v Normalizable,
+	sasToken string,
+	authorization string,
+) error {
+	if err := binding.Validator.ValidateStruct(v); err != nil {
+		return validationError(err, v)
+	}
+
+	err := setHeaderCredentials(v, sasToken, authorization)
+	if err != nil {
+		return err
+	}
+	err = v.resolveAlias(e.Resolver)
+	if err != nil {
+		return err
+	}
+	if request, ok := v.(defaultsRequest); ok {
+		request.resolveDefaults(e.Defaults)
+	}
+	return v.NormalizeConnection()
+}
+
+/*
+ * Header that lets clients opt out of the unknown field check. Only meant to
+ * give clients time to clean up their requests, and will be removed.
+ */
+const allowUnknownFieldsHeader = "X-Allow-Unknown-Fields"
+
+type jsonField struct {
+	name string
+	typ  reflect.Type
+}
+
+/** The json fields of a request type
+ *
+ * Fields of embedded structs are included, as encoding/json promotes them.
+ */
+func jsonFields(t reflect.Type) []jsonField {
+	var fields []jsonField
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			fields = append(fields, jsonFields(field.Type)...)
+			continue
+		}
+		if !field.IsExported() {
+			continue
+		}
+
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		switch name {
+		case "-":
+			continue
+		case "":
+			name = field.Name
+		}
+		fields = append(fields, jsonField{name: name, typ: field.Type})
+	}
+	return fields
+}
+
+/** The json field names accepted by a request type */
+func acceptedFields(t reflect.Type) []string {
+	var names []string
+	for _, field := range jsonFields(t) {
+		names = append(names, field.name)
+	}
+	return names
+}
+
+/*
+ * Field names are matched case-insensitively, just like encoding/json does
+ * when decoding.
+ */
+func unknownFields(data []byte, accepted []string) []string {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil
+	}
+
+	var unknown []string
+	for field := range fields {
+		known := false
+		for _, candidate := range accepted {
+			if strings.EqualFold(field, candidate) {
+				known = true
+				break
+			}
+		}
+		if !known {
+			unknown = append(unknown, field)
+		}
+	}
+	sort.Strings(unknown)
+	return unknown
+}
+
+/* How the json type expected for a go type is described to users */
+func jsonTypeName(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Pointer:
+		return jsonTypeName(t.Elem())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return "an integer"
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "a non-negative integer"
+	case reflect.Float32, reflect.Float64:
+		return "a number"
+	case reflect.String:
+		return "a string"
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.Slice, reflect.Array:
+		return "an array"
+	case reflect.Struct, reflect.Map:
+		return "an object"
+	default:
+		return "a " + t.String()
+	}
+}
+
+/* Literals longer than this are cut short in error messages */
+const maxLiteralLength = 40
+
+/** Describe the offending value of a type mismatch
+ *
+ * encoding/json only reports the kind of the value, and the offset right
+ * after it. Strings, numbers and booleans are quoted as they appear in the
+ * request, arrays and objects by their kind only.
+ */
+func describeJsonValue(data []byte, err *json.UnmarshalTypeError) string {
+	end := int(err.Offset)
+	if end > len(data) {
+		end = len(data)
+	}
+
+	start := end
+	switch err.Value {
+	case "string":
+		/* Find the opening quote, skipping escaped ones */
+		for start = end - 2; start > 0; start-- {
+			if data[start] != '"' {
+				continue
+			}
+			escapes := 0
+			for i := start - 1; i >= 0 && data[i] == '\\'; i-- {
+				escapes++
+			}
+			if escapes%2 == 0 {
+				break
+			}
+		}
+	case "number":
+		for start > 0 && strings.IndexByte("0123456789+-.eE", data[start-1]) >= 0 {
+			start--
+		}
+	case "bool":
+		if bytes.HasSuffix(data[:end], []byte("true")) {
+			return "boolean true"
+		}
+		return "boolean false"
+	default:
+		return err.Value
+	}
+
+	if start < 0 || start >= end {
+		return err.Value
+	}
+	literal := []rune(string(data[start:end]))
+	if len(literal) > maxLiteralLength {
+		return fmt.Sprintf("%s %s...", err.Value, string(literal[:maxLiteralLength]))
+	}
+	return fmt.Sprintf("%s %s", err.Value, string(literal))
+}
+
+/*
+ * encoding/json reports array indices as path elements, e.g. bounds.0.lower,
+ * which is written as bounds[0].lower instead.
+ */
+func jsonPath(field string) string {
+	var path strings.Builder
+	for i, element := range strings.Split(field, ".") {
+		if _, err := strconv.Atoi(element); err == nil {
+			fmt.Fprintf(&path, "[%s]", element)
+			continue
+		}
+		if i > 0 {
+			path.WriteString(".")
+		}
+		path.WriteString(element)
+	}
+	return path.String()
+}
+
+/** User friendly error for a json value of the wrong type
+ *
+ * The error from encoding/json is phrased in terms of go types, e.g.
+ * "cannot unmarshal string into Go struct field SliceRequest.lineno of type
+ * int", which means little to users.
+ */
+func typeMismatchError(data []byte, err *json.UnmarshalTypeError) error {
+	expected := jsonTypeName(err.Type)
+	got := describeJsonValue(data, err)
+	if err.Field == "" {
+		return core.NewCodedInvalidArgument(
+			"type_mismatch",
+			nil,
+			fmt.Sprintf("request must be %s, got %s", expected, got),
+		)
+	}
+	field := jsonPath(err.Field)
+	return core.NewCodedInvalidArgument(
+		"type_mismatch",
+		core.ErrorDetails{"field": field},
+		fmt.Sprintf("field '%s' must be %s, got %s", field, expected, got),
+	)
+}
+
+/** Decode a json request, rejecting unknown fields
+ *
+ * Misspelled fields would otherwise be silently ignored, leaving the client
+ * with default behaviour they never asked for. All unknown top-level fields
+ * are listed in the error, together with the accepted ones. Unknown fields in
+ * nested objects are reported one at a time, as by encoding/json.
+ *
+ * Errors about unknown fields and mismatching types are returned as
+ * InvalidArgument, anything else is returned as-is from encoding/json.
+ */
+func decodeRequest(ctx *gin.Context, data []byte, v Normalizable) error {
+	/*
+	 * json.Decoder reports offsets relative to the start of the value, so
+	 * leading whitespace is dropped to have them line up with data.
+	 */
+	data = bytes.TrimLeft(data, " \t\r\n")
+
+	var err error
+	if strings.EqualFold(ctx.GetHeader(allowUnknownFieldsHeader), "true") {
+		err = json.Unmarshal(data, v)
+	} else {
+		accepted := acceptedFields(reflect.TypeOf(v).Elem())
+		if unknown := unknownFields(data, accepted); len(unknown) > 0 {
+			return core.NewCodedInvalidArgument(
+				"unknown_field",
+				nil,
+				fmt.Sprintf(
+					"Unknown field(s) in request: %s. Accepted fields are: %s",
+					strings.Join(unknown, ", "),
+					strings.Join(accepted, ", "),
+				),
+			)
+		}
+
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		err = decoder.Decode(v)
+		if err != nil && strings.HasPrefix(err.Error(), "json: unknown field") {
+			return core.NewCodedInvalidArgument(
+				"unknown_field",
+				nil,
+				fmt.Sprintf(
+					"Unknown field in request: %s",
+					strings.TrimPrefix(err.Error(), "json: unknown field "),
+				),
+			)
+		}
+	}
+
+	var mismatch *json.UnmarshalTypeError
+	if errors.As(err, &mismatch) {
+		return typeMismatchError(data, mismatch)
+	}
+	return err
+}
+
+/*
+ * A number or boolean as given in a query parameter. Anything that is not a
+ * valid json literal is passed on as a string, such that it's reported as a
+ * type mismatch by decodeRequest.
+ */
+func jsonLiteral(value string) json.RawMessage {
+	var literal interface{}
+	if err := json.Unmarshal([]byte(value), &literal); err == nil {
+		switch literal.(type) {
+		case float64, bool:
+			return json.RawMessage(value)
+		}
+	}
+	quoted, _ := json.Marshal(value)
+	return quoted
+}
+
+/*
+ * An array or object as given in a query parameter. Anything that is not
+ * valid json is passed on as a string, and reported as a type mismatch.
+ */
+func jsonValue(value string) json.RawMessage {
+	if json.Valid([]byte(value)) {
+		return json.RawMessage(value)
+	}
+	quoted, _ := json.Marshal(value)
+	return quoted
+}
+
+/*
+ * Coordinates in compact form, i.e. pairs separated by ';' and the values of
+ * a pair by ','. E.g. "3,11;2,10" is [[3, 11], [2, 10]].
+ */
+func compactCoordinates(value string) []json.RawMessage {
+	var coordinates []json.RawMessage
+	for _, pair := range strings.Split(value, ";") {
+		var values []string
+		for _, v := range strings.Split(pair, ",") {
+			values = append(values, string(jsonLiteral(strings.TrimSpace(v))))
+		}
+		coordinates = append(
+			coordinates,
+			json.RawMessage("["+strings.Join(values, ",")+"]"),
+		)
+	}
+	return coordinates
+}
+
+/** Convert the values of a query parameter to json, according to field type */
+func queryParameterAsJson(name string, t reflect.Type, values []string) (json.RawMessage, error) {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	if t.Kind() == reflect.Slice {
+		var elements []json.RawMessage
+		for _, value := range values {
+			switch elem := t.Elem(); {
+			case elem.Kind() == reflect.Slice:
+				elements = append(elements, compactCoordinates(value)...)
+			case elem.Kind() == reflect.Struct:
+				/* Either the whole array, or one element per parameter */
+				if strings.HasPrefix(strings.TrimSpace(value), "[") {
+					return jsonValue(value), nil
+				}
+				elements = append(elements, jsonValue(value))
+			default:
+				for _, v := range strings.Split(value, ",") {
+					if elem.Kind() == reflect.String {
+						quoted, _ := json.Marshal(v)
+						elements = append(elements, quoted)
+					} else {
+						elements = append(elements, jsonLiteral(v))
+					}
+				}
+			}
+		}
+		return json.Marshal(elements)
 	}
 
-	interpolation, err := core.GetInterpolationMethod(request.Interpolation)
-	if err != nil {
-		return
+	if len(values) > 1 {
+		return nil, core.NewCodedInvalidArgument(
+			"duplicate_parameter",
+			core.ErrorDetails{"parameter": name},
+			fmt.Sprintf("Query parameter '%s' is given more than once", name),
+		)
 	}
+	value := values[0]
 
-	metadata, err = handle.GetAttributeMetadata(request.Surface.Values)
-	if err != nil {
-		return
+	switch t.Kind() {
+	case reflect.String:
+		return json.Marshal(value)
+	case reflect.Struct, reflect.Map:
+		return jsonValue(value), nil
+	default:
+		return jsonLiteral(value), nil
 	}
+}
 
-	data, err = handle.GetAttributesAlongSurface(
-		request.Surface,
-		request.Above,
-		request.Below,
-		request.Stepsize,
-		request.Attributes,
-		interpolation,
-	)
-	if err != nil {
-		return
+/** Build a json request from individual query parameters
+ *
+ * Lets clients write GET requests by hand, e.g.
+ *
+ *     /slice?vds=...&direction=inline&lineno=100&sas=...
+ *
+ * Each parameter is converted to json according to the type of the request
+ * field it names, and the result is decoded just like the json given in the
+ * 'query' parameter. Arrays can be given as repeated parameters or as comma
+ * separated values, and coordinates in compact form, e.g.
+ * coordinates=3,11;2,10. Objects, like bounds, are given as json.
+ *
+ * Parameters that don't name a field are passed on as strings, such that
+ * they are reported as unknown fields.
+ */
+func queryParametersAsJson(params url.Values, t reflect.Type) ([]byte, error) {
+	fields := jsonFields(t)
+
+	request := map[string]json.RawMessage{}
+	for name, values := range params {
+		var field *jsonField
+		for i := range fields {
+			if strings.EqualFold(fields[i].name, name) {
+				field = &fields[i]
+				break
+			}
+		}
+		if field == nil {
+			quoted, _ := json.Marshal(values[0])
+			request[name] = quoted
+			continue
+		}
+
+		value, err := queryParameterAsJson(name, field.typ, values)
+		if err != nil {
+			return nil, err
+		}
+		request[name] = value
 	}
+	return json.Marshal(request)
+}
 
-	return data, metadata, nil
+/*
+ * url.ParseQuery drops parameters containing ';', which is used by compact
+ * coordinates, hence the query is split on '&' only.
+ */
+func parseQueryParameters(rawQuery string) (url.Values, error) {
+	params := url.Values{}
+	for _, param := range strings.Split(rawQuery, "&") {
+		if param == "" {
+			continue
+		}
+		key, value, _ := strings.Cut(param, "=")
+		key, err := url.QueryUnescape(key)
+		if err != nil {
+			return nil, core.NewInvalidArgument(err.Error())
+		}
+		value, err = url.QueryUnescape(value)
+		if err != nil {
+			return nil, core.NewInvalidArgument(err.Error())
+		}
+		params.Add(key, value)
+	}
+	return params, nil
 }
 
-func (request AttributeBetweenSurfacesRequest) execute(
-	handle core.DSHandle,
-) (data [][]byte, metadata []byte, err error) {
-	interpolation, err := core.GetInterpolationMethod(request.Interpolation)
-	if err != nil {
-		return
+/** Parse a GET request
+ *
+ * The request is either given as json in the 'query' parameter, or as
+ * individual query parameters. The former takes precedence.
+ */
+func (e *Endpoint) parseGetRequest(ctx *gin.Context, v Normalizable) error {
+	query, status := ctx.GetQuery("query")
+	if !status {
+		params, err := parseQueryParameters(ctx.Request.URL.RawQuery)
+		if err != nil {
+			return err
+		}
+		if len(params) == 0 {
+			return core.NewCodedInvalidArgument(
+				"missing_query",
+				nil,
+				"GET request to specified endpoint requires a 'query' "+
+					"parameter, or the request fields as individual query "+
+					"parameters",
+			)
+		}
+
+		request, err := queryParametersAsJson(params, reflect.TypeOf(v).Elem())
+		if err != nil {
+			return err
+		}
+		query = string(request)
 	}
 
-	metadata, err = handle.GetAttributeMetadata(request.PrimarySurface.Values)
-	if err != nil {
-		return
+	if err := decodeRequest(ctx, []byte(query), v); err != nil {
+		if _, ok := err.(*core.InvalidArgument); ok {
+			return err
+		}
+		msg := "Please ensure that the supplied query is valid " +
+			"and conforms to the expected swagger Request specification: %v"
+		return core.NewCodedInvalidArgument(
+			"malformed_request",
+			nil,
+			fmt.Sprintf(msg, err.Error()),
+		)
 	}
 
-	data, err = handle.GetAttributesBetweenSurfaces(
-		request.PrimarySurface,
-		request.SecondarySurface,
-		request.Stepsize,
-		request.Attributes,
-		interpolation,
+	return e.validateRequest(
+		v,
+		ctx.GetHeader(sasHeader),
+		ctx.GetHeader("Authorization"),
 	)
-	if err != nil {
-		return
+}
+
+/** Limit the size of request bodies
+ *
+ * Bodies larger than limit bytes are cut off while binding, which is then
+ * reported as 413 by parsePostRequest. A limit of zero means no limit.
+ */
+func limitRequestSize(ctx *gin.Context, limit int64) {
+	if limit > 0 && ctx.Request.Body != nil {
+		ctx.Request.Body = http.MaxBytesReader(
+			ctx.Writer,
+			ctx.Request.Body,
+			limit,
+		)
 	}
+	ctx.Next()
+}
 
-	return data, metadata, nil
+/*
+ * The limits are read per request rather than when the routes are
+ * registered, such that reloaded limits apply
+ */
+func (e *Endpoint) LimitRequestSize(ctx *gin.Context) {
+	limitRequestSize(ctx, e.limits().RequestSize)
 }
 
-func parseGetRequest(ctx *gin.Context, v Normalizable) error {
-	query, status := ctx.GetQuery("query")
-	if (!status){
-		return core.NewInvalidArgument(
-			"GET request to specified endpoint requires a 'query' parameter",
+func (e *Endpoint) LimitAttributeRequestSize(ctx *gin.Context) {
+	limitRequestSize(ctx, e.limits().AttributeRequestSize)
+}
+
+/** Limit the size of the query of GET requests
+ *
+ * The attribute endpoints take whole surfaces, which belong in the body of a
+ * POST. GET is for small requests only, e.g. such that gateways can cache
+ * them, and the url encoded query is held to limit bytes. A limit of zero
+ * means no limit.
+ */
+func validateQuerySize(ctx *gin.Context, limit int64) error {
+	size := int64(len(ctx.Request.URL.RawQuery))
+	if limit > 0 && size > limit {
+		return core.NewCodedInvalidArgument(
+			"query_too_large",
+			core.ErrorDetails{"limit": strconv.FormatInt(limit, 10)},
+			fmt.Sprintf(
+				"Query is too large for GET, the limit is %d bytes, was %d "+
+					"bytes. Use POST for larger requests",
+				limit,
+				size,
+			),
 		)
 	}
-	if err := json.Unmarshal([]byte(query), v); err != nil {
-		msg := "Please ensure that the supplied query is valid " +
-			"and conforms to the expected swagger Request specification: %v"
-		return core.NewInvalidArgument(
-			fmt.Sprintf(msg, err.Error()))
-	}
+	return nil
+}
 
-	if err := binding.Validator.ValidateStruct(v); err != nil {
-		return core.NewInvalidArgument(err.Error())
+func readRequestBody(ctx *gin.Context) ([]byte, error) {
+	body, err := io.ReadAll(ctx.Request.Body)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return nil, core.NewCodedPayloadTooLargeError(
+				"request_too_large",
+				core.ErrorDetails{
+					"limit": strconv.FormatInt(tooLarge.Limit, 10),
+				},
+				fmt.Sprintf(
+					"Request body is too large, the limit is %d bytes",
+					tooLarge.Limit,
+				),
+			)
+		}
+		return nil, core.NewInvalidArgument(err.Error())
 	}
+	return body, nil
+}
 
-	return v.NormalizeConnection()
+func (e *Endpoint) parsePostRequest(ctx *gin.Context, v Normalizable) error {
+	body, err := readRequestBody(ctx)
+	if err != nil {
+		return err
+	}
+	return e.parseRequestBody(ctx, body, v)
 }
 
-func parsePostRequest(ctx *gin.Context, v Normalizable) error {
-	if err := ctx.ShouldBind(v); err != nil {
-		return core.NewInvalidArgument(err.Error())
+func (e *Endpoint) parseRequestBody(ctx *gin.Context, body []byte, v Normalizable) error {
+	if err := decodeRequest(ctx, body, v); err != nil {
+		if _, ok := err.(*core.InvalidArgument); ok {
+			return err
+		}
+		return core.NewCodedInvalidArgument("malformed_request", nil, err.Error())
 	}
-	return v.NormalizeConnection()
+
+	return e.validateRequest(
+		v,
+		ctx.GetHeader(sasHeader),
+		ctx.GetHeader("Authorization"),
+	)
 }
 
 func (e *Endpoint) Health(ctx *gin.Context) {
@@ -308,6 +2458,44 @@ func (e *Endpoint) Health(ctx *gin.Context) {
 	ctx.HTML(http.StatusOK, "index.html", gin.H{})
 }
 
+// VersionGet godoc
+// @Summary  Return the server version and the features it supports
+// @Tags     version
+// @Produce  json
+// @Success  200 {object} VersionResponse
+// @Router   /version  [get]
+func (e *Endpoint) VersionGet(ctx *gin.Context) {
+	var generation *ConfigGeneration
+	if e.Config != nil {
+		current := e.Config.Generation()
+		generation = &current
+	}
+
+	ctx.JSON(http.StatusOK, VersionResponse{
+		Version: e.Version,
+		OpenVDS: core.OpenVDSVersion(),
+		Capabilities: Capabilities{
+			Directions:                   core.Directions(),
+			LinenoModes:                  core.LinenoModes(),
+			CoordinateSystems:            core.CoordinateSystems(),
+			InterpolationMethods:         core.InterpolationMethods(),
+			FenceInterpolationMethods:    core.FenceInterpolationMethods(),
+			VerticalInterpolationMethods: core.VerticalInterpolationMethods(),
+			VerticalUnits:                core.VerticalUnits(),
+			GriddingMethods:              core.GriddingMethods(),
+			Attributes:                   core.AttributeTypes(),
+			Encodings:                    []string{"gzip"},
+			Endpoints:                    e.Endpoints.Names(),
+			Limits:                       e.limits(),
+			Defaults: Defaults{
+				Interpolation: e.Defaults.DefaultInterpolation(),
+				FillValue:     (*core.FillValue)(e.Defaults.FillValue),
+			},
+		},
+		Config: generation,
+	})
+}
+
 // MetadataGet godoc
 // @Summary  Return volumetric metadata about the VDS
 // @description.markdown metadata
@@ -316,11 +2504,16 @@ func (e *Endpoint) Health(ctx *gin.Context) {
 // @Produce  json
 // @Success  200 {object} core.Metadata
 // @Failure  400 {object} ErrorResponse "Request is invalid"
+// @Failure  401 {object} ErrorResponse "Credentials are missing, invalid or expired"
+// @Failure  403 {object} ErrorResponse "Credentials do not grant read access to the VDS"
+// @Failure  404 {object} ErrorResponse "VDS not found"
 // @Failure  500 {object} ErrorResponse "openvds failed to process the request"
+// @Failure  503 {object} ErrorResponse "Storage account is unavailable, see Retry-After"
+// @Failure  504 {object} ErrorResponse "The deadline of the request passed, see X-Request-Deadline"
 // @Router   /metadata  [get]
 func (e *Endpoint) MetadataGet(ctx *gin.Context) {
 	var request MetadataRequest
-	err := parseGetRequest(ctx, &request)
+	err := e.parseGetRequest(ctx, &request)
 	if abortOnError(ctx, err) {
 		return
 	}
@@ -328,19 +2521,58 @@ func (e *Endpoint) MetadataGet(ctx *gin.Context) {
 	e.metadata(ctx, request)
 }
 
+// MetadataHead godoc
+// @Summary  Return the headers of the corresponding GET request, without the body
+// @Tags     metadata
+// @Param    query  query  string  True  "Urlencoded/escaped MetadataRequest"
+// @Success  200
+// @Failure  400 "Request is invalid"
+// @Failure  401 "Credentials are missing, invalid or expired"
+// @Failure  403 "Credentials do not grant read access to the VDS"
+// @Failure  404 "VDS not found"
+// @Failure  500 "openvds failed to process the request"
+// @Failure  503 "Storage account is unavailable, see Retry-After"
+// @Failure  504 "The deadline of the request passed, see X-Request-Deadline"
+// @Router   /metadata  [head]
+func (e *Endpoint) MetadataHead(ctx *gin.Context) {
+	var request MetadataRequest
+	err := e.parseGetRequest(ctx, &request)
+	if abortOnError(ctx, err) {
+		return
+	}
+
+	e.metadataHead(ctx, request)
+}
+
 // MetadataPost godoc
 // @Summary  Return volumetric metadata about the VDS
 // @description.markdown metadata
 // @Tags     metadata
-// @Param    body  body  MetadataRequest  True  "Request parameters"
+// @Param    body  body  MetadataRequest  True  "Request parameters. Alternatively a MetadataListRequest"
 // @Produce  json
-// @Success  200 {object} core.Metadata
+// @Success  200 {object} core.Metadata "Or an array of core.Metadata and ErrorResponse, for a MetadataListRequest"
 // @Failure  400 {object} ErrorResponse "Request is invalid"
+// @Failure  401 {object} ErrorResponse "Credentials are missing, invalid or expired"
+// @Failure  403 {object} ErrorResponse "Credentials do not grant read access to the VDS"
+// @Failure  404 {object} ErrorResponse "VDS not found"
+// @Failure  413 {object} ErrorResponse "Request body is too large"
 // @Failure  500 {object} ErrorResponse "openvds failed to process the request"
+// @Failure  503 {object} ErrorResponse "Storage account is unavailable, see Retry-After"
+// @Failure  504 {object} ErrorResponse "The deadline of the request passed, see X-Request-Deadline"
 // @Router   /metadata  [post]
 func (e *Endpoint) MetadataPost(ctx *gin.Context) {
+	body, err := readRequestBody(ctx)
+	if abortOnError(ctx, err) {
+		return
+	}
+
+	if isMetadataListRequest(body) {
+		e.metadataList(ctx, body)
+		return
+	}
+
 	var request MetadataRequest
-	err := parsePostRequest(ctx, &request)
+	err = e.parseRequestBody(ctx, body, &request)
 	if abortOnError(ctx, err) {
 		return
 	}
@@ -356,11 +2588,16 @@ func (e *Endpoint) MetadataPost(ctx *gin.Context) {
 // @Produce  multipart/mixed
 // @Success  200 {object} core.SliceMetadata "(Example below only for metadata part)"
 // @Failure  400 {object} ErrorResponse "Request is invalid"
+// @Failure  401 {object} ErrorResponse "Credentials are missing, invalid or expired"
+// @Failure  403 {object} ErrorResponse "Credentials do not grant read access to the VDS"
+// @Failure  404 {object} ErrorResponse "VDS not found"
 // @Failure  500 {object} ErrorResponse "openvds failed to process the request"
+// @Failure  503 {object} ErrorResponse "Storage account is unavailable, see Retry-After"
+// @Failure  504 {object} ErrorResponse "The deadline of the request passed, see X-Request-Deadline"
 // @Router   /slice  [get]
 func (e *Endpoint) SliceGet(ctx *gin.Context) {
 	var request SliceRequest
-	err := parseGetRequest(ctx, &request)
+	err := e.parseGetRequest(ctx, &request)
 	if abortOnError(ctx, err) {
 		return
 	}
@@ -368,6 +2605,31 @@ func (e *Endpoint) SliceGet(ctx *gin.Context) {
 	e.makeDataRequest(ctx, request)
 }
 
+// SliceHead godoc
+// @Summary  Return the headers of the corresponding GET request, without the body
+// @description Only the slice metadata is read. Content-Length is computed
+// @description from its shape, and is that of the uncompressed response.
+// @Tags     slice
+// @Param    query  query  string  True  "Urlencoded/escaped SliceRequest"
+// @Success  200
+// @Failure  400 "Request is invalid"
+// @Failure  401 "Credentials are missing, invalid or expired"
+// @Failure  403 "Credentials do not grant read access to the VDS"
+// @Failure  404 "VDS not found"
+// @Failure  500 "openvds failed to process the request"
+// @Failure  503 "Storage account is unavailable, see Retry-After"
+// @Failure  504 "The deadline of the request passed, see X-Request-Deadline"
+// @Router   /slice  [head]
+func (e *Endpoint) SliceHead(ctx *gin.Context) {
+	var request SliceRequest
+	err := e.parseGetRequest(ctx, &request)
+	if abortOnError(ctx, err) {
+		return
+	}
+
+	e.makeDataHeadRequest(ctx, request)
+}
+
 // SlicePost godoc
 // @Summary  Fetch a slice from a VDS
 // @description.markdown slice
@@ -377,11 +2639,17 @@ func (e *Endpoint) SliceGet(ctx *gin.Context) {
 // @Produce  multipart/mixed
 // @Success  200 {object} core.SliceMetadata "(Example below only for metadata part)"
 // @Failure  400 {object} ErrorResponse "Request is invalid"
+// @Failure  401 {object} ErrorResponse "Credentials are missing, invalid or expired"
+// @Failure  403 {object} ErrorResponse "Credentials do not grant read access to the VDS"
+// @Failure  404 {object} ErrorResponse "VDS not found"
+// @Failure  413 {object} ErrorResponse "Request body is too large"
 // @Failure  500 {object} ErrorResponse "openvds failed to process the request"
+// @Failure  503 {object} ErrorResponse "Storage account is unavailable, see Retry-After"
+// @Failure  504 {object} ErrorResponse "The deadline of the request passed, see X-Request-Deadline"
 // @Router   /slice  [post]
 func (e *Endpoint) SlicePost(ctx *gin.Context) {
 	var request SliceRequest
-	err := parsePostRequest(ctx, &request)
+	err := e.parsePostRequest(ctx, &request)
 	if abortOnError(ctx, err) {
 		return
 	}
@@ -389,6 +2657,58 @@ func (e *Endpoint) SlicePost(ctx *gin.Context) {
 	e.makeDataRequest(ctx, request)
 }
 
+// SliceProgressiveGet godoc
+// @Summary  Fetch a slice from a VDS in progressively finer passes
+// @description.markdown slice_progressive
+// @Tags     slice
+// @Param    query  query  string  True  "Urlencoded/escaped SliceRequest"
+// @Produce  multipart/mixed
+// @Success  200 {object} core.SliceMetadata "(Example below only for metadata part)"
+// @Failure  400 {object} ErrorResponse "Request is invalid"
+// @Failure  401 {object} ErrorResponse "Credentials are missing, invalid or expired"
+// @Failure  403 {object} ErrorResponse "Credentials do not grant read access to the VDS"
+// @Failure  404 {object} ErrorResponse "VDS not found"
+// @Failure  500 {object} ErrorResponse "openvds failed to process the request"
+// @Failure  503 {object} ErrorResponse "Storage account is unavailable, see Retry-After"
+// @Failure  504 {object} ErrorResponse "The deadline of the request passed, see X-Request-Deadline"
+// @Router   /slice/progressive  [get]
+func (e *Endpoint) SliceProgressiveGet(ctx *gin.Context) {
+	var request SliceRequest
+	err := e.parseGetRequest(ctx, &request)
+	if abortOnError(ctx, err) {
+		return
+	}
+
+	e.makeProgressiveSliceRequest(ctx, request)
+}
+
+// SliceProgressivePost godoc
+// @Summary  Fetch a slice from a VDS in progressively finer passes
+// @description.markdown slice_progressive
+// @Tags     slice
+// @Param    body  body  SliceRequest  True  "Query Parameters"
+// @Accept   application/json
+// @Produce  multipart/mixed
+// @Success  200 {object} core.SliceMetadata "(Example below only for metadata part)"
+// @Failure  400 {object} ErrorResponse "Request is invalid"
+// @Failure  401 {object} ErrorResponse "Credentials are missing, invalid or expired"
+// @Failure  403 {object} ErrorResponse "Credentials do not grant read access to the VDS"
+// @Failure  404 {object} ErrorResponse "VDS not found"
+// @Failure  413 {object} ErrorResponse "Request body is too large"
+// @Failure  500 {object} ErrorResponse "openvds failed to process the request"
+// @Failure  503 {object} ErrorResponse "Storage account is unavailable, see Retry-After"
+// @Failure  504 {object} ErrorResponse "The deadline of the request passed, see X-Request-Deadline"
+// @Router   /slice/progressive  [post]
+func (e *Endpoint) SliceProgressivePost(ctx *gin.Context) {
+	var request SliceRequest
+	err := e.parsePostRequest(ctx, &request)
+	if abortOnError(ctx, err) {
+		return
+	}
+
+	e.makeProgressiveSliceRequest(ctx, request)
+}
+
 // FenceGet godoc
 // @Summary  Returns traces along an arbitrary path, such as a well-path
 // @description.markdown fence
@@ -398,16 +2718,56 @@ func (e *Endpoint) SlicePost(ctx *gin.Context) {
 // @Produce  multipart/mixed
 // @Success  200 {object} core.FenceMetadata "(Example below only for metadata part)"
 // @Failure  400 {object} ErrorResponse "Request is invalid"
+// @Failure  401 {object} ErrorResponse "Credentials are missing, invalid or expired"
+// @Failure  403 {object} ErrorResponse "Credentials do not grant read access to the VDS"
+// @Failure  404 {object} ErrorResponse "VDS not found"
 // @Failure  500 {object} ErrorResponse "openvds failed to process the request"
+// @Failure  503 {object} ErrorResponse "Storage account is unavailable, see Retry-After"
+// @Failure  504 {object} ErrorResponse "The deadline of the request passed, see X-Request-Deadline"
 // @Router   /fence  [get]
 func (e *Endpoint) FenceGet(ctx *gin.Context) {
 	var request FenceRequest
-	err := parseGetRequest(ctx, &request)
+	err := e.parseGetRequest(ctx, &request)
 	if abortOnError(ctx, err) {
 		return
 	}
 
-	e.makeDataRequest(ctx, request)
+	err = request.normalizeCoordinates(e.limits().FenceCoordinates)
+	if abortOnError(ctx, err) {
+		return
+	}
+
+	e.makeFenceRequest(ctx, request)
+}
+
+// FenceHead godoc
+// @Summary  Return the headers of the corresponding GET request, without the body
+// @description Only the fence metadata is read. Content-Length is computed
+// @description from its shape, and is that of the uncompressed response.
+// @Tags     fence
+// @Param    query  query  string  True  "Urlencoded/escaped FenceRequest"
+// @Success  200
+// @Failure  400 "Request is invalid"
+// @Failure  401 "Credentials are missing, invalid or expired"
+// @Failure  403 "Credentials do not grant read access to the VDS"
+// @Failure  404 "VDS not found"
+// @Failure  500 "openvds failed to process the request"
+// @Failure  503 "Storage account is unavailable, see Retry-After"
+// @Failure  504 "The deadline of the request passed, see X-Request-Deadline"
+// @Router   /fence  [head]
+func (e *Endpoint) FenceHead(ctx *gin.Context) {
+	var request FenceRequest
+	err := e.parseGetRequest(ctx, &request)
+	if abortOnError(ctx, err) {
+		return
+	}
+
+	err = request.normalizeCoordinates(e.limits().FenceCoordinates)
+	if abortOnError(ctx, err) {
+		return
+	}
+
+	e.makeDataHeadRequest(ctx, request)
 }
 
 // FencePost godoc
@@ -419,11 +2779,126 @@ func (e *Endpoint) FenceGet(ctx *gin.Context) {
 // @Produce  multipart/mixed
 // @Success  200 {object} core.FenceMetadata "(Example below only for metadata part)"
 // @Failure  400 {object} ErrorResponse "Request is invalid"
+// @Failure  401 {object} ErrorResponse "Credentials are missing, invalid or expired"
+// @Failure  403 {object} ErrorResponse "Credentials do not grant read access to the VDS"
+// @Failure  404 {object} ErrorResponse "VDS not found"
+// @Failure  413 {object} ErrorResponse "Request body is too large"
 // @Failure  500 {object} ErrorResponse "openvds failed to process the request"
+// @Failure  503 {object} ErrorResponse "Storage account is unavailable, see Retry-After"
+// @Failure  504 {object} ErrorResponse "The deadline of the request passed, see X-Request-Deadline"
 // @Router   /fence  [post]
 func (e *Endpoint) FencePost(ctx *gin.Context) {
 	var request FenceRequest
-	err := parsePostRequest(ctx, &request)
+	err := e.parsePostRequest(ctx, &request)
+	if abortOnError(ctx, err) {
+		return
+	}
+
+	err = request.normalizeCoordinates(e.limits().FenceCoordinates)
+	if abortOnError(ctx, err) {
+		return
+	}
+
+	e.makeFenceRequest(ctx, request)
+}
+
+// SamplePost godoc
+// @Summary  Returns the values of the cube at scattered points
+// @description.markdown sample
+// @Tags     sample
+// @Param    body  body  SampleRequest  True  "Request Parameters"
+// @Accept   application/json
+// @Produce  multipart/mixed
+// @Success  200 {object} core.SampleMetadata "(Example below only for metadata part)"
+// @Failure  400 {object} ErrorResponse "Request is invalid"
+// @Failure  401 {object} ErrorResponse "Credentials are missing, invalid or expired"
+// @Failure  403 {object} ErrorResponse "Credentials do not grant read access to the VDS"
+// @Failure  404 {object} ErrorResponse "VDS not found"
+// @Failure  413 {object} ErrorResponse "Request body is too large"
+// @Failure  500 {object} ErrorResponse "openvds failed to process the request"
+// @Failure  503 {object} ErrorResponse "Storage account is unavailable, see Retry-After"
+// @Failure  504 {object} ErrorResponse "The deadline of the request passed, see X-Request-Deadline"
+// @Router   /sample  [post]
+func (e *Endpoint) SamplePost(ctx *gin.Context) {
+	var request SampleRequest
+	err := e.parsePostRequest(ctx, &request)
+	if abortOnError(ctx, err) {
+		return
+	}
+
+	err = validateCoordinates(request.Coordinates, e.limits().SamplePoints)
+	if abortOnError(ctx, err) {
+		return
+	}
+
+	e.makeDataRequest(ctx, request)
+}
+
+// TraversePost godoc
+// @Summary  Fetch a panel along a path of inline and crossline segments
+// @description.markdown traverse
+// @Tags     traverse
+// @Param    body  body  TraverseRequest  True  "Request parameters"
+// @Accept   application/json
+// @Produce  multipart/mixed
+// @Success  200 {object} TraverseMetadata "(Example below only for metadata part)"
+// @Failure  400 {object} ErrorResponse "Request is invalid"
+// @Failure  401 {object} ErrorResponse "Credentials are missing, invalid or expired"
+// @Failure  403 {object} ErrorResponse "Credentials do not grant read access to the VDS"
+// @Failure  404 {object} ErrorResponse "VDS not found"
+// @Failure  413 {object} ErrorResponse "Request body is too large"
+// @Failure  500 {object} ErrorResponse "openvds failed to process the request"
+// @Failure  503 {object} ErrorResponse "Storage account is unavailable, see Retry-After"
+// @Failure  504 {object} ErrorResponse "The deadline of the request passed, see X-Request-Deadline"
+// @Router   /traverse  [post]
+func (e *Endpoint) TraversePost(ctx *gin.Context) {
+	var request TraverseRequest
+	err := e.parsePostRequest(ctx, &request)
+	if abortOnError(ctx, err) {
+		return
+	}
+
+	err = request.validateSegmentCount(e.limits().TraverseSegments)
+	if abortOnError(ctx, err) {
+		return
+	}
+
+	e.makeDataRequest(ctx, request)
+}
+
+// AttributesAlongSurfaceGet godoc
+// @Summary  Returns horizon attributes along the surface
+// @description.markdown attribute_along
+// @Tags     attributes
+// @Param    query  query  string  True  "Urlencoded/escaped AttributeAlongSurfaceRequest"
+// @Produce  multipart/mixed
+// @Success  200 {object} core.AttributeMetadata "(Example below only for metadata part)"
+// @Failure  400 {object} ErrorResponse "Request is invalid, or too large for GET"
+// @Failure  401 {object} ErrorResponse "Credentials are missing, invalid or expired"
+// @Failure  403 {object} ErrorResponse "Credentials do not grant read access to the VDS"
+// @Failure  404 {object} ErrorResponse "VDS not found"
+// @Failure  500 {object} ErrorResponse "openvds failed to process the request"
+// @Failure  503 {object} ErrorResponse "Storage account is unavailable, see Retry-After"
+// @Failure  504 {object} ErrorResponse "The deadline of the request passed, see X-Request-Deadline"
+// @Router   /attributes/surface/along  [get]
+func (e *Endpoint) AttributesAlongSurfaceGet(ctx *gin.Context) {
+	err := validateQuerySize(ctx, e.limits().AttributeQuerySize)
+	if abortOnError(ctx, err) {
+		return
+	}
+
+	var request AttributeAlongSurfaceRequest
+	err = e.parseGetRequest(ctx, &request)
+	if abortOnError(ctx, err) {
+		return
+	}
+
+	err = request.validateAttributeCount(e.limits().Attributes)
+	if abortOnError(ctx, err) {
+		return
+	}
+
+	err = request.normalizeSurface(e.limits().SurfacePoints)
 	if abortOnError(ctx, err) {
 		return
 	}
@@ -440,11 +2915,72 @@ func (e *Endpoint) FencePost(ctx *gin.Context) {
 // @Produce  multipart/mixed
 // @Success  200 {object} core.AttributeMetadata "(Example below only for metadata part)"
 // @Failure  400 {object} ErrorResponse "Request is invalid"
+// @Failure  401 {object} ErrorResponse "Credentials are missing, invalid or expired"
+// @Failure  403 {object} ErrorResponse "Credentials do not grant read access to the VDS"
+// @Failure  404 {object} ErrorResponse "VDS not found"
+// @Failure  413 {object} ErrorResponse "Request body is too large"
 // @Failure  500 {object} ErrorResponse "openvds failed to process the request"
+// @Failure  503 {object} ErrorResponse "Storage account is unavailable, see Retry-After"
+// @Failure  504 {object} ErrorResponse "The deadline of the request passed, see X-Request-Deadline"
 // @Router   /attributes/surface/along  [post]
 func (e *Endpoint) AttributesAlongSurfacePost(ctx *gin.Context) {
 	var request AttributeAlongSurfaceRequest
-	err := parsePostRequest(ctx, &request)
+	err := e.parsePostRequest(ctx, &request)
+	if abortOnError(ctx, err) {
+		return
+	}
+
+	err = request.validateAttributeCount(e.limits().Attributes)
+	if abortOnError(ctx, err) {
+		return
+	}
+
+	err = request.normalizeSurface(e.limits().SurfacePoints)
+	if abortOnError(ctx, err) {
+		return
+	}
+
+	e.makeDataRequest(ctx, request)
+}
+
+// AttributesBetweenSurfacesGet godoc
+// @Summary  Returns horizon attributes between provided surfaces
+// @description.markdown attribute_between
+// @Tags     attributes
+// @Param    query  query  string  True  "Urlencoded/escaped AttributeBetweenSurfacesRequest"
+// @Produce  multipart/mixed
+// @Success  200 {object} core.AttributeMetadata "(Example below only for metadata part)"
+// @Failure  400 {object} ErrorResponse "Request is invalid, or too large for GET"
+// @Failure  401 {object} ErrorResponse "Credentials are missing, invalid or expired"
+// @Failure  403 {object} ErrorResponse "Credentials do not grant read access to the VDS"
+// @Failure  404 {object} ErrorResponse "VDS not found"
+// @Failure  500 {object} ErrorResponse "openvds failed to process the request"
+// @Failure  503 {object} ErrorResponse "Storage account is unavailable, see Retry-After"
+// @Failure  504 {object} ErrorResponse "The deadline of the request passed, see X-Request-Deadline"
+// @Router   /attributes/surface/between  [get]
+func (e *Endpoint) AttributesBetweenSurfacesGet(ctx *gin.Context) {
+	err := validateQuerySize(ctx, e.limits().AttributeQuerySize)
+	if abortOnError(ctx, err) {
+		return
+	}
+
+	var request AttributeBetweenSurfacesRequest
+	err = e.parseGetRequest(ctx, &request)
+	if abortOnError(ctx, err) {
+		return
+	}
+
+	err = request.validateAttributeCount(e.limits().Attributes)
+	if abortOnError(ctx, err) {
+		return
+	}
+
+	err = validateSurface("primarySurface", &request.PrimarySurface)
+	if abortOnError(ctx, err) {
+		return
+	}
+
+	err = validateSurface("secondarySurface", &request.SecondarySurface)
 	if abortOnError(ctx, err) {
 		return
 	}
@@ -461,11 +2997,32 @@ func (e *Endpoint) AttributesAlongSurfacePost(ctx *gin.Context) {
 // @Produce  multipart/mixed
 // @Success  200 {object} core.AttributeMetadata "(Example below only for metadata part)"
 // @Failure  400 {object} ErrorResponse "Request is invalid"
+// @Failure  401 {object} ErrorResponse "Credentials are missing, invalid or expired"
+// @Failure  403 {object} ErrorResponse "Credentials do not grant read access to the VDS"
+// @Failure  404 {object} ErrorResponse "VDS not found"
+// @Failure  413 {object} ErrorResponse "Request body is too large"
 // @Failure  500 {object} ErrorResponse "openvds failed to process the request"
+// @Failure  503 {object} ErrorResponse "Storage account is unavailable, see Retry-After"
+// @Failure  504 {object} ErrorResponse "The deadline of the request passed, see X-Request-Deadline"
 // @Router   /attributes/surface/between  [post]
 func (e *Endpoint) AttributesBetweenSurfacesPost(ctx *gin.Context) {
 	var request AttributeBetweenSurfacesRequest
-	err := parsePostRequest(ctx, &request)
+	err := e.parsePostRequest(ctx, &request)
+	if abortOnError(ctx, err) {
+		return
+	}
+
+	err = request.validateAttributeCount(e.limits().Attributes)
+	if abortOnError(ctx, err) {
+		return
+	}
+
+	err = validateSurface("primarySurface", &request.PrimarySurface)
+	if abortOnError(ctx, err) {
+		return
+	}
+
+	err = validateSurface("secondarySurface", &request.SecondarySurface)
 	if abortOnError(ctx, err) {
 		return
 	}
@@ -0,0 +1,147 @@
+package api
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/equinor/vds-slice/internal/core"
+)
+
+func TestValidateCoordinates(t *testing.T) {
+	nan := float32(math.NaN())
+	inf := float32(math.Inf(-1))
+
+	testCases := []struct {
+		name        string
+		coordinates [][]float32
+		limit       int
+		expected    string
+	}{
+		{
+			name:        "Finite coordinates within limit",
+			coordinates: [][]float32{{1, 2}, {3, 4}},
+			limit:       2,
+			expected:    "",
+		},
+		{
+			name:        "No limit",
+			coordinates: [][]float32{{1, 2}, {3, 4}, {5, 6}},
+			limit:       0,
+			expected:    "",
+		},
+		{
+			name:        "Too many coordinates",
+			coordinates: [][]float32{{1, 2}, {3, 4}, {5, 6}},
+			limit:       2,
+			expected:    "Too many coordinates in request: 3. The limit is 2",
+		},
+		{
+			name:        "NaN coordinate",
+			coordinates: [][]float32{{1, 2}, {3, 4}, {nan, 6}},
+			limit:       0,
+			expected:    "invalid coordinate [NaN 6] at position 2, expected finite values",
+		},
+		{
+			name:        "Infinite coordinate",
+			coordinates: [][]float32{{1, inf}},
+			limit:       0,
+			expected:    "invalid coordinate [1 -Inf] at position 0, expected finite values",
+		},
+	}
+
+	for _, testCase := range testCases {
+		err := validateCoordinates(testCase.coordinates, testCase.limit)
+		if testCase.expected == "" {
+			require.NoErrorf(t, err, "[%s]", testCase.name)
+			continue
+		}
+		require.IsTypef(t, &core.InvalidArgument{}, err, "[%s]", testCase.name)
+		require.EqualErrorf(t, err, testCase.expected, "[%s]", testCase.name)
+	}
+}
+
+func TestFlatCoordinates(t *testing.T) {
+	decode := func(coordinates string) FenceRequest {
+		var request FenceRequest
+		err := json.Unmarshal([]byte(`{
+			"vds": "https://account.blob.core.windows.net/container/blob",
+			"coordinateSystem": "ij",
+			`+coordinates+`
+		}`), &request)
+		require.NoError(t, err, coordinates)
+		return request
+	}
+
+	nested := decode(`"coordinates": [[1, 2], [3, 4]]`)
+	require.NoError(t, nested.normalizeCoordinates(0))
+	expected, err := nested.hash()
+	require.NoError(t, err)
+
+	for _, flat := range []string{
+		`"coordinatesFlat": [1, 2, 3, 4]`,
+		`"coordinatesFlat": "AACAPwAAAEAAAEBAAACAQA=="`,
+	} {
+		request := decode(flat)
+		_, err := request.hash()
+		require.NoError(t, err, "Expected %s to hash before normalizing", flat)
+
+		require.NoError(t, request.normalizeCoordinates(0), flat)
+		require.Equal(t, [][]float32{{1, 2}, {3, 4}}, request.Coordinates, flat)
+
+		hash, err := request.hash()
+		require.NoError(t, err)
+		require.Equal(t, expected, hash, "Expected %s to hash as nested", flat)
+	}
+}
+
+func TestFlatCoordinatesErrors(t *testing.T) {
+	testCases := []struct {
+		name     string
+		request  string
+		expected string
+	}{
+		{
+			name:     "Odd number of values",
+			request:  `{"coordinatesFlat": [1, 2, 3, 4, 5]}`,
+			expected: "invalid coordinate [5] at position 2, expected [x y] pair",
+		},
+		{
+			name:     "Odd number of packed values",
+			request:  `{"coordinatesFlat": "AACAPwAAAEAAAEBA"}`,
+			expected: "invalid coordinate [3] at position 1, expected [x y] pair",
+		},
+		{
+			name:     "Partial float",
+			request:  `{"coordinatesFlat": "AACA"}`,
+			expected: "field 'coordinatesFlat' must hold whole float32 values, got 3 bytes",
+		},
+		{
+			name:     "Invalid base64",
+			request:  `{"coordinatesFlat": "not base64!"}`,
+			expected: "field 'coordinatesFlat' is not valid base64",
+		},
+		{
+			name:     "Neither array nor string",
+			request:  `{"coordinatesFlat": {"x": 1}}`,
+			expected: "field 'coordinatesFlat' must be an array of numbers or a base64 string",
+		},
+		{
+			name:     "Both nested and flat",
+			request:  `{"coordinates": [[1, 2]], "coordinatesFlat": [1, 2]}`,
+			expected: "coordinates and coordinatesFlat are mutually exclusive",
+		},
+	}
+
+	for _, testCase := range testCases {
+		var request FenceRequest
+		err := json.Unmarshal([]byte(testCase.request), &request)
+		if err == nil {
+			err = request.normalizeCoordinates(0)
+		}
+		require.IsTypef(t, &core.InvalidArgument{}, err, "[%s]", testCase.name)
+		require.ErrorContainsf(t, err, testCase.expected, "[%s]", testCase.name)
+	}
+}
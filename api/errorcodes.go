@@ -0,0 +1,141 @@
+package api
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/equinor/vds-slice/internal/core"
+)
+
+/*
+ * Stable, machine readable codes for the errors we hand back to clients.
+ *
+ * Codes are set where the errors are created, see core.ErrorCode, and this
+ * table maps every code to the http status of the errors that carry it.
+ * Errors without a code, or with a code that is not listed for their status,
+ * are handed back with the default code of their status.
+ *
+ * Codes are part of the API. Messages may be reworded freely, but codes must
+ * never change meaning.
+ */
+var errorCodes = map[string]int{
+	"lineno_out_of_range":            http.StatusBadRequest,
+	"empty_selection":                http.StatusBadRequest,
+	"invalid_direction":              http.StatusBadRequest,
+	"invalid_lineno_mode":            http.StatusBadRequest,
+	"invalid_coordinate_system":      http.StatusBadRequest,
+	"invalid_interpolation":          http.StatusBadRequest,
+	"invalid_vertical_interpolation": http.StatusBadRequest,
+	"invalid_vertical_unit":          http.StatusBadRequest,
+	"vertical_unit_mismatch":         http.StatusBadRequest,
+	"invalid_attribute":              http.StatusBadRequest,
+	"too_many_coordinates":           http.StatusBadRequest,
+	"too_many_batch_requests":        http.StatusBadRequest,
+	"invalid_request_type":           http.StatusBadRequest,
+	"duplicate_request_name":         http.StatusBadRequest,
+	"resource_in_batch_request":      http.StatusBadRequest,
+	"invalid_coordinate":             http.StatusBadRequest,
+	"conflicting_coordinates":        http.StatusBadRequest,
+	"coordinate_out_of_bounds":       http.StatusBadRequest,
+	"invalid_vertical_window":        http.StatusBadRequest,
+	"vertical_window_out_of_bounds":  http.StatusBadRequest,
+	"inconsistent_surface_rows":      http.StatusBadRequest,
+	"empty_surface":                  http.StatusBadRequest,
+	"invalid_surface_value":          http.StatusBadRequest,
+	"surfaces_intersect":             http.StatusBadRequest,
+	"surfaces_do_not_overlap":        http.StatusBadRequest,
+	"unknown_field":                  http.StatusBadRequest,
+	"type_mismatch":                  http.StatusBadRequest,
+	"validation_failed":              http.StatusBadRequest,
+	"missing_query":                  http.StatusBadRequest,
+	"duplicate_parameter":            http.StatusBadRequest,
+	"malformed_request":              http.StatusBadRequest,
+	"missing_credentials":            http.StatusBadRequest,
+	"conflicting_credentials":        http.StatusBadRequest,
+	"unresolved_vds_alias":           http.StatusBadRequest,
+	"invalid_sas":                    http.StatusBadRequest,
+	"unsupported_credentials":        http.StatusBadRequest,
+	"incomplete_credentials":         http.StatusBadRequest,
+	"unsupported_scheme":             http.StatusBadRequest,
+	"invalid_url":                    http.StatusBadRequest,
+	"path_outside_root":              http.StatusBadRequest,
+	"incompatible_vertical_axis":     http.StatusBadRequest,
+	"incompatible_vds":               http.StatusBadRequest,
+	"query_too_large":                http.StatusBadRequest,
+	"endpoint_disabled":              http.StatusNotFound,
+	"sas_expired":                    http.StatusUnauthorized,
+	"storage_account_not_allowed":    http.StatusForbidden,
+	"request_too_large":              http.StatusRequestEntityTooLarge,
+	"client_deadline_exceeded":       http.StatusGatewayTimeout,
+	"server_timeout":                 http.StatusGatewayTimeout,
+}
+
+/* Code for errors that have no code of their own */
+var defaultErrorCodes = map[int]string{
+	http.StatusBadRequest:            "invalid_argument",
+	http.StatusUnauthorized:          "unauthorized",
+	http.StatusForbidden:             "forbidden",
+	http.StatusNotFound:              "vds_not_found",
+	http.StatusRequestEntityTooLarge: "request_too_large",
+	http.StatusServiceUnavailable:    "storage_unavailable",
+	http.StatusGatewayTimeout:        "deadline_exceeded",
+	http.StatusInternalServerError:   "internal_error",
+}
+
+/* Find the code and details of an error */
+func classifyError(err error) (string, map[string]string) {
+	status := httpStatusCode(err)
+
+	code, ok := defaultErrorCodes[status]
+	if !ok {
+		code = defaultErrorCodes[http.StatusInternalServerError]
+	}
+
+	var details map[string]string
+	var coded core.CodedError
+	if errors.As(err, &coded) && coded.Code() != "" {
+		if registered, ok := errorCodes[coded.Code()]; ok && registered == status {
+			code = coded.Code()
+			if len(coded.Details()) > 0 {
+				details = coded.Details()
+			}
+		}
+	}
+
+	if unavailable, ok := err.(*core.UnavailableError); ok {
+		seconds := int(math.Ceil(unavailable.RetryAfter().Seconds()))
+		details = map[string]string{"retryAfter": strconv.Itoa(seconds)}
+	}
+
+	return code, details
+}
+
+/** An InvalidArgument with a new message, that keeps the code of err
+ *
+ * For errors that are rephrased to say which part of the request they are
+ * about.
+ */
+func rephrasedInvalidArgument(err error, msg string) error {
+	var coded core.CodedError
+	if errors.As(err, &coded) {
+		return core.NewCodedInvalidArgument(coded.Code(), coded.Details(), msg)
+	}
+	return core.NewInvalidArgument(msg)
+}
+
+/*
+ * Code for responses that failed without an error being recorded, which
+ * happens for requests gin itself rejects, e.g. unknown paths.
+ */
+func statusErrorCode(status int) string {
+	switch status {
+	case http.StatusNotFound:
+		return "route_not_found"
+	case http.StatusMethodNotAllowed:
+		return "method_not_allowed"
+	default:
+		return defaultErrorCodes[http.StatusInternalServerError]
+	}
+}
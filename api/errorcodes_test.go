@@ -0,0 +1,567 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/stretchr/testify/require"
+
+	"github.com/equinor/vds-slice/internal/core"
+)
+
+/* A request to target, with the middleware of the endpoint left out */
+func requestContext(method string, target string, body string) *gin.Context {
+	ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+	ctx.Request = httptest.NewRequest(method, target, strings.NewReader(body))
+	return ctx
+}
+
+/* A context whose deadline, from source, has passed */
+func expiredContext(t *testing.T, source deadlineSource) context.Context {
+	ctx, cancel := context.WithDeadline(
+		context.WithValue(context.Background(), deadlineKey{}, source),
+		time.Unix(0, 0),
+	)
+	t.Cleanup(cancel)
+	return ctx
+}
+
+func TestErrorCodes(t *testing.T) {
+	_, invalidDirection := core.GetAxis("diagonal")
+	_, invalidCoordinateSystem := core.GetCoordinateSystem("xyz")
+	_, invalidLinenoMode := core.GetLinenoSystem(core.AxisI, "cdp")
+	_, invalidInterpolation := core.GetInterpolationMethod("bicubic")
+	_, invalidVerticalInterpolation := core.GetVerticalInterpolationMethod("angular")
+	_, invalidAttribute := core.GetAttributeType("mode")
+	_, invalidVerticalUnit := core.NewVerticalUnitConversion("ms", "hours")
+	_, verticalUnitMismatch := core.NewVerticalUnitConversion("ms", "m")
+	_, oddCoordinates := FlatCoordinates{values: []float32{1, 1, 0}}.pairs()
+
+	get := requestContext(http.MethodGet, "/slice", "")
+	_, invalidRequestType := decodeSubRequest(
+		get,
+		RequestedResource{},
+		BatchSubRequest{Type: "surface"},
+		core.Defaults{},
+	)
+	_, resourceInBatchRequest := decodeSubRequest(
+		get,
+		RequestedResource{},
+		BatchSubRequest{
+			Type:       "metadata",
+			Parameters: []byte(`{"vds": "https://account.blob.core.windows.net/c/b"}`),
+		},
+		core.Defaults{},
+	)
+	_, tooManyBatchRequests := (&Endpoint{Limits: Limits{BatchRequests: 2}}).decodeBatch(
+		get,
+		BatchRequest{Requests: make([]BatchSubRequest, 3)},
+	)
+	_, duplicateRequestName := (&Endpoint{}).decodeBatch(get, BatchRequest{
+		Requests: []BatchSubRequest{
+			{Name: "inline", Type: "surface"},
+			{Name: "inline", Type: "surface"},
+		},
+	})
+
+	_, duplicateParameter := queryParametersAsJson(
+		url.Values{"lineno": {"1", "2"}},
+		reflect.TypeOf(SliceRequest{}),
+	)
+
+	tooLarge := requestContext(http.MethodPost, "/slice", "{\"lineno\": 10}")
+	tooLarge.Request.Body = http.MaxBytesReader(
+		tooLarge.Writer,
+		tooLarge.Request.Body,
+		8,
+	)
+	_, requestTooLarge := readRequestBody(tooLarge)
+
+	_, conflictingCredentials := headerSas("sv=2020", "Bearer token")
+
+	blob := "https://account.blob.core.windows.net/container/blob"
+	allowlist, err := core.NewAllowlist([]string{
+		"https://other.blob.core.windows.net/container",
+	})
+	require.NoError(t, err)
+	_, invalidSas := core.MakeAzureConnection(nil, 0, "")(
+		blob,
+		core.Credentials{Sas: "sv=2020&srt=c"},
+	)
+	_, expiredSas := core.MakeAzureConnection(nil, 0, "")(
+		blob,
+		core.Credentials{Sas: "sv=2020&se=2000-01-01"},
+	)
+	_, notAllowed := core.MakeAzureConnection(allowlist, 0, "")(
+		blob,
+		core.Credentials{Sas: "sv=2020"},
+	)
+	_, unsupportedScheme := core.MakeConnection(
+		map[string]core.ConnectionMaker{"https": core.MakeAzureConnection(nil, 0, "")},
+	)("ftp://host/container/blob", core.Credentials{})
+	_, outsideRoot := core.MakeLocalConnection(t.TempDir(), nil)(
+		"/etc/passwd",
+		core.Credentials{},
+	)
+
+	testCases := []struct {
+		name    string
+		err     error
+		code    string
+		details map[string]string
+	}{
+		{
+			/* As handed over from core, see toError */
+			name: "Lineno out of range",
+			err: core.NewCodedInvalidArgument(
+				"lineno_out_of_range",
+				core.ErrorDetails{
+					"lineno":   "10",
+					"min":      "0",
+					"max":      "2",
+					"stepsize": "1",
+				},
+				"Invalid lineno: 10, valid range: [0:2:1]",
+			),
+			code: "lineno_out_of_range",
+			details: map[string]string{
+				"lineno":   "10",
+				"min":      "0",
+				"max":      "2",
+				"stepsize": "1",
+			},
+		},
+		{
+			name: "Bounds select no samples",
+			err: core.NewCodedInvalidArgument(
+				"empty_selection",
+				core.ErrorDetails{"axis": "Sample", "lower": "3", "upper": "1"},
+				"Bounds select no samples: Sample index 3 of the lower bound "+
+					"is above index 1 of the upper bound",
+			),
+			code: "empty_selection",
+			details: map[string]string{
+				"axis":  "Sample",
+				"lower": "3",
+				"upper": "1",
+			},
+		},
+		{
+			name:    "Invalid direction",
+			err:     invalidDirection,
+			code:    "invalid_direction",
+			details: map[string]string{"direction": "diagonal"},
+		},
+		{
+			name:    "Invalid coordinate system",
+			err:     invalidCoordinateSystem,
+			code:    "invalid_coordinate_system",
+			details: map[string]string{"coordinateSystem": "xyz"},
+		},
+		{
+			name:    "Invalid interpolation",
+			err:     invalidInterpolation,
+			code:    "invalid_interpolation",
+			details: map[string]string{"interpolation": "bicubic"},
+		},
+		{
+			name:    "Invalid vertical interpolation",
+			err:     invalidVerticalInterpolation,
+			code:    "invalid_vertical_interpolation",
+			details: map[string]string{"verticalInterpolation": "angular"},
+		},
+		{
+			name:    "Invalid lineno mode",
+			err:     invalidLinenoMode,
+			code:    "invalid_lineno_mode",
+			details: map[string]string{"linenoMode": "cdp"},
+		},
+		{
+			name:    "Invalid vertical unit",
+			err:     invalidVerticalUnit,
+			code:    "invalid_vertical_unit",
+			details: map[string]string{"verticalUnit": "hours"},
+		},
+		{
+			name:    "Vertical unit mismatch",
+			err:     verticalUnitMismatch,
+			code:    "vertical_unit_mismatch",
+			details: map[string]string{"from": "ms", "to": "m"},
+		},
+		{
+			name:    "Invalid attribute",
+			err:     invalidAttribute,
+			code:    "invalid_attribute",
+			details: map[string]string{"attribute": "mode"},
+		},
+		{
+			name:    "Invalid attribute in request",
+			err:     AttributeRequest{Attributes: []string{"max", "mode"}}.validateAttributes(),
+			code:    "invalid_attribute",
+			details: map[string]string{"attribute": "mode"},
+		},
+		{
+			name:    "Too many coordinates",
+			err:     validateCoordinates([][]float32{{0, 0}, {1, 1}}, 1),
+			code:    "too_many_coordinates",
+			details: map[string]string{"count": "2", "limit": "1"},
+		},
+		{
+			name:    "Too many requests in batch",
+			err:     tooManyBatchRequests,
+			code:    "too_many_batch_requests",
+			details: map[string]string{"count": "3", "limit": "2"},
+		},
+		{
+			name:    "Invalid batch request type",
+			err:     invalidRequestType,
+			code:    "invalid_request_type",
+			details: map[string]string{"type": "surface"},
+		},
+		{
+			name:    "Duplicate batch request name",
+			err:     duplicateRequestName,
+			code:    "duplicate_request_name",
+			details: map[string]string{"name": "inline"},
+		},
+		{
+			name: "Resource in batch request",
+			err:  resourceInBatchRequest,
+			code: "resource_in_batch_request",
+		},
+		{
+			name:    "Coordinate is not a pair",
+			err:     oddCoordinates,
+			code:    "invalid_coordinate",
+			details: map[string]string{"position": "1"},
+		},
+		{
+			name:    "Coordinate is not finite",
+			err:     validateCoordinates([][]float32{{0, 0}, {float32(math.NaN()), 1}}, 0),
+			code:    "invalid_coordinate",
+			details: map[string]string{"position": "1"},
+		},
+		{
+			name: "Both nested and flat coordinates",
+			err: (&FenceRequest{
+				Coordinates:     [][]float32{{0, 0}},
+				CoordinatesFlat: &FlatCoordinates{},
+			}).normalizeCoordinates(0),
+			code: "conflicting_coordinates",
+		},
+		{
+			name: "Coordinate out of bounds",
+			err: core.NewCodedInvalidArgument(
+				"coordinate_out_of_bounds",
+				nil,
+				"Coordinate (5.000000,0.000000) is out of boundaries in dimension 0.",
+			),
+			code: "coordinate_out_of_bounds",
+		},
+		{
+			name:    "Above out of range",
+			err:     validateVerticalWindow(-1, 0, 1),
+			code:    "invalid_vertical_window",
+			details: map[string]string{"field": "above"},
+		},
+		{
+			name: "Vertical window out of bounds",
+			err: core.NewCodedInvalidArgument(
+				"vertical_window_out_of_bounds",
+				core.ErrorDetails{"row": "1", "column": "2"},
+				"Vertical window is out of vertical bounds at row: 1 col:2",
+			),
+			code:    "vertical_window_out_of_bounds",
+			details: map[string]string{"row": "1", "column": "2"},
+		},
+		{
+			name: "Inconsistent surface rows",
+			err: validateSurface("primarySurface", &core.RegularSurface{
+				Values: [][]float32{{1, 1}, {1}},
+			}),
+			code: "inconsistent_surface_rows",
+		},
+		{
+			name: "Empty surface",
+			err:  validateSurface("primarySurface", &core.RegularSurface{}),
+			code: "empty_surface",
+		},
+		{
+			name: "Invalid surface value",
+			err: validateSurface("primarySurface", &core.RegularSurface{
+				Values: [][]float32{{1, 1}, {1, float32(math.Inf(1))}},
+			}),
+			code:    "invalid_surface_value",
+			details: map[string]string{"row": "1", "column": "1"},
+		},
+		{
+			name: "Surfaces intersect",
+			err: core.NewCodedInvalidArgument(
+				"surfaces_intersect",
+				core.ErrorDetails{"row": "3", "column": "4"},
+				"Surfaces intersect at primary surface point (3, 4)",
+			),
+			code:    "surfaces_intersect",
+			details: map[string]string{"row": "3", "column": "4"},
+		},
+		{
+			name: "Unknown field",
+			err: decodeRequest(
+				get,
+				[]byte(`{"line_no": 100}`),
+				&SliceRequest{},
+			),
+			code: "unknown_field",
+		},
+		{
+			name: "Type mismatch",
+			err: decodeRequest(
+				get,
+				[]byte(`{"lineno": "100"}`),
+				&SliceRequest{},
+			),
+			code:    "type_mismatch",
+			details: map[string]string{"field": "lineno"},
+		},
+		{
+			name: "Missing field",
+			err: func() error {
+				request := &struct {
+					Lineno *int `binding:"required"`
+				}{}
+				return validationError(binding.Validator.ValidateStruct(request), request)
+			}(),
+			code:    "validation_failed",
+			details: map[string]string{"field": "Lineno", "tag": "required"},
+		},
+		{
+			name: "Missing query",
+			err:  (&Endpoint{}).parseGetRequest(get, &SliceRequest{}),
+			code: "missing_query",
+		},
+		{
+			name:    "Repeated query parameter",
+			err:     duplicateParameter,
+			code:    "duplicate_parameter",
+			details: map[string]string{"parameter": "lineno"},
+		},
+		{
+			name: "Malformed json",
+			err: (&Endpoint{}).parseRequestBody(
+				requestContext(http.MethodPost, "/slice", ""),
+				[]byte("{"),
+				&SliceRequest{},
+			),
+			code: "malformed_request",
+		},
+		{
+			name: "Missing credentials",
+			err: (&RequestedResource{
+				Vds: "https://account.blob.core.windows.net/container/blob",
+			}).NormalizeConnection(),
+			code: "missing_credentials",
+		},
+		{
+			name: "Conflicting credentials",
+			err: (&RequestedResource{
+				Vds:         "https://account.blob.core.windows.net/container/blob",
+				Sas:         "sv=2020",
+				bearerToken: "token",
+			}).NormalizeConnection(),
+			code: "conflicting_credentials",
+		},
+		{
+			name: "Sas in both request and header",
+			err:  conflictingCredentials,
+			code: "conflicting_credentials",
+		},
+		{
+			name: "Invalid sas",
+			err:  invalidSas,
+			code: "invalid_sas",
+		},
+		{
+			name:    "Unsupported scheme",
+			err:     unsupportedScheme,
+			code:    "unsupported_scheme",
+			details: map[string]string{"scheme": "ftp"},
+		},
+		{
+			name: "Path outside of root",
+			err:  outsideRoot,
+			code: "path_outside_root",
+		},
+		{
+			name: "Rephrased errors keep their code",
+			err: rephrasedInvalidArgument(
+				outsideRoot,
+				"segment 1: "+outsideRoot.Error(),
+			),
+			code: "path_outside_root",
+		},
+		{
+			name: "Other invalid argument",
+			err:  core.NewInvalidArgument("something is wrong"),
+			code: "invalid_argument",
+		},
+		{
+			name: "Code of another status",
+			err:  core.NewCodedInvalidArgument("sas_expired", nil, "expired"),
+			code: "invalid_argument",
+		},
+		{
+			name: "Unregistered code",
+			err:  core.NewCodedInvalidArgument("no_such_code", nil, "unknown"),
+			code: "invalid_argument",
+		},
+		{
+			name: "Expiring sas",
+			err:  expiredSas,
+			code: "sas_expired",
+		},
+		{
+			name: "Anonymous access",
+			err: core.NewUnauthorizedError(
+				"Could not open VDS: 409 Public access is not permitted on this storage account.",
+			),
+			code: "unauthorized",
+		},
+		{
+			name: "Storage account not allowed",
+			err:  notAllowed,
+			code: "storage_account_not_allowed",
+		},
+		{
+			name: "Insufficient access",
+			err: core.NewForbiddenError(
+				"Could not open VDS: 403 Server failed to authenticate the request.",
+			),
+			code: "forbidden",
+		},
+		{
+			name: "VDS not found",
+			err: core.NewNotFoundError(
+				"Could not open VDS: 404 The specified blob does not exist",
+			),
+			code: "vds_not_found",
+		},
+		{
+			name: "Query too large",
+			err: validateQuerySize(
+				requestContext(http.MethodGet, "/slice?lineno=100", ""),
+				8,
+			),
+			code:    "query_too_large",
+			details: map[string]string{"limit": "8"},
+		},
+		{
+			name:    "Endpoint disabled",
+			err:     endpointDisabledError("attributes"),
+			code:    "endpoint_disabled",
+			details: map[string]string{"endpoint": "attributes"},
+		},
+		{
+			name:    "Request too large",
+			err:     requestTooLarge,
+			code:    "request_too_large",
+			details: map[string]string{"limit": "8"},
+		},
+		{
+			name:    "Storage unavailable",
+			err:     core.NewUnavailableError("unavailable", 1500*time.Millisecond),
+			code:    "storage_unavailable",
+			details: map[string]string{"retryAfter": "2"},
+		},
+		{
+			name: "Client deadline exceeded",
+			err:  checkDeadline(expiredContext(t, deadlineSource{client: true})),
+			code: "client_deadline_exceeded",
+		},
+		{
+			name: "Server timeout",
+			err: checkDeadline(expiredContext(
+				t,
+				deadlineSource{timeout: 30 * time.Second},
+			)),
+			code:    "server_timeout",
+			details: map[string]string{"timeout": "30s"},
+		},
+		{
+			name: "Internal error",
+			err:  core.NewInternalError("Failed to read from VDS."),
+			code: "internal_error",
+		},
+		{
+			name: "Error of unknown type",
+			err:  errors.New("unexpected internal error when writing Response Data"),
+			code: "internal_error",
+		},
+	}
+
+	for _, testCase := range testCases {
+		require.Errorf(t, testCase.err, "[%s] Expected an error", testCase.name)
+
+		code, details := classifyError(testCase.err)
+		require.Equalf(t, testCase.code, code, "[%s]", testCase.name)
+		require.Equalf(t, testCase.details, details, "[%s]", testCase.name)
+	}
+}
+
+/* Every code is handed back with a status that has a default code */
+func TestErrorCodesHaveKnownStatus(t *testing.T) {
+	for code, status := range errorCodes {
+		_, ok := defaultErrorCodes[status]
+		require.Truef(t, ok, "[%s] Unexpected status %d", code, status)
+	}
+}
+
+func TestErrorResponseHasCode(t *testing.T) {
+	w := httptest.NewRecorder()
+	ctx, r := gin.CreateTestContext(w)
+
+	r.Use(ErrorHandler)
+	r.GET("/", func(ctx *gin.Context) {
+		abortOnError(ctx, core.NewCodedInvalidArgument(
+			"lineno_out_of_range",
+			core.ErrorDetails{
+				"lineno":   "10",
+				"min":      "0",
+				"max":      "2",
+				"stepsize": "1",
+			},
+			"Invalid lineno: 10, valid range: [0:2:1]",
+		))
+	})
+
+	ctx.Request, _ = http.NewRequest(http.MethodGet, "/", nil)
+	r.ServeHTTP(w, ctx.Request)
+
+	require.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+
+	response := ErrorResponse{}
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	require.NoError(t, err)
+
+	expected := ErrorResponse{
+		Error: "Invalid lineno: 10, valid range: [0:2:1]",
+		Code:  "lineno_out_of_range",
+		Details: map[string]string{
+			"lineno":   "10",
+			"min":      "0",
+			"max":      "2",
+			"stepsize": "1",
+		},
+	}
+	require.Equal(t, expected, response)
+}
@@ -0,0 +1,334 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/equinor/vds-slice/internal/core"
+)
+
+/* Traces per batch when no batch size is configured */
+const defaultFenceBatchSize = 10000
+
+/** When and how fences are processed in batches of traces
+ *
+ * Size is the number of traces per batch, where 0 means
+ * defaultFenceBatchSize. With Always every fence longer than a batch is
+ * batched, otherwise only fences that ask for it with FenceRequest.Batched.
+ */
+type FenceBatching struct {
+	Size   int
+	Always bool
+}
+
+func (b FenceBatching) size() int {
+	if b.Size <= 0 {
+		return defaultFenceBatchSize
+	}
+	return b.Size
+}
+
+/** Whether the fence is to be processed in batches
+ *
+ * Batched data is streamed into the single data part of a multipart
+ * response, so fences without a metadata part, or with trace headers, are
+ * never batched. Asking for both is an error, while fences that are batched
+ * by configuration alone are processed whole.
+ */
+func (b FenceBatching) batches(request FenceRequest) (bool, error) {
+	if request.Batched && request.OmitMetadata {
+		return false, core.NewInvalidArgument(
+			"batched is not supported together with omitMetadata, as batched " +
+				"fences are streamed into a multipart response",
+		)
+	}
+
+	if request.Batched && len(request.HeaderFields) > 0 {
+		return false, core.NewInvalidArgument(
+			"batched is not supported together with headerFields, as batched " +
+				"fences have a single data part",
+		)
+	}
+
+	if !request.Batched &&
+		(!b.Always || request.OmitMetadata || len(request.HeaderFields) > 0) {
+		return false, nil
+	}
+	return len(request.Coordinates) > b.size(), nil
+}
+
+/* The fence as consecutive fences of at most size coordinates */
+func (request FenceRequest) split(size int) []FenceRequest {
+	batches := []FenceRequest{}
+	for start := 0; start < len(request.Coordinates); start += size {
+		end := start + size
+		if end > len(request.Coordinates) {
+			end = len(request.Coordinates)
+		}
+
+		batch := request
+		batch.Coordinates = request.Coordinates[start:end]
+		batches = append(batches, batch)
+	}
+	return batches
+}
+
+/** The metadata of the whole fence, given the metadata of its first batch
+ *
+ * The batch gives the format and the length of the (possibly resampled)
+ * traces. The number of traces, and the trace indices of nearest_trace, are
+ * those of the whole fence.
+ */
+func (request FenceRequest) batchedMetadata(
+	handle core.DSHandle,
+	first []byte,
+) ([]byte, error) {
+	unresampled := request
+	unresampled.ResampleTo = nil
+	buf, err := unresampled.executeMetadata(handle)
+	if err != nil {
+		return nil, err
+	}
+
+	var whole core.FenceMetadata
+	err = json.Unmarshal(buf, &whole)
+	if err != nil {
+		return nil, core.NewInternalError(err.Error())
+	}
+
+	var metadata core.FenceMetadata
+	err = json.Unmarshal(first, &metadata)
+	if err != nil {
+		return nil, core.NewInternalError(err.Error())
+	}
+
+	metadata.Shape[0] = whole.Shape[0]
+	metadata.Indices = whole.Indices
+
+	out, err := json.Marshal(metadata)
+	if err != nil {
+		return nil, core.NewInternalError(err.Error())
+	}
+	return out, nil
+}
+
+/* Send the fence whole or in batches, see FenceBatching */
+func (e *Endpoint) makeFenceRequest(ctx *gin.Context, request FenceRequest) {
+	batched, err := e.FenceBatching.batches(request)
+	if abortOnError(ctx, err) {
+		return
+	}
+
+	if !batched {
+		e.makeDataRequest(ctx, request)
+		return
+	}
+	e.makeBatchedFenceRequest(ctx, request)
+}
+
+/** Process a fence batch by batch, streaming every batch as it completes
+ *
+ * Only a single batch is held in memory, and reserved against the memory
+ * budget, at any time. In return the fence is neither cached nor retried as
+ * a whole, as part of it is already sent by the time a batch fails. Every
+ * batch is retried on its own. A fence that is already in the cache is sent
+ * from there, and may be served stale if storage is down, see StaleIfError.
+ */
+func (e *Endpoint) makeBatchedFenceRequest(
+	ctx *gin.Context,
+	request FenceRequest,
+) {
+	prepareRequestLogging(ctx, request)
+	defer e.audit(ctx, request, "")
+	conn, err := e.MakeVdsConnection(request.credentials())
+	if abortOnError(ctx, err) {
+		return
+	}
+
+	cacheKey, err := request.hash()
+	if abortOnError(ctx, err) {
+		return
+	}
+
+	_, hit := e.Cache.Get(cacheKey)
+	if hit && conn.IsAuthorizedToRead() {
+		e.serveDataRequest(ctx, request)
+		return
+	}
+
+	var handle core.DSHandle
+	vds, _ := request.credentials()
+	err = e.Breaker.Do(core.StorageHost(vds), func() error {
+		handle, err = e.openHandle(ctx.Request.Context(), conn)
+		return err
+	})
+	/* Cached fences may still be served when storage is down */
+	if err != nil && hit && core.IsStorageTransient(err) {
+		e.serveDataRequest(ctx, request)
+		return
+	}
+	if abortOnError(ctx, err) {
+		return
+	}
+	defer handle.Close()
+
+	batches := request.split(e.FenceBatching.size())
+
+	release, err := e.Budget.ReserveEstimate(func() (int64, error) {
+		return batches[0].estimateSize(handle)
+	})
+	if abortOnError(ctx, err) {
+		return
+	}
+	defer release()
+
+	read := func(batch FenceRequest) (data [][]byte, metadata []byte, err error) {
+		if err := checkDeadline(ctx.Request.Context()); err != nil {
+			return nil, nil, err
+		}
+		return batch.execute(handle)
+	}
+
+	first, batchMetadata, err := read(batches[0])
+	if abortOnError(ctx, err) {
+		return
+	}
+
+	metadata, err := request.batchedMetadata(handle, batchMetadata)
+	if abortOnError(ctx, err) {
+		return
+	}
+
+	metadata, err = e.withEcho(ctx.Request.Context(), request, metadata)
+	if abortOnError(ctx, err) {
+		return
+	}
+
+	next := func(i int) ([]byte, error) {
+		if i == 0 {
+			data := first[0]
+			first = nil
+			return data, nil
+		}
+		data, _, err := read(batches[i])
+		if err != nil {
+			return nil, batchError(i, e.FenceBatching.size(), err)
+		}
+		return data[0], nil
+	}
+
+	err = writeBatchedResponse(ctx, metadata, len(batches), next)
+	setStorageStats(ctx, handleStats(handle))
+	if err != nil {
+		log.Println(sanitizeErrorMessage(err.Error()))
+		return
+	}
+
+	e.observeRequest(request, metadata)
+	if e.Observer != nil {
+		e.Observer.FenceBatches("fence", len(batches))
+	}
+}
+
+/** Place errors that are about the request in the whole fence
+ *
+ * Positions in the error are those within the batch, which the client knows
+ * nothing about.
+ */
+func batchError(batch int, size int, err error) error {
+	var invalid *core.InvalidArgument
+	if errors.As(err, &invalid) {
+		return rephrasedInvalidArgument(err, fmt.Sprintf(
+			"batch %d, coordinates from position %d: %v",
+			batch,
+			batch*size,
+			err,
+		))
+	}
+	return err
+}
+
+/** Write a multipart response where the data part is written batch by batch
+ *
+ * The metadata part is written up front, and every batch is appended to the
+ * single data part as soon as it is read, and flushed. The data part has no
+ * checksum, as it is not known before the last batch is read.
+ *
+ * The status is written with the metadata part. If a batch fails after that,
+ * the data part is cut short and followed by a part named error, with the
+ * ErrorResponse as json, before the response is ended.
+ */
+func writeBatchedResponse(
+	ctx *gin.Context,
+	metadata []byte,
+	batches int,
+	next func(i int) ([]byte, error),
+) error {
+	writer := multipart.NewWriter(ctx.Writer)
+
+	ctx.Header("Content-Type", "multipart/mixed; boundary="+writer.Boundary())
+	ctx.Status(http.StatusOK)
+
+	err := writeData(ctx, writer, "application/json", metadata)
+	if err != nil {
+		ctx.Abort()
+		return err
+	}
+
+	part, err := writer.CreatePart(
+		textproto.MIMEHeader{"Content-Type": {"application/octet-stream"}},
+	)
+	if err != nil {
+		ctx.Abort()
+		return err
+	}
+
+	for i := 0; i < batches; i++ {
+		data, err := next(i)
+		if err != nil {
+			if err := writeErrorPart(writer, err); err != nil {
+				log.Println(err)
+			}
+			writer.Close()
+			ctx.Abort()
+			return err
+		}
+
+		_, err = part.Write(data)
+		if err != nil {
+			ctx.Abort()
+			return err
+		}
+		ctx.Writer.Flush()
+	}
+
+	return writer.Close()
+}
+
+/* The ErrorResponse of err as a part named error */
+func writeErrorPart(writer *multipart.Writer, err error) error {
+	msg := sanitizeErrorMessage(err.Error())
+	response := ErrorResponse{Error: msg}
+	response.Code, response.Details = classifyError(err)
+
+	body, err := json.Marshal(response)
+	if err != nil {
+		return err
+	}
+
+	header := textproto.MIMEHeader{
+		"Content-Type": {"application/json"},
+		"Content-Disposition": {
+			mime.FormatMediaType("inline", map[string]string{"name": "error"}),
+		},
+	}
+	return writePart(writer, header, body)
+}
@@ -0,0 +1,429 @@
+package api
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/equinor/vds-slice/api/vdsslicepb"
+	"github.com/equinor/vds-slice/internal/core"
+)
+
+/*
+ * Max size of the data in a single streamed message. Well below the default
+ * max message size of 4 MB, to leave room for the framing.
+ */
+const maxChunkSize = 2 * 1024 * 1024
+
+/** Metadata key that tells whether a data response was served from cache */
+const cacheHitMetadata = "x-cache-hit"
+
+/* Header with the estimated bytes fetched from storage for the response */
+const storageBytesMetadata = "x-storage-bytes"
+
+/* Header with the estimated number of requests to storage for the response */
+const storageRequestsMetadata = "x-storage-requests"
+
+/* Header with the seconds spent reading from storage for the response */
+const storageReadMetadata = "x-storage-read-seconds"
+
+/* Metadata key that tells that a response was served stale, see StaleIfError */
+const servedStaleMetadata = "x-served-stale"
+
+/** gRPC facade of the Endpoint
+ *
+ * Requests are converted to their http counterparts, and go through the same
+ * validation, cache and execution. Errors are mapped to grpc status codes,
+ * with the same sanitized messages as the http API hands out.
+ */
+type GrpcServer struct {
+	vdsslicepb.UnimplementedVdsSliceServer
+	endpoint *Endpoint
+}
+
+func NewGrpcServer(endpoint *Endpoint) *GrpcServer {
+	return &GrpcServer{endpoint: endpoint}
+}
+
+func grpcStatusCode(err error) codes.Code {
+	switch err.(type) {
+	case *core.InvalidArgument:
+		return codes.InvalidArgument
+	case *core.UnauthorizedError:
+		return codes.Unauthenticated
+	case *core.ForbiddenError:
+		return codes.PermissionDenied
+	case *core.NotFoundError:
+		return codes.NotFound
+	case *core.PayloadTooLargeError:
+		return codes.ResourceExhausted
+	case *core.UnavailableError:
+		return codes.Unavailable
+	case *core.DeadlineExceededError:
+		return codes.DeadlineExceeded
+	default:
+		return codes.Internal
+	}
+}
+
+func grpcError(err error) error {
+	return status.Error(grpcStatusCode(err), sanitizeErrorMessage(err.Error()))
+}
+
+/* First value of key in the request metadata, if any */
+func incomingMetadata(ctx context.Context, key string) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get(key)
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+/*
+ * The grpc counterpart of parseGetRequest and parsePostRequest, for requests
+ * that are already decoded.
+ */
+func (e *Endpoint) parseGrpcRequest(ctx context.Context, v Normalizable) error {
+	return e.validateRequest(
+		v,
+		incomingMetadata(ctx, strings.ToLower(sasHeader)),
+		incomingMetadata(ctx, "authorization"),
+	)
+}
+
+func resourceFromProto(resource *vdsslicepb.RequestedResource) RequestedResource {
+	out := RequestedResource{
+		Vds: resource.GetVds(),
+		Sas: resource.GetSas(),
+	}
+	if s3 := resource.GetS3(); s3 != nil {
+		out.S3 = &S3Options{
+			Region:       s3.GetRegion(),
+			AccessKeyId:  s3.GetAccessKeyId(),
+			SecretKey:    s3.GetSecretKey(),
+			SessionToken: s3.GetSessionToken(),
+		}
+	}
+	return out
+}
+
+func surfaceFromProto(surface *vdsslicepb.RegularSurface) core.RegularSurface {
+	var values [][]float32
+	for _, row := range surface.GetValues() {
+		values = append(values, row.GetValues())
+	}
+
+	return core.RegularSurface{
+		Values:    values,
+		Rotation:  surface.Rotation,
+		Xori:      surface.Xori,
+		Yori:      surface.Yori,
+		Xinc:      surface.GetXinc(),
+		Yinc:      surface.GetYinc(),
+		FillValue: surface.FillValue,
+	}
+}
+
+func sliceRequestFromProto(request *vdsslicepb.SliceRequest) SliceRequest {
+	out := SliceRequest{
+		RequestedResource: resourceFromProto(request.GetResource()),
+		Direction:         request.GetDirection(),
+		VerticalUnit:      request.GetVerticalUnit(),
+	}
+	if request.Lineno != nil {
+		lineno := int(*request.Lineno)
+		out.Lineno = &lineno
+	}
+	if request.FillValue != nil {
+		fillValue := core.FillValue(*request.FillValue)
+		out.FillValue = &fillValue
+	}
+	for _, bound := range request.GetBounds() {
+		direction := bound.GetDirection()
+		lower := int(bound.GetLower())
+		upper := int(bound.GetUpper())
+		out.Bounds = append(out.Bounds, core.Bound{
+			Direction: &direction,
+			Lower:     &lower,
+			Upper:     &upper,
+		})
+	}
+	return out
+}
+
+func fenceRequestFromProto(request *vdsslicepb.FenceRequest) FenceRequest {
+	out := FenceRequest{
+		RequestedResource: resourceFromProto(request.GetResource()),
+		CoordinateSystem:  request.GetCoordinateSystem(),
+		Interpolation:     request.GetInterpolation(),
+		FillValue:         request.FillValue,
+		VerticalUnit:      request.GetVerticalUnit(),
+	}
+	for _, coordinate := range request.GetCoordinates() {
+		out.Coordinates = append(
+			out.Coordinates,
+			[]float32{coordinate.GetX(), coordinate.GetY()},
+		)
+	}
+	return out
+}
+
+func attributeAlongSurfaceRequestFromProto(
+	request *vdsslicepb.AttributeAlongSurfaceRequest,
+) AttributeAlongSurfaceRequest {
+	surface := surfaceFromProto(request.GetSurface())
+	return AttributeAlongSurfaceRequest{
+		AttributeRequest: AttributeRequest{
+			RequestedResource:     resourceFromProto(request.GetResource()),
+			Interpolation:         request.GetInterpolation(),
+			VerticalInterpolation: request.GetVerticalInterpolation(),
+			Stepsize:              request.GetStepsize(),
+			Attributes:            request.GetAttributes(),
+			VerticalUnit:          request.GetVerticalUnit(),
+		},
+		Surface: &surface,
+		Above:   request.GetAbove(),
+		Below:   request.GetBelow(),
+	}
+}
+
+func attributeBetweenSurfacesRequestFromProto(
+	request *vdsslicepb.AttributeBetweenSurfacesRequest,
+) AttributeBetweenSurfacesRequest {
+	return AttributeBetweenSurfacesRequest{
+		AttributeRequest: AttributeRequest{
+			RequestedResource:     resourceFromProto(request.GetResource()),
+			Interpolation:         request.GetInterpolation(),
+			VerticalInterpolation: request.GetVerticalInterpolation(),
+			Stepsize:              request.GetStepsize(),
+			Attributes:            request.GetAttributes(),
+			VerticalUnit:          request.GetVerticalUnit(),
+		},
+		PrimarySurface:   surfaceFromProto(request.GetPrimarySurface()),
+		SecondarySurface: surfaceFromProto(request.GetSecondarySurface()),
+	}
+}
+
+/** Stream a data response
+ *
+ * The metadata goes first, followed by every data part in chunks of at most
+ * maxChunkSize bytes. Empty parts are sent as a single empty chunk, such that
+ * the client sees every part.
+ */
+func sendData(stream grpc.ServerStream, response *dataResponse) error {
+	header := metadata.Pairs(
+		storageBytesMetadata,
+		strconv.FormatInt(response.storage.StorageBytes, 10),
+		storageRequestsMetadata,
+		strconv.FormatInt(response.storage.StorageRequests, 10),
+		storageReadMetadata,
+		strconv.FormatFloat(response.storage.ReadTime.Seconds(), 'f', -1, 64),
+	)
+	if response.cacheHit {
+		header.Set(cacheHitMetadata, "true")
+	}
+	if response.stale {
+		header.Set(servedStaleMetadata, "true")
+	}
+	err := stream.SetHeader(header)
+	if err != nil {
+		return err
+	}
+
+	err = stream.SendMsg(&vdsslicepb.DataResponse{
+		Response: &vdsslicepb.DataResponse_Metadata{
+			Metadata: string(response.metadata),
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	for part, data := range response.data {
+		for offset := 0; offset == 0 || offset < len(data); offset += maxChunkSize {
+			end := offset + maxChunkSize
+			if end > len(data) {
+				end = len(data)
+			}
+
+			err := stream.SendMsg(&vdsslicepb.DataResponse{
+				Response: &vdsslicepb.DataResponse_Data{
+					Data: &vdsslicepb.DataChunk{
+						Part: uint32(part),
+						Data: data[offset:end],
+					},
+				},
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+/* NotFound if the endpoint is disabled, see EnabledEndpoints */
+func (s *GrpcServer) checkEnabled(name string) error {
+	if !s.endpoint.Endpoints.Enabled(name) {
+		return grpcError(endpointDisabledError(name))
+	}
+	return nil
+}
+
+func (s *GrpcServer) streamData(
+	stream grpc.ServerStream,
+	request DataRequest,
+) error {
+	response, err := s.endpoint.fetchData(stream.Context(), request)
+	if err != nil {
+		s.endpoint.auditGrpc(stream.Context(), request, "", err, 0)
+		return grpcError(err)
+	}
+	defer response.release()
+
+	err = sendData(stream, response)
+	s.endpoint.auditGrpc(
+		stream.Context(),
+		request,
+		response.hash,
+		err,
+		responseBytes(response),
+	)
+	return err
+}
+
+/* The size of the metadata and data of response */
+func responseBytes(response *dataResponse) int64 {
+	bytes := int64(len(response.metadata))
+	for _, data := range response.data {
+		bytes += int64(len(data))
+	}
+	return bytes
+}
+
+func (s *GrpcServer) Metadata(
+	ctx context.Context,
+	in *vdsslicepb.MetadataRequest,
+) (*vdsslicepb.MetadataResponse, error) {
+	if err := s.checkEnabled(EndpointMetadata); err != nil {
+		return nil, err
+	}
+
+	request := MetadataRequest{
+		RequestedResource: resourceFromProto(in.GetResource()),
+		IncludeImportInfo: in.GetIncludeImportInfo(),
+	}
+	if err := s.endpoint.parseGrpcRequest(ctx, &request); err != nil {
+		return nil, grpcError(err)
+	}
+
+	buffer, err := s.endpoint.fetchMetadata(ctx, request)
+	s.endpoint.auditGrpc(ctx, request, "", err, int64(len(buffer)))
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return &vdsslicepb.MetadataResponse{Json: string(buffer)}, nil
+}
+
+func (s *GrpcServer) Slice(
+	in *vdsslicepb.SliceRequest,
+	stream vdsslicepb.VdsSlice_SliceServer,
+) error {
+	if err := s.checkEnabled(EndpointSlice); err != nil {
+		return err
+	}
+
+	request := sliceRequestFromProto(in)
+	if err := s.endpoint.parseGrpcRequest(stream.Context(), &request); err != nil {
+		return grpcError(err)
+	}
+
+	return s.streamData(stream, request)
+}
+
+func (s *GrpcServer) Fence(
+	in *vdsslicepb.FenceRequest,
+	stream vdsslicepb.VdsSlice_FenceServer,
+) error {
+	if err := s.checkEnabled(EndpointFence); err != nil {
+		return err
+	}
+
+	request := fenceRequestFromProto(in)
+	if err := s.endpoint.parseGrpcRequest(stream.Context(), &request); err != nil {
+		return grpcError(err)
+	}
+
+	err := validateCoordinates(
+		request.Coordinates,
+		s.endpoint.limits().FenceCoordinates,
+	)
+	if err != nil {
+		return grpcError(err)
+	}
+
+	return s.streamData(stream, request)
+}
+
+func (s *GrpcServer) AttributesAlongSurface(
+	in *vdsslicepb.AttributeAlongSurfaceRequest,
+	stream vdsslicepb.VdsSlice_AttributesAlongSurfaceServer,
+) error {
+	if err := s.checkEnabled(EndpointAttributes); err != nil {
+		return err
+	}
+
+	request := attributeAlongSurfaceRequestFromProto(in)
+	if err := s.endpoint.parseGrpcRequest(stream.Context(), &request); err != nil {
+		return grpcError(err)
+	}
+
+	err := request.validateAttributeCount(s.endpoint.limits().Attributes)
+	if err != nil {
+		return grpcError(err)
+	}
+
+	err = request.normalizeSurface(s.endpoint.limits().SurfacePoints)
+	if err != nil {
+		return grpcError(err)
+	}
+
+	return s.streamData(stream, request)
+}
+
+func (s *GrpcServer) AttributesBetweenSurfaces(
+	in *vdsslicepb.AttributeBetweenSurfacesRequest,
+	stream vdsslicepb.VdsSlice_AttributesBetweenSurfacesServer,
+) error {
+	if err := s.checkEnabled(EndpointAttributes); err != nil {
+		return err
+	}
+
+	request := attributeBetweenSurfacesRequestFromProto(in)
+	if err := s.endpoint.parseGrpcRequest(stream.Context(), &request); err != nil {
+		return grpcError(err)
+	}
+
+	err := request.validateAttributeCount(s.endpoint.limits().Attributes)
+	if err != nil {
+		return grpcError(err)
+	}
+
+	err = validateSurface("primarySurface", &request.PrimarySurface)
+	if err != nil {
+		return grpcError(err)
+	}
+
+	err = validateSurface("secondarySurface", &request.SecondarySurface)
+	if err != nil {
+		return grpcError(err)
+	}
+
+	return s.streamData(stream, request)
+}
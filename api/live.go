@@ -0,0 +1,83 @@
+package api
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// @Description The generation of the config the server runs with
+type ConfigGeneration struct {
+	// Counts the times the config has been loaded, starting at 1 when the
+	// server starts. Bumped by every successful reload.
+	Generation int `json:"generation" example:"3"`
+
+	// When the config was loaded
+	LoadedAt time.Time `json:"loadedAt" example:"2024-05-02T13:37:00Z"`
+} // @name ConfigGeneration
+
+type liveConfig struct {
+	limits     Limits
+	generation ConfigGeneration
+}
+
+/** The parts of the config that can be replaced while the server is running
+ *
+ * Operators tune the limits during incidents, and a restart would drop the
+ * cache and the requests in flight. The limits are swapped as a whole, such
+ * that a request never sees a mix of the old and the new. Every swap is a new
+ * generation of the config, which is reported on /version, such that it can
+ * be verified that a reload has been picked up.
+ *
+ * Safe for concurrent use.
+ */
+type LiveConfig struct {
+	current atomic.Value
+	/* Serializes reloads, such that no generation is lost */
+	lock sync.Mutex
+}
+
+func NewLiveConfig(limits Limits) *LiveConfig {
+	config := &LiveConfig{}
+	config.current.Store(&liveConfig{
+		limits: limits,
+		generation: ConfigGeneration{
+			Generation: 1,
+			LoadedAt:   time.Now().UTC(),
+		},
+	})
+	return config
+}
+
+func (c *LiveConfig) load() *liveConfig {
+	return c.current.Load().(*liveConfig)
+}
+
+func (c *LiveConfig) Limits() Limits {
+	return c.load().limits
+}
+
+func (c *LiveConfig) Generation() ConfigGeneration {
+	return c.load().generation
+}
+
+/* Swap in new limits, as the next generation of the config */
+func (c *LiveConfig) Reload(limits Limits) ConfigGeneration {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	generation := ConfigGeneration{
+		Generation: c.load().generation.Generation + 1,
+		LoadedAt:   time.Now().UTC(),
+	}
+	c.current.Store(&liveConfig{limits: limits, generation: generation})
+	return generation
+}
+
+/* The limits in effect, see Endpoint.Config */
+func (e *Endpoint) limits() Limits {
+	if e.Config != nil {
+		return e.Config.Limits()
+	}
+	return e.Limits
+}
@@ -0,0 +1,210 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/equinor/vds-slice/internal/clientip"
+	"github.com/equinor/vds-slice/internal/core"
+)
+
+/* Max number of VDSs in a metadata list request that are opened at once */
+const metadataListParallelism = 8
+
+// @Description A single VDS in a metadata list request
+type MetadataListItem struct {
+	// The blob URL of the VDS, as for the vds field of MetadataRequest
+	Vds string `json:"vds" binding:"required" example:"https://account.blob.core.windows.net/container/blob"`
+
+	// A valid sas-token with read access to the VDS. Defaults to the sas of
+	// the request, which is shared by all VDSs that have none of their own.
+	Sas string `json:"sas,omitempty" example:"sp=r&st=2022-09-12T09:44:17Z&se=2022-09-12T17:44:17Z&spr=https&sv=2021-06-08&sr=c&sig=..."`
+} //@name MetadataListItem
+
+// Query for metadata of several VDSs
+// @Description Query payload for metadata endpoint /metadata, for several
+// @Description VDSs at once.
+type MetadataListRequest struct {
+	// The VDSs to get metadata for
+	VdsList []MetadataListItem `json:"vdsList" binding:"required,min=1,dive"`
+
+	// A sas-token shared by the VDSs that have none of their own
+	Sas string `json:"sas,omitempty" example:"sp=r&st=2022-09-12T09:44:17Z&se=2022-09-12T17:44:17Z&spr=https&sv=2021-06-08&sr=c&sig=..."`
+
+	// As for MetadataRequest, for every VDS in the list
+	IncludeImportInfo bool `json:"includeImportInfo" example:"false"`
+
+	// As for MetadataRequest, for every VDS in the list
+	IncludeLayout bool `json:"includeLayout" example:"false"`
+
+	// Credentials from the request headers, see RequestedResource
+	bearerToken string
+	headerSas   string
+} //@name MetadataListRequest
+
+func (l *MetadataListRequest) setBearerToken(token string) {
+	l.bearerToken = token
+}
+
+func (l *MetadataListRequest) setHeaderSas(sas string) {
+	l.headerSas = sas
+}
+
+/** Aliases and connections are handled for every VDS in the list on its own
+ *
+ * Such that one invalid VDS doesn't fail the whole list.
+ */
+func (l *MetadataListRequest) resolveAlias(resolver core.VdsResolver) error {
+	return nil
+}
+
+func (l *MetadataListRequest) NormalizeConnection() error {
+	return nil
+}
+
+func (l MetadataListRequest) toString() (string, error) {
+	vds := []string{}
+	for _, item := range l.VdsList {
+		vds = append(vds, item.Vds)
+	}
+	return fmt.Sprintf("{vdsList: %v, includeImportInfo: %t, includeLayout: %t}",
+		vds,
+		l.IncludeImportInfo,
+		l.IncludeLayout,
+	), nil
+}
+
+/** The metadata request for a single VDS in the list
+ *
+ * The sas of the item takes precedence over the shared one, which in turn
+ * takes precedence over the one in the request headers.
+ */
+func (l MetadataListRequest) itemRequest(
+	item MetadataListItem,
+	resolver core.VdsResolver,
+) (MetadataRequest, error) {
+	request := MetadataRequest{
+		RequestedResource: RequestedResource{
+			Vds:         item.Vds,
+			Sas:         item.Sas,
+			bearerToken: l.bearerToken,
+		},
+		IncludeImportInfo: l.IncludeImportInfo,
+		IncludeLayout:     l.IncludeLayout,
+	}
+	if request.Sas == "" {
+		request.Sas = l.Sas
+	}
+	if request.Sas == "" {
+		request.headerSas = l.headerSas
+	}
+
+	err := request.resolveAlias(resolver)
+	if err != nil {
+		return request, err
+	}
+	err = request.NormalizeConnection()
+	return request, err
+}
+
+/* Whether a metadata request body lists several VDSs */
+func isMetadataListRequest(body []byte) bool {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return false
+	}
+	_, ok := fields["vdsList"]
+	return ok
+}
+
+/** The metadata of a VDS in the list, or the error it failed with
+ *
+ * Errors are given as ErrorResponse, just as they would have been for a
+ * request for the VDS on its own.
+ */
+func metadataListEntry(metadata []byte, err error) json.RawMessage {
+	if err == nil {
+		return metadata
+	}
+
+	msg := sanitizeErrorMessage(err.Error())
+	code, details := classifyError(err)
+	/* ErrorResponse only holds strings, so marshaling can not fail */
+	entry, _ := json.Marshal(ErrorResponse{
+		Error:   msg,
+		Code:    code,
+		Details: details,
+	})
+	return entry
+}
+
+/** Fetch the metadata of every VDS in the list
+ *
+ * At most metadataListParallelism VDSs are fetched at once. The metadata of
+ * every VDS is cached on its own, such that later requests for any single
+ * one of them are served from the cache.
+ */
+func (e *Endpoint) fetchMetadataList(
+	ctx *gin.Context,
+	request MetadataListRequest,
+) []json.RawMessage {
+	entries := make([]json.RawMessage, len(request.VdsList))
+	semaphore := make(chan struct{}, metadataListParallelism)
+	client := clientip.Get(ctx)
+	endpoint := ctx.FullPath()
+
+	var wg sync.WaitGroup
+	for i, item := range request.VdsList {
+		wg.Add(1)
+		go func(i int, item MetadataListItem) {
+			defer wg.Done()
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
+
+			itemRequest, err := request.itemRequest(item, e.Resolver)
+			if err != nil {
+				entries[i] = metadataListEntry(nil, err)
+				return
+			}
+
+			metadata, err := e.fetchMetadata(ctx.Request.Context(), itemRequest)
+			entries[i] = metadataListEntry(metadata, err)
+			e.logAudit(
+				client,
+				endpoint,
+				itemRequest,
+				"",
+				auditStatus(err),
+				int64(len(metadata)),
+			)
+		}(i, item)
+	}
+	wg.Wait()
+
+	return entries
+}
+
+func (e *Endpoint) metadataList(ctx *gin.Context, body []byte) {
+	var request MetadataListRequest
+	err := e.parseRequestBody(ctx, body, &request)
+	if abortOnError(ctx, err) {
+		return
+	}
+	prepareRequestLogging(ctx, request)
+
+	limit := e.limits().MetadataList
+	if limit > 0 && len(request.VdsList) > limit {
+		abortOnError(ctx, core.NewInvalidArgument(fmt.Sprintf(
+			"Too many VDSs in vdsList: %d. The limit is %d",
+			len(request.VdsList),
+			limit,
+		)))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, e.fetchMetadataList(ctx, request))
+}
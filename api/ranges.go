@@ -0,0 +1,135 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/equinor/vds-slice/internal/cache"
+	"github.com/equinor/vds-slice/internal/core"
+)
+
+// Query for the ranges endpoint
+// @Description Query payload for the ranges endpoint /metadata/ranges.
+type RangesRequest struct {
+	RequestedResource
+} //@name RangesRequest
+
+func (r RangesRequest) toString() (string, error) {
+	return MetadataRequest{RequestedResource: r.RequestedResource}.toString()
+}
+
+// @Description The valid vertical window of the attribute endpoints
+type VerticalWindowRange struct {
+	// Smallest valid above and below
+	Min float64 `json:"min" example:"0"`
+
+	// Above and below must be less than max
+	Max float64 `json:"max" example:"250"`
+
+	// The stepsize that is used if none is given, i.e. the vertical stepsize
+	// of the cube. Smaller stepsizes resample the traces.
+	DefaultStepsize float64 `json:"defaultStepsize" example:"4"`
+
+	// The unit of min, max and defaultStepsize, i.e. the vertical unit of the
+	// cube. Requests that give a verticalUnit are converted to it before the
+	// window is validated.
+	Unit string `json:"unit" example:"ms"`
+} // @name VerticalWindowRange
+
+// @Description The valid values of the request parameters of a cube
+type Ranges struct {
+	// The valid linenos of every direction the cube can be sliced by. The
+	// directions that name the axes of the cube by position or annotation
+	// are aliases of these, and are not listed.
+	Directions []core.DirectionRange `json:"directions"`
+
+	// The valid above, below and stepsize of the attribute endpoints
+	VerticalWindow VerticalWindowRange `json:"verticalWindow"`
+} // @name Ranges
+
+/** The ranges of a cube, from its metadata
+ *
+ * The ranges are derived from the same metadata as the metadata endpoint
+ * serves, and thus cached, and served stale, with it.
+ */
+func (e *Endpoint) fetchRanges(
+	ctx context.Context,
+	request RangesRequest,
+) (buffer []byte, stale bool, err error) {
+	metadata, stale, err := e.fetchMetadataOrStale(
+		ctx,
+		MetadataRequest{RequestedResource: request.RequestedResource},
+	)
+	if err != nil {
+		return nil, false, err
+	}
+
+	directions, err := core.GetRanges(metadata)
+	if err != nil {
+		return nil, false, err
+	}
+
+	var meta core.Metadata
+	if err := json.Unmarshal(metadata, &meta); err != nil {
+		return nil, false, core.NewInternalError(err.Error())
+	}
+	vertical := meta.Axis[len(meta.Axis)-1]
+
+	buffer, err = json.Marshal(Ranges{
+		Directions: directions,
+		VerticalWindow: VerticalWindowRange{
+			Min:             verticalWindowLowerBound,
+			Max:             verticalWindowUpperBound,
+			DefaultStepsize: vertical.StepSize,
+			Unit:            vertical.Unit,
+		},
+	})
+	if err != nil {
+		return nil, false, core.NewInternalError(err.Error())
+	}
+	return buffer, stale, nil
+}
+
+// RangesGet godoc
+// @Summary  Return the valid values of the request parameters of a cube
+// @description.markdown ranges
+// @Tags     metadata
+// @Param    query  query  string  True  "Urlencoded/escaped RangesRequest"
+// @Produce  json
+// @Success  200 {object} Ranges
+// @Failure  400 {object} ErrorResponse "Request is invalid"
+// @Failure  401 {object} ErrorResponse "Credentials are missing, invalid or expired"
+// @Failure  403 {object} ErrorResponse "Credentials do not grant read access to the VDS"
+// @Failure  404 {object} ErrorResponse "VDS not found"
+// @Failure  500 {object} ErrorResponse "openvds failed to process the request"
+// @Failure  503 {object} ErrorResponse "Storage account is unavailable, see Retry-After"
+// @Failure  504 {object} ErrorResponse "The deadline of the request passed, see X-Request-Deadline"
+// @Router   /metadata/ranges  [get]
+func (e *Endpoint) RangesGet(ctx *gin.Context) {
+	var request RangesRequest
+	err := e.parseGetRequest(ctx, &request)
+	if abortOnError(ctx, err) {
+		return
+	}
+	prepareRequestLogging(ctx, request)
+	defer e.auditResource(ctx, request.RequestedResource)
+
+	buffer, stale, err := e.fetchRanges(ctx.Request.Context(), request)
+	if abortOnError(ctx, err) {
+		return
+	}
+	if stale {
+		setStaleHeaders(ctx)
+	}
+
+	etag, err := cache.Hash(buffer)
+	if abortOnError(ctx, err) {
+		return
+	}
+
+	ctx.Header("ETag", weakETag(etag))
+	ctx.Data(http.StatusOK, "application/json", buffer)
+}
@@ -1,9 +1,15 @@
 package api
 
 import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"math"
 	"net/url"
+	"strconv"
 	"strings"
 
 	"github.com/equinor/vds-slice/internal/cache"
@@ -25,57 +31,346 @@ type RequestedResource struct {
 	// only and give no guarantee that Openvds/Azure returns you if you provide
 	// any additional arguments.
 	//
+	// If the server is set up with an alias resolver, the VDS can also be
+	// given by its alias, e.g. a survey name, which is anything without a
+	// scheme or a path separator. The server resolves the alias to a URL and,
+	// for requests without credentials, possibly a sas of its own.
+	//
 	// Warning: We do not expect storage accounts to have snapshots. If your
 	// storage account has any, please contact System Admin, as due to caching
 	// you might end up with incorrect data.
 	Vds string `json:"vds" binding:"required" example:"https://account.blob.core.windows.net/container/blob"`
 
 	// A valid sas-token with read access to the container specified in Vds
+	//
+	// Instead of a sas-token, an Azure AD access token with read access to
+	// the blob can be passed in the Authorization header as
+	// 'Authorization: Bearer <token>'. Exactly one of the two must be given.
+	//
+	// The sas-token can also be left out of the request altogether and be
+	// passed in the X-SAS-Token header, or as
+	// 'Authorization: SharedAccessSignature <sas>'. This keeps the token out of
+	// the query string of GET requests, which proxies and browsers tend to
+	// record.
 	Sas string `json:"sas,omitempty" example:"sp=r&st=2022-09-12T09:44:17Z&se=2022-09-12T17:44:17Z&spr=https&sv=2021-06-08&sr=c&sig=..."`
+
+	// Options for VDSs in AWS S3 (s3://<bucket>/<key>). Can be omitted
+	// altogether if the server is set up with credentials of its own.
+	//
+	// VDSs in Google Cloud Storage (gs://<bucket>/<key>) are always read with
+	// the server's own credentials, and takes neither sas nor s3.
+	S3 *S3Options `json:"s3,omitempty"`
+
+	// Bearer token from the Authorization header. Unexported such that it's
+	// never serialized, logged or included in the request hash.
+	bearerToken string
+
+	// Sas token from the request headers. Unexported for the same reason as
+	// bearerToken.
+	headerSas string
 }
 
-func (r RequestedResource) credentials() (string, string) {
-	return r.Vds, r.Sas
+type S3Options struct {
+	// AWS region of the bucket. Defaults to the region the server is
+	// configured with.
+	Region string `json:"region,omitempty" example:"eu-north-1"`
+
+	// Access key id. Must be given together with secretKey.
+	AccessKeyId string `json:"accessKeyId,omitempty"`
+
+	// Secret access key. Must be given together with accessKeyId.
+	SecretKey string `json:"secretKey,omitempty"`
+
+	// Session token, for temporary credentials.
+	SessionToken string `json:"sessionToken,omitempty"`
+
+	// Who the VDS is read as, see withIdentity. Never given by the client.
+	Identity string `json:"-" swaggerignore:"true"`
+} //@name S3Options
+
+/*
+ * Copy of the options with only the non-secret fields, such that they can be
+ * safely logged.
+ */
+func (o *S3Options) withoutSecrets() *S3Options {
+	if o == nil {
+		return nil
+	}
+	return &S3Options{Region: o.Region}
+}
+
+/** Like withoutSecrets, but with the identity the VDS is read as
+ *
+ * Used in the request hash, such that responses read with the credentials of
+ * one client are not shared with clients that give other credentials, or
+ * none. The identity is a digest of the access key id, or ambient for the
+ * server's own credentials, and never the key itself.
+ */
+func (o *S3Options) withIdentity() *S3Options {
+	if o == nil {
+		return nil
+	}
+	identity := "ambient"
+	if o.AccessKeyId != "" {
+		digest := sha256.Sum256([]byte(o.AccessKeyId))
+		identity = "key:" + hex.EncodeToString(digest[:])
+	}
+	return &S3Options{Region: o.Region, Identity: identity}
+}
+
+func (r RequestedResource) credentials() (string, core.Credentials) {
+	credentials := core.Credentials{Sas: r.Sas, BearerToken: r.bearerToken}
+	if r.S3 != nil {
+		credentials.S3 = core.S3Options{
+			Region:       r.S3.Region,
+			AccessKeyId:  r.S3.AccessKeyId,
+			SecretKey:    r.S3.SecretKey,
+			SessionToken: r.S3.SessionToken,
+		}
+	}
+	return r.Vds, credentials
+}
+
+/*
+ * Backends where the server may use its own credentials, such that the
+ * request need not carry any.
+ */
+func hasAmbientCredentials(scheme string) bool {
+	return strings.EqualFold(scheme, "s3") || strings.EqualFold(scheme, "gs")
 }
 
 type DataRequest interface {
 	toString() (string, error)
 	hash() (string, error)
-	credentials() (string, string)
+	credentials() (string, core.Credentials)
 	execute(handle core.DSHandle) (data [][]byte, metadata []byte, err error)
+	/* Report the size of the request, given the metadata of its response */
+	observe(observer RequestObserver, metadata []byte)
+	/*
+	 * An upper bound of the size of the response data, which is reserved
+	 * against the memory budget before execute
+	 */
+	estimateSize(handle core.DSHandle) (int64, error)
+}
+
+/** Data requests that can leave out the metadata part of the response
+ *
+ * For clients that already know the metadata, and only want the data. The
+ * data is cached and hashed the same either way, only the framing of the
+ * response differs.
+ */
+type dataOnlyRequest interface {
+	DataRequest
+	omitsMetadata() bool
+	/*
+	 * The names of the data parts, given the metadata of the response. Nil
+	 * for requests with a single data part, which is then the whole body.
+	 */
+	partNames(metadata []byte) ([]string, error)
+}
+
+/** Observes the size of data requests in domain terms
+ *
+ * Such that latency can be correlated with how much work a request is, and
+ * limits can be set from what clients actually ask for. The endpoint is the
+ * route of the request type, e.g. fence or attributes/surface/along.
+ */
+type RequestObserver interface {
+	/* The number of coordinates of a fence */
+	FenceCoordinates(endpoint string, count int)
+
+	/* The number of points of the surface attributes are calculated on */
+	SurfacePoints(endpoint string, count int)
+
+	/* The number of scattered points the cube is sampled at */
+	SamplePoints(endpoint string, count int)
+
+	/* The number of samples in a slice, after bounds are applied */
+	SliceSamples(endpoint string, count int)
+
+	/* The length of an attribute window, in the vertical unit of the request */
+	AttributeWindow(endpoint string, length float64)
+
+	/* The number of batches a batched fence was processed in */
+	FenceBatches(endpoint string, count int)
 }
 
 type Stringable interface {
 	toString() (string, error)
 }
 type Normalizable interface {
+	setBearerToken(token string)
+	setHeaderSas(sas string)
+	resolveAlias(resolver core.VdsResolver) error
 	NormalizeConnection() error
 }
 
+func (r *RequestedResource) setBearerToken(token string) {
+	r.bearerToken = token
+}
+
+func (r *RequestedResource) setHeaderSas(sas string) {
+	r.headerSas = sas
+}
+
+/** Requests with options the deployment has defaults for
+ *
+ * The interpolation method and fill value of the request take precedence
+ * over the defaults of the server, see core.Defaults. They are resolved
+ * before the request is hashed, so that requests answered alike share their
+ * cache entry, and the echo gives the options the request was answered with.
+ */
+type defaultsRequest interface {
+	resolveDefaults(defaults core.Defaults)
+}
+
+func resolveCoreFillValue(fillValue *core.FillValue, defaults core.Defaults) *core.FillValue {
+	resolved := defaults.ResolveFillValue((*float32)(fillValue))
+	return (*core.FillValue)(resolved)
+}
+
+func (r *SliceRequest) resolveDefaults(defaults core.Defaults) {
+	r.FillValue = resolveCoreFillValue(r.FillValue, defaults)
+}
+
+func (r *FenceRequest) resolveDefaults(defaults core.Defaults) {
+	r.Interpolation = defaults.ResolveInterpolation(r.Interpolation)
+	r.FillValue = defaults.ResolveFillValue(r.FillValue)
+}
+
+func (r *SampleRequest) resolveDefaults(defaults core.Defaults) {
+	r.Interpolation = defaults.ResolveInterpolation(r.Interpolation)
+	r.FillValue = defaults.ResolveFillValue(r.FillValue)
+}
+
+func (r *TraverseRequest) resolveDefaults(defaults core.Defaults) {
+	r.FillValue = resolveCoreFillValue(r.FillValue, defaults)
+}
+
+/* Attributes take their fill value from the surface, which always gives one */
+func (r *AttributeRequest) resolveDefaults(defaults core.Defaults) {
+	r.Interpolation = defaults.ResolveInterpolation(r.Interpolation)
+}
+
+/** Replace an alias in vds with the url it resolves to
+ *
+ * This happens before the connection is normalized, such that the request is
+ * hashed by the resolved url, and shares cache entries with requests that
+ * give the url directly. The credential of the resolution is only used if the
+ * request carries none of its own.
+ */
+func (r *RequestedResource) resolveAlias(resolver core.VdsResolver) error {
+	if resolver == nil || !core.IsVdsAlias(r.Vds) {
+		return nil
+	}
+
+	alias := strings.TrimSpace(r.Vds)
+	resolution, err := resolver.Resolve(alias)
+	if err != nil {
+		return core.NewCodedInvalidArgument(
+			"unresolved_vds_alias",
+			core.ErrorDetails{"alias": alias},
+			fmt.Sprintf("Unable to resolve vds alias '%s': %v", alias, err),
+		)
+	}
+
+	hasCredentials := strings.TrimSpace(r.Sas) != "" ||
+		strings.TrimSpace(r.headerSas) != "" ||
+		strings.TrimSpace(r.bearerToken) != "" ||
+		r.S3 != nil
+	r.Vds = resolution.Url
+	if !hasCredentials {
+		r.Sas = resolution.Sas
+	}
+	return nil
+}
+
 func (r *RequestedResource) NormalizeConnection() error {
 	url, err := url.Parse(r.Vds)
 	if err != nil {
 		return core.NewInvalidArgument(err.Error())
 	}
-	if strings.TrimSpace(r.Sas) == "" {
-		if url.RawQuery == "" {
-			return core.NewInvalidArgument("No valid Sas token is found in the request")
-		}
+	if strings.TrimSpace(r.Sas) == "" && url.RawQuery != "" {
 		r.Sas = url.RawQuery
 	}
 
-	url.RawQuery = ""
-	url.Host = url.Hostname()
-	r.Vds = url.String()
+	if strings.TrimSpace(r.headerSas) != "" {
+		if strings.TrimSpace(r.Sas) != "" {
+			return core.NewCodedInvalidArgument(
+				"conflicting_credentials",
+				nil,
+				"A Sas token is found in both the request and the request "+
+					"headers, only one of them is allowed",
+			)
+		}
+		r.Sas = r.headerSas
+	}
+
+	hasSas := strings.TrimSpace(r.Sas) != ""
+	hasToken := strings.TrimSpace(r.bearerToken) != ""
+	if hasSas && hasToken {
+		return core.NewCodedInvalidArgument(
+			"conflicting_credentials",
+			nil,
+			"Both a Sas token and a bearer token is found in the request, "+
+				"only one of them is allowed",
+		)
+	}
+	if !hasSas && !hasToken && !hasAmbientCredentials(url.Scheme) {
+		return core.NewCodedInvalidArgument(
+			"missing_credentials",
+			nil,
+			"No valid Sas token is found in the request or the request "+
+				"headers, nor a bearer token in the Authorization header",
+		)
+	}
+
+	r.Vds = normalizeUrl(url)
 	return nil
 }
 
+/** The url of the VDS, stripped of anything that doesn't change what it names
+ *
+ * The query (sas token) and port are dropped. Scheme and host are
+ * case-insensitive and may be written as a fully qualified name, i.e. with a
+ * trailing dot, while trailing slashes are ignored by the connection. The
+ * percent-encoding of the path is normalized, see normalizeEscapes. None of
+ * these should fragment the cache.
+ */
+func normalizeUrl(url *url.URL) string {
+	url.RawQuery = ""
+	url.Scheme = strings.ToLower(url.Scheme)
+	url.Host = strings.TrimSuffix(strings.ToLower(url.Hostname()), ".")
+	url.Path = strings.TrimRight(url.Path, "/")
+	url.RawPath = normalizeEscapes(strings.TrimRight(url.RawPath, "/"))
+	return url.String()
+}
+
 type MetadataRequest struct {
 	RequestedResource
+
+	// Include the textual header of the SEG-Y the VDS was imported from.
+	// Defaults to false, as the header is 3200 characters.
+	IncludeImportInfo bool `json:"includeImportInfo" example:"false"`
+
+	// Include how the VDS is laid out in storage, i.e. the brick size, the
+	// levels of detail, the compression and the channels. Defaults to false.
+	IncludeLayout bool `json:"includeLayout" example:"false"`
 } //@name MetadataRequest
 
+/** Compute a hash of the request that uniquely identifies the metadata
+ *
+ * The hash is computed from the canonical form of the request, i.e. from all
+ * fields that contribute toward a unique response, spelled one way only. See
+ * canonical.go.
+ */
+func (m MetadataRequest) hash() (string, error) {
+	return cache.Hash(m.canonical())
+}
+
 func (m MetadataRequest) toString() (string, error) {
 	m.Sas = ""
+	m.S3 = m.S3.withoutSecrets()
 	out, err := json.Marshal(m)
 	if err != nil {
 		return "", err
@@ -98,23 +393,189 @@ type FenceRequest struct {
 
 	// A list of (x, y) points in the coordinate system specified in
 	// coordinateSystem, for example [[2000.5, 100.5], [2050, 200], [10, 20]].
-	Coordinates [][]float32 `json:"coordinates" binding:"required"`
+	// Either this or coordinatesFlat must be given.
+	Coordinates [][]float32 `json:"coordinates" binding:"required_without=CoordinatesFlat"`
+
+	// The same points as coordinates, flattened to x0, y0, x1, y1, ... Given
+	// either as an array of numbers, or as a base64 string of the values
+	// packed as little endian float32. The latter is far more compact for
+	// long fences. Mutually exclusive with coordinates.
+	CoordinatesFlat *FlatCoordinates `json:"coordinatesFlat,omitempty" swaggertype:"string" example:"AAD6RAAAyEI="`
 
 	// Interpolation method
-	// Supported options are: nearest, linear, cubic, angular and triangular.
-	// Defaults to nearest.
+	// Supported options are: nearest, linear, cubic, angular, triangular,
+	// nearest_trace and none. Defaults to nearest.
 	// This field is passed on to OpenVDS, which does the actual interpolation.
 	// Note: For nearest interpolation result will snap to the nearest point
 	// as per "half up" rounding. This is different from openvds logic.
+	//
+	// nearest_trace snaps every coordinate to the nearest trace, also with
+	// "half up" rounding, and returns that trace exactly as stored in the
+	// VDS, i.e. identical to the trace in a slice. The indices of the traces
+	// are returned in the metadata.
+	//
+	// none requires every coordinate to be at a trace, and fails the request
+	// otherwise, naming the first coordinate that is not and its nearest
+	// trace. The traces are returned as stored in the VDS.
 	Interpolation string `json:"interpolation" example:"linear"`
 
 	// Providing a FillValue is optional and will be used for the sample points
-	// that lie outside the seismic cube.
+	// that lie outside the seismic cube, and for absent data such as dead
+	// traces. The fill value is reported in the metadata.
 	// Note: In case the FillValue is not set, and any of the provided coordinates
 	// fall outside the seismic cube, the request will be rejected with an error.
+	// Defaults to the fill value of the server, if it has one.
 	FillValue *float32 `json:"fillValue"`
+
+	// Resample every trace to this sample interval, in verticalUnit. The new
+	// samples start at the first sample of the cube. The interval must be at
+	// least 1/8 of the sample interval of the cube.
+	//
+	// The traces are resampled with the interpolation method of the fence,
+	// which must be nearest, linear, cubic or nearest_trace, where the
+	// latter resamples as nearest. Optional, the traces are returned as
+	// sampled in the cube if not given.
+	ResampleTo *float32 `json:"resampleTo,omitempty" example:"1"`
+
+	// Unit of the vertical axis. Supported options are: ms, s, m and ft.
+	// Only resampleTo is given in this unit, and the unit is otherwise only
+	// checked to be convertible from the unit of the VDS. Defaults to the
+	// unit of the VDS.
+	VerticalUnit string `json:"verticalUnit" example:"ms"`
+
+	// Leave out the metadata part, for clients that already know it. The
+	// traces are then the whole response body, as application/octet-stream
+	// rather than multipart. Defaults to false.
+	OmitMetadata bool `json:"omitMetadata" example:"false"`
+
+	// Process the fence in batches of traces, which are streamed into the
+	// data part as they complete. This bounds the memory of very long
+	// fences, at the cost of the data part having no checksum, and of
+	// failures mid-way being reported in a trailing error part rather than
+	// by the status. Fences no longer than a batch are unaffected. Not
+	// supported together with omitMetadata. Defaults to false.
+	Batched bool `json:"batched" example:"false"`
+
+	// Trace header fields to return alongside the traces, e.g. CDP or
+	// Offset, from the header channels of the VDS as listed by /metadata.
+	// Fields are matched by name, ignoring case. The headers of the trace
+	// nearest to every coordinate are returned in a second data part, with
+	// one row per coordinate and one column per field. Coordinates outside
+	// of the cube get the fill value. A field the VDS does not have fails
+	// the request. Not supported together with batched. Optional.
+	HeaderFields []string `json:"headerFields,omitempty" example:"CDP,Offset"`
+
+	// Include the request, as resolved by the server, in the metadata part
+	// as request. Defaults are filled in and directions that refer to the
+	// axes of the cube are resolved, such that it tells what the server
+	// actually did. Credentials are never included. Defaults to false.
+	Echo bool `json:"echo" example:"false"`
 } //@name FenceRequest
 
+/** Fence coordinates as a flat list of x, y values
+ *
+ * Unmarshals from either a json array of numbers or a base64 string of
+ * packed little endian float32, and always marshals to the former.
+ */
+type FlatCoordinates struct {
+	values []float32
+}
+
+func (f *FlatCoordinates) UnmarshalJSON(data []byte) error {
+	var values []float32
+	if err := json.Unmarshal(data, &values); err == nil {
+		f.values = values
+		return nil
+	}
+
+	var encoded string
+	if err := json.Unmarshal(data, &encoded); err != nil {
+		return core.NewCodedInvalidArgument(
+			"type_mismatch",
+			core.ErrorDetails{"field": "coordinatesFlat"},
+			"field 'coordinatesFlat' must be an array of numbers or a base64 "+
+				"string",
+		)
+	}
+
+	packed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return core.NewInvalidArgument(fmt.Sprintf(
+			"field 'coordinatesFlat' is not valid base64: %v",
+			err,
+		))
+	}
+	if len(packed)%4 != 0 {
+		return core.NewInvalidArgument(fmt.Sprintf(
+			"field 'coordinatesFlat' must hold whole float32 values, got %d bytes",
+			len(packed),
+		))
+	}
+
+	f.values = unpackFloats(packed)
+	return nil
+}
+
+func (f FlatCoordinates) MarshalJSON() ([]byte, error) {
+	return json.Marshal(f.values)
+}
+
+/** Encode the values as packed little endian float32
+ *
+ * Requests are hashed with gob, which refuses types without exported fields
+ * even when they are nil.
+ */
+func (f FlatCoordinates) GobEncode() ([]byte, error) {
+	packed := make([]byte, len(f.values)*4)
+	for i, value := range f.values {
+		binary.LittleEndian.PutUint32(packed[i*4:], math.Float32bits(value))
+	}
+	return packed, nil
+}
+
+func (f *FlatCoordinates) GobDecode(packed []byte) error {
+	if len(packed)%4 != 0 {
+		return fmt.Errorf("invalid packed coordinates of %d bytes", len(packed))
+	}
+	f.values = unpackFloats(packed)
+	return nil
+}
+
+/* Little endian float32 values, of a buffer of whole values */
+func unpackFloats(packed []byte) []float32 {
+	values := make([]float32, len(packed)/4)
+	for i := range values {
+		bits := binary.LittleEndian.Uint32(packed[i*4:])
+		values[i] = math.Float32frombits(bits)
+	}
+	return values
+}
+
+/** The values as [x, y] pairs
+ *
+ * Positions in errors are that of the pair, as for nested coordinates.
+ */
+func (f FlatCoordinates) pairs() ([][]float32, error) {
+	if len(f.values)%2 != 0 {
+		last := len(f.values) - 1
+		return nil, core.NewCodedInvalidArgument(
+			"invalid_coordinate",
+			core.ErrorDetails{"position": strconv.Itoa(last / 2)},
+			fmt.Sprintf(
+				"invalid coordinate %v at position %d, expected [x y] pair",
+				f.values[last:],
+				last/2,
+			),
+		)
+	}
+
+	coordinates := make([][]float32, len(f.values)/2)
+	for i := range coordinates {
+		coordinates[i] = f.values[i*2 : i*2+2]
+	}
+	return coordinates, nil
+}
+
 func (f FenceRequest) toString() (string, error) {
 	coordinates := func() string {
 		var length = len(f.Coordinates)
@@ -130,23 +591,110 @@ func (f FenceRequest) toString() (string, error) {
 		}
 	}()
 
-	return fmt.Sprintf("{vds: %s, coordinate system: %s, coordinates: %s, interpolation (optional): %s}",
+	resampleTo := "None"
+	if f.ResampleTo != nil {
+		resampleTo = fmt.Sprintf("%v", *f.ResampleTo)
+	}
+
+	return fmt.Sprintf("{vds: %s, coordinate system: %s, coordinates: %s, "+
+		"interpolation (optional): %s, resample to (optional): %s}",
 		f.Vds,
 		f.CoordinateSystem,
 		coordinates,
 		f.Interpolation,
+		resampleTo,
 	), nil
 }
 
 /** Compute a hash of the request that uniquely identifies the requested fence
  *
- * The hash is computed based on all fields that contribute toward a unique response.
- * I.e. every field except the sas token.
+ * The hash is computed from the canonical form of the request, i.e. from all
+ * fields that contribute toward a unique response, spelled one way only. See
+ * canonical.go.
  */
 func (f FenceRequest) hash() (string, error) {
-	// Strip the sas token before computing hash
-	f.Sas = ""
-	return cache.Hash(f)
+	return cache.Hash(f.canonical())
+}
+
+// Query for sample endpoint
+// @Description Query payload for sample endpoint /sample.
+type SampleRequest struct {
+	RequestedResource
+	// Coordinate system of the x and y of the points
+	// Supported options are:
+	// ilxl : inline, crossline pairs
+	// ij   : Coordinates are given as in 0-indexed system, where the first
+	//        line in each direction is 0 and the last is number-of-lines - 1.
+	// cdp  : Coordinates are given as cdpx/cdpy pairs. In the original SEGY
+	//        this would correspond to the cdpx and cdpy fields in the
+	//        trace-headers after applying the scaling factor.
+	CoordinateSystem string `json:"coordinateSystem" binding:"required" example:"cdp"`
+
+	// A list of (x, y, z) points, where x and y are in the coordinate system
+	// specified in coordinateSystem, and z is in verticalUnit, for example
+	// [[2000.5, 100.5, 1200], [2050, 200, 1204.5]].
+	Coordinates [][]float32 `json:"coordinates" binding:"required"`
+
+	// Interpolation method
+	// Supported options are: nearest, linear, cubic, angular and triangular.
+	// Defaults to nearest.
+	// This field is passed on to OpenVDS, which does the actual interpolation.
+	Interpolation string `json:"interpolation" example:"linear"`
+
+	// The value given for points that are outside of the seismic cube, or
+	// where the data is absent. Such points are also flagged as invalid.
+	// Defaults to the fill value of the server, or NaN if it has none.
+	FillValue *float32 `json:"fillValue"`
+
+	// Unit of z. Supported options are: ms, s, m and ft. Defaults to the
+	// unit of the VDS.
+	VerticalUnit string `json:"verticalUnit" example:"ms"`
+
+	// Leave out the metadata part, for clients that already know it. The
+	// response is still multipart, with the values and the validity flags
+	// as parts named values and valid. Defaults to false.
+	OmitMetadata bool `json:"omitMetadata" example:"false"`
+
+	// Include the request, as resolved by the server, in the metadata part
+	// as request. Defaults are filled in and directions that refer to the
+	// axes of the cube are resolved, such that it tells what the server
+	// actually did. Credentials are never included. Defaults to false.
+	Echo bool `json:"echo" example:"false"`
+} //@name SampleRequest
+
+func (s SampleRequest) toString() (string, error) {
+	coordinates := func() string {
+		var length = len(s.Coordinates)
+		const halfPrintLength = 5
+		const printLength = halfPrintLength * 2
+		if length > printLength {
+			return fmt.Sprintf("%v, ...[%d element(s) skipped]..., %v",
+				s.Coordinates[0:halfPrintLength],
+				length-printLength,
+				s.Coordinates[length-halfPrintLength:length])
+		} else {
+			return fmt.Sprintf("%v", s.Coordinates)
+		}
+	}()
+
+	return fmt.Sprintf("{vds: %s, coordinate system: %s, coordinates: %s, "+
+		"interpolation (optional): %s, vertical unit (optional): %s}",
+		s.Vds,
+		s.CoordinateSystem,
+		coordinates,
+		s.Interpolation,
+		s.VerticalUnit,
+	), nil
+}
+
+/** Compute a hash of the request that uniquely identifies the requested points
+ *
+ * The hash is computed from the canonical form of the request, i.e. from all
+ * fields that contribute toward a unique response, spelled one way only. See
+ * canonical.go.
+ */
+func (s SampleRequest) hash() (string, error) {
+	return cache.Hash(s.canonical())
 }
 
 // Query for slice endpoints
@@ -167,12 +715,26 @@ type SliceRequest struct {
 	// i, j, k are zero-indexed and correspond to Inline, Crossline,
 	// Depth/Time/Sample, respectively.
 	//
+	// The axes of the cube can also be referred to as /metadata lists them,
+	// either by position in the axis list, e.g. 0 for the first axis, which
+	// is read as i, j or k, or by annotation, e.g. Inline, which is read as
+	// inline, crossline or sample. The options above take precedence over
+	// the annotations of the cube.
+	//
 	// All options are case-insensitive.
 	Direction string `json:"direction" binding:"required" example:"inline"`
 
 	// Line number of the slice
 	Lineno *int `json:"lineno" binding:"required" example:"10000"`
 
+	// How lineno is interpreted. Valid options: index and annotation.
+	//
+	// By default lineno is an index for i, j and k, and an annotation for
+	// the other directions. LinenoMode overrides that, e.g. such that an
+	// inline can be requested by its index. The vertical unit only applies
+	// to linenos given as annotation.
+	LinenoMode string `json:"linenoMode" example:"annotation"`
+
 	// Restrict the slice in the other dimensions (sub-slicing)
 	//
 	// Bounds can be used to retrieve sub-slices. For example: when requesting
@@ -193,21 +755,58 @@ type SliceRequest struct {
 	// Bounds can be set using both annotation and index. You are free to mix
 	// and match as you see fit.
 	Bounds []core.Bound `json:"bounds" binding:"dive"`
+
+	// Providing a FillValue is optional. If given, samples of absent data,
+	// such as dead traces, are replaced by it, rather than left as stored in
+	// the VDS. NaN is given as the string "nan". Defaults to the fill value
+	// of the server, if it has one.
+	FillValue *core.FillValue `json:"fillValue" swaggertype:"number" example:"-999.25"`
+
+	// Unit of the vertical axis, as used in the request and the response
+	// Supported options are: ms, s, m and ft. Defaults to the unit of the
+	// VDS.
+	//
+	// Linenos and bounds along depth/time/sample are given in this unit, as
+	// are the min, max and stepsize of the vertical axis in the metadata.
+	// As linenos and bounds are integers, they must convert to a whole
+	// number in the unit of the VDS. Time can not be converted to depth, or
+	// the other way around.
+	VerticalUnit string `json:"verticalUnit" example:"ms"`
+
+	// Leave out the metadata part, for clients that already know it, such as
+	// clients that poll the same slice. The slice is then the whole response
+	// body, as application/octet-stream rather than multipart. Not supported
+	// by /slice/progressive. Defaults to false.
+	OmitMetadata bool `json:"omitMetadata" example:"false"`
+
+	// Return the samples as stored in the VDS, rather than as float32. Cubes
+	// stored as integers keep their original quantization, and the format of
+	// the metadata tells the format of the samples, e.g. <u1. The
+	// storedFormat of the VDS metadata tells how they map to the values they
+	// represent. fillValue does not apply, as absent data can not be told
+	// apart in the stored format. Defaults to false.
+	Raw bool `json:"raw" example:"false"`
+
+	// Include the request, as resolved by the server, in the metadata part
+	// as request. Defaults are filled in and directions that refer to the
+	// axes of the cube are resolved, such that it tells what the server
+	// actually did. Credentials are never included. Defaults to false.
+	Echo bool `json:"echo" example:"false"`
 } //@name SliceRequest
 
 /** Compute a hash of the request that uniquely identifies the requested slice
  *
- * The hash is computed based on all fields that contribute toward a unique response.
- * I.e. every field except the sas token.
+ * The hash is computed from the canonical form of the request, i.e. from all
+ * fields that contribute toward a unique response, spelled one way only. See
+ * canonical.go.
  */
 func (s SliceRequest) hash() (string, error) {
-	// Strip the sas token before computing hash
-	s.Sas = ""
-	return cache.Hash(s)
+	return cache.Hash(s.canonical())
 }
 
 func (s SliceRequest) toString() (string, error) {
 	s.Sas = ""
+	s.S3 = s.S3.withoutSecrets()
 	out, err := json.Marshal(s)
 	if err != nil {
 		return "", err
@@ -226,17 +825,25 @@ type AttributeRequest struct {
 	// Defaults to nearest.
 	// This field is passed on to OpenVDS, which does the actual interpolation.
 	//
-	// This only applies to the horizontal plane. Traces are always
-	// interpolated with cubic interpolation (algorithm: modified makima)
+	// This only applies to the horizontal plane. Re-sampling of the traces
+	// is controlled by 'verticalInterpolation'.
 	// Note: For nearest interpolation result will snap to the nearest point
 	// as per "half up" rounding. This is different from openvds logic.
 	Interpolation string `json:"interpolation" example:"linear"`
 
+	// Vertical interpolation method
+	// Supported options are: nearest, linear and cubic. Defaults to cubic
+	// (algorithm: modified makima).
+	//
+	// This only applies to the re-sampling of the traces to 'stepsize' within
+	// the vertical window. The traces themselves are looked up according to
+	// 'interpolation'.
+	VerticalInterpolation string `json:"verticalInterpolation" example:"cubic"`
+
 	// Stepsize for samples within the window defined by above below
 	//
 	// Samples within the vertical window will be re-sampled to 'stepsize'
-	// using cubic interpolation (modified makima) before the attributes are
-	// calculated.
+	// using 'verticalInterpolation' before the attributes are calculated.
 	//
 	// This value should be given in the vertical domain of the traces. E.g.
 	// 0.1 implies re-sample samples at an interval of 0.1 meter (if it's a
@@ -251,15 +858,176 @@ type AttributeRequest struct {
 	// request. This is considerably faster than doing one request per
 	// attribute.
 	Attributes []string `json:"attributes" binding:"required" swaggertype:"array,string" example:"min,max"`
+
+	// Compute the valid attributes, rather than failing the request if any
+	// attribute is invalid. The metadata lists the status of every requested
+	// attribute, and there is a data part for every attribute with status
+	// "ok", in the order they were requested. The request still fails if
+	// none of the attributes are valid.
+	//
+	// Defaults to false
+	Partial bool `json:"partial" example:"false"`
+
+	// Absent data in the cube, e.g. dead traces, is left out of the
+	// attributes. A node where the fraction of the vertical window that
+	// holds data is below minValidFraction gets the fill value of the
+	// surface, as does a node with no data in its window at all. Must be
+	// between 0 and 1, where 1 requires data in the whole window.
+	//
+	// Defaults to 0
+	MinValidFraction float32 `json:"minValidFraction,omitempty" example:"0.5"`
+
+	// Unit of the vertical parameters of the request
+	// Supported options are: ms, s, m and ft. Defaults to the unit of the
+	// VDS.
+	//
+	// The surface values, above, below and stepsize are all given in this
+	// unit. Attributes that are positions, such as min_at, are still
+	// returned in the unit of the VDS. Time can not be converted to depth,
+	// or the other way around.
+	VerticalUnit string `json:"verticalUnit" example:"ms"`
+
+	// Leave out the metadata part, for clients that already know it. The
+	// response is still multipart, and every part is named by its attribute
+	// in its Content-Disposition header. For partial requests the statuses
+	// of the attributes are lost with the metadata. Defaults to false.
+	OmitMetadata bool `json:"omitMetadata" example:"false"`
+
+	// Include the request, as resolved by the server, in the metadata part
+	// as request. Defaults are filled in and directions that refer to the
+	// axes of the cube are resolved, such that it tells what the server
+	// actually did. Credentials are never included. Defaults to false.
+	Echo bool `json:"echo" example:"false"`
 } //@name AttributeRequest
 
+/** The attributes that are actually computed for a partial request
+ *
+ * Partial requests are hashed along with these, such that the cache key
+ * follows the resolved attribute set. All-or-nothing requests don't need it,
+ * and hash exactly as they did before partial requests were added.
+ */
+func (a AttributeRequest) resolvedAttributes() []string {
+	resolved, _ := core.ResolveAttributes(a.Attributes)
+	return resolved
+}
+
+/** Reject requests with more than limit attributes. Zero means no limit */
+func (a AttributeRequest) validateAttributeCount(limit int) error {
+	if limit > 0 && len(a.Attributes) > limit {
+		return core.NewInvalidArgument(fmt.Sprintf(
+			"Too many attributes in request: %d. The limit is %d",
+			len(a.Attributes),
+			limit,
+		))
+	}
+	return nil
+}
+
+/** Check the attribute names before anything is read from the VDS
+ *
+ * Names are matched case-insensitively, and every attribute can only be
+ * requested once. Unless the request is partial, every name must be valid,
+ * and a single error lists all the invalid ones along with the valid
+ * options.
+ */
+func (a AttributeRequest) validateAttributes() error {
+	seen := map[string]bool{}
+	for _, name := range a.Attributes {
+		normalized := strings.ToLower(name)
+		if seen[normalized] {
+			return core.NewInvalidArgument(fmt.Sprintf(
+				"attribute '%s' is requested more than once",
+				name,
+			))
+		}
+		seen[normalized] = true
+	}
+
+	if a.Partial {
+		return nil
+	}
+
+	_, errs := core.ResolveAttributes(a.Attributes)
+	var invalid []string
+	var details core.ErrorDetails
+	for i, err := range errs {
+		if err != nil {
+			details = core.ErrorDetails{"attribute": a.Attributes[i]}
+			invalid = append(invalid, fmt.Sprintf("'%s'", a.Attributes[i]))
+		}
+	}
+	if len(invalid) == 0 {
+		return nil
+	}
+
+	msg := "invalid attribute %s, valid options are: %s"
+	if len(invalid) > 1 {
+		msg = "invalid attributes %s, valid options are: %s"
+		details = nil
+	}
+	return core.NewCodedInvalidArgument(
+		"invalid_attribute",
+		details,
+		fmt.Sprintf(
+			msg,
+			strings.Join(invalid, ", "),
+			strings.Join(core.AttributeTypes(), ", "),
+		),
+	)
+}
+
+/** The attributes to compute, and the status of every requested one
+ *
+ * All-or-nothing requests are passed on as is once validated, and have no
+ * statuses. Partial requests only pass on the valid attributes. If none of
+ * them are, the request fails with the error of the first one.
+ */
+func (a AttributeRequest) resolveAttributes() (
+	[]string,
+	[]core.AttributeStatus,
+	error,
+) {
+	if err := a.validateAttributes(); err != nil {
+		return nil, nil, err
+	}
+
+	if !a.Partial {
+		return a.Attributes, nil, nil
+	}
+
+	valid, errs := core.ResolveAttributes(a.Attributes)
+	statuses := make([]core.AttributeStatus, len(a.Attributes))
+	var firstErr error
+	for i, name := range a.Attributes {
+		statuses[i] = core.AttributeStatus{Name: name, Status: "ok"}
+		if errs[i] == nil {
+			continue
+		}
+		statuses[i].Status = errs[i].Error()
+		if firstErr == nil {
+			firstErr = errs[i]
+		}
+	}
+
+	if len(valid) == 0 && firstErr != nil {
+		return nil, nil, firstErr
+	}
+	return valid, statuses, nil
+}
+
 // Query for Attribute along the surface endpoints
 // @Description Query payload for attribute "along" endpoint.
 type AttributeAlongSurfaceRequest struct {
 	AttributeRequest
 
-	// Surface along which data must be retrieved
-	Surface core.RegularSurface `json:"surface" binding:"required"`
+	// Surface along which data must be retrieved. Either this or
+	// pointSurface must be given.
+	Surface *core.RegularSurface `json:"surface,omitempty" binding:"required_without=PointSurface"`
+
+	// The surface as scattered (x, y, z) points, which are gridded onto the
+	// grid given along with them, and then used as surface. Mutually
+	// exclusive with surface.
+	PointSurface *core.PointSurface `json:"pointSurface,omitempty"`
 
 	// Samples interval above the horizon to include in attribute calculation.
 	// This value should be given in the VDS's vertical domain. E.g. if the
@@ -277,24 +1045,103 @@ type AttributeAlongSurfaceRequest struct {
 	//
 	// Defaults to zero
 	Below float32 `json:"below" example:"20.0"`
+
+	// Optional polygon that limits the attribute computation to a part of
+	// the surface, given as a list of (x, y) vertices, for example
+	// [[2000.5, 100.5], [2050, 200], [10, 20]]. The polygon is closed
+	// implicitly and must have at least 3 vertices.
+	//
+	// Nodes outside the polygon are not sampled and get the fill value. The
+	// shape of the response is still that of the whole surface.
+	Polygon [][]float32 `json:"polygon,omitempty"`
+
+	// Coordinate system of the polygon vertices, one of cdp, ilxl or ij.
+	// See the fence request for a description of each.
+	//
+	// Defaults to cdp
+	PolygonCoordinateSystem string `json:"polygonCoordinateSystem,omitempty" example:"cdp"`
+
+	// Optional first row of a band of rows of the surface to compute the
+	// attributes for, for paging through the attributes of surfaces too
+	// large for a single response. Only the rows of the band are returned,
+	// and the metadata tells where the band is in the surface. The rows are
+	// those of the surface after rowStep is applied.
+	//
+	// Defaults to 0 if rowCount is given
+	RowOffset *int `json:"rowOffset,omitempty" example:"1000"`
+
+	// Optional number of rows in the band, see rowOffset. A band that
+	// reaches past the last row of the surface is cut short.
+	//
+	// Defaults to the rows from rowOffset to the end of the surface
+	RowCount *int `json:"rowCount,omitempty" example:"500"`
 } //@name AttributeAlongSurfaceRequest
 
 /** Compute a hash of the request that uniquely identifies the requested attributes
  *
- * The hash is computed based on all fields that contribute toward a unique response.
- * I.e. every field except the sas token.
+ * The hash is computed from the canonical form of the request, i.e. from all
+ * fields that contribute toward a unique response, spelled one way only. See
+ * canonical.go.
  */
 func (h AttributeAlongSurfaceRequest) hash() (string, error) {
-	// Strip the sas token before computing hash
-	h.Sas = ""
-	return cache.Hash(h)
+	h = h.canonical()
+	if !h.Partial {
+		return cache.Hash(h)
+	}
+	return cache.Hash(struct {
+		Request  AttributeAlongSurfaceRequest
+		Resolved []string
+	}{h, h.resolvedAttributes()})
+}
+
+/** The polygon to mask the surface with, or nil if none is given */
+func (h AttributeAlongSurfaceRequest) polygon() (*core.Polygon, error) {
+	if h.Polygon == nil {
+		return nil, nil
+	}
+
+	system := h.PolygonCoordinateSystem
+	if system == "" {
+		system = "cdp"
+	}
+	coordinateSystem, err := core.GetCoordinateSystem(system)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(h.Polygon) < 3 {
+		msg := "polygon must have at least 3 vertices, got %d"
+		return nil, core.NewInvalidArgument(fmt.Sprintf(msg, len(h.Polygon)))
+	}
+
+	return &core.Polygon{
+		CoordinateSystem: coordinateSystem,
+		Vertices:         h.Polygon,
+	}, nil
+}
+
+/** The band of rows to compute attributes for, or nil if none is given */
+func (h AttributeAlongSurfaceRequest) band() *core.SurfaceBand {
+	if h.RowOffset == nil && h.RowCount == nil {
+		return nil
+	}
+
+	band := core.SurfaceBand{RowOffset: 0, RowCount: math.MaxInt32}
+	if h.RowOffset != nil {
+		band.RowOffset = *h.RowOffset
+	}
+	if h.RowCount != nil {
+		band.RowCount = *h.RowCount
+	}
+	return &band
 }
 
 func (h AttributeAlongSurfaceRequest) toString() (string, error) {
 	msg := "{vds: %s, Horizon: (ncols: %d, nrows: %d), Rotation: %.2f, " +
 		"Origin: [%.2f, %.2f], Increment: [%.2f, %.2f], FillValue: %.2f, " +
-		"interpolation: %s, Above: %.2f, Below: %.2f, Stepsize: %.2f, " +
-		"Attributes: %v}"
+		"interpolation: %s, verticalInterpolation: %s, " +
+		"Above: %.2f, Below: %.2f, Stepsize: %.2f, " +
+		"Attributes: %v, verticalUnit: %s, band: %v}"
 	return fmt.Sprintf(
 		msg,
 		h.Vds,
@@ -307,10 +1154,13 @@ func (h AttributeAlongSurfaceRequest) toString() (string, error) {
 		h.Surface.Yinc,
 		*h.Surface.FillValue,
 		h.Interpolation,
+		h.VerticalInterpolation,
 		h.Above,
 		h.Below,
 		h.Stepsize,
 		h.Attributes,
+		h.VerticalUnit,
+		h.band(),
 	), nil
 }
 
@@ -335,21 +1185,28 @@ type AttributeBetweenSurfacesRequest struct {
 	// would be found and its value set as the request boundary. It might not be
 	// included in final calculations. If the closest value is fillvalue,
 	// fillvalue will be set in the result buffer. It is not required for
-	// surfaces to have the same plane (origin, rotation, step). If surfaces
-	// intersect, exception will be thrown. If any of the values of the surface
-	// is outside of data boundaries, exception will be raised.
+	// surfaces to have the same plane (origin, rotation, step), but at least
+	// one point on the primary surface must have a closest point on this one.
+	// If surfaces intersect, exception will be thrown. If any of the values of
+	// the surface is outside of data boundaries, exception will be raised.
 	SecondarySurface core.RegularSurface `json:"secondarySurface" binding:"required"`
 } //@name AttributeBetweenSurfacesRequest
 
 /** Compute a hash of the request that uniquely identifies the requested attributes
  *
- * The hash is computed based on all fields that contribute toward a unique response.
- * I.e. every field except the sas token.
+ * The hash is computed from the canonical form of the request, i.e. from all
+ * fields that contribute toward a unique response, spelled one way only. See
+ * canonical.go.
  */
 func (h AttributeBetweenSurfacesRequest) hash() (string, error) {
-	// Strip the sas token before computing hash
-	h.Sas = ""
-	return cache.Hash(h)
+	h = h.canonical()
+	if !h.Partial {
+		return cache.Hash(h)
+	}
+	return cache.Hash(struct {
+		Request  AttributeBetweenSurfacesRequest
+		Resolved []string
+	}{h, h.resolvedAttributes()})
 }
 
 func (h AttributeBetweenSurfacesRequest) toString() (string, error) {
@@ -358,7 +1215,8 @@ func (h AttributeBetweenSurfacesRequest) toString() (string, error) {
 		"Origin: [%.2f, %.2f], Increment: [%.2f, %.2f], FillValue: %.2f. " +
 		"Secondary surface: Values: (ncols: %d, nrows: %d), Rotation: %.2f, " +
 		"Origin: [%.2f, %.2f], Increment: [%.2f, %.2f], FillValue: %.2f. " +
-		"Interpolation: %s, Stepsize: %.2f, Attributes: %v}"
+		"Interpolation: %s, VerticalInterpolation: %s, Stepsize: %.2f, " +
+		"Attributes: %v, VerticalUnit: %s}"
 	return fmt.Sprintf(
 		msg,
 		h.Vds,
@@ -379,7 +1237,9 @@ func (h AttributeBetweenSurfacesRequest) toString() (string, error) {
 		h.SecondarySurface.Yinc,
 		*h.SecondarySurface.FillValue,
 		h.Interpolation,
+		h.VerticalInterpolation,
 		h.Stepsize,
 		h.Attributes,
+		h.VerticalUnit,
 	), nil
 }
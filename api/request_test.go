@@ -1,9 +1,12 @@
 package api
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/require"
+
+	"github.com/equinor/vds-slice/internal/core"
 )
 
 func newSliceRequest(
@@ -96,7 +99,26 @@ func TestSasIsOmmitedFromFenceHash(t *testing.T) {
 	require.Equalf(t, hash1, hash2, "Expected hashes to be equal")
 }
 
+func withRaw(request SliceRequest) SliceRequest {
+	request.Raw = true
+	return request
+}
+
 func TestSliceGivesUniqueHash(t *testing.T) {
+	withLinenoMode := func(request SliceRequest, mode string) SliceRequest {
+		request.LinenoMode = mode
+		return request
+	}
+	withBound := func(request SliceRequest, direction string, lower int) SliceRequest {
+		upper := lower + 10
+		request.Bounds = append(request.Bounds, core.Bound{
+			Direction: &direction,
+			Lower:     &lower,
+			Upper:     &upper,
+		})
+		return request
+	}
+
 	testCases := []struct {
 		name     string
 		request1 SliceRequest
@@ -117,6 +139,31 @@ func TestSliceGivesUniqueHash(t *testing.T) {
 			request1: newSliceRequest("vds", "sas", "inline", 10),
 			request2: newSliceRequest("vds", "sas", "inline", 11),
 		},
+		{
+			name:     "lineno mode differ",
+			request1: newSliceRequest("vds", "sas", "inline", 10),
+			request2: withLinenoMode(newSliceRequest("vds", "sas", "inline", 10), "index"),
+		},
+		{
+			name:     "bounds differ",
+			request1: withBound(newSliceRequest("vds", "sas", "time", 10), "inline", 1),
+			request2: withBound(newSliceRequest("vds", "sas", "time", 10), "inline", 2),
+		},
+		{
+			name:     "bound direction differ",
+			request1: withBound(newSliceRequest("vds", "sas", "time", 10), "inline", 1),
+			request2: withBound(newSliceRequest("vds", "sas", "time", 10), "i", 1),
+		},
+		{
+			name:     "bounded and unbounded",
+			request1: newSliceRequest("vds", "sas", "time", 10),
+			request2: withBound(newSliceRequest("vds", "sas", "time", 10), "inline", 1),
+		},
+		{
+			name:     "raw and float32",
+			request1: newSliceRequest("vds", "sas", "time", 10),
+			request2: withRaw(newSliceRequest("vds", "sas", "time", 10)),
+		},
 	}
 
 	for _, testCase := range testCases {
@@ -141,6 +188,11 @@ func TestFenceGivesUniqueHash(t *testing.T) {
 	fence1 := [][]float32{{1, 2}, {3, 4}}
 	fence2 := [][]float32{{2, 2}, {3, 4}}
 
+	resampled := func(request FenceRequest, interval float32) FenceRequest {
+		request.ResampleTo = &interval
+		return request
+	}
+
 	testCases := []struct {
 		name     string
 		request1 FenceRequest
@@ -166,6 +218,16 @@ func TestFenceGivesUniqueHash(t *testing.T) {
 			request1: newFenceRequest("vds", "sas", "ij", fence1, "linear"),
 			request2: newFenceRequest("vds", "sas", "ij", fence1, "cubic"),
 		},
+		{
+			name:     "Resampling differ",
+			request1: newFenceRequest("vds", "sas", "ij", fence1, "linear"),
+			request2: resampled(newFenceRequest("vds", "sas", "ij", fence1, "linear"), 1),
+		},
+		{
+			name:     "Resample interval differ",
+			request1: resampled(newFenceRequest("vds", "sas", "ij", fence1, "linear"), 1),
+			request2: resampled(newFenceRequest("vds", "sas", "ij", fence1, "linear"), 2),
+		},
 	}
 
 	for _, testCase := range testCases {
@@ -266,3 +328,678 @@ func TestPortPresenceInURL(t *testing.T) {
 		require.Equal(t, testCase.expected, testCase.request.Vds)
 	}
 }
+
+func TestUrlNormalization(t *testing.T) {
+	testCases := []struct {
+		name     string
+		vds      string
+		expected string
+	}{
+		{
+			name:     "Public cloud",
+			vds:      "https://account.blob.core.windows.net/container/blob",
+			expected: "https://account.blob.core.windows.net/container/blob",
+		},
+		{
+			name:     "Sovereign cloud",
+			vds:      "https://account.blob.core.usgovcloudapi.net/container/blob",
+			expected: "https://account.blob.core.usgovcloudapi.net/container/blob",
+		},
+		{
+			name:     "Sovereign cloud, china",
+			vds:      "https://account.blob.core.chinacloudapi.cn/container/blob",
+			expected: "https://account.blob.core.chinacloudapi.cn/container/blob",
+		},
+		{
+			name:     "Private link",
+			vds:      "https://account.privatelink.blob.core.windows.net/container/blob",
+			expected: "https://account.privatelink.blob.core.windows.net/container/blob",
+		},
+		{
+			name:     "Custom dns suffix",
+			vds:      "https://seismic.storage.example.com/container/blob",
+			expected: "https://seismic.storage.example.com/container/blob",
+		},
+		{
+			name:     "Trailing slash",
+			vds:      "https://account.blob.core.windows.net/container/blob/",
+			expected: "https://account.blob.core.windows.net/container/blob",
+		},
+		{
+			name:     "Trailing slashes",
+			vds:      "https://account.blob.core.windows.net/container/blob//",
+			expected: "https://account.blob.core.windows.net/container/blob",
+		},
+		{
+			name:     "Trailing slash with sas",
+			vds:      "https://account.blob.core.windows.net/container/blob/?sas",
+			expected: "https://account.blob.core.windows.net/container/blob",
+		},
+		{
+			name:     "Upper case scheme and host",
+			vds:      "HTTPS://Account.Blob.Core.Windows.Net/container/blob",
+			expected: "https://account.blob.core.windows.net/container/blob",
+		},
+		{
+			name:     "Fully qualified host",
+			vds:      "https://account.blob.core.windows.net./container/blob",
+			expected: "https://account.blob.core.windows.net/container/blob",
+		},
+		{
+			name:     "Percent-encoding in lower case",
+			vds:      "https://account.blob.core.windows.net/container/a%2fb%c3%a6",
+			expected: "https://account.blob.core.windows.net/container/a%2Fb%C3%A6",
+		},
+		{
+			name:     "Percent-encoded unreserved characters",
+			vds:      "https://account.blob.core.windows.net/container/%7Eblob%2D1",
+			expected: "https://account.blob.core.windows.net/container/~blob-1",
+		},
+		{
+			name:     "Case sensitive path",
+			vds:      "https://account.blob.core.windows.net/Container/Blob",
+			expected: "https://account.blob.core.windows.net/Container/Blob",
+		},
+	}
+
+	for _, testCase := range testCases {
+		request := newRequestedResource(testCase.vds, "sas")
+		err := request.NormalizeConnection()
+		require.NoErrorf(t, err, "[%s]", testCase.name)
+		require.Equalf(t, testCase.expected, request.Vds, "[%s]", testCase.name)
+	}
+}
+
+func TestUrlNormalizationSharesHash(t *testing.T) {
+	request1 := newSliceRequest(
+		"https://account.blob.core.windows.net/container/blob",
+		"sas",
+		"inline",
+		9961,
+	)
+	request2 := newSliceRequest(
+		"https://Account.blob.core.windows.net/container/blob/",
+		"sas",
+		"inline",
+		9961,
+	)
+
+	require.NoError(t, request1.NormalizeConnection())
+	require.NoError(t, request2.NormalizeConnection())
+
+	hash1, err := request1.hash()
+	require.NoError(t, err)
+	hash2, err := request2.hash()
+	require.NoError(t, err)
+	require.Equal(t, hash1, hash2, "Expected hashes to be equal")
+}
+
+func TestEquivalentRequestsShareHash(t *testing.T) {
+	withLinenoMode := func(request SliceRequest, mode string) SliceRequest {
+		request.LinenoMode = mode
+		return request
+	}
+	withBound := func(request SliceRequest, direction string, mode string) SliceRequest {
+		lower, upper := 1, 5
+		request.Bounds = append(request.Bounds, core.Bound{
+			Direction: &direction,
+			Lower:     &lower,
+			Upper:     &upper,
+			Mode:      mode,
+		})
+		return request
+	}
+	withoutMetadata := func(request SliceRequest) SliceRequest {
+		request.OmitMetadata = true
+		return request
+	}
+	withFillValue := func(request SliceRequest) SliceRequest {
+		fillValue := core.FillValue(-999.25)
+		request.FillValue = &fillValue
+		return request
+	}
+	withS3 := func(request FenceRequest, secret string) FenceRequest {
+		request.S3 = &S3Options{
+			Region:      "eu-north-1",
+			AccessKeyId: "id",
+			SecretKey:   secret,
+		}
+		return request
+	}
+	fence := [][]float32{{1, 2}, {3, 4}}
+
+	testCases := []struct {
+		name     string
+		request1 interface{ hash() (string, error) }
+		request2 interface{ hash() (string, error) }
+	}{
+		{
+			name:     "Slice direction case",
+			request1: newSliceRequest("vds", "sas", "inline", 10),
+			request2: newSliceRequest("vds", "sas", "InLine", 10),
+		},
+		{
+			name:     "Slice lineno mode given as default",
+			request1: newSliceRequest("vds", "sas", "inline", 10),
+			request2: withLinenoMode(newSliceRequest("vds", "sas", "inline", 10), "Annotation"),
+		},
+		{
+			name:     "Slice index lineno mode given as default",
+			request1: newSliceRequest("vds", "sas", "i", 10),
+			request2: withLinenoMode(newSliceRequest("vds", "sas", "I", 10), "index"),
+		},
+		{
+			name:     "Slice bound mode given as default",
+			request1: withBound(newSliceRequest("vds", "sas", "time", 10), "crossline", ""),
+			request2: withBound(newSliceRequest("vds", "sas", "time", 10), "Crossline", "annotation"),
+		},
+		{
+			name:     "Slice with and without metadata",
+			request1: newSliceRequest("vds", "sas", "inline", 10),
+			request2: withoutMetadata(newSliceRequest("vds", "sas", "inline", 10)),
+		},
+		{
+			name:     "Raw slice with and without fill value",
+			request1: withRaw(newSliceRequest("vds", "sas", "inline", 10)),
+			request2: withFillValue(withRaw(newSliceRequest("vds", "sas", "inline", 10))),
+		},
+		{
+			name:     "Slice with and without echo",
+			request1: newSliceRequest("vds", "sas", "inline", 10),
+			request2: func() SliceRequest {
+				request := newSliceRequest("vds", "sas", "inline", 10)
+				request.Echo = true
+				return request
+			}(),
+		},
+		{
+			name:     "Fence interpolation given as default",
+			request1: newFenceRequest("vds", "sas", "ij", fence, ""),
+			request2: newFenceRequest("vds", "sas", "ij", fence, "Nearest"),
+		},
+		{
+			name:     "Fence coordinate system case",
+			request1: newFenceRequest("vds", "sas", "ilxl", fence, "linear"),
+			request2: newFenceRequest("vds", "sas", "ILXL", fence, "LINEAR"),
+		},
+		{
+			name:     "Fence S3 secrets",
+			request1: withS3(newFenceRequest("vds", "", "ij", fence, "linear"), "a"),
+			request2: withS3(newFenceRequest("vds", "", "ij", fence, "linear"), "b"),
+		},
+		{
+			name: "Sample interpolation given as default",
+			request1: SampleRequest{
+				RequestedResource: newRequestedResource("vds", "sas"),
+				CoordinateSystem:  "ij",
+				Coordinates:       [][]float32{{1, 2, 3}},
+			},
+			request2: SampleRequest{
+				RequestedResource: newRequestedResource("vds", "other-sas"),
+				CoordinateSystem:  "IJ",
+				Coordinates:       [][]float32{{1, 2, 3}},
+				Interpolation:     "nearest",
+			},
+		},
+		{
+			name: "Attribute interpolations given as default",
+			request1: AttributeAlongSurfaceRequest{
+				AttributeRequest: AttributeRequest{
+					RequestedResource: newRequestedResource("vds", "sas"),
+					Attributes:        []string{"samplevalue"},
+				},
+			},
+			request2: AttributeAlongSurfaceRequest{
+				AttributeRequest: AttributeRequest{
+					RequestedResource:     newRequestedResource("vds", "sas"),
+					Attributes:            []string{"samplevalue"},
+					Interpolation:         "Nearest",
+					VerticalInterpolation: "CUBIC",
+					VerticalUnit:          "MS",
+				},
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		hash1, err := testCase.request1.hash()
+		require.NoErrorf(t, err, "[%s]", testCase.name)
+		hash2, err := testCase.request2.hash()
+		require.NoErrorf(t, err, "[%s]", testCase.name)
+		require.Equalf(t, hash1, hash2,
+			"[%s] Expected hashes to be equal", testCase.name,
+		)
+	}
+}
+
+func TestBearerTokenAsCredentials(t *testing.T) {
+	testCases := []struct {
+		name        string
+		request     RequestedResource
+		token       string
+		expected    string
+		shouldError bool
+	}{
+		{
+			name:        "Bearer token only",
+			request:     newRequestedResource("https://account/container/blob", ""),
+			token:       "token",
+			shouldError: false,
+		},
+		{
+			name:        "Both sas and bearer token",
+			request:     newRequestedResource("https://account/container/blob", "sas"),
+			token:       "token",
+			expected:    "only one of them is allowed",
+			shouldError: true,
+		},
+		{
+			name:        "Both signed url and bearer token",
+			request:     newRequestedResource("https://account/container/blob?sas", ""),
+			token:       "token",
+			expected:    "only one of them is allowed",
+			shouldError: true,
+		},
+		{
+			name:        "Neither sas nor bearer token",
+			request:     newRequestedResource("https://account/container/blob", ""),
+			token:       "",
+			expected:    "No valid Sas token is found in the request",
+			shouldError: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		testCase.request.setBearerToken(testCase.token)
+		err := testCase.request.NormalizeConnection()
+		if testCase.shouldError {
+			require.ErrorContainsf(t, err, testCase.expected, "[%s]", testCase.name)
+			continue
+		}
+
+		require.NoErrorf(t, err, "[%s]", testCase.name)
+		_, credentials := testCase.request.credentials()
+		require.Equalf(t, testCase.token, credentials.BearerToken, "[%s]", testCase.name)
+		require.Emptyf(t, credentials.Sas, "[%s]", testCase.name)
+	}
+}
+
+func TestBearerTokenIsOmittedFromHashAndLog(t *testing.T) {
+	request1 := newSliceRequest("some-path", "", "inline", 9961)
+	request1.setBearerToken("some-token")
+	request2 := newSliceRequest("some-path", "", "inline", 9961)
+	request2.setBearerToken("different-token")
+
+	hash1, err := request1.hash()
+	require.NoError(t, err)
+	hash2, err := request2.hash()
+	require.NoError(t, err)
+	require.Equal(t, hash1, hash2, "Expected hashes to be equal")
+
+	str, err := request1.toString()
+	require.NoError(t, err)
+	require.NotContains(t, str, "some-token")
+}
+
+func TestSasInHeader(t *testing.T) {
+	testCases := []struct {
+		name        string
+		request     RequestedResource
+		headerSas   string
+		token       string
+		expected    string
+		shouldError bool
+	}{
+		{
+			name:        "Sas in header only",
+			request:     newRequestedResource("https://account/container/blob", ""),
+			headerSas:   "headersas",
+			expected:    "headersas",
+			shouldError: false,
+		},
+		{
+			name:        "Sas in both request and header",
+			request:     newRequestedResource("https://account/container/blob", "sas"),
+			headerSas:   "headersas",
+			expected:    "only one of them is allowed",
+			shouldError: true,
+		},
+		{
+			name:        "Both signed url and sas in header",
+			request:     newRequestedResource("https://account/container/blob?sas", ""),
+			headerSas:   "headersas",
+			expected:    "only one of them is allowed",
+			shouldError: true,
+		},
+		{
+			name:        "Both sas in header and bearer token",
+			request:     newRequestedResource("https://account/container/blob", ""),
+			headerSas:   "headersas",
+			token:       "token",
+			expected:    "only one of them is allowed",
+			shouldError: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		testCase.request.setHeaderSas(testCase.headerSas)
+		testCase.request.setBearerToken(testCase.token)
+		err := testCase.request.NormalizeConnection()
+		if testCase.shouldError {
+			require.ErrorContainsf(t, err, testCase.expected, "[%s]", testCase.name)
+			continue
+		}
+
+		require.NoErrorf(t, err, "[%s]", testCase.name)
+		_, credentials := testCase.request.credentials()
+		require.Equalf(t, testCase.expected, credentials.Sas, "[%s]", testCase.name)
+	}
+}
+
+func TestHeaderSasIsOmittedFromHashAndLog(t *testing.T) {
+	request1 := newSliceRequest("some-path", "", "inline", 9961)
+	request1.setHeaderSas("some-sas")
+	request2 := newSliceRequest("some-path", "", "inline", 9961)
+	request2.setHeaderSas("different-sas")
+
+	require.NoError(t, request1.NormalizeConnection())
+	require.NoError(t, request2.NormalizeConnection())
+
+	hash1, err := request1.hash()
+	require.NoError(t, err)
+	hash2, err := request2.hash()
+	require.NoError(t, err)
+	require.Equal(t, hash1, hash2, "Expected hashes to be equal")
+
+	str, err := request1.toString()
+	require.NoError(t, err)
+	require.NotContains(t, str, "some-sas")
+}
+
+func TestAmbientCredentials(t *testing.T) {
+	testCases := []struct {
+		vds         string
+		shouldError bool
+	}{
+		{vds: "s3://bucket/blob", shouldError: false},
+		{vds: "gs://bucket/blob", shouldError: false},
+		{vds: "https://account/container/blob", shouldError: true},
+	}
+
+	for _, testCase := range testCases {
+		request := newRequestedResource(testCase.vds, "")
+		err := request.NormalizeConnection()
+		if testCase.shouldError {
+			require.Errorf(t, err, "[%s]", testCase.vds)
+		} else {
+			require.NoErrorf(t, err, "[%s]", testCase.vds)
+		}
+	}
+}
+
+func TestS3SecretsAreOmittedFromHashAndLog(t *testing.T) {
+	newRequest := func(options *S3Options) SliceRequest {
+		request := newSliceRequest("s3://bucket/blob", "", "inline", 9961)
+		request.S3 = options
+		return request
+	}
+	hash := func(request SliceRequest) string {
+		hash, err := request.hash()
+		require.NoError(t, err)
+		return hash
+	}
+
+	request1 := newRequest(&S3Options{Region: "eu-north-1", AccessKeyId: "id1", SecretKey: "secret1"})
+	rotated := newRequest(&S3Options{Region: "eu-north-1", AccessKeyId: "id1", SecretKey: "rotated"})
+	request2 := newRequest(&S3Options{Region: "eu-north-1", AccessKeyId: "id2", SecretKey: "secret2"})
+	ambient := newRequest(&S3Options{Region: "eu-north-1"})
+
+	require.Equal(t, hash(request1), hash(rotated),
+		"Expected the secret key to not be part of the hash")
+	require.NotEqual(t, hash(request1), hash(request2),
+		"Expected requests read as different identities to hash differently")
+	require.NotEqual(t, hash(request1), hash(ambient),
+		"Expected requests with and without credentials to hash differently")
+
+	canonical := request1.canonical()
+	require.NotContains(t, canonical.S3.Identity, "id1")
+	require.Empty(t, canonical.S3.SecretKey)
+
+	str, err := request1.toString()
+	require.NoError(t, err)
+	require.NotContains(t, str, "secret1")
+	require.NotContains(t, str, "id1")
+	require.Equal(t, "secret1", request1.S3.SecretKey, "Request should be unchanged")
+}
+
+func TestPartialAttributesHash(t *testing.T) {
+	newRequest := func(partial bool, attributes ...string) AttributeAlongSurfaceRequest {
+		return AttributeAlongSurfaceRequest{
+			AttributeRequest: AttributeRequest{
+				RequestedResource: newRequestedResource("some-path", "some-sas"),
+				Attributes:        attributes,
+				Partial:           partial,
+			},
+		}
+	}
+	hash := func(request AttributeAlongSurfaceRequest) string {
+		hash, err := request.hash()
+		require.NoError(t, err)
+		return hash
+	}
+
+	allOrNothing := newRequest(false, "min", "bogus")
+	partial := newRequest(true, "min", "bogus")
+	require.NotEqual(t, hash(allOrNothing), hash(partial))
+
+	require.NotEqual(t,
+		hash(newRequest(true, "min", "bogus")),
+		hash(newRequest(true, "min", "max")),
+		"Expected the resolved attribute set to be part of the hash",
+	)
+}
+
+func TestResolvePartialAttributes(t *testing.T) {
+	request := AttributeRequest{
+		Attributes: []string{"min", "MAX", "bogus", "rms"},
+		Partial:    true,
+	}
+
+	attributes, statuses, err := request.resolveAttributes()
+	require.NoError(t, err)
+	require.Equal(t, []string{"min", "max", "rms"}, attributes)
+	require.Len(t, statuses, 4)
+	require.Equal(t, "ok", statuses[0].Status)
+	require.Equal(t, "MAX", statuses[1].Name)
+	require.Equal(t, "bogus", statuses[2].Name)
+	require.Contains(t, statuses[2].Status, "invalid attribute 'bogus'")
+	require.Equal(t, "ok", statuses[3].Status)
+
+	request.Attributes = []string{"bogus"}
+	_, _, err = request.resolveAttributes()
+	require.ErrorContains(t, err, "invalid attribute 'bogus'")
+
+	request.Partial = false
+	request.Attributes = []string{"min", "RMS"}
+	attributes, statuses, err = request.resolveAttributes()
+	require.NoError(t, err)
+	require.Equal(t, []string{"min", "RMS"}, attributes)
+	require.Nil(t, statuses)
+
+	request.Attributes = []string{"min", "bogus"}
+	_, _, err = request.resolveAttributes()
+	require.ErrorContains(t, err, "invalid attribute 'bogus'",
+		"Expected all-or-nothing requests to be validated before core")
+}
+
+func TestValidateAttributes(t *testing.T) {
+	valid := strings.Join(core.AttributeTypes(), ", ")
+
+	testcases := []struct {
+		name       string
+		attributes []string
+		partial    bool
+		expected   string
+	}{
+		{
+			name:       "Case-insensitive names",
+			attributes: []string{"RMS", "Min", "max_at", "SampleValue"},
+		},
+		{
+			name:       "Every invalid name is listed",
+			attributes: []string{"min", "bogus", "rms", "sand"},
+			expected: "invalid attributes 'bogus', 'sand', valid options are: " +
+				valid,
+		},
+		{
+			name:       "Single invalid name",
+			attributes: []string{"bogus"},
+			expected:   "invalid attribute 'bogus', valid options are: " + valid,
+		},
+		{
+			name:       "Invalid names are left to partial requests",
+			attributes: []string{"min", "bogus"},
+			partial:    true,
+		},
+		{
+			name:       "Duplicates",
+			attributes: []string{"min", "rms", "RMS"},
+			expected:   "attribute 'RMS' is requested more than once",
+		},
+		{
+			name:       "Duplicates in partial requests",
+			attributes: []string{"bogus", "bogus"},
+			partial:    true,
+			expected:   "attribute 'bogus' is requested more than once",
+		},
+	}
+
+	for _, testcase := range testcases {
+		request := AttributeRequest{
+			Attributes: testcase.attributes,
+			Partial:    testcase.partial,
+		}
+		err := request.validateAttributes()
+		if testcase.expected == "" {
+			require.NoErrorf(t, err, "[%s]", testcase.name)
+			continue
+		}
+		require.EqualErrorf(t, err, testcase.expected, "[%s]", testcase.name)
+		require.IsTypef(t, &core.InvalidArgument{}, err, "[%s]", testcase.name)
+	}
+}
+
+func TestValidateAttributeCount(t *testing.T) {
+	request := AttributeRequest{Attributes: []string{"min", "max", "rms"}}
+
+	require.NoError(t, request.validateAttributeCount(0), "Expected zero to be no limit")
+	require.NoError(t, request.validateAttributeCount(3))
+
+	err := request.validateAttributeCount(2)
+	require.EqualError(t, err, "Too many attributes in request: 3. The limit is 2")
+}
+
+func TestAttributeAlongSurfacePolygon(t *testing.T) {
+	request := AttributeAlongSurfaceRequest{}
+	polygon, err := request.polygon()
+	require.NoError(t, err)
+	require.Nil(t, polygon)
+
+	request.Polygon = [][]float32{{0, 0}, {1, 0}, {1, 1}}
+	polygon, err = request.polygon()
+	require.NoError(t, err)
+	cdp, _ := core.GetCoordinateSystem("cdp")
+	require.Equal(t, cdp, polygon.CoordinateSystem,
+		"Expected cdp as the default coordinate system")
+	require.Equal(t, request.Polygon, polygon.Vertices)
+
+	request.PolygonCoordinateSystem = "xyz"
+	_, err = request.polygon()
+	require.ErrorContains(t, err, "coordinate system not recognized")
+
+	request.PolygonCoordinateSystem = "ilxl"
+	request.Polygon = request.Polygon[:2]
+	_, err = request.polygon()
+	require.ErrorContains(t, err, "at least 3 vertices")
+}
+
+func TestAttributeAlongSurfacePointSurface(t *testing.T) {
+	zero := float32(0)
+	fill := float32(-999.25)
+	points := func() *core.PointSurface {
+		return &core.PointSurface{
+			Points:       [][]float32{{0, 0, 10}, {1, 0, 11}, {0, 1, 12}},
+			Rotation:     &zero,
+			Xori:         &zero,
+			Yori:         &zero,
+			Xinc:         1,
+			Yinc:         1,
+			Nrows:        2,
+			Ncols:        2,
+			FillValue:    &fill,
+			SearchRadius: 0.5,
+		}
+	}
+
+	request := AttributeAlongSurfaceRequest{PointSurface: points()}
+	require.NoError(t, request.normalizeSurface(0))
+	require.Nil(t, request.PointSurface)
+	require.Equal(t, [][]float32{{10, 12}, {11, fill}}, request.Surface.Values)
+
+	gridded := AttributeAlongSurfaceRequest{PointSurface: points()}
+	require.NoError(t, gridded.normalizeSurface(0))
+	regular := AttributeAlongSurfaceRequest{Surface: gridded.Surface}
+	require.NoError(t, regular.normalizeSurface(0))
+	griddedHash, err := gridded.hash()
+	require.NoError(t, err)
+	regularHash, err := regular.hash()
+	require.NoError(t, err)
+	require.Equal(t, griddedHash, regularHash,
+		"Expected gridded and regular surfaces to share cache entries")
+
+	both := AttributeAlongSurfaceRequest{
+		Surface:      gridded.Surface,
+		PointSurface: points(),
+	}
+	require.EqualError(t, both.normalizeSurface(0),
+		"surface and pointSurface are mutually exclusive")
+
+	tooMany := AttributeAlongSurfaceRequest{PointSurface: points()}
+	require.EqualError(t, tooMany.normalizeSurface(2),
+		"Too many points in pointSurface: 3. The limit is 2")
+
+	largeGrid := AttributeAlongSurfaceRequest{PointSurface: points()}
+	largeGrid.PointSurface.Nrows = 3
+	require.EqualError(t, largeGrid.normalizeSurface(5),
+		"Too many nodes in the grid of pointSurface: 3 x 2. The limit is 5")
+}
+
+func TestAttributeAlongSurfaceBand(t *testing.T) {
+	request := AttributeAlongSurfaceRequest{}
+	require.Nil(t, request.band())
+
+	count := 10
+	request.RowCount = &count
+	require.Equal(t, &core.SurfaceBand{RowOffset: 0, RowCount: 10},
+		request.band(), "Expected the band to start at row 0 by default")
+
+	offset := 20
+	request = AttributeAlongSurfaceRequest{RowOffset: &offset}
+	band := request.band()
+	require.Equal(t, 20, band.RowOffset)
+	require.Greater(t, band.RowCount, 1<<30,
+		"Expected the band to reach the last row by default")
+
+	hash := func(request AttributeAlongSurfaceRequest) string {
+		hash, err := request.hash()
+		require.NoError(t, err)
+		return hash
+	}
+	whole := AttributeAlongSurfaceRequest{}
+	first := AttributeAlongSurfaceRequest{RowOffset: &offset, RowCount: &count}
+	next := offset + count
+	second := AttributeAlongSurfaceRequest{RowOffset: &next, RowCount: &count}
+	require.NotEqual(t, hash(whole), hash(first))
+	require.NotEqual(t, hash(first), hash(second),
+		"Expected every band to be cached on its own")
+}
@@ -2,22 +2,197 @@ package api
 
 import (
 	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"github.com/gin-gonic/gin"
+	"hash/crc32"
 	"log"
+	"mime"
 	"mime/multipart"
+	"net"
 	"net/http"
 	"net/textproto"
+	"os"
+	"regexp"
+	"runtime/debug"
+	"strconv"
 	"strings"
+	"syscall"
+	"time"
+
+	"github.com/equinor/vds-slice/internal/core"
 )
 
 // @Description Error response description
 type ErrorResponse struct {
-	// Textual description of encountered error
-	Error string `json:"error" example:"message"`
+	// Textual description of encountered error. Meant for humans, the wording
+	// may change between releases
+	Error string `json:"error" example:"Invalid lineno: 10, valid range: [0:2:1]"`
+
+	// Stable, machine readable code of the error
+	Code string `json:"code" example:"lineno_out_of_range"`
+
+	// Details about the error, depending on the code
+	Details map[string]string `json:"details,omitempty" swaggertype:"object,string" example:"lineno:10,min:0,max:2,stepsize:1"`
+
+	// Identifies the error in the server logs. Only given for unexpected
+	// errors, which should be reported together with the id
+	ErrorId string `json:"errorId,omitempty" example:"3f2a9c0d5e7b1a48"`
 } // @name ErrorResponse
 
-func writeResponse(ctx *gin.Context, metadata []byte, data [][]byte) {
+// @Description Server limits. A value of zero means no limit is configured
+type Limits struct {
+	// Max size of the response cache, in megabytes
+	CacheSize uint64 `json:"cacheSize" example:"512"`
+
+	// Max size of request bodies for metadata, slice and fence, in bytes
+	RequestSize int64 `json:"requestSize" example:"10485760"`
+
+	// Max size of request bodies for the attribute endpoints, in bytes
+	AttributeRequestSize int64 `json:"attributeRequestSize" example:"209715200"`
+
+	// Max size of the query of GET requests to the attribute endpoints, in
+	// bytes. Larger requests must use POST.
+	AttributeQuerySize int64 `json:"attributeQuerySize" example:"8192"`
+
+	// Max number of coordinates in a fence request
+	FenceCoordinates int `json:"fenceCoordinates" example:"100000"`
+
+	// Max number of points in a sample request
+	SamplePoints int `json:"samplePoints" example:"100000"`
+
+	// Max number of attributes in an attribute request
+	Attributes int `json:"attributes" example:"32"`
+
+	// Max number of requests in a batch request
+	BatchRequests int `json:"batchRequests" example:"20"`
+
+	// Max number of VDSs in a metadata list request
+	MetadataList int `json:"metadataList" example:"100"`
+
+	// Max number of segments in a traverse request
+	TraverseSegments int `json:"traverseSegments" example:"50"`
+
+	// Max number of points in a point surface, and of nodes in the grid it
+	// is gridded onto
+	SurfacePoints int `json:"surfacePoints" example:"1000000"`
+} // @name Limits
+
+// @Description Features supported by this deployment of the server
+type Capabilities struct {
+	// Valid options for slice direction and bound direction
+	Directions []string `json:"directions" example:"i,j,k,inline,crossline,depth,time,sample"`
+
+	// Valid options for slice lineno mode
+	LinenoModes []string `json:"linenoModes" example:"index,annotation"`
+
+	// Valid options for fence coordinate system
+	CoordinateSystems []string `json:"coordinateSystems" example:"ij,ilxl,cdp"`
+
+	// Valid interpolation methods
+	InterpolationMethods []string `json:"interpolationMethods" example:"nearest,linear,cubic,angular,triangular"`
+
+	// Valid interpolation methods for the fence endpoint
+	FenceInterpolationMethods []string `json:"fenceInterpolationMethods" example:"nearest,linear,cubic,angular,triangular,nearest_trace,none"`
+
+	// Valid vertical interpolation methods for the attribute endpoints
+	VerticalInterpolationMethods []string `json:"verticalInterpolationMethods" example:"nearest,linear,cubic"`
+
+	// Valid options for the vertical unit of requests
+	VerticalUnits []string `json:"verticalUnits" example:"ms,s,m,ft"`
+
+	// Valid gridding methods of point surfaces
+	GriddingMethods []string `json:"griddingMethods" example:"nearest,inversedistance"`
+
+	// Valid attributes for the attribute endpoints
+	Attributes []string `json:"attributes" example:"samplevalue,min,max"`
+
+	// Content encodings the server can compress responses with
+	Encodings []string `json:"encodings" example:"gzip"`
+
+	// The endpoints enabled in this deployment. Requests to the others get
+	// 404 with the endpoint_disabled error code.
+	Endpoints []string `json:"endpoints" example:"metadata,slice,fence"`
+
+	// Limits configured for this deployment
+	Limits Limits `json:"limits"`
+
+	// Defaults of requests that leave options out, as configured for this
+	// deployment
+	Defaults Defaults `json:"defaults"`
+} // @name Capabilities
+
+// @Description Defaults of requests that leave options out
+type Defaults struct {
+	// Interpolation method of fence, sample and attribute requests
+	Interpolation string `json:"interpolation" example:"nearest"`
+
+	// Fill value of slice, fence, sample and traverse requests. NaN is given
+	// as the string "nan". Null if there is none, in which case absent data
+	// is returned as stored.
+	FillValue *core.FillValue `json:"fillValue" swaggertype:"number" example:"-999.25"`
+} // @name Defaults
+
+// @Description Server version and capabilities
+type VersionResponse struct {
+	// Version of the server, as given by the git tag or commit it was built from
+	Version string `json:"version" example:"v1.0.0"`
+
+	// Version of the OpenVDS library
+	OpenVDS string `json:"openvds" example:"3.2.7"`
+
+	// Features supported by the server
+	Capabilities Capabilities `json:"capabilities"`
+
+	// The generation of the config, which changes when it is reloaded. Not
+	// given by servers that cannot reload their config.
+	Config *ConfigGeneration `json:"config,omitempty"`
+} // @name VersionResponse
+
+/** Header of data parts, that carries the checksum of the part
+ *
+ * The value is the name of the algorithm and the checksum as 8 hexadecimal
+ * digits, e.g. crc32c=1a2b3c4d. The algorithm is CRC-32C (Castagnoli).
+ */
+const checksumHeader = "X-Content-Checksum"
+
+var crc32cTable = crc32.MakeTable(crc32.Castagnoli)
+
+/* Checksums of the data parts, as given in checksumHeader */
+func dataChecksums(data [][]byte) []uint32 {
+	checksums := make([]uint32, len(data))
+	for i, part := range data {
+		checksums[i] = crc32.Checksum(part, crc32cTable)
+	}
+	return checksums
+}
+
+/*
+ * The value of checksumHeader. It has the same length for any checksum, such
+ * that HEAD requests can give the Content-Length without computing it.
+ */
+func checksumHeaderValue(checksum uint32) string {
+	return fmt.Sprintf("crc32c=%08x", checksum)
+}
+
+/* Header of a binary data part with the given checksum */
+func dataPartHeader(checksum uint32) textproto.MIMEHeader {
+	return textproto.MIMEHeader{
+		"Content-Type": {"application/octet-stream"},
+		checksumHeader: {checksumHeaderValue(checksum)},
+	}
+}
+
+/* The data parts are written in the order of data, which is never changed */
+func writeResponse(
+	ctx *gin.Context,
+	metadata []byte,
+	data [][]byte,
+	checksums []uint32,
+) {
 	response := &bytes.Buffer{}
 	writer := multipart.NewWriter(response)
 
@@ -27,8 +202,8 @@ func writeResponse(ctx *gin.Context, metadata []byte, data [][]byte) {
 		return
 	}
 
-	for _, part := range data {
-		err = writeData(ctx, writer, "application/octet-stream", part)
+	for i, part := range data {
+		err = writePart(writer, dataPartHeader(checksums[i]), part)
 		if err != nil {
 			ctx.AbortWithError(http.StatusInternalServerError, err)
 			return
@@ -45,11 +220,311 @@ func writeResponse(ctx *gin.Context, metadata []byte, data [][]byte) {
 		return
 	}
 
+	ctx.Header("Content-Length", strconv.Itoa(response.Len()))
+	ctx.Data(http.StatusOK, "multipart/mixed; boundary="+writer.Boundary(), response.Bytes())
+}
+
+/** Size in bytes of the data described by the metadata of a data response */
+func dataSize(metadata []byte) (int, error) {
+	var array core.Array
+	err := json.Unmarshal(metadata, &array)
+	if err != nil {
+		return 0, core.NewInternalError(err.Error())
+	}
+
+	size, err := itemSize(array.Format)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, dim := range array.Shape {
+		size *= dim
+	}
+	return size, nil
+}
+
+/** Size in bytes of a single item of the given numpy-style format, e.g. <f4 */
+func itemSize(format string) (int, error) {
+	if len(format) < 3 {
+		return 0, core.NewInternalError(
+			fmt.Sprintf("unexpected data format '%s'", format),
+		)
+	}
+	size, err := strconv.Atoi(format[2:])
+	if err != nil {
+		return 0, core.NewInternalError(
+			fmt.Sprintf("unexpected data format '%s'", format),
+		)
+	}
+	return size, nil
+}
+
+/** A multipart response written part by part, flushing after every part
+ *
+ * Like writeResponse, but with a Stride header on every data part, and
+ * without buffering the response, such that the client can start painting
+ * the coarse passes before the fine ones arrive. The status is written with
+ * the first part, so errors past that point can only be logged, and the
+ * client sees a truncated response.
+ *
+ * For that reason there is no Content-Length. The length the response has
+ * when nothing goes wrong is given in X-Expected-Content-Length instead, such
+ * that clients can still show progress.
+ */
+type progressiveResponse struct {
+	ctx     *gin.Context
+	writer  *multipart.Writer
+	strides []int
+	passes  int
+}
+
+/* Header of a data part of a progressive response */
+func passPartHeader(checksum uint32, stride int) textproto.MIMEHeader {
+	header := dataPartHeader(checksum)
+	header.Set("Stride", strconv.Itoa(stride))
+	return header
+}
+
+/** Write the status, the headers and the metadata part of a progressive response
+ *
+ * sizes are the sizes of the passes in bytes, which give the expected length
+ * of the response before the passes are read. On failure the request is
+ * aborted, and nil is returned.
+ */
+func startProgressiveResponse(
+	ctx *gin.Context,
+	metadata []byte,
+	sizes []int,
+	strides []int,
+) *progressiveResponse {
+	writer := multipart.NewWriter(ctx.Writer)
+
+	/* The checksum header is of fixed width, so any checksum gives the length */
+	headers := []textproto.MIMEHeader{{"Content-Type": {"application/json"}}}
+	partSizes := []int{len(metadata)}
+	for i, size := range sizes {
+		headers = append(headers, passPartHeader(0, strides[i]))
+		partSizes = append(partSizes, size)
+	}
+
+	length, err := multipartLength(writer.Boundary(), headers, partSizes)
+	if err != nil {
+		ctx.AbortWithError(http.StatusInternalServerError, err)
+		return nil
+	}
+
+	ctx.Header("Content-Type", "multipart/mixed; boundary="+writer.Boundary())
+	ctx.Header("X-Expected-Content-Length", strconv.Itoa(length))
+	ctx.Status(http.StatusOK)
+
+	err = writePart(writer, headers[0], metadata)
+	if err != nil {
+		ctx.Abort()
+		return nil
+	}
+	ctx.Writer.Flush()
+
+	return &progressiveResponse{ctx: ctx, writer: writer, strides: strides}
+}
+
+/* Write the next pass, aborting the request on failure */
+func (r *progressiveResponse) writePass(pass []byte) error {
+	stride := r.strides[r.passes]
+	header := passPartHeader(crc32.Checksum(pass, crc32cTable), stride)
+	err := writePart(r.writer, header, pass)
+	if err != nil {
+		r.ctx.Abort()
+		return err
+	}
+	r.passes++
+	r.ctx.Writer.Flush()
+	return nil
+}
+
+/* Write the closing boundary, once every pass is written */
+func (r *progressiveResponse) close() {
+	err := r.writer.Close()
+	if err != nil {
+		log.Println(err)
+		r.ctx.Abort()
+	}
+}
+
+/* A progressive response of passes that are all known up front */
+func writeProgressiveResponse(
+	ctx *gin.Context,
+	metadata []byte,
+	passes [][]byte,
+	strides []int,
+) {
+	sizes := []int{}
+	for _, pass := range passes {
+		sizes = append(sizes, len(pass))
+	}
+
+	response := startProgressiveResponse(ctx, metadata, sizes, strides)
+	if response == nil {
+		return
+	}
+	for _, pass := range passes {
+		if response.writePass(pass) != nil {
+			return
+		}
+	}
+	response.close()
+}
+
+/* Header of a binary data part that is named, rather than described by metadata */
+func namedDataPartHeader(name string, checksum uint32) textproto.MIMEHeader {
+	header := dataPartHeader(checksum)
+	header.Set(
+		"Content-Disposition",
+		mime.FormatMediaType("inline", map[string]string{"name": name}),
+	)
+	return header
+}
+
+/** Write the data of a response without its metadata part
+ *
+ * Without names, the single data part is the whole body, with the checksum
+ * as a header of the response. With names, the response is multipart as
+ * usual, but with the data parts only, each named by its Content-Disposition.
+ */
+func writeDataOnlyResponse(
+	ctx *gin.Context,
+	names []string,
+	data [][]byte,
+	checksums []uint32,
+) {
+	if names == nil {
+		if len(data) != 1 {
+			ctx.AbortWithError(http.StatusInternalServerError, fmt.Errorf(
+				"expected a single data part, got %d", len(data),
+			))
+			return
+		}
+		ctx.Header(checksumHeader, checksumHeaderValue(checksums[0]))
+		ctx.Header("Content-Length", strconv.Itoa(len(data[0])))
+		ctx.Data(http.StatusOK, "application/octet-stream", data[0])
+		return
+	}
+
+	response := &bytes.Buffer{}
+	writer := multipart.NewWriter(response)
+	for i, part := range data {
+		err := writePart(writer, namedDataPartHeader(names[i], checksums[i]), part)
+		if err != nil {
+			ctx.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+	}
+
+	err := writer.Close()
+	if err != nil {
+		ctx.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	ctx.Header("Content-Length", strconv.Itoa(response.Len()))
 	ctx.Data(http.StatusOK, "multipart/mixed; boundary="+writer.Boundary(), response.Bytes())
 }
 
+/** Write the headers writeDataOnlyResponse would for a single data part
+ *
+ * Only slices and fences answer HEAD requests, which have a single data part.
+ * Its checksum is not known without the data, and is left out.
+ */
+func writeDataOnlyResponseHeaders(ctx *gin.Context, size int) {
+	ctx.Header("Content-Type", "application/octet-stream")
+	ctx.Header("Content-Length", strconv.Itoa(size))
+	ctx.Status(http.StatusOK)
+}
+
+/** Write the headers writeResponse would, without writing the body
+ *
+ * sizes are the sizes of the data parts. The multipart framing is written to
+ * a counter only, such that Content-Length matches the response of the
+ * corresponding GET, save for the boundary which is random for every
+ * response anyway.
+ */
+func writeResponseHeaders(ctx *gin.Context, metadata []byte, sizes []int) {
+	headers := []textproto.MIMEHeader{{"Content-Type": {"application/json"}}}
+	partSizes := []int{len(metadata)}
+	for _, size := range sizes {
+		/* The checksum is not known, but its header has a fixed length */
+		headers = append(headers, dataPartHeader(0))
+		partSizes = append(partSizes, size)
+	}
+
+	boundary := multipart.NewWriter(nil).Boundary()
+	length, err := multipartLength(boundary, headers, partSizes)
+	if err != nil {
+		ctx.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	ctx.Header("Content-Type", "multipart/mixed; boundary="+boundary)
+	ctx.Header("Content-Length", strconv.Itoa(length))
+	ctx.Status(http.StatusOK)
+}
+
+/** Length of a multipart body with the given boundary, part headers and sizes
+ *
+ * The framing is written to a counter only, so the parts themselves need not
+ * be at hand.
+ */
+func multipartLength(
+	boundary string,
+	headers []textproto.MIMEHeader,
+	sizes []int,
+) (int, error) {
+	counter := &byteCounter{}
+	writer := multipart.NewWriter(counter)
+	err := writer.SetBoundary(boundary)
+	if err != nil {
+		return 0, core.NewInternalError(err.Error())
+	}
+
+	length := 0
+	for i, header := range headers {
+		err = writePart(writer, header, nil)
+		if err != nil {
+			return 0, err
+		}
+		length += sizes[i]
+	}
+
+	err = writer.Close()
+	if err != nil {
+		return 0, err
+	}
+	return length + counter.count, nil
+}
+
+type byteCounter struct {
+	count int
+}
+
+func (c *byteCounter) Write(p []byte) (int, error) {
+	c.count += len(p)
+	return len(p), nil
+}
+
+/*
+ * The ETag is weak, as data responses differ in their multipart boundary.
+ * Metadata responses use the same form for consistency.
+ */
+func weakETag(hash string) string {
+	return fmt.Sprintf("W/\"%s\"", hash)
+}
+
 func writeData(ctx *gin.Context, writer *multipart.Writer, contentType string, data []byte) error {
-	dataPart, err := writer.CreatePart(textproto.MIMEHeader{"Content-Type": {contentType}})
+	header := textproto.MIMEHeader{"Content-Type": {contentType}}
+	return writePart(writer, header, data)
+}
+
+func writePart(writer *multipart.Writer, header textproto.MIMEHeader, data []byte) error {
+	dataPart, err := writer.CreatePart(header)
 	if err != nil {
 		log.Println(err)
 		return errors.New("unexpected internal error when writing " +
@@ -66,6 +541,40 @@ func writeData(ctx *gin.Context, writer *multipart.Writer, contentType string, d
 	return nil
 }
 
+/*
+ * Query parameters that make up a sas-token, both in plain and url-encoded
+ * form. The prefix group makes sure we only match whole parameter names, i.e.
+ * "se=" but not "response=", while also catching url-encoded separators (%26
+ * and %3F). The value runs until the next parameter separator.
+ */
+var sasParameter = regexp.MustCompile(
+	`(?i)(^|[^a-z0-9_]|%26|%3F)` +
+		`(sig|se|st|sp|sv|sr|srt|ss|spr|sip|si|sdd|skoid|sktid|skt|ske|sks|skv|saoid|suoid|scid)` +
+		`(=|%3D)` +
+		`(?:[^&%\s"']|%(?:[013-9a-f][0-9a-f]|2[0-57-9a-f]))*`,
+)
+
+/*
+ * Bearer tokens and sas-tokens, as found in the Authorization and X-SAS-Token
+ * headers or in an OpenVDS connection string, and S3 secrets as found in the
+ * latter.
+ */
+var credentialPattern = regexp.MustCompile(
+	`(?i)(bearer\s+|sharedaccesssignature\s+|x-sas-token:\s*|` +
+		`bearertoken=|secretkey=|sessiontoken=)[^\s&;"']+`,
+)
+
+/** Strip anything that looks like sas-token parameters from a message
+ *
+ * Errors bubbling up from openvds and Azure may contain the signed url of the
+ * requested resource. We never want to hand the token back to the client, as
+ * error messages tend to end up in client logs and crash reports.
+ */
+func sanitizeErrorMessage(msg string) string {
+	msg = sasParameter.ReplaceAllString(msg, "${1}${2}${3}REDACTED")
+	return credentialPattern.ReplaceAllString(msg, "${1}REDACTED")
+}
+
 func ErrorHandler(ctx *gin.Context) {
 	ctx.Next()
 
@@ -76,16 +585,152 @@ func ErrorHandler(ctx *gin.Context) {
 		return
 	}
 
+	/* Handlers that answer with a body of their own, e.g. validate */
+	if len(ctx.Errors) == 0 && ctx.Writer.Written() {
+		return
+	}
+
 	status := -1
 	if ctx.Writer.Status() == http.StatusOK {
 		status = http.StatusInternalServerError
 	}
 
+	/* Responses to HEAD requests have no body, not even for errors */
+	if ctx.Request.Method == http.MethodHead {
+		if status != -1 {
+			ctx.Status(status)
+		}
+		return
+	}
+
+	response := ErrorResponse{Code: statusErrorCode(ctx.Writer.Status())}
+
 	errors := []string{}
-	for _, err := range ctx.Errors {
-		errors = append(errors, err.Error())
+	for i, err := range ctx.Errors {
+		msg := sanitizeErrorMessage(err.Error())
+		if i == 0 {
+			response.Code, response.Details = classifyError(err.Err)
+		}
+		errors = append(errors, msg)
 	}
-	error := strings.Join(errors[:], ",")
+	response.Error = strings.Join(errors[:], ",")
 
-	ctx.JSON(status, &ErrorResponse{Error: error})
+	ctx.JSON(status, &response)
+}
+
+/** Answer requests to unknown routes with an ErrorResponse
+ *
+ * Replaces the plain text 404 of gin, such that clients get the same error
+ * format from any url.
+ */
+func NoRoute(ctx *gin.Context) {
+	abortWithStatusError(
+		ctx,
+		http.StatusNotFound,
+		fmt.Sprintf("No such route: %s", ctx.Request.URL.Path),
+	)
+}
+
+/** Answer requests with a method the route does not support with an ErrorResponse */
+func NoMethod(ctx *gin.Context) {
+	abortWithStatusError(
+		ctx,
+		http.StatusMethodNotAllowed,
+		fmt.Sprintf(
+			"Method %s is not allowed for %s",
+			ctx.Request.Method,
+			ctx.Request.URL.Path,
+		),
+	)
+}
+
+/* Abort with status, and an error response of msg for any but HEAD requests */
+func abortWithStatusError(ctx *gin.Context, status int, msg string) {
+	if ctx.Request.Method == http.MethodHead {
+		ctx.AbortWithStatus(status)
+		return
+	}
+	ctx.AbortWithStatusJSON(status, ErrorResponse{
+		Error: msg,
+		Code:  statusErrorCode(status),
+	})
+}
+
+/* Random id that ties an error response to the log line of the error */
+func newErrorId() string {
+	id := make([]byte, 8)
+	/* Never fails on supported platforms, and a zero id is still usable */
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+/* Whether the panic is caused by the client going away mid-response */
+func isBrokenConnection(recovered any) bool {
+	err, ok := recovered.(error)
+	if !ok {
+		return false
+	}
+
+	var opErr *net.OpError
+	if !errors.As(err, &opErr) {
+		return false
+	}
+	var syscallErr *os.SyscallError
+	if !errors.As(opErr, &syscallErr) {
+		return false
+	}
+	return errors.Is(syscallErr.Err, syscall.EPIPE) ||
+		errors.Is(syscallErr.Err, syscall.ECONNRESET)
+}
+
+/** Recover from panics in the handlers with a 500 and an ErrorResponse
+ *
+ * Replaces gin.Recovery, which answers with an empty body. The response has
+ * a generic message and an error id. The id is logged together with the
+ * panic, the stack and the request, as set by prepareRequestLogging, such
+ * that reported errors can be found in the logs. The panic is also added to
+ * the errors of the context, so the id ends up in the access log line.
+ *
+ * The panic message is sanitized, as it may hold a signed url.
+ */
+func Recovery() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		defer func() {
+			recovered := recover()
+			if recovered == nil {
+				return
+			}
+
+			errorId := newErrorId()
+			msg := sanitizeErrorMessage(fmt.Sprint(recovered))
+			request, _ := ctx.Get("request")
+			fmt.Fprintf(gin.DefaultErrorWriter,
+				"[Recovery] %v | error id: %s | panic: %s\nrequest: %v\n%s\n",
+				time.Now().Format(time.RFC1123),
+				errorId,
+				msg,
+				request,
+				debug.Stack(),
+			)
+
+			ctx.Error(fmt.Errorf("panic recovered, error id: %s", errorId))
+
+			/*
+			 * Either the client is gone, or it already got the status of a
+			 * response that is now truncated
+			 */
+			if isBrokenConnection(recovered) || ctx.Writer.Written() {
+				ctx.Abort()
+				return
+			}
+
+			ctx.AbortWithStatusJSON(http.StatusInternalServerError, ErrorResponse{
+				Error: "unexpected internal error. Please retry and contact " +
+					"the system admin with the error id if the problem persists",
+				Code:    defaultErrorCodes[http.StatusInternalServerError],
+				ErrorId: errorId,
+			})
+		}()
+		ctx.Next()
+	}
 }
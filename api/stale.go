@@ -0,0 +1,152 @@
+package api
+
+import (
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/equinor/vds-slice/internal/cache"
+	"github.com/equinor/vds-slice/internal/core"
+)
+
+/** Receives notifications about responses served stale, e.g. for metrics */
+type StaleObserver interface {
+	StaleServed()
+}
+
+/** Serve cached responses when storage is down (stale-if-error)
+ *
+ * Cached responses are normally only served after the credentials of the
+ * request are checked against storage, which is impossible while storage is
+ * down. Instead, every time a credential is proven to read a VDS, by a
+ * successful check or read, that is remembered for Window. A request that
+ * fails with a transient storage error, see core.IsStorageTransient, is then
+ * served from the cache if its credential was proven to read the VDS within
+ * Window. Sas tokens must also not have expired. Anything else, including
+ * credentials that storage rejects, fails as usual.
+ *
+ * Only a hash of the VDS and the credential is remembered. A nil
+ * StaleIfError, or a Window of zero, never serves stale responses.
+ *
+ * Safe for concurrent use.
+ */
+type StaleIfError struct {
+	Window   time.Duration
+	Observer StaleObserver
+
+	lock      sync.Mutex
+	verified  map[string]time.Time
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+func NewStaleIfError(window time.Duration, observer StaleObserver) *StaleIfError {
+	return &StaleIfError{
+		Window:   window,
+		Observer: observer,
+		verified: make(map[string]time.Time),
+		now:      time.Now,
+	}
+}
+
+func (s *StaleIfError) enabled() bool {
+	return s != nil && s.Window > 0
+}
+
+func staleKey(vds string, credentials core.Credentials) (string, error) {
+	return cache.Hash(struct {
+		Vds         string
+		Credentials core.Credentials
+	}{vds, credentials})
+}
+
+/* Forget credentials that are verified too long ago, at most once a Window */
+func (s *StaleIfError) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < s.Window {
+		return
+	}
+	for key, verified := range s.verified {
+		if now.Sub(verified) >= s.Window {
+			delete(s.verified, key)
+		}
+	}
+	s.lastSweep = now
+}
+
+/* Remember that the credentials are proven to read the VDS */
+func (s *StaleIfError) verify(vds string, credentials core.Credentials) {
+	if !s.enabled() {
+		return
+	}
+	key, err := staleKey(vds, credentials)
+	if err != nil {
+		return
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	now := s.now()
+	s.sweep(now)
+	s.verified[key] = now
+}
+
+/*
+ * The expiry of a sas token, given by its signed expiry (se). False if it
+ * has none, or one that cannot be parsed.
+ */
+func sasExpiry(sas string) (time.Time, bool) {
+	query, err := url.ParseQuery(sas)
+	if err != nil || !query.Has("se") {
+		return time.Time{}, false
+	}
+	return core.ParseSasTime(query.Get("se"))
+}
+
+/** Check if a request that failed with err may be served from the cache
+ *
+ * The caller must have a cache entry for the request, and is expected to
+ * serve it when true is returned, as that is what the Observer is told.
+ */
+func (s *StaleIfError) serves(
+	vds string,
+	credentials core.Credentials,
+	err error,
+) bool {
+	if !s.enabled() || !core.IsStorageTransient(err) {
+		return false
+	}
+	key, hashErr := staleKey(vds, credentials)
+	if hashErr != nil {
+		return false
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	now := s.now()
+
+	verified, ok := s.verified[key]
+	if !ok || now.Sub(verified) >= s.Window {
+		return false
+	}
+
+	expiry, ok := sasExpiry(credentials.Sas)
+	if ok && !now.Before(expiry) {
+		return false
+	}
+
+	if s.Observer != nil {
+		s.Observer.StaleServed()
+	}
+	return true
+}
+
+/** Tell the client that the response is served stale
+ *
+ * The Warning header is that of a failed revalidation, as in RFC 7234.
+ */
+func setStaleHeaders(ctx *gin.Context) {
+	ctx.Header("Warning", `111 - "Revalidation Failed"`)
+	ctx.Header("X-Served-Stale", "true")
+}
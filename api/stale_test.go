@@ -0,0 +1,122 @@
+package api
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/equinor/vds-slice/internal/core"
+)
+
+type staleCounter struct {
+	served int
+}
+
+func (c *staleCounter) StaleServed() {
+	c.served++
+}
+
+func newTestStaleIfError(window time.Duration) (*StaleIfError, *time.Time, *staleCounter) {
+	counter := &staleCounter{}
+	stale := NewStaleIfError(window, counter)
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	stale.now = func() time.Time { return now }
+	return stale, &now, counter
+}
+
+func TestStaleIfErrorServesVerifiedCredentials(t *testing.T) {
+	stale, now, counter := newTestStaleIfError(time.Minute)
+	credentials := core.Credentials{Sas: "sv=1&sig=a"}
+	transient := core.NewStorageError("Error on downloading: 503 Server Busy", 503)
+
+	require.False(t, stale.serves("vds", credentials, transient),
+		"Credentials that are never verified must not be served stale")
+
+	stale.verify("vds", credentials)
+	require.True(t, stale.serves("vds", credentials, transient))
+
+	require.False(t, stale.serves("vds", core.Credentials{Sas: "sv=1&sig=b"}, transient),
+		"Other credentials must not be served stale")
+	require.False(t, stale.serves("other-vds", credentials, transient),
+		"Credentials are verified per VDS")
+
+	*now = now.Add(time.Minute)
+	require.False(t, stale.serves("vds", credentials, transient),
+		"Credentials verified longer than the window ago must not be served stale")
+
+	require.Equal(t, 1, counter.served)
+}
+
+func TestStaleIfErrorOnlyOnTransientErrors(t *testing.T) {
+	stale, _, _ := newTestStaleIfError(time.Minute)
+	credentials := core.Credentials{BearerToken: "token"}
+	stale.verify("vds", credentials)
+
+	testCases := []struct {
+		name  string
+		err   error
+		serve bool
+	}{
+		{"Circuit open", core.NewUnavailableError("open", time.Second), true},
+		{"Throttled", core.NewStorageError("429 Too Many Requests", 429), true},
+		{"Timeout", core.NewStorageError("operation timed out", 504), true},
+		{"Forbidden", core.NewForbiddenError("403 Forbidden"), false},
+		{"Not found", core.NewNotFoundError("404 Not Found"), false},
+		{"Invalid argument", core.NewInvalidArgument("503"), false},
+		{"Other internal error", core.NewInternalError("segfault 503"), false},
+		{"Unclassified", errors.New("503"), false},
+	}
+
+	for _, testCase := range testCases {
+		require.Equalf(t,
+			testCase.serve,
+			stale.serves("vds", credentials, testCase.err),
+			"[%s]",
+			testCase.name,
+		)
+	}
+}
+
+func TestStaleIfErrorExpiredSas(t *testing.T) {
+	stale, _, _ := newTestStaleIfError(time.Hour)
+	transient := core.NewUnavailableError("open", time.Second)
+
+	testCases := []struct {
+		name  string
+		sas   string
+		serve bool
+	}{
+		{"No expiry", "sv=1&sig=a", true},
+		{"Valid", "se=2024-01-01T13:00:00Z&sig=a", true},
+		{"Expired", "se=2024-01-01T11:59:00Z&sig=a", false},
+		{"Expired without seconds", "se=2024-01-01T11:59Z&sig=a", false},
+		{"Expired with fractions", "se=2024-01-01T11:59:00.0000000Z&sig=a", false},
+		{"Expired date", "se=2023-12-31&sig=a", false},
+	}
+
+	for _, testCase := range testCases {
+		credentials := core.Credentials{Sas: testCase.sas}
+		stale.verify("vds", credentials)
+		require.Equalf(t,
+			testCase.serve,
+			stale.serves("vds", credentials, transient),
+			"[%s]",
+			testCase.name,
+		)
+	}
+}
+
+func TestStaleIfErrorDisabled(t *testing.T) {
+	credentials := core.Credentials{Sas: "sig=a"}
+	transient := core.NewUnavailableError("open", time.Second)
+
+	var disabled *StaleIfError
+	disabled.verify("vds", credentials)
+	require.False(t, disabled.serves("vds", credentials, transient))
+
+	stale, _, _ := newTestStaleIfError(0)
+	stale.verify("vds", credentials)
+	require.False(t, stale.serves("vds", credentials, transient))
+}
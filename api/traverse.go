@@ -0,0 +1,407 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/equinor/vds-slice/internal/cache"
+	"github.com/equinor/vds-slice/internal/core"
+)
+
+// @Description A straight segment of a traverse, along an inline or crossline
+type TraverseSegment struct {
+	// Direction of the segment. Valid options: inline, crossline, i and j.
+	//
+	// For inline and crossline, lineno, from and to are annotations, i.e.
+	// line numbers. For i and j they are 0-indexed.
+	Direction string `json:"direction" binding:"required" example:"inline"`
+
+	// The line the segment runs along
+	Lineno *int `json:"lineno" binding:"required" example:"10000"`
+
+	// The first and last line of the segment, inclusive. For a segment along
+	// an inline these are crosslines, and the other way around. From may be
+	// greater than to, in which case the segment runs backwards.
+	From *int `json:"from" binding:"required" example:"2000"`
+	To   *int `json:"to" binding:"required" example:"2100"`
+
+	// Bounds along the vertical axis, see SliceRequest.Bounds. Every segment
+	// must give the same vertical window. Optional, the segment spans the
+	// whole vertical axis if not given.
+	Bounds []core.Bound `json:"bounds" binding:"dive"`
+} //@name TraverseSegment
+
+// Query for the traverse endpoint
+// @Description Query payload for traverse endpoint /traverse.
+type TraverseRequest struct {
+	RequestedResource
+
+	// The segments of the traverse, in order. Every segment must start where
+	// the previous one ends.
+	Segments []TraverseSegment `json:"segments" binding:"required,min=1,dive"`
+
+	// Providing a FillValue is optional, see SliceRequest.FillValue
+	FillValue *core.FillValue `json:"fillValue" swaggertype:"number" example:"-999.25"`
+
+	// Unit of the vertical axis, as used in the bounds and the metadata, see
+	// SliceRequest.VerticalUnit
+	VerticalUnit string `json:"verticalUnit" example:"ms"`
+
+	// Include the request in the metadata part, see SliceRequest.Echo
+	Echo bool `json:"echo" example:"false"`
+} //@name TraverseRequest
+
+// @Description Where a segment is in the traverse
+type TraverseSegmentMetadata struct {
+	// Index of the first trace of the segment in the panel
+	Offset int `json:"offset" example:"0"`
+
+	// Number of traces of the segment in the panel
+	Traces int `json:"traces" example:"101"`
+} // @name TraverseSegmentMetadata
+
+// @Description Traverse metadata
+type TraverseMetadata struct {
+	// The panel, as traces by samples
+	core.Array
+
+	// The vertical axis, common to all segments
+	Vertical core.Axis `json:"vertical"`
+
+	// Where every segment is in the panel, in the order of the request. The
+	// trace where two segments meet is only given once, as the last trace of
+	// the first segment.
+	Segments []TraverseSegmentMetadata `json:"segments"`
+
+	// The value absent data is replaced by, see SliceMetadata.FillValue
+	FillValue *core.FillValue `json:"fillValue" swaggertype:"number" example:"-999.25"`
+} // @name TraverseMetadata
+
+func (t TraverseRequest) toString() (string, error) {
+	t.Sas = ""
+	t.S3 = t.S3.withoutSecrets()
+	out, err := json.Marshal(t)
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
+
+/** Compute a hash of the request that uniquely identifies the traverse
+ *
+ * The hash is computed from the canonical form of the request, i.e. from all
+ * fields that contribute toward a unique response, spelled one way only. See
+ * canonical.go.
+ */
+func (t TraverseRequest) hash() (string, error) {
+	return cache.Hash(t.canonical())
+}
+
+/* Reject traverses with more segments than the limit. 0 means no limit. */
+func (t TraverseRequest) validateSegmentCount(limit int) error {
+	if limit > 0 && len(t.Segments) > limit {
+		return core.NewInvalidArgument(fmt.Sprintf(
+			"Too many segments in traverse: %d. The limit is %d",
+			len(t.Segments),
+			limit,
+		))
+	}
+	return nil
+}
+
+/** The directions of the segment, and of the line it is bounded along
+ *
+ * The range of a segment is a bound along the other horizontal axis, in the
+ * same system as its lineno.
+ */
+func (s TraverseSegment) axes() (axis int, rangeDirection string, err error) {
+	axis, err = core.GetAxis(strings.ToLower(s.Direction))
+	if err != nil {
+		return
+	}
+
+	switch axis {
+	case core.AxisInline:
+		return axis, "crossline", nil
+	case core.AxisCrossline:
+		return axis, "inline", nil
+	case core.AxisI:
+		return axis, "j", nil
+	case core.AxisJ:
+		return axis, "i", nil
+	default:
+		return axis, "", core.NewInvalidArgument(fmt.Sprintf(
+			"segments must be along inline, crossline, i or j, got %s",
+			s.Direction,
+		))
+	}
+}
+
+/* The range of the segment, lowest line first */
+func (s TraverseSegment) span() (lower int, upper int) {
+	if *s.From > *s.To {
+		return *s.To, *s.From
+	}
+	return *s.From, *s.To
+}
+
+/* The segment as a slice, bounded to its range */
+func (s TraverseSegment) slice(request TraverseRequest) (SliceRequest, error) {
+	_, rangeDirection, err := s.axes()
+	if err != nil {
+		return SliceRequest{}, err
+	}
+
+	for _, bound := range s.Bounds {
+		axis, err := core.GetAxis(strings.ToLower(*bound.Direction))
+		if err == nil && !isVertical(axis) {
+			return SliceRequest{}, core.NewInvalidArgument(fmt.Sprintf(
+				"only vertical bounds are allowed, got %s. The horizontal "+
+					"range of a segment is given by from and to",
+				*bound.Direction,
+			))
+		}
+	}
+
+	lower, upper := s.span()
+	bounds := []core.Bound{{
+		Direction: &rangeDirection,
+		Lower:     &lower,
+		Upper:     &upper,
+	}}
+
+	return SliceRequest{
+		RequestedResource: request.RequestedResource,
+		Direction:         s.Direction,
+		Lineno:            s.Lineno,
+		Bounds:            append(bounds, s.Bounds...),
+		FillValue:         request.FillValue,
+		VerticalUnit:      request.VerticalUnit,
+	}, nil
+}
+
+/** The (inline, crossline) annotation of the first and last trace of the segment
+ *
+ * The lines of i and j segments are converted from indices with the axes of
+ * the cube. Whether the lines exist is left for the slice to report.
+ */
+func (s TraverseSegment) endpoints(
+	inline core.Axis,
+	crossline core.Axis,
+) (first [2]float64, last [2]float64, err error) {
+	axis, _, err := s.axes()
+	if err != nil {
+		return
+	}
+
+	annotation := func(axis core.Axis, line int, isIndex bool) float64 {
+		if isIndex {
+			return axis.Min + float64(line)*axis.StepSize
+		}
+		return float64(line)
+	}
+
+	isIndex := axis == core.AxisI || axis == core.AxisJ
+	switch axis {
+	case core.AxisInline, core.AxisI:
+		il := annotation(inline, *s.Lineno, isIndex)
+		first = [2]float64{il, annotation(crossline, *s.From, isIndex)}
+		last = [2]float64{il, annotation(crossline, *s.To, isIndex)}
+	default:
+		xl := annotation(crossline, *s.Lineno, isIndex)
+		first = [2]float64{annotation(inline, *s.From, isIndex), xl}
+		last = [2]float64{annotation(inline, *s.To, isIndex), xl}
+	}
+	return
+}
+
+/* Every segment must start where the previous one ends */
+func (t TraverseRequest) validateContinuity(handle core.DSHandle) error {
+	buf, err := handle.GetMetadata(false, false)
+	if err != nil {
+		return err
+	}
+
+	var metadata core.Metadata
+	err = json.Unmarshal(buf, &metadata)
+	if err != nil {
+		return core.NewInternalError(err.Error())
+	}
+
+	/* 2D lines have no inline, and are a single segment by themselves */
+	if len(metadata.Axis) != 3 {
+		return core.NewInvalidArgument("traverses are not supported for 2D lines")
+	}
+	inline, crossline := *metadata.Axis[0], *metadata.Axis[1]
+
+	var previous [2]float64
+	for i, segment := range t.Segments {
+		first, last, err := segment.endpoints(inline, crossline)
+		if err != nil {
+			return segmentError(i, err)
+		}
+
+		if i > 0 && first != previous {
+			return core.NewInvalidArgument(fmt.Sprintf(
+				"segment %d starts at (inline %v, crossline %v), but segment %d "+
+					"ends at (inline %v, crossline %v). Every segment must "+
+					"start where the previous one ends",
+				i, first[0], first[1], i-1, previous[0], previous[1],
+			))
+		}
+		previous = last
+	}
+	return nil
+}
+
+/* Traverses are at most one trace per line in range, if the lines are contiguous */
+func (t TraverseRequest) estimateSize(handle core.DSHandle) (int64, error) {
+	buf, err := handle.GetMetadata(false, false)
+	if err != nil {
+		return 0, err
+	}
+
+	var metadata core.Metadata
+	err = json.Unmarshal(buf, &metadata)
+	if err != nil {
+		return 0, core.NewInternalError(err.Error())
+	}
+
+	traces := int64(0)
+	for _, segment := range t.Segments {
+		lower, upper := segment.span()
+		traces += int64(upper-lower) + 1
+	}
+	samples := int64(metadata.Axis[len(metadata.Axis)-1].Samples)
+	return traces * samples * 4, nil
+}
+
+func (t TraverseRequest) observe(observer RequestObserver, metadata []byte) {
+	var array core.Array
+	if err := json.Unmarshal(metadata, &array); err != nil {
+		return
+	}
+
+	samples := 1
+	for _, length := range array.Shape {
+		samples *= length
+	}
+	observer.SliceSamples("traverse", samples)
+}
+
+/** The traverse as a single panel
+ *
+ * Every segment is read as a bounded slice against the same handle, reversed
+ * if it runs backwards, and the slices are concatenated trace by trace. The
+ * trace where two segments meet is only included once.
+ */
+func (t TraverseRequest) execute(
+	handle core.DSHandle,
+) (data [][]byte, metadata []byte, err error) {
+	err = t.validateContinuity(handle)
+	if err != nil {
+		return
+	}
+
+	var panel []byte
+	traverse := TraverseMetadata{Segments: []TraverseSegmentMetadata{}}
+	for i, segment := range t.Segments {
+		slice, err := segment.slice(t)
+		if err != nil {
+			return nil, nil, segmentError(i, err)
+		}
+
+		sliceData, sliceMetadata, err := slice.execute(handle)
+		if err != nil {
+			return nil, nil, segmentError(i, err)
+		}
+
+		var meta core.SliceMetadata
+		err = json.Unmarshal(sliceMetadata, &meta)
+		if err != nil {
+			return nil, nil, core.NewInternalError(err.Error())
+		}
+
+		itemsize, err := itemSize(meta.Format)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if i == 0 {
+			traverse.Array.Format = meta.Format
+			traverse.Vertical = meta.X
+			traverse.FillValue = meta.FillValue
+		} else if !sameVerticalWindow(traverse.Vertical, meta.X) {
+			return nil, nil, core.NewInvalidArgument(fmt.Sprintf(
+				"the vertical window of segment %d, %v to %v, differs from "+
+					"that of segment 0, %v to %v. Every segment must have "+
+					"the same vertical window",
+				i, meta.X.Min, meta.X.Max,
+				traverse.Vertical.Min, traverse.Vertical.Max,
+			))
+		}
+
+		traceSize := meta.Shape[1] * itemsize
+		traces := splitTraces(sliceData[0], traceSize)
+		if *segment.From > *segment.To {
+			for l, r := 0, len(traces)-1; l < r; l, r = l+1, r-1 {
+				traces[l], traces[r] = traces[r], traces[l]
+			}
+		}
+
+		/* The first trace is the last of the previous segment */
+		if i > 0 {
+			traces = traces[1:]
+		}
+
+		traverse.Segments = append(traverse.Segments, TraverseSegmentMetadata{
+			Offset: len(panel) / traceSize,
+			Traces: len(traces),
+		})
+		for _, trace := range traces {
+			panel = append(panel, trace...)
+		}
+	}
+
+	ntraces := 0
+	for _, segment := range traverse.Segments {
+		ntraces += segment.Traces
+	}
+	traverse.Array.Shape = []int{ntraces, traverse.Vertical.Samples}
+
+	metadata, err = json.Marshal(traverse)
+	if err != nil {
+		return nil, nil, core.NewInternalError(err.Error())
+	}
+	return [][]byte{panel}, metadata, nil
+}
+
+/* Name the segment in errors that are about the request */
+func segmentError(index int, err error) error {
+	var invalid *core.InvalidArgument
+	if errors.As(err, &invalid) {
+		return rephrasedInvalidArgument(
+			err,
+			fmt.Sprintf("segment %d: %v", index, err),
+		)
+	}
+	return err
+}
+
+func sameVerticalWindow(a, b core.Axis) bool {
+	return a.Min == b.Min &&
+		a.Max == b.Max &&
+		a.Samples == b.Samples &&
+		a.StepSize == b.StepSize
+}
+
+/* The traces of a slice, given as traces by samples */
+func splitTraces(data []byte, traceSize int) [][]byte {
+	traces := [][]byte{}
+	for offset := 0; offset+traceSize <= len(data); offset += traceSize {
+		traces = append(traces, data[offset:offset+traceSize])
+	}
+	return traces
+}
@@ -0,0 +1,176 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/equinor/vds-slice/internal/core"
+)
+
+// Query for the validate endpoint
+// @Description Query payload for the validate endpoint /validate.
+type ValidateRequest struct {
+	RequestedResource
+} //@name ValidateRequest
+
+func (v ValidateRequest) toString() (string, error) {
+	return MetadataRequest{RequestedResource: v.RequestedResource}.toString()
+}
+
+// @Description Whether a VDS can be read with the given credentials. Fields
+// @Description that can not be told are null, e.g. whether the VDS exists
+// @Description when the credentials are rejected.
+type ValidateResponse struct {
+	// The VDS exists in storage
+	Exists *bool `json:"exists" example:"true"`
+
+	// The credentials grant read access to the VDS
+	Authorized *bool `json:"authorized" example:"true"`
+
+	// The VDS can be opened by OpenVDS, i.e. it is a VDS
+	VdsFormatOk *bool `json:"vdsFormatOk" example:"true"`
+
+	// Why the VDS can not be read, as for an ErrorResponse
+	Error string `json:"error,omitempty" example:"Could not open VDS: 404 The specified blob does not exist"`
+
+	// Stable, machine readable code of the error, as for an ErrorResponse
+	Code string `json:"code,omitempty" example:"vds_not_found"`
+} //@name ValidateResponse
+
+/** The status and response of a validation that failed with err
+ *
+ * False for errors that say nothing about the VDS, e.g. transient storage
+ * errors, which are answered as for any other request. OpenVDS failing to
+ * open a VDS that is neither missing nor inaccessible is taken to mean it
+ * is not a VDS.
+ */
+func validation(err error) (int, ValidateResponse, bool) {
+	yes, no := true, false
+	switch err.(type) {
+	case nil:
+		return http.StatusOK, ValidateResponse{
+			Exists:      &yes,
+			Authorized:  &yes,
+			VdsFormatOk: &yes,
+		}, true
+	case *core.UnauthorizedError:
+		return http.StatusUnauthorized, ValidateResponse{Authorized: &no}, true
+	case *core.ForbiddenError:
+		return http.StatusForbidden, ValidateResponse{Authorized: &no}, true
+	case *core.NotFoundError:
+		return http.StatusNotFound, ValidateResponse{
+			Exists:     &no,
+			Authorized: &yes,
+		}, true
+	case *core.InternalError:
+		if core.IsStorageTransient(err) {
+			return 0, ValidateResponse{}, false
+		}
+		return http.StatusUnprocessableEntity, ValidateResponse{
+			Exists:      &yes,
+			Authorized:  &yes,
+			VdsFormatOk: &no,
+		}, true
+	}
+	return 0, ValidateResponse{}, false
+}
+
+/** Check that the VDS can be opened, without reading any of its data
+ *
+ * Opening a VDS reads its layout, but neither the metadata the metadata
+ * endpoint serves nor any data chunks. There are no retries, as a failed
+ * validation is cheap to repeat, and nothing is cached.
+ */
+func (e *Endpoint) validate(ctx *gin.Context, request ValidateRequest) {
+	prepareRequestLogging(ctx, request)
+	defer e.auditResource(ctx, request.RequestedResource)
+
+	conn, err := e.MakeVdsConnection(request.credentials())
+	if abortOnError(ctx, err) {
+		return
+	}
+
+	err = e.Breaker.Do(core.StorageHost(request.Vds), func() error {
+		if err := checkDeadline(ctx.Request.Context()); err != nil {
+			return err
+		}
+		handle, err := e.opener()(conn)
+		if err != nil {
+			return err
+		}
+		handle.Close()
+		return nil
+	})
+
+	status, response, ok := validation(err)
+	if !ok {
+		abortOnError(ctx, err)
+		return
+	}
+	if err != nil {
+		response.Error = sanitizeErrorMessage(err.Error())
+		response.Code, _ = classifyError(err)
+	}
+	ctx.JSON(status, response)
+}
+
+// ValidateGet godoc
+// @Summary  Check that a VDS exists and can be read with the given credentials
+// @description.markdown validate
+// @Tags     metadata
+// @Param    query  query  string  True  "Urlencoded/escaped ValidateRequest"
+// @Produce  json
+// @Success  200 {object} ValidateResponse
+// @Failure  400 {object} ErrorResponse "Request is invalid"
+// @Failure  401 {object} ValidateResponse "Credentials are missing, invalid or expired"
+// @Failure  403 {object} ValidateResponse "Credentials do not grant read access to the VDS"
+// @Failure  404 {object} ValidateResponse "VDS not found"
+// @Failure  422 {object} ValidateResponse "The blob is not a VDS"
+// @Failure  429 {object} ErrorResponse "Too many requests, see Retry-After"
+// @Failure  500 {object} ErrorResponse "openvds failed to process the request"
+// @Failure  503 {object} ErrorResponse "Storage account is unavailable, see Retry-After"
+// @Failure  504 {object} ErrorResponse "The deadline of the request passed, see X-Request-Deadline"
+// @Router   /validate  [get]
+func (e *Endpoint) ValidateGet(ctx *gin.Context) {
+	var request ValidateRequest
+	err := e.parseGetRequest(ctx, &request)
+	if abortOnError(ctx, err) {
+		return
+	}
+
+	e.validate(ctx, request)
+}
+
+// ValidatePost godoc
+// @Summary  Check that a VDS exists and can be read with the given credentials
+// @description.markdown validate
+// @Tags     metadata
+// @Param    body  body  ValidateRequest  True  "Request parameters"
+// @Produce  json
+// @Success  200 {object} ValidateResponse
+// @Failure  400 {object} ErrorResponse "Request is invalid"
+// @Failure  401 {object} ValidateResponse "Credentials are missing, invalid or expired"
+// @Failure  403 {object} ValidateResponse "Credentials do not grant read access to the VDS"
+// @Failure  404 {object} ValidateResponse "VDS not found"
+// @Failure  413 {object} ErrorResponse "Request body is too large"
+// @Failure  422 {object} ValidateResponse "The blob is not a VDS"
+// @Failure  429 {object} ErrorResponse "Too many requests, see Retry-After"
+// @Failure  500 {object} ErrorResponse "openvds failed to process the request"
+// @Failure  503 {object} ErrorResponse "Storage account is unavailable, see Retry-After"
+// @Failure  504 {object} ErrorResponse "The deadline of the request passed, see X-Request-Deadline"
+// @Router   /validate  [post]
+func (e *Endpoint) ValidatePost(ctx *gin.Context) {
+	body, err := readRequestBody(ctx)
+	if abortOnError(ctx, err) {
+		return
+	}
+
+	var request ValidateRequest
+	err = e.parseRequestBody(ctx, body, &request)
+	if abortOnError(ctx, err) {
+		return
+	}
+
+	e.validate(ctx, request)
+}
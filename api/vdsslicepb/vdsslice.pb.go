@@ -0,0 +1,1512 @@
+//
+// gRPC facade for the vds-slice server.
+//
+// The messages mirror the json requests of the http API, and the fields have
+// the same meaning and validation. See the swagger documentation of the http
+// API for the details.
+//
+// Credentials are given either in the sas field, or as request metadata: a
+// sas-token in 'x-sas-token', or an Azure AD access token as
+// 'authorization: Bearer <token>'.
+//
+// Regenerate the go code with:
+//
+//     protoc --go_out=. --go_opt=paths=source_relative \
+//            --go-grpc_out=. --go-grpc_opt=paths=source_relative \
+//            api/vdsslicepb/vdsslice.proto
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.31.0
+// 	protoc        (unknown)
+// source: api/vdsslicepb/vdsslice.proto
+
+package vdsslicepb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type S3Options struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Region       string `protobuf:"bytes,1,opt,name=region,proto3" json:"region,omitempty"`
+	AccessKeyId  string `protobuf:"bytes,2,opt,name=access_key_id,json=accessKeyId,proto3" json:"access_key_id,omitempty"`
+	SecretKey    string `protobuf:"bytes,3,opt,name=secret_key,json=secretKey,proto3" json:"secret_key,omitempty"`
+	SessionToken string `protobuf:"bytes,4,opt,name=session_token,json=sessionToken,proto3" json:"session_token,omitempty"`
+}
+
+func (x *S3Options) Reset() {
+	*x = S3Options{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_api_vdsslicepb_vdsslice_proto_msgTypes[0]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *S3Options) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*S3Options) ProtoMessage() {}
+
+func (x *S3Options) ProtoReflect() protoreflect.Message {
+	mi := &file_api_vdsslicepb_vdsslice_proto_msgTypes[0]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use S3Options.ProtoReflect.Descriptor instead.
+func (*S3Options) Descriptor() ([]byte, []int) {
+	return file_api_vdsslicepb_vdsslice_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *S3Options) GetRegion() string {
+	if x != nil {
+		return x.Region
+	}
+	return ""
+}
+
+func (x *S3Options) GetAccessKeyId() string {
+	if x != nil {
+		return x.AccessKeyId
+	}
+	return ""
+}
+
+func (x *S3Options) GetSecretKey() string {
+	if x != nil {
+		return x.SecretKey
+	}
+	return ""
+}
+
+func (x *S3Options) GetSessionToken() string {
+	if x != nil {
+		return x.SessionToken
+	}
+	return ""
+}
+
+type RequestedResource struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Vds string     `protobuf:"bytes,1,opt,name=vds,proto3" json:"vds,omitempty"`
+	Sas string     `protobuf:"bytes,2,opt,name=sas,proto3" json:"sas,omitempty"`
+	S3  *S3Options `protobuf:"bytes,3,opt,name=s3,proto3" json:"s3,omitempty"`
+}
+
+func (x *RequestedResource) Reset() {
+	*x = RequestedResource{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_api_vdsslicepb_vdsslice_proto_msgTypes[1]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *RequestedResource) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RequestedResource) ProtoMessage() {}
+
+func (x *RequestedResource) ProtoReflect() protoreflect.Message {
+	mi := &file_api_vdsslicepb_vdsslice_proto_msgTypes[1]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RequestedResource.ProtoReflect.Descriptor instead.
+func (*RequestedResource) Descriptor() ([]byte, []int) {
+	return file_api_vdsslicepb_vdsslice_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *RequestedResource) GetVds() string {
+	if x != nil {
+		return x.Vds
+	}
+	return ""
+}
+
+func (x *RequestedResource) GetSas() string {
+	if x != nil {
+		return x.Sas
+	}
+	return ""
+}
+
+func (x *RequestedResource) GetS3() *S3Options {
+	if x != nil {
+		return x.S3
+	}
+	return nil
+}
+
+type MetadataRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Resource          *RequestedResource `protobuf:"bytes,1,opt,name=resource,proto3" json:"resource,omitempty"`
+	IncludeImportInfo bool               `protobuf:"varint,2,opt,name=include_import_info,json=includeImportInfo,proto3" json:"include_import_info,omitempty"`
+}
+
+func (x *MetadataRequest) Reset() {
+	*x = MetadataRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_api_vdsslicepb_vdsslice_proto_msgTypes[2]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *MetadataRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MetadataRequest) ProtoMessage() {}
+
+func (x *MetadataRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_api_vdsslicepb_vdsslice_proto_msgTypes[2]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MetadataRequest.ProtoReflect.Descriptor instead.
+func (*MetadataRequest) Descriptor() ([]byte, []int) {
+	return file_api_vdsslicepb_vdsslice_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *MetadataRequest) GetResource() *RequestedResource {
+	if x != nil {
+		return x.Resource
+	}
+	return nil
+}
+
+func (x *MetadataRequest) GetIncludeImportInfo() bool {
+	if x != nil {
+		return x.IncludeImportInfo
+	}
+	return false
+}
+
+type MetadataResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// The same json document as returned by the http API
+	Json string `protobuf:"bytes,1,opt,name=json,proto3" json:"json,omitempty"`
+}
+
+func (x *MetadataResponse) Reset() {
+	*x = MetadataResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_api_vdsslicepb_vdsslice_proto_msgTypes[3]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *MetadataResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MetadataResponse) ProtoMessage() {}
+
+func (x *MetadataResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_api_vdsslicepb_vdsslice_proto_msgTypes[3]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MetadataResponse.ProtoReflect.Descriptor instead.
+func (*MetadataResponse) Descriptor() ([]byte, []int) {
+	return file_api_vdsslicepb_vdsslice_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *MetadataResponse) GetJson() string {
+	if x != nil {
+		return x.Json
+	}
+	return ""
+}
+
+type Bound struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Direction string `protobuf:"bytes,1,opt,name=direction,proto3" json:"direction,omitempty"`
+	Lower     int32  `protobuf:"varint,2,opt,name=lower,proto3" json:"lower,omitempty"`
+	Upper     int32  `protobuf:"varint,3,opt,name=upper,proto3" json:"upper,omitempty"`
+}
+
+func (x *Bound) Reset() {
+	*x = Bound{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_api_vdsslicepb_vdsslice_proto_msgTypes[4]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Bound) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Bound) ProtoMessage() {}
+
+func (x *Bound) ProtoReflect() protoreflect.Message {
+	mi := &file_api_vdsslicepb_vdsslice_proto_msgTypes[4]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Bound.ProtoReflect.Descriptor instead.
+func (*Bound) Descriptor() ([]byte, []int) {
+	return file_api_vdsslicepb_vdsslice_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *Bound) GetDirection() string {
+	if x != nil {
+		return x.Direction
+	}
+	return ""
+}
+
+func (x *Bound) GetLower() int32 {
+	if x != nil {
+		return x.Lower
+	}
+	return 0
+}
+
+func (x *Bound) GetUpper() int32 {
+	if x != nil {
+		return x.Upper
+	}
+	return 0
+}
+
+type SliceRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Resource     *RequestedResource `protobuf:"bytes,1,opt,name=resource,proto3" json:"resource,omitempty"`
+	Direction    string             `protobuf:"bytes,2,opt,name=direction,proto3" json:"direction,omitempty"`
+	Lineno       *int32             `protobuf:"varint,3,opt,name=lineno,proto3,oneof" json:"lineno,omitempty"`
+	Bounds       []*Bound           `protobuf:"bytes,4,rep,name=bounds,proto3" json:"bounds,omitempty"`
+	FillValue    *float32           `protobuf:"fixed32,5,opt,name=fill_value,json=fillValue,proto3,oneof" json:"fill_value,omitempty"`
+	VerticalUnit string             `protobuf:"bytes,6,opt,name=vertical_unit,json=verticalUnit,proto3" json:"vertical_unit,omitempty"`
+}
+
+func (x *SliceRequest) Reset() {
+	*x = SliceRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_api_vdsslicepb_vdsslice_proto_msgTypes[5]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *SliceRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SliceRequest) ProtoMessage() {}
+
+func (x *SliceRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_api_vdsslicepb_vdsslice_proto_msgTypes[5]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SliceRequest.ProtoReflect.Descriptor instead.
+func (*SliceRequest) Descriptor() ([]byte, []int) {
+	return file_api_vdsslicepb_vdsslice_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *SliceRequest) GetResource() *RequestedResource {
+	if x != nil {
+		return x.Resource
+	}
+	return nil
+}
+
+func (x *SliceRequest) GetDirection() string {
+	if x != nil {
+		return x.Direction
+	}
+	return ""
+}
+
+func (x *SliceRequest) GetLineno() int32 {
+	if x != nil && x.Lineno != nil {
+		return *x.Lineno
+	}
+	return 0
+}
+
+func (x *SliceRequest) GetBounds() []*Bound {
+	if x != nil {
+		return x.Bounds
+	}
+	return nil
+}
+
+func (x *SliceRequest) GetFillValue() float32 {
+	if x != nil && x.FillValue != nil {
+		return *x.FillValue
+	}
+	return 0
+}
+
+func (x *SliceRequest) GetVerticalUnit() string {
+	if x != nil {
+		return x.VerticalUnit
+	}
+	return ""
+}
+
+type Coordinate struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	X float32 `protobuf:"fixed32,1,opt,name=x,proto3" json:"x,omitempty"`
+	Y float32 `protobuf:"fixed32,2,opt,name=y,proto3" json:"y,omitempty"`
+}
+
+func (x *Coordinate) Reset() {
+	*x = Coordinate{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_api_vdsslicepb_vdsslice_proto_msgTypes[6]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Coordinate) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Coordinate) ProtoMessage() {}
+
+func (x *Coordinate) ProtoReflect() protoreflect.Message {
+	mi := &file_api_vdsslicepb_vdsslice_proto_msgTypes[6]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Coordinate.ProtoReflect.Descriptor instead.
+func (*Coordinate) Descriptor() ([]byte, []int) {
+	return file_api_vdsslicepb_vdsslice_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *Coordinate) GetX() float32 {
+	if x != nil {
+		return x.X
+	}
+	return 0
+}
+
+func (x *Coordinate) GetY() float32 {
+	if x != nil {
+		return x.Y
+	}
+	return 0
+}
+
+type FenceRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Resource         *RequestedResource `protobuf:"bytes,1,opt,name=resource,proto3" json:"resource,omitempty"`
+	CoordinateSystem string             `protobuf:"bytes,2,opt,name=coordinate_system,json=coordinateSystem,proto3" json:"coordinate_system,omitempty"`
+	Coordinates      []*Coordinate      `protobuf:"bytes,3,rep,name=coordinates,proto3" json:"coordinates,omitempty"`
+	Interpolation    string             `protobuf:"bytes,4,opt,name=interpolation,proto3" json:"interpolation,omitempty"`
+	FillValue        *float32           `protobuf:"fixed32,5,opt,name=fill_value,json=fillValue,proto3,oneof" json:"fill_value,omitempty"`
+	VerticalUnit     string             `protobuf:"bytes,6,opt,name=vertical_unit,json=verticalUnit,proto3" json:"vertical_unit,omitempty"`
+}
+
+func (x *FenceRequest) Reset() {
+	*x = FenceRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_api_vdsslicepb_vdsslice_proto_msgTypes[7]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *FenceRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FenceRequest) ProtoMessage() {}
+
+func (x *FenceRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_api_vdsslicepb_vdsslice_proto_msgTypes[7]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FenceRequest.ProtoReflect.Descriptor instead.
+func (*FenceRequest) Descriptor() ([]byte, []int) {
+	return file_api_vdsslicepb_vdsslice_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *FenceRequest) GetResource() *RequestedResource {
+	if x != nil {
+		return x.Resource
+	}
+	return nil
+}
+
+func (x *FenceRequest) GetCoordinateSystem() string {
+	if x != nil {
+		return x.CoordinateSystem
+	}
+	return ""
+}
+
+func (x *FenceRequest) GetCoordinates() []*Coordinate {
+	if x != nil {
+		return x.Coordinates
+	}
+	return nil
+}
+
+func (x *FenceRequest) GetInterpolation() string {
+	if x != nil {
+		return x.Interpolation
+	}
+	return ""
+}
+
+func (x *FenceRequest) GetFillValue() float32 {
+	if x != nil && x.FillValue != nil {
+		return *x.FillValue
+	}
+	return 0
+}
+
+func (x *FenceRequest) GetVerticalUnit() string {
+	if x != nil {
+		return x.VerticalUnit
+	}
+	return ""
+}
+
+type SurfaceRow struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Values []float32 `protobuf:"fixed32,1,rep,packed,name=values,proto3" json:"values,omitempty"`
+}
+
+func (x *SurfaceRow) Reset() {
+	*x = SurfaceRow{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_api_vdsslicepb_vdsslice_proto_msgTypes[8]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *SurfaceRow) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SurfaceRow) ProtoMessage() {}
+
+func (x *SurfaceRow) ProtoReflect() protoreflect.Message {
+	mi := &file_api_vdsslicepb_vdsslice_proto_msgTypes[8]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SurfaceRow.ProtoReflect.Descriptor instead.
+func (*SurfaceRow) Descriptor() ([]byte, []int) {
+	return file_api_vdsslicepb_vdsslice_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *SurfaceRow) GetValues() []float32 {
+	if x != nil {
+		return x.Values
+	}
+	return nil
+}
+
+type RegularSurface struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Values    []*SurfaceRow `protobuf:"bytes,1,rep,name=values,proto3" json:"values,omitempty"`
+	Rotation  *float32      `protobuf:"fixed32,2,opt,name=rotation,proto3,oneof" json:"rotation,omitempty"`
+	Xori      *float32      `protobuf:"fixed32,3,opt,name=xori,proto3,oneof" json:"xori,omitempty"`
+	Yori      *float32      `protobuf:"fixed32,4,opt,name=yori,proto3,oneof" json:"yori,omitempty"`
+	Xinc      float32       `protobuf:"fixed32,5,opt,name=xinc,proto3" json:"xinc,omitempty"`
+	Yinc      float32       `protobuf:"fixed32,6,opt,name=yinc,proto3" json:"yinc,omitempty"`
+	FillValue *float32      `protobuf:"fixed32,7,opt,name=fill_value,json=fillValue,proto3,oneof" json:"fill_value,omitempty"`
+}
+
+func (x *RegularSurface) Reset() {
+	*x = RegularSurface{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_api_vdsslicepb_vdsslice_proto_msgTypes[9]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *RegularSurface) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RegularSurface) ProtoMessage() {}
+
+func (x *RegularSurface) ProtoReflect() protoreflect.Message {
+	mi := &file_api_vdsslicepb_vdsslice_proto_msgTypes[9]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RegularSurface.ProtoReflect.Descriptor instead.
+func (*RegularSurface) Descriptor() ([]byte, []int) {
+	return file_api_vdsslicepb_vdsslice_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *RegularSurface) GetValues() []*SurfaceRow {
+	if x != nil {
+		return x.Values
+	}
+	return nil
+}
+
+func (x *RegularSurface) GetRotation() float32 {
+	if x != nil && x.Rotation != nil {
+		return *x.Rotation
+	}
+	return 0
+}
+
+func (x *RegularSurface) GetXori() float32 {
+	if x != nil && x.Xori != nil {
+		return *x.Xori
+	}
+	return 0
+}
+
+func (x *RegularSurface) GetYori() float32 {
+	if x != nil && x.Yori != nil {
+		return *x.Yori
+	}
+	return 0
+}
+
+func (x *RegularSurface) GetXinc() float32 {
+	if x != nil {
+		return x.Xinc
+	}
+	return 0
+}
+
+func (x *RegularSurface) GetYinc() float32 {
+	if x != nil {
+		return x.Yinc
+	}
+	return 0
+}
+
+func (x *RegularSurface) GetFillValue() float32 {
+	if x != nil && x.FillValue != nil {
+		return *x.FillValue
+	}
+	return 0
+}
+
+type AttributeAlongSurfaceRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Resource              *RequestedResource `protobuf:"bytes,1,opt,name=resource,proto3" json:"resource,omitempty"`
+	Surface               *RegularSurface    `protobuf:"bytes,2,opt,name=surface,proto3" json:"surface,omitempty"`
+	Above                 float32            `protobuf:"fixed32,3,opt,name=above,proto3" json:"above,omitempty"`
+	Below                 float32            `protobuf:"fixed32,4,opt,name=below,proto3" json:"below,omitempty"`
+	Stepsize              float32            `protobuf:"fixed32,5,opt,name=stepsize,proto3" json:"stepsize,omitempty"`
+	Interpolation         string             `protobuf:"bytes,6,opt,name=interpolation,proto3" json:"interpolation,omitempty"`
+	Attributes            []string           `protobuf:"bytes,7,rep,name=attributes,proto3" json:"attributes,omitempty"`
+	VerticalInterpolation string             `protobuf:"bytes,8,opt,name=vertical_interpolation,json=verticalInterpolation,proto3" json:"vertical_interpolation,omitempty"`
+	VerticalUnit          string             `protobuf:"bytes,9,opt,name=vertical_unit,json=verticalUnit,proto3" json:"vertical_unit,omitempty"`
+}
+
+func (x *AttributeAlongSurfaceRequest) Reset() {
+	*x = AttributeAlongSurfaceRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_api_vdsslicepb_vdsslice_proto_msgTypes[10]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *AttributeAlongSurfaceRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AttributeAlongSurfaceRequest) ProtoMessage() {}
+
+func (x *AttributeAlongSurfaceRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_api_vdsslicepb_vdsslice_proto_msgTypes[10]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AttributeAlongSurfaceRequest.ProtoReflect.Descriptor instead.
+func (*AttributeAlongSurfaceRequest) Descriptor() ([]byte, []int) {
+	return file_api_vdsslicepb_vdsslice_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *AttributeAlongSurfaceRequest) GetResource() *RequestedResource {
+	if x != nil {
+		return x.Resource
+	}
+	return nil
+}
+
+func (x *AttributeAlongSurfaceRequest) GetSurface() *RegularSurface {
+	if x != nil {
+		return x.Surface
+	}
+	return nil
+}
+
+func (x *AttributeAlongSurfaceRequest) GetAbove() float32 {
+	if x != nil {
+		return x.Above
+	}
+	return 0
+}
+
+func (x *AttributeAlongSurfaceRequest) GetBelow() float32 {
+	if x != nil {
+		return x.Below
+	}
+	return 0
+}
+
+func (x *AttributeAlongSurfaceRequest) GetStepsize() float32 {
+	if x != nil {
+		return x.Stepsize
+	}
+	return 0
+}
+
+func (x *AttributeAlongSurfaceRequest) GetInterpolation() string {
+	if x != nil {
+		return x.Interpolation
+	}
+	return ""
+}
+
+func (x *AttributeAlongSurfaceRequest) GetAttributes() []string {
+	if x != nil {
+		return x.Attributes
+	}
+	return nil
+}
+
+func (x *AttributeAlongSurfaceRequest) GetVerticalInterpolation() string {
+	if x != nil {
+		return x.VerticalInterpolation
+	}
+	return ""
+}
+
+func (x *AttributeAlongSurfaceRequest) GetVerticalUnit() string {
+	if x != nil {
+		return x.VerticalUnit
+	}
+	return ""
+}
+
+type AttributeBetweenSurfacesRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Resource              *RequestedResource `protobuf:"bytes,1,opt,name=resource,proto3" json:"resource,omitempty"`
+	PrimarySurface        *RegularSurface    `protobuf:"bytes,2,opt,name=primary_surface,json=primarySurface,proto3" json:"primary_surface,omitempty"`
+	SecondarySurface      *RegularSurface    `protobuf:"bytes,3,opt,name=secondary_surface,json=secondarySurface,proto3" json:"secondary_surface,omitempty"`
+	Stepsize              float32            `protobuf:"fixed32,4,opt,name=stepsize,proto3" json:"stepsize,omitempty"`
+	Interpolation         string             `protobuf:"bytes,5,opt,name=interpolation,proto3" json:"interpolation,omitempty"`
+	Attributes            []string           `protobuf:"bytes,6,rep,name=attributes,proto3" json:"attributes,omitempty"`
+	VerticalInterpolation string             `protobuf:"bytes,7,opt,name=vertical_interpolation,json=verticalInterpolation,proto3" json:"vertical_interpolation,omitempty"`
+	VerticalUnit          string             `protobuf:"bytes,8,opt,name=vertical_unit,json=verticalUnit,proto3" json:"vertical_unit,omitempty"`
+}
+
+func (x *AttributeBetweenSurfacesRequest) Reset() {
+	*x = AttributeBetweenSurfacesRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_api_vdsslicepb_vdsslice_proto_msgTypes[11]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *AttributeBetweenSurfacesRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AttributeBetweenSurfacesRequest) ProtoMessage() {}
+
+func (x *AttributeBetweenSurfacesRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_api_vdsslicepb_vdsslice_proto_msgTypes[11]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AttributeBetweenSurfacesRequest.ProtoReflect.Descriptor instead.
+func (*AttributeBetweenSurfacesRequest) Descriptor() ([]byte, []int) {
+	return file_api_vdsslicepb_vdsslice_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *AttributeBetweenSurfacesRequest) GetResource() *RequestedResource {
+	if x != nil {
+		return x.Resource
+	}
+	return nil
+}
+
+func (x *AttributeBetweenSurfacesRequest) GetPrimarySurface() *RegularSurface {
+	if x != nil {
+		return x.PrimarySurface
+	}
+	return nil
+}
+
+func (x *AttributeBetweenSurfacesRequest) GetSecondarySurface() *RegularSurface {
+	if x != nil {
+		return x.SecondarySurface
+	}
+	return nil
+}
+
+func (x *AttributeBetweenSurfacesRequest) GetStepsize() float32 {
+	if x != nil {
+		return x.Stepsize
+	}
+	return 0
+}
+
+func (x *AttributeBetweenSurfacesRequest) GetInterpolation() string {
+	if x != nil {
+		return x.Interpolation
+	}
+	return ""
+}
+
+func (x *AttributeBetweenSurfacesRequest) GetAttributes() []string {
+	if x != nil {
+		return x.Attributes
+	}
+	return nil
+}
+
+func (x *AttributeBetweenSurfacesRequest) GetVerticalInterpolation() string {
+	if x != nil {
+		return x.VerticalInterpolation
+	}
+	return ""
+}
+
+func (x *AttributeBetweenSurfacesRequest) GetVerticalUnit() string {
+	if x != nil {
+		return x.VerticalUnit
+	}
+	return ""
+}
+
+type DataChunk struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	//
+	// Index of the data part the chunk belongs to. Attribute requests return
+	// one part per requested attribute, in the requested order.
+	Part uint32 `protobuf:"varint,1,opt,name=part,proto3" json:"part,omitempty"`
+	Data []byte `protobuf:"bytes,2,opt,name=data,proto3" json:"data,omitempty"`
+}
+
+func (x *DataChunk) Reset() {
+	*x = DataChunk{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_api_vdsslicepb_vdsslice_proto_msgTypes[12]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *DataChunk) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DataChunk) ProtoMessage() {}
+
+func (x *DataChunk) ProtoReflect() protoreflect.Message {
+	mi := &file_api_vdsslicepb_vdsslice_proto_msgTypes[12]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DataChunk.ProtoReflect.Descriptor instead.
+func (*DataChunk) Descriptor() ([]byte, []int) {
+	return file_api_vdsslicepb_vdsslice_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *DataChunk) GetPart() uint32 {
+	if x != nil {
+		return x.Part
+	}
+	return 0
+}
+
+func (x *DataChunk) GetData() []byte {
+	if x != nil {
+		return x.Data
+	}
+	return nil
+}
+
+type DataResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// Types that are assignable to Response:
+	//	*DataResponse_Metadata
+	//	*DataResponse_Data
+	Response isDataResponse_Response `protobuf_oneof:"response"`
+}
+
+func (x *DataResponse) Reset() {
+	*x = DataResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_api_vdsslicepb_vdsslice_proto_msgTypes[13]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *DataResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DataResponse) ProtoMessage() {}
+
+func (x *DataResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_api_vdsslicepb_vdsslice_proto_msgTypes[13]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DataResponse.ProtoReflect.Descriptor instead.
+func (*DataResponse) Descriptor() ([]byte, []int) {
+	return file_api_vdsslicepb_vdsslice_proto_rawDescGZIP(), []int{13}
+}
+
+func (m *DataResponse) GetResponse() isDataResponse_Response {
+	if m != nil {
+		return m.Response
+	}
+	return nil
+}
+
+func (x *DataResponse) GetMetadata() string {
+	if x, ok := x.GetResponse().(*DataResponse_Metadata); ok {
+		return x.Metadata
+	}
+	return ""
+}
+
+func (x *DataResponse) GetData() *DataChunk {
+	if x, ok := x.GetResponse().(*DataResponse_Data); ok {
+		return x.Data
+	}
+	return nil
+}
+
+type isDataResponse_Response interface {
+	isDataResponse_Response()
+}
+
+type DataResponse_Metadata struct {
+	// The same json document as the metadata part of the http API
+	Metadata string `protobuf:"bytes,1,opt,name=metadata,proto3,oneof"`
+}
+
+type DataResponse_Data struct {
+	Data *DataChunk `protobuf:"bytes,2,opt,name=data,proto3,oneof"`
+}
+
+func (*DataResponse_Metadata) isDataResponse_Response() {}
+
+func (*DataResponse_Data) isDataResponse_Response() {}
+
+var File_api_vdsslicepb_vdsslice_proto protoreflect.FileDescriptor
+
+var file_api_vdsslicepb_vdsslice_proto_rawDesc = []byte{
+	0x0a, 0x1d, 0x61, 0x70, 0x69, 0x2f, 0x76, 0x64, 0x73, 0x73, 0x6c, 0x69, 0x63, 0x65, 0x70, 0x62,
+	0x2f, 0x76, 0x64, 0x73, 0x73, 0x6c, 0x69, 0x63, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x12,
+	0x0b, 0x76, 0x64, 0x73, 0x73, 0x6c, 0x69, 0x63, 0x65, 0x2e, 0x76, 0x31, 0x22, 0x8b, 0x01, 0x0a,
+	0x09, 0x53, 0x33, 0x4f, 0x70, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x12, 0x16, 0x0a, 0x06, 0x72, 0x65,
+	0x67, 0x69, 0x6f, 0x6e, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x72, 0x65, 0x67, 0x69,
+	0x6f, 0x6e, 0x12, 0x22, 0x0a, 0x0d, 0x61, 0x63, 0x63, 0x65, 0x73, 0x73, 0x5f, 0x6b, 0x65, 0x79,
+	0x5f, 0x69, 0x64, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0b, 0x61, 0x63, 0x63, 0x65, 0x73,
+	0x73, 0x4b, 0x65, 0x79, 0x49, 0x64, 0x12, 0x1d, 0x0a, 0x0a, 0x73, 0x65, 0x63, 0x72, 0x65, 0x74,
+	0x5f, 0x6b, 0x65, 0x79, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x09, 0x73, 0x65, 0x63, 0x72,
+	0x65, 0x74, 0x4b, 0x65, 0x79, 0x12, 0x23, 0x0a, 0x0d, 0x73, 0x65, 0x73, 0x73, 0x69, 0x6f, 0x6e,
+	0x5f, 0x74, 0x6f, 0x6b, 0x65, 0x6e, 0x18, 0x04, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0c, 0x73, 0x65,
+	0x73, 0x73, 0x69, 0x6f, 0x6e, 0x54, 0x6f, 0x6b, 0x65, 0x6e, 0x22, 0x5f, 0x0a, 0x11, 0x52, 0x65,
+	0x71, 0x75, 0x65, 0x73, 0x74, 0x65, 0x64, 0x52, 0x65, 0x73, 0x6f, 0x75, 0x72, 0x63, 0x65, 0x12,
+	0x10, 0x0a, 0x03, 0x76, 0x64, 0x73, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x76, 0x64,
+	0x73, 0x12, 0x10, 0x0a, 0x03, 0x73, 0x61, 0x73, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03,
+	0x73, 0x61, 0x73, 0x12, 0x26, 0x0a, 0x02, 0x73, 0x33, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0b, 0x32,
+	0x16, 0x2e, 0x76, 0x64, 0x73, 0x73, 0x6c, 0x69, 0x63, 0x65, 0x2e, 0x76, 0x31, 0x2e, 0x53, 0x33,
+	0x4f, 0x70, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x52, 0x02, 0x73, 0x33, 0x22, 0x7d, 0x0a, 0x0f, 0x4d,
+	0x65, 0x74, 0x61, 0x64, 0x61, 0x74, 0x61, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x3a,
+	0x0a, 0x08, 0x72, 0x65, 0x73, 0x6f, 0x75, 0x72, 0x63, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b,
+	0x32, 0x1e, 0x2e, 0x76, 0x64, 0x73, 0x73, 0x6c, 0x69, 0x63, 0x65, 0x2e, 0x76, 0x31, 0x2e, 0x52,
+	0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x65, 0x64, 0x52, 0x65, 0x73, 0x6f, 0x75, 0x72, 0x63, 0x65,
+	0x52, 0x08, 0x72, 0x65, 0x73, 0x6f, 0x75, 0x72, 0x63, 0x65, 0x12, 0x2e, 0x0a, 0x13, 0x69, 0x6e,
+	0x63, 0x6c, 0x75, 0x64, 0x65, 0x5f, 0x69, 0x6d, 0x70, 0x6f, 0x72, 0x74, 0x5f, 0x69, 0x6e, 0x66,
+	0x6f, 0x18, 0x02, 0x20, 0x01, 0x28, 0x08, 0x52, 0x11, 0x69, 0x6e, 0x63, 0x6c, 0x75, 0x64, 0x65,
+	0x49, 0x6d, 0x70, 0x6f, 0x72, 0x74, 0x49, 0x6e, 0x66, 0x6f, 0x22, 0x26, 0x0a, 0x10, 0x4d, 0x65,
+	0x74, 0x61, 0x64, 0x61, 0x74, 0x61, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x12,
+	0x0a, 0x04, 0x6a, 0x73, 0x6f, 0x6e, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x6a, 0x73,
+	0x6f, 0x6e, 0x22, 0x51, 0x0a, 0x05, 0x42, 0x6f, 0x75, 0x6e, 0x64, 0x12, 0x1c, 0x0a, 0x09, 0x64,
+	0x69, 0x72, 0x65, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x09,
+	0x64, 0x69, 0x72, 0x65, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x14, 0x0a, 0x05, 0x6c, 0x6f, 0x77,
+	0x65, 0x72, 0x18, 0x02, 0x20, 0x01, 0x28, 0x05, 0x52, 0x05, 0x6c, 0x6f, 0x77, 0x65, 0x72, 0x12,
+	0x14, 0x0a, 0x05, 0x75, 0x70, 0x70, 0x65, 0x72, 0x18, 0x03, 0x20, 0x01, 0x28, 0x05, 0x52, 0x05,
+	0x75, 0x70, 0x70, 0x65, 0x72, 0x22, 0x94, 0x02, 0x0a, 0x0c, 0x53, 0x6c, 0x69, 0x63, 0x65, 0x52,
+	0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x3a, 0x0a, 0x08, 0x72, 0x65, 0x73, 0x6f, 0x75, 0x72,
+	0x63, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1e, 0x2e, 0x76, 0x64, 0x73, 0x73, 0x6c,
+	0x69, 0x63, 0x65, 0x2e, 0x76, 0x31, 0x2e, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x65, 0x64,
+	0x52, 0x65, 0x73, 0x6f, 0x75, 0x72, 0x63, 0x65, 0x52, 0x08, 0x72, 0x65, 0x73, 0x6f, 0x75, 0x72,
+	0x63, 0x65, 0x12, 0x1c, 0x0a, 0x09, 0x64, 0x69, 0x72, 0x65, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x18,
+	0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x09, 0x64, 0x69, 0x72, 0x65, 0x63, 0x74, 0x69, 0x6f, 0x6e,
+	0x12, 0x1b, 0x0a, 0x06, 0x6c, 0x69, 0x6e, 0x65, 0x6e, 0x6f, 0x18, 0x03, 0x20, 0x01, 0x28, 0x05,
+	0x48, 0x00, 0x52, 0x06, 0x6c, 0x69, 0x6e, 0x65, 0x6e, 0x6f, 0x88, 0x01, 0x01, 0x12, 0x2a, 0x0a,
+	0x06, 0x62, 0x6f, 0x75, 0x6e, 0x64, 0x73, 0x18, 0x04, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x12, 0x2e,
+	0x76, 0x64, 0x73, 0x73, 0x6c, 0x69, 0x63, 0x65, 0x2e, 0x76, 0x31, 0x2e, 0x42, 0x6f, 0x75, 0x6e,
+	0x64, 0x52, 0x06, 0x62, 0x6f, 0x75, 0x6e, 0x64, 0x73, 0x12, 0x22, 0x0a, 0x0a, 0x66, 0x69, 0x6c,
+	0x6c, 0x5f, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x05, 0x20, 0x01, 0x28, 0x02, 0x48, 0x01, 0x52,
+	0x09, 0x66, 0x69, 0x6c, 0x6c, 0x56, 0x61, 0x6c, 0x75, 0x65, 0x88, 0x01, 0x01, 0x12, 0x23, 0x0a,
+	0x0d, 0x76, 0x65, 0x72, 0x74, 0x69, 0x63, 0x61, 0x6c, 0x5f, 0x75, 0x6e, 0x69, 0x74, 0x18, 0x06,
+	0x20, 0x01, 0x28, 0x09, 0x52, 0x0c, 0x76, 0x65, 0x72, 0x74, 0x69, 0x63, 0x61, 0x6c, 0x55, 0x6e,
+	0x69, 0x74, 0x42, 0x09, 0x0a, 0x07, 0x5f, 0x6c, 0x69, 0x6e, 0x65, 0x6e, 0x6f, 0x42, 0x0d, 0x0a,
+	0x0b, 0x5f, 0x66, 0x69, 0x6c, 0x6c, 0x5f, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x22, 0x28, 0x0a, 0x0a,
+	0x43, 0x6f, 0x6f, 0x72, 0x64, 0x69, 0x6e, 0x61, 0x74, 0x65, 0x12, 0x0c, 0x0a, 0x01, 0x78, 0x18,
+	0x01, 0x20, 0x01, 0x28, 0x02, 0x52, 0x01, 0x78, 0x12, 0x0c, 0x0a, 0x01, 0x79, 0x18, 0x02, 0x20,
+	0x01, 0x28, 0x02, 0x52, 0x01, 0x79, 0x22, 0xb0, 0x02, 0x0a, 0x0c, 0x46, 0x65, 0x6e, 0x63, 0x65,
+	0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x3a, 0x0a, 0x08, 0x72, 0x65, 0x73, 0x6f, 0x75,
+	0x72, 0x63, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1e, 0x2e, 0x76, 0x64, 0x73, 0x73,
+	0x6c, 0x69, 0x63, 0x65, 0x2e, 0x76, 0x31, 0x2e, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x65,
+	0x64, 0x52, 0x65, 0x73, 0x6f, 0x75, 0x72, 0x63, 0x65, 0x52, 0x08, 0x72, 0x65, 0x73, 0x6f, 0x75,
+	0x72, 0x63, 0x65, 0x12, 0x2b, 0x0a, 0x11, 0x63, 0x6f, 0x6f, 0x72, 0x64, 0x69, 0x6e, 0x61, 0x74,
+	0x65, 0x5f, 0x73, 0x79, 0x73, 0x74, 0x65, 0x6d, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x10,
+	0x63, 0x6f, 0x6f, 0x72, 0x64, 0x69, 0x6e, 0x61, 0x74, 0x65, 0x53, 0x79, 0x73, 0x74, 0x65, 0x6d,
+	0x12, 0x39, 0x0a, 0x0b, 0x63, 0x6f, 0x6f, 0x72, 0x64, 0x69, 0x6e, 0x61, 0x74, 0x65, 0x73, 0x18,
+	0x03, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x17, 0x2e, 0x76, 0x64, 0x73, 0x73, 0x6c, 0x69, 0x63, 0x65,
+	0x2e, 0x76, 0x31, 0x2e, 0x43, 0x6f, 0x6f, 0x72, 0x64, 0x69, 0x6e, 0x61, 0x74, 0x65, 0x52, 0x0b,
+	0x63, 0x6f, 0x6f, 0x72, 0x64, 0x69, 0x6e, 0x61, 0x74, 0x65, 0x73, 0x12, 0x24, 0x0a, 0x0d, 0x69,
+	0x6e, 0x74, 0x65, 0x72, 0x70, 0x6f, 0x6c, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x18, 0x04, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x0d, 0x69, 0x6e, 0x74, 0x65, 0x72, 0x70, 0x6f, 0x6c, 0x61, 0x74, 0x69, 0x6f,
+	0x6e, 0x12, 0x22, 0x0a, 0x0a, 0x66, 0x69, 0x6c, 0x6c, 0x5f, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18,
+	0x05, 0x20, 0x01, 0x28, 0x02, 0x48, 0x00, 0x52, 0x09, 0x66, 0x69, 0x6c, 0x6c, 0x56, 0x61, 0x6c,
+	0x75, 0x65, 0x88, 0x01, 0x01, 0x12, 0x23, 0x0a, 0x0d, 0x76, 0x65, 0x72, 0x74, 0x69, 0x63, 0x61,
+	0x6c, 0x5f, 0x75, 0x6e, 0x69, 0x74, 0x18, 0x06, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0c, 0x76, 0x65,
+	0x72, 0x74, 0x69, 0x63, 0x61, 0x6c, 0x55, 0x6e, 0x69, 0x74, 0x42, 0x0d, 0x0a, 0x0b, 0x5f, 0x66,
+	0x69, 0x6c, 0x6c, 0x5f, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x22, 0x24, 0x0a, 0x0a, 0x53, 0x75, 0x72,
+	0x66, 0x61, 0x63, 0x65, 0x52, 0x6f, 0x77, 0x12, 0x16, 0x0a, 0x06, 0x76, 0x61, 0x6c, 0x75, 0x65,
+	0x73, 0x18, 0x01, 0x20, 0x03, 0x28, 0x02, 0x52, 0x06, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x73, 0x22,
+	0x8e, 0x02, 0x0a, 0x0e, 0x52, 0x65, 0x67, 0x75, 0x6c, 0x61, 0x72, 0x53, 0x75, 0x72, 0x66, 0x61,
+	0x63, 0x65, 0x12, 0x2f, 0x0a, 0x06, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x73, 0x18, 0x01, 0x20, 0x03,
+	0x28, 0x0b, 0x32, 0x17, 0x2e, 0x76, 0x64, 0x73, 0x73, 0x6c, 0x69, 0x63, 0x65, 0x2e, 0x76, 0x31,
+	0x2e, 0x53, 0x75, 0x72, 0x66, 0x61, 0x63, 0x65, 0x52, 0x6f, 0x77, 0x52, 0x06, 0x76, 0x61, 0x6c,
+	0x75, 0x65, 0x73, 0x12, 0x1f, 0x0a, 0x08, 0x72, 0x6f, 0x74, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x18,
+	0x02, 0x20, 0x01, 0x28, 0x02, 0x48, 0x00, 0x52, 0x08, 0x72, 0x6f, 0x74, 0x61, 0x74, 0x69, 0x6f,
+	0x6e, 0x88, 0x01, 0x01, 0x12, 0x17, 0x0a, 0x04, 0x78, 0x6f, 0x72, 0x69, 0x18, 0x03, 0x20, 0x01,
+	0x28, 0x02, 0x48, 0x01, 0x52, 0x04, 0x78, 0x6f, 0x72, 0x69, 0x88, 0x01, 0x01, 0x12, 0x17, 0x0a,
+	0x04, 0x79, 0x6f, 0x72, 0x69, 0x18, 0x04, 0x20, 0x01, 0x28, 0x02, 0x48, 0x02, 0x52, 0x04, 0x79,
+	0x6f, 0x72, 0x69, 0x88, 0x01, 0x01, 0x12, 0x12, 0x0a, 0x04, 0x78, 0x69, 0x6e, 0x63, 0x18, 0x05,
+	0x20, 0x01, 0x28, 0x02, 0x52, 0x04, 0x78, 0x69, 0x6e, 0x63, 0x12, 0x12, 0x0a, 0x04, 0x79, 0x69,
+	0x6e, 0x63, 0x18, 0x06, 0x20, 0x01, 0x28, 0x02, 0x52, 0x04, 0x79, 0x69, 0x6e, 0x63, 0x12, 0x22,
+	0x0a, 0x0a, 0x66, 0x69, 0x6c, 0x6c, 0x5f, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x07, 0x20, 0x01,
+	0x28, 0x02, 0x48, 0x03, 0x52, 0x09, 0x66, 0x69, 0x6c, 0x6c, 0x56, 0x61, 0x6c, 0x75, 0x65, 0x88,
+	0x01, 0x01, 0x42, 0x0b, 0x0a, 0x09, 0x5f, 0x72, 0x6f, 0x74, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x42,
+	0x07, 0x0a, 0x05, 0x5f, 0x78, 0x6f, 0x72, 0x69, 0x42, 0x07, 0x0a, 0x05, 0x5f, 0x79, 0x6f, 0x72,
+	0x69, 0x42, 0x0d, 0x0a, 0x0b, 0x5f, 0x66, 0x69, 0x6c, 0x6c, 0x5f, 0x76, 0x61, 0x6c, 0x75, 0x65,
+	0x22, 0xfb, 0x02, 0x0a, 0x1c, 0x41, 0x74, 0x74, 0x72, 0x69, 0x62, 0x75, 0x74, 0x65, 0x41, 0x6c,
+	0x6f, 0x6e, 0x67, 0x53, 0x75, 0x72, 0x66, 0x61, 0x63, 0x65, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73,
+	0x74, 0x12, 0x3a, 0x0a, 0x08, 0x72, 0x65, 0x73, 0x6f, 0x75, 0x72, 0x63, 0x65, 0x18, 0x01, 0x20,
+	0x01, 0x28, 0x0b, 0x32, 0x1e, 0x2e, 0x76, 0x64, 0x73, 0x73, 0x6c, 0x69, 0x63, 0x65, 0x2e, 0x76,
+	0x31, 0x2e, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x65, 0x64, 0x52, 0x65, 0x73, 0x6f, 0x75,
+	0x72, 0x63, 0x65, 0x52, 0x08, 0x72, 0x65, 0x73, 0x6f, 0x75, 0x72, 0x63, 0x65, 0x12, 0x35, 0x0a,
+	0x07, 0x73, 0x75, 0x72, 0x66, 0x61, 0x63, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1b,
+	0x2e, 0x76, 0x64, 0x73, 0x73, 0x6c, 0x69, 0x63, 0x65, 0x2e, 0x76, 0x31, 0x2e, 0x52, 0x65, 0x67,
+	0x75, 0x6c, 0x61, 0x72, 0x53, 0x75, 0x72, 0x66, 0x61, 0x63, 0x65, 0x52, 0x07, 0x73, 0x75, 0x72,
+	0x66, 0x61, 0x63, 0x65, 0x12, 0x14, 0x0a, 0x05, 0x61, 0x62, 0x6f, 0x76, 0x65, 0x18, 0x03, 0x20,
+	0x01, 0x28, 0x02, 0x52, 0x05, 0x61, 0x62, 0x6f, 0x76, 0x65, 0x12, 0x14, 0x0a, 0x05, 0x62, 0x65,
+	0x6c, 0x6f, 0x77, 0x18, 0x04, 0x20, 0x01, 0x28, 0x02, 0x52, 0x05, 0x62, 0x65, 0x6c, 0x6f, 0x77,
+	0x12, 0x1a, 0x0a, 0x08, 0x73, 0x74, 0x65, 0x70, 0x73, 0x69, 0x7a, 0x65, 0x18, 0x05, 0x20, 0x01,
+	0x28, 0x02, 0x52, 0x08, 0x73, 0x74, 0x65, 0x70, 0x73, 0x69, 0x7a, 0x65, 0x12, 0x24, 0x0a, 0x0d,
+	0x69, 0x6e, 0x74, 0x65, 0x72, 0x70, 0x6f, 0x6c, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x18, 0x06, 0x20,
+	0x01, 0x28, 0x09, 0x52, 0x0d, 0x69, 0x6e, 0x74, 0x65, 0x72, 0x70, 0x6f, 0x6c, 0x61, 0x74, 0x69,
+	0x6f, 0x6e, 0x12, 0x1e, 0x0a, 0x0a, 0x61, 0x74, 0x74, 0x72, 0x69, 0x62, 0x75, 0x74, 0x65, 0x73,
+	0x18, 0x07, 0x20, 0x03, 0x28, 0x09, 0x52, 0x0a, 0x61, 0x74, 0x74, 0x72, 0x69, 0x62, 0x75, 0x74,
+	0x65, 0x73, 0x12, 0x35, 0x0a, 0x16, 0x76, 0x65, 0x72, 0x74, 0x69, 0x63, 0x61, 0x6c, 0x5f, 0x69,
+	0x6e, 0x74, 0x65, 0x72, 0x70, 0x6f, 0x6c, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x18, 0x08, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x15, 0x76, 0x65, 0x72, 0x74, 0x69, 0x63, 0x61, 0x6c, 0x49, 0x6e, 0x74, 0x65,
+	0x72, 0x70, 0x6f, 0x6c, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x23, 0x0a, 0x0d, 0x76, 0x65, 0x72,
+	0x74, 0x69, 0x63, 0x61, 0x6c, 0x5f, 0x75, 0x6e, 0x69, 0x74, 0x18, 0x09, 0x20, 0x01, 0x28, 0x09,
+	0x52, 0x0c, 0x76, 0x65, 0x72, 0x74, 0x69, 0x63, 0x61, 0x6c, 0x55, 0x6e, 0x69, 0x74, 0x22, 0xab,
+	0x03, 0x0a, 0x1f, 0x41, 0x74, 0x74, 0x72, 0x69, 0x62, 0x75, 0x74, 0x65, 0x42, 0x65, 0x74, 0x77,
+	0x65, 0x65, 0x6e, 0x53, 0x75, 0x72, 0x66, 0x61, 0x63, 0x65, 0x73, 0x52, 0x65, 0x71, 0x75, 0x65,
+	0x73, 0x74, 0x12, 0x3a, 0x0a, 0x08, 0x72, 0x65, 0x73, 0x6f, 0x75, 0x72, 0x63, 0x65, 0x18, 0x01,
+	0x20, 0x01, 0x28, 0x0b, 0x32, 0x1e, 0x2e, 0x76, 0x64, 0x73, 0x73, 0x6c, 0x69, 0x63, 0x65, 0x2e,
+	0x76, 0x31, 0x2e, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x65, 0x64, 0x52, 0x65, 0x73, 0x6f,
+	0x75, 0x72, 0x63, 0x65, 0x52, 0x08, 0x72, 0x65, 0x73, 0x6f, 0x75, 0x72, 0x63, 0x65, 0x12, 0x44,
+	0x0a, 0x0f, 0x70, 0x72, 0x69, 0x6d, 0x61, 0x72, 0x79, 0x5f, 0x73, 0x75, 0x72, 0x66, 0x61, 0x63,
+	0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1b, 0x2e, 0x76, 0x64, 0x73, 0x73, 0x6c, 0x69,
+	0x63, 0x65, 0x2e, 0x76, 0x31, 0x2e, 0x52, 0x65, 0x67, 0x75, 0x6c, 0x61, 0x72, 0x53, 0x75, 0x72,
+	0x66, 0x61, 0x63, 0x65, 0x52, 0x0e, 0x70, 0x72, 0x69, 0x6d, 0x61, 0x72, 0x79, 0x53, 0x75, 0x72,
+	0x66, 0x61, 0x63, 0x65, 0x12, 0x48, 0x0a, 0x11, 0x73, 0x65, 0x63, 0x6f, 0x6e, 0x64, 0x61, 0x72,
+	0x79, 0x5f, 0x73, 0x75, 0x72, 0x66, 0x61, 0x63, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0b, 0x32,
+	0x1b, 0x2e, 0x76, 0x64, 0x73, 0x73, 0x6c, 0x69, 0x63, 0x65, 0x2e, 0x76, 0x31, 0x2e, 0x52, 0x65,
+	0x67, 0x75, 0x6c, 0x61, 0x72, 0x53, 0x75, 0x72, 0x66, 0x61, 0x63, 0x65, 0x52, 0x10, 0x73, 0x65,
+	0x63, 0x6f, 0x6e, 0x64, 0x61, 0x72, 0x79, 0x53, 0x75, 0x72, 0x66, 0x61, 0x63, 0x65, 0x12, 0x1a,
+	0x0a, 0x08, 0x73, 0x74, 0x65, 0x70, 0x73, 0x69, 0x7a, 0x65, 0x18, 0x04, 0x20, 0x01, 0x28, 0x02,
+	0x52, 0x08, 0x73, 0x74, 0x65, 0x70, 0x73, 0x69, 0x7a, 0x65, 0x12, 0x24, 0x0a, 0x0d, 0x69, 0x6e,
+	0x74, 0x65, 0x72, 0x70, 0x6f, 0x6c, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x18, 0x05, 0x20, 0x01, 0x28,
+	0x09, 0x52, 0x0d, 0x69, 0x6e, 0x74, 0x65, 0x72, 0x70, 0x6f, 0x6c, 0x61, 0x74, 0x69, 0x6f, 0x6e,
+	0x12, 0x1e, 0x0a, 0x0a, 0x61, 0x74, 0x74, 0x72, 0x69, 0x62, 0x75, 0x74, 0x65, 0x73, 0x18, 0x06,
+	0x20, 0x03, 0x28, 0x09, 0x52, 0x0a, 0x61, 0x74, 0x74, 0x72, 0x69, 0x62, 0x75, 0x74, 0x65, 0x73,
+	0x12, 0x35, 0x0a, 0x16, 0x76, 0x65, 0x72, 0x74, 0x69, 0x63, 0x61, 0x6c, 0x5f, 0x69, 0x6e, 0x74,
+	0x65, 0x72, 0x70, 0x6f, 0x6c, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x18, 0x07, 0x20, 0x01, 0x28, 0x09,
+	0x52, 0x15, 0x76, 0x65, 0x72, 0x74, 0x69, 0x63, 0x61, 0x6c, 0x49, 0x6e, 0x74, 0x65, 0x72, 0x70,
+	0x6f, 0x6c, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x23, 0x0a, 0x0d, 0x76, 0x65, 0x72, 0x74, 0x69,
+	0x63, 0x61, 0x6c, 0x5f, 0x75, 0x6e, 0x69, 0x74, 0x18, 0x08, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0c,
+	0x76, 0x65, 0x72, 0x74, 0x69, 0x63, 0x61, 0x6c, 0x55, 0x6e, 0x69, 0x74, 0x22, 0x33, 0x0a, 0x09,
+	0x44, 0x61, 0x74, 0x61, 0x43, 0x68, 0x75, 0x6e, 0x6b, 0x12, 0x12, 0x0a, 0x04, 0x70, 0x61, 0x72,
+	0x74, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x04, 0x70, 0x61, 0x72, 0x74, 0x12, 0x12, 0x0a,
+	0x04, 0x64, 0x61, 0x74, 0x61, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x04, 0x64, 0x61, 0x74,
+	0x61, 0x22, 0x66, 0x0a, 0x0c, 0x44, 0x61, 0x74, 0x61, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73,
+	0x65, 0x12, 0x1c, 0x0a, 0x08, 0x6d, 0x65, 0x74, 0x61, 0x64, 0x61, 0x74, 0x61, 0x18, 0x01, 0x20,
+	0x01, 0x28, 0x09, 0x48, 0x00, 0x52, 0x08, 0x6d, 0x65, 0x74, 0x61, 0x64, 0x61, 0x74, 0x61, 0x12,
+	0x2c, 0x0a, 0x04, 0x64, 0x61, 0x74, 0x61, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x16, 0x2e,
+	0x76, 0x64, 0x73, 0x73, 0x6c, 0x69, 0x63, 0x65, 0x2e, 0x76, 0x31, 0x2e, 0x44, 0x61, 0x74, 0x61,
+	0x43, 0x68, 0x75, 0x6e, 0x6b, 0x48, 0x00, 0x52, 0x04, 0x64, 0x61, 0x74, 0x61, 0x42, 0x0a, 0x0a,
+	0x08, 0x72, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x32, 0x9f, 0x03, 0x0a, 0x08, 0x56, 0x64,
+	0x73, 0x53, 0x6c, 0x69, 0x63, 0x65, 0x12, 0x47, 0x0a, 0x08, 0x4d, 0x65, 0x74, 0x61, 0x64, 0x61,
+	0x74, 0x61, 0x12, 0x1c, 0x2e, 0x76, 0x64, 0x73, 0x73, 0x6c, 0x69, 0x63, 0x65, 0x2e, 0x76, 0x31,
+	0x2e, 0x4d, 0x65, 0x74, 0x61, 0x64, 0x61, 0x74, 0x61, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74,
+	0x1a, 0x1d, 0x2e, 0x76, 0x64, 0x73, 0x73, 0x6c, 0x69, 0x63, 0x65, 0x2e, 0x76, 0x31, 0x2e, 0x4d,
+	0x65, 0x74, 0x61, 0x64, 0x61, 0x74, 0x61, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12,
+	0x3f, 0x0a, 0x05, 0x53, 0x6c, 0x69, 0x63, 0x65, 0x12, 0x19, 0x2e, 0x76, 0x64, 0x73, 0x73, 0x6c,
+	0x69, 0x63, 0x65, 0x2e, 0x76, 0x31, 0x2e, 0x53, 0x6c, 0x69, 0x63, 0x65, 0x52, 0x65, 0x71, 0x75,
+	0x65, 0x73, 0x74, 0x1a, 0x19, 0x2e, 0x76, 0x64, 0x73, 0x73, 0x6c, 0x69, 0x63, 0x65, 0x2e, 0x76,
+	0x31, 0x2e, 0x44, 0x61, 0x74, 0x61, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x30, 0x01,
+	0x12, 0x3f, 0x0a, 0x05, 0x46, 0x65, 0x6e, 0x63, 0x65, 0x12, 0x19, 0x2e, 0x76, 0x64, 0x73, 0x73,
+	0x6c, 0x69, 0x63, 0x65, 0x2e, 0x76, 0x31, 0x2e, 0x46, 0x65, 0x6e, 0x63, 0x65, 0x52, 0x65, 0x71,
+	0x75, 0x65, 0x73, 0x74, 0x1a, 0x19, 0x2e, 0x76, 0x64, 0x73, 0x73, 0x6c, 0x69, 0x63, 0x65, 0x2e,
+	0x76, 0x31, 0x2e, 0x44, 0x61, 0x74, 0x61, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x30,
+	0x01, 0x12, 0x60, 0x0a, 0x16, 0x41, 0x74, 0x74, 0x72, 0x69, 0x62, 0x75, 0x74, 0x65, 0x73, 0x41,
+	0x6c, 0x6f, 0x6e, 0x67, 0x53, 0x75, 0x72, 0x66, 0x61, 0x63, 0x65, 0x12, 0x29, 0x2e, 0x76, 0x64,
+	0x73, 0x73, 0x6c, 0x69, 0x63, 0x65, 0x2e, 0x76, 0x31, 0x2e, 0x41, 0x74, 0x74, 0x72, 0x69, 0x62,
+	0x75, 0x74, 0x65, 0x41, 0x6c, 0x6f, 0x6e, 0x67, 0x53, 0x75, 0x72, 0x66, 0x61, 0x63, 0x65, 0x52,
+	0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x19, 0x2e, 0x76, 0x64, 0x73, 0x73, 0x6c, 0x69, 0x63,
+	0x65, 0x2e, 0x76, 0x31, 0x2e, 0x44, 0x61, 0x74, 0x61, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73,
+	0x65, 0x30, 0x01, 0x12, 0x66, 0x0a, 0x19, 0x41, 0x74, 0x74, 0x72, 0x69, 0x62, 0x75, 0x74, 0x65,
+	0x73, 0x42, 0x65, 0x74, 0x77, 0x65, 0x65, 0x6e, 0x53, 0x75, 0x72, 0x66, 0x61, 0x63, 0x65, 0x73,
+	0x12, 0x2c, 0x2e, 0x76, 0x64, 0x73, 0x73, 0x6c, 0x69, 0x63, 0x65, 0x2e, 0x76, 0x31, 0x2e, 0x41,
+	0x74, 0x74, 0x72, 0x69, 0x62, 0x75, 0x74, 0x65, 0x42, 0x65, 0x74, 0x77, 0x65, 0x65, 0x6e, 0x53,
+	0x75, 0x72, 0x66, 0x61, 0x63, 0x65, 0x73, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x19,
+	0x2e, 0x76, 0x64, 0x73, 0x73, 0x6c, 0x69, 0x63, 0x65, 0x2e, 0x76, 0x31, 0x2e, 0x44, 0x61, 0x74,
+	0x61, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x30, 0x01, 0x42, 0x2d, 0x5a, 0x2b, 0x67,
+	0x69, 0x74, 0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x65, 0x71, 0x75, 0x69, 0x6e, 0x6f,
+	0x72, 0x2f, 0x76, 0x64, 0x73, 0x2d, 0x73, 0x6c, 0x69, 0x63, 0x65, 0x2f, 0x61, 0x70, 0x69, 0x2f,
+	0x76, 0x64, 0x73, 0x73, 0x6c, 0x69, 0x63, 0x65, 0x70, 0x62, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74,
+	0x6f, 0x33,
+}
+
+var (
+	file_api_vdsslicepb_vdsslice_proto_rawDescOnce sync.Once
+	file_api_vdsslicepb_vdsslice_proto_rawDescData = file_api_vdsslicepb_vdsslice_proto_rawDesc
+)
+
+func file_api_vdsslicepb_vdsslice_proto_rawDescGZIP() []byte {
+	file_api_vdsslicepb_vdsslice_proto_rawDescOnce.Do(func() {
+		file_api_vdsslicepb_vdsslice_proto_rawDescData = protoimpl.X.CompressGZIP(file_api_vdsslicepb_vdsslice_proto_rawDescData)
+	})
+	return file_api_vdsslicepb_vdsslice_proto_rawDescData
+}
+
+var file_api_vdsslicepb_vdsslice_proto_msgTypes = make([]protoimpl.MessageInfo, 14)
+var file_api_vdsslicepb_vdsslice_proto_goTypes = []interface{}{
+	(*S3Options)(nil),                       // 0: vdsslice.v1.S3Options
+	(*RequestedResource)(nil),               // 1: vdsslice.v1.RequestedResource
+	(*MetadataRequest)(nil),                 // 2: vdsslice.v1.MetadataRequest
+	(*MetadataResponse)(nil),                // 3: vdsslice.v1.MetadataResponse
+	(*Bound)(nil),                           // 4: vdsslice.v1.Bound
+	(*SliceRequest)(nil),                    // 5: vdsslice.v1.SliceRequest
+	(*Coordinate)(nil),                      // 6: vdsslice.v1.Coordinate
+	(*FenceRequest)(nil),                    // 7: vdsslice.v1.FenceRequest
+	(*SurfaceRow)(nil),                      // 8: vdsslice.v1.SurfaceRow
+	(*RegularSurface)(nil),                  // 9: vdsslice.v1.RegularSurface
+	(*AttributeAlongSurfaceRequest)(nil),    // 10: vdsslice.v1.AttributeAlongSurfaceRequest
+	(*AttributeBetweenSurfacesRequest)(nil), // 11: vdsslice.v1.AttributeBetweenSurfacesRequest
+	(*DataChunk)(nil),                       // 12: vdsslice.v1.DataChunk
+	(*DataResponse)(nil),                    // 13: vdsslice.v1.DataResponse
+}
+var file_api_vdsslicepb_vdsslice_proto_depIdxs = []int32{
+	0,  // 0: vdsslice.v1.RequestedResource.s3:type_name -> vdsslice.v1.S3Options
+	1,  // 1: vdsslice.v1.MetadataRequest.resource:type_name -> vdsslice.v1.RequestedResource
+	1,  // 2: vdsslice.v1.SliceRequest.resource:type_name -> vdsslice.v1.RequestedResource
+	4,  // 3: vdsslice.v1.SliceRequest.bounds:type_name -> vdsslice.v1.Bound
+	1,  // 4: vdsslice.v1.FenceRequest.resource:type_name -> vdsslice.v1.RequestedResource
+	6,  // 5: vdsslice.v1.FenceRequest.coordinates:type_name -> vdsslice.v1.Coordinate
+	8,  // 6: vdsslice.v1.RegularSurface.values:type_name -> vdsslice.v1.SurfaceRow
+	1,  // 7: vdsslice.v1.AttributeAlongSurfaceRequest.resource:type_name -> vdsslice.v1.RequestedResource
+	9,  // 8: vdsslice.v1.AttributeAlongSurfaceRequest.surface:type_name -> vdsslice.v1.RegularSurface
+	1,  // 9: vdsslice.v1.AttributeBetweenSurfacesRequest.resource:type_name -> vdsslice.v1.RequestedResource
+	9,  // 10: vdsslice.v1.AttributeBetweenSurfacesRequest.primary_surface:type_name -> vdsslice.v1.RegularSurface
+	9,  // 11: vdsslice.v1.AttributeBetweenSurfacesRequest.secondary_surface:type_name -> vdsslice.v1.RegularSurface
+	12, // 12: vdsslice.v1.DataResponse.data:type_name -> vdsslice.v1.DataChunk
+	2,  // 13: vdsslice.v1.VdsSlice.Metadata:input_type -> vdsslice.v1.MetadataRequest
+	5,  // 14: vdsslice.v1.VdsSlice.Slice:input_type -> vdsslice.v1.SliceRequest
+	7,  // 15: vdsslice.v1.VdsSlice.Fence:input_type -> vdsslice.v1.FenceRequest
+	10, // 16: vdsslice.v1.VdsSlice.AttributesAlongSurface:input_type -> vdsslice.v1.AttributeAlongSurfaceRequest
+	11, // 17: vdsslice.v1.VdsSlice.AttributesBetweenSurfaces:input_type -> vdsslice.v1.AttributeBetweenSurfacesRequest
+	3,  // 18: vdsslice.v1.VdsSlice.Metadata:output_type -> vdsslice.v1.MetadataResponse
+	13, // 19: vdsslice.v1.VdsSlice.Slice:output_type -> vdsslice.v1.DataResponse
+	13, // 20: vdsslice.v1.VdsSlice.Fence:output_type -> vdsslice.v1.DataResponse
+	13, // 21: vdsslice.v1.VdsSlice.AttributesAlongSurface:output_type -> vdsslice.v1.DataResponse
+	13, // 22: vdsslice.v1.VdsSlice.AttributesBetweenSurfaces:output_type -> vdsslice.v1.DataResponse
+	18, // [18:23] is the sub-list for method output_type
+	13, // [13:18] is the sub-list for method input_type
+	13, // [13:13] is the sub-list for extension type_name
+	13, // [13:13] is the sub-list for extension extendee
+	0,  // [0:13] is the sub-list for field type_name
+}
+
+func init() { file_api_vdsslicepb_vdsslice_proto_init() }
+func file_api_vdsslicepb_vdsslice_proto_init() {
+	if File_api_vdsslicepb_vdsslice_proto != nil {
+		return
+	}
+	if !protoimpl.UnsafeEnabled {
+		file_api_vdsslicepb_vdsslice_proto_msgTypes[0].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*S3Options); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_api_vdsslicepb_vdsslice_proto_msgTypes[1].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*RequestedResource); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_api_vdsslicepb_vdsslice_proto_msgTypes[2].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*MetadataRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_api_vdsslicepb_vdsslice_proto_msgTypes[3].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*MetadataResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_api_vdsslicepb_vdsslice_proto_msgTypes[4].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Bound); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_api_vdsslicepb_vdsslice_proto_msgTypes[5].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*SliceRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_api_vdsslicepb_vdsslice_proto_msgTypes[6].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Coordinate); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_api_vdsslicepb_vdsslice_proto_msgTypes[7].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*FenceRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_api_vdsslicepb_vdsslice_proto_msgTypes[8].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*SurfaceRow); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_api_vdsslicepb_vdsslice_proto_msgTypes[9].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*RegularSurface); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_api_vdsslicepb_vdsslice_proto_msgTypes[10].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*AttributeAlongSurfaceRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_api_vdsslicepb_vdsslice_proto_msgTypes[11].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*AttributeBetweenSurfacesRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_api_vdsslicepb_vdsslice_proto_msgTypes[12].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*DataChunk); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_api_vdsslicepb_vdsslice_proto_msgTypes[13].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*DataResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+	}
+	file_api_vdsslicepb_vdsslice_proto_msgTypes[5].OneofWrappers = []interface{}{}
+	file_api_vdsslicepb_vdsslice_proto_msgTypes[7].OneofWrappers = []interface{}{}
+	file_api_vdsslicepb_vdsslice_proto_msgTypes[9].OneofWrappers = []interface{}{}
+	file_api_vdsslicepb_vdsslice_proto_msgTypes[13].OneofWrappers = []interface{}{
+		(*DataResponse_Metadata)(nil),
+		(*DataResponse_Data)(nil),
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: file_api_vdsslicepb_vdsslice_proto_rawDesc,
+			NumEnums:      0,
+			NumMessages:   14,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_api_vdsslicepb_vdsslice_proto_goTypes,
+		DependencyIndexes: file_api_vdsslicepb_vdsslice_proto_depIdxs,
+		MessageInfos:      file_api_vdsslicepb_vdsslice_proto_msgTypes,
+	}.Build()
+	File_api_vdsslicepb_vdsslice_proto = out.File
+	file_api_vdsslicepb_vdsslice_proto_rawDesc = nil
+	file_api_vdsslicepb_vdsslice_proto_goTypes = nil
+	file_api_vdsslicepb_vdsslice_proto_depIdxs = nil
+}
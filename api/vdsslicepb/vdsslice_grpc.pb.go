@@ -0,0 +1,391 @@
+//
+// gRPC facade for the vds-slice server.
+//
+// The messages mirror the json requests of the http API, and the fields have
+// the same meaning and validation. See the swagger documentation of the http
+// API for the details.
+//
+// Credentials are given either in the sas field, or as request metadata: a
+// sas-token in 'x-sas-token', or an Azure AD access token as
+// 'authorization: Bearer <token>'.
+//
+// Regenerate the go code with:
+//
+//     protoc --go_out=. --go_opt=paths=source_relative \
+//            --go-grpc_out=. --go-grpc_opt=paths=source_relative \
+//            api/vdsslicepb/vdsslice.proto
+
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             (unknown)
+// source: api/vdsslicepb/vdsslice.proto
+
+package vdsslicepb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	VdsSlice_Metadata_FullMethodName                  = "/vdsslice.v1.VdsSlice/Metadata"
+	VdsSlice_Slice_FullMethodName                     = "/vdsslice.v1.VdsSlice/Slice"
+	VdsSlice_Fence_FullMethodName                     = "/vdsslice.v1.VdsSlice/Fence"
+	VdsSlice_AttributesAlongSurface_FullMethodName    = "/vdsslice.v1.VdsSlice/AttributesAlongSurface"
+	VdsSlice_AttributesBetweenSurfaces_FullMethodName = "/vdsslice.v1.VdsSlice/AttributesBetweenSurfaces"
+)
+
+// VdsSliceClient is the client API for VdsSlice service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type VdsSliceClient interface {
+	Metadata(ctx context.Context, in *MetadataRequest, opts ...grpc.CallOption) (*MetadataResponse, error)
+	//
+	// Data responses are streamed. The first message holds the metadata,
+	// followed by the data in chunks, such that no message exceeds the
+	// default max message size of 4 MB.
+	Slice(ctx context.Context, in *SliceRequest, opts ...grpc.CallOption) (VdsSlice_SliceClient, error)
+	Fence(ctx context.Context, in *FenceRequest, opts ...grpc.CallOption) (VdsSlice_FenceClient, error)
+	AttributesAlongSurface(ctx context.Context, in *AttributeAlongSurfaceRequest, opts ...grpc.CallOption) (VdsSlice_AttributesAlongSurfaceClient, error)
+	AttributesBetweenSurfaces(ctx context.Context, in *AttributeBetweenSurfacesRequest, opts ...grpc.CallOption) (VdsSlice_AttributesBetweenSurfacesClient, error)
+}
+
+type vdsSliceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewVdsSliceClient(cc grpc.ClientConnInterface) VdsSliceClient {
+	return &vdsSliceClient{cc}
+}
+
+func (c *vdsSliceClient) Metadata(ctx context.Context, in *MetadataRequest, opts ...grpc.CallOption) (*MetadataResponse, error) {
+	out := new(MetadataResponse)
+	err := c.cc.Invoke(ctx, VdsSlice_Metadata_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *vdsSliceClient) Slice(ctx context.Context, in *SliceRequest, opts ...grpc.CallOption) (VdsSlice_SliceClient, error) {
+	stream, err := c.cc.NewStream(ctx, &VdsSlice_ServiceDesc.Streams[0], VdsSlice_Slice_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &vdsSliceSliceClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type VdsSlice_SliceClient interface {
+	Recv() (*DataResponse, error)
+	grpc.ClientStream
+}
+
+type vdsSliceSliceClient struct {
+	grpc.ClientStream
+}
+
+func (x *vdsSliceSliceClient) Recv() (*DataResponse, error) {
+	m := new(DataResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *vdsSliceClient) Fence(ctx context.Context, in *FenceRequest, opts ...grpc.CallOption) (VdsSlice_FenceClient, error) {
+	stream, err := c.cc.NewStream(ctx, &VdsSlice_ServiceDesc.Streams[1], VdsSlice_Fence_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &vdsSliceFenceClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type VdsSlice_FenceClient interface {
+	Recv() (*DataResponse, error)
+	grpc.ClientStream
+}
+
+type vdsSliceFenceClient struct {
+	grpc.ClientStream
+}
+
+func (x *vdsSliceFenceClient) Recv() (*DataResponse, error) {
+	m := new(DataResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *vdsSliceClient) AttributesAlongSurface(ctx context.Context, in *AttributeAlongSurfaceRequest, opts ...grpc.CallOption) (VdsSlice_AttributesAlongSurfaceClient, error) {
+	stream, err := c.cc.NewStream(ctx, &VdsSlice_ServiceDesc.Streams[2], VdsSlice_AttributesAlongSurface_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &vdsSliceAttributesAlongSurfaceClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type VdsSlice_AttributesAlongSurfaceClient interface {
+	Recv() (*DataResponse, error)
+	grpc.ClientStream
+}
+
+type vdsSliceAttributesAlongSurfaceClient struct {
+	grpc.ClientStream
+}
+
+func (x *vdsSliceAttributesAlongSurfaceClient) Recv() (*DataResponse, error) {
+	m := new(DataResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *vdsSliceClient) AttributesBetweenSurfaces(ctx context.Context, in *AttributeBetweenSurfacesRequest, opts ...grpc.CallOption) (VdsSlice_AttributesBetweenSurfacesClient, error) {
+	stream, err := c.cc.NewStream(ctx, &VdsSlice_ServiceDesc.Streams[3], VdsSlice_AttributesBetweenSurfaces_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &vdsSliceAttributesBetweenSurfacesClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type VdsSlice_AttributesBetweenSurfacesClient interface {
+	Recv() (*DataResponse, error)
+	grpc.ClientStream
+}
+
+type vdsSliceAttributesBetweenSurfacesClient struct {
+	grpc.ClientStream
+}
+
+func (x *vdsSliceAttributesBetweenSurfacesClient) Recv() (*DataResponse, error) {
+	m := new(DataResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// VdsSliceServer is the server API for VdsSlice service.
+// All implementations must embed UnimplementedVdsSliceServer
+// for forward compatibility
+type VdsSliceServer interface {
+	Metadata(context.Context, *MetadataRequest) (*MetadataResponse, error)
+	//
+	// Data responses are streamed. The first message holds the metadata,
+	// followed by the data in chunks, such that no message exceeds the
+	// default max message size of 4 MB.
+	Slice(*SliceRequest, VdsSlice_SliceServer) error
+	Fence(*FenceRequest, VdsSlice_FenceServer) error
+	AttributesAlongSurface(*AttributeAlongSurfaceRequest, VdsSlice_AttributesAlongSurfaceServer) error
+	AttributesBetweenSurfaces(*AttributeBetweenSurfacesRequest, VdsSlice_AttributesBetweenSurfacesServer) error
+	mustEmbedUnimplementedVdsSliceServer()
+}
+
+// UnimplementedVdsSliceServer must be embedded to have forward compatible implementations.
+type UnimplementedVdsSliceServer struct {
+}
+
+func (UnimplementedVdsSliceServer) Metadata(context.Context, *MetadataRequest) (*MetadataResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Metadata not implemented")
+}
+func (UnimplementedVdsSliceServer) Slice(*SliceRequest, VdsSlice_SliceServer) error {
+	return status.Errorf(codes.Unimplemented, "method Slice not implemented")
+}
+func (UnimplementedVdsSliceServer) Fence(*FenceRequest, VdsSlice_FenceServer) error {
+	return status.Errorf(codes.Unimplemented, "method Fence not implemented")
+}
+func (UnimplementedVdsSliceServer) AttributesAlongSurface(*AttributeAlongSurfaceRequest, VdsSlice_AttributesAlongSurfaceServer) error {
+	return status.Errorf(codes.Unimplemented, "method AttributesAlongSurface not implemented")
+}
+func (UnimplementedVdsSliceServer) AttributesBetweenSurfaces(*AttributeBetweenSurfacesRequest, VdsSlice_AttributesBetweenSurfacesServer) error {
+	return status.Errorf(codes.Unimplemented, "method AttributesBetweenSurfaces not implemented")
+}
+func (UnimplementedVdsSliceServer) mustEmbedUnimplementedVdsSliceServer() {}
+
+// UnsafeVdsSliceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to VdsSliceServer will
+// result in compilation errors.
+type UnsafeVdsSliceServer interface {
+	mustEmbedUnimplementedVdsSliceServer()
+}
+
+func RegisterVdsSliceServer(s grpc.ServiceRegistrar, srv VdsSliceServer) {
+	s.RegisterService(&VdsSlice_ServiceDesc, srv)
+}
+
+func _VdsSlice_Metadata_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(MetadataRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(VdsSliceServer).Metadata(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: VdsSlice_Metadata_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(VdsSliceServer).Metadata(ctx, req.(*MetadataRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _VdsSlice_Slice_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(SliceRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(VdsSliceServer).Slice(m, &vdsSliceSliceServer{stream})
+}
+
+type VdsSlice_SliceServer interface {
+	Send(*DataResponse) error
+	grpc.ServerStream
+}
+
+type vdsSliceSliceServer struct {
+	grpc.ServerStream
+}
+
+func (x *vdsSliceSliceServer) Send(m *DataResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _VdsSlice_Fence_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(FenceRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(VdsSliceServer).Fence(m, &vdsSliceFenceServer{stream})
+}
+
+type VdsSlice_FenceServer interface {
+	Send(*DataResponse) error
+	grpc.ServerStream
+}
+
+type vdsSliceFenceServer struct {
+	grpc.ServerStream
+}
+
+func (x *vdsSliceFenceServer) Send(m *DataResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _VdsSlice_AttributesAlongSurface_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(AttributeAlongSurfaceRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(VdsSliceServer).AttributesAlongSurface(m, &vdsSliceAttributesAlongSurfaceServer{stream})
+}
+
+type VdsSlice_AttributesAlongSurfaceServer interface {
+	Send(*DataResponse) error
+	grpc.ServerStream
+}
+
+type vdsSliceAttributesAlongSurfaceServer struct {
+	grpc.ServerStream
+}
+
+func (x *vdsSliceAttributesAlongSurfaceServer) Send(m *DataResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _VdsSlice_AttributesBetweenSurfaces_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(AttributeBetweenSurfacesRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(VdsSliceServer).AttributesBetweenSurfaces(m, &vdsSliceAttributesBetweenSurfacesServer{stream})
+}
+
+type VdsSlice_AttributesBetweenSurfacesServer interface {
+	Send(*DataResponse) error
+	grpc.ServerStream
+}
+
+type vdsSliceAttributesBetweenSurfacesServer struct {
+	grpc.ServerStream
+}
+
+func (x *vdsSliceAttributesBetweenSurfacesServer) Send(m *DataResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+// VdsSlice_ServiceDesc is the grpc.ServiceDesc for VdsSlice service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var VdsSlice_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "vdsslice.v1.VdsSlice",
+	HandlerType: (*VdsSliceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Metadata",
+			Handler:    _VdsSlice_Metadata_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Slice",
+			Handler:       _VdsSlice_Slice_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "Fence",
+			Handler:       _VdsSlice_Fence_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "AttributesAlongSurface",
+			Handler:       _VdsSlice_AttributesAlongSurface_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "AttributesBetweenSurfaces",
+			Handler:       _VdsSlice_AttributesBetweenSurfaces_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "api/vdsslicepb/vdsslice.proto",
+}
@@ -0,0 +1,193 @@
+package api
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"net/url"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/equinor/vds-slice/internal/core"
+)
+
+/* States of a VDS in the warm-up list */
+const (
+	warmupPending = "pending"
+	warmupDone    = "done"
+	warmupFailed  = "failed"
+)
+
+// @Description Warm-up state of a VDS in the warm-up list of the server
+type WarmupStatus struct {
+	// The VDS, without credentials
+	Vds string `json:"vds" example:"https://account.blob.core.windows.net/container/blob"`
+
+	// One of pending, done and failed
+	State string `json:"state" example:"done"`
+
+	// Why warm-up failed, if it did
+	Error string `json:"error,omitempty" example:"The specified blob does not exist."`
+} // @name WarmupStatus
+
+// @Description Readiness of the server
+type ReadyResponse struct {
+	// Whether the server is ready to take requests
+	Ready bool `json:"ready" example:"true"`
+
+	// Warm-up state of every VDS in the warm-up list
+	Warmup []WarmupStatus `json:"warmup"`
+} // @name ReadyResponse
+
+/** VDSs to open at startup, such that the first requests are fast
+ *
+ * The server is not ready until warm-up is done, unless it is async. VDSs
+ * that fail to warm up are logged and reported by /ready, but don't hold
+ * readiness back. The VDSs are opened with the credentials of the server,
+ * or a sas in the url.
+ */
+type Warmup struct {
+	vdsList          []string
+	prefetchMetadata bool
+	async            bool
+
+	lock     sync.Mutex
+	statuses []WarmupStatus
+	done     bool
+}
+
+/** New warm-up of the VDSs in vdsList
+ *
+ * prefetchMetadata fetches the metadata of every VDS into the cache. With
+ * async the server reports ready while warm-up is still running.
+ */
+func NewWarmup(vdsList []string, prefetchMetadata bool, async bool) *Warmup {
+	statuses := make([]WarmupStatus, len(vdsList))
+	for i, vds := range vdsList {
+		statuses[i] = WarmupStatus{Vds: warmupName(vds), State: warmupPending}
+	}
+	return &Warmup{
+		vdsList:          vdsList,
+		prefetchMetadata: prefetchMetadata,
+		async:            async,
+		statuses:         statuses,
+	}
+}
+
+/* The VDS without the query, which may hold a sas */
+func warmupName(vds string) string {
+	u, err := url.Parse(vds)
+	if err != nil {
+		return "<invalid url>"
+	}
+	u.RawQuery = ""
+	return u.String()
+}
+
+func (w *Warmup) setState(i int, state string, err error) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	w.statuses[i].State = state
+	if err != nil {
+		w.statuses[i].Error = sanitizeErrorMessage(err.Error())
+	}
+}
+
+func (w *Warmup) status() ReadyResponse {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	return ReadyResponse{
+		Ready:  w.done || w.async,
+		Warmup: append([]WarmupStatus{}, w.statuses...),
+	}
+}
+
+/* Open a single VDS, and fetch its metadata if asked to */
+func (e *Endpoint) warmupVds(
+	ctx context.Context,
+	vds string,
+	prefetchMetadata bool,
+) error {
+	request := MetadataRequest{RequestedResource: RequestedResource{Vds: vds}}
+	err := request.resolveAlias(e.Resolver)
+	if err != nil {
+		return err
+	}
+	err = request.NormalizeConnection()
+	if err != nil {
+		return err
+	}
+
+	if prefetchMetadata {
+		_, err = e.fetchMetadata(ctx, request)
+		return err
+	}
+
+	conn, err := e.MakeVdsConnection(request.credentials())
+	if err != nil {
+		return err
+	}
+
+	return e.Breaker.Do(core.StorageHost(request.Vds), func() error {
+		handle, err := e.openHandle(ctx, conn)
+		if err != nil {
+			return err
+		}
+		handle.Close()
+		return nil
+	})
+}
+
+/** Warm up the VDSs of the warm-up list, one at a time
+ *
+ * Does nothing if the endpoint has no warm-up list. Meant to be run in the
+ * background while the server starts serving.
+ */
+func (e *Endpoint) RunWarmup(ctx context.Context) {
+	w := e.Warmup
+	if w == nil {
+		return
+	}
+
+	for i, vds := range w.vdsList {
+		err := e.warmupVds(ctx, vds, w.prefetchMetadata)
+		if err != nil {
+			log.Printf(
+				"Warm-up of %s failed: %s",
+				warmupName(vds),
+				sanitizeErrorMessage(err.Error()),
+			)
+			w.setState(i, warmupFailed, err)
+			continue
+		}
+		w.setState(i, warmupDone, nil)
+	}
+
+	w.lock.Lock()
+	w.done = true
+	w.lock.Unlock()
+}
+
+// ReadyGet godoc
+// @Summary  Whether the server is ready to take requests
+// @Description Not ready (503) while the configured VDSs are warmed up,
+// @Description unless warm-up runs asynchronously. The state of every VDS in
+// @Description the warm-up list is given either way.
+// @Tags     health
+// @Produce  json
+// @Success  200 {object} ReadyResponse
+// @Failure  503 {object} ReadyResponse "Warm-up is still running"
+// @Router   /ready  [get]
+func (e *Endpoint) ReadyGet(ctx *gin.Context) {
+	response := ReadyResponse{Ready: true, Warmup: []WarmupStatus{}}
+	if e.Warmup != nil {
+		response = e.Warmup.status()
+	}
+
+	status := http.StatusOK
+	if !response.Ready {
+		status = http.StatusServiceUnavailable
+	}
+	ctx.JSON(status, response)
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+
+	"github.com/equinor/vds-slice/api"
+	"github.com/equinor/vds-slice/api/vdsslicepb"
+	"github.com/equinor/vds-slice/internal/audit"
+	"github.com/equinor/vds-slice/internal/cache"
+)
+
+/* Sink that keeps the records written to it */
+type recordingSink struct {
+	lock    sync.Mutex
+	records []audit.Record
+}
+
+func (s *recordingSink) Write(record audit.Record) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.records = append(s.records, record)
+	return nil
+}
+
+func (s *recordingSink) Close() error {
+	return nil
+}
+
+/* An endpoint that audits to the returned sink, see flushAudit */
+func auditedEndpoint() (api.Endpoint, *recordingSink) {
+	sink := &recordingSink{}
+	return api.Endpoint{
+		MakeVdsConnection: MakeFileConnection(),
+		Cache:             cache.NewNoCache(),
+		Audit:             audit.NewLogger(sink),
+	}, sink
+}
+
+/* Wait for the records logged so far, which are written in the background */
+func flushAudit(t *testing.T, endpoint api.Endpoint, sink *recordingSink) []audit.Record {
+	require.NoError(t, endpoint.Audit.Close())
+	return sink.records
+}
+
+func TestAuditRoutes(t *testing.T) {
+	slice := fmt.Sprintf(
+		`{"vds": "%s", "sas": "n/a", "direction": "i", "lineno": 1}`,
+		well_known,
+	)
+	query := "?query=" + url.QueryEscape(slice)
+
+	testcases := []struct {
+		method   string
+		path     string
+		body     string
+		endpoint string
+		status   int
+	}{
+		{http.MethodPost, "/slice", slice, "/slice", http.StatusOK},
+		{http.MethodPost, "/slice/progressive", slice, "/slice/progressive", http.StatusOK},
+		{http.MethodHead, "/slice" + query, "", "/slice", http.StatusOK},
+		{
+			http.MethodHead,
+			"/metadata?query=" + url.QueryEscape(fmt.Sprintf(
+				`{"vds": "%s", "sas": "n/a"}`,
+				well_known,
+			)),
+			"",
+			"/metadata",
+			http.StatusOK,
+		},
+	}
+
+	for _, testcase := range testcases {
+		endpoint, sink := auditedEndpoint()
+
+		w := httptest.NewRecorder()
+		ctx, r := gin.CreateTestContext(w)
+		setupApp(r, &endpoint, nil, nil)
+
+		ctx.Request, _ = http.NewRequest(
+			testcase.method,
+			testcase.path,
+			bytes.NewBufferString(testcase.body),
+		)
+		ctx.Request.Header.Set("Content-Type", "application/json")
+		r.ServeHTTP(w, ctx.Request)
+		require.Equalf(t, testcase.status, w.Result().StatusCode,
+			"[%s %s] Wrong response status", testcase.method, testcase.endpoint)
+
+		records := flushAudit(t, endpoint, sink)
+		require.Lenf(t, records, 1,
+			"[%s %s] Expected one record", testcase.method, testcase.endpoint)
+		require.Equal(t, testcase.endpoint, records[0].Endpoint)
+		require.Equal(t, well_known, records[0].Vds)
+		require.Equal(t, testcase.status, records[0].Status)
+		require.NotEmpty(t, records[0].RequestHash)
+	}
+}
+
+func TestAuditBatch(t *testing.T) {
+	endpoint, sink := auditedEndpoint()
+
+	w := httptest.NewRecorder()
+	ctx, r := gin.CreateTestContext(w)
+	setupApp(r, &endpoint, nil, nil)
+
+	request := fmt.Sprintf(`{
+		"vds": "%s",
+		"sas": "n/a",
+		"requests": [
+			{"type": "metadata"},
+			{"type": "slice", "parameters": {"direction": "i", "lineno": 1}},
+			{"type": "slice", "parameters": {"direction": "i", "lineno": 10}},
+			{"type": "surface"}
+		]
+	}`, well_known)
+	ctx.Request, _ = http.NewRequest(
+		http.MethodPost,
+		"/batch",
+		bytes.NewBufferString(request),
+	)
+	ctx.Request.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(w, ctx.Request)
+	require.Equalf(t, http.StatusOK, w.Result().StatusCode,
+		"Wrong response status. Body: %v", w.Body.String())
+
+	/* The sub-request of unknown type never got as far as the cube */
+	records := flushAudit(t, endpoint, sink)
+	require.Len(t, records, 3)
+
+	statuses := []int{}
+	for _, record := range records {
+		require.Equal(t, "/batch", record.Endpoint)
+		require.Equal(t, well_known, record.Vds)
+		require.NotEmpty(t, record.RequestHash)
+		statuses = append(statuses, record.Status)
+	}
+	require.Equal(t,
+		[]int{http.StatusOK, http.StatusOK, http.StatusBadRequest},
+		statuses,
+	)
+	require.NotZero(t, records[1].Bytes)
+	require.Zero(t, records[2].Bytes)
+}
+
+func TestAuditGrpc(t *testing.T) {
+	endpoint, sink := auditedEndpoint()
+	client := setupGrpcTestWith(t, endpoint)
+	resource := &vdsslicepb.RequestedResource{Vds: well_known, Sas: "n/a"}
+	lineno, outOfBounds := int32(1), int32(10)
+
+	_, err := client.Metadata(
+		context.Background(),
+		&vdsslicepb.MetadataRequest{Resource: resource},
+	)
+	require.NoError(t, err)
+
+	stream, err := client.Slice(
+		context.Background(),
+		&vdsslicepb.SliceRequest{
+			Resource:  resource,
+			Direction: "i",
+			Lineno:    &lineno,
+		},
+	)
+	require.NoError(t, err)
+	_, _, err = readGrpcStream(stream)
+	require.NoError(t, err)
+
+	stream, err = client.Slice(
+		context.Background(),
+		&vdsslicepb.SliceRequest{
+			Resource:  resource,
+			Direction: "i",
+			Lineno:    &outOfBounds,
+		},
+	)
+	require.NoError(t, err)
+	_, _, err = readGrpcStream(stream)
+	require.Error(t, err)
+
+	records := flushAudit(t, endpoint, sink)
+	require.Len(t, records, 3)
+
+	expected := []struct {
+		endpoint string
+		status   int
+	}{
+		{vdsslicepb.VdsSlice_Metadata_FullMethodName, http.StatusOK},
+		{vdsslicepb.VdsSlice_Slice_FullMethodName, http.StatusOK},
+		{vdsslicepb.VdsSlice_Slice_FullMethodName, http.StatusBadRequest},
+	}
+	for i, record := range records {
+		require.Equal(t, expected[i].endpoint, record.Endpoint)
+		require.Equal(t, expected[i].status, record.Status)
+		require.Equal(t, well_known, record.Vds)
+		require.NotEmpty(t, record.Client)
+		require.NotEmpty(t, record.RequestHash)
+	}
+	require.NotZero(t, records[1].Bytes)
+}
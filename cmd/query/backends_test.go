@@ -0,0 +1,132 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+/** Requests that both backends must answer the same
+ *
+ * Fences with the nearest interpolation are at traces only, as OpenVDS
+ * interpolates positions between them, which the in-memory cube does not.
+ */
+func parityTestcases() []endpointTest {
+	post := func(name string) baseTest {
+		return baseTest{name: name, method: http.MethodPost}
+	}
+	slice := func(name, direction string, lineno int, bounds ...testBound) sliceTest {
+		return sliceTest{post(name), testSliceRequest{
+			Vds:       well_known,
+			Direction: direction,
+			Lineno:    lineno,
+			Sas:       "n/a",
+			Bounds:    bounds,
+		}}
+	}
+	fence := func(name, system, interpolation string, coordinates [][]float32) fenceTest {
+		return fenceTest{post(name), testFenceRequest{
+			Vds:              well_known,
+			CoordinateSystem: system,
+			Coordinates:      coordinates,
+			Interpolation:    interpolation,
+			FillValue:        -999.25,
+			Sas:              "n/a",
+		}}
+	}
+
+	return []endpointTest{
+		metadataTest{post("Metadata"), testMetadataRequest{Vds: well_known, Sas: "n/a"}},
+		slice("Inline slice", "inline", 3),
+		slice("Crossline slice", "crossline", 11),
+		slice("Time slice", "time", 8),
+		slice("Index slice", "k", 2),
+		slice("Bounded slice", "sample", 12,
+			testBound{Direction: "inline", Lower: 3, Upper: 5},
+			testBound{Direction: "j", Lower: 1, Upper: 1},
+		),
+		slice("Last bound wins", "inline", 1,
+			testBound{Direction: "sample", Lower: 4, Upper: 4},
+			testBound{Direction: "sample", Lower: 8, Upper: 16},
+		),
+		slice("Invalid lineno", "inline", 2),
+		slice("Lineno out of range", "i", 3),
+		slice("Invalid bound", "inline", 1,
+			testBound{Direction: "crossline", Lower: 9, Upper: 11},
+		),
+		slice("Empty bounds", "inline", 1,
+			testBound{Direction: "sample", Lower: 12, Upper: 8},
+		),
+		slice("Depth slice of time cube", "depth", 8),
+		fence("Fence at traces", "ij", "", [][]float32{{0, 1}, {2, 0}}),
+		fence("Fence by annotation", "ilxl", "", [][]float32{{3, 11}, {5, 10}}),
+		fence("Fence by cdp", "cdp", "", [][]float32{{14, 8}, {0, 3}}),
+		fence("Nearest trace", "ilxl", "nearest_trace",
+			[][]float32{{3.9, 10.4}, {4, 10.5}, {9, 10}},
+		),
+		fence("Nearest trace by cdp", "cdp", "nearest_trace",
+			[][]float32{{8.2, 4.1}, {13, 9}},
+		),
+		fence("No interpolation", "ij", "none", [][]float32{{1, 1}, {2, 0}}),
+		fence("No interpolation between traces", "ij", "none",
+			[][]float32{{1, 1}, {1.5, 0}},
+		),
+	}
+}
+
+func TestBackendParity(t *testing.T) {
+	for _, testcase := range parityTestcases() {
+		reference := setupTestWith(t, backends[0], testcase)
+
+		for _, backend := range backends[1:] {
+			w := setupTestWith(t, backend, testcase)
+
+			require.Equalf(t, reference.Code, w.Code,
+				"Status of %s differs in case '%s'. Body: %s",
+				backend.name, testcase.base().name, w.Body.String(),
+			)
+
+			if reference.Code != http.StatusOK {
+				require.JSONEqf(t, reference.Body.String(), w.Body.String(),
+					"Error of %s differs in case '%s'",
+					backend.name, testcase.base().name,
+				)
+				continue
+			}
+
+			if _, ok := testcase.(metadataTest); ok {
+				requireMetadataEqual(t, reference.Body.Bytes(), w.Body.Bytes())
+				continue
+			}
+
+			expected := readMultipartData(t, reference)
+			actual := readMultipartData(t, w)
+			require.Lenf(t, actual, len(expected),
+				"Parts of %s differ in case '%s'",
+				backend.name, testcase.base().name,
+			)
+			require.JSONEqf(t, string(expected[0]), string(actual[0]),
+				"Metadata of %s differs in case '%s'",
+				backend.name, testcase.base().name,
+			)
+			require.Equalf(t, expected[1:], actual[1:],
+				"Data of %s differs in case '%s'",
+				backend.name, testcase.base().name,
+			)
+		}
+	}
+}
+
+/* Metadata of two cubes, equal but for the time they were imported */
+func requireMetadataEqual(t *testing.T, expected, actual []byte) {
+	var expectedMap map[string]any
+	var actualMap map[string]any
+	require.NoError(t, json.Unmarshal(expected, &expectedMap))
+	require.NoError(t, json.Unmarshal(actual, &actualMap))
+
+	delete(expectedMap, "importTimeStamp")
+	delete(actualMap, "importTimeStamp")
+	require.Equal(t, expectedMap, actualMap)
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+
+	"github.com/equinor/vds-slice/api"
+	"github.com/equinor/vds-slice/api/client"
+	"github.com/equinor/vds-slice/internal/cache"
+)
+
+func setupClient(t *testing.T) *client.Client {
+	app := gin.New()
+	endpoint := api.Endpoint{
+		MakeVdsConnection: MakeFileConnection(),
+		Cache:             cache.NewNoCache(),
+	}
+	setupApp(app, &endpoint, nil, nil)
+
+	server := httptest.NewServer(app)
+	t.Cleanup(server.Close)
+	return client.NewClient(server.URL + "/v1")
+}
+
+func TestClientMetadata(t *testing.T) {
+	c := setupClient(t)
+
+	metadata, err := c.Metadata(context.Background(), client.MetadataRequest{
+		RequestedResource: client.RequestedResource{Vds: well_known, Sas: "n/a"},
+	})
+	require.NoError(t, err)
+	require.Equal(t, "utmXX", metadata.Crs)
+	require.Len(t, metadata.Axis, 3)
+	require.Equal(t, "Inline", metadata.Axis[0].Annotation)
+}
+
+func TestClientSlice(t *testing.T) {
+	c := setupClient(t)
+
+	metadata, data, err := c.Slice(context.Background(), client.SliceRequest{
+		RequestedResource: client.RequestedResource{Vds: well_known, Sas: "n/a"},
+		Direction:         "i",
+		Lineno:            0,
+	})
+	require.NoError(t, err)
+	require.Equal(t, "<f4", metadata.Format)
+	require.Equal(t, []int{2, 4}, metadata.Shape)
+	require.Equal(t, "Crossline", metadata.Y.Annotation)
+	require.Len(t, data, 1)
+	require.Len(t, data[0], 2*4*4)
+}
+
+func TestClientFence(t *testing.T) {
+	c := setupClient(t)
+
+	metadata, data, err := c.Fence(context.Background(), client.FenceRequest{
+		RequestedResource: client.RequestedResource{Vds: well_known, Sas: "n/a"},
+		CoordinateSystem:  "ilxl",
+		CoordinatesFlat:   []float32{1, 10, 3, 11},
+	})
+	require.NoError(t, err)
+	require.Equal(t, []int{2, 4}, metadata.Shape)
+	require.Len(t, data, 1)
+	require.Len(t, data[0], 2*4*4)
+}
+
+func TestClientAttributes(t *testing.T) {
+	c := setupClient(t)
+
+	surface := client.RegularSurface{
+		Values:    [][]float32{{20, 20}, {20, 20}, {20, 20}},
+		Rotation:  33.69,
+		Xori:      2,
+		Yori:      0,
+		Xinc:      7.2111,
+		Yinc:      3.6056,
+		FillValue: 666.66,
+	}
+	attributes := client.AttributeRequest{
+		RequestedResource: client.RequestedResource{Vds: samples10, Sas: "n/a"},
+		Attributes:        []string{"samplevalue", "min"},
+	}
+
+	metadata, data, err := c.AttributesAlongSurface(
+		context.Background(),
+		client.AttributeAlongSurfaceRequest{
+			AttributeRequest: attributes,
+			Surface:          surface,
+			Above:            8,
+			Below:            4,
+		},
+	)
+	require.NoError(t, err)
+	require.Equal(t, []int{3, 2}, metadata.Shape)
+	require.Len(t, data, 2)
+
+	metadata, data, err = c.AttributesBetweenSurfaces(
+		context.Background(),
+		client.AttributeBetweenSurfacesRequest{
+			AttributeRequest: attributes,
+			PrimarySurface:   surface,
+			SecondarySurface: surface,
+		},
+	)
+	require.NoError(t, err)
+	require.Equal(t, []int{3, 2}, metadata.Shape)
+	require.Len(t, data, 2)
+}
+
+func TestClientError(t *testing.T) {
+	c := setupClient(t)
+
+	_, _, err := c.Slice(context.Background(), client.SliceRequest{
+		RequestedResource: client.RequestedResource{Vds: well_known, Sas: "n/a"},
+		Direction:         "i",
+		Lineno:            10,
+	})
+
+	var apiError *client.Error
+	require.True(t, errors.As(err, &apiError))
+	require.Equal(t, http.StatusBadRequest, apiError.Status)
+	require.Equal(t, "lineno_out_of_range", apiError.Code)
+	require.Equal(t, "10", apiError.Details["lineno"])
+}
+
+func TestClientPartialAttributes(t *testing.T) {
+	c := setupClient(t)
+
+	request := client.AttributeAlongSurfaceRequest{
+		AttributeRequest: client.AttributeRequest{
+			RequestedResource: client.RequestedResource{Vds: samples10, Sas: "n/a"},
+			Attributes:        []string{"min", "max", "bogus", "rms"},
+		},
+		Surface: client.RegularSurface{
+			Values:    [][]float32{{20, 20}, {20, 20}, {20, 20}},
+			Rotation:  33.69,
+			Xori:      2,
+			Yori:      0,
+			Xinc:      7.2111,
+			Yinc:      3.6056,
+			FillValue: 666.66,
+		},
+		Above: 8,
+		Below: 4,
+	}
+
+	_, _, err := c.AttributesAlongSurface(context.Background(), request)
+	var apiError *client.Error
+	require.True(t, errors.As(err, &apiError))
+	require.Equal(t, "invalid_attribute", apiError.Code,
+		"Expected requests to be all-or-nothing by default")
+
+	request.Partial = true
+	metadata, data, err := c.AttributesAlongSurface(context.Background(), request)
+	require.NoError(t, err)
+	require.Len(t, data, 3, "Expected a part for every valid attribute")
+	require.Len(t, metadata.Attributes, 4)
+
+	for i, name := range request.Attributes {
+		status := metadata.Attributes[i]
+		require.Equal(t, name, status.Name)
+		if name == "bogus" {
+			require.Contains(t, status.Status, "invalid attribute 'bogus'")
+		} else {
+			require.Equal(t, "ok", status.Status)
+		}
+	}
+
+	request.Attributes = []string{"min", "max", "rms"}
+	request.Partial = false
+	_, expected, err := c.AttributesAlongSurface(context.Background(), request)
+	require.NoError(t, err)
+	require.Equal(t, expected, data,
+		"Expected the valid attributes to be computed as in a regular request")
+}
@@ -0,0 +1,1101 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/pborman/getopt/v2"
+	"gopkg.in/yaml.v3"
+
+	"github.com/equinor/vds-slice/api"
+	"github.com/equinor/vds-slice/internal/clientip"
+	"github.com/equinor/vds-slice/internal/core"
+)
+
+/** Configuration of the server
+ *
+ * Every setting is loaded in order of precedence: the default, the config
+ * file, the environment and finally the command line. Later sources override
+ * earlier ones.
+ */
+type config struct {
+	storageAccounts         string
+	port                    uint32
+	cacheSize               uint64
+	metrics                 bool
+	metricsPort             uint32
+	sasExpiryGrace          uint32
+	azureBlobEndpoint       string
+	allowLocal              bool
+	localRoot               string
+	s3                      bool
+	s3Region                string
+	s3Endpoint              string
+	gs                      bool
+	retries                 uint32
+	retryBackoff            uint32
+	circuitThreshold        uint32
+	circuitCooldown         uint32
+	staleIfError            uint32
+	memoryBudget            uint32
+	rateLimit               uint32
+	rateLimitBurst          uint32
+	rateLimitHeader         string
+	validateRateLimit       uint32
+	validateRateLimitBurst  uint32
+	trustedProxies          string
+	slowRequestThreshold    uint32
+	requestTimeout          uint32
+	attributeWorkers        uint32
+	slowRequestLogLimit     uint32
+	auditLog                string
+	auditLogMaxSize         uint32
+	auditLogMaxFiles        uint32
+	auditWebhook            string
+	auditWebhookRetries     uint32
+	maxRequestSize          uint64
+	maxAttributeRequestSize uint64
+	maxAttributeQuerySize   uint32
+	maxFenceCoordinates     uint32
+	fenceBatchSize          uint32
+	fenceBatching           bool
+	defaultFillValue        string
+	defaultInterpolation    string
+	maxSamplePoints         uint32
+	maxAttributes           uint32
+	maxBatchRequests        uint32
+	maxMetadataList         uint32
+	maxTraverseSegments     uint32
+	maxSurfacePoints        uint32
+	enabledEndpoints        string
+	grpcPort                uint32
+	shutdownTimeout         uint32
+	tlsCert                 string
+	tlsKey                  string
+	tlsClientCA             string
+	metricsTLSCert          string
+	metricsTLSKey           string
+	warmup                  string
+	warmupMetadata          bool
+	warmupAsync             bool
+	vdsAliases              string
+	vdsCatalogue            string
+	vdsResolverTTL          uint32
+}
+
+func defaultConfig() config {
+	return config{
+		port:                    8080,
+		metricsPort:             8081,
+		localRoot:               ".",
+		retries:                 2,
+		retryBackoff:            100,
+		circuitThreshold:        5,
+		circuitCooldown:         30,
+		memoryBudget:            70,
+		validateRateLimit:       1,
+		validateRateLimitBurst:  5,
+		maxRequestSize:          10,
+		maxAttributeRequestSize: 200,
+		maxAttributeQuerySize:   8192,
+		maxFenceCoordinates:     100000,
+		fenceBatchSize:          10000,
+		maxSamplePoints:         100000,
+		maxAttributes:           32,
+		maxBatchRequests:        20,
+		maxMetadataList:         100,
+		maxTraverseSegments:     50,
+		maxSurfacePoints:        1000000,
+		shutdownTimeout:         30,
+		vdsResolverTTL:          60,
+		slowRequestLogLimit:     10,
+		auditLogMaxSize:         100,
+		auditLogMaxFiles:        10,
+		auditWebhookRetries:     3,
+	}
+}
+
+/** A single setting of the config
+ *
+ * The name is used both as the command line flag and as the key in the config
+ * file. Settings without an argname are switches, i.e. booleans that are
+ * turned on by giving the flag alone. Reloadable settings are re-read on
+ * SIGHUP, see configReloader.
+ */
+type setting struct {
+	name    string
+	env     string
+	argname string
+	help    string
+	/* Pointer to the field of the setting in c */
+	field      func(c *config) interface{}
+	reloadable bool
+}
+
+var settings = []setting{
+	{
+		name:    "storage-accounts",
+		env:     "VDSSLICE_STORAGE_ACCOUNTS",
+		argname: "string",
+		field:   func(c *config) interface{} { return &c.storageAccounts },
+		help: "Comma-separated list of storage accounts that should be accepted by the API.\n" +
+			"Each entry may be narrowed down to containers and paths, which can contain\n" +
+			"wildcards. Requests outside the list are rejected with 403. If not set,\n" +
+			"all storage accounts are accepted.\n" +
+			"Example: 'https://<account1>.blob.core.windows.net,https://<account2>.blob.core.windows.net/seismic/*'",
+		reloadable: true,
+	},
+	{
+		name:    "port",
+		env:     "VDSSLICE_PORT",
+		argname: "int",
+		field:   func(c *config) interface{} { return &c.port },
+		help:    "Port to start server on. Defaults to 8080.",
+	},
+	{
+		name:    "cache-size",
+		env:     "VDSSLICE_CACHE_SIZE",
+		argname: "int",
+		field:   func(c *config) interface{} { return &c.cacheSize },
+		help: "Max size of the response cache. In megabytes. A value of zero effectively\n" +
+			"disables caching. Defaults to 0.",
+	},
+	{
+		name:  "metrics",
+		env:   "VDSSLICE_METRICS",
+		field: func(c *config) interface{} { return &c.metrics },
+		help: "Turn on server metrics. Metrics are posted to /metrics using the\n" +
+			"prometheus data model. Off by default.",
+	},
+	{
+		name:    "metrics-port",
+		env:     "VDSSLICE_METRICS_PORT",
+		argname: "int",
+		field:   func(c *config) interface{} { return &c.metricsPort },
+		help: "Port to host the /metrics endpoint on. Metrics are always hosted on a\n" +
+			"different port than the server itself. This allows for them to be kept\n" +
+			"private, if desirable. Defaults to 8081.\n" +
+			"Ignored if metrics are not turned on. (see --metrics)",
+	},
+	{
+		name:    "sas-expiry-grace",
+		env:     "VDSSLICE_SAS_EXPIRY_GRACE",
+		argname: "int",
+		field:   func(c *config) interface{} { return &c.sasExpiryGrace },
+		help: "Reject sas-tokens that expire within this many seconds, rather than\n" +
+			"letting requests fail half-way through. Expired tokens are always\n" +
+			"rejected. Defaults to 0.",
+	},
+	{
+		name:    "azure-blob-endpoint",
+		env:     "VDSSLICE_AZURE_BLOB_ENDPOINT",
+		argname: "string",
+		field:   func(c *config) interface{} { return &c.azureBlobEndpoint },
+		help: "Read Azure blobs through this endpoint rather than the host of the\n" +
+			"requested url, e.g. a private link. {account} is replaced by the\n" +
+			"storage account of the url, as in\n" +
+			"'https://{account}.privatelink.blob.core.windows.net'. The allowlist\n" +
+			"is still checked against the requested url. (see --storage-accounts)",
+	},
+	{
+		name:  "allow-local",
+		env:   "VDSSLICE_ALLOW_LOCAL",
+		field: func(c *config) interface{} { return &c.allowLocal },
+		help: "Allow reading VDSs from the local filesystem, given as file:// urls or\n" +
+			"paths. Meant for development and testing only. Off by default.",
+	},
+	{
+		name:    "local-root",
+		env:     "VDSSLICE_LOCAL_ROOT",
+		argname: "string",
+		field:   func(c *config) interface{} { return &c.localRoot },
+		help: "Directory that local VDSs must reside in. Requests for paths outside\n" +
+			"of it are rejected. Defaults to the current working directory.\n" +
+			"Ignored if local files are not allowed. (see --allow-local)",
+	},
+	{
+		name:  "s3",
+		env:   "VDSSLICE_S3",
+		field: func(c *config) interface{} { return &c.s3 },
+		help: "Accept VDSs in AWS S3, given as s3://<bucket>/<key>. Requests without\n" +
+			"credentials of their own are made with the server's credentials,\n" +
+			"and only for the buckets listed by --storage-accounts, e.g.\n" +
+			"'s3://<bucket>', which must list at least one. Off by default.",
+	},
+	{
+		name:    "s3-region",
+		env:     "VDSSLICE_S3_REGION",
+		argname: "string",
+		field:   func(c *config) interface{} { return &c.s3Region },
+		help: "Default AWS region for S3. Can be overridden per request.\n" +
+			"Ignored if S3 is not turned on. (see --s3)",
+	},
+	{
+		name:    "s3-endpoint",
+		env:     "VDSSLICE_S3_ENDPOINT",
+		argname: "string",
+		field:   func(c *config) interface{} { return &c.s3Endpoint },
+		help: "Endpoint for S3-compatible storage other than AWS.\n" +
+			"Ignored if S3 is not turned on. (see --s3)",
+	},
+	{
+		name:  "gs",
+		env:   "VDSSLICE_GS",
+		field: func(c *config) interface{} { return &c.gs },
+		help: "Accept VDSs in Google Cloud Storage, given as gs://<bucket>/<key>.\n" +
+			"All requests are made with the server's credentials, and only for\n" +
+			"the buckets listed by --storage-accounts, e.g. 'gs://<bucket>',\n" +
+			"which must list at least one. Off by default.",
+	},
+	{
+		name:    "retries",
+		env:     "VDSSLICE_RETRIES",
+		argname: "int",
+		field:   func(c *config) interface{} { return &c.retries },
+		help: "Number of times to retry reads that fail with transient storage\n" +
+			"errors, such as 503 or timeouts. Defaults to 2.",
+	},
+	{
+		name:    "retry-backoff",
+		env:     "VDSSLICE_RETRY_BACKOFF",
+		argname: "int",
+		field:   func(c *config) interface{} { return &c.retryBackoff },
+		help: "Time to wait before the first retry, in milliseconds. Doubled for\n" +
+			"every subsequent retry. Defaults to 100.",
+	},
+	{
+		name:    "circuit-threshold",
+		env:     "VDSSLICE_CIRCUIT_THRESHOLD",
+		argname: "int",
+		field:   func(c *config) interface{} { return &c.circuitThreshold },
+		help: "Number of consecutive transient errors from a storage account before\n" +
+			"requests against it fail fast with 503. A value of zero disables\n" +
+			"the circuit breaker. Defaults to 5.",
+	},
+	{
+		name:    "circuit-cooldown",
+		env:     "VDSSLICE_CIRCUIT_COOLDOWN",
+		argname: "int",
+		field:   func(c *config) interface{} { return &c.circuitCooldown },
+		help: "Seconds to fail fast against a failing storage account before probing\n" +
+			"it again. Defaults to 30.",
+	},
+	{
+		name:    "stale-if-error",
+		env:     "VDSSLICE_STALE_IF_ERROR",
+		argname: "int",
+		field:   func(c *config) interface{} { return &c.staleIfError },
+		help: "Seconds a credential is trusted to read a VDS after it was last\n" +
+			"proven to, for serving cached responses while storage fails with\n" +
+			"transient errors. Such responses have the headers X-Served-Stale\n" +
+			"and Warning. A value of zero disables it. Defaults to 0.",
+	},
+	{
+		name:    "memory-budget",
+		env:     "VDSSLICE_MEMORY_BUDGET",
+		argname: "int",
+		field:   func(c *config) interface{} { return &c.memoryBudget },
+		help: "Share of the memory limit of the container, in percent, that the\n" +
+			"responses in flight may take up. Requests whose response would\n" +
+			"exceed it fail fast with 503. A value of zero, or no memory limit,\n" +
+			"means no budget. Defaults to 70.",
+		reloadable: true,
+	},
+	{
+		name:    "rate-limit",
+		env:     "VDSSLICE_RATE_LIMIT",
+		argname: "int",
+		field:   func(c *config) interface{} { return &c.rateLimit },
+		help: "Max number of requests per second per client. Clients exceeding it\n" +
+			"get 429. A value of zero disables rate limiting. Defaults to 0.",
+		reloadable: true,
+	},
+	{
+		name:    "rate-limit-burst",
+		env:     "VDSSLICE_RATE_LIMIT_BURST",
+		argname: "int",
+		field:   func(c *config) interface{} { return &c.rateLimitBurst },
+		help: "Number of requests a client can make in a burst, before the rate\n" +
+			"limit kicks in. Defaults to the rate limit itself.",
+		reloadable: true,
+	},
+	{
+		name:    "validate-rate-limit",
+		env:     "VDSSLICE_VALIDATE_RATE_LIMIT",
+		argname: "int",
+		field:   func(c *config) interface{} { return &c.validateRateLimit },
+		help: "Max number of requests per second per client to /validate, on top\n" +
+			"of rate-limit. Validation is cheap, and thus cheap to abuse for\n" +
+			"scanning. A value of zero disables it. Defaults to 1.",
+		reloadable: true,
+	},
+	{
+		name:    "validate-rate-limit-burst",
+		env:     "VDSSLICE_VALIDATE_RATE_LIMIT_BURST",
+		argname: "int",
+		field:   func(c *config) interface{} { return &c.validateRateLimitBurst },
+		help: "Number of requests a client can make to /validate in a burst.\n" +
+			"Defaults to 5.",
+		reloadable: true,
+	},
+	{
+		name:    "rate-limit-header",
+		env:     "VDSSLICE_RATE_LIMIT_HEADER",
+		argname: "string",
+		field:   func(c *config) interface{} { return &c.rateLimitHeader },
+		help: "Header that identifies the client for rate limiting, e.g. X-Client-Id.\n" +
+			"Clients are identified by their IP if not set, or if the header is\n" +
+			"missing from the request.",
+	},
+	{
+		name:    "trusted-proxies",
+		env:     "VDSSLICE_TRUSTED_PROXIES",
+		argname: "string",
+		field:   func(c *config) interface{} { return &c.trustedProxies },
+		help: "Comma separated list of IPs and CIDR ranges of proxies, e.g. the\n" +
+			"ingress, that are trusted to report the client IP in the\n" +
+			"X-Forwarded-For and X-Real-IP headers. The client IP, as used in\n" +
+			"logs and for rate limiting, is the socket peer if not set.",
+	},
+	{
+		name:    "slow-request-threshold",
+		env:     "VDSSLICE_SLOW_REQUEST_THRESHOLD",
+		argname: "int",
+		field:   func(c *config) interface{} { return &c.slowRequestThreshold },
+		help: "Requests that take longer than this, in milliseconds, are logged\n" +
+			"with their parameters in a slow_request record. A value of zero\n" +
+			"disables slow request logging. Defaults to 0.",
+	},
+	{
+		name:    "slow-request-log-limit",
+		env:     "VDSSLICE_SLOW_REQUEST_LOG_LIMIT",
+		argname: "int",
+		field:   func(c *config) interface{} { return &c.slowRequestLogLimit },
+		help: "Max number of slow_request records per minute. Slow requests beyond\n" +
+			"it are only counted. Defaults to 10.",
+	},
+	{
+		name:    "max-request-size",
+		env:     "VDSSLICE_MAX_REQUEST_SIZE",
+		argname: "int",
+		field:   func(c *config) interface{} { return &c.maxRequestSize },
+		help: "Max size of request bodies for metadata, slice and fence. In megabytes.\n" +
+			"Larger requests are rejected with 413. A value of zero means no\n" +
+			"limit. Defaults to 10.",
+		reloadable: true,
+	},
+	{
+		name:    "max-attribute-request-size",
+		env:     "VDSSLICE_MAX_ATTRIBUTE_REQUEST_SIZE",
+		argname: "int",
+		field:   func(c *config) interface{} { return &c.maxAttributeRequestSize },
+		help: "Max size of request bodies for the attribute endpoints, which carry\n" +
+			"whole surfaces. In megabytes. A value of zero means no limit.\n" +
+			"Defaults to 200.",
+		reloadable: true,
+	},
+	{
+		name:    "max-attribute-query-size",
+		env:     "VDSSLICE_MAX_ATTRIBUTE_QUERY_SIZE",
+		argname: "int",
+		field:   func(c *config) interface{} { return &c.maxAttributeQuerySize },
+		help: "Max size of the url encoded query of GET requests to the attribute\n" +
+			"endpoints, in bytes. Larger requests must use POST. A value of zero\n" +
+			"means no limit. Defaults to 8192.",
+		reloadable: true,
+	},
+	{
+		name:    "max-fence-coordinates",
+		env:     "VDSSLICE_MAX_FENCE_COORDINATES",
+		argname: "int",
+		field:   func(c *config) interface{} { return &c.maxFenceCoordinates },
+		help: "Max number of coordinates in a single fence request. A value of zero\n" +
+			"means no limit. Defaults to 100000.",
+		reloadable: true,
+	},
+	{
+		name:    "fence-batch-size",
+		env:     "VDSSLICE_FENCE_BATCH_SIZE",
+		argname: "int",
+		field:   func(c *config) interface{} { return &c.fenceBatchSize },
+		help: "Number of traces per batch for fences that are processed in batches.\n" +
+			"Defaults to 10000.",
+	},
+	{
+		name:  "fence-batching",
+		env:   "VDSSLICE_FENCE_BATCHING",
+		field: func(c *config) interface{} { return &c.fenceBatching },
+		help: "Process every fence longer than a batch in batches, streaming the\n" +
+			"traces as they are read, rather than only the fences that ask for\n" +
+			"it. Bounds the memory of long fences, which are then not cached.\n" +
+			"Fences are still limited by --max-fence-coordinates. Off by default.",
+	},
+	{
+		name:    "default-fill-value",
+		env:     "VDSSLICE_DEFAULT_FILL_VALUE",
+		argname: "float",
+		field:   func(c *config) interface{} { return &c.defaultFillValue },
+		help: "Fill value of slice, fence, sample and traverse requests that do not\n" +
+			"give one. Absent data, such as dead traces, is replaced by it, and it\n" +
+			"is reported in the metadata. Accepts 'nan'. Unset by default, in which\n" +
+			"case absent data is returned as stored.",
+	},
+	{
+		name:    "default-interpolation",
+		env:     "VDSSLICE_DEFAULT_INTERPOLATION",
+		argname: "method",
+		field:   func(c *config) interface{} { return &c.defaultInterpolation },
+		help: "Interpolation method of fence, sample and attribute requests that do\n" +
+			"not give one. One of nearest, linear, cubic, angular and triangular.\n" +
+			"Defaults to nearest.",
+	},
+	{
+		name:    "max-sample-points",
+		env:     "VDSSLICE_MAX_SAMPLE_POINTS",
+		argname: "int",
+		field:   func(c *config) interface{} { return &c.maxSamplePoints },
+		help: "Max number of points in a single sample request. A value of zero\n" +
+			"means no limit. Defaults to 100000.",
+		reloadable: true,
+	},
+	{
+		name:    "max-attributes",
+		env:     "VDSSLICE_MAX_ATTRIBUTES",
+		argname: "int",
+		field:   func(c *config) interface{} { return &c.maxAttributes },
+		help: "Max number of attributes in a single attribute request. A value of\n" +
+			"zero means no limit. Defaults to 32.",
+		reloadable: true,
+	},
+	{
+		name:    "max-batch-requests",
+		env:     "VDSSLICE_MAX_BATCH_REQUESTS",
+		argname: "int",
+		field:   func(c *config) interface{} { return &c.maxBatchRequests },
+		help: "Max number of requests in a single batch request. A value of zero\n" +
+			"means no limit. Defaults to 20.",
+		reloadable: true,
+	},
+	{
+		name:    "max-metadata-list",
+		env:     "VDSSLICE_MAX_METADATA_LIST",
+		argname: "int",
+		field:   func(c *config) interface{} { return &c.maxMetadataList },
+		help: "Max number of VDSs in a single metadata request with vdsList. A value\n" +
+			"of zero means no limit. Defaults to 100.",
+		reloadable: true,
+	},
+	{
+		name:    "max-traverse-segments",
+		env:     "VDSSLICE_MAX_TRAVERSE_SEGMENTS",
+		argname: "int",
+		field:   func(c *config) interface{} { return &c.maxTraverseSegments },
+		help: "Max number of segments in a single traverse request. A value of\n" +
+			"zero means no limit. Defaults to 50.",
+		reloadable: true,
+	},
+	{
+		name:    "max-surface-points",
+		env:     "VDSSLICE_MAX_SURFACE_POINTS",
+		argname: "int",
+		field:   func(c *config) interface{} { return &c.maxSurfacePoints },
+		help: "Max number of points in a single point surface, and of nodes in\n" +
+			"the grid it is gridded onto. A value of zero means no limit.\n" +
+			"Defaults to 1000000.",
+		reloadable: true,
+	},
+	{
+		name:    "enabled-endpoints",
+		env:     "VDSSLICE_ENABLED_ENDPOINTS",
+		argname: "string",
+		field:   func(c *config) interface{} { return &c.enabledEndpoints },
+		help: "Comma separated list of the endpoints to serve, of: metadata, slice,\n" +
+			"fence, sample, traverse, batch, compatibility and attributes.\n" +
+			"Requests to the others get 404, both over http and grpc. All\n" +
+			"endpoints are served if not set.",
+	},
+	{
+		name:    "grpc-port",
+		env:     "VDSSLICE_GRPC_PORT",
+		argname: "int",
+		field:   func(c *config) interface{} { return &c.grpcPort },
+		help:    "Port to serve the gRPC API on. The gRPC API is disabled if not set.",
+	},
+	{
+		name:    "request-timeout",
+		env:     "VDSSLICE_REQUEST_TIMEOUT",
+		argname: "int",
+		field:   func(c *config) interface{} { return &c.requestTimeout },
+		help: "Seconds a request may take before it fails with 504. Clients can\n" +
+			"ask for less with the X-Request-Deadline or Grpc-Timeout header,\n" +
+			"but not for more. A value of zero means no timeout. Defaults to 0.",
+	},
+	{
+		name:    "attribute-workers",
+		env:     "VDSSLICE_ATTRIBUTE_WORKERS",
+		argname: "int",
+		field:   func(c *config) interface{} { return &c.attributeWorkers },
+		help: "Number of workers that compute the attributes of all requests.\n" +
+			"Concurrent attribute requests share them, taking turns. A value of\n" +
+			"zero means one per CPU. Defaults to 0.",
+	},
+	{
+		name:    "audit-log",
+		env:     "VDSSLICE_AUDIT_LOG",
+		argname: "path",
+		field:   func(c *config) interface{} { return &c.auditLog },
+		help: "File to record every data and metadata request in, one json object\n" +
+			"per line: who accessed which cube when, and with what outcome.\n" +
+			"Neither credentials nor surfaces are recorded. No audit log if not set.",
+	},
+	{
+		name:    "audit-log-max-size",
+		env:     "VDSSLICE_AUDIT_LOG_MAX_SIZE",
+		argname: "int",
+		field:   func(c *config) interface{} { return &c.auditLogMaxSize },
+		help: "Megabytes the audit log may grow to before it is rotated to\n" +
+			"<audit-log>.1. A value of zero disables rotation. Defaults to 100.",
+	},
+	{
+		name:    "audit-log-max-files",
+		env:     "VDSSLICE_AUDIT_LOG_MAX_FILES",
+		argname: "int",
+		field:   func(c *config) interface{} { return &c.auditLogMaxFiles },
+		help: "Number of rotated audit logs to keep, the oldest are removed. A\n" +
+			"value of zero keeps all of them. Defaults to 10.",
+	},
+	{
+		name:    "audit-webhook",
+		env:     "VDSSLICE_AUDIT_WEBHOOK",
+		argname: "url",
+		field:   func(c *config) interface{} { return &c.auditWebhook },
+		help: "Url to POST every audit record to, as json, e.g. for a SIEM. Can be\n" +
+			"combined with --audit-log. No webhook if not set.",
+	},
+	{
+		name:    "audit-webhook-retries",
+		env:     "VDSSLICE_AUDIT_WEBHOOK_RETRIES",
+		argname: "int",
+		field:   func(c *config) interface{} { return &c.auditWebhookRetries },
+		help: "Number of times a record is retried when the webhook is\n" +
+			"unreachable or responds 429 or 5xx. Defaults to 3.",
+	},
+	{
+		name:    "shutdown-timeout",
+		env:     "VDSSLICE_SHUTDOWN_TIMEOUT",
+		argname: "int",
+		field:   func(c *config) interface{} { return &c.shutdownTimeout },
+		help: "Seconds to wait for in-flight requests to finish on SIGINT and SIGTERM,\n" +
+			"before the servers are stopped forcefully. Defaults to 30.",
+	},
+	{
+		name:    "tls-cert",
+		env:     "VDSSLICE_TLS_CERT",
+		argname: "path",
+		field:   func(c *config) interface{} { return &c.tlsCert },
+		help: "PEM encoded certificate, or chain, to serve https and grpc with.\n" +
+			"The server terminates TLS itself, and negotiates HTTP/2, if set.\n" +
+			"Requires --tls-key. The certificate is re-read on SIGHUP.",
+	},
+	{
+		name:    "tls-key",
+		env:     "VDSSLICE_TLS_KEY",
+		argname: "path",
+		field:   func(c *config) interface{} { return &c.tlsKey },
+		help:    "PEM encoded private key of --tls-cert.",
+	},
+	{
+		name:    "tls-client-ca",
+		env:     "VDSSLICE_TLS_CLIENT_CA",
+		argname: "path",
+		field:   func(c *config) interface{} { return &c.tlsClientCA },
+		help: "PEM encoded CA bundle. If set, clients must present a certificate\n" +
+			"signed by one of the CAs in it (mutual TLS). Requires --tls-cert.",
+	},
+	{
+		name:    "metrics-tls-cert",
+		env:     "VDSSLICE_METRICS_TLS_CERT",
+		argname: "path",
+		field:   func(c *config) interface{} { return &c.metricsTLSCert },
+		help: "PEM encoded certificate to serve /metrics over https with. Independent\n" +
+			"of --tls-cert, /metrics is served over plain http if not set.\n" +
+			"Requires --metrics-tls-key. The certificate is re-read on SIGHUP.",
+	},
+	{
+		name:    "metrics-tls-key",
+		env:     "VDSSLICE_METRICS_TLS_KEY",
+		argname: "path",
+		field:   func(c *config) interface{} { return &c.metricsTLSKey },
+		help:    "PEM encoded private key of --metrics-tls-cert.",
+	},
+	{
+		name:    "warm-up",
+		env:     "VDSSLICE_WARM_UP",
+		argname: "string",
+		field:   func(c *config) interface{} { return &c.warmup },
+		help: "Comma-separated list of VDSs to open at startup, such that the first\n" +
+			"requests against them are fast. The VDSs are opened with the server's\n" +
+			"credentials, or a sas in the url. /ready reports not ready until\n" +
+			"warm-up is done. VDSs that fail to warm up are logged and reported by\n" +
+			"/ready, but don't stop the server.",
+	},
+	{
+		name:  "warm-up-metadata",
+		env:   "VDSSLICE_WARM_UP_METADATA",
+		field: func(c *config) interface{} { return &c.warmupMetadata },
+		help: "Fetch the metadata of the VDSs in --warm-up into the cache.\n" +
+			"Off by default.",
+	},
+	{
+		name:  "warm-up-async",
+		env:   "VDSSLICE_WARM_UP_ASYNC",
+		field: func(c *config) interface{} { return &c.warmupAsync },
+		help: "Report ready while warm-up is still running, rather than after.\n" +
+			"Off by default.",
+	},
+	{
+		name:    "vds-aliases",
+		env:     "VDSSLICE_VDS_ALIASES",
+		argname: "path",
+		field:   func(c *config) interface{} { return &c.vdsAliases },
+		help: "YAML file that maps aliases, e.g. survey names, to a url and optionally\n" +
+			"a sas, such that requests can give the alias as vds. Anything without\n" +
+			"a scheme or a path separator is taken to be an alias.",
+	},
+	{
+		name:    "vds-catalogue",
+		env:     "VDSSLICE_VDS_CATALOGUE",
+		argname: "url",
+		field:   func(c *config) interface{} { return &c.vdsCatalogue },
+		help: "Base url of a catalogue to resolve aliases by, as for --vds-aliases.\n" +
+			"The alias is looked up by GET <url>/<alias>, which is expected to\n" +
+			"return {\"url\": ..., \"sas\": ...}, or 404 for unknown aliases.",
+	},
+	{
+		name:    "vds-resolver-ttl",
+		env:     "VDSSLICE_VDS_RESOLVER_TTL",
+		argname: "int",
+		field:   func(c *config) interface{} { return &c.vdsResolverTTL },
+		help: "Time, in seconds, to remember aliases resolved by the catalogue.\n" +
+			"Defaults to 60.",
+	},
+}
+
+/* Parse raw into the field, which is a pointer to a field of config */
+func setField(field interface{}, raw string) error {
+	switch field := field.(type) {
+	case *string:
+		*field = raw
+	case *bool:
+		value, err := strconv.ParseBool(strings.TrimSpace(raw))
+		if err != nil {
+			return fmt.Errorf("cannot parse '%s', expected true or false", raw)
+		}
+		*field = value
+	case *uint32:
+		value, err := strconv.ParseUint(strings.TrimSpace(raw), 10, 32)
+		if err != nil {
+			return fmt.Errorf("cannot parse '%s', expected a non-negative integer", raw)
+		}
+		*field = uint32(value)
+	case *uint64:
+		value, err := strconv.ParseUint(strings.TrimSpace(raw), 10, 64)
+		if err != nil {
+			return fmt.Errorf("cannot parse '%s', expected a non-negative integer", raw)
+		}
+		*field = value
+	default:
+		panic(fmt.Sprintf("unsupported setting type %T", field))
+	}
+	return nil
+}
+
+/** The command line of the server
+ *
+ * Holds a flag for every setting, and the flags that control the server
+ * itself rather than configure it. Flags of settings are read as strings,
+ * such that they are parsed, and fail, just like the other sources.
+ */
+type commandLine struct {
+	set         *getopt.Set
+	help        *bool
+	printConfig *bool
+	configFile  *string
+	flags       map[string]interface{}
+}
+
+func newCommandLine() *commandLine {
+	set := getopt.New()
+	cl := &commandLine{
+		set:   set,
+		help:  set.BoolLong("help", 0, "print this help text"),
+		flags: map[string]interface{}{},
+	}
+
+	cl.configFile = set.StringLong(
+		"config",
+		0,
+		"",
+		"Path to a YAML config file. Its keys are the names of the flags\n"+
+			"below, e.g. 'cache-size: 512'. Environment variables and flags take\n"+
+			"precedence over the file. On SIGHUP the config is loaded again, and\n"+
+			"the settings that can be reloaded are applied without a restart.\n"+
+			"Can also be set by environment variable 'VDSSLICE_CONFIG'",
+		"path",
+	)
+
+	cl.printConfig = set.BoolLong(
+		"print-config",
+		0,
+		"Print the effective configuration as YAML, with secrets masked, and\n"+
+			"exit. The output is a valid config file.",
+	)
+
+	for _, s := range settings {
+		help := fmt.Sprintf(
+			"%s\nCan also be set by environment variable '%s'",
+			s.help,
+			s.env,
+		)
+		if s.reloadable {
+			help += "\nCan be reloaded without a restart, see --config"
+		}
+		if s.argname == "" {
+			cl.flags[s.name] = set.BoolLong(s.name, 0, help)
+		} else {
+			cl.flags[s.name] = set.StringLong(s.name, 0, "", help, s.argname)
+		}
+	}
+	return cl
+}
+
+func (cl *commandLine) parse(args []string) error {
+	return cl.set.Getopt(args, nil)
+}
+
+func (cl *commandLine) usage(w io.Writer) {
+	cl.set.PrintUsage(w)
+}
+
+/** Load the config from all sources, see config
+ *
+ * Errors name the setting and the source of the offending value, e.g.
+ * "cache-size: cannot parse '2Gib', expected a non-negative integer
+ * (environment variable VDSSLICE_CACHE_SIZE)".
+ */
+func loadConfig(cl *commandLine, getenv func(string) string) (config, error) {
+	cfg := defaultConfig()
+
+	path := *cl.configFile
+	if path == "" {
+		path = getenv("VDSSLICE_CONFIG")
+	}
+	if path != "" {
+		err := cfg.loadFile(path)
+		if err != nil {
+			return cfg, err
+		}
+	}
+
+	for _, s := range settings {
+		raw := getenv(s.env)
+		if raw == "" {
+			continue
+		}
+		err := setField(s.field(&cfg), raw)
+		if err != nil {
+			return cfg, fmt.Errorf(
+				"%s: %v (environment variable %s)",
+				s.name,
+				err,
+				s.env,
+			)
+		}
+	}
+
+	for _, s := range settings {
+		if !cl.set.IsSet(s.name) {
+			continue
+		}
+		switch flag := cl.flags[s.name].(type) {
+		case *bool:
+			*s.field(&cfg).(*bool) = *flag
+		case *string:
+			err := setField(s.field(&cfg), *flag)
+			if err != nil {
+				return cfg, fmt.Errorf("%s: %v (flag --%s)", s.name, err, s.name)
+			}
+		}
+	}
+
+	return cfg, cfg.validate()
+}
+
+/*
+ * Unlike the other lists, empty entries are kept, such that they are
+ * rejected by the allowlist rather than silently dropped
+ */
+/* Whether storage-accounts has a pattern with the scheme */
+func (c *config) listsScheme(scheme string) bool {
+	for _, pattern := range c.storageAccountList() {
+		u, err := url.Parse(strings.TrimSpace(pattern))
+		if err == nil && strings.EqualFold(u.Scheme, scheme) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *config) storageAccountList() []string {
+	if len(c.storageAccounts) == 0 {
+		return nil
+	}
+	return strings.Split(c.storageAccounts, ",")
+}
+
+/* Split a comma separated setting into its non-empty entries */
+func splitList(value string) []string {
+	var entries []string
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry != "" {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+func lookupSetting(name string) (setting, bool) {
+	for _, s := range settings {
+		if s.name == name {
+			return s, true
+		}
+	}
+	return setting{}, false
+}
+
+/** Read the settings of a YAML config file into c
+ *
+ * The file is a flat mapping from setting names to values. Unknown settings
+ * are rejected, such that typos don't go unnoticed.
+ */
+func (c *config) loadFile(path string) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("config file: %v", err)
+	}
+
+	var document yaml.Node
+	err = yaml.Unmarshal(content, &document)
+	if err != nil {
+		return fmt.Errorf("config file %s: %v", path, err)
+	}
+	/* An empty file has no content at all */
+	if len(document.Content) == 0 {
+		return nil
+	}
+
+	mapping := document.Content[0]
+	if mapping.Kind != yaml.MappingNode {
+		return fmt.Errorf(
+			"config file %s: expected a mapping of settings to values",
+			path,
+		)
+	}
+
+	for i := 0; i < len(mapping.Content); i += 2 {
+		key := mapping.Content[i]
+		value := mapping.Content[i+1]
+
+		s, ok := lookupSetting(key.Value)
+		if !ok {
+			return fmt.Errorf(
+				"config file %s, line %d: unknown setting '%s'",
+				path,
+				key.Line,
+				key.Value,
+			)
+		}
+		if value.Kind != yaml.ScalarNode {
+			return fmt.Errorf(
+				"%s: expected a single value (config file %s, line %d)",
+				s.name,
+				path,
+				value.Line,
+			)
+		}
+
+		err = setField(s.field(c), value.Value)
+		if err != nil {
+			return fmt.Errorf(
+				"%s: %v (config file %s, line %d)",
+				s.name,
+				err,
+				path,
+				value.Line,
+			)
+		}
+	}
+	return nil
+}
+
+func validatePort(name string, port uint32) error {
+	if port > 65535 {
+		return fmt.Errorf("%s: %d is not a valid port", name, port)
+	}
+	return nil
+}
+
+/* Check the settings that can be wrong even when they parse */
+func (c *config) validate() error {
+	if c.port == 0 {
+		return fmt.Errorf("port: must be set")
+	}
+
+	ports := map[string]uint32{"port": c.port}
+	if c.metrics {
+		ports["metrics-port"] = c.metricsPort
+	}
+	if c.grpcPort > 0 {
+		ports["grpc-port"] = c.grpcPort
+	}
+
+	/* Iterate the settings rather than the map, for a stable error */
+	seen := map[uint32]string{}
+	for _, s := range settings {
+		port, ok := ports[s.name]
+		if !ok {
+			continue
+		}
+		if err := validatePort(s.name, port); err != nil {
+			return err
+		}
+		if other, ok := seen[port]; ok {
+			return fmt.Errorf("%s: %d is already used by %s", s.name, port, other)
+		}
+		seen[port] = s.name
+	}
+
+	if (c.tlsCert == "") != (c.tlsKey == "") {
+		return fmt.Errorf("tls-cert and tls-key must be given together")
+	}
+	if (c.metricsTLSCert == "") != (c.metricsTLSKey == "") {
+		return fmt.Errorf("metrics-tls-cert and metrics-tls-key must be given together")
+	}
+	if c.tlsClientCA != "" && c.tlsCert == "" {
+		return fmt.Errorf("tls-client-ca: requires tls-cert")
+	}
+	if c.vdsAliases != "" && c.vdsCatalogue != "" {
+		return fmt.Errorf("vds-aliases and vds-catalogue are mutually exclusive")
+	}
+
+	_, err := api.NewEnabledEndpoints(splitList(c.enabledEndpoints))
+	if err != nil {
+		return fmt.Errorf("enabled-endpoints: %v", err)
+	}
+
+	_, err = core.NewAllowlist(c.storageAccountList())
+	if err != nil {
+		return fmt.Errorf("storage-accounts: %v", err)
+	}
+
+	/*
+	 * Buckets are read with the server's own credentials, which without an
+	 * allowlist would give access to everything the server can read
+	 */
+	ambient := []struct {
+		name    string
+		enabled bool
+	}{{"s3", c.s3}, {"gs", c.gs}}
+	for _, backend := range ambient {
+		if backend.enabled && !c.listsScheme(backend.name) {
+			return fmt.Errorf(
+				"%s: requires storage-accounts to list the %s:// buckets "+
+					"that may be read",
+				backend.name,
+				backend.name,
+			)
+		}
+	}
+
+	for _, proxy := range clientip.ParseTrustedProxies(c.trustedProxies) {
+		_, _, err := net.ParseCIDR(proxy)
+		if err != nil && net.ParseIP(proxy) == nil {
+			return fmt.Errorf(
+				"trusted-proxies: '%s' is neither an IP nor a CIDR range",
+				proxy,
+			)
+		}
+	}
+
+	return nil
+}
+
+/* The value of the setting in c */
+func (s setting) value(c *config) interface{} {
+	switch field := s.field(c).(type) {
+	case *string:
+		return *field
+	case *bool:
+		return *field
+	case *uint32:
+		return *field
+	case *uint64:
+		return *field
+	}
+	return nil
+}
+
+/** Mask credentials in a setting value before it is printed
+ *
+ * Values are comma separated lists at most. Any entry that is a url gets the
+ * password and the query, which may be a sas, masked.
+ */
+func maskSecrets(value string) string {
+	entries := strings.Split(value, ",")
+	for i, entry := range entries {
+		u, err := url.Parse(strings.TrimSpace(entry))
+		if err != nil || (u.User == nil && u.RawQuery == "") {
+			continue
+		}
+		if u.RawQuery != "" {
+			u.RawQuery = "xxxxx"
+		}
+		entries[i] = u.Redacted()
+	}
+	return strings.Join(entries, ",")
+}
+
+/** Write the config as YAML, in the form of a config file
+ *
+ * Secrets are masked, so the output is safe to log.
+ */
+func (c *config) print(w io.Writer) error {
+	mapping := &yaml.Node{Kind: yaml.MappingNode}
+	for _, s := range settings {
+		value := s.value(c)
+		if str, ok := value.(string); ok {
+			value = maskSecrets(str)
+		}
+
+		var node yaml.Node
+		err := node.Encode(value)
+		if err != nil {
+			return err
+		}
+		mapping.Content = append(
+			mapping.Content,
+			&yaml.Node{Kind: yaml.ScalarNode, Value: s.name},
+			&node,
+		)
+	}
+
+	encoder := yaml.NewEncoder(w)
+	defer encoder.Close()
+	return encoder.Encode(mapping)
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+/* The echo in the metadata part of the response, nil if there is none */
+func requestEcho(t *testing.T, testcase endpointTest) map[string]interface{} {
+	w := setupTest(t, testcase)
+	requireStatus(t, testcase, w)
+	parts := readMultipartData(t, w)
+
+	var metadata map[string]interface{}
+	require.NoError(t, json.Unmarshal(parts[0], &metadata))
+	echo, ok := metadata["request"]
+	if !ok {
+		return nil
+	}
+	return echo.(map[string]interface{})
+}
+
+func TestSliceEcho(t *testing.T) {
+	testcase := sliceTest{
+		baseTest{
+			name:           "Slice with echo",
+			method:         http.MethodPost,
+			expectedStatus: http.StatusOK,
+		},
+		testSliceRequest{
+			Vds:       well_known,
+			Direction: "0",
+			Lineno:    1,
+			Sas:       "n/a",
+			Bounds: []testBound{
+				{Direction: "Sample", Lower: 4, Upper: 8},
+			},
+			Echo: true,
+		},
+	}
+
+	echo := requestEcho(t, testcase)
+	require.NotNil(t, echo, "Expected the metadata to echo the request")
+	require.NotEmpty(t, echo["vds"])
+	delete(echo, "vds")
+
+	expected := map[string]interface{}{
+		"endpoint":   "slice",
+		"direction":  "i",
+		"lineno":     1.0,
+		"linenoMode": "index",
+		"bounds": []interface{}{
+			map[string]interface{}{
+				"direction": "sample",
+				"lower":     4.0,
+				"upper":     8.0,
+				"mode":      "annotation",
+			},
+		},
+		"verticalUnit": "ms",
+	}
+	require.Equal(t, expected, echo)
+
+	/* Without echo the metadata is as it always was */
+	testcase.slice.Echo = false
+	require.Nil(t, requestEcho(t, testcase))
+}
+
+func TestFenceEcho(t *testing.T) {
+	testcase := fenceTest{
+		baseTest{
+			name:           "Fence with echo",
+			method:         http.MethodPost,
+			expectedStatus: http.StatusOK,
+		},
+		testFenceRequest{
+			Vds:              well_known,
+			CoordinateSystem: "IJ",
+			Coordinates:      [][]float32{{0, 0}, {1, 1}, {2, 0}},
+			FillValue:        -999.25,
+			Sas:              "n/a",
+			Echo:             true,
+		},
+	}
+
+	echo := requestEcho(t, testcase)
+	require.NotNil(t, echo, "Expected the metadata to echo the request")
+	require.Equal(t, "fence", echo["endpoint"])
+	require.Equal(t, "ij", echo["coordinateSystem"])
+	require.Equal(t, 3.0, echo["coordinates"])
+	require.Equal(t, "nearest", echo["interpolation"])
+	require.Equal(t, "ms", echo["verticalUnit"])
+	require.Equal(t, -999.25, echo["fillValue"])
+	require.NotContains(t, echo, "sas")
+}
+
+func TestAttributeAlongSurfaceEcho(t *testing.T) {
+	testcase := attributeAlongSurfaceTest{
+		baseTest{
+			name:           "Attributes with echo",
+			method:         http.MethodPost,
+			expectedStatus: http.StatusOK,
+		},
+		testAttributeAlongSurfaceRequest{
+			Vds:        well_known,
+			Sas:        "n/a",
+			Values:     [][]float32{{8, 8}, {8, 8}, {8, 8}},
+			Above:      4,
+			Below:      4,
+			Attributes: []string{"Min", "max"},
+			Echo:       true,
+		},
+	}
+
+	echo := requestEcho(t, testcase)
+	require.NotNil(t, echo, "Expected the metadata to echo the request")
+	require.Equal(t, "attributes/surface/along", echo["endpoint"])
+	require.Equal(t, 6.0, echo["surfacePoints"])
+	require.Equal(t, []interface{}{"min", "max"}, echo["attributes"])
+	require.Equal(t, "cubic", echo["interpolation"])
+	require.Equal(t, "cubic", echo["verticalInterpolation"])
+	require.Equal(t, 4.0, echo["above"])
+	require.Equal(t, 4.0, echo["below"])
+	/* No stepsize is the stepsize of the cube */
+	require.Equal(t, 4.0, echo["stepsize"])
+	require.Equal(t, "ms", echo["verticalUnit"])
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"math"
 	"net/http"
+	"os"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -155,3 +156,153 @@ func TestSupportedFormatsHorizon(t *testing.T) {
 		}
 	}
 }
+
+/*
+ * Variants of well_known that are stored as integers, with the integer scale
+ * and offset they are quantized by. Generated by
+ * testdata/scripts/make_well_known_formats.py, and skipped without it.
+ */
+var storedFormats = []struct {
+	vds    string
+	format string
+	dtype  string
+	scale  float64
+	offset float64
+}{
+	{"../../testdata/well_known/well_known_u8.vds", "U8", "<u1", 0.3, 99},
+	{"../../testdata/well_known/well_known_u16.vds", "U16", "<u2", 0.001, 99},
+}
+
+/** Test cubes stored as integers are read as the values they represent
+ *
+ * The slices are compared to the float32 values of well_known, within the
+ * quantization error of half a scale.
+ */
+func TestStoredFormats(t *testing.T) {
+	/* Inline 3 of well_known */
+	expected := []float64{108, 109, 110, 111, 112, 113, 114, 115}
+
+	for _, stored := range storedFormats {
+		t.Run(stored.format, func(t *testing.T) {
+			if _, err := os.Stat(stored.vds); err != nil {
+				t.Skipf("%s not found, generate it with make_well_known_formats.py", stored.vds)
+			}
+			tolerance := stored.scale/2 + 1e-4
+
+			metadataTest := metadataTest{
+				baseTest{
+					name:           "metadata",
+					method:         http.MethodGet,
+					expectedStatus: http.StatusOK,
+				},
+				testMetadataRequest{Vds: stored.vds, Sas: "n/a"},
+			}
+			w := setupTest(t, metadataTest)
+			requireStatus(t, metadataTest, w)
+
+			var metadata struct {
+				StoredFormat struct {
+					Format        string  `json:"format"`
+					IntegerScale  float64 `json:"integerScale"`
+					IntegerOffset float64 `json:"integerOffset"`
+				} `json:"storedFormat"`
+			}
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &metadata))
+			require.Equal(t, stored.format, metadata.StoredFormat.Format)
+			require.InDelta(t, stored.scale, metadata.StoredFormat.IntegerScale, 1e-6)
+			require.InDelta(t, stored.offset, metadata.StoredFormat.IntegerOffset, 1e-6)
+
+			for _, raw := range []bool{false, true} {
+				sliceTest := sliceTest{
+					baseTest{
+						name:           fmt.Sprintf("slice, raw: %v", raw),
+						method:         http.MethodPost,
+						expectedStatus: http.StatusOK,
+					},
+					testSliceRequest{
+						Vds:       stored.vds,
+						Direction: "i",
+						Lineno:    1,
+						Sas:       "n/a",
+						Raw:       raw,
+					},
+				}
+				w := setupTest(t, sliceTest)
+				requireStatus(t, sliceTest, w)
+				parts := readMultipartData(t, w)
+
+				var sliceMetadata map[string]interface{}
+				require.NoError(t, json.Unmarshal(parts[0], &sliceMetadata))
+
+				var actual []float64
+				if raw {
+					require.Equal(t, stored.dtype, sliceMetadata["format"])
+					for _, value := range toUnsigned(t, parts[1], stored.dtype) {
+						actual = append(actual, value*stored.scale+stored.offset)
+					}
+				} else {
+					require.Equal(t, "<f4", sliceMetadata["format"])
+					require.Len(t, parts[1], 4*len(expected))
+					for i := range expected {
+						bits := binary.LittleEndian.Uint32(parts[1][i*4:])
+						actual = append(actual, float64(math.Float32frombits(bits)))
+					}
+				}
+
+				require.Len(t, actual, len(expected))
+				for i := range expected {
+					require.InDeltaf(t, expected[i], actual[i], tolerance,
+						"Test '%s', sample %d", sliceTest.name, i)
+				}
+			}
+		})
+	}
+}
+
+/* Cubes stored as float32 are returned the same, raw or not */
+func TestRawSliceOfFloatCube(t *testing.T) {
+	var data [][]byte
+	for _, raw := range []bool{false, true} {
+		testcase := sliceTest{
+			baseTest{
+				name:           fmt.Sprintf("slice, raw: %v", raw),
+				method:         http.MethodPost,
+				expectedStatus: http.StatusOK,
+			},
+			testSliceRequest{
+				Vds:       well_known,
+				Direction: "i",
+				Lineno:    1,
+				Sas:       "n/a",
+				Raw:       raw,
+			},
+		}
+		w := setupTest(t, testcase)
+		requireStatus(t, testcase, w)
+		parts := readMultipartData(t, w)
+
+		var metadata map[string]interface{}
+		require.NoError(t, json.Unmarshal(parts[0], &metadata))
+		require.Equal(t, "<f4", metadata["format"])
+		data = append(data, parts[1])
+	}
+	require.Equal(t, data[0], data[1])
+}
+
+/* Little endian unsigned integers of the numpy-style format, e.g. <u2 */
+func toUnsigned(t *testing.T, data []byte, dtype string) []float64 {
+	var values []float64
+	switch dtype {
+	case "<u1":
+		for _, value := range data {
+			values = append(values, float64(value))
+		}
+	case "<u2":
+		for i := 0; i+2 <= len(data); i += 2 {
+			values = append(values, float64(binary.LittleEndian.Uint16(data[i:])))
+		}
+	default:
+		t.Fatalf("unexpected format %s", dtype)
+	}
+	return values
+}
@@ -1,174 +1,503 @@
 package main
 
 import (
+	"context"
+	"crypto/tls"
 	"fmt"
+	"math"
+	"net"
+	"net/http"
 	"os"
+	"os/signal"
+	"sort"
 	"strconv"
 	"strings"
+	"syscall"
+	"time"
 
 	"github.com/gin-contrib/gzip"
 	"github.com/gin-gonic/gin"
-	"github.com/pborman/getopt/v2"
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 
 	"github.com/equinor/vds-slice/api"
+	"github.com/equinor/vds-slice/api/vdsslicepb"
 	_ "github.com/equinor/vds-slice/docs"
+	"github.com/equinor/vds-slice/internal/audit"
 	"github.com/equinor/vds-slice/internal/cache"
+	"github.com/equinor/vds-slice/internal/clientip"
 	"github.com/equinor/vds-slice/internal/core"
 	"github.com/equinor/vds-slice/internal/logging"
 	"github.com/equinor/vds-slice/internal/metrics"
+	"github.com/equinor/vds-slice/internal/ratelimit"
 )
 
-type opts struct {
-	storageAccounts string
-	port            uint32
-	cacheSize       uint64
-	metrics         bool
-	metricsPort     uint32
+/*
+ * Version of the server. Injected at build time through ldflags, e.g:
+ *
+ *     go build -ldflags "-X main.version=$(git describe --tags --always)"
+ */
+var version = "dev"
+
+const megabyte = 1024 * 1024
+
+/** Register the seismic endpoints on the given route group
+ *
+ * The endpoints are registered both under the version prefix, e.g. /v1/slice,
+ * and without it, e.g. /slice. The latter are aliases for v1, kept around for
+ * the clients that were written before the API was versioned. Changes that
+ * break the existing responses go into a new version, and never into the
+ * unprefixed routes.
+ */
+func registerSeismicRoutes(seismic *gin.RouterGroup, endpoint *api.Endpoint) {
+	limitRequestSize := endpoint.LimitRequestSize
+	limitAttributeRequestSize := endpoint.LimitAttributeRequestSize
+
+	/*
+	 * Disabled endpoints keep their routes, but with a handler that answers
+	 * 404, such that clients are told why and metrics are labeled the same
+	 * in every deployment
+	 */
+	handlers := func(name string, handlers ...gin.HandlerFunc) []gin.HandlerFunc {
+		if endpoint.Endpoints.Enabled(name) {
+			return handlers
+		}
+		return []gin.HandlerFunc{api.EndpointDisabled(name)}
+	}
+
+	metadata := func(handler ...gin.HandlerFunc) []gin.HandlerFunc {
+		return handlers(api.EndpointMetadata, handler...)
+	}
+	seismic.GET("metadata", metadata(endpoint.MetadataGet)...)
+	seismic.HEAD("metadata", metadata(endpoint.MetadataHead)...)
+	seismic.POST("metadata", metadata(limitRequestSize, endpoint.MetadataPost)...)
+	seismic.GET("metadata/ranges", metadata(endpoint.RangesGet)...)
+
+	validate := func(handler ...gin.HandlerFunc) []gin.HandlerFunc {
+		if endpoint.ValidateLimiter != nil {
+			handler = append([]gin.HandlerFunc{endpoint.ValidateLimiter}, handler...)
+		}
+		return handler
+	}
+	seismic.GET("validate", validate(endpoint.ValidateGet)...)
+	seismic.POST("validate", validate(limitRequestSize, endpoint.ValidatePost)...)
+
+	slice := func(handler ...gin.HandlerFunc) []gin.HandlerFunc {
+		return handlers(api.EndpointSlice, handler...)
+	}
+	seismic.GET("slice", slice(endpoint.SliceGet)...)
+	seismic.HEAD("slice", slice(endpoint.SliceHead)...)
+	seismic.POST("slice", slice(limitRequestSize, endpoint.SlicePost)...)
+	seismic.GET("slice/progressive", slice(endpoint.SliceProgressiveGet)...)
+	seismic.POST(
+		"slice/progressive",
+		slice(limitRequestSize, endpoint.SliceProgressivePost)...,
+	)
+
+	fence := func(handler ...gin.HandlerFunc) []gin.HandlerFunc {
+		return handlers(api.EndpointFence, handler...)
+	}
+	seismic.GET("fence", fence(endpoint.FenceGet)...)
+	seismic.HEAD("fence", fence(endpoint.FenceHead)...)
+	seismic.POST("fence", fence(limitRequestSize, endpoint.FencePost)...)
+
+	seismic.POST("sample", handlers(
+		api.EndpointSample,
+		limitRequestSize,
+		endpoint.SamplePost,
+	)...)
+
+	seismic.POST("traverse", handlers(
+		api.EndpointTraverse,
+		limitRequestSize,
+		endpoint.TraversePost,
+	)...)
+
+	seismic.POST("batch", handlers(
+		api.EndpointBatch,
+		limitRequestSize,
+		endpoint.BatchPost,
+	)...)
+
+	seismic.POST("compatibility", handlers(
+		api.EndpointCompatibility,
+		limitRequestSize,
+		endpoint.CompatibilityPost,
+	)...)
+
+	attributes := seismic.Group("attributes")
+	attributesSurface := attributes.Group("surface")
+
+	attributesSurface.GET("along", handlers(
+		api.EndpointAttributes,
+		endpoint.AttributesAlongSurfaceGet,
+	)...)
+	attributesSurface.POST("along", handlers(
+		api.EndpointAttributes,
+		limitAttributeRequestSize,
+		endpoint.AttributesAlongSurfacePost,
+	)...)
+	attributesSurface.GET("between", handlers(
+		api.EndpointAttributes,
+		endpoint.AttributesBetweenSurfacesGet,
+	)...)
+	attributesSurface.POST("between", handlers(
+		api.EndpointAttributes,
+		limitAttributeRequestSize,
+		endpoint.AttributesBetweenSurfacesPost,
+	)...)
 }
 
-func parseAsUint32(fallback uint32, value string) uint32 {
-	if len(value) == 0 {
-		return fallback
+/** Answer OPTIONS requests for every route registered so far
+ *
+ * The response lists the methods allowed on the route in the Allow header.
+ * Must be called after all other routes are registered.
+ */
+func registerOptionsRoutes(app *gin.Engine) {
+	allowed := map[string][]string{}
+	for _, route := range app.Routes() {
+		allowed[route.Path] = append(allowed[route.Path], route.Method)
 	}
-	out, err := strconv.ParseUint(value, 10, 32)
-	if err != nil {
-		panic(err)
+
+	for path, methods := range allowed {
+		methods = append(methods, http.MethodOptions)
+		sort.Strings(methods)
+		allow := strings.Join(methods, ", ")
+
+		app.OPTIONS(path, func(ctx *gin.Context) {
+			ctx.Header("Allow", allow)
+			ctx.Status(http.StatusNoContent)
+		})
+	}
+}
+
+/* The methods of every path of app, as given in the Allow header */
+func allowedMethods(app *gin.Engine) map[string]string {
+	allowed := map[string][]string{}
+	for _, route := range app.Routes() {
+		allowed[route.Path] = append(allowed[route.Path], route.Method)
 	}
 
-	return uint32(out)
+	allow := map[string]string{}
+	for path, methods := range allowed {
+		sort.Strings(methods)
+		allow[path] = strings.Join(methods, ", ")
+	}
+	return allow
 }
 
-func parseAsUint64(fallback uint64, value string) uint64 {
-	if len(value) == 0 {
-		return fallback
+/** Answer unknown routes and unsupported methods with error responses
+ *
+ * The handlers only get the middleware of the app itself, not that of the
+ * groups, so the metrics middleware is added to them explicitly. 405s carry
+ * the Allow header, like the OPTIONS routes, which must be registered first.
+ */
+func registerFallbackRoutes(app *gin.Engine, metric *metrics.Metrics) {
+	allow := allowedMethods(app)
+	setAllow := func(ctx *gin.Context) {
+		if methods, ok := allow[ctx.Request.URL.Path]; ok {
+			ctx.Header("Allow", methods)
+		}
 	}
-	out, err := strconv.ParseUint(value, 10, 64)
-	if err != nil {
-		panic(err)
+
+	noRoute := []gin.HandlerFunc{api.NoRoute}
+	noMethod := []gin.HandlerFunc{setAllow, api.NoMethod}
+	if metric != nil {
+		observe := metrics.NewGinMiddleware(metric)
+		noRoute = append([]gin.HandlerFunc{observe}, noRoute...)
+		noMethod = append([]gin.HandlerFunc{observe}, noMethod...)
 	}
 
-	return out
+	app.HandleMethodNotAllowed = true
+	app.NoRoute(noRoute...)
+	app.NoMethod(noMethod...)
 }
 
-func parseAsString(fallback string, value string) string {
-	if len(value) == 0 {
-		return fallback
+/*
+ * Responses to HEAD requests have no body to compress, and compressing would
+ * replace the Content-Length we computed for the uncompressed response.
+ */
+/*
+ * Progressive responses are not compressed, as the gzip writer does not pass
+ * on flushes, which would hold every pass back until the response is done.
+ */
+func compress() gin.HandlerFunc {
+	gz := gzip.Gzip(gzip.BestSpeed)
+	return func(ctx *gin.Context) {
+		progressive := strings.HasSuffix(ctx.Request.URL.Path, "/progressive")
+		if ctx.Request.Method == http.MethodHead || progressive {
+			ctx.Next()
+			return
+		}
+		gz(ctx)
 	}
-	return value
 }
 
-func parseAsBool(fallback bool, value string) bool {
-	v, err := strconv.ParseBool(value)
-	if err != nil {
-		return fallback
+func setupApp(
+	app *gin.Engine,
+	endpoint *api.Endpoint,
+	metric *metrics.Metrics,
+	limiter gin.HandlerFunc,
+) {
+	app.Use(clientip.NewGinMiddleware())
+	app.Use(logging.FormattedLogger())
+	app.Use(api.Recovery())
+	if limiter != nil {
+		app.Use(limiter)
 	}
+	app.Use(compress())
+
+	seismic := app.Group("/")
+	seismic.Use(api.ErrorHandler)
+
+	if metric != nil {
+		seismic.Use(metrics.NewGinMiddleware(metric))
+	}
+	seismic.Use(endpoint.Deadline)
+
+	app.GET("/", endpoint.Health)
+	app.GET("/ready", endpoint.ReadyGet)
+	app.GET("/version", endpoint.VersionGet)
+
+	registerSeismicRoutes(seismic, endpoint)
+	registerSeismicRoutes(seismic.Group("v1"), endpoint)
+
+	app.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
+	registerDocsRoutes(app)
+	app.LoadHTMLFiles("docs/index.html")
 
-	return v
+	registerOptionsRoutes(app)
+	registerFallbackRoutes(app, metric)
 }
 
-func parseopts() opts {
-	help := getopt.BoolLong("help", 0, "print this help text")
+/** Set up the gRPC server, which shares the endpoint with the http app
+ *
+ * The attribute request size limit applies to all rpcs, as grpc has a single
+ * limit for the size of incoming messages. The server terminates TLS with
+ * tlsConfig, if given.
+ */
+func setupGrpcServer(
+	endpoint *api.Endpoint,
+	metric *metrics.Metrics,
+	tlsConfig *tls.Config,
+) *grpc.Server {
+	maxMessageSize := math.MaxInt32
+	if limit := endpoint.Limits.AttributeRequestSize; limit > 0 {
+		maxMessageSize = int(limit)
+	}
 
-	opts := opts{
-		storageAccounts: parseAsString("", os.Getenv("VDSSLICE_STORAGE_ACCOUNTS")),
-		port:            parseAsUint32(8080, os.Getenv("VDSSLICE_PORT")),
-		cacheSize:       parseAsUint64(0, os.Getenv("VDSSLICE_CACHE_SIZE")),
-		metrics:         parseAsBool(false, os.Getenv("VDSSLICE_METRICS")),
-		metricsPort:     parseAsUint32(8081, os.Getenv("VDSSLICE_METRICS_PORT")),
+	options := []grpc.ServerOption{grpc.MaxRecvMsgSize(maxMessageSize)}
+	if tlsConfig != nil {
+		options = append(
+			options,
+			grpc.Creds(credentials.NewTLS(tlsConfig.Clone())),
+		)
+	}
+	if metric != nil {
+		options = append(
+			options,
+			grpc.UnaryInterceptor(metrics.NewGrpcUnaryInterceptor(metric)),
+			grpc.StreamInterceptor(metrics.NewGrpcStreamInterceptor(metric)),
+		)
 	}
 
-	getopt.FlagLong(
-		&opts.storageAccounts,
-		"storage-accounts",
-		0,
-		"Comma-separated list of storage accounts that should be accepted by the API.\n"+
-			"Example: 'https://<account1>.blob.core.windows.net,https://<account2>.blob.core.windows.net'\n"+
-			"Can also be set by environment variable 'VDSSLICE_STORAGE_ACCOUNTS'",
-		"string",
-	)
+	server := grpc.NewServer(options...)
+	vdsslicepb.RegisterVdsSliceServer(server, api.NewGrpcServer(endpoint))
+	return server
+}
 
-	getopt.FlagLong(
-		&opts.port,
-		"port",
-		0,
-		"Port to start server on. Defaults to 8080.\n"+
-			"Can also be set by environment variable 'VDSSLICE_PORT'",
-		"int",
-	)
+/** Serve http, and grpc if given, until SIGINT or SIGTERM
+ *
+ * On either signal both servers stop accepting new requests, and in-flight
+ * requests get up to timeout to finish before the servers are stopped
+ * forcefully.
+ *
+ * The http server serves https, with HTTP/2, if it has a tls config.
+ */
+func serve(
+	server *http.Server,
+	grpcServer *grpc.Server,
+	grpcListener net.Listener,
+	timeout time.Duration,
+) {
+	failed := make(chan error, 2)
+	go func() {
+		failed <- listenAndServe(server)
+	}()
+	if grpcServer != nil {
+		go func() {
+			failed <- grpcServer.Serve(grpcListener)
+		}()
+	}
 
-	getopt.FlagLong(
-		&opts.cacheSize,
-		"cache-size",
-		0,
-		"Max size of the response cache. In megabytes. A value of zero effectively\n"+
-			"disables caching. Defaults to 0.\n"+
-			"Can also be set by environment variable 'VDSSLICE_CACHE_SIZE'",
-		"int",
-	)
+	shutdown := make(chan os.Signal, 1)
+	signal.Notify(shutdown, syscall.SIGINT, syscall.SIGTERM)
 
-	getopt.FlagLong(
-		&opts.metrics,
-		"metrics",
-		0,
-		"Turn on server metrics. Metrics are posted to /metrics using the\n"+
-			"prometheus data model. Off by default.\n"+
-			"Can also be set by environment variable 'VDSSLICE_METRICS'",
-	)
+	select {
+	case err := <-failed:
+		panic(err)
+	case <-shutdown:
+	}
 
-	getopt.FlagLong(
-		&opts.metricsPort,
-		"metrics-port",
-		0,
-		"Port to host the /metrics endpoint on. Metrics are always hosted on a\n"+
-			"different port than the server itself. This allows for them to be kept\n"+
-			"private, if desirable. Defaults to 8081.\n"+
-			"Ignored if metrics are not turned on. (see --metrics)\n"+
-			"Can also be set by enviroment variable 'VDSSLICE_METRICS_PORT'",
-		"int",
-	)
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
 
-	getopt.Parse()
-	if *help {
-		getopt.Usage()
-		os.Exit(0)
+	if grpcServer != nil {
+		stopped := make(chan struct{})
+		go func() {
+			grpcServer.GracefulStop()
+			close(stopped)
+		}()
+		defer func() {
+			select {
+			case <-stopped:
+			case <-ctx.Done():
+				grpcServer.Stop()
+			}
+		}()
 	}
 
-	return opts
+	if err := server.Shutdown(ctx); err != nil {
+		server.Close()
+	}
 }
 
-func setupApp(app *gin.Engine, endpoint *api.Endpoint, metric *metrics.Metrics) {
-	app.Use(logging.FormattedLogger())
-	app.Use(gin.Recovery())
-	app.Use(gzip.Gzip(gzip.BestSpeed))
+/*
+ * The certificates are given by the tls config rather than as files, such
+ * that they can be reloaded.
+ */
+func listenAndServe(server *http.Server) error {
+	if server.TLSConfig != nil {
+		return server.ListenAndServeTLS("", "")
+	}
+	return server.ListenAndServe()
+}
 
-	seismic := app.Group("/")
-	seismic.Use(api.ErrorHandler)
+/** The tls config for a certificate and key, if given
+ *
+ * Returns nil if cert is not set, i.e. TLS is not terminated by the server.
+ * The certificate is added to reloaders, for reloading on SIGHUP.
+ */
+func setupTLS(
+	cert string,
+	key string,
+	clientCA string,
+	reloaders *[]*certificateReloader,
+) (*tls.Config, error) {
+	if cert == "" {
+		return nil, nil
+	}
 
-	if metric != nil {
-		seismic.Use(metrics.NewGinMiddleware(metric))
+	reloader, err := newCertificateReloader(cert, key)
+	if err != nil {
+		return nil, err
 	}
+	*reloaders = append(*reloaders, reloader)
+	return newTLSConfig(reloader, clientCA)
+}
 
-	app.GET("/", endpoint.Health)
+/* Max time to wait for the catalogue to resolve an alias */
+const catalogueTimeout = 10 * time.Second
+
+/* The fill value of raw, or nil if raw is empty. Accepts nan */
+func parseFillValue(raw string) (*float32, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return nil, nil
+	}
+	value, err := strconv.ParseFloat(raw, 32)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse '%s', expected a number", raw)
+	}
+	fillValue := float32(value)
+	return &fillValue, nil
+}
+
+/* The limits of the endpoint, which are reloadable except for the cache size */
+func limits(cfg config) api.Limits {
+	return api.Limits{
+		CacheSize:            cfg.cacheSize,
+		RequestSize:          int64(cfg.maxRequestSize * megabyte),
+		AttributeRequestSize: int64(cfg.maxAttributeRequestSize * megabyte),
+		AttributeQuerySize:   int64(cfg.maxAttributeQuerySize),
+		FenceCoordinates:     int(cfg.maxFenceCoordinates),
+		SamplePoints:         int(cfg.maxSamplePoints),
+		Attributes:           int(cfg.maxAttributes),
+		BatchRequests:        int(cfg.maxBatchRequests),
+		MetadataList:         int(cfg.maxMetadataList),
+		TraverseSegments:     int(cfg.maxTraverseSegments),
+		SurfacePoints:        int(cfg.maxSurfacePoints),
+	}
+}
 
-	seismic.GET("metadata", endpoint.MetadataGet)
-	seismic.POST("metadata", endpoint.MetadataPost)
+/* The rate and burst of the rate limiter, where a rate of zero is no limit */
+func rateLimit(cfg config) (float64, int) {
+	burst := cfg.rateLimitBurst
+	if burst == 0 {
+		burst = cfg.rateLimit
+	}
+	return float64(cfg.rateLimit), int(burst)
+}
 
-	seismic.GET("slice", endpoint.SliceGet)
-	seismic.POST("slice", endpoint.SlicePost)
+/* The rate and burst of the rate limiter of validate */
+func validateRateLimit(cfg config) (float64, int) {
+	burst := cfg.validateRateLimitBurst
+	if burst == 0 {
+		burst = cfg.validateRateLimit
+	}
+	return float64(cfg.validateRateLimit), int(burst)
+}
 
-	seismic.GET("fence", endpoint.FenceGet)
-	seismic.POST("fence", endpoint.FencePost)
+/** The resolver of vds aliases, if any is configured
+ *
+ * Aliases from the catalogue are cached for the configured ttl. The mapping
+ * file is read once, at startup.
+ */
+func setupResolver(cfg config) (core.VdsResolver, error) {
+	if cfg.vdsAliases != "" {
+		return core.NewStaticResolver(cfg.vdsAliases)
+	}
+	if cfg.vdsCatalogue == "" {
+		return nil, nil
+	}
 
-	attributes := seismic.Group("attributes")
-	attributesSurface := attributes.Group("surface")
+	resolver := core.NewCatalogueResolver(cfg.vdsCatalogue, catalogueTimeout)
+	if cfg.vdsResolverTTL == 0 {
+		return resolver, nil
+	}
+	return core.NewCachingResolver(
+		resolver,
+		time.Duration(cfg.vdsResolverTTL)*time.Second,
+	), nil
+}
 
-	attributesSurface.POST("along", endpoint.AttributesAlongSurfacePost)
-	attributesSurface.POST("between", endpoint.AttributesBetweenSurfacesPost)
+/** The audit log of the config, nil if neither a file nor webhook is set */
+func setupAudit(cfg config) (*audit.Logger, error) {
+	var sinks []audit.Sink
+	if cfg.auditLog != "" {
+		sink, err := audit.NewFileSink(
+			cfg.auditLog,
+			int64(cfg.auditLogMaxSize)*1024*1024,
+			int(cfg.auditLogMaxFiles),
+		)
+		if err != nil {
+			return nil, err
+		}
+		sinks = append(sinks, sink)
+	}
+	if cfg.auditWebhook != "" {
+		sinks = append(sinks, audit.NewWebhookSink(
+			cfg.auditWebhook,
+			int(cfg.auditWebhookRetries),
+		))
+	}
 
-	app.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
-	app.LoadHTMLFiles("docs/index.html")
+	if len(sinks) == 0 {
+		return nil, nil
+	}
+	return audit.NewLogger(audit.MultiSink(sinks...)), nil
 }
 
 // @title        VDS-slice API
@@ -180,21 +509,177 @@ func setupApp(app *gin.Engine, endpoint *api.Endpoint, metric *metrics.Metrics)
 // @license.url  https://www.gnu.org/licenses/agpl-3.0.en.html
 // @schemes      https
 func main() {
-	opts := parseopts()
+	cl := newCommandLine()
+	err := cl.parse(os.Args)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		cl.usage(os.Stderr)
+		os.Exit(1)
+	}
+	if *cl.help {
+		cl.usage(os.Stderr)
+		os.Exit(0)
+	}
+
+	cfg, err := loadConfig(cl, os.Getenv)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid configuration: %v\n", err)
+		os.Exit(1)
+	}
+	if *cl.printConfig {
+		cfg.print(os.Stdout)
+		os.Exit(0)
+	}
+	fmt.Println("Effective configuration:")
+	cfg.print(os.Stdout)
+
+	var reloaders []*certificateReloader
+	tlsConfig, err := setupTLS(
+		cfg.tlsCert,
+		cfg.tlsKey,
+		cfg.tlsClientCA,
+		&reloaders,
+	)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid TLS configuration: %v\n", err)
+		os.Exit(1)
+	}
+	metricsTLSConfig, err := setupTLS(
+		cfg.metricsTLSCert,
+		cfg.metricsTLSKey,
+		"",
+		&reloaders,
+	)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid TLS configuration: %v\n", err)
+		os.Exit(1)
+	}
+	reloadOnHangup(reloaders...)
+
+	allowlist, err := core.NewAllowlist(cfg.storageAccountList())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid storage accounts: %v\n", err)
+		os.Exit(1)
+	}
+
+	makers := map[string]core.ConnectionMaker{
+		"https": core.MakeAzureConnection(
+			allowlist,
+			time.Duration(cfg.sasExpiryGrace)*time.Second,
+			cfg.azureBlobEndpoint,
+		),
+	}
+	if cfg.s3 {
+		makers["s3"] = core.MakeS3Connection(
+			allowlist,
+			cfg.s3Region,
+			cfg.s3Endpoint,
+		)
+	}
+	if cfg.gs {
+		makers["gs"] = core.MakeGSConnection(allowlist)
+	}
+
+	makeVdsConnection := core.MakeConnection(makers)
+	if cfg.allowLocal {
+		makeVdsConnection = core.MakeLocalConnection(
+			cfg.localRoot,
+			makeVdsConnection,
+		)
+	}
+
+	resolver, err := setupResolver(cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid vds aliases: %v\n", err)
+		os.Exit(1)
+	}
 
-	storageAccounts := strings.Split(opts.storageAccounts, ",")
+	defaultFillValue, err := parseFillValue(cfg.defaultFillValue)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid default fill value: %v\n", err)
+		os.Exit(1)
+	}
+	defaults, err := core.NewDefaults(cfg.defaultInterpolation, defaultFillValue)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid defaults: %v\n", err)
+		os.Exit(1)
+	}
+
+	enabledEndpoints, err := api.NewEnabledEndpoints(
+		splitList(cfg.enabledEndpoints),
+	)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid enabled endpoints: %v\n", err)
+		os.Exit(1)
+	}
+
+	auditLog, err := setupAudit(cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid audit log: %v\n", err)
+		os.Exit(1)
+	}
+
+	memoryLimit := containerMemoryLimit(cgroupMemoryLimitFiles)
 
 	endpoint := api.Endpoint{
-		MakeVdsConnection: core.MakeAzureConnection(storageAccounts),
-		Cache:             cache.NewCache(opts.cacheSize),
+		MakeVdsConnection: makeVdsConnection,
+		Cache:             cache.NewCache(cfg.cacheSize),
+		Version:           version,
+		/* The limits at startup, which grpc's max message size is fixed to */
+		Limits: limits(cfg),
+		Retry: core.RetryPolicy{
+			Retries: int(cfg.retries),
+			Backoff: time.Duration(cfg.retryBackoff) * time.Millisecond,
+		},
+		Breaker: core.NewCircuitBreaker(
+			int(cfg.circuitThreshold),
+			time.Duration(cfg.circuitCooldown)*time.Second,
+			nil,
+		),
+		Resolver: resolver,
+		FenceBatching: api.FenceBatching{
+			Size:   int(cfg.fenceBatchSize),
+			Always: cfg.fenceBatching,
+		},
+		Defaults:  defaults,
+		Endpoints: enabledEndpoints,
+		Config:    api.NewLiveConfig(limits(cfg)),
+		StaleIfError: api.NewStaleIfError(
+			time.Duration(cfg.staleIfError)*time.Second,
+			nil,
+		),
+		Budget: core.NewMemoryBudget(
+			memoryBudget(memoryLimit, cfg.memoryBudget),
+			nil,
+		),
+		RequestTimeout: time.Duration(cfg.requestTimeout) * time.Second,
+		Workers:        core.NewWorkerPool(int(cfg.attributeWorkers), nil),
+		Audit:          auditLog,
+	}
+	if warmup := splitList(cfg.warmup); len(warmup) > 0 {
+		endpoint.Warmup = api.NewWarmup(
+			warmup,
+			cfg.warmupMetadata,
+			cfg.warmupAsync,
+		)
 	}
 
 	app := gin.New()
-	app.SetTrustedProxies(nil)
+	err = app.SetTrustedProxies(clientip.ParseTrustedProxies(cfg.trustedProxies))
+	if err != nil {
+		panic(err)
+	}
 
 	var metric *metrics.Metrics
-	if opts.metrics {
+	if cfg.metrics {
 		metric = metrics.NewMetrics()
+		endpoint.Retry.Observer = metric
+		endpoint.Breaker.Observer = metric
+		endpoint.Budget.Observer = metric
+		endpoint.Workers.Observer = metric
+		metric.WorkersBusy(0, endpoint.Workers.Workers())
+		endpoint.StaleIfError.Observer = metric
+		endpoint.Observer = metric
 		/*
 		 * Host the /metrics endpoint on a different app instance. This is needed
 		 * in order to serve it on a different port, while also giving some benefits
@@ -207,11 +692,94 @@ func main() {
 		metricsApp.Use(gin.Recovery())
 		metricsApp.GET("metrics", metrics.NewGinHandler(metric))
 
+		metricsServer := &http.Server{
+			Addr:      fmt.Sprintf(":%d", cfg.metricsPort),
+			Handler:   metricsApp,
+			TLSConfig: metricsTLSConfig,
+		}
 		go func() {
-			metricsApp.Run(fmt.Sprintf(":%d", opts.metricsPort))
+			listenAndServe(metricsServer)
 		}()
 	}
 
-	setupApp(app, &endpoint, metric)
-	app.Run(fmt.Sprintf(":%d", opts.port))
+	/*
+	 * The rate limiter is always installed, such that rate limiting can be
+	 * turned on by a reload. A rate of zero lets everything through.
+	 */
+	rate, burst := rateLimit(cfg)
+	rateLimiter := ratelimit.NewLimiter(rate, burst)
+
+	/*
+	 * Avoid storing a nil *Metrics in the interface, which would be
+	 * non-nil from the middleware's point of view
+	 */
+	var observer ratelimit.Observer
+	if metric != nil {
+		observer = metric
+	}
+
+	limiter := ratelimit.NewGinMiddleware(
+		rateLimiter,
+		cfg.rateLimitHeader,
+		observer,
+	)
+
+	validateRateLimiter := ratelimit.NewLimiter(validateRateLimit(cfg))
+	endpoint.ValidateLimiter = ratelimit.NewGinMiddleware(
+		validateRateLimiter,
+		cfg.rateLimitHeader,
+		observer,
+	)
+
+	reloadConfigOnHangup(&configReloader{
+		load: func() (config, error) {
+			return loadConfig(cl, os.Getenv)
+		},
+		startup:         cfg,
+		live:            endpoint.Config,
+		allowlist:       allowlist,
+		budget:          endpoint.Budget,
+		limiter:         rateLimiter,
+		validateLimiter: validateRateLimiter,
+		memoryLimit:     memoryLimit,
+	})
+
+	if cfg.slowRequestThreshold > 0 {
+		app.Use(logging.NewSlowRequestLogger(
+			time.Duration(cfg.slowRequestThreshold)*time.Millisecond,
+			int(cfg.slowRequestLogLimit),
+		))
+	}
+
+	setupApp(app, &endpoint, metric, limiter)
+
+	var grpcServer *grpc.Server
+	var grpcListener net.Listener
+	if cfg.grpcPort > 0 {
+		var err error
+		grpcListener, err = net.Listen("tcp", fmt.Sprintf(":%d", cfg.grpcPort))
+		if err != nil {
+			panic(err)
+		}
+		grpcServer = setupGrpcServer(&endpoint, metric, tlsConfig)
+	}
+
+	server := &http.Server{
+		Addr:      fmt.Sprintf(":%d", cfg.port),
+		Handler:   app,
+		TLSConfig: tlsConfig,
+	}
+	go endpoint.RunWarmup(context.Background())
+
+	serve(
+		server,
+		grpcServer,
+		grpcListener,
+		time.Duration(cfg.shutdownTimeout)*time.Second,
+	)
+
+	/* Requests are drained, write the records that are still queued */
+	if err := endpoint.Audit.Close(); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to close audit log: %v\n", err)
+	}
 }
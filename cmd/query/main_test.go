@@ -2,13 +2,38 @@ package main
 
 import (
 	"bytes"
+	"context"
+	"encoding/binary"
 	"encoding/json"
 	"fmt"
+	"hash/crc32"
+	"io"
+	"math"
+	"mime"
+	"mime/multipart"
+	"net"
 	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/equinor/vds-slice/api"
+	"github.com/equinor/vds-slice/api/vdsslicepb"
+	"github.com/equinor/vds-slice/internal/cache"
+	"github.com/equinor/vds-slice/internal/core"
+	"github.com/equinor/vds-slice/vds/vdstest"
 )
 
 func TestSliceHappyHTTPResponse(t *testing.T) {
@@ -47,53 +72,156 @@ func TestSliceHappyHTTPResponse(t *testing.T) {
 		},
 	}
 
+	for _, backend := range backends {
+		t.Run(backend.name, func(t *testing.T) {
+			for _, testcase := range testcases {
+				w := setupTestWith(t, backend, testcase)
+
+				requireStatus(t, testcase, w)
+				parts := readMultipartData(t, w)
+
+				require.Equalf(t, 2, len(parts),
+					"Wrong number of multipart data parts in case '%s'", testcase.name)
+
+				inlineAxis := testSliceAxis{
+					Annotation: "Inline", Max: 3.0, Min: 1.0, Samples: 2, StepSize: 2, Regular: true, Unit: "unitless", RawUnit: "unitless",
+				}
+				crosslineAxis := testSliceAxis{
+					Annotation: "Crossline", Max: 11.0, Min: 10.0, Samples: 2, StepSize: 1, Regular: true, Unit: "unitless", RawUnit: "unitless",
+				}
+				sampleAxis := testSliceAxis{
+					Annotation: "Sample", Max: 16.0, Min: 4.0, Samples: 4, StepSize: 4, Regular: true, Unit: "ms", RawUnit: "ms",
+				}
+				expectedFormat := "<f4"
+
+				var expectedMetadata *testSliceMetadata
+				switch testcase.slice.Direction {
+				case "i":
+					expectedMetadata = &testSliceMetadata{
+						X:      sampleAxis,
+						Y:      crosslineAxis,
+						Format: expectedFormat}
+				case "crossline":
+					expectedMetadata = &testSliceMetadata{
+						X:      sampleAxis,
+						Y:      inlineAxis,
+						Format: expectedFormat}
+				default:
+					t.Fatalf("Unhandled direction %s in case %s", testcase.slice.Direction, testcase.name)
+				}
+
+				metadata := &testSliceMetadata{}
+				err := json.Unmarshal(parts[0], metadata)
+				require.NoErrorf(t, err, "Failed json metadata extraction in case '%s'", testcase.name)
+				require.EqualValuesf(t, expectedMetadata, metadata,
+					"Metadata not equal in case '%s'", testcase.name)
+
+				expectedDataLength := expectedMetadata.X.Samples *
+					expectedMetadata.Y.Samples * 4 //4 bytes each
+				require.Equalf(t, expectedDataLength, len(parts[1]),
+					"Wrong number of bytes in data reply in case '%s'", testcase.name)
+			}
+		})
+	}
+}
+
+func TestSliceLinenoMode(t *testing.T) {
+	newCase := func(direction, mode string, lineno int) sliceTest {
+		return sliceTest{
+			baseTest{
+				name:           fmt.Sprintf("%s by %s", direction, mode),
+				method:         http.MethodPost,
+				expectedStatus: http.StatusOK,
+			},
+			testSliceRequest{
+				Vds:        well_known,
+				Direction:  direction,
+				Lineno:     lineno,
+				LinenoMode: mode,
+				Sas:        "n/a",
+			},
+		}
+	}
+
+	/* Inline 3 is at index 1 */
+	testcases := []sliceTest{
+		newCase("inline", "annotation", 3),
+		newCase("inline", "index", 1),
+		newCase("i", "index", 1),
+		newCase("i", "annotation", 3),
+	}
+
+	var expected []byte
 	for _, testcase := range testcases {
 		w := setupTest(t, testcase)
-
 		requireStatus(t, testcase, w)
+
 		parts := readMultipartData(t, w)
+		require.Len(t, parts, 2, testcase.name)
+		if expected == nil {
+			expected = parts[1]
+		}
+		require.Equal(t, expected, parts[1], testcase.name)
+	}
+}
 
-		require.Equalf(t, 2, len(parts),
-			"Wrong number of multipart data parts in case '%s'", testcase.name)
-
-		inlineAxis := testSliceAxis{
-			Annotation: "Inline", Max: 3.0, Min: 1.0, Samples: 2, StepSize: 2, Unit: "unitless",
-		}
-		crosslineAxis := testSliceAxis{
-			Annotation: "Crossline", Max: 11.0, Min: 10.0, Samples: 2, StepSize: 1, Unit: "unitless",
-		}
-		sampleAxis := testSliceAxis{
-			Annotation: "Sample", Max: 16.0, Min: 4.0, Samples: 4, StepSize: 4, Unit: "ms",
-		}
-		expectedFormat := "<f4"
-
-		var expectedMetadata *testSliceMetadata
-		switch testcase.slice.Direction {
-		case "i":
-			expectedMetadata = &testSliceMetadata{
-				X:      sampleAxis,
-				Y:      crosslineAxis,
-				Format: expectedFormat}
-		case "crossline":
-			expectedMetadata = &testSliceMetadata{
-				X:      sampleAxis,
-				Y:      inlineAxis,
-				Format: expectedFormat}
-		default:
-			t.Fatalf("Unhandled direction %s in case %s", testcase.slice.Direction, testcase.name)
-		}
-
-		metadata := &testSliceMetadata{}
-		err := json.Unmarshal(parts[0], metadata)
-		require.NoErrorf(t, err, "Failed json metadata extraction in case '%s'", testcase.name)
-		require.EqualValuesf(t, expectedMetadata, metadata,
-			"Metadata not equal in case '%s'", testcase.name)
-
-		expectedDataLength := expectedMetadata.X.Samples *
-			expectedMetadata.Y.Samples * 4 //4 bytes each
-		require.Equalf(t, expectedDataLength, len(parts[1]),
-			"Wrong number of bytes in data reply in case '%s'", testcase.name)
+func TestSliceDirectionOfCube(t *testing.T) {
+	newCase := func(direction string, lineno int) sliceTest {
+		return sliceTest{
+			baseTest{
+				name:           direction,
+				method:         http.MethodPost,
+				expectedStatus: http.StatusOK,
+			},
+			testSliceRequest{
+				Vds:       well_known,
+				Direction: direction,
+				Lineno:    lineno,
+				Sas:       "n/a",
+				Bounds: []testBound{
+					{Direction: "1", Lower: 0, Upper: 0},
+				},
+			},
+		}
+	}
+
+	/* Inline 3 is at index 1, and inline is the first axis of the metadata */
+	testcases := []sliceTest{
+		newCase("i", 1),
+		newCase("0", 1),
+		newCase("Inline", 3),
+		newCase("INLINE", 3),
+	}
+
+	var expected []byte
+	for _, testcase := range testcases {
+		w := setupTest(t, testcase)
+		requireStatus(t, testcase, w)
+
+		parts := readMultipartData(t, w)
+		require.Len(t, parts, 2, testcase.name)
+		if expected == nil {
+			expected = parts[1]
+		}
+		require.Equal(t, expected, parts[1], testcase.name)
 	}
+
+	testErrorHTTPResponse(t, []endpointTest{
+		sliceTest{
+			baseTest{
+				name:           "Axis position outside of the cube",
+				method:         http.MethodPost,
+				expectedStatus: http.StatusBadRequest,
+				expectedError:  "(Inline, Crossline, Sample) or the axis positions 0 to 2",
+			},
+			testSliceRequest{
+				Vds:       well_known,
+				Direction: "3",
+				Lineno:    0,
+				Sas:       "n/a",
+			},
+		},
+	})
 }
 
 func TestSliceErrorHTTPResponse(t *testing.T) {
@@ -135,7 +263,7 @@ func TestSliceErrorHTTPResponse(t *testing.T) {
 				jsonRequest: "{\"vds\":\"" + well_known +
 					"\", \"direction\":\"i\", \"sas\": \"n/a\"}",
 				expectedStatus: http.StatusBadRequest,
-				expectedError:  "Error:Field validation for 'Lineno'",
+				expectedError:  "field 'lineno' is required, got null",
 			},
 			testSliceRequest{},
 		},
@@ -146,7 +274,7 @@ func TestSliceErrorHTTPResponse(t *testing.T) {
 				jsonRequest: "{\"vds\":\"" + well_known +
 					"\", \"lineno\":1, \"sas\": \"n/a\"}",
 				expectedStatus: http.StatusBadRequest,
-				expectedError:  "Error:Field validation for 'Direction'",
+				expectedError:  "field 'direction' is required",
 			},
 			testSliceRequest{},
 		},
@@ -158,7 +286,43 @@ func TestSliceErrorHTTPResponse(t *testing.T) {
 					"\", \"lineno\":1, \"direction\": \"i\", \"sas\": \"n/a\", " +
 					"\"bounds\": [{\"Upper\": 2 }]}",
 				expectedStatus: http.StatusBadRequest,
-				expectedError:  "Error:Field validation for 'Direction'",
+				expectedError:  "field 'bounds[0].direction' is required, got null",
+			},
+			testSliceRequest{},
+		},
+		sliceTest{
+			baseTest{
+				name:   "Request with invalid fill value",
+				method: http.MethodPost,
+				jsonRequest: "{\"vds\":\"" + well_known +
+					"\", \"lineno\":1, \"direction\": \"i\", \"sas\": \"n/a\", " +
+					"\"fillValue\": \"none\"}",
+				expectedStatus: http.StatusBadRequest,
+				expectedError:  "field 'fillValue' must be a number or \"nan\"",
+			},
+			testSliceRequest{},
+		},
+		sliceTest{
+			baseTest{
+				name:   "Request with vertical unit of another quantity",
+				method: http.MethodPost,
+				jsonRequest: "{\"vds\":\"" + well_known +
+					"\", \"lineno\":1, \"direction\": \"i\", \"sas\": \"n/a\", " +
+					"\"verticalUnit\": \"m\"}",
+				expectedStatus: http.StatusBadRequest,
+				expectedError:  "cannot convert vertical axis from 'ms' to 'm'",
+			},
+			testSliceRequest{},
+		},
+		sliceTest{
+			baseTest{
+				name:   "Request with vertical lineno converted out of range",
+				method: http.MethodPost,
+				jsonRequest: "{\"vds\":\"" + well_known +
+					"\", \"lineno\":1, \"direction\": \"sample\", \"sas\": \"n/a\", " +
+					"\"verticalUnit\": \"s\"}",
+				expectedStatus: http.StatusBadRequest,
+				expectedError:  "Invalid lineno: 1000, valid range: [4.00:16.00:4.00]",
 			},
 			testSliceRequest{},
 		},
@@ -192,9 +356,41 @@ func TestSliceErrorHTTPResponse(t *testing.T) {
 		},
 		sliceTest{
 			baseTest{
-				name:           "Datahandle error",
+				name:           "Request with inline lineno by index out of range",
+				method:         http.MethodPost,
+				expectedStatus: http.StatusBadRequest,
+				expectedError: "Invalid lineno: 3, valid range: [0:2:1]. The lineno " +
+					"is interpreted as an index, as an annotation the valid range " +
+					"is [1.00:5.00:2.00]",
+			},
+			testSliceRequest{
+				Vds:        well_known,
+				Direction:  "inline",
+				Lineno:     3,
+				LinenoMode: "index",
+				Sas:        "n/a",
+			},
+		},
+		sliceTest{
+			baseTest{
+				name:           "Request with unknown lineno mode",
+				method:         http.MethodPost,
+				expectedStatus: http.StatusBadRequest,
+				expectedError:  "invalid lineno mode 'cdp', valid options are: index or annotation",
+			},
+			testSliceRequest{
+				Vds:        well_known,
+				Direction:  "i",
+				Lineno:     1,
+				LinenoMode: "cdp",
+				Sas:        "n/a",
+			},
+		},
+		sliceTest{
+			baseTest{
+				name:           "Datahandle not found error",
 				method:         http.MethodPost,
-				expectedStatus: http.StatusInternalServerError,
+				expectedStatus: http.StatusNotFound,
 				expectedError:  "Could not open VDS",
 			},
 			testSliceRequest{
@@ -242,26 +438,68 @@ func TestFenceHappyHTTPResponse(t *testing.T) {
 		},
 	}
 
-	for _, testcase := range testcases {
-		w := setupTest(t, testcase)
+	for _, backend := range backends {
+		t.Run(backend.name, func(t *testing.T) {
+			for _, testcase := range testcases {
+				w := setupTestWith(t, backend, testcase)
 
-		requireStatus(t, testcase, w)
-		parts := readMultipartData(t, w)
-		require.Equalf(t, 2, len(parts),
-			"Wrong number of multipart data parts in case '%s'", testcase.name)
+				requireStatus(t, testcase, w)
+				parts := readMultipartData(t, w)
+				require.Equalf(t, 2, len(parts),
+					"Wrong number of multipart data parts in case '%s'", testcase.name)
 
-		metadata := string(parts[0])
-		coordinatesLength := len(testcase.fence.Coordinates)
-		expectedMetadata := `{
-			"shape": [` + fmt.Sprint(coordinatesLength) + `, 4],
-			"format": "<f4"
-		}`
-		require.JSONEqf(t, expectedMetadata, metadata,
-			"Metadata not equal in case '%s'", testcase.name)
+				metadata := string(parts[0])
+				coordinatesLength := len(testcase.fence.Coordinates)
+				expectedMetadata := `{
+					"shape": [` + fmt.Sprint(coordinatesLength) + `, 4],
+					"format": "<f4",
+					"fillValue": -999.25
+				}`
+				require.JSONEqf(t, expectedMetadata, metadata,
+					"Metadata not equal in case '%s'", testcase.name)
 
-		expectedDataLength := coordinatesLength * 4 * 4 //4 bytes, 4 samples per each requested
-		require.Equalf(t, expectedDataLength, len(parts[1]),
-			"Wrong number of bytes in data reply in case '%s'", testcase.name)
+				expectedDataLength := coordinatesLength * 4 * 4 //4 bytes, 4 samples per each requested
+				require.Equalf(t, expectedDataLength, len(parts[1]),
+					"Wrong number of bytes in data reply in case '%s'", testcase.name)
+			}
+		})
+	}
+}
+
+func TestFenceNearestTraceHTTPResponse(t *testing.T) {
+	testcase := fenceTest{
+		baseTest{
+			name:           "Valid nearest_trace Request",
+			method:         http.MethodPost,
+			expectedStatus: http.StatusOK,
+		},
+		testFenceRequest{
+			Vds:              well_known,
+			CoordinateSystem: "ilxl",
+			Coordinates:      [][]float32{{3.9, 10.4}, {4, 10.5}, {9, 10}},
+			Interpolation:    "nearest_trace",
+			FillValue:        float32(-999.25),
+			Sas:              "n/a",
+		},
+	}
+
+	for _, backend := range backends {
+		t.Run(backend.name, func(t *testing.T) {
+			w := setupTestWith(t, backend, testcase)
+
+			requireStatus(t, testcase, w)
+			parts := readMultipartData(t, w)
+			require.Equal(t, 2, len(parts), "Wrong number of multipart data parts")
+
+			expectedMetadata := `{
+				"shape": [3, 4],
+				"format": "<f4",
+				"fillValue": -999.25,
+				"indices": [[1, 0], [2, 1], null]
+			}`
+			require.JSONEq(t, expectedMetadata, string(parts[0]))
+			require.Equal(t, 3*4*4, len(parts[1]), "Wrong number of bytes in data reply")
+		})
 	}
 }
 
@@ -319,7 +557,7 @@ func TestFenceErrorHTTPResponse(t *testing.T) {
 					"\"fillValue\": -999.25," +
 					"\"sas\": \"n/a\"}",
 				expectedStatus: http.StatusBadRequest,
-				expectedError:  "Error:Field validation for 'Coordinates'",
+				expectedError:  "field 'coordinates' is required, got null",
 			},
 			testFenceRequest{},
 		},
@@ -353,9 +591,9 @@ func TestFenceErrorHTTPResponse(t *testing.T) {
 		},
 		fenceTest{
 			baseTest{
-				name:           "Datahandle error",
+				name:           "Datahandle not found error",
 				method:         http.MethodPost,
-				expectedStatus: http.StatusInternalServerError,
+				expectedStatus: http.StatusNotFound,
 				expectedError:  "Could not open VDS",
 			},
 			testFenceRequest{
@@ -396,43 +634,48 @@ func TestMetadataHappyHTTPResponse(t *testing.T) {
 		},
 	}
 
-	for _, testcase := range testcases {
-		w := setupTest(t, testcase)
+	for _, backend := range backends {
+		t.Run(backend.name, func(t *testing.T) {
+			for _, testcase := range testcases {
+				w := setupTestWith(t, backend, testcase)
 
-		requireStatus(t, testcase, w)
-		metadata := w.Body.String()
-		expectedMetadata := `{
-			"axis": [
-				{"annotation": "Inline", "max": 5.0, "min": 1.0, "samples" : 3, "stepsize":2, "unit": "unitless"},
-				{"annotation": "Crossline", "max": 11.0, "min": 10.0, "samples" : 2, "stepsize":1, "unit": "unitless"},
-				{"annotation": "Sample", "max": 16.0, "min": 4.0, "samples" : 4, "stepsize":4, "unit": "ms"}
-			],
-			"boundingBox": {
-				"cdp": [[2,0],[14,8],[12,11],[0,3]],
-				"ilxl": [[1, 10], [5, 10], [5, 11], [1, 11]],
-				"ij": [[0, 0], [2, 0], [2, 1], [0, 1]]
-			},
-			"crs"            : "utmXX",
-			"inputFileName"  : "well_known.segy",
-			"importTimeStamp": "^\\d{4}-\\d{2}-\\d{2}[A-Z]\\d{2}:\\d{2}:\\d{2}\\.\\d{3}[A-Z]$"
-		}`
+				requireStatus(t, testcase, w)
+				metadata := w.Body.String()
+				expectedMetadata := `{
+					"axis": [
+						{"annotation": "Inline", "max": 5.0, "min": 1.0, "samples" : 3, "stepsize":2, "regular": true, "unit": "unitless", "rawUnit": "unitless"},
+						{"annotation": "Crossline", "max": 11.0, "min": 10.0, "samples" : 2, "stepsize":1, "regular": true, "unit": "unitless", "rawUnit": "unitless"},
+						{"annotation": "Sample", "max": 16.0, "min": 4.0, "samples" : 4, "stepsize":4, "regular": true, "unit": "ms", "rawUnit": "ms"}
+					],
+					"boundingBox": {
+						"cdp": [[2,0],[14,8],[12,11],[0,3]],
+						"ilxl": [[1, 10], [5, 10], [5, 11], [1, 11]],
+						"ij": [[0, 0], [2, 0], [2, 1], [0, 1]]
+					},
+					"crs"            : "utmXX",
+					"inputFileName"  : "well_known.segy",
+					"storedFormat"   : {"format": "R32", "integerScale": 1, "integerOffset": 0},
+					"importTimeStamp": "^\\d{4}-\\d{2}-\\d{2}[A-Z]\\d{2}:\\d{2}:\\d{2}\\.\\d{3}[A-Z]$"
+				}`
 
-		var expectedMap map[string]any
-		var actualMap map[string]any
+				var expectedMap map[string]any
+				var actualMap map[string]any
 
-		json.Unmarshal([]byte(expectedMetadata), &expectedMap)
-		json.Unmarshal([]byte(metadata), &actualMap)
+				json.Unmarshal([]byte(expectedMetadata), &expectedMap)
+				json.Unmarshal([]byte(metadata), &actualMap)
 
-		if _, ok := actualMap["importTimeStamp"]; !ok {
-			t.Errorf("importTimeStampt is not found in case '%s'", testcase.name)
-		}
+				if _, ok := actualMap["importTimeStamp"]; !ok {
+					t.Errorf("importTimeStampt is not found in case '%s'", testcase.name)
+				}
 
-		require.Regexp(t, expectedMap["importTimeStamp"], actualMap["importTimeStamp"])
+				require.Regexp(t, expectedMap["importTimeStamp"], actualMap["importTimeStamp"])
 
-		expectedMap["importTimeStamp"] = "dummy"
-		actualMap["importTimeStamp"] = "dummy"
+				expectedMap["importTimeStamp"] = "dummy"
+				actualMap["importTimeStamp"] = "dummy"
 
-		require.Equal(t, expectedMap, actualMap, "Metadata not equal in case '%s'", testcase.name)
+				require.Equal(t, expectedMap, actualMap, "Metadata not equal in case '%s'", testcase.name)
+			}
+		})
 	}
 }
 
@@ -481,14 +724,14 @@ func TestMetadataErrorHTTPResponse(t *testing.T) {
 				method:         http.MethodPost,
 				jsonRequest:    "{\"sas\":\"somevalidsas\"}",
 				expectedStatus: http.StatusBadRequest,
-				expectedError:  "Error:Field validation for 'Vds'",
+				expectedError:  "field 'vds' is required",
 			}, testMetadataRequest{},
 		},
 		metadataTest{
 			baseTest{
-				name:           "Datahandle error",
+				name:           "Datahandle not found error",
 				method:         http.MethodPost,
-				expectedStatus: http.StatusInternalServerError,
+				expectedStatus: http.StatusNotFound,
 				expectedError:  "Could not open VDS",
 			},
 
@@ -497,6 +740,19 @@ func TestMetadataErrorHTTPResponse(t *testing.T) {
 				Sas: "n/a",
 			},
 		},
+		metadataTest{
+			baseTest{
+				name:           "Path outside of local root",
+				method:         http.MethodPost,
+				expectedStatus: http.StatusBadRequest,
+				expectedError:  "outside of the configured root",
+			},
+
+			testMetadataRequest{
+				Vds: "../../../" + well_known,
+				Sas: "n/a",
+			},
+		},
 	}
 	testErrorHTTPResponse(t, testcases)
 }
@@ -583,9 +839,29 @@ func TestAttributeHappyHTTPResponse(t *testing.T) {
 		metadata := string(parts[0])
 		xLength := testcase.nrows()
 		yLength := testcase.ncols()
+		shape := `[` + fmt.Sprint(xLength) + `,` + fmt.Sprint(yLength) + `]`
+		nodes := fmt.Sprint(xLength * yLength)
+		coverage := `{
+			"total": ` + nodes + `,
+			"valid": ` + nodes + `,
+			"fill": {
+				"missingSurface": 0,
+				"outsideSurvey": 0,
+				"deadTraces": 0,
+				"belowMinValidFraction": 0,
+				"undefined": 0
+			}
+		}`
 		expectedMetadata := `{
-			"shape": [` + fmt.Sprint(xLength) + `,` + fmt.Sprint(yLength) + `],
-			"format": "<f4"
+			"shape": ` + shape + `,
+			"format": "<f4",
+			"parts": [{
+				"name": "samplevalue",
+				"shape": ` + shape + `,
+				"format": "<f4",
+				"coverage": ` + coverage + `
+			}],
+			"fillValue": 666.66
 		}`
 		require.JSONEqf(t, expectedMetadata, metadata,
 			"Metadata not equal in case '%s'", testcase.base().name)
@@ -596,6 +872,420 @@ func TestAttributeHappyHTTPResponse(t *testing.T) {
 	}
 }
 
+/*
+ * The data parts are in the order the attributes are requested, which is
+ * also the order of the parts in the metadata. Every part must be the same
+ * as when its attribute is requested on its own.
+ */
+func TestAttributePartsOrder(t *testing.T) {
+	attributes := []string{"max", "min", "rms"}
+
+	request := func(attributes []string) attributeEndpointTest {
+		return attributeAlongSurfaceTest{
+			baseTest{
+				name:           fmt.Sprintf("Attributes %v", attributes),
+				method:         http.MethodPost,
+				expectedStatus: http.StatusOK,
+			},
+			testAttributeAlongSurfaceRequest{
+				Vds:        samples10,
+				Values:     [][]float32{{20, 20}, {20, 20}, {20, 20}},
+				Sas:        "n/a",
+				Above:      8.0,
+				Below:      8.0,
+				Attributes: attributes,
+			},
+		}
+	}
+
+	testcase := request(attributes)
+	w := setupTest(t, testcase)
+	requireStatus(t, testcase, w)
+
+	parts := readMultipartData(t, w)
+	require.Len(t, parts, len(attributes)+1)
+
+	var metadata core.AttributeMetadata
+	require.NoError(t, json.Unmarshal(parts[0], &metadata))
+
+	names := []string{}
+	for _, part := range metadata.Parts {
+		names = append(names, part.Name)
+	}
+	require.Equal(t, attributes, names)
+
+	data := parts[1:]
+	require.NotEqual(t, data[0], data[1], "Expected max and min to differ")
+	require.NotEqual(t, data[0], data[2], "Expected max and rms to differ")
+	require.NotEqual(t, data[1], data[2], "Expected min and rms to differ")
+
+	for i, attribute := range attributes {
+		single := request([]string{attribute})
+		w := setupTest(t, single)
+		requireStatus(t, single, w)
+
+		expected := readMultipartData(t, w)
+		require.Len(t, expected, 2)
+		require.Equalf(t, expected[1], data[i],
+			"Expected part %d to be %s", i+1, attribute)
+	}
+}
+
+func TestSliceOmitMetadata(t *testing.T) {
+	request := testSliceRequest{
+		Vds:       well_known,
+		Direction: "i",
+		Lineno:    0,
+		Sas:       "n/a",
+	}
+	full := sliceTest{
+		baseTest{name: "With metadata", method: http.MethodPost},
+		request,
+	}
+	request.OmitMetadata = true
+	dataOnly := sliceTest{
+		baseTest{name: "Without metadata", method: http.MethodPost},
+		request,
+	}
+
+	w := setupTest(t, full)
+	require.Equal(t, http.StatusOK, w.Result().StatusCode)
+	etag := w.Result().Header.Get("ETag")
+	parts := readMultipartData(t, w)
+	require.Len(t, parts, 2)
+
+	w = setupTest(t, dataOnly)
+	require.Equal(t, http.StatusOK, w.Result().StatusCode)
+	require.Equal(t,
+		"application/octet-stream",
+		w.Result().Header.Get("Content-Type"),
+	)
+	require.Equal(t, etag, w.Result().Header.Get("ETag"),
+		"Expected the ETag to be the same with and without metadata")
+	require.Equal(t,
+		fmt.Sprintf("crc32c=%08x", crc32.Checksum(parts[1], crc32.MakeTable(crc32.Castagnoli))),
+		w.Result().Header.Get("X-Content-Checksum"),
+	)
+	require.Equal(t, parts[1], w.Body.Bytes())
+}
+
+func TestAttributeOmitMetadata(t *testing.T) {
+	attributes := []string{"max", "min"}
+	request := testAttributeAlongSurfaceRequest{
+		Vds:        samples10,
+		Values:     [][]float32{{20, 20}, {20, 20}, {20, 20}},
+		Sas:        "n/a",
+		Above:      8.0,
+		Below:      8.0,
+		Attributes: attributes,
+	}
+	full := attributeAlongSurfaceTest{
+		baseTest{name: "With metadata", method: http.MethodPost},
+		request,
+	}
+	request.OmitMetadata = true
+	dataOnly := attributeAlongSurfaceTest{
+		baseTest{name: "Without metadata", method: http.MethodPost},
+		request,
+	}
+
+	w := setupTest(t, full)
+	require.Equal(t, http.StatusOK, w.Result().StatusCode)
+	expected := readMultipartData(t, w)
+	require.Len(t, expected, len(attributes)+1)
+
+	w = setupTest(t, dataOnly)
+	require.Equal(t, http.StatusOK, w.Result().StatusCode)
+
+	_, params, err := mime.ParseMediaType(w.Result().Header.Get("Content-Type"))
+	require.NoError(t, err)
+	reader := multipart.NewReader(w.Body, params["boundary"])
+
+	for i, attribute := range attributes {
+		part, err := reader.NextPart()
+		require.NoError(t, err)
+		require.Equal(t, "application/octet-stream", part.Header.Get("Content-Type"))
+		require.Equal(t, attribute, part.FormName(),
+			"Expected part %d to be named by its attribute", i)
+
+		data, err := io.ReadAll(part)
+		require.NoError(t, err)
+		require.Equal(t, expected[i+1], data)
+	}
+	_, err = reader.NextPart()
+	require.Equal(t, io.EOF, err, "Expected no metadata part")
+}
+
+func TestAttributePointSurface(t *testing.T) {
+	reference := attributeAlongSurfaceTest{
+		baseTest{name: "Regular surface", method: http.MethodPost},
+		testAttributeAlongSurfaceRequest{
+			Vds:        samples10,
+			Values:     [][]float32{{16, 20}, {20, 24}, {24, 20}},
+			Sas:        "n/a",
+			Above:      8.0,
+			Below:      8.0,
+			Attributes: []string{"samplevalue", "max", "min"},
+		},
+	}
+	w := setupTest(t, reference)
+	require.Equalf(t, http.StatusOK, w.Result().StatusCode, w.Body.String())
+	expected := readMultipartData(t, w)
+
+	/* The nodes of the regular surface, as scattered points */
+	requestJSON, err := reference.requestAsJSON()
+	require.NoError(t, err)
+	var request map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(requestJSON), &request))
+	surface := request["surface"].(map[string]interface{})
+	delete(request, "surface")
+
+	rotation := surface["rotation"].(float64) * math.Pi / 180
+	xinc := surface["xinc"].(float64)
+	yinc := surface["yinc"].(float64)
+	points := [][]float64{}
+	for row, values := range reference.attribute.Values {
+		for col, value := range values {
+			x := surface["xori"].(float64) +
+				float64(row)*xinc*math.Cos(rotation) -
+				float64(col)*yinc*math.Sin(rotation)
+			y := surface["yori"].(float64) +
+				float64(row)*xinc*math.Sin(rotation) +
+				float64(col)*yinc*math.Cos(rotation)
+			points = append(points, []float64{x, y, float64(value)})
+		}
+	}
+	delete(surface, "values")
+	surface["points"] = points
+	surface["nrows"] = reference.nrows()
+	surface["ncols"] = reference.ncols()
+	surface["searchRadius"] = 1
+	request["pointSurface"] = surface
+
+	pointJSON, err := json.Marshal(request)
+	require.NoError(t, err)
+	gridded := attributeAlongSurfaceTest{
+		baseTest{
+			name:        "Point surface",
+			method:      http.MethodPost,
+			jsonRequest: string(pointJSON),
+		},
+		testAttributeAlongSurfaceRequest{},
+	}
+	w = setupTest(t, gridded)
+	require.Equalf(t, http.StatusOK, w.Result().StatusCode, w.Body.String())
+	require.Equal(t, expected, readMultipartData(t, w),
+		"Expected the gridded surface to give the same attributes as the "+
+			"regular surface it was sampled from")
+}
+
+func TestAttributeAlongSurfaceBands(t *testing.T) {
+	whole := attributeAlongSurfaceTest{
+		baseTest{name: "Whole surface", method: http.MethodPost},
+		testAttributeAlongSurfaceRequest{
+			Vds:        samples10,
+			Values:     [][]float32{{16, 20}, {20, 24}, {24, 20}},
+			Sas:        "n/a",
+			Above:      8.0,
+			Below:      8.0,
+			Attributes: []string{"samplevalue", "max", "min"},
+		},
+	}
+	w := setupTest(t, whole)
+	require.Equalf(t, http.StatusOK, w.Result().StatusCode, w.Body.String())
+	expected := readMultipartData(t, w)
+
+	requestJSON, err := whole.requestAsJSON()
+	require.NoError(t, err)
+	band := func(offset int, count int) attributeAlongSurfaceTest {
+		var request map[string]interface{}
+		require.NoError(t, json.Unmarshal([]byte(requestJSON), &request))
+		request["rowOffset"] = offset
+		request["rowCount"] = count
+		bandJSON, err := json.Marshal(request)
+		require.NoError(t, err)
+		return attributeAlongSurfaceTest{
+			baseTest{
+				name:        fmt.Sprintf("Band from row %d", offset),
+				method:      http.MethodPost,
+				jsonRequest: string(bandJSON),
+			},
+			testAttributeAlongSurfaceRequest{},
+		}
+	}
+
+	/* The last band is cut short at the last row */
+	concatenated := make([][]byte, len(expected)-1)
+	for _, offset := range []int{0, 2} {
+		w := setupTest(t, band(offset, 2))
+		require.Equalf(t, http.StatusOK, w.Result().StatusCode, w.Body.String())
+		parts := readMultipartData(t, w)
+		require.Len(t, parts, len(expected))
+
+		var metadata core.AttributeMetadata
+		require.NoError(t, json.Unmarshal(parts[0], &metadata))
+		rows := 2
+		if offset == 2 {
+			rows = 1
+		}
+		require.Equal(t, []int{rows, 2}, metadata.Shape)
+		require.Equal(t, core.AttributeBand{
+			RowOffset: offset,
+			RowCount:  rows,
+			Nrows:     3,
+		}, *metadata.Band)
+
+		for i, part := range parts[1:] {
+			concatenated[i] = append(concatenated[i], part...)
+		}
+	}
+	require.Equal(t, expected[1:], concatenated,
+		"Expected the bands to make up the attributes of the whole surface")
+
+	w = setupTest(t, band(3, 2))
+	require.Equalf(t, http.StatusBadRequest, w.Result().StatusCode, w.Body.String())
+	require.Contains(t, w.Body.String(), "rowOffset 3 is out of range, "+
+		"the surface has 3 rows")
+}
+
+func TestAttributeGetHTTPResponse(t *testing.T) {
+	along := testAttributeAlongSurfaceRequest{
+		Vds:        samples10,
+		Values:     [][]float32{{20, 20}, {20, 24}, {24, 20}},
+		Sas:        "n/a",
+		Above:      8.0,
+		Below:      4.0,
+		Attributes: []string{"samplevalue", "max"},
+	}
+	between := testAttributeBetweenSurfacesRequest{
+		Vds:             samples10,
+		ValuesPrimary:   [][]float32{{20, 20}, {20, 20}, {20, 20}},
+		ValuesSecondary: [][]float32{{24, 24}, {28, 28}, {24, 24}},
+		Sas:             "n/a",
+		Attributes:      []string{"samplevalue", "min"},
+	}
+
+	testcases := []struct {
+		get  endpointTest
+		post endpointTest
+	}{
+		{
+			get: attributeAlongSurfaceTest{
+				baseTest{name: "Along GET", method: http.MethodGet},
+				along,
+			},
+			post: attributeAlongSurfaceTest{
+				baseTest{name: "Along POST", method: http.MethodPost},
+				along,
+			},
+		},
+		{
+			get: attributeBetweenSurfacesTest{
+				baseTest{name: "Between GET", method: http.MethodGet},
+				between,
+			},
+			post: attributeBetweenSurfacesTest{
+				baseTest{name: "Between POST", method: http.MethodPost},
+				between,
+			},
+		},
+	}
+
+	for _, testcase := range testcases {
+		name := testcase.get.base().name
+
+		w := setupTest(t, testcase.post)
+		require.Equalf(t, http.StatusOK, w.Result().StatusCode,
+			"[%s] %s", name, w.Body.String())
+		expected := readMultipartData(t, w)
+
+		w = setupTest(t, testcase.get)
+		require.Equalf(t, http.StatusOK, w.Result().StatusCode,
+			"[%s] %s", name, w.Body.String())
+		require.Equalf(t, expected, readMultipartData(t, w),
+			"[%s] Expected GET and POST to give the same response", name)
+	}
+}
+
+func TestAttributeGetQuerySize(t *testing.T) {
+	testcase := attributeAlongSurfaceTest{
+		baseTest{name: "Along GET", method: http.MethodGet},
+		testAttributeAlongSurfaceRequest{
+			Vds:        samples10,
+			Values:     [][]float32{{20, 20}, {20, 24}, {24, 20}},
+			Sas:        "n/a",
+			Above:      8.0,
+			Below:      4.0,
+			Attributes: []string{"samplevalue"},
+		},
+	}
+
+	serve := func(limit int64) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		ctx, r := gin.CreateTestContext(w)
+
+		endpoint := api.Endpoint{
+			MakeVdsConnection: MakeFileConnection(),
+			Cache:             cache.NewNoCache(),
+			Limits:            api.Limits{AttributeQuerySize: limit},
+		}
+		setupApp(r, &endpoint, nil, nil)
+
+		prepareRequest(ctx, t, testcase)
+		r.ServeHTTP(w, ctx.Request)
+		return w
+	}
+
+	w := serve(0)
+	require.Equalf(t, http.StatusOK, w.Result().StatusCode, w.Body.String())
+	w = serve(8192)
+	require.Equalf(t, http.StatusOK, w.Result().StatusCode, w.Body.String())
+
+	w = serve(64)
+	require.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+
+	response := api.ErrorResponse{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	require.Contains(t, response.Error,
+		"Query is too large for GET, the limit is 64 bytes")
+	require.Contains(t, response.Error, "Use POST for larger requests")
+	require.Equal(t, "query_too_large", response.Code)
+	require.Equal(t, map[string]string{"limit": "64"}, response.Details)
+}
+
+func TestProgressiveSliceOmitMetadata(t *testing.T) {
+	request := fmt.Sprintf(
+		`{"vds": "%s", "sas": "n/a", "direction": "i", "lineno": 0, `+
+			`"omitMetadata": true}`,
+		well_known,
+	)
+
+	w := httptest.NewRecorder()
+	ctx, r := gin.CreateTestContext(w)
+
+	endpoint := api.Endpoint{
+		MakeVdsConnection: MakeFileConnection(),
+		Cache:             cache.NewNoCache(),
+	}
+	setupApp(r, &endpoint, nil, nil)
+
+	ctx.Request, _ = http.NewRequest(
+		http.MethodPost,
+		"/slice/progressive",
+		bytes.NewBufferString(request),
+	)
+	ctx.Request.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(w, ctx.Request)
+
+	require.Equalf(t, http.StatusBadRequest, w.Result().StatusCode,
+		"Wrong response status. Body: %v", w.Body.String())
+
+	testErrorInfo := &testErrorResponse{}
+	err := json.Unmarshal(w.Body.Bytes(), testErrorInfo)
+	require.NoError(t, err)
+	require.Contains(t, testErrorInfo.Error, "omitMetadata is not supported")
+}
+
 func TestAttributeErrorHTTPResponse(t *testing.T) {
 	testcases := []endpointTest{
 		attributeAlongSurfaceTest{
@@ -625,7 +1315,7 @@ func TestAttributeErrorHTTPResponse(t *testing.T) {
 				jsonRequest: "{\"vds\":\"" + well_known +
 					"\", \"interpolation\":\"cubic\", \"sas\": \"n/a\"}",
 				expectedStatus: http.StatusBadRequest,
-				expectedError:  "Error:Field validation for",
+				expectedError:  "' is required",
 			},
 			testAttributeAlongSurfaceRequest{},
 		},
@@ -636,7 +1326,7 @@ func TestAttributeErrorHTTPResponse(t *testing.T) {
 				jsonRequest: "{\"vds\":\"" + well_known +
 					"\", \"interpolation\":\"cubic\", \"sas\": \"n/a\"}",
 				expectedStatus: http.StatusBadRequest,
-				expectedError:  "Error:Field validation for",
+				expectedError:  "' is required",
 			},
 			testAttributeBetweenSurfacesRequest{},
 		},
@@ -673,9 +1363,25 @@ func TestAttributeErrorHTTPResponse(t *testing.T) {
 		},
 		attributeAlongSurfaceTest{
 			baseTest{
-				name:           "Along: Datahandle error",
+				name:           "Along: Bad Request: horizontal-only vertical interpolation method",
 				method:         http.MethodPost,
-				expectedStatus: http.StatusInternalServerError,
+				expectedStatus: http.StatusBadRequest,
+				expectedError:  "invalid vertical interpolation method 'angular'",
+			},
+			testAttributeAlongSurfaceRequest{
+				Vds:                   well_known,
+				Values:                [][]float32{{4, 4}, {4, 4}, {4, 4}},
+				Sas:                   "n/a",
+				Interpolation:         "angular",
+				VerticalInterpolation: "angular",
+				Attributes:            []string{"samplevalue"},
+			},
+		},
+		attributeAlongSurfaceTest{
+			baseTest{
+				name:           "Along: Datahandle error",
+				method:         http.MethodPost,
+				expectedStatus: http.StatusNotFound,
 				expectedError:  "Could not open VDS",
 			},
 			testAttributeAlongSurfaceRequest{
@@ -689,7 +1395,7 @@ func TestAttributeErrorHTTPResponse(t *testing.T) {
 			baseTest{
 				name:           "Between: Datahandle error",
 				method:         http.MethodPost,
-				expectedStatus: http.StatusInternalServerError,
+				expectedStatus: http.StatusNotFound,
 				expectedError:  "Could not open VDS",
 			},
 			testAttributeBetweenSurfacesRequest{
@@ -700,6 +1406,51 @@ func TestAttributeErrorHTTPResponse(t *testing.T) {
 				Attributes:      []string{"samplevalue"},
 			},
 		},
+		attributeAlongSurfaceTest{
+			baseTest{
+				name:           "Along: Surface outside of vertical bounds",
+				method:         http.MethodPost,
+				expectedStatus: http.StatusBadRequest,
+				expectedError:  "Vertical window is out of vertical bounds",
+			},
+			testAttributeAlongSurfaceRequest{
+				Vds:        well_known,
+				Values:     [][]float32{{100000, 100000}, {100000, 100000}},
+				Sas:        "n/a",
+				Attributes: []string{"samplevalue"},
+			},
+		},
+		attributeAlongSurfaceTest{
+			baseTest{
+				name:           "Along: Surface without rows",
+				method:         http.MethodPost,
+				expectedStatus: http.StatusBadRequest,
+				expectedError:  "Surface has no rows",
+			},
+			testAttributeAlongSurfaceRequest{
+				Vds:        well_known,
+				Values:     [][]float32{},
+				Sas:        "n/a",
+				Attributes: []string{"samplevalue"},
+			},
+		},
+		attributeBetweenSurfacesTest{
+			baseTest{
+				name:           "Between: Inconsistent rows in secondary surface",
+				method:         http.MethodPost,
+				expectedStatus: http.StatusBadRequest,
+				expectedError: "Invalid secondarySurface: " +
+					"Surface rows are not of the same length. " +
+					"Row 0 has 2 elements. Row 1 has 1 elements. Row 2 has 3 elements",
+			},
+			testAttributeBetweenSurfacesRequest{
+				Vds:             well_known,
+				ValuesPrimary:   [][]float32{{4, 4}, {4, 4}, {4, 4}},
+				ValuesSecondary: [][]float32{{4, 4}, {4}, {4, 4, 4}},
+				Sas:             "n/a",
+				Attributes:      []string{"samplevalue"},
+			},
+		},
 	}
 	testErrorHTTPResponse(t, testcases)
 }
@@ -726,7 +1477,7 @@ func TestLogHasNoSas(t *testing.T) {
 			baseTest{
 				name:           fmt.Sprintf("%v Error Request", method),
 				method:         method,
-				expectedStatus: http.StatusInternalServerError,
+				expectedStatus: http.StatusNotFound,
 			},
 			testMetadataRequest{
 				Vds: "unknown",
@@ -764,6 +1515,39 @@ func TestLogHasNoSas(t *testing.T) {
 	}
 }
 
+func TestVersionHTTPResponse(t *testing.T) {
+	w := httptest.NewRecorder()
+	ctx, r := gin.CreateTestContext(w)
+
+	endpoint := api.Endpoint{
+		MakeVdsConnection: MakeFileConnection(),
+		Cache:             cache.NewNoCache(),
+		Version:           "v0.0.1",
+	}
+	setupApp(r, &endpoint, nil, nil)
+
+	ctx.Request, _ = http.NewRequest(http.MethodGet, "/version", nil)
+	r.ServeHTTP(w, ctx.Request)
+
+	require.Equal(t, http.StatusOK, w.Result().StatusCode)
+
+	response := api.VersionResponse{}
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	require.NoError(t, err, "Couldn't unmarshal version response")
+
+	require.Equal(t, "v0.0.1", response.Version)
+	require.NotEmpty(t, response.OpenVDS)
+	require.Contains(t, response.Capabilities.Attributes, "max")
+	require.Contains(t, response.Capabilities.CoordinateSystems, "cdp")
+	require.Contains(t, response.Capabilities.InterpolationMethods, "nearest")
+	require.Contains(t, response.Capabilities.GriddingMethods, "inversedistance")
+	require.Contains(t, response.Capabilities.Directions, "inline")
+	require.Equal(t, []string{"index", "annotation"}, response.Capabilities.LinenoModes)
+	require.Equal(t, "nearest", response.Capabilities.Defaults.Interpolation)
+	require.Nil(t, response.Capabilities.Defaults.FillValue)
+	require.Nil(t, response.Config, "Expected no generation without a live config")
+}
+
 func testErrorHTTPResponse(t *testing.T, testcases []endpointTest) {
 	for _, testcase := range testcases {
 		w := setupTest(t, testcase)
@@ -778,3 +1562,2862 @@ func testErrorHTTPResponse(t *testing.T, testcases []endpointTest) {
 			"Test '%v'. Error string does not contain expected message.", testcase.base().name)
 	}
 }
+
+func TestRequestSizeLimit(t *testing.T) {
+	request := fmt.Sprintf(`{"vds": "%s", "sas": "n/a"}`, well_known)
+	const limit = 1024
+
+	/*
+	 * Pad in front of the json, such that the whole body has to be read in
+	 * order to bind the request.
+	 */
+	padded := func(size int) string {
+		return strings.Repeat(" ", size-len(request)) + request
+	}
+
+	testcases := []struct {
+		name           string
+		body           string
+		expectedStatus int
+	}{
+		{
+			name:           "Body just under the limit",
+			body:           padded(limit),
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Body just over the limit",
+			body:           padded(limit + 1),
+			expectedStatus: http.StatusRequestEntityTooLarge,
+		},
+	}
+
+	for _, testcase := range testcases {
+		w := httptest.NewRecorder()
+		ctx, r := gin.CreateTestContext(w)
+
+		endpoint := api.Endpoint{
+			MakeVdsConnection: MakeFileConnection(),
+			Cache:             cache.NewNoCache(),
+			Limits: api.Limits{
+				RequestSize:          limit,
+				AttributeRequestSize: 2 * limit,
+			},
+		}
+		setupApp(r, &endpoint, nil, nil)
+
+		ctx.Request, _ = http.NewRequest(
+			http.MethodPost,
+			"/metadata",
+			bytes.NewBufferString(testcase.body),
+		)
+		ctx.Request.Header.Set("Content-Type", "application/json")
+		r.ServeHTTP(w, ctx.Request)
+
+		require.Equalf(t, testcase.expectedStatus, w.Result().StatusCode,
+			"[%s] Wrong response status. Body: %v", testcase.name, w.Body.String())
+
+		if testcase.expectedStatus != http.StatusOK {
+			testErrorInfo := &testErrorResponse{}
+			err := json.Unmarshal(w.Body.Bytes(), testErrorInfo)
+			require.NoErrorf(t, err, "[%s] Couldn't unmarshal error", testcase.name)
+			require.Containsf(t, testErrorInfo.Error, "1024 bytes",
+				"[%s] Error should state the limit", testcase.name)
+		}
+	}
+}
+
+func TestUnknownFieldsHTTPResponse(t *testing.T) {
+	misspelled := "{\"vds\":\"" + well_known + "\", \"direction\":\"i\", " +
+		"\"lineno\":1, \"line_no\":1, \"sas\": \"n/a\", \"bonds\": []}"
+	nested := "{\"vds\":\"" + well_known + "\", \"direction\":\"i\", " +
+		"\"lineno\":1, \"sas\": \"n/a\", " +
+		"\"bounds\": [{\"direction\": \"i\", \"lower\": 0, \"uper\": 1}]}"
+
+	testcases := []endpointTest{
+		sliceTest{
+			baseTest{
+				name:           "Unknown fields GET request",
+				method:         http.MethodGet,
+				jsonRequest:    misspelled,
+				expectedStatus: http.StatusBadRequest,
+				expectedError: "Unknown field(s) in request: bonds, line_no. " +
+					"Accepted fields are: vds, sas, s3, direction, lineno, bounds",
+			},
+			testSliceRequest{},
+		},
+		sliceTest{
+			baseTest{
+				name:           "Unknown fields POST request",
+				method:         http.MethodPost,
+				jsonRequest:    misspelled,
+				expectedStatus: http.StatusBadRequest,
+				expectedError:  "Unknown field(s) in request: bonds, line_no.",
+			},
+			testSliceRequest{},
+		},
+		sliceTest{
+			baseTest{
+				name:           "Unknown nested field POST request",
+				method:         http.MethodPost,
+				jsonRequest:    nested,
+				expectedStatus: http.StatusBadRequest,
+				expectedError:  "Unknown field in request: \"uper\"",
+			},
+			testSliceRequest{},
+		},
+	}
+	testErrorHTTPResponse(t, testcases)
+}
+
+func TestUnknownFieldsOptOut(t *testing.T) {
+	request := "{\"vds\":\"" + well_known + "\", \"sas\": \"n/a\", \"unknown\": 1}"
+
+	w := httptest.NewRecorder()
+	ctx, r := gin.CreateTestContext(w)
+
+	endpoint := api.Endpoint{
+		MakeVdsConnection: MakeFileConnection(),
+		Cache:             cache.NewNoCache(),
+	}
+	setupApp(r, &endpoint, nil, nil)
+
+	ctx.Request, _ = http.NewRequest(
+		http.MethodPost,
+		"/metadata",
+		bytes.NewBufferString(request),
+	)
+	ctx.Request.Header.Set("Content-Type", "application/json")
+	ctx.Request.Header.Set("X-Allow-Unknown-Fields", "true")
+	r.ServeHTTP(w, ctx.Request)
+
+	require.Equalf(t, http.StatusOK, w.Result().StatusCode,
+		"Wrong response status. Body: %v", w.Body.String())
+}
+
+func TestTypeMismatchHTTPResponse(t *testing.T) {
+	slice := func(lineno string, bounds string) string {
+		return "{\"vds\":\"" + well_known + "\", \"sas\": \"n/a\", " +
+			"\"direction\":\"i\", \"lineno\":" + lineno + ", \"bounds\": " + bounds + "}"
+	}
+	surface := func(surface string) string {
+		return "{\"vds\":\"" + well_known + "\", \"sas\": \"n/a\", " +
+			"\"surface\": " + surface + ", \"attributes\": [\"min\"]}"
+	}
+
+	testcases := []endpointTest{
+		sliceTest{
+			baseTest{
+				name:           "String instead of integer",
+				method:         http.MethodGet,
+				jsonRequest:    slice("\"100\"", "[]"),
+				expectedStatus: http.StatusBadRequest,
+				expectedError:  "field 'lineno' must be an integer, got string \"100\"",
+			},
+			testSliceRequest{},
+		},
+		sliceTest{
+			baseTest{
+				name:           "Float instead of integer",
+				method:         http.MethodPost,
+				jsonRequest:    slice("1.5", "[]"),
+				expectedStatus: http.StatusBadRequest,
+				expectedError:  "field 'lineno' must be an integer, got number 1.5",
+			},
+			testSliceRequest{},
+		},
+		sliceTest{
+			baseTest{
+				name:           "Boolean instead of integer",
+				method:         http.MethodPost,
+				jsonRequest:    slice("true", "[]"),
+				expectedStatus: http.StatusBadRequest,
+				expectedError:  "field 'lineno' must be an integer, got boolean true",
+			},
+			testSliceRequest{},
+		},
+		sliceTest{
+			baseTest{
+				name:           "Object instead of array",
+				method:         http.MethodPost,
+				jsonRequest:    slice("1", "{}"),
+				expectedStatus: http.StatusBadRequest,
+				expectedError:  "field 'bounds' must be an array, got object",
+			},
+			testSliceRequest{},
+		},
+		sliceTest{
+			baseTest{
+				name:           "Null instead of required integer",
+				method:         http.MethodPost,
+				jsonRequest:    slice("null", "[]"),
+				expectedStatus: http.StatusBadRequest,
+				expectedError:  "field 'lineno' is required, got null",
+			},
+			testSliceRequest{},
+		},
+		sliceTest{
+			baseTest{
+				name:           "Array instead of request object",
+				method:         http.MethodPost,
+				jsonRequest:    "[1]",
+				expectedStatus: http.StatusBadRequest,
+				expectedError:  "request must be an object, got array",
+			},
+			testSliceRequest{},
+		},
+		metadataTest{
+			baseTest{
+				name:           "Number instead of string",
+				method:         http.MethodPost,
+				jsonRequest:    "{\"vds\": 1, \"sas\": \"n/a\"}",
+				expectedStatus: http.StatusBadRequest,
+				expectedError:  "field 'vds' must be a string, got number 1",
+			},
+			testMetadataRequest{},
+		},
+		attributeAlongSurfaceTest{
+			baseTest{
+				name:           "Array instead of object",
+				method:         http.MethodPost,
+				jsonRequest:    surface("[]"),
+				expectedStatus: http.StatusBadRequest,
+				expectedError:  "field 'surface' must be an object, got array",
+			},
+			testAttributeAlongSurfaceRequest{},
+		},
+		attributeAlongSurfaceTest{
+			baseTest{
+				name:           "String in nested object",
+				method:         http.MethodPost,
+				jsonRequest:    surface("{\"rotation\": \"north\"}"),
+				expectedStatus: http.StatusBadRequest,
+				expectedError:  "field 'surface.rotation' must be a number, got string \"north\"",
+			},
+			testAttributeAlongSurfaceRequest{},
+		},
+	}
+	testErrorHTTPResponse(t, testcases)
+}
+
+func TestRequiredFieldHTTPResponse(t *testing.T) {
+	testcases := []struct {
+		name     string
+		request  string
+		expected string
+	}{
+		{
+			name:     "Null",
+			request:  `"direction": "i", "lineno": null`,
+			expected: "field 'lineno' is required, got null",
+		},
+		{
+			name:     "Missing",
+			request:  `"direction": "i"`,
+			expected: "field 'lineno' is required, got null",
+		},
+		{
+			name:     "Empty",
+			request:  `"direction": "", "lineno": 0`,
+			expected: "field 'direction' is required",
+		},
+		{
+			name:     "Nested",
+			request:  `"direction": "i", "lineno": 0, "bounds": [{"lower": 0, "upper": 1}]`,
+			expected: "field 'bounds[0].direction' is required, got null",
+		},
+	}
+
+	for _, testcase := range testcases {
+		w := httptest.NewRecorder()
+		ctx, r := gin.CreateTestContext(w)
+
+		endpoint := api.Endpoint{
+			MakeVdsConnection: MakeFileConnection(),
+			Cache:             cache.NewNoCache(),
+		}
+		setupApp(r, &endpoint, nil, nil)
+
+		request := fmt.Sprintf(
+			`{"vds": "%s", "sas": "n/a", %s}`,
+			well_known,
+			testcase.request,
+		)
+		ctx.Request, _ = http.NewRequest(
+			http.MethodPost,
+			"/slice",
+			bytes.NewBufferString(request),
+		)
+		ctx.Request.Header.Set("Content-Type", "application/json")
+		r.ServeHTTP(w, ctx.Request)
+
+		require.Equalf(t, http.StatusBadRequest, w.Result().StatusCode,
+			"[%s] Wrong response status. Body: %v", testcase.name, w.Body.String())
+
+		testErrorInfo := &testErrorResponse{}
+		err := json.Unmarshal(w.Body.Bytes(), testErrorInfo)
+		require.NoErrorf(t, err, "[%s]", testcase.name)
+		require.Equalf(t, testcase.expected, testErrorInfo.Error, "[%s]", testcase.name)
+	}
+}
+
+func TestFenceCoordinateLimit(t *testing.T) {
+	request := fmt.Sprintf(
+		`{"vds": "%s", "sas": "n/a", "coordinateSystem": "ij", `+
+			`"coordinates": [[0, 0], [1, 1], [2, 2]]}`,
+		well_known,
+	)
+
+	w := httptest.NewRecorder()
+	ctx, r := gin.CreateTestContext(w)
+
+	endpoint := api.Endpoint{
+		MakeVdsConnection: MakeFileConnection(),
+		Cache:             cache.NewNoCache(),
+		Limits:            api.Limits{FenceCoordinates: 2},
+	}
+	setupApp(r, &endpoint, nil, nil)
+
+	ctx.Request, _ = http.NewRequest(
+		http.MethodPost,
+		"/fence",
+		bytes.NewBufferString(request),
+	)
+	ctx.Request.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(w, ctx.Request)
+
+	require.Equalf(t, http.StatusBadRequest, w.Result().StatusCode,
+		"Wrong response status. Body: %v", w.Body.String())
+
+	testErrorInfo := &testErrorResponse{}
+	err := json.Unmarshal(w.Body.Bytes(), testErrorInfo)
+	require.NoError(t, err)
+	require.Equal(t,
+		"Too many coordinates in request: 3. The limit is 2",
+		testErrorInfo.Error,
+	)
+}
+
+func postFence(
+	t *testing.T,
+	endpoint *api.Endpoint,
+	request string,
+) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	ctx, r := gin.CreateTestContext(w)
+	setupApp(r, endpoint, nil, nil)
+
+	ctx.Request, _ = http.NewRequest(
+		http.MethodPost,
+		"/fence",
+		bytes.NewBufferString(request),
+	)
+	ctx.Request.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(w, ctx.Request)
+	return w
+}
+
+func TestFenceBatched(t *testing.T) {
+	fence := func(batched bool) string {
+		return fmt.Sprintf(
+			`{"vds": "%s", "sas": "n/a", "coordinateSystem": "ij", `+
+				`"interpolation": "nearest_trace", "batched": %t, `+
+				`"coordinates": [[0, 0], [1, 1], [2, 1], [0, 1], [2, 0]]}`,
+			well_known,
+			batched,
+		)
+	}
+
+	whole := postFence(t, &api.Endpoint{
+		MakeVdsConnection: MakeFileConnection(),
+		Cache:             cache.NewNoCache(),
+	}, fence(false))
+	require.Equalf(t, http.StatusOK, whole.Result().StatusCode,
+		"Wrong response status. Body: %v", whole.Body.String())
+	expected := readMultipartData(t, whole)
+	require.Len(t, expected, 2)
+
+	testcases := []struct {
+		name     string
+		batching api.FenceBatching
+		batched  bool
+	}{
+		{
+			name:     "Batched by request",
+			batching: api.FenceBatching{Size: 2},
+			batched:  true,
+		},
+		{
+			name:     "Batched by configuration",
+			batching: api.FenceBatching{Size: 2, Always: true},
+			batched:  false,
+		},
+	}
+
+	for _, testcase := range testcases {
+		observer := &recordingObserver{}
+		w := postFence(t, &api.Endpoint{
+			MakeVdsConnection: MakeFileConnection(),
+			Cache:             cache.NewNoCache(),
+			Observer:          observer,
+			FenceBatching:     testcase.batching,
+		}, fence(testcase.batched))
+		require.Equalf(t, http.StatusOK, w.Result().StatusCode,
+			"[%s] Wrong response status. Body: %v", testcase.name, w.Body.String())
+
+		parts := readMultipartData(t, w)
+		require.Lenf(t, parts, 2, "[%s] Wrong number of parts", testcase.name)
+		require.JSONEqf(t, string(expected[0]), string(parts[0]),
+			"[%s] Metadata differs from the whole fence", testcase.name)
+		require.Equalf(t, expected[1], parts[1],
+			"[%s] Data differs from the whole fence", testcase.name)
+		require.Containsf(t, observer.observations, "fence: batches 3",
+			"[%s] Expected the batches to be observed", testcase.name)
+	}
+}
+
+func TestFenceBatchedErrors(t *testing.T) {
+	endpoint := &api.Endpoint{
+		MakeVdsConnection: MakeFileConnection(),
+		Cache:             cache.NewNoCache(),
+		FenceBatching:     api.FenceBatching{Size: 2},
+	}
+
+	request := fmt.Sprintf(
+		`{"vds": "%s", "sas": "n/a", "coordinateSystem": "ij", `+
+			`"batched": true, "omitMetadata": true, `+
+			`"coordinates": [[0, 0], [1, 1], [2, 1]]}`,
+		well_known,
+	)
+	w := postFence(t, endpoint, request)
+	require.Equalf(t, http.StatusBadRequest, w.Result().StatusCode,
+		"Wrong response status. Body: %v", w.Body.String())
+
+	/* The third coordinate fails the second batch, after the first is sent */
+	request = fmt.Sprintf(
+		`{"vds": "%s", "sas": "n/a", "coordinateSystem": "ij", `+
+			`"interpolation": "none", "batched": true, `+
+			`"coordinates": [[0, 0], [1, 1], [1.5, 1]]}`,
+		well_known,
+	)
+	w = postFence(t, endpoint, request)
+	require.Equal(t, http.StatusOK, w.Result().StatusCode)
+
+	parts := readMultipartData(t, w)
+	require.Len(t, parts, 3)
+	require.Equal(t, 2*4*4, len(parts[1]), "Expected the first batch only")
+
+	testErrorInfo := &testErrorResponse{}
+	err := json.Unmarshal(parts[2], testErrorInfo)
+	require.NoError(t, err)
+	require.Contains(t, testErrorInfo.Error, "batch 1, coordinates from position 2")
+	require.Contains(t, testErrorInfo.Error, "is not at a trace")
+}
+
+func TestFenceHeaderFieldsErrors(t *testing.T) {
+	endpoint := &api.Endpoint{
+		MakeVdsConnection: MakeFileConnection(),
+		Cache:             cache.NewNoCache(),
+	}
+
+	testcases := []struct {
+		name     string
+		extra    string
+		expected string
+	}{
+		{
+			name:     "Header field not in the VDS",
+			extra:    "",
+			expected: "Header field 'cdp' is not in the VDS",
+		},
+		{
+			name:     "Batched",
+			extra:    `, "batched": true`,
+			expected: "batched is not supported together with headerFields",
+		},
+	}
+
+	for _, testcase := range testcases {
+		request := fmt.Sprintf(
+			`{"vds": "%s", "sas": "n/a", "coordinateSystem": "ij", `+
+				`"headerFields": ["cdp"], "coordinates": [[0, 0], [1, 1]]%s}`,
+			well_known,
+			testcase.extra,
+		)
+		w := postFence(t, endpoint, request)
+		require.Equalf(t, http.StatusBadRequest, w.Result().StatusCode,
+			"[%s] Wrong response status. Body: %v", testcase.name, w.Body.String())
+
+		testErrorInfo := &testErrorResponse{}
+		err := json.Unmarshal(w.Body.Bytes(), testErrorInfo)
+		require.NoError(t, err)
+		require.Containsf(t, testErrorInfo.Error, testcase.expected, "[%s]", testcase.name)
+	}
+}
+
+func TestDefaultFillValue(t *testing.T) {
+	fence := func(fillValue string) string {
+		return fmt.Sprintf(
+			`{"vds": "%s", "sas": "n/a", "coordinateSystem": "ij", `+
+				`"coordinates": [[0, 0], [5, 0]]%s}`,
+			well_known,
+			fillValue,
+		)
+	}
+	defaultFillValue := float32(-999.25)
+
+	testcases := []struct {
+		name             string
+		request          string
+		defaultFillValue *float32
+		expected         float32
+	}{
+		{
+			name:             "Default of the server",
+			request:          fence(""),
+			defaultFillValue: &defaultFillValue,
+			expected:         -999.25,
+		},
+		{
+			name:             "Fill value of the request",
+			request:          fence(`, "fillValue": 0`),
+			defaultFillValue: &defaultFillValue,
+			expected:         0,
+		},
+	}
+
+	for _, testcase := range testcases {
+		w := postFence(t, &api.Endpoint{
+			MakeVdsConnection: MakeFileConnection(),
+			Cache:             cache.NewNoCache(),
+			Defaults:          core.Defaults{FillValue: testcase.defaultFillValue},
+		}, testcase.request)
+		require.Equalf(t, http.StatusOK, w.Result().StatusCode,
+			"[%s] Wrong response status. Body: %v", testcase.name, w.Body.String())
+
+		parts := readMultipartData(t, w)
+		require.Lenf(t, parts, 2, "[%s]", testcase.name)
+
+		expectedMetadata := fmt.Sprintf(
+			`{"shape": [2, 4], "format": "<f4", "fillValue": %v}`,
+			testcase.expected,
+		)
+		require.JSONEqf(t, expectedMetadata, string(parts[0]), "[%s]", testcase.name)
+
+		outside := parts[1][4*4:]
+		for i := 0; i < len(outside); i += 4 {
+			value := math.Float32frombits(binary.LittleEndian.Uint32(outside[i:]))
+			require.Equalf(t, testcase.expected, value, "[%s]", testcase.name)
+		}
+	}
+
+	w := postFence(t, &api.Endpoint{
+		MakeVdsConnection: MakeFileConnection(),
+		Cache:             cache.NewNoCache(),
+	}, fence(""))
+	require.Equalf(t, http.StatusBadRequest, w.Result().StatusCode,
+		"Fence outside of the cube without fill value. Body: %v", w.Body.String())
+}
+
+/*
+ * The defaults of the deployment apply to requests that leave the options
+ * out, and are given by /version and the echo of the request
+ */
+func TestDeploymentDefaults(t *testing.T) {
+	fillValue := float32(-999.25)
+	nan := float32(math.NaN())
+
+	deployments := []struct {
+		name     string
+		defaults core.Defaults
+		// Interpolation and fill value as given by the json of /version
+		interpolation string
+		fillValue     interface{}
+	}{
+		{"Server defaults", core.Defaults{}, "nearest", nil},
+		{"Cubic and -999.25", core.Defaults{Interpolation: "cubic", FillValue: &fillValue}, "cubic", -999.25},
+		{"Linear and nan", core.Defaults{Interpolation: "linear", FillValue: &nan}, "linear", "nan"},
+	}
+
+	requests := []struct {
+		name    string
+		options string
+		// Whether the request gives both options itself
+		explicit bool
+	}{
+		{"Options left out", "", false},
+		{"Options given", `, "interpolation": "Triangular", "fillValue": 0`, true},
+	}
+
+	for _, deployment := range deployments {
+		endpoint := &api.Endpoint{
+			MakeVdsConnection: MakeFileConnection(),
+			Cache:             cache.NewNoCache(),
+			Defaults:          deployment.defaults,
+		}
+
+		w := httptest.NewRecorder()
+		ctx, r := gin.CreateTestContext(w)
+		setupApp(r, endpoint, nil, nil)
+		ctx.Request, _ = http.NewRequest(http.MethodGet, "/version", nil)
+		r.ServeHTTP(w, ctx.Request)
+		require.Equal(t, http.StatusOK, w.Result().StatusCode)
+
+		var version struct {
+			Capabilities struct {
+				Defaults map[string]interface{} `json:"defaults"`
+			} `json:"capabilities"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &version))
+		require.Equalf(t,
+			map[string]interface{}{
+				"interpolation": deployment.interpolation,
+				"fillValue":     deployment.fillValue,
+			},
+			version.Capabilities.Defaults,
+			"[%s] Wrong defaults in /version", deployment.name,
+		)
+
+		for _, request := range requests {
+			name := deployment.name + ", " + request.name
+			fence := fmt.Sprintf(
+				`{"vds": "%s", "sas": "n/a", "coordinateSystem": "ij", `+
+					`"coordinates": [[0, 0], [1, 1]], "echo": true%s}`,
+				well_known,
+				request.options,
+			)
+			w := postFence(t, endpoint, fence)
+			require.Equalf(t, http.StatusOK, w.Result().StatusCode,
+				"[%s] Wrong response status. Body: %v", name, w.Body.String())
+
+			parts := readMultipartData(t, w)
+			var metadata struct {
+				FillValue interface{}            `json:"fillValue"`
+				Request   map[string]interface{} `json:"request"`
+			}
+			require.NoErrorf(t, json.Unmarshal(parts[0], &metadata), "[%s]", name)
+
+			expectedInterpolation := deployment.interpolation
+			expectedFillValue := deployment.fillValue
+			if request.explicit {
+				expectedInterpolation = "triangular"
+				expectedFillValue = 0.0
+			}
+			require.Equalf(t, expectedInterpolation, metadata.Request["interpolation"],
+				"[%s] Wrong interpolation in echo", name)
+			require.Equalf(t, expectedFillValue, metadata.Request["fillValue"],
+				"[%s] Wrong fill value in echo", name)
+			require.Equalf(t, expectedFillValue, metadata.FillValue,
+				"[%s] Wrong fill value in metadata", name)
+		}
+	}
+}
+
+func TestSampleHTTPResponse(t *testing.T) {
+	request := fmt.Sprintf(
+		`{"vds": "%s", "sas": "n/a", "coordinateSystem": "ilxl", `+
+			`"coordinates": [[3, 10, 8], [7, 10, 8]], "fillValue": -999.25}`,
+		well_known,
+	)
+
+	w := httptest.NewRecorder()
+	ctx, r := gin.CreateTestContext(w)
+
+	endpoint := api.Endpoint{
+		MakeVdsConnection: MakeFileConnection(),
+		Cache:             cache.NewNoCache(),
+	}
+	setupApp(r, &endpoint, nil, nil)
+
+	ctx.Request, _ = http.NewRequest(
+		http.MethodPost,
+		"/sample",
+		bytes.NewBufferString(request),
+	)
+	ctx.Request.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(w, ctx.Request)
+
+	require.Equalf(t, http.StatusOK, w.Result().StatusCode,
+		"Wrong response status. Body: %v", w.Body.String())
+
+	parts := readMultipartData(t, w)
+	require.Equal(t, 3, len(parts), "Wrong number of multipart data parts")
+
+	expectedMetadata := `{
+		"shape": [2],
+		"format": "<f4",
+		"valid": {"shape": [2], "format": "|u1"},
+		"fillValue": -999.25
+	}`
+	require.JSONEq(t, expectedMetadata, string(parts[0]))
+	require.Equal(t, 2*4, len(parts[1]), "Wrong number of bytes in values")
+	require.Equal(t, []byte{1, 0}, parts[2], "Wrong validity flags")
+}
+
+func TestSamplePointLimit(t *testing.T) {
+	request := fmt.Sprintf(
+		`{"vds": "%s", "sas": "n/a", "coordinateSystem": "ij", `+
+			`"coordinates": [[0, 0, 4], [1, 1, 4], [2, 1, 4]]}`,
+		well_known,
+	)
+
+	w := httptest.NewRecorder()
+	ctx, r := gin.CreateTestContext(w)
+
+	endpoint := api.Endpoint{
+		MakeVdsConnection: MakeFileConnection(),
+		Cache:             cache.NewNoCache(),
+		Limits:            api.Limits{SamplePoints: 2},
+	}
+	setupApp(r, &endpoint, nil, nil)
+
+	ctx.Request, _ = http.NewRequest(
+		http.MethodPost,
+		"/sample",
+		bytes.NewBufferString(request),
+	)
+	ctx.Request.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(w, ctx.Request)
+
+	require.Equalf(t, http.StatusBadRequest, w.Result().StatusCode,
+		"Wrong response status. Body: %v", w.Body.String())
+
+	testErrorInfo := &testErrorResponse{}
+	err := json.Unmarshal(w.Body.Bytes(), testErrorInfo)
+	require.NoError(t, err)
+	require.Equal(t,
+		"Too many coordinates in request: 3. The limit is 2",
+		testErrorInfo.Error,
+	)
+}
+
+func postTraverse(
+	t *testing.T,
+	request string,
+	limits api.Limits,
+) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	ctx, r := gin.CreateTestContext(w)
+
+	endpoint := api.Endpoint{
+		MakeVdsConnection: MakeFileConnection(),
+		Cache:             cache.NewNoCache(),
+		Limits:            limits,
+	}
+	setupApp(r, &endpoint, nil, nil)
+
+	ctx.Request, _ = http.NewRequest(
+		http.MethodPost,
+		"/traverse",
+		bytes.NewBufferString(request),
+	)
+	ctx.Request.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(w, ctx.Request)
+	return w
+}
+
+func TestTraverse(t *testing.T) {
+	request := fmt.Sprintf(
+		`{"vds": "%s", "sas": "n/a", "segments": [`+
+			`{"direction": "inline", "lineno": 1, "from": 10, "to": 11}, `+
+			`{"direction": "crossline", "lineno": 11, "from": 1, "to": 5}`+
+			`]}`,
+		well_known,
+	)
+
+	w := postTraverse(t, request, api.Limits{})
+	require.Equalf(t, http.StatusOK, w.Result().StatusCode,
+		"Wrong response status. Body: %v", w.Body.String())
+
+	parts := readMultipartData(t, w)
+	require.Equal(t, 2, len(parts), "Wrong number of multipart data parts")
+
+	var metadata api.TraverseMetadata
+	err := json.Unmarshal(parts[0], &metadata)
+	require.NoError(t, err)
+
+	require.Equal(t, []int{4, 4}, metadata.Shape)
+	require.Equal(t, "<f4", metadata.Format)
+	require.Equal(t, []api.TraverseSegmentMetadata{
+		{Offset: 0, Traces: 2},
+		{Offset: 2, Traces: 2},
+	}, metadata.Segments)
+	require.Equal(t, 4*4*4, len(parts[1]), "Wrong number of bytes in panel")
+}
+
+func TestTraverseErrors(t *testing.T) {
+	testcases := []struct {
+		name     string
+		segments string
+		limits   api.Limits
+		expected string
+	}{
+		{
+			name: "Segments that do not meet",
+			segments: `{"direction": "inline", "lineno": 1, "from": 10, "to": 11}, ` +
+				`{"direction": "crossline", "lineno": 10, "from": 1, "to": 5}`,
+			expected: "segment 1 starts at (inline 1, crossline 10), but " +
+				"segment 0 ends at (inline 1, crossline 11)",
+		},
+		{
+			name: "Different vertical windows",
+			segments: `{"direction": "inline", "lineno": 1, "from": 10, "to": 11, ` +
+				`"bounds": [{"direction": "sample", "lower": 4, "upper": 8}]}, ` +
+				`{"direction": "crossline", "lineno": 11, "from": 1, "to": 5}`,
+			expected: "the vertical window of segment 1",
+		},
+		{
+			name: "Horizontal bound",
+			segments: `{"direction": "inline", "lineno": 1, "from": 10, "to": 11, ` +
+				`"bounds": [{"direction": "crossline", "lower": 10, "upper": 11}]}`,
+			expected: "segment 0: only vertical bounds are allowed",
+		},
+		{
+			name: "Too many segments",
+			segments: `{"direction": "inline", "lineno": 1, "from": 10, "to": 11}, ` +
+				`{"direction": "crossline", "lineno": 11, "from": 1, "to": 5}`,
+			limits:   api.Limits{TraverseSegments: 1},
+			expected: "Too many segments in traverse: 2. The limit is 1",
+		},
+	}
+
+	for _, testcase := range testcases {
+		request := fmt.Sprintf(
+			`{"vds": "%s", "sas": "n/a", "segments": [%s]}`,
+			well_known,
+			testcase.segments,
+		)
+
+		w := postTraverse(t, request, testcase.limits)
+		require.Equalf(t, http.StatusBadRequest, w.Result().StatusCode,
+			"[%s] Wrong response status. Body: %v",
+			testcase.name,
+			w.Body.String(),
+		)
+
+		testErrorInfo := &testErrorResponse{}
+		err := json.Unmarshal(w.Body.Bytes(), testErrorInfo)
+		require.NoError(t, err)
+		require.Containsf(t, testErrorInfo.Error, testcase.expected,
+			"[%s] Wrong error message", testcase.name)
+	}
+}
+
+func TestMemoryBudget(t *testing.T) {
+	request := fmt.Sprintf(
+		`{"vds": "%s", "sas": "n/a", "coordinateSystem": "ij", `+
+			`"coordinates": [[0, 0], [1, 1]]}`,
+		well_known,
+	)
+
+	testcases := []struct {
+		name           string
+		limit          int64
+		expectedStatus int
+	}{
+		{name: "Within budget", limit: 1024, expectedStatus: http.StatusOK},
+		{name: "Beyond budget", limit: 16, expectedStatus: http.StatusServiceUnavailable},
+	}
+
+	for _, testcase := range testcases {
+		w := httptest.NewRecorder()
+		ctx, r := gin.CreateTestContext(w)
+
+		budget := core.NewMemoryBudget(testcase.limit, nil)
+		endpoint := api.Endpoint{
+			MakeVdsConnection: MakeFileConnection(),
+			Cache:             cache.NewNoCache(),
+			Budget:            budget,
+		}
+		setupApp(r, &endpoint, nil, nil)
+
+		ctx.Request, _ = http.NewRequest(
+			http.MethodPost,
+			"/fence",
+			bytes.NewBufferString(request),
+		)
+		ctx.Request.Header.Set("Content-Type", "application/json")
+		r.ServeHTTP(w, ctx.Request)
+
+		require.Equalf(t, testcase.expectedStatus, w.Result().StatusCode,
+			"[%s] Wrong response status. Body: %v", testcase.name, w.Body.String())
+		require.Equalf(t, int64(0), budget.Reserved(),
+			"[%s] Expected the reservation to be released", testcase.name)
+
+		if testcase.expectedStatus != http.StatusOK {
+			testErrorInfo := &testErrorResponse{}
+			err := json.Unmarshal(w.Body.Bytes(), testErrorInfo)
+			require.NoError(t, err)
+			require.Equal(t,
+				"server busy, response of 32 bytes exceeds available budget of 16 bytes",
+				testErrorInfo.Error,
+			)
+			require.NotEmpty(t, w.Header().Get("Retry-After"))
+		}
+	}
+}
+
+func TestAttributeCountLimit(t *testing.T) {
+	surface := `{
+		"values": [[20, 20], [20, 20]],
+		"rotation": 33.69, "xinc": 7.2111, "yinc": 3.6056,
+		"xori": 2, "yori": 0, "fillValue": 666.66
+	}`
+	requests := []struct {
+		path string
+		body string
+	}{
+		{
+			path: "/attributes/surface/along",
+			body: `{"vds": "` + samples10 + `", "sas": "n/a",
+				"surface": ` + surface + `, "above": 8, "below": 4,
+				"attributes": ["min", "max", "rms"]}`,
+		},
+		{
+			path: "/attributes/surface/between",
+			body: `{"vds": "` + samples10 + `", "sas": "n/a",
+				"primarySurface": ` + surface + `,
+				"secondarySurface": ` + surface + `,
+				"attributes": ["min", "max", "rms"]}`,
+		},
+	}
+
+	for _, request := range requests {
+		w := httptest.NewRecorder()
+		ctx, r := gin.CreateTestContext(w)
+
+		endpoint := api.Endpoint{
+			MakeVdsConnection: MakeFileConnection(),
+			Cache:             cache.NewNoCache(),
+			Limits:            api.Limits{Attributes: 2},
+		}
+		setupApp(r, &endpoint, nil, nil)
+
+		ctx.Request, _ = http.NewRequest(
+			http.MethodPost,
+			request.path,
+			bytes.NewBufferString(request.body),
+		)
+		ctx.Request.Header.Set("Content-Type", "application/json")
+		r.ServeHTTP(w, ctx.Request)
+
+		require.Equalf(t, http.StatusBadRequest, w.Result().StatusCode,
+			"[%s] Wrong response status. Body: %v", request.path, w.Body.String())
+
+		testErrorInfo := &testErrorResponse{}
+		err := json.Unmarshal(w.Body.Bytes(), testErrorInfo)
+		require.NoError(t, err)
+		require.Equalf(t,
+			"Too many attributes in request: 3. The limit is 2",
+			testErrorInfo.Error,
+			"[%s]", request.path,
+		)
+	}
+}
+
+func TestGetWithQueryParameters(t *testing.T) {
+	resource := "vds=" + url.QueryEscape(well_known) +
+		"&sas=" + url.QueryEscape("sv=2021-06-08&se=2023-01-01&sig=secret")
+	query := url.QueryEscape(fmt.Sprintf(
+		`{"vds": "%s", "sas": "n/a", "direction": "i", "lineno": 0}`,
+		well_known,
+	))
+
+	testcases := []struct {
+		name           string
+		path           string
+		query          string
+		expectedStatus int
+		expectedError  string
+	}{
+		{
+			name:           "Slice",
+			path:           "/slice",
+			query:          resource + "&direction=i&lineno=0",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name: "Slice with bounds",
+			path: "/slice",
+			query: resource + "&direction=i&lineno=0&bounds=" +
+				url.QueryEscape(`{"direction": "j", "lower": 0, "upper": 1}`),
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Fence with compact coordinates",
+			path:           "/fence",
+			query:          resource + "&coordinateSystem=ij&coordinates=0,0;1,1",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Fence with repeated coordinates",
+			path:           "/fence",
+			query:          resource + "&coordinateSystem=ij&coordinates=0,0&coordinates=1,1",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Metadata",
+			path:           "/metadata",
+			query:          resource + "",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Query parameter takes precedence",
+			path:           "/slice",
+			query:          "query=" + query + "&lineno=abc",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Not a number",
+			path:           "/slice",
+			query:          resource + "&direction=i&lineno=abc",
+			expectedStatus: http.StatusBadRequest,
+			expectedError:  "field 'lineno' must be an integer, got string \"abc\"",
+		},
+		{
+			name:           "Repeated parameter",
+			path:           "/slice",
+			query:          resource + "&direction=i&lineno=0&lineno=1",
+			expectedStatus: http.StatusBadRequest,
+			expectedError:  "Query parameter 'lineno' is given more than once",
+		},
+		{
+			name:           "Unknown parameter",
+			path:           "/metadata",
+			query:          resource + "&direction=i",
+			expectedStatus: http.StatusBadRequest,
+			expectedError:  "Unknown field(s) in request: direction",
+		},
+		{
+			name:           "Missing parameter",
+			path:           "/slice",
+			query:          resource + "&direction=i",
+			expectedStatus: http.StatusBadRequest,
+			expectedError:  "field 'lineno' is required, got null",
+		},
+	}
+
+	d := gin.DefaultWriter
+	defer func() {
+		gin.DefaultWriter = d
+	}()
+
+	for _, testcase := range testcases {
+		buffer := new(bytes.Buffer)
+		gin.DefaultWriter = buffer
+
+		w := httptest.NewRecorder()
+		ctx, r := gin.CreateTestContext(w)
+
+		endpoint := api.Endpoint{
+			MakeVdsConnection: MakeFileConnection(),
+			Cache:             cache.NewNoCache(),
+		}
+		setupApp(r, &endpoint, nil, nil)
+
+		ctx.Request, _ = http.NewRequest(http.MethodGet, testcase.path, nil)
+		ctx.Request.URL.RawQuery = testcase.query
+		r.ServeHTTP(w, ctx.Request)
+
+		require.Equalf(t, testcase.expectedStatus, w.Result().StatusCode,
+			"[%s] Wrong response status. Body: %v", testcase.name, w.Body.String())
+
+		require.NotContainsf(t, buffer.String(), "secret",
+			"[%s] Log should not contain SAS", testcase.name)
+
+		if testcase.expectedStatus == http.StatusOK {
+			continue
+		}
+
+		testErrorInfo := &testErrorResponse{}
+		err := json.Unmarshal(w.Body.Bytes(), testErrorInfo)
+		require.NoErrorf(t, err, "[%s] Couldn't unmarshal error", testcase.name)
+		require.Containsf(t, testErrorInfo.Error, testcase.expectedError,
+			"[%s] Error string does not contain expected message", testcase.name)
+	}
+}
+
+func TestSasInHeader(t *testing.T) {
+	const token = "sv=2021-06-08&se=2023-01-01&sig=secret"
+	withSas := fmt.Sprintf(`{"vds": "%s", "sas": "n/a"}`, well_known)
+	withoutSas := fmt.Sprintf(`{"vds": "%s"}`, well_known)
+
+	testcases := []struct {
+		name           string
+		request        string
+		headers        map[string]string
+		expectedStatus int
+		expectedCode   string
+	}{
+		{
+			name:           "X-SAS-Token header",
+			request:        withoutSas,
+			headers:        map[string]string{"X-SAS-Token": token},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:    "SharedAccessSignature authorization",
+			request: withoutSas,
+			headers: map[string]string{
+				"Authorization": "SharedAccessSignature " + token,
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Sas in both request and header",
+			request:        withSas,
+			headers:        map[string]string{"X-SAS-Token": token},
+			expectedStatus: http.StatusBadRequest,
+			expectedCode:   "conflicting_credentials",
+		},
+		{
+			name:    "Sas in both headers",
+			request: withoutSas,
+			headers: map[string]string{
+				"X-SAS-Token":   token,
+				"Authorization": "SharedAccessSignature " + token,
+			},
+			expectedStatus: http.StatusBadRequest,
+			expectedCode:   "conflicting_credentials",
+		},
+		{
+			name:           "No sas at all",
+			request:        withoutSas,
+			expectedStatus: http.StatusBadRequest,
+			expectedCode:   "missing_credentials",
+		},
+	}
+
+	d := gin.DefaultWriter
+	defer func() {
+		gin.DefaultWriter = d
+	}()
+
+	for _, testcase := range testcases {
+		for _, method := range []string{http.MethodGet, http.MethodPost} {
+			name := fmt.Sprintf("%s (%s)", testcase.name, method)
+
+			buffer := new(bytes.Buffer)
+			gin.DefaultWriter = buffer
+
+			w := httptest.NewRecorder()
+			ctx, r := gin.CreateTestContext(w)
+
+			endpoint := api.Endpoint{
+				MakeVdsConnection: MakeFileConnection(),
+				Cache:             cache.NewNoCache(),
+			}
+			setupApp(r, &endpoint, nil, nil)
+
+			if method == http.MethodGet {
+				ctx.Request, _ = http.NewRequest(method, "/metadata", nil)
+				ctx.Request.URL.RawQuery = "query=" + url.QueryEscape(testcase.request)
+			} else {
+				ctx.Request, _ = http.NewRequest(
+					method,
+					"/metadata",
+					bytes.NewBufferString(testcase.request),
+				)
+				ctx.Request.Header.Set("Content-Type", "application/json")
+			}
+			for key, value := range testcase.headers {
+				ctx.Request.Header.Set(key, value)
+			}
+			r.ServeHTTP(w, ctx.Request)
+
+			require.Equalf(t, testcase.expectedStatus, w.Result().StatusCode,
+				"[%s] Wrong response status. Body: %v", name, w.Body.String())
+			require.NotContainsf(t, buffer.String(), "secret",
+				"[%s] Log should not contain SAS", name)
+			require.NotContainsf(t, w.Body.String(), "secret",
+				"[%s] Response should not contain SAS", name)
+
+			if testcase.expectedStatus == http.StatusOK {
+				continue
+			}
+
+			response := api.ErrorResponse{}
+			err := json.Unmarshal(w.Body.Bytes(), &response)
+			require.NoErrorf(t, err, "[%s] Couldn't unmarshal error", name)
+			require.Equalf(t, testcase.expectedCode, response.Code, "[%s]", name)
+		}
+	}
+}
+
+func TestVersionedRoutes(t *testing.T) {
+	testcases := []endpointTest{
+		metadataTest{
+			baseTest{name: "Metadata", method: http.MethodGet},
+			testMetadataRequest{Vds: well_known, Sas: "n/a"},
+		},
+		metadataTest{
+			baseTest{name: "Metadata error", method: http.MethodPost},
+			testMetadataRequest{Vds: well_known},
+		},
+		sliceTest{
+			baseTest{name: "Slice", method: http.MethodGet},
+			testSliceRequest{
+				Vds:       well_known,
+				Direction: "i",
+				Lineno:    0,
+				Sas:       "n/a",
+			},
+		},
+		sliceTest{
+			baseTest{name: "Slice error", method: http.MethodPost},
+			testSliceRequest{
+				Vds:       well_known,
+				Direction: "i",
+				Lineno:    10,
+				Sas:       "n/a",
+			},
+		},
+		fenceTest{
+			baseTest{name: "Fence", method: http.MethodPost},
+			testFenceRequest{
+				Vds:              well_known,
+				CoordinateSystem: "ij",
+				Coordinates:      [][]float32{{0, 1}, {1, 1}, {1, 0}},
+				Sas:              "n/a",
+			},
+		},
+		attributeAlongSurfaceTest{
+			baseTest{name: "Attributes along surface", method: http.MethodPost},
+			testAttributeAlongSurfaceRequest{
+				Vds:        samples10,
+				Values:     [][]float32{{20, 20}, {20, 20}, {20, 20}},
+				Sas:        "n/a",
+				Above:      8.0,
+				Below:      4.0,
+				Attributes: []string{"samplevalue"},
+			},
+		},
+		attributeBetweenSurfacesTest{
+			baseTest{name: "Attributes between surfaces", method: http.MethodPost},
+			testAttributeBetweenSurfacesRequest{
+				Vds:             samples10,
+				ValuesPrimary:   [][]float32{{20, 20}, {20, 20}, {20, 20}},
+				ValuesSecondary: [][]float32{{20, 20}, {20, 20}, {20, 20}},
+				Sas:             "n/a",
+				Attributes:      []string{"samplevalue"},
+			},
+		},
+	}
+
+	serve := func(testcase endpointTest, prefix string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		ctx, r := gin.CreateTestContext(w)
+
+		endpoint := api.Endpoint{
+			MakeVdsConnection: MakeFileConnection(),
+			Cache:             cache.NewNoCache(),
+		}
+		setupApp(r, &endpoint, nil, nil)
+
+		prepareRequest(ctx, t, testcase)
+		ctx.Request.URL.Path = prefix + ctx.Request.URL.Path
+		r.ServeHTTP(w, ctx.Request)
+		return w
+	}
+
+	for _, testcase := range testcases {
+		name := testcase.base().name
+
+		unversioned := serve(testcase, "")
+		versioned := serve(testcase, "/v1")
+
+		require.NotEqualf(t, http.StatusNotFound, versioned.Result().StatusCode,
+			"[%s] Versioned route is missing. Body: %v", name, versioned.Body.String())
+		require.Equalf(t,
+			unversioned.Result().StatusCode,
+			versioned.Result().StatusCode,
+			"[%s] Status differs between versioned and unversioned route", name,
+		)
+
+		contentType := unversioned.Result().Header.Get("Content-Type")
+		if strings.HasPrefix(contentType, "multipart/") {
+			require.Equalf(t,
+				readMultipartData(t, unversioned),
+				readMultipartData(t, versioned),
+				"[%s] Body differs between versioned and unversioned route", name,
+			)
+			continue
+		}
+
+		require.Equalf(t,
+			unversioned.Body.String(),
+			versioned.Body.String(),
+			"[%s] Body differs between versioned and unversioned route", name,
+		)
+	}
+}
+
+func TestHeadRequests(t *testing.T) {
+	testcases := []endpointTest{
+		metadataTest{
+			baseTest{name: "Metadata", method: http.MethodGet},
+			testMetadataRequest{Vds: well_known, Sas: "n/a"},
+		},
+		sliceTest{
+			baseTest{name: "Slice", method: http.MethodGet},
+			testSliceRequest{
+				Vds:       well_known,
+				Direction: "i",
+				Lineno:    0,
+				Sas:       "n/a",
+			},
+		},
+		fenceTest{
+			baseTest{name: "Fence", method: http.MethodGet},
+			testFenceRequest{
+				Vds:              well_known,
+				CoordinateSystem: "ij",
+				Coordinates:      [][]float32{{0, 1}, {1, 1}, {1, 0}},
+				Sas:              "n/a",
+			},
+		},
+		sliceTest{
+			baseTest{name: "Slice without metadata", method: http.MethodGet},
+			testSliceRequest{
+				Vds:          well_known,
+				Direction:    "i",
+				Lineno:       0,
+				Sas:          "n/a",
+				OmitMetadata: true,
+			},
+		},
+	}
+
+	serve := func(testcase endpointTest, method string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		ctx, r := gin.CreateTestContext(w)
+
+		endpoint := api.Endpoint{
+			MakeVdsConnection: MakeFileConnection(),
+			Cache:             cache.NewNoCache(),
+		}
+		setupApp(r, &endpoint, nil, nil)
+
+		prepareRequest(ctx, t, testcase)
+		ctx.Request.Method = method
+		r.ServeHTTP(w, ctx.Request)
+		return w
+	}
+
+	for _, testcase := range testcases {
+		name := testcase.base().name
+
+		get := serve(testcase, http.MethodGet)
+		head := serve(testcase, http.MethodHead)
+
+		require.Equalf(t, http.StatusOK, head.Result().StatusCode,
+			"[%s] Wrong response status", name)
+		require.Emptyf(t, head.Body.String(), "[%s] Expected no body", name)
+
+		require.Equalf(t,
+			strconv.Itoa(get.Body.Len()),
+			head.Result().Header.Get("Content-Length"),
+			"[%s] Content-Length differs from GET", name,
+		)
+		require.NotEmptyf(t, head.Result().Header.Get("ETag"),
+			"[%s] Expected an ETag", name)
+		require.Equalf(t,
+			get.Result().Header.Get("ETag"),
+			head.Result().Header.Get("ETag"),
+			"[%s] ETag differs from GET", name,
+		)
+
+		getType, _, err := mime.ParseMediaType(get.Result().Header.Get("Content-Type"))
+		require.NoErrorf(t, err, "[%s]", name)
+		headType, _, err := mime.ParseMediaType(head.Result().Header.Get("Content-Type"))
+		require.NoErrorf(t, err, "[%s]", name)
+		require.Equalf(t, getType, headType, "[%s] Content-Type differs from GET", name)
+	}
+
+	invalid := sliceTest{
+		baseTest{name: "Invalid slice", method: http.MethodGet},
+		testSliceRequest{
+			Vds:       well_known,
+			Direction: "i",
+			Lineno:    10,
+			Sas:       "n/a",
+		},
+	}
+	head := serve(invalid, http.MethodHead)
+	require.Equal(t, http.StatusBadRequest, head.Result().StatusCode)
+	require.Empty(t, head.Body.String())
+}
+
+func TestOptionsRequests(t *testing.T) {
+	testcases := []struct {
+		path  string
+		allow string
+	}{
+		{path: "/slice", allow: "GET, HEAD, OPTIONS, POST"},
+		{path: "/v1/fence", allow: "GET, HEAD, OPTIONS, POST"},
+		{path: "/attributes/surface/along", allow: "GET, OPTIONS, POST"},
+		{path: "/version", allow: "GET, OPTIONS"},
+	}
+
+	for _, testcase := range testcases {
+		w := httptest.NewRecorder()
+		ctx, r := gin.CreateTestContext(w)
+
+		endpoint := api.Endpoint{
+			MakeVdsConnection: MakeFileConnection(),
+			Cache:             cache.NewNoCache(),
+		}
+		setupApp(r, &endpoint, nil, nil)
+
+		ctx.Request, _ = http.NewRequest(http.MethodOptions, testcase.path, nil)
+		r.ServeHTTP(w, ctx.Request)
+
+		require.Equalf(t, http.StatusNoContent, w.Result().StatusCode,
+			"[%s] Wrong response status", testcase.path)
+		require.Equalf(t, testcase.allow, w.Result().Header.Get("Allow"),
+			"[%s] Wrong Allow header", testcase.path)
+	}
+}
+
+func TestUnknownRoutes(t *testing.T) {
+	testcases := []struct {
+		method   string
+		path     string
+		status   int
+		expected string
+		allow    string
+	}{
+		{
+			method:   http.MethodGet,
+			path:     "/slicex?sas=secret",
+			status:   http.StatusNotFound,
+			expected: `{"error": "No such route: /slicex", "code": "route_not_found"}`,
+		},
+		{
+			method:   http.MethodPost,
+			path:     "/v1/fence/extra",
+			status:   http.StatusNotFound,
+			expected: `{"error": "No such route: /v1/fence/extra", "code": "route_not_found"}`,
+		},
+		{
+			method: http.MethodDelete,
+			path:   "/slice",
+			status: http.StatusMethodNotAllowed,
+			expected: `{
+				"error": "Method DELETE is not allowed for /slice",
+				"code":  "method_not_allowed"
+			}`,
+			allow: "GET, HEAD, OPTIONS, POST",
+		},
+		{
+			method: http.MethodGet,
+			path:   "/v1/batch",
+			status: http.StatusMethodNotAllowed,
+			expected: `{
+				"error": "Method GET is not allowed for /v1/batch",
+				"code":  "method_not_allowed"
+			}`,
+			allow: "OPTIONS, POST",
+		},
+	}
+
+	for _, testcase := range testcases {
+		w := httptest.NewRecorder()
+		ctx, r := gin.CreateTestContext(w)
+
+		endpoint := api.Endpoint{
+			MakeVdsConnection: MakeFileConnection(),
+			Cache:             cache.NewNoCache(),
+		}
+		setupApp(r, &endpoint, nil, nil)
+
+		ctx.Request, _ = http.NewRequest(testcase.method, testcase.path, nil)
+		r.ServeHTTP(w, ctx.Request)
+
+		require.Equalf(t, testcase.status, w.Result().StatusCode,
+			"[%s %s] Wrong response status", testcase.method, testcase.path)
+		require.JSONEqf(t, testcase.expected, w.Body.String(),
+			"[%s %s] Wrong body", testcase.method, testcase.path)
+		require.Equalf(t, testcase.allow, w.Result().Header.Get("Allow"),
+			"[%s %s] Wrong Allow header", testcase.method, testcase.path)
+	}
+}
+
+func TestEnabledEndpoints(t *testing.T) {
+	enabled, err := api.NewEnabledEndpoints([]string{"metadata", "Slice"})
+	require.NoError(t, err)
+
+	serve := func(method string, path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		ctx, r := gin.CreateTestContext(w)
+
+		endpoint := api.Endpoint{
+			MakeVdsConnection: MakeFileConnection(),
+			Cache:             cache.NewNoCache(),
+			Endpoints:         enabled,
+		}
+		setupApp(r, &endpoint, nil, nil)
+
+		ctx.Request, _ = http.NewRequest(method, path, bytes.NewBufferString("{}"))
+		ctx.Request.Header.Set("Content-Type", "application/json")
+		r.ServeHTTP(w, ctx.Request)
+		return w
+	}
+
+	/* Empty requests are invalid, which is 400 from enabled endpoints */
+	for _, path := range []string{"/metadata", "/slice", "/v1/slice/progressive"} {
+		w := serve(http.MethodPost, path)
+		require.Equalf(t, http.StatusBadRequest, w.Result().StatusCode,
+			"[%s] Expected the endpoint to be enabled", path)
+	}
+
+	disabled := map[string]string{
+		"/fence":                         "fence",
+		"/v1/sample":                     "sample",
+		"/batch":                         "batch",
+		"/attributes/surface/along":      "attributes",
+		"/v1/attributes/surface/between": "attributes",
+	}
+	for path, name := range disabled {
+		w := serve(http.MethodPost, path)
+		require.Equalf(t, http.StatusNotFound, w.Result().StatusCode,
+			"[%s] Expected the endpoint to be disabled", path)
+
+		response := api.ErrorResponse{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		require.Equalf(t,
+			"The "+name+" endpoint is disabled in this deployment",
+			response.Error,
+			"[%s] Wrong error",
+			path,
+		)
+		require.Equal(t, "endpoint_disabled", response.Code)
+	}
+
+	w := serve(http.MethodHead, "/fence")
+	require.Equal(t, http.StatusNotFound, w.Result().StatusCode)
+	require.Empty(t, w.Body.String())
+
+	w = serve(http.MethodGet, "/version")
+	require.Equal(t, http.StatusOK, w.Result().StatusCode)
+	version := api.VersionResponse{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &version))
+	require.Equal(t, []string{"metadata", "slice"}, version.Capabilities.Endpoints)
+
+	client := setupGrpcTestWith(t, api.Endpoint{
+		MakeVdsConnection: MakeFileConnection(),
+		Cache:             cache.NewNoCache(),
+		Endpoints:         enabled,
+	})
+	stream, err := client.Fence(
+		context.Background(),
+		&vdsslicepb.FenceRequest{
+			Resource: &vdsslicepb.RequestedResource{
+				Vds: well_known,
+				Sas: "n/a",
+			},
+		},
+	)
+	require.NoError(t, err)
+	_, _, err = readGrpcStream(stream)
+	require.Equal(t, codes.NotFound, status.Code(err))
+	require.Contains(t, err.Error(), "The fence endpoint is disabled")
+}
+
+func TestAllEndpointsEnabledByDefault(t *testing.T) {
+	w := httptest.NewRecorder()
+	ctx, r := gin.CreateTestContext(w)
+
+	endpoint := api.Endpoint{
+		MakeVdsConnection: MakeFileConnection(),
+		Cache:             cache.NewNoCache(),
+	}
+	setupApp(r, &endpoint, nil, nil)
+
+	ctx.Request, _ = http.NewRequest(http.MethodGet, "/version", nil)
+	r.ServeHTTP(w, ctx.Request)
+
+	version := api.VersionResponse{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &version))
+	require.Equal(t,
+		[]string{
+			"metadata",
+			"slice",
+			"fence",
+			"sample",
+			"traverse",
+			"batch",
+			"compatibility",
+			"attributes",
+		},
+		version.Capabilities.Endpoints,
+	)
+}
+
+func setupGrpcTest(t *testing.T) vdsslicepb.VdsSliceClient {
+	return setupGrpcTestWith(t, api.Endpoint{
+		MakeVdsConnection: MakeFileConnection(),
+		Cache:             cache.NewNoCache(),
+	})
+}
+
+func setupGrpcTestWith(t *testing.T, endpoint api.Endpoint) vdsslicepb.VdsSliceClient {
+	server := setupGrpcServer(&endpoint, nil, nil)
+
+	listener := bufconn.Listen(1024 * 1024)
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.Dial(
+		"bufnet",
+		grpc.WithContextDialer(
+			func(context.Context, string) (net.Conn, error) {
+				return listener.Dial()
+			},
+		),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	return vdsslicepb.NewVdsSliceClient(conn)
+}
+
+/* Read a data stream into its metadata and the reassembled data parts */
+func readGrpcStream(stream grpc.ClientStream) (string, [][]byte, error) {
+	var metadata string
+	parts := [][]byte{}
+	for {
+		var response vdsslicepb.DataResponse
+		err := stream.RecvMsg(&response)
+		if err == io.EOF {
+			return metadata, parts, nil
+		}
+		if err != nil {
+			return "", nil, err
+		}
+
+		switch message := response.Response.(type) {
+		case *vdsslicepb.DataResponse_Metadata:
+			metadata = message.Metadata
+		case *vdsslicepb.DataResponse_Data:
+			part := int(message.Data.Part)
+			for len(parts) <= part {
+				parts = append(parts, []byte{})
+			}
+			parts[part] = append(parts[part], message.Data.Data...)
+		}
+	}
+}
+
+func TestGrpcMetadata(t *testing.T) {
+	client := setupGrpcTest(t)
+
+	response, err := client.Metadata(
+		context.Background(),
+		&vdsslicepb.MetadataRequest{
+			Resource: &vdsslicepb.RequestedResource{
+				Vds: well_known,
+				Sas: "n/a",
+			},
+		},
+	)
+	require.NoError(t, err)
+
+	w := setupTest(t, metadataTest{
+		baseTest{name: "Metadata", method: http.MethodGet},
+		testMetadataRequest{Vds: well_known, Sas: "n/a"},
+	})
+	require.Equal(t, http.StatusOK, w.Result().StatusCode)
+	require.JSONEq(t, w.Body.String(), response.Json)
+}
+
+func TestGrpcSlice(t *testing.T) {
+	client := setupGrpcTest(t)
+
+	lineno := int32(0)
+	stream, err := client.Slice(
+		context.Background(),
+		&vdsslicepb.SliceRequest{
+			Resource: &vdsslicepb.RequestedResource{
+				Vds: well_known,
+				Sas: "n/a",
+			},
+			Direction: "i",
+			Lineno:    &lineno,
+		},
+	)
+	require.NoError(t, err)
+	metadata, parts, err := readGrpcStream(stream)
+	require.NoError(t, err)
+
+	w := setupTest(t, sliceTest{
+		baseTest{name: "Slice", method: http.MethodGet},
+		testSliceRequest{
+			Vds:       well_known,
+			Direction: "i",
+			Lineno:    0,
+			Sas:       "n/a",
+		},
+	})
+	require.Equal(t, http.StatusOK, w.Result().StatusCode)
+	expected := readMultipartData(t, w)
+
+	require.Len(t, expected, 2)
+	require.JSONEq(t, string(expected[0]), metadata)
+	require.Equal(t, expected[1:], parts)
+}
+
+func TestGrpcErrors(t *testing.T) {
+	client := setupGrpcTest(t)
+
+	outOfBounds := int32(10)
+	testcases := []struct {
+		name    string
+		request *vdsslicepb.SliceRequest
+		code    codes.Code
+		message string
+	}{
+		{
+			name: "Out-of-bound lineno",
+			request: &vdsslicepb.SliceRequest{
+				Resource:  &vdsslicepb.RequestedResource{Vds: well_known, Sas: "n/a"},
+				Direction: "i",
+				Lineno:    &outOfBounds,
+			},
+			code:    codes.InvalidArgument,
+			message: "Invalid lineno: 10, valid range: [0:2:1]",
+		},
+		{
+			name: "Missing sas",
+			request: &vdsslicepb.SliceRequest{
+				Resource:  &vdsslicepb.RequestedResource{Vds: well_known},
+				Direction: "i",
+				Lineno:    new(int32),
+			},
+			code:    codes.InvalidArgument,
+			message: "No valid Sas token",
+		},
+		{
+			name: "Invalid direction",
+			request: &vdsslicepb.SliceRequest{
+				Resource:  &vdsslicepb.RequestedResource{Vds: well_known, Sas: "n/a"},
+				Direction: "unknown",
+				Lineno:    new(int32),
+			},
+			code:    codes.InvalidArgument,
+			message: "invalid direction 'unknown'",
+		},
+		{
+			name: "Missing vds",
+			request: &vdsslicepb.SliceRequest{
+				Resource:  &vdsslicepb.RequestedResource{Vds: "notfound", Sas: "n/a"},
+				Direction: "i",
+				Lineno:    new(int32),
+			},
+			code:    codes.NotFound,
+			message: "Could not open VDS",
+		},
+	}
+
+	for _, testcase := range testcases {
+		stream, err := client.Slice(context.Background(), testcase.request)
+		require.NoErrorf(t, err, "[%s] Unable to open stream", testcase.name)
+		_, _, err = readGrpcStream(stream)
+
+		actual := status.Convert(err)
+		require.Equalf(t, testcase.code, actual.Code(),
+			"[%s] Wrong status code, message: %s", testcase.name, actual.Message())
+		require.Containsf(t, actual.Message(), testcase.message,
+			"[%s] Wrong error message", testcase.name)
+	}
+}
+
+/* Assemble the passes of a progressive slice response into the full slice */
+func assembleProgressiveSlice(
+	t *testing.T,
+	w *httptest.ResponseRecorder,
+) (metadata []byte, data []byte) {
+	_, params, err := mime.ParseMediaType(w.Result().Header.Get("Content-Type"))
+	require.NoErrorf(t, err, "Cannot parse Content Type")
+	mr := multipart.NewReader(w.Body, params["boundary"])
+
+	part, err := mr.NextPart()
+	require.NoErrorf(t, err, "Couldn't read metadata part")
+	metadata, err = io.ReadAll(part)
+	require.NoErrorf(t, err, "Couldn't read metadata part")
+
+	var array struct {
+		Shape []int `json:"shape"`
+	}
+	err = json.Unmarshal(metadata, &array)
+	require.NoErrorf(t, err, "Couldn't parse metadata")
+	rows, columns := array.Shape[0], array.Shape[1]
+	const itemsize = 4
+
+	data = make([]byte, rows*columns*itemsize)
+	previous := 0
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			require.Equalf(t, 1, previous, "Last pass must have stride 1")
+			return metadata, data
+		}
+		require.NoErrorf(t, err, "Couldn't process part")
+		pass, err := io.ReadAll(part)
+		require.NoErrorf(t, err, "Couldn't read part")
+
+		stride, err := strconv.Atoi(part.Header.Get("Stride"))
+		require.NoErrorf(t, err, "Part has no valid Stride header")
+
+		for row := 0; row < rows; row += stride {
+			for column := 0; column < columns; column += stride {
+				if previous > 0 && row%previous == 0 && column%previous == 0 {
+					continue
+				}
+				require.NotEmptyf(t, pass, "Pass with stride %d is too short", stride)
+				offset := (row*columns + column) * itemsize
+				copy(data[offset:offset+itemsize], pass[:itemsize])
+				pass = pass[itemsize:]
+			}
+		}
+		require.Emptyf(t, pass, "Pass with stride %d is too long", stride)
+		previous = stride
+	}
+}
+
+func TestProgressiveSlice(t *testing.T) {
+	testcases := []endpointTest{
+		sliceTest{
+			baseTest{name: "Inline", method: http.MethodGet},
+			testSliceRequest{Vds: well_known, Direction: "i", Lineno: 0, Sas: "n/a"},
+		},
+		sliceTest{
+			baseTest{name: "Crossline", method: http.MethodPost},
+			testSliceRequest{Vds: well_known, Direction: "j", Lineno: 1, Sas: "n/a"},
+		},
+		sliceTest{
+			baseTest{name: "Time", method: http.MethodGet},
+			testSliceRequest{Vds: samples10, Direction: "k", Lineno: 5, Sas: "n/a"},
+		},
+	}
+
+	serve := func(testcase endpointTest, suffix string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		ctx, r := gin.CreateTestContext(w)
+
+		endpoint := api.Endpoint{
+			MakeVdsConnection: MakeFileConnection(),
+			Cache:             cache.NewNoCache(),
+		}
+		setupApp(r, &endpoint, nil, nil)
+
+		prepareRequest(ctx, t, testcase)
+		ctx.Request.URL.Path = ctx.Request.URL.Path + suffix
+		if suffix != "" {
+			ctx.Request.Header.Set("Accept-Encoding", "gzip")
+		}
+		r.ServeHTTP(w, ctx.Request)
+		return w
+	}
+
+	for _, testcase := range testcases {
+		name := testcase.base().name
+
+		regular := serve(testcase, "")
+		progressive := serve(testcase, "/progressive")
+
+		require.Equalf(t, http.StatusOK, regular.Result().StatusCode,
+			"[%s] Wrong response status. Body: %v", name, regular.Body.String())
+		require.Equalf(t, http.StatusOK, progressive.Result().StatusCode,
+			"[%s] Wrong response status. Body: %v", name, progressive.Body.String())
+		require.Emptyf(t, progressive.Result().Header.Get("Content-Encoding"),
+			"[%s] Progressive response must not be compressed", name)
+		require.Equalf(t,
+			strconv.Itoa(progressive.Body.Len()),
+			progressive.Result().Header.Get("X-Expected-Content-Length"),
+			"[%s] Wrong expected length", name,
+		)
+
+		expected := readMultipartData(t, regular)
+		metadata, data := assembleProgressiveSlice(t, progressive)
+
+		require.Equalf(t, expected[0], metadata, "[%s] Wrong metadata", name)
+		require.Equalf(t, expected[1], data, "[%s] Wrong data", name)
+	}
+
+	errorcase := sliceTest{
+		baseTest{name: "Error", method: http.MethodGet},
+		testSliceRequest{Vds: well_known, Direction: "i", Lineno: 10, Sas: "n/a"},
+	}
+	w := serve(errorcase, "/progressive")
+	require.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+	require.Contains(t, w.Body.String(), "Invalid lineno: 10")
+}
+
+/** A handle that keeps track of the rows of slices read through it */
+type rowRecordingHandle struct {
+	core.DSHandle
+	reads *[][2]int
+}
+
+func (h rowRecordingHandle) WithWorkerPool(pool *core.WorkerPool) core.DSHandle {
+	h.DSHandle = h.DSHandle.WithWorkerPool(pool)
+	return h
+}
+
+func (h rowRecordingHandle) GetSliceRows(
+	lineno int,
+	direction int,
+	linenoSystem int,
+	bounds []core.Bound,
+	fillValue *float32,
+	first int,
+	stride int,
+) ([]byte, error) {
+	*h.reads = append(*h.reads, [2]int{first, stride})
+	return h.DSHandle.GetSliceRows(
+		lineno,
+		direction,
+		linenoSystem,
+		bounds,
+		fillValue,
+		first,
+		stride,
+	)
+}
+
+/* A recorder that keeps the rows read so far every time it is flushed */
+type flushRecorder struct {
+	*httptest.ResponseRecorder
+	reads   *[][2]int
+	flushes [][][2]int
+}
+
+func (r *flushRecorder) Flush() {
+	r.flushes = append(r.flushes, append([][2]int{}, *r.reads...))
+	r.ResponseRecorder.Flush()
+}
+
+/*
+ * The coarse pass is sent once its rows are read, before the rows of the
+ * finer passes are
+ */
+func TestProgressiveSliceReadsPassByPass(t *testing.T) {
+	reads := [][2]int{}
+	storage := vdstest.Storage{well_known: vdstest.WellKnown()}
+	endpoint := api.Endpoint{
+		MakeVdsConnection: storage.MakeConnection(),
+		OpenHandle: func(conn core.Connection) (core.DSHandle, error) {
+			handle, err := storage.Open(conn)
+			if err != nil {
+				return nil, err
+			}
+			return rowRecordingHandle{DSHandle: handle, reads: &reads}, nil
+		},
+		Cache: cache.NewNoCache(),
+	}
+
+	w := &flushRecorder{ResponseRecorder: httptest.NewRecorder(), reads: &reads}
+	ctx, r := gin.CreateTestContext(w)
+	setupApp(r, &endpoint, nil, nil)
+
+	testcase := sliceTest{
+		baseTest{name: "Inline", method: http.MethodGet},
+		testSliceRequest{Vds: well_known, Direction: "i", Lineno: 0, Sas: "n/a"},
+	}
+	prepareRequest(ctx, t, testcase)
+	ctx.Request.URL.Path = ctx.Request.URL.Path + "/progressive"
+	r.ServeHTTP(w, ctx.Request)
+	require.Equalf(t, http.StatusOK, w.Result().StatusCode,
+		"Wrong response status. Body: %v", w.Body.String())
+
+	/* The metadata part is flushed first, then the coarse pass */
+	require.GreaterOrEqual(t, len(w.flushes), 2)
+	require.Equal(t, [][2]int{{0, 8}}, w.flushes[1],
+		"Coarse pass was sent after reading more than its rows")
+	/* The slice has two rows, so the passes between read nothing */
+	require.Equal(t, [][2]int{{0, 8}, {1, 2}}, reads)
+
+	regular := setupTestWith(t, backends[1], testcase)
+	expected := readMultipartData(t, regular)
+	metadata, data := assembleProgressiveSlice(t, w.ResponseRecorder)
+	require.Equal(t, expected[0], metadata, "Wrong metadata")
+	require.Equal(t, expected[1], data, "Wrong data")
+}
+
+func serveBatch(t *testing.T, limits api.Limits, request string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	ctx, r := gin.CreateTestContext(w)
+
+	endpoint := api.Endpoint{
+		MakeVdsConnection: MakeFileConnection(),
+		Cache:             cache.NewNoCache(),
+		Limits:            limits,
+	}
+	setupApp(r, &endpoint, nil, nil)
+
+	ctx.Request, _ = http.NewRequest(
+		http.MethodPost,
+		"/batch",
+		bytes.NewBufferString(request),
+	)
+	ctx.Request.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(w, ctx.Request)
+	return w
+}
+
+type batchPart struct {
+	request string
+	data    []byte
+}
+
+func readBatchResponse(
+	t *testing.T,
+	w *httptest.ResponseRecorder,
+) (api.BatchMetadata, []batchPart) {
+	_, params, err := mime.ParseMediaType(w.Result().Header.Get("Content-Type"))
+	require.NoErrorf(t, err, "Cannot parse Content Type")
+	mr := multipart.NewReader(w.Body, params["boundary"])
+
+	part, err := mr.NextPart()
+	require.NoErrorf(t, err, "Couldn't read batch metadata part")
+	var metadata api.BatchMetadata
+	err = json.NewDecoder(part).Decode(&metadata)
+	require.NoErrorf(t, err, "Couldn't parse batch metadata")
+
+	parts := []batchPart{}
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			return metadata, parts
+		}
+		require.NoErrorf(t, err, "Couldn't process part")
+		data, err := io.ReadAll(part)
+		require.NoErrorf(t, err, "Couldn't read part")
+		parts = append(parts, batchPart{
+			request: part.Header.Get("Request"),
+			data:    data,
+		})
+	}
+}
+
+func TestBatch(t *testing.T) {
+	request := fmt.Sprintf(`{
+		"vds": "%s",
+		"sas": "n/a",
+		"requests": [
+			{"type": "metadata"},
+			{"type": "slice", "name": "inline", "parameters": {
+				"direction": "i", "lineno": 1
+			}},
+			{"type": "slice", "name": "out-of-bounds", "parameters": {
+				"direction": "i", "lineno": 10
+			}},
+			{"type": "surface", "name": "unknown-type"},
+			{"type": "fence", "name": "fence", "parameters": {
+				"coordinateSystem": "ij",
+				"coordinates": [[0, 1], [1, 1], [1, 0]]
+			}}
+		]
+	}`, well_known)
+
+	w := serveBatch(t, api.Limits{}, request)
+	require.Equalf(t, http.StatusOK, w.Result().StatusCode,
+		"Wrong response status. Body: %v", w.Body.String())
+	metadata, parts := readBatchResponse(t, w)
+
+	statuses := map[string]int{}
+	codes := map[string]string{}
+	for _, status := range metadata.Requests {
+		statuses[status.Name] = status.Status
+		if status.Error != nil {
+			codes[status.Name] = status.Error.Code
+		}
+	}
+	require.Equal(t, map[string]int{
+		"0":             http.StatusOK,
+		"inline":        http.StatusOK,
+		"out-of-bounds": http.StatusBadRequest,
+		"unknown-type":  http.StatusBadRequest,
+		"fence":         http.StatusOK,
+	}, statuses)
+	require.Equal(t, map[string]string{
+		"out-of-bounds": "lineno_out_of_range",
+		"unknown-type":  "invalid_request_type",
+	}, codes)
+
+	standalone := []endpointTest{
+		metadataTest{
+			baseTest{name: "Metadata", method: http.MethodPost},
+			testMetadataRequest{Vds: well_known, Sas: "n/a"},
+		},
+		sliceTest{
+			baseTest{name: "Slice", method: http.MethodPost},
+			testSliceRequest{Vds: well_known, Direction: "i", Lineno: 1, Sas: "n/a"},
+		},
+		fenceTest{
+			baseTest{name: "Fence", method: http.MethodPost},
+			testFenceRequest{
+				Vds:              well_known,
+				CoordinateSystem: "ij",
+				Coordinates:      [][]float32{{0, 1}, {1, 1}, {1, 0}},
+				Sas:              "n/a",
+			},
+		},
+	}
+
+	expected := []batchPart{}
+	metadataResponse := setupTest(t, standalone[0])
+	expected = append(expected, batchPart{"0", metadataResponse.Body.Bytes()})
+	for i, name := range []string{"inline", "fence"} {
+		for _, data := range readMultipartData(t, setupTest(t, standalone[i+1])) {
+			expected = append(expected, batchPart{name, data})
+		}
+	}
+	require.Equal(t, expected, parts)
+}
+
+func TestBatchErrors(t *testing.T) {
+	slice := `{"type": "slice", "parameters": {"direction": "i", "lineno": 1}}`
+
+	testcases := []struct {
+		name          string
+		limits        api.Limits
+		request       string
+		expectedError string
+	}{
+		{
+			name:   "Too many requests",
+			limits: api.Limits{BatchRequests: 2},
+			request: fmt.Sprintf(
+				`{"vds": "%s", "sas": "n/a", "requests": [%s, %s, %s]}`,
+				well_known, slice, slice, slice,
+			),
+			expectedError: "Too many requests in batch: 3. The limit is 2",
+		},
+		{
+			name: "Duplicate names",
+			request: fmt.Sprintf(
+				`{"vds": "%s", "sas": "n/a", "requests": [%s, %s]}`,
+				well_known,
+				`{"type": "metadata", "name": "a"}`,
+				`{"type": "metadata", "name": "a"}`,
+			),
+			expectedError: "Request name 'a' is given more than once in batch",
+		},
+		{
+			name:          "No requests",
+			request:       fmt.Sprintf(`{"vds": "%s", "sas": "n/a", "requests": []}`, well_known),
+			expectedError: "Error:Field validation for 'Requests' failed on the 'min' tag",
+		},
+		{
+			name:          "Missing sas",
+			request:       fmt.Sprintf(`{"vds": "%s", "requests": [%s]}`, well_known, slice),
+			expectedError: "No valid Sas token is found",
+		},
+	}
+
+	for _, testcase := range testcases {
+		w := serveBatch(t, testcase.limits, testcase.request)
+		require.Equalf(t, http.StatusBadRequest, w.Result().StatusCode,
+			"[%s] Wrong response status. Body: %v", testcase.name, w.Body.String())
+
+		var response api.ErrorResponse
+		err := json.Unmarshal(w.Body.Bytes(), &response)
+		require.NoErrorf(t, err, "[%s] Couldn't parse error response", testcase.name)
+		require.Containsf(t, response.Error, testcase.expectedError, "[%s]", testcase.name)
+	}
+
+	/* Errors in sub-requests leave the other requests unaffected */
+	request := fmt.Sprintf(`{"vds": "%s", "sas": "n/a", "requests": [%s, %s]}`,
+		well_known,
+		`{"type": "slice", "parameters": {"vds": "other", "direction": "i", "lineno": 1}}`,
+		slice,
+	)
+	w := serveBatch(t, api.Limits{}, request)
+	require.Equalf(t, http.StatusOK, w.Result().StatusCode,
+		"Wrong response status. Body: %v", w.Body.String())
+	metadata, parts := readBatchResponse(t, w)
+	require.Len(t, metadata.Requests, 2)
+	require.Equal(t, "resource_in_batch_request", metadata.Requests[0].Error.Code)
+	require.Nil(t, metadata.Requests[1].Error)
+	require.Len(t, parts, 2)
+}
+
+/* Sub-requests to disabled endpoints fail, and are not run by the batch */
+func TestBatchDisabledSubRequests(t *testing.T) {
+	enabled, err := api.NewEnabledEndpoints([]string{"batch", "slice"})
+	require.NoError(t, err)
+
+	w := httptest.NewRecorder()
+	ctx, r := gin.CreateTestContext(w)
+	endpoint := api.Endpoint{
+		MakeVdsConnection: MakeFileConnection(),
+		Cache:             cache.NewNoCache(),
+		Endpoints:         enabled,
+	}
+	setupApp(r, &endpoint, nil, nil)
+
+	request := fmt.Sprintf(`{
+		"vds": "%s",
+		"sas": "n/a",
+		"requests": [
+			{"type": "slice", "name": "slice", "parameters": {
+				"direction": "i", "lineno": 1
+			}},
+			{"type": "fence", "name": "fence", "parameters": {
+				"coordinateSystem": "ij",
+				"coordinates": [[0, 1], [1, 1]]
+			}}
+		]
+	}`, well_known)
+	ctx.Request, _ = http.NewRequest(
+		http.MethodPost,
+		"/batch",
+		bytes.NewBufferString(request),
+	)
+	ctx.Request.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(w, ctx.Request)
+
+	require.Equalf(t, http.StatusOK, w.Result().StatusCode,
+		"Wrong response status. Body: %v", w.Body.String())
+	metadata, parts := readBatchResponse(t, w)
+	require.Len(t, metadata.Requests, 2)
+
+	require.Nil(t, metadata.Requests[0].Error)
+	fence := metadata.Requests[1]
+	require.Equal(t, "fence", fence.Name)
+	require.Equal(t, http.StatusNotFound, fence.Status)
+	require.Equal(t, "endpoint_disabled", fence.Error.Code)
+	require.Equal(t,
+		"The fence endpoint is disabled in this deployment",
+		fence.Error.Error,
+	)
+
+	for _, part := range parts {
+		require.NotEqual(t, "fence", part.request,
+			"Expected no data from the disabled fence")
+	}
+}
+
+/* Cache that records what is stored in it */
+type recordingCache struct {
+	mu      sync.Mutex
+	entries map[string]cache.CacheEntry
+}
+
+func (c *recordingCache) Get(key string) (cache.CacheEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[key]
+	return entry, ok
+}
+
+func (c *recordingCache) Set(key string, entry cache.CacheEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[key] = entry
+}
+
+func serveMetadataList(
+	t *testing.T,
+	endpoint *api.Endpoint,
+	request string,
+) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	ctx, r := gin.CreateTestContext(w)
+	setupApp(r, endpoint, nil, nil)
+
+	ctx.Request, _ = http.NewRequest(
+		http.MethodPost,
+		"/metadata",
+		bytes.NewBufferString(request),
+	)
+	ctx.Request.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(w, ctx.Request)
+	return w
+}
+
+func TestMetadataList(t *testing.T) {
+	request := fmt.Sprintf(`{
+		"sas": "n/a",
+		"vdsList": [
+			{"vds": "%s"},
+			{"vds": "%s", "sas": "other"},
+			{"vds": "%s"}
+		]
+	}`, well_known, "../../testdata/well_known/missing.vds", samples10)
+
+	records := &recordingCache{entries: map[string]cache.CacheEntry{}}
+	endpoint := &api.Endpoint{
+		MakeVdsConnection: MakeFileConnection(),
+		Cache:             records,
+	}
+
+	w := serveMetadataList(t, endpoint, request)
+	require.Equalf(t, http.StatusOK, w.Result().StatusCode,
+		"Wrong response status. Body: %v", w.Body.String())
+
+	var entries []map[string]interface{}
+	err := json.Unmarshal(w.Body.Bytes(), &entries)
+	require.NoError(t, err)
+	require.Len(t, entries, 3)
+
+	require.Equal(t, "well_known.segy", entries[0]["inputFileName"])
+	require.Equal(t, "vds_not_found", entries[1]["code"])
+	require.Contains(t, entries[1], "error")
+	require.Contains(t, entries[2], "axis")
+
+	require.Len(t, records.entries, 2, "Metadata of every cube is cached on its own")
+
+	single := fmt.Sprintf(`{"vds": "%s", "sas": "n/a"}`, well_known)
+	w = serveMetadataList(t, endpoint, single)
+	require.Equal(t, http.StatusOK, w.Result().StatusCode)
+	require.Len(t, records.entries, 2, "Single request is served from cache")
+
+	var metadata map[string]interface{}
+	err = json.Unmarshal(w.Body.Bytes(), &metadata)
+	require.NoError(t, err)
+	require.Equal(t, entries[0], metadata)
+}
+
+func TestMetadataListLimit(t *testing.T) {
+	request := fmt.Sprintf(`{
+		"sas": "n/a",
+		"vdsList": [{"vds": "%s"}, {"vds": "%s"}, {"vds": "%s"}]
+	}`, well_known, well_known, well_known)
+
+	endpoint := &api.Endpoint{
+		MakeVdsConnection: MakeFileConnection(),
+		Cache:             cache.NewNoCache(),
+		Limits:            api.Limits{MetadataList: 2},
+	}
+
+	w := serveMetadataList(t, endpoint, request)
+	require.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+	require.Contains(t, w.Body.String(), "Too many VDSs in vdsList: 3. The limit is 2")
+}
+
+func TestCompatibility(t *testing.T) {
+	testcases := []struct {
+		name           string
+		request        string
+		expectedStatus int
+		compatible     bool
+		expectedError  string
+	}{
+		{
+			name: "Same cube",
+			request: fmt.Sprintf(
+				`{"a": {"vds": "%s", "sas": "n/a"}, "b": {"vds": "%s", "sas": "n/a"}}`,
+				samples10,
+				samples10,
+			),
+			expectedStatus: http.StatusOK,
+			compatible:     true,
+		},
+		{
+			name: "Different cubes",
+			request: fmt.Sprintf(
+				`{"a": {"vds": "%s", "sas": "n/a"}, "b": {"vds": "%s", "sas": "n/a"}}`,
+				samples10,
+				well_known,
+			),
+			expectedStatus: http.StatusOK,
+			compatible:     false,
+		},
+		{
+			name: "Negative tolerance",
+			request: fmt.Sprintf(
+				`{"a": {"vds": "%s", "sas": "n/a"}, "b": {"vds": "%s", "sas": "n/a"}, "tolerance": -1}`,
+				samples10,
+				samples10,
+			),
+			expectedStatus: http.StatusBadRequest,
+			expectedError:  "Invalid tolerance: -1, must be non-negative",
+		},
+		{
+			name:           "Missing cube",
+			request:        fmt.Sprintf(`{"a": {"vds": "%s", "sas": "n/a"}}`, samples10),
+			expectedStatus: http.StatusBadRequest,
+			expectedError:  "field 'b.vds' is required",
+		},
+	}
+
+	for _, testcase := range testcases {
+		w := httptest.NewRecorder()
+		ctx, r := gin.CreateTestContext(w)
+		endpoint := api.Endpoint{
+			MakeVdsConnection: MakeFileConnection(),
+			Cache:             cache.NewNoCache(),
+		}
+		setupApp(r, &endpoint, nil, nil)
+
+		ctx.Request, _ = http.NewRequest(
+			http.MethodPost,
+			"/compatibility",
+			bytes.NewBufferString(testcase.request),
+		)
+		ctx.Request.Header.Set("Content-Type", "application/json")
+		r.ServeHTTP(w, ctx.Request)
+
+		require.Equalf(t, testcase.expectedStatus, w.Result().StatusCode,
+			"Wrong status in case '%s'. Body: %v", testcase.name, w.Body.String())
+
+		if testcase.expectedError != "" {
+			require.Containsf(t, w.Body.String(), testcase.expectedError,
+				"Wrong error in case '%s'", testcase.name)
+			continue
+		}
+
+		var compatibility map[string]interface{}
+		err := json.Unmarshal(w.Body.Bytes(), &compatibility)
+		require.NoError(t, err)
+		require.Equalf(t, testcase.compatible, compatibility["compatible"],
+			"Wrong compatibility in case '%s'", testcase.name)
+	}
+}
+
+func TestMetadataRanges(t *testing.T) {
+	w := httptest.NewRecorder()
+	ctx, r := gin.CreateTestContext(w)
+	endpoint := api.Endpoint{
+		MakeVdsConnection: MakeFileConnection(),
+		Cache:             cache.NewNoCache(),
+	}
+	setupApp(r, &endpoint, nil, nil)
+
+	query := url.Values{"vds": {well_known}, "sas": {"n/a"}}
+	ctx.Request, _ = http.NewRequest(
+		http.MethodGet,
+		"/metadata/ranges?"+query.Encode(),
+		nil,
+	)
+	r.ServeHTTP(w, ctx.Request)
+
+	require.Equalf(t, http.StatusOK, w.Result().StatusCode,
+		"Wrong status. Body: %v", w.Body.String())
+	require.NotEmpty(t, w.Result().Header.Get("ETag"))
+
+	inline := `"index": {"min": 0, "max": 2, "step": 1},
+		"annotation": {"min": 1, "max": 5, "step": 2}`
+	crossline := `"index": {"min": 0, "max": 1, "step": 1},
+		"annotation": {"min": 10, "max": 11, "step": 1}`
+	sample := `"index": {"min": 0, "max": 3, "step": 1},
+		"annotation": {"min": 4, "max": 16, "step": 4}`
+
+	/* The vertical axis is in ms, so it can not be sliced as depth */
+	expected := fmt.Sprintf(`{
+		"directions": [
+			{"direction": "i",         "axis": 0, %[1]s},
+			{"direction": "j",         "axis": 1, %[2]s},
+			{"direction": "k",         "axis": 2, %[3]s},
+			{"direction": "inline",    "axis": 0, %[1]s},
+			{"direction": "crossline", "axis": 1, %[2]s},
+			{"direction": "time",      "axis": 2, %[3]s},
+			{"direction": "sample",    "axis": 2, %[3]s}
+		],
+		"verticalWindow": {
+			"min": 0,
+			"max": 250,
+			"defaultStepsize": 4,
+			"unit": "ms"
+		}
+	}`, inline, crossline, sample)
+	require.JSONEq(t, expected, w.Body.String())
+}
+
+func TestDataPartChecksums(t *testing.T) {
+	testcase := sliceTest{
+		baseTest{
+			name:           "Slice with checksum",
+			method:         http.MethodPost,
+			expectedStatus: http.StatusOK,
+		},
+		testSliceRequest{
+			Vds:       well_known,
+			Direction: "i",
+			Lineno:    3,
+			Sas:       "n/a",
+		},
+	}
+	w := setupTest(t, testcase)
+	requireStatus(t, testcase, w)
+
+	_, params, err := mime.ParseMediaType(w.Result().Header.Get("Content-Type"))
+	require.NoError(t, err)
+	reader := multipart.NewReader(w.Body, params["boundary"])
+
+	_, err = reader.NextPart()
+	require.NoError(t, err, "Expected a metadata part")
+
+	part, err := reader.NextPart()
+	require.NoError(t, err, "Expected a data part")
+	data, err := io.ReadAll(part)
+	require.NoError(t, err)
+
+	checksum := part.Header.Get("X-Content-Checksum")
+	require.Regexp(t, "^crc32c=[0-9a-f]{8}$", checksum)
+
+	table := crc32.MakeTable(crc32.Castagnoli)
+	expected := fmt.Sprintf("crc32c=%08x", crc32.Checksum(data, table))
+	require.Equal(t, expected, checksum)
+
+	corrupted := append([]byte{}, data...)
+	corrupted[len(corrupted)/2] ^= 0x01
+	actual := fmt.Sprintf("crc32c=%08x", crc32.Checksum(corrupted, table))
+	require.NotEqual(t, checksum, actual, "A flipped bit must be detectable")
+}
+
+func TestContentLength(t *testing.T) {
+	testcases := []endpointTest{
+		sliceTest{
+			baseTest{
+				name:           "Slice",
+				method:         http.MethodPost,
+				expectedStatus: http.StatusOK,
+			},
+			testSliceRequest{
+				Vds:       well_known,
+				Direction: "i",
+				Lineno:    0,
+				Sas:       "n/a",
+			},
+		},
+		fenceTest{
+			baseTest{
+				name:           "Fence",
+				method:         http.MethodPost,
+				expectedStatus: http.StatusOK,
+			},
+			testFenceRequest{
+				Vds:              well_known,
+				CoordinateSystem: "ij",
+				Coordinates:      [][]float32{{0, 1}, {1, 1}, {1, 0}},
+				Sas:              "n/a",
+			},
+		},
+		attributeAlongSurfaceTest{
+			baseTest{
+				name:           "Several attributes",
+				method:         http.MethodPost,
+				expectedStatus: http.StatusOK,
+			},
+			testAttributeAlongSurfaceRequest{
+				Vds:        samples10,
+				Values:     [][]float32{{20, 20}, {20, 20}, {20, 20}},
+				Sas:        "n/a",
+				Above:      8.0,
+				Below:      4.0,
+				Attributes: []string{"samplevalue", "min", "max"},
+			},
+		},
+	}
+
+	for _, testcase := range testcases {
+		w := setupTest(t, testcase)
+		requireStatus(t, testcase, w)
+
+		name := testcase.base().name
+		require.Equalf(t,
+			strconv.Itoa(w.Body.Len()),
+			w.Result().Header.Get("Content-Length"),
+			"[%s] Content-Length differs from the body", name,
+		)
+	}
+}
+
+func TestRecoveryHTTPResponse(t *testing.T) {
+	defaultWriter := gin.DefaultWriter
+	defaultErrorWriter := gin.DefaultErrorWriter
+	defer func() {
+		gin.DefaultWriter = defaultWriter
+		gin.DefaultErrorWriter = defaultErrorWriter
+	}()
+	buffer := new(bytes.Buffer)
+	gin.DefaultWriter = buffer
+	gin.DefaultErrorWriter = buffer
+
+	w := httptest.NewRecorder()
+	ctx, r := gin.CreateTestContext(w)
+
+	endpoint := api.Endpoint{
+		MakeVdsConnection: MakeFileConnection(),
+		Cache:             cache.NewNoCache(),
+	}
+	setupApp(r, &endpoint, nil, nil)
+	r.POST("/panic", func(ctx *gin.Context) {
+		ctx.Set("request", "{vds: panicking.vds}")
+		panic("could not open https://account.blob.core.windows.net/c/b?sv=2021&sig=secret")
+	})
+
+	ctx.Request, _ = http.NewRequest(http.MethodPost, "/panic", nil)
+	r.ServeHTTP(w, ctx.Request)
+
+	require.Equal(t, http.StatusInternalServerError, w.Result().StatusCode)
+
+	var response api.ErrorResponse
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	require.NoError(t, err, "Body must be an ErrorResponse: %s", w.Body.String())
+	require.Equal(t, "internal_error", response.Code)
+	require.Regexp(t, "^[0-9a-f]{16}$", response.ErrorId)
+	require.NotContains(t, response.Error, "sig=")
+
+	log := buffer.String()
+	require.Equal(t, 2, strings.Count(log, response.ErrorId),
+		"Expected the error id in both the panic and the access log. Log: %s", log)
+	require.Contains(t, log, "{vds: panicking.vds}")
+	require.Contains(t, log, "goroutine", "Expected the stack in the log")
+	require.NotContains(t, log, "secret", "Log should not contain SAS")
+}
+
+func serveReady(t *testing.T, endpoint *api.Endpoint) (int, api.ReadyResponse) {
+	w := httptest.NewRecorder()
+	ctx, r := gin.CreateTestContext(w)
+	setupApp(r, endpoint, nil, nil)
+
+	ctx.Request, _ = http.NewRequest(http.MethodGet, "/ready", nil)
+	r.ServeHTTP(w, ctx.Request)
+
+	var response api.ReadyResponse
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	require.NoError(t, err, "Body: %s", w.Body.String())
+	return w.Result().StatusCode, response
+}
+
+func TestReady(t *testing.T) {
+	endpoint := &api.Endpoint{
+		MakeVdsConnection: MakeFileConnection(),
+		Cache:             cache.NewNoCache(),
+	}
+	status, response := serveReady(t, endpoint)
+	require.Equal(t, http.StatusOK, status, "Expected ready without warm-up")
+	require.Empty(t, response.Warmup)
+
+	records := &recordingCache{entries: map[string]cache.CacheEntry{}}
+	endpoint.Cache = records
+	endpoint.Warmup = api.NewWarmup(
+		[]string{well_known, "testdata/missing.vds"},
+		true,
+		false,
+	)
+
+	status, response = serveReady(t, endpoint)
+	require.Equal(t, http.StatusServiceUnavailable, status)
+	require.False(t, response.Ready)
+	require.Equal(t, "pending", response.Warmup[0].State)
+
+	endpoint.RunWarmup(context.Background())
+
+	status, response = serveReady(t, endpoint)
+	require.Equal(t, http.StatusOK, status, "Failed warm-up must not block readiness")
+	require.True(t, response.Ready)
+	require.Len(t, response.Warmup, 2)
+	require.Equal(t, well_known, response.Warmup[0].Vds)
+	require.Equal(t, "done", response.Warmup[0].State)
+	require.Empty(t, response.Warmup[0].Error)
+	require.Equal(t, "failed", response.Warmup[1].State)
+	require.NotEmpty(t, response.Warmup[1].Error)
+	require.Len(t, records.entries, 1, "Expected the metadata to be prefetched")
+
+	endpoint.Warmup = api.NewWarmup([]string{well_known}, false, true)
+	status, response = serveReady(t, endpoint)
+	require.Equal(t, http.StatusOK, status, "Async warm-up must not block readiness")
+	require.True(t, response.Ready)
+	require.Equal(t, "pending", response.Warmup[0].State)
+}
+
+func TestVdsAlias(t *testing.T) {
+	records := &recordingCache{entries: map[string]cache.CacheEntry{}}
+	endpoint := &api.Endpoint{
+		MakeVdsConnection: MakeFileConnection(),
+		Cache:             records,
+		Resolver: core.StaticResolver{
+			"well-known": {Url: well_known, Sas: "n/a"},
+		},
+	}
+
+	w := serveMetadataList(t, endpoint, `{"vds": "well-known"}`)
+	require.Equalf(t, http.StatusOK, w.Result().StatusCode,
+		"Wrong response status. Body: %v", w.Body.String())
+	require.Len(t, records.entries, 1)
+
+	direct := fmt.Sprintf(`{"vds": "%s", "sas": "n/a"}`, well_known)
+	w = serveMetadataList(t, endpoint, direct)
+	require.Equal(t, http.StatusOK, w.Result().StatusCode)
+	require.Len(t, records.entries, 1, "Alias and url should share cache entry")
+
+	w = serveMetadataList(t, endpoint, `{"vds": "missing"}`)
+	require.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+
+	var response api.ErrorResponse
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	require.NoError(t, err)
+	require.Equal(t, "unresolved_vds_alias", response.Code)
+	require.Contains(t, response.Error, "'missing'")
+
+	list := `{"vdsList": [{"vds": "well-known"}, {"vds": "missing"}]}`
+	w = serveMetadataList(t, endpoint, list)
+	require.Equal(t, http.StatusOK, w.Result().StatusCode)
+
+	var entries []map[string]interface{}
+	err = json.Unmarshal(w.Body.Bytes(), &entries)
+	require.NoError(t, err)
+	require.Equal(t, "well_known.segy", entries[0]["inputFileName"])
+	require.Equal(t, "unresolved_vds_alias", entries[1]["code"])
+}
+
+/* Observer that records the request sizes reported to it */
+type recordingObserver struct {
+	mu           sync.Mutex
+	observations []string
+}
+
+func (o *recordingObserver) record(format string, args ...interface{}) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.observations = append(o.observations, fmt.Sprintf(format, args...))
+}
+
+func (o *recordingObserver) FenceCoordinates(endpoint string, count int) {
+	o.record("%s: coordinates %d", endpoint, count)
+}
+
+func (o *recordingObserver) SurfacePoints(endpoint string, count int) {
+	o.record("%s: points %d", endpoint, count)
+}
+
+func (o *recordingObserver) SamplePoints(endpoint string, count int) {
+	o.record("%s: sample points %d", endpoint, count)
+}
+
+func (o *recordingObserver) SliceSamples(endpoint string, count int) {
+	o.record("%s: samples %d", endpoint, count)
+}
+
+func (o *recordingObserver) AttributeWindow(endpoint string, length float64) {
+	o.record("%s: window %g", endpoint, length)
+}
+
+func (o *recordingObserver) FenceBatches(endpoint string, count int) {
+	o.record("%s: batches %d", endpoint, count)
+}
+
+func TestRequestObserver(t *testing.T) {
+	observer := &recordingObserver{}
+	endpoint := api.Endpoint{
+		MakeVdsConnection: MakeFileConnection(),
+		Cache:             cache.NewNoCache(),
+		Observer:          observer,
+	}
+
+	surface := `{
+		"values": [[20, 20], [20, 20], [20, 20]],
+		"rotation": 33.69, "xinc": 7.2111, "yinc": 3.6056,
+		"xori": 2, "yori": 0, "fillValue": 666.66
+	}`
+	requests := []struct {
+		path string
+		body string
+	}{
+		{
+			path: "/slice",
+			body: `{"vds": "` + well_known + `", "sas": "n/a",
+				"direction": "i", "lineno": 0}`,
+		},
+		{
+			path: "/fence",
+			body: `{"vds": "` + well_known + `", "sas": "n/a",
+				"coordinateSystem": "ij", "coordinates": [[0, 1], [1, 1], [1, 0]]}`,
+		},
+		{
+			path: "/attributes/surface/along",
+			body: `{"vds": "` + samples10 + `", "sas": "n/a",
+				"surface": ` + surface + `, "above": 8, "below": 4,
+				"attributes": ["samplevalue"]}`,
+		},
+		{
+			path: "/attributes/surface/between",
+			body: `{"vds": "` + samples10 + `", "sas": "n/a",
+				"primarySurface": ` + surface + `,
+				"secondarySurface": ` + surface + `,
+				"attributes": ["samplevalue"]}`,
+		},
+		{
+			path: "/slice",
+			body: `{"vds": "` + well_known + `", "sas": "n/a",
+				"direction": "i", "lineno": 10}`,
+		},
+	}
+
+	for _, request := range requests {
+		w := httptest.NewRecorder()
+		ctx, r := gin.CreateTestContext(w)
+		setupApp(r, &endpoint, nil, nil)
+
+		ctx.Request, _ = http.NewRequest(
+			http.MethodPost,
+			request.path,
+			bytes.NewBufferString(request.body),
+		)
+		ctx.Request.Header.Set("Content-Type", "application/json")
+		r.ServeHTTP(w, ctx.Request)
+	}
+
+	require.Equal(t, []string{
+		"slice: samples 8",
+		"fence: coordinates 3",
+		"attributes/surface/along: points 6",
+		"attributes/surface/along: window 12",
+		"attributes/surface/between: points 6",
+	}, observer.observations, "Expected failed requests not to be observed")
+}
+
+func TestStorageBytes(t *testing.T) {
+	endpoint := api.Endpoint{
+		MakeVdsConnection: MakeFileConnection(),
+		Cache:             &recordingCache{entries: map[string]cache.CacheEntry{}},
+	}
+
+	app := gin.New()
+	storageBytes := []int64{}
+	storageRequests := []int64{}
+	storageReads := []time.Duration{}
+	app.Use(func(ctx *gin.Context) {
+		ctx.Next()
+		storageBytes = append(storageBytes, ctx.GetInt64("storage-bytes"))
+		storageRequests = append(storageRequests, ctx.GetInt64("storage-requests"))
+		storageReads = append(storageReads, ctx.GetDuration("storage-read-time"))
+	})
+	setupApp(app, &endpoint, nil, nil)
+
+	body := `{"vds": "` + well_known + `", "sas": "n/a",
+		"direction": "i", "lineno": 0}`
+	for i := 0; i < 2; i++ {
+		w := httptest.NewRecorder()
+		request, _ := http.NewRequest(
+			http.MethodPost,
+			"/slice",
+			bytes.NewBufferString(body),
+		)
+		request.Header.Set("Content-Type", "application/json")
+		app.ServeHTTP(w, request)
+		require.Equal(t, http.StatusOK, w.Code)
+	}
+
+	require.Len(t, storageBytes, 2)
+	require.Greater(t, storageBytes[0], int64(0),
+		"Expected reads from storage to be counted")
+	require.Equal(t, int64(0), storageBytes[1],
+		"Expected cache hits not to read from storage")
+
+	require.Greater(t, storageRequests[0], int64(0),
+		"Expected requests to storage to be counted")
+	require.Greater(t, storageReads[0], time.Duration(0),
+		"Expected the time spent reading to be measured")
+	require.Equal(t, int64(0), storageRequests[1],
+		"Expected cache hits not to request anything from storage")
+	require.Equal(t, time.Duration(0), storageReads[1],
+		"Expected cache hits not to spend time reading")
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"os"
+	"strconv"
+	"strings"
+)
+
+/*
+ * Where the memory limit of the container is found, for cgroup v2 and v1
+ * respectively
+ */
+var cgroupMemoryLimitFiles = []string{
+	"/sys/fs/cgroup/memory.max",
+	"/sys/fs/cgroup/memory/memory.limit_in_bytes",
+}
+
+/*
+ * cgroup v1 reports no limit as a number close to the max int64, rounded
+ * down to the page size
+ */
+const unlimitedMemory = 1 << 60
+
+/** The memory limit of the container, from the first file that has one
+ *
+ * Returns zero if none of the files exist or hold a limit, e.g. outside of
+ * a container.
+ */
+func containerMemoryLimit(files []string) int64 {
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			continue
+		}
+
+		value := strings.TrimSpace(string(content))
+		if value == "max" {
+			return 0
+		}
+
+		limit, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || limit <= 0 || limit >= unlimitedMemory {
+			return 0
+		}
+		return limit
+	}
+	return 0
+}
+
+/** The memory budget for responses, as percent of limit
+ *
+ * Zero, i.e. no budget, if either is zero.
+ */
+func memoryBudget(limit int64, percent uint32) int64 {
+	return limit / 100 * int64(percent)
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestContainerMemoryLimit(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name string, content string) string {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+		return path
+	}
+
+	v2 := write("memory.max", "2147483648\n")
+	v2unlimited := write("memory.max.unlimited", "max\n")
+	v1unlimited := write("memory.limit_in_bytes", "9223372036854771712\n")
+	missing := filepath.Join(dir, "missing")
+
+	testcases := []struct {
+		name     string
+		files    []string
+		expected int64
+	}{
+		{name: "cgroup v2", files: []string{v2}, expected: 2147483648},
+		{name: "First existing file", files: []string{missing, v2}, expected: 2147483648},
+		{name: "cgroup v2 without limit", files: []string{v2unlimited, v2}, expected: 0},
+		{name: "cgroup v1 without limit", files: []string{v1unlimited}, expected: 0},
+		{name: "No cgroup", files: []string{missing}, expected: 0},
+	}
+
+	for _, testcase := range testcases {
+		require.Equalf(t, testcase.expected, containerMemoryLimit(testcase.files),
+			"[%s]", testcase.name)
+	}
+}
+
+func TestMemoryBudgetFromLimit(t *testing.T) {
+	require.Equal(t, int64(700), memoryBudget(1000, 70))
+	require.Equal(t, int64(0), memoryBudget(0, 70))
+	require.Equal(t, int64(0), memoryBudget(1000, 0))
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	swaggerFiles "github.com/swaggo/files"
+	ginSwagger "github.com/swaggo/gin-swagger"
+
+	"github.com/equinor/vds-slice/docs"
+)
+
+/** The OpenAPI document of the server
+ *
+ * The document is generated by swag from the annotations of the handlers and
+ * compiled into the binary, such that it always describes the server that
+ * serves it. The host is that of the request, such that "try it out" in the
+ * docs calls the same deployment the docs were loaded from.
+ */
+func openapiGet(ctx *gin.Context) {
+	spec := *docs.SwaggerInfo
+	spec.Host = ctx.Request.Host
+	ctx.Data(
+		http.StatusOK,
+		"application/json; charset=utf-8",
+		[]byte(spec.ReadDoc()),
+	)
+}
+
+/** Register the OpenAPI document and the interactive docs
+ *
+ * The docs are the Swagger UI, which is embedded in the binary by
+ * swaggo/files, and reads the document from /openapi.json. The UI gets a
+ * file handler of its own, as the handler remembers the prefix it is served
+ * under.
+ */
+func registerDocsRoutes(app *gin.Engine) {
+	app.GET("/openapi.json", openapiGet)
+	app.GET("/docs", func(ctx *gin.Context) {
+		ctx.Redirect(http.StatusMovedPermanently, "/docs/index.html")
+	})
+	app.GET("/docs/*any", ginSwagger.WrapHandler(
+		swaggerFiles.NewHandler(),
+		ginSwagger.URL("/openapi.json"),
+	))
+}
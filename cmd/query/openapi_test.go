@@ -0,0 +1,66 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+
+	"github.com/equinor/vds-slice/api"
+	"github.com/equinor/vds-slice/internal/cache"
+)
+
+func serveDocs(t *testing.T, path string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	ctx, r := gin.CreateTestContext(w)
+
+	endpoint := api.Endpoint{
+		MakeVdsConnection: MakeFileConnection(),
+		Cache:             cache.NewNoCache(),
+	}
+	setupApp(r, &endpoint, nil, nil)
+
+	ctx.Request = httptest.NewRequest(http.MethodGet, path, nil)
+	ctx.Request.Host = "vds.example.com:8080"
+	r.ServeHTTP(w, ctx.Request)
+	return w
+}
+
+func TestOpenAPIDocument(t *testing.T) {
+	w := serveDocs(t, "/openapi.json")
+	require.Equal(t, http.StatusOK, w.Result().StatusCode)
+	require.Contains(t, w.Result().Header.Get("Content-Type"), "application/json")
+
+	var document struct {
+		Host  string                     `json:"host"`
+		Paths map[string]json.RawMessage `json:"paths"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &document),
+		"Expected the document to be valid json")
+	require.Equal(t, "vds.example.com:8080", document.Host,
+		"Expected the host of the request")
+
+	require.Contains(t, document.Paths, "/slice")
+	attributes := 0
+	for path := range document.Paths {
+		if strings.HasPrefix(path, "/attributes/") {
+			attributes++
+		}
+	}
+	require.NotZero(t, attributes, "Expected the /attributes paths")
+}
+
+func TestInteractiveDocs(t *testing.T) {
+	w := serveDocs(t, "/docs")
+	require.Equal(t, http.StatusMovedPermanently, w.Result().StatusCode)
+	require.Equal(t, "/docs/index.html", w.Result().Header.Get("Location"))
+
+	w = serveDocs(t, "/docs/index.html")
+	require.Equal(t, http.StatusOK, w.Result().StatusCode)
+	require.Contains(t, w.Body.String(), "/openapi.json",
+		"Expected the docs to read the document served by /openapi.json")
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+
+	"github.com/equinor/vds-slice/api"
+	"github.com/equinor/vds-slice/internal/core"
+	"github.com/equinor/vds-slice/internal/ratelimit"
+)
+
+/** Applies the reloadable settings of the config to a running server
+ *
+ * The config is loaded from all sources again, like at startup, and
+ * validated as a whole. An invalid config is rejected, and the server keeps
+ * the settings it has. Changes to settings that are not reloadable are
+ * ignored until the next restart. The max message size of grpc is fixed
+ * when the grpc server is set up, so max-attribute-request-size is only
+ * reloaded for http.
+ *
+ * Safe for concurrent use.
+ */
+type configReloader struct {
+	load func() (config, error)
+	/* The config the server was started with */
+	startup config
+
+	live      *api.LiveConfig
+	allowlist *core.Allowlist
+	budget    *core.MemoryBudget
+	limiter   *ratelimit.Limiter
+	/* The rate limiter of validate, see validateRateLimit */
+	validateLimiter *ratelimit.Limiter
+	/* The memory limit of the container, which the budget is a share of */
+	memoryLimit int64
+
+	lock sync.Mutex
+}
+
+/** Load the config and apply it
+ *
+ * Returns the new generation of the config, and the settings that were
+ * changed but cannot be reloaded.
+ */
+func (r *configReloader) reload() (
+	generation api.ConfigGeneration,
+	ignored []string,
+	err error,
+) {
+	cfg, err := r.load()
+	if err != nil {
+		return generation, nil, err
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	/*
+	 * The allowlist is the only part that can fail, so it goes first, such
+	 * that nothing is applied if it does
+	 */
+	err = r.allowlist.Set(cfg.storageAccountList())
+	if err != nil {
+		return generation, nil, fmt.Errorf("storage-accounts: %v", err)
+	}
+	r.budget.SetLimit(memoryBudget(r.memoryLimit, cfg.memoryBudget))
+	r.limiter.SetRate(rateLimit(cfg))
+	if r.validateLimiter != nil {
+		r.validateLimiter.SetRate(validateRateLimit(cfg))
+	}
+	/* The cache is created at startup, and keeps the size it had then */
+	reloaded := limits(cfg)
+	reloaded.CacheSize = r.startup.cacheSize
+	generation = r.live.Reload(reloaded)
+
+	for _, s := range settings {
+		if s.reloadable {
+			continue
+		}
+		if s.value(&cfg) != s.value(&r.startup) {
+			ignored = append(ignored, s.name)
+		}
+	}
+	return generation, ignored, nil
+}
+
+/** Reload the config on SIGHUP
+ *
+ * Runs until the process exits. Rejected reloads are logged, and the server
+ * keeps running with the config it has.
+ */
+func reloadConfigOnHangup(reloader *configReloader) {
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+
+	go func() {
+		for range hangup {
+			generation, ignored, err := reloader.reload()
+			if err != nil {
+				log.Printf(
+					"Config reload rejected, keeping generation %d: %v",
+					reloader.live.Generation().Generation,
+					err,
+				)
+				continue
+			}
+			log.Printf("Reloaded config, generation %d", generation.Generation)
+			for _, name := range ignored {
+				log.Printf("Config reload: %s changed, but requires a restart", name)
+			}
+		}
+	}()
+}
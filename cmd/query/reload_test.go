@@ -0,0 +1,172 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+
+	"github.com/equinor/vds-slice/api"
+	"github.com/equinor/vds-slice/internal/cache"
+	"github.com/equinor/vds-slice/internal/core"
+	"github.com/equinor/vds-slice/internal/ratelimit"
+)
+
+/* A reloader of the config file at path, as set up by main */
+func newTestReloader(t *testing.T, path string) *configReloader {
+	cl := newCommandLine()
+	require.NoError(t, cl.parse([]string{"query", "--config", path}))
+	load := func() (config, error) {
+		return loadConfig(cl, makeGetenv(map[string]string{}))
+	}
+
+	cfg, err := load()
+	require.NoError(t, err)
+
+	allowlist, err := core.NewAllowlist(cfg.storageAccountList())
+	require.NoError(t, err)
+
+	return &configReloader{
+		load:        load,
+		startup:     cfg,
+		live:        api.NewLiveConfig(limits(cfg)),
+		allowlist:   allowlist,
+		budget:      core.NewMemoryBudget(0, nil),
+		limiter:     ratelimit.NewLimiter(rateLimit(cfg)),
+		memoryLimit: 1000,
+	}
+}
+
+func TestConfigReload(t *testing.T) {
+	path := writeConfigFile(t, ""+
+		"max-attributes: 2\n"+
+		"max-request-size: 1\n"+
+		"cache-size: 100\n",
+	)
+	reloader := newTestReloader(t, path)
+	require.Equal(t, 1, reloader.live.Generation().Generation)
+
+	err := os.WriteFile(path, []byte(""+
+		"max-attributes: 5\n"+
+		"max-request-size: 1\n"+
+		"cache-size: 200\n"+
+		"memory-budget: 50\n"+
+		"port: 9000\n"+
+		"storage-accounts: https://account.blob.core.windows.net\n",
+	), 0o600)
+	require.NoError(t, err)
+
+	generation, ignored, err := reloader.reload()
+	require.NoError(t, err)
+	require.Equal(t, 2, generation.Generation)
+	require.Equal(t, generation, reloader.live.Generation())
+	require.Equal(t, 5, reloader.live.Limits().Attributes)
+	require.Equal(t, int64(500), reloader.budget.Limit)
+	require.Equal(t, uint64(100), reloader.live.Limits().CacheSize,
+		"Expected the cache size the server started with")
+	require.Equal(t, []string{"port", "cache-size"}, ignored)
+
+	_, err = core.MakeAzureConnection(reloader.allowlist, 0, "")(
+		"https://other.blob.core.windows.net/container/blob",
+		core.Credentials{Sas: "sp=r&sig=sas"},
+	)
+	require.IsType(t, &core.ForbiddenError{}, err,
+		"Expected the reloaded allowlist to apply")
+}
+
+func TestConfigReloadRejectsInvalidConfig(t *testing.T) {
+	path := writeConfigFile(t, "max-attributes: 2\n")
+	reloader := newTestReloader(t, path)
+
+	invalid := []struct {
+		content  string
+		expected string
+	}{
+		{
+			content:  "max-attributes: many\n",
+			expected: "max-attributes: cannot parse 'many'",
+		},
+		{
+			content:  "max-attributes: 5\nunknown: 1\n",
+			expected: "unknown setting 'unknown'",
+		},
+		{
+			content:  "max-attributes: 5\nstorage-accounts: account.blob.core.windows.net\n",
+			expected: "storage-accounts: Storage-account 'account.blob.core.windows.net' must contain both scheme and host",
+		},
+	}
+
+	for _, testcase := range invalid {
+		err := os.WriteFile(path, []byte(testcase.content), 0o600)
+		require.NoError(t, err)
+
+		_, _, err = reloader.reload()
+		require.ErrorContains(t, err, testcase.expected)
+		require.Equal(t, 1, reloader.live.Generation().Generation,
+			"Expected the old config to be kept")
+		require.Equal(t, 2, reloader.live.Limits().Attributes,
+			"Expected the old limits to be kept")
+	}
+}
+
+/* Reloaded limits apply to the routes, and the generation is on /version */
+func TestConfigReloadHTTP(t *testing.T) {
+	path := writeConfigFile(t, "max-request-size: 10\n")
+	reloader := newTestReloader(t, path)
+
+	app := gin.New()
+	endpoint := api.Endpoint{
+		MakeVdsConnection: MakeFileConnection(),
+		Cache:             cache.NewNoCache(),
+		Config:            reloader.live,
+	}
+	setupApp(app, &endpoint, nil, nil)
+
+	version := func() api.VersionResponse {
+		w := httptest.NewRecorder()
+		request, _ := http.NewRequest(http.MethodGet, "/version", nil)
+		app.ServeHTTP(w, request)
+		require.Equal(t, http.StatusOK, w.Result().StatusCode)
+
+		response := api.VersionResponse{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		return response
+	}
+
+	/* Padded in front, such that the whole body is read to bind it */
+	request := fmt.Sprintf(`{"vds": "%s", "sas": "n/a"}`, well_known)
+	body := strings.Repeat(" ", 2*megabyte) + request
+	postMetadata := func() int {
+		w := httptest.NewRecorder()
+		request, _ := http.NewRequest(
+			http.MethodPost,
+			"/metadata",
+			strings.NewReader(body),
+		)
+		request.Header.Set("Content-Type", "application/json")
+		app.ServeHTTP(w, request)
+		return w.Result().StatusCode
+	}
+
+	response := version()
+	require.Equal(t, 1, response.Config.Generation)
+	require.Equal(t, int64(10*megabyte), response.Capabilities.Limits.RequestSize)
+	require.Equal(t, http.StatusOK, postMetadata())
+
+	err := os.WriteFile(path, []byte("max-request-size: 1\n"), 0o600)
+	require.NoError(t, err)
+	_, _, err = reloader.reload()
+	require.NoError(t, err)
+
+	response = version()
+	require.Equal(t, 2, response.Config.Generation)
+	require.False(t, response.Config.LoadedAt.IsZero())
+	require.Equal(t, int64(megabyte), response.Capabilities.Limits.RequestSize)
+	require.Equal(t, http.StatusRequestEntityTooLarge, postMetadata())
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+)
+
+/** A certificate and key pair that can be re-read from disk
+ *
+ * Handed to the tls config through GetCertificate, such that rotated
+ * certificates are picked up by new connections without a restart.
+ *
+ * Safe for concurrent use.
+ */
+type certificateReloader struct {
+	certFile string
+	keyFile  string
+
+	lock        sync.RWMutex
+	certificate *tls.Certificate
+}
+
+func newCertificateReloader(
+	certFile string,
+	keyFile string,
+) (*certificateReloader, error) {
+	reloader := &certificateReloader{certFile: certFile, keyFile: keyFile}
+	err := reloader.reload()
+	return reloader, err
+}
+
+/** Re-read the certificate and key
+ *
+ * On failure the current certificate is kept, such that a botched rotation
+ * does not take the server down.
+ */
+func (r *certificateReloader) reload() error {
+	certificate, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf(
+			"unable to load certificate %s and key %s: %v",
+			r.certFile,
+			r.keyFile,
+			err,
+		)
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.certificate = &certificate
+	return nil
+}
+
+func (r *certificateReloader) getCertificate(
+	*tls.ClientHelloInfo,
+) (*tls.Certificate, error) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	return r.certificate, nil
+}
+
+/** Server side tls config
+ *
+ * Clients must present a certificate signed by one of the certificates in the
+ * clientCA bundle, if given. HTTP/2 is negotiated by net/http and grpc on top
+ * of this config.
+ */
+func newTLSConfig(
+	reloader *certificateReloader,
+	clientCA string,
+) (*tls.Config, error) {
+	config := &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: reloader.getCertificate,
+	}
+
+	if clientCA == "" {
+		return config, nil
+	}
+
+	bundle, err := os.ReadFile(clientCA)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read client CA bundle: %v", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(bundle) {
+		return nil, fmt.Errorf(
+			"no certificates found in client CA bundle %s",
+			clientCA,
+		)
+	}
+	config.ClientCAs = pool
+	config.ClientAuth = tls.RequireAndVerifyClientCert
+	return config, nil
+}
+
+/** Re-read the certificates on SIGHUP
+ *
+ * Runs until the process exits. Failed reloads are logged, and the old
+ * certificate stays in use.
+ */
+func reloadOnHangup(reloaders ...*certificateReloader) {
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+
+	go func() {
+		for range hangup {
+			for _, reloader := range reloaders {
+				if err := reloader.reload(); err != nil {
+					log.Printf("Certificate reload failed: %v", err)
+					continue
+				}
+				log.Printf("Reloaded certificate %s", reloader.certFile)
+			}
+		}
+	}()
+}
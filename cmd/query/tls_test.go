@@ -0,0 +1,183 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type testCertificate struct {
+	certificate *x509.Certificate
+	key         *ecdsa.PrivateKey
+	certPEM     []byte
+	keyPEM      []byte
+}
+
+/* Certificate for localhost, signed by parent, or self-signed if nil */
+func makeTestCertificate(
+	t *testing.T,
+	name string,
+	parent *testCertificate,
+) *testCertificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: name},
+		DNSNames:              []string{"localhost"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  parent == nil,
+	}
+
+	signer, signerKey := template, key
+	if parent != nil {
+		signer, signerKey = parent.certificate, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	require.NoError(t, err)
+	certificate, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	return &testCertificate{
+		certificate: certificate,
+		key:         key,
+		certPEM:     pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		keyPEM:      pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}),
+	}
+}
+
+func (c *testCertificate) write(t *testing.T, dir string) (string, string) {
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+	require.NoError(t, os.WriteFile(certFile, c.certPEM, 0o600))
+	require.NoError(t, os.WriteFile(keyFile, c.keyPEM, 0o600))
+	return certFile, keyFile
+}
+
+func (c *testCertificate) keyPair(t *testing.T) tls.Certificate {
+	pair, err := tls.X509KeyPair(c.certPEM, c.keyPEM)
+	require.NoError(t, err)
+	return pair
+}
+
+func TestCertificateReload(t *testing.T) {
+	dir := t.TempDir()
+	first := makeTestCertificate(t, "first", nil)
+	certFile, keyFile := first.write(t, dir)
+
+	reloader, err := newCertificateReloader(certFile, keyFile)
+	require.NoError(t, err)
+
+	current, err := reloader.getCertificate(nil)
+	require.NoError(t, err)
+	require.Equal(t, first.certificate.Raw, current.Certificate[0])
+
+	second := makeTestCertificate(t, "second", nil)
+	second.write(t, dir)
+	err = reloader.reload()
+	require.NoError(t, err)
+
+	current, err = reloader.getCertificate(nil)
+	require.NoError(t, err)
+	require.Equal(t, second.certificate.Raw, current.Certificate[0])
+
+	err = os.WriteFile(certFile, []byte("garbage"), 0o600)
+	require.NoError(t, err)
+	err = reloader.reload()
+	require.Error(t, err)
+
+	current, err = reloader.getCertificate(nil)
+	require.NoError(t, err)
+	require.Equal(t, second.certificate.Raw, current.Certificate[0],
+		"A failed reload should keep the current certificate")
+
+	_, err = newCertificateReloader(certFile, keyFile)
+	require.Error(t, err)
+}
+
+func TestMutualTLS(t *testing.T) {
+	ca := makeTestCertificate(t, "ca", nil)
+	serverCert := makeTestCertificate(t, "server", ca)
+	clientCert := makeTestCertificate(t, "client", ca)
+	strangerCert := makeTestCertificate(t, "stranger", nil)
+
+	dir := t.TempDir()
+	certFile, keyFile := serverCert.write(t, dir)
+	caFile := filepath.Join(dir, "ca.pem")
+	require.NoError(t, os.WriteFile(caFile, ca.certPEM, 0o600))
+
+	reloader, err := newCertificateReloader(certFile, keyFile)
+	require.NoError(t, err)
+	config, err := newTLSConfig(reloader, caFile)
+	require.NoError(t, err)
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Protocol", r.Proto)
+		},
+	))
+	server.TLS = config
+	server.EnableHTTP2 = true
+	server.StartTLS()
+	defer server.Close()
+
+	/*
+	 * The server name makes the client send SNI, without which the server
+	 * would fall back to the certificate of httptest
+	 */
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.certificate)
+
+	get := func(certificates []tls.Certificate) (*http.Response, error) {
+		transport := &http.Transport{
+			TLSClientConfig: &tls.Config{
+				RootCAs:      roots,
+				ServerName:   "localhost",
+				Certificates: certificates,
+			},
+			ForceAttemptHTTP2: true,
+		}
+		defer transport.CloseIdleConnections()
+		client := &http.Client{Transport: transport}
+		return client.Get(server.URL)
+	}
+
+	response, err := get([]tls.Certificate{clientCert.keyPair(t)})
+	require.NoError(t, err)
+	response.Body.Close()
+	require.Equal(t, http.StatusOK, response.StatusCode)
+	require.Equal(t, "HTTP/2.0", response.Header.Get("Protocol"))
+
+	_, err = get(nil)
+	require.Error(t, err, "Clients without a certificate must be rejected")
+
+	_, err = get([]tls.Certificate{strangerCert.keyPair(t)})
+	require.Error(t, err, "Clients with an unknown certificate must be rejected")
+
+	_, err = newTLSConfig(reloader, certFile+".missing")
+	require.Error(t, err)
+}
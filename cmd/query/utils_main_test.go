@@ -18,6 +18,7 @@ import (
 	"github.com/equinor/vds-slice/api"
 	"github.com/equinor/vds-slice/internal/cache"
 	"github.com/equinor/vds-slice/internal/core"
+	"github.com/equinor/vds-slice/vds/vdstest"
 )
 
 const well_known = "../../testdata/well_known/well_known_default.vds"
@@ -155,6 +156,15 @@ func (h attributeAlongSurfaceTest) requestAsJSON() (string, error) {
 	} else {
 		out["interpolation"] = "cubic"
 	}
+	if h.attribute.VerticalInterpolation != "" {
+		out["verticalInterpolation"] = h.attribute.VerticalInterpolation
+	}
+	if h.attribute.OmitMetadata {
+		out["omitMetadata"] = true
+	}
+	if h.attribute.Echo {
+		out["echo"] = true
+	}
 
 	req, err := json.Marshal(out)
 	if err != nil {
@@ -229,19 +239,26 @@ type testBound struct {
 }
 
 type testSliceRequest struct {
-	Vds       string      `json:"vds"`
-	Direction string      `json:"direction"`
-	Lineno    int         `json:"lineno"`
-	Sas       string      `json:"sas"`
-	Bounds    []testBound `json:"bounds"`
+	Vds        string      `json:"vds"`
+	Direction  string      `json:"direction"`
+	Lineno     int         `json:"lineno"`
+	LinenoMode string      `json:"linenoMode,omitempty"`
+	Sas        string      `json:"sas"`
+	Bounds     []testBound `json:"bounds"`
+
+	OmitMetadata bool `json:"omitMetadata,omitempty"`
+	Raw          bool `json:"raw,omitempty"`
+	Echo         bool `json:"echo,omitempty"`
 }
 
 type testFenceRequest struct {
 	Vds              string      `json:"vds"`
 	CoordinateSystem string      `json:"coordinateSystem"`
 	Coordinates      [][]float32 `json:"coordinates"`
+	Interpolation    string      `json:"interpolation,omitempty"`
 	FillValue        float32     `json:"fillValue"`
 	Sas              string      `json:"sas"`
+	Echo             bool        `json:"echo,omitempty"`
 }
 
 type testMetadataRequest struct {
@@ -250,14 +267,17 @@ type testMetadataRequest struct {
 }
 
 type testAttributeAlongSurfaceRequest struct {
-	Vds           string
-	Sas           string
-	Values        [][]float32
-	Interpolation string
-	Above         float32
-	Below         float32
-	StepSize      float32
-	Attributes    []string
+	Vds                   string
+	Sas                   string
+	Values                [][]float32
+	Interpolation         string
+	VerticalInterpolation string
+	Above                 float32
+	Below                 float32
+	StepSize              float32
+	Attributes            []string
+	OmitMetadata          bool
+	Echo                  bool
 }
 
 type testAttributeBetweenSurfacesRequest struct {
@@ -276,7 +296,9 @@ type testSliceAxis struct {
 	Min        float32 `json:"min"        binding:"required"`
 	Samples    int     `json:"samples"    binding:"required"`
 	StepSize   float32 `json:"stepsize"   binding:"required"`
+	Regular    bool    `json:"regular"`
 	Unit       string  `json:"unit"       binding:"required"`
+	RawUnit    string  `json:"rawUnit"    binding:"required"`
 }
 
 type testSliceMetadata struct {
@@ -285,23 +307,53 @@ type testSliceMetadata struct {
 	Format string        `json:"format" binding:"required"`
 }
 
+const repositoryRoot = "../.."
+
 func MakeFileConnection() core.ConnectionMaker {
-	return func(path, sas string) (core.Connection, error) {
-		path = fmt.Sprintf("file://%s", path)
-		return core.NewFileConnection(path), nil
-	}
+	return core.MakeLocalConnection(repositoryRoot, nil)
+}
+
+/** A source of the cubes tests read
+ *
+ * Tests that run for every backend check that the in-memory cubes of
+ * vdstest answer the same as the files they stand in for.
+ */
+type backend struct {
+	name     string
+	endpoint func() api.Endpoint
+}
+
+var backends = []backend{
+	{"vds", func() api.Endpoint {
+		return api.Endpoint{
+			MakeVdsConnection: MakeFileConnection(),
+			Cache:             cache.NewNoCache(),
+		}
+	}},
+	{"memory", func() api.Endpoint {
+		storage := vdstest.Storage{well_known: vdstest.WellKnown()}
+		return api.Endpoint{
+			MakeVdsConnection: storage.MakeConnection(),
+			OpenHandle:        storage.Open,
+			Cache:             cache.NewNoCache(),
+		}
+	}},
 }
 
 func setupTest(t *testing.T, testcase endpointTest) *httptest.ResponseRecorder {
+	return setupTestWith(t, backends[0], testcase)
+}
+
+func setupTestWith(
+	t *testing.T,
+	backend backend,
+	testcase endpointTest,
+) *httptest.ResponseRecorder {
 	w := httptest.NewRecorder()
 	ctx, r := gin.CreateTestContext(w)
 
-	endpoint := api.Endpoint{
-		MakeVdsConnection: MakeFileConnection(),
-		Cache:             cache.NewNoCache(),
-	}
-
-	setupApp(r, &endpoint, nil)
+	endpoint := backend.endpoint()
+	setupApp(r, &endpoint, nil, nil)
 
 	prepareRequest(ctx, t, testcase)
 	r.ServeHTTP(w, ctx.Request)
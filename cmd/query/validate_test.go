@@ -0,0 +1,140 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+
+	"github.com/equinor/vds-slice/api"
+	"github.com/equinor/vds-slice/internal/cache"
+	"github.com/equinor/vds-slice/internal/ratelimit"
+)
+
+func TestValidate(t *testing.T) {
+	yes, no := true, false
+	testcases := []struct {
+		name     string
+		vds      string
+		status   int
+		expected api.ValidateResponse
+	}{
+		{
+			name:   "VDS",
+			vds:    well_known,
+			status: http.StatusOK,
+			expected: api.ValidateResponse{
+				Exists:      &yes,
+				Authorized:  &yes,
+				VdsFormatOk: &yes,
+			},
+		},
+		{
+			name:     "Missing VDS",
+			vds:      "../../testdata/well_known/missing.vds",
+			status:   http.StatusNotFound,
+			expected: api.ValidateResponse{Exists: &no, Authorized: &yes},
+		},
+		{
+			name:   "Not a VDS",
+			vds:    "../../testdata/10_samples/make_10_samples.py",
+			status: http.StatusUnprocessableEntity,
+			expected: api.ValidateResponse{
+				Exists:      &yes,
+				Authorized:  &yes,
+				VdsFormatOk: &no,
+			},
+		},
+	}
+
+	for _, testcase := range testcases {
+		for _, method := range []string{http.MethodGet, http.MethodPost} {
+			w := httptest.NewRecorder()
+			ctx, r := gin.CreateTestContext(w)
+			endpoint := api.Endpoint{
+				MakeVdsConnection: MakeFileConnection(),
+				Cache:             cache.NewNoCache(),
+			}
+			setupApp(r, &endpoint, nil, nil)
+
+			if method == http.MethodGet {
+				query := url.Values{"vds": {testcase.vds}, "sas": {"n/a"}}
+				ctx.Request, _ = http.NewRequest(
+					method,
+					"/validate?"+query.Encode(),
+					nil,
+				)
+			} else {
+				body, _ := json.Marshal(map[string]string{
+					"vds": testcase.vds,
+					"sas": "n/a",
+				})
+				ctx.Request, _ = http.NewRequest(
+					method,
+					"/v1/validate",
+					strings.NewReader(string(body)),
+				)
+				ctx.Request.Header.Set("Content-Type", "application/json")
+			}
+			r.ServeHTTP(w, ctx.Request)
+
+			require.Equalf(t, testcase.status, w.Result().StatusCode,
+				"[%s, %s] Body: %v", testcase.name, method, w.Body.String())
+
+			var response api.ValidateResponse
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			if testcase.status != http.StatusOK {
+				require.NotEmptyf(t, response.Error, "[%s]", testcase.name)
+				require.NotEmptyf(t, response.Code, "[%s]", testcase.name)
+			}
+			response.Error = ""
+			response.Code = ""
+			require.Equalf(t, testcase.expected, response,
+				"[%s, %s]", testcase.name, method)
+		}
+	}
+}
+
+func TestValidateIsRateLimited(t *testing.T) {
+	w := httptest.NewRecorder()
+	ctx, r := gin.CreateTestContext(w)
+	endpoint := api.Endpoint{
+		MakeVdsConnection: MakeFileConnection(),
+		Cache:             cache.NewNoCache(),
+		ValidateLimiter: ratelimit.NewGinMiddleware(
+			ratelimit.NewLimiter(1, 1),
+			"",
+			nil,
+		),
+	}
+	setupApp(r, &endpoint, nil, nil)
+
+	query := url.Values{"vds": {well_known}, "sas": {"n/a"}}
+	ctx.Request, _ = http.NewRequest(
+		http.MethodGet,
+		"/validate?"+query.Encode(),
+		nil,
+	)
+	r.ServeHTTP(w, ctx.Request)
+	require.Equal(t, http.StatusOK, w.Result().StatusCode)
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, ctx.Request)
+	require.Equal(t, http.StatusTooManyRequests, w.Result().StatusCode)
+	require.Equal(t, "1", w.Result().Header.Get("Retry-After"))
+
+	/* The other endpoints are not limited by it */
+	w = httptest.NewRecorder()
+	request, _ := http.NewRequest(
+		http.MethodGet,
+		"/metadata?"+query.Encode(),
+		nil,
+	)
+	r.ServeHTTP(w, request)
+	require.Equal(t, http.StatusOK, w.Result().StatusCode)
+}
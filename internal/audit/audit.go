@@ -0,0 +1,189 @@
+package audit
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+/** Who accessed which cube when, for the audit trail
+ *
+ * Never holds credentials or the payload of the request, e.g. the sas or the
+ * surfaces, only the hash the request is cached by.
+ */
+type Record struct {
+	Time time.Time `json:"time"`
+
+	// The IP of the client, with regard to the trusted proxies
+	Client string `json:"client"`
+
+	// The subject of the bearer token of the request, if any
+	Subject string `json:"subject,omitempty"`
+
+	// The url of the cube, without the query, which may hold the sas
+	Vds string `json:"vds"`
+
+	// The route of the request, e.g. /v1/slice
+	Endpoint string `json:"endpoint"`
+
+	// The hash of the request, as it is cached by
+	RequestHash string `json:"requestHash,omitempty"`
+
+	Status int   `json:"status"`
+	Bytes  int64 `json:"bytes"`
+}
+
+/** Where the audit records are written, e.g. a file or a webhook
+ *
+ * Write is only called by a single goroutine at a time, see Logger, so sinks
+ * need not be safe for concurrent use. Close is called once the last record
+ * is written.
+ */
+type Sink interface {
+	Write(record Record) error
+	Close() error
+}
+
+/* Writes every record to all of the sinks */
+type multiSink []Sink
+
+/** A sink that writes every record to all of the sinks
+ *
+ * A sink that fails does not keep the record from the others, and the errors
+ * of all the sinks are returned together.
+ */
+func MultiSink(sinks ...Sink) Sink {
+	if len(sinks) == 1 {
+		return sinks[0]
+	}
+	return multiSink(sinks)
+}
+
+func (m multiSink) Write(record Record) error {
+	return joinErrors(m, func(sink Sink) error { return sink.Write(record) })
+}
+
+func (m multiSink) Close() error {
+	return joinErrors(m, func(sink Sink) error { return sink.Close() })
+}
+
+func joinErrors(sinks []Sink, f func(sink Sink) error) error {
+	var failed []error
+	for _, sink := range sinks {
+		if err := f(sink); err != nil {
+			failed = append(failed, err)
+		}
+	}
+	switch len(failed) {
+	case 0:
+		return nil
+	case 1:
+		return failed[0]
+	default:
+		return fmt.Errorf("%v", failed)
+	}
+}
+
+/** Hands audit records over to a sink, off the path of the request
+ *
+ * Records are queued and written by a goroutine of the logger, such that a
+ * slow sink, e.g. a webhook that is retrying, does not hold up responses.
+ * Records that do not fit in the queue are dropped, and the number of them
+ * is reported with the next record that is written. Failures of the sink are
+ * reported to Errors, which defaults to stderr.
+ *
+ * A nil logger drops every record, such that auditing can be turned off.
+ */
+type Logger struct {
+	Errors io.Writer
+
+	sink    Sink
+	records chan Record
+	done    chan struct{}
+
+	lock    sync.Mutex
+	closed  bool
+	dropped int
+}
+
+/* The number of records that can wait for the sink */
+const queueSize = 1024
+
+func NewLogger(sink Sink) *Logger {
+	logger := &Logger{
+		Errors:  os.Stderr,
+		sink:    sink,
+		records: make(chan Record, queueSize),
+		done:    make(chan struct{}),
+	}
+	go logger.run()
+	return logger
+}
+
+/** Queue the record for the sink, without waiting for it to be written */
+func (l *Logger) Log(record Record) {
+	if l == nil {
+		return
+	}
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if l.closed {
+		return
+	}
+
+	select {
+	case l.records <- record:
+	default:
+		l.dropped++
+	}
+}
+
+/** Write the records that are queued, and close the sink
+ *
+ * Records logged after Close are dropped.
+ */
+func (l *Logger) Close() error {
+	if l == nil {
+		return nil
+	}
+
+	l.lock.Lock()
+	if !l.closed {
+		l.closed = true
+		close(l.records)
+	}
+	l.lock.Unlock()
+
+	<-l.done
+	return l.sink.Close()
+}
+
+func (l *Logger) run() {
+	defer close(l.done)
+	for record := range l.records {
+		l.lock.Lock()
+		dropped := l.dropped
+		l.dropped = 0
+		l.lock.Unlock()
+
+		if dropped > 0 {
+			fmt.Fprintf(l.Errors,
+				"[AUDIT] %d record(s) dropped, the sink could not keep up\n",
+				dropped,
+			)
+		}
+
+		if err := l.sink.Write(record); err != nil {
+			fmt.Fprintf(l.Errors,
+				"[AUDIT] failed to write record of %s %s at %s: %v\n",
+				record.Endpoint,
+				record.Vds,
+				record.Time.Format(time.RFC3339),
+				err,
+			)
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type recordingSink struct {
+	records []Record
+	closed  bool
+	err     error
+}
+
+func (s *recordingSink) Write(record Record) error {
+	s.records = append(s.records, record)
+	return s.err
+}
+
+func (s *recordingSink) Close() error {
+	s.closed = true
+	return nil
+}
+
+func testRecord(hash string) Record {
+	return Record{
+		Time:        time.Unix(1700000000, 0).UTC(),
+		Client:      "10.0.0.1",
+		Vds:         "https://account.blob.core.windows.net/container/cube",
+		Endpoint:    "/slice",
+		RequestHash: hash,
+		Status:      200,
+		Bytes:       42,
+	}
+}
+
+func TestLoggerFlushesOnClose(t *testing.T) {
+	sink := &recordingSink{}
+	logger := NewLogger(sink)
+	for _, hash := range []string{"a", "b", "c"} {
+		logger.Log(testRecord(hash))
+	}
+	require.NoError(t, logger.Close())
+
+	require.True(t, sink.closed)
+	require.Len(t, sink.records, 3)
+	for i, hash := range []string{"a", "b", "c"} {
+		require.Equal(t, hash, sink.records[i].RequestHash)
+	}
+
+	logger.Log(testRecord("d"))
+	require.Len(t, sink.records, 3, "records logged after close are dropped")
+}
+
+func TestLoggerReportsSinkErrors(t *testing.T) {
+	sink := &recordingSink{err: errors.New("disk full")}
+	logger := NewLogger(sink)
+	var errs bytes.Buffer
+	logger.Errors = &errs
+
+	logger.Log(testRecord("a"))
+	require.NoError(t, logger.Close())
+	require.Contains(t, errs.String(), "disk full")
+	require.Contains(t, errs.String(), "/slice")
+}
+
+func TestNilLogger(t *testing.T) {
+	var logger *Logger
+	logger.Log(testRecord("a"))
+	require.NoError(t, logger.Close())
+}
+
+func TestMultiSinkWritesToAll(t *testing.T) {
+	failing := &recordingSink{err: errors.New("unreachable")}
+	working := &recordingSink{}
+	sink := MultiSink(failing, working)
+
+	err := sink.Write(testRecord("a"))
+	require.ErrorContains(t, err, "unreachable")
+	require.Len(t, working.records, 1)
+
+	require.NoError(t, sink.Close())
+	require.True(t, failing.closed)
+	require.True(t, working.closed)
+}
+
+func readLines(t *testing.T, path string) []Record {
+	file, err := os.Open(path)
+	require.NoError(t, err)
+	defer file.Close()
+
+	var records []Record
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var record Record
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &record))
+		records = append(records, record)
+	}
+	require.NoError(t, scanner.Err())
+	return records
+}
+
+func TestFileSinkWritesJsonLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	sink, err := NewFileSink(path, 0, 0)
+	require.NoError(t, err)
+
+	require.NoError(t, sink.Write(testRecord("a")))
+	require.NoError(t, sink.Write(testRecord("b")))
+	require.NoError(t, sink.Close())
+
+	records := readLines(t, path)
+	require.Equal(t, []Record{testRecord("a"), testRecord("b")}, records)
+}
+
+func TestFileSinkRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	line, err := json.Marshal(testRecord("0"))
+	require.NoError(t, err)
+
+	/* Room for two records per file */
+	sink, err := NewFileSink(path, int64(2*(len(line)+1)), 2)
+	require.NoError(t, err)
+	var rotated []string
+	sink.OnRotate = func(path string) { rotated = append(rotated, path) }
+
+	for _, hash := range []string{"0", "1", "2", "3", "4", "5", "6", "7"} {
+		require.NoError(t, sink.Write(testRecord(hash)))
+	}
+	require.NoError(t, sink.Close())
+
+	hashes := func(path string) []string {
+		var hashes []string
+		for _, record := range readLines(t, path) {
+			hashes = append(hashes, record.RequestHash)
+		}
+		return hashes
+	}
+	require.Equal(t, []string{"6", "7"}, hashes(path))
+	require.Equal(t, []string{"4", "5"}, hashes(path+".1"))
+	require.Equal(t, []string{"2", "3"}, hashes(path+".2"))
+
+	_, err = os.Stat(path + ".3")
+	require.True(t, os.IsNotExist(err), "files beyond the retention are removed")
+
+	require.Equal(t, []string{path + ".1", path + ".1", path + ".1"}, rotated)
+}
+
+func TestFileSinkAppends(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	for _, hash := range []string{"a", "b"} {
+		sink, err := NewFileSink(path, 0, 0)
+		require.NoError(t, err)
+		require.NoError(t, sink.Write(testRecord(hash)))
+		require.NoError(t, sink.Close())
+	}
+	require.Len(t, readLines(t, path), 2)
+}
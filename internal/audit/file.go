@@ -0,0 +1,127 @@
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+/** Writes records to a file, one json object per line, with rotation
+ *
+ * Once the file would grow beyond MaxBytes it is rotated, i.e. renamed to
+ * <path>.1, pushing the files already rotated one step down, <path>.1 to
+ * <path>.2 and so on. Only the MaxFiles newest rotated files are kept, and
+ * older ones are removed, unless MaxFiles is zero, which keeps every file.
+ *
+ * OnRotate, if set, is called with the path of every file as it is rotated,
+ * e.g. to ship it to long term storage before the retention removes it. It is
+ * called on the goroutine of the logger, and holds up the records that come
+ * after it.
+ */
+type FileSink struct {
+	MaxBytes int64
+	MaxFiles int
+	OnRotate func(path string)
+
+	path string
+	file *os.File
+	size int64
+}
+
+/** A sink that appends to the file at path, creating it if needed */
+func NewFileSink(path string, maxBytes int64, maxFiles int) (*FileSink, error) {
+	sink := &FileSink{MaxBytes: maxBytes, MaxFiles: maxFiles, path: path}
+	if err := sink.open(); err != nil {
+		return nil, err
+	}
+	return sink, nil
+}
+
+func (f *FileSink) open() error {
+	file, err := os.OpenFile(
+		f.path,
+		os.O_CREATE|os.O_WRONLY|os.O_APPEND,
+		0o640,
+	)
+	if err != nil {
+		return err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	f.file = file
+	f.size = info.Size()
+	return nil
+}
+
+func (f *FileSink) rotated(n int) string {
+	return fmt.Sprintf("%s.%d", f.path, n)
+}
+
+func (f *FileSink) rotate() error {
+	if err := f.file.Close(); err != nil {
+		return err
+	}
+
+	/* Files beyond the retention are left for the removal below */
+	oldest := f.MaxFiles
+	if oldest == 0 {
+		for oldest = 1; ; oldest++ {
+			if _, err := os.Stat(f.rotated(oldest)); os.IsNotExist(err) {
+				break
+			}
+		}
+	}
+	for n := oldest - 1; n >= 1; n-- {
+		err := os.Rename(f.rotated(n), f.rotated(n+1))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	if f.MaxFiles > 0 {
+		for n := f.MaxFiles + 1; ; n++ {
+			err := os.Remove(f.rotated(n))
+			if os.IsNotExist(err) {
+				break
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := os.Rename(f.path, f.rotated(1)); err != nil {
+		return err
+	}
+	if f.OnRotate != nil {
+		f.OnRotate(f.rotated(1))
+	}
+	return f.open()
+}
+
+func (f *FileSink) Write(record Record) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	if f.MaxBytes > 0 && f.size > 0 && f.size+int64(len(line)) > f.MaxBytes {
+		if err := f.rotate(); err != nil {
+			return err
+		}
+	}
+
+	n, err := f.file.Write(line)
+	f.size += int64(n)
+	return err
+}
+
+func (f *FileSink) Close() error {
+	return f.file.Close()
+}
@@ -0,0 +1,78 @@
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+/** Posts every record, as json, to a webhook
+ *
+ * Records that fail to be delivered, due to network errors, 429 or 5xx
+ * responses, are retried up to Retries times, waiting Backoff before the
+ * first retry and doubling the wait for every retry after it. Any other
+ * response outside of 2xx is taken as a rejection of the record, which is not
+ * retried.
+ */
+type WebhookSink struct {
+	Client  *http.Client
+	Retries int
+	Backoff time.Duration
+
+	url string
+}
+
+func NewWebhookSink(url string, retries int) *WebhookSink {
+	return &WebhookSink{
+		Client:  &http.Client{Timeout: 10 * time.Second},
+		Retries: retries,
+		Backoff: 500 * time.Millisecond,
+		url:     url,
+	}
+}
+
+/* Whether a failed delivery is worth retrying */
+func retryable(status int) bool {
+	return status == http.StatusTooManyRequests || status >= 500
+}
+
+func (w *WebhookSink) post(body []byte) (bool, error) {
+	response, err := w.Client.Post(w.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return true, err
+	}
+	response.Body.Close()
+
+	if response.StatusCode >= 200 && response.StatusCode < 300 {
+		return false, nil
+	}
+	return retryable(response.StatusCode),
+		fmt.Errorf("webhook responded %s", response.Status)
+}
+
+func (w *WebhookSink) Write(record Record) error {
+	body, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	backoff := w.Backoff
+	for attempt := 0; ; attempt++ {
+		retry, err := w.post(body)
+		if err == nil {
+			return nil
+		}
+		if !retry || attempt >= w.Retries {
+			return fmt.Errorf("%w (after %d attempt(s))", err, attempt+1)
+		}
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+func (w *WebhookSink) Close() error {
+	w.Client.CloseIdleConnections()
+	return nil
+}
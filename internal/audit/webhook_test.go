@@ -0,0 +1,108 @@
+package audit
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+/* A webhook that responds with failures before it starts accepting records */
+type flakyServer struct {
+	lock     sync.Mutex
+	failures []int
+	attempts int
+	records  []Record
+}
+
+func (s *flakyServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.attempts++
+	if len(s.failures) > 0 {
+		status := s.failures[0]
+		s.failures = s.failures[1:]
+		w.WriteHeader(status)
+		return
+	}
+
+	var record Record
+	if err := json.NewDecoder(r.Body).Decode(&record); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	s.records = append(s.records, record)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func newFlakySink(t *testing.T, retries int, failures ...int) (*WebhookSink, *flakyServer) {
+	flaky := &flakyServer{failures: failures}
+	server := httptest.NewServer(flaky)
+	t.Cleanup(server.Close)
+
+	sink := NewWebhookSink(server.URL, retries)
+	sink.Backoff = time.Millisecond
+	return sink, flaky
+}
+
+func TestWebhookRetriesTransientFailures(t *testing.T) {
+	sink, server := newFlakySink(t, 3,
+		http.StatusServiceUnavailable,
+		http.StatusTooManyRequests,
+		http.StatusInternalServerError,
+	)
+
+	require.NoError(t, sink.Write(testRecord("a")))
+	require.Equal(t, 4, server.attempts)
+	require.Equal(t, []Record{testRecord("a")}, server.records)
+}
+
+func TestWebhookGivesUpAfterRetries(t *testing.T) {
+	sink, server := newFlakySink(t, 2,
+		http.StatusBadGateway,
+		http.StatusBadGateway,
+		http.StatusBadGateway,
+	)
+
+	err := sink.Write(testRecord("a"))
+	require.ErrorContains(t, err, "502")
+	require.ErrorContains(t, err, "after 3 attempt(s)")
+	require.Equal(t, 3, server.attempts)
+	require.Empty(t, server.records)
+}
+
+func TestWebhookDoesNotRetryRejections(t *testing.T) {
+	sink, server := newFlakySink(t, 3, http.StatusBadRequest)
+
+	err := sink.Write(testRecord("a"))
+	require.ErrorContains(t, err, "400")
+	require.Equal(t, 1, server.attempts)
+}
+
+func TestWebhookRetriesUnreachable(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	url := server.URL
+	server.Close()
+
+	sink := NewWebhookSink(url, 1)
+	sink.Backoff = time.Millisecond
+	err := sink.Write(testRecord("a"))
+	require.ErrorContains(t, err, "after 2 attempt(s)")
+}
+
+func TestWebhookThroughLogger(t *testing.T) {
+	sink, server := newFlakySink(t, 1, http.StatusServiceUnavailable)
+
+	logger := NewLogger(sink)
+	logger.Log(testRecord("a"))
+	logger.Log(testRecord("b"))
+	require.NoError(t, logger.Close())
+
+	require.Equal(t, []Record{testRecord("a"), testRecord("b")}, server.records)
+	require.Equal(t, 3, server.attempts)
+}
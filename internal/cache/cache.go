@@ -11,8 +11,9 @@ import (
 )
 
 type CacheEntry struct {
-	data     [][]byte
-	metadata []byte
+	data      [][]byte
+	metadata  []byte
+	checksums []uint32
 }
 
 func (c *CacheEntry) Data() [][]byte {
@@ -23,16 +24,22 @@ func (c *CacheEntry) Metadata() []byte {
 	return c.metadata
 }
 
+/* Checksums of the data parts, such that cache hits need not recompute them */
+func (c *CacheEntry) Checksums() []uint32 {
+	return c.checksums
+}
+
 func (c *CacheEntry) Size() int {
 	var dataLength int
 	for _, val := range c.data {
 		dataLength += len(val)
 	}
-	return dataLength + len(c.metadata) + int(unsafe.Sizeof(*c))
+	checksumsLength := len(c.checksums) * int(unsafe.Sizeof(uint32(0)))
+	return dataLength + len(c.metadata) + checksumsLength + int(unsafe.Sizeof(*c))
 }
 
-func NewCacheEntry(data [][]byte, metadata []byte) CacheEntry {
-	return CacheEntry{ data: data, metadata: metadata }
+func NewCacheEntry(data [][]byte, metadata []byte, checksums []uint32) CacheEntry {
+	return CacheEntry{ data: data, metadata: metadata, checksums: checksums }
 }
 
 type Cache interface {
@@ -18,17 +18,18 @@ func TestRistrettoCacheMaxSize(t *testing.T) {
 	/** CacheEntry with a memory footprint of exactly 1 KB
 	 *
 	 * The true size (in memory) is given by the size of the struct itself,
-	 * which for cacheEntry is 48 bytes plus the size of the two buffers. I.e:
+	 * which for cacheEntry is 72 bytes plus the size of the buffers. I.e:
 	 *
-	 * unsafe.Sizeof(entry) + len(entry.Data) + len(entry.Metadata) =
-	 * 48                   + 512             + 464                 = 1024
+	 * unsafe.Sizeof(entry) + len(entry.Data) + len(entry.Metadata) + checksums =
+	 * 72                   + 512             + 424                 + 16        = 1024
 	 */
 	data := make([][]byte, 4)
 	for i := range data {
 		data[i] = make([]byte, 128)
 	}
-	metadata := make([]byte, 464)
-	entry := NewCacheEntry(data, metadata)
+	metadata := make([]byte, 424)
+	checksums := make([]uint32, 4)
+	entry := NewCacheEntry(data, metadata, checksums)
 
 	cacheSize := 1 * 1024 * 1024 // 1 MB
 	maxEntries := cacheSize / 1024
@@ -0,0 +1,54 @@
+package clientip
+
+import (
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+/* Key of the resolved client IP among the keys of the gin context */
+const Key = "client-ip"
+
+/** Parse a comma separated list of trusted proxies
+ *
+ * Entries are IPs or CIDR ranges, as taken by gin's SetTrustedProxies. An
+ * empty list gives nil, i.e. no proxy is trusted.
+ */
+func ParseTrustedProxies(value string) []string {
+	var proxies []string
+	for _, proxy := range strings.Split(value, ",") {
+		proxy = strings.TrimSpace(proxy)
+		if proxy != "" {
+			proxies = append(proxies, proxy)
+		}
+	}
+	return proxies
+}
+
+/** New gin middleware that resolves the IP of the client once per request
+ *
+ * X-Forwarded-For and X-Real-IP are only honored if the request comes from
+ * one of the trusted proxies of the engine, see gin's SetTrustedProxies.
+ * Otherwise the IP is that of the socket peer, such that clients can't spoof
+ * their IP to dodge rate limits or to hide in the logs.
+ *
+ * The IP is stored under Key, for the logger and the rate limiter to share.
+ */
+func NewGinMiddleware() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		ctx.Set(Key, ctx.ClientIP())
+		ctx.Next()
+	}
+}
+
+/** The client IP of the request
+ *
+ * As resolved by the middleware, or by gin directly if the middleware is not
+ * in use.
+ */
+func Get(ctx *gin.Context) string {
+	if ip := ctx.GetString(Key); ip != "" {
+		return ip
+	}
+	return ctx.ClientIP()
+}
@@ -0,0 +1,116 @@
+package clientip
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTrustedProxies(t *testing.T) {
+	require.Nil(t, ParseTrustedProxies(""))
+	require.Equal(t,
+		[]string{"10.0.0.0/8", "192.168.1.1"},
+		ParseTrustedProxies(" 10.0.0.0/8, ,192.168.1.1 "),
+	)
+}
+
+func resolve(
+	t *testing.T,
+	trustedProxies []string,
+	peer string,
+	headers map[string]string,
+) string {
+	gin.SetMode(gin.TestMode)
+	app := gin.New()
+	err := app.SetTrustedProxies(trustedProxies)
+	require.NoError(t, err)
+
+	var resolved string
+	app.Use(NewGinMiddleware())
+	app.GET("/", func(ctx *gin.Context) {
+		resolved = Get(ctx)
+	})
+
+	request := httptest.NewRequest(http.MethodGet, "/", nil)
+	request.RemoteAddr = peer + ":12345"
+	for header, value := range headers {
+		request.Header.Set(header, value)
+	}
+	app.ServeHTTP(httptest.NewRecorder(), request)
+	return resolved
+}
+
+func TestClientIP(t *testing.T) {
+	proxies := []string{"10.0.0.0/8"}
+
+	testcases := []struct {
+		name     string
+		proxies  []string
+		peer     string
+		headers  map[string]string
+		expected string
+	}{
+		{
+			name:     "No proxy trusted, no header",
+			peer:     "203.0.113.7",
+			expected: "203.0.113.7",
+		},
+		{
+			name:     "No proxy trusted, spoofed X-Forwarded-For",
+			peer:     "203.0.113.7",
+			headers:  map[string]string{"X-Forwarded-For": "198.51.100.1"},
+			expected: "203.0.113.7",
+		},
+		{
+			name:     "Untrusted peer, spoofed X-Forwarded-For",
+			proxies:  proxies,
+			peer:     "203.0.113.7",
+			headers:  map[string]string{"X-Forwarded-For": "198.51.100.1"},
+			expected: "203.0.113.7",
+		},
+		{
+			name:     "Untrusted peer, spoofed X-Real-IP",
+			proxies:  proxies,
+			peer:     "203.0.113.7",
+			headers:  map[string]string{"X-Real-IP": "198.51.100.1"},
+			expected: "203.0.113.7",
+		},
+		{
+			name:     "Trusted peer, X-Forwarded-For",
+			proxies:  proxies,
+			peer:     "10.1.2.3",
+			headers:  map[string]string{"X-Forwarded-For": "198.51.100.1"},
+			expected: "198.51.100.1",
+		},
+		{
+			name:     "Trusted peer, X-Real-IP",
+			proxies:  proxies,
+			peer:     "10.1.2.3",
+			headers:  map[string]string{"X-Real-IP": "198.51.100.1"},
+			expected: "198.51.100.1",
+		},
+		{
+			name:    "Trusted peer, client prepends a spoofed IP",
+			proxies: proxies,
+			peer:    "10.1.2.3",
+			headers: map[string]string{
+				"X-Forwarded-For": "192.0.2.99, 198.51.100.1, 10.4.5.6",
+			},
+			expected: "198.51.100.1",
+		},
+		{
+			name:     "Trusted peer, no header",
+			proxies:  proxies,
+			peer:     "10.1.2.3",
+			expected: "10.1.2.3",
+		},
+	}
+
+	for _, testcase := range testcases {
+		actual := resolve(t, testcase.proxies, testcase.peer, testcase.headers)
+		require.Equal(t, testcase.expected, actual, testcase.name)
+	}
+}
@@ -2,6 +2,7 @@
 #include <cmath>
 #include <cstring>
 #include <functional>
+#include <iterator>
 #include <numeric>
 #include <memory>
 #include <stdexcept>
@@ -10,6 +11,97 @@
 #include "attribute.hpp"
 #include "regularsurface.hpp"
 
+/*
+ * Absent data is read as NaN. Attributes are computed from the valid samples
+ * only, and calc_attributes makes sure there is at least one valid sample in
+ * every segment passed on to compute.
+ */
+namespace {
+
+bool is_valid(double x) noexcept {
+    return not std::isnan(x);
+}
+
+/*
+ * First valid sample for which compare(sample, others) holds against all
+ * other valid samples, e.g. the first minimum for std::less.
+ */
+template< typename Compare >
+std::vector<double>::const_iterator find_valid(
+    ResampledSegment const & segment,
+    Compare compare
+) {
+    auto found = segment.end();
+    for (auto it = segment.begin(); it != segment.end(); ++it) {
+        if (not is_valid(*it)) continue;
+        if (found == segment.end() or compare(*it, *found)) {
+            found = it;
+        }
+    }
+    return found;
+}
+
+/*
+ * Sum of f(x) and number of samples over the valid samples in the segment
+ */
+template< typename Function >
+double sum_valid(
+    ResampledSegment const & segment,
+    std::size_t& count,
+    Function f
+) {
+    count = 0;
+    return std::accumulate(segment.begin(), segment.end(), 0.0,
+        [&](double acc, double x) {
+            if (not is_valid(x)) return acc;
+            count ++;
+            return acc + f(x);
+        }
+    );
+}
+
+double identity(double x) { return x; }
+
+/*
+ * The valid samples of the segment in values, which keeps its capacity so
+ * that it can be reused between segments
+ */
+void copy_valid(
+    ResampledSegment const & segment,
+    std::vector< double >& values
+) {
+    values.clear();
+    std::copy_if(
+        segment.begin(),
+        segment.end(),
+        std::back_inserter(values),
+        is_valid
+    );
+}
+
+/*
+ * The std::nth_element function sets the middle element of a vector in such a
+ * manner that all values on the right side of the middle element are greater
+ * than or equal to it, and all elements preceding the middle are less than or
+ * equal to the middle. With this approach, we don't need to sort the entire
+ * vector. In the case of even number of elements in the vector, we use
+ * std::max_element to obtain the largest element before the middle element to
+ * compute the average. The order of values is not kept.
+ */
+double median(std::vector< double >& values) {
+    const auto middle_right = values.begin() + values.size() / 2;
+    std::nth_element(values.begin(), middle_right, values.end());
+    if (values.size() % 2 == 0) {
+        const auto max_left = std::max_element(values.begin(), middle_right);
+        return (*max_left + *middle_right) / 2;
+    }
+    else {
+        return *middle_right;
+    }
+}
+
+} // namespace
+
 float Value::compute(
     ResampledSegment const & segment
 ) noexcept (false) {
@@ -21,14 +113,14 @@ float Value::compute(
 float Min::compute(
     ResampledSegment const & segment
 ) noexcept (false) {
-    return *std::min_element(segment.begin(), segment.end());
+    return *find_valid(segment, std::less< double >());
 }
 
 float MinAt::compute(
     ResampledSegment const & segment) noexcept(false) {
     auto min_index = std::distance(
             segment.begin(),
-            std::min_element(segment.begin(), segment.end())
+            find_valid(segment, std::less< double >())
         );
     return segment.sample_position_at(min_index);
 }
@@ -36,7 +128,7 @@ float MinAt::compute(
 float Max::compute(
     ResampledSegment const & segment
 ) noexcept (false) {
-    return *std::max_element(segment.begin(), segment.end());
+    return *find_valid(segment, std::greater< double >());
 }
 
 float MaxAt::compute(
@@ -44,22 +136,21 @@ float MaxAt::compute(
 ) noexcept (false) {
     auto max_index = std::distance(
         segment.begin(),
-        std::max_element(segment.begin(), segment.end())
+        find_valid(segment, std::greater< double >())
     );
     return segment.sample_position_at(max_index);
 }
 
 namespace {
 
-double max_abs(
+std::vector<double>::const_iterator max_abs(
     ResampledSegment const & segment
 ){
-    auto max = *std::max_element(segment.begin(), segment.end(),
-    [](const double& a, const double& b) { 
-            return std::abs(a) < std::abs(b); 
+    return find_valid(segment,
+        [](const double& a, const double& b) {
+            return std::abs(a) > std::abs(b);
         }
     );
-    return std::abs(max);
 }
 
 } // namespace
@@ -67,39 +158,37 @@ double max_abs(
 float MaxAbs::compute(
     ResampledSegment const & segment
 ) noexcept (false) {
-    return max_abs(segment);
+    return std::abs(*max_abs(segment));
 }
 
-float MaxAbsAt::compute(
+float MaxAbsSigned::compute(
     ResampledSegment const & segment
 ) noexcept (false) {
+    return *max_abs(segment);
+}
 
-    auto max_abs_val = max_abs(segment);
-    auto max_abs_index = std::distance(
-        segment.begin(),
-        std::find_if(segment.begin(), segment.end(),
-        [&](const double& val) {
-                return std::abs(val) == max_abs_val;
-            }
-        )
-    );
-
+float MaxAbsAt::compute(
+    ResampledSegment const & segment
+) noexcept (false) {
+    auto max_abs_index = std::distance(segment.begin(), max_abs(segment));
     return segment.sample_position_at(max_abs_index);
 }
 
 float Mean::compute(
     ResampledSegment const & segment
 ) noexcept (false) {
-    double sum = std::accumulate(segment.begin(), segment.end(), 0.0);
-    return sum / segment.size();
+    std::size_t count;
+    double sum = sum_valid(segment, count, identity);
+    return sum / count;
 }
 
 float MeanAbs::compute(
     ResampledSegment const & segment
 ) noexcept (false) {
-    double sum = std::accumulate(segment.begin(), segment.end(), 0.0,
-        [](double acc, double x) { return acc + std::abs(x); });
-    return sum / segment.size();
+    std::size_t count;
+    double sum = sum_valid(segment, count,
+        [](double x) { return std::abs(x); });
+    return sum / count;
 }
 
 float MeanPos::compute(
@@ -137,36 +226,28 @@ float MeanNeg::compute(
 float Median::compute(
     ResampledSegment const & segment
 ) noexcept (false) {
-    /*
-    The std::nth_element function sets the middle element of a vector in such a
-    manner that all values on the right side of the middle element are greater
-    than or equal to it, and all elements preceding the middle are less than or
-    equal to the middle. With this approach, we don't need to sort the entire
-    vector. In the case of even number of elements in the vector, we use
-    std::max_element to obtain the largest element before the middle element to
-    compute the average.
-    */
-    auto temp = std::vector<double>(segment.begin(), segment.end());
-    const auto middle_right = temp.begin() + segment.size() / 2;
-    std::nth_element(temp.begin(), middle_right, temp.end());
-    if (segment.size() % 2 == 0) {
-        const auto max_left = std::max_element(temp.begin(), middle_right);
-        return (*max_left + *middle_right) / 2;
-    }
-    else {
-        return *middle_right;
+    copy_valid(segment, this->scratch);
+    return median(this->scratch);
+}
+
+float Mad::compute(
+    ResampledSegment const & segment
+) noexcept (false) {
+    copy_valid(segment, this->scratch);
+    const double center = median(this->scratch);
+    for (auto& x : this->scratch) {
+        x = std::abs(x - center);
     }
+    return median(this->scratch);
 }
 
 float Rms::compute(
     ResampledSegment const & segment
 ) noexcept (false) {
-    float sum = std::accumulate(segment.begin(), segment.end(), 0.0,
-        [](double a, double b) {
-            return a + std::pow(b, 2);
-        }
-    );
-    return std::sqrt(sum / segment.size());
+    std::size_t count;
+    float sum = sum_valid(segment, count,
+        [](double x) { return std::pow(x, 2); });
+    return std::sqrt(sum / count);
 }
 
 namespace {
@@ -174,12 +255,13 @@ namespace {
 double variance(
     ResampledSegment const & segment
 ){
-    double sum = std::accumulate(segment.begin(), segment.end(), 0.0);
-    double mean = sum / segment.size();
-    double stdSum = std::accumulate(segment.begin(), segment.end(), 0.0,
-        [&](double a, double b){ return a + std::pow(b - mean, 2); }
+    std::size_t count;
+    double sum = sum_valid(segment, count, identity);
+    double mean = sum / count;
+    double stdSum = sum_valid(segment, count,
+        [&](double x){ return std::pow(x - mean, 2); }
     );
-    return stdSum / segment.size();
+    return stdSum / count;
 }
 
 } // namespace
@@ -196,6 +278,13 @@ float Sd::compute(
     return std::sqrt(variance(segment));
 }
 
+float Sum::compute(
+    ResampledSegment const & segment
+) noexcept (false) {
+    std::size_t count;
+    return sum_valid(segment, count, identity);
+}
+
 float SumPos::compute(
     ResampledSegment const & segment
 ) noexcept (false) {
@@ -220,29 +309,61 @@ void calc_attributes(
     SurfaceBoundedSubVolume const& src_subvolume,
     ResampledSegmentBlueprint const* dst_segment_blueprint,
     std::vector< std::unique_ptr< AttributeMap > >& attrs,
+    enum interpolation_method vertical_interpolation,
+    float min_valid_fraction,
     std::size_t from,
-    std::size_t to
+    std::size_t to,
+    attribute_coverage* coverage
 ) noexcept (false) {
     auto fill = src_subvolume.fillvalue();
 
     RawSegment src_segment = src_subvolume.vertical_segment(from);
     ResampledSegment dst_segment =  ResampledSegment(0, 0, 0, dst_segment_blueprint);
 
+    /* Fill the node for all attributes, and count it by why it is filled */
+    auto write_fill = [&](std::size_t i, std::size_t attribute_coverage::* reason) {
+        for (std::size_t j = 0; j < attrs.size(); ++j) {
+            attrs[j]->write(fill, i);
+            ++(coverage[j].*reason);
+        }
+    };
+
     for (std::size_t i = from; i < to; ++i) {
         if (src_subvolume.is_empty(i)) {
-            for (auto& attr : attrs) {
-                attr->write(fill, i);
-            }
+            write_fill(i, src_subvolume.is_surface_missing(i)
+                ? &attribute_coverage::missing_surface
+                : &attribute_coverage::outside_survey
+            );
             continue;
         }
 
         src_subvolume.reinitialize(i, src_segment);
         src_subvolume.reinitialize(i, dst_segment);
-        resample(src_segment, dst_segment);
+        resample(src_segment, dst_segment, vertical_interpolation);
 
-        for (auto& attr : attrs) {
-            auto value = attr->compute(dst_segment);
-            attr->write(value, i);
+        /*
+         * Resampling spreads absent data to the neighbouring resampled
+         * samples, so validity is judged after resampling
+         */
+        std::size_t const nvalid =
+            std::count_if(dst_segment.begin(), dst_segment.end(), is_valid);
+        if (nvalid == 0) {
+            write_fill(i, &attribute_coverage::dead_traces);
+            continue;
+        }
+        if (nvalid < min_valid_fraction * dst_segment.size()) {
+            write_fill(i, &attribute_coverage::below_min_valid_fraction);
+            continue;
+        }
+
+        for (std::size_t j = 0; j < attrs.size(); ++j) {
+            auto value = attrs[j]->compute(dst_segment);
+            /* E.g. samplevalue when the reference sample itself is absent */
+            if (std::isnan(value)) {
+                value = fill;
+                ++coverage[j].undefined;
+            }
+            attrs[j]->write(value, i);
         }
     }
 }
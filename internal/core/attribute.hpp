@@ -5,6 +5,7 @@
 #include "subvolume.hpp"
 #include <memory>
 #include <stdexcept>
+#include <vector>
 
 /* Base class for attribute calculations
  *
@@ -76,6 +77,16 @@ public:
     float compute(ResampledSegment const & segment) noexcept (false) override;
 };
 
+/* The sample of largest magnitude, with its sign. Of samples of the same
+ * magnitude, the first in the window is returned.
+ */
+class MaxAbsSigned final : public AttributeMap {
+public:
+    MaxAbsSigned(void* dst, std::size_t size) : AttributeMap(dst, size) {}
+
+    float compute(ResampledSegment const & segment) noexcept (false) override;
+};
+
 class MaxAbsAt final : public AttributeMap {
 public:
     MaxAbsAt(void* dst, std::size_t size) : AttributeMap(dst, size) {}
@@ -111,11 +122,32 @@ public:
     float compute(ResampledSegment const & segment) noexcept (false) override;
 };
 
+/* Median of the valid samples
+ *
+ * The samples are selected in a scratch buffer that is kept between nodes, as
+ * the map is used by a single worker, so that no allocation is done per node.
+ */
 class Median final : public AttributeMap {
 public:
     Median(void* dst, std::size_t size) : AttributeMap(dst, size) {}
 
     float compute(ResampledSegment const & segment) noexcept (false) override;
+
+private:
+    std::vector< double > scratch;
+};
+
+/* Median absolute deviation, the median of the absolute deviations from the
+ * median. It is not scaled to be an estimator of the standard deviation.
+ */
+class Mad final : public AttributeMap {
+public:
+    Mad(void* dst, std::size_t size) : AttributeMap(dst, size) {}
+
+    float compute(ResampledSegment const & segment) noexcept (false) override;
+
+private:
+    std::vector< double > scratch;
 };
 
 class Rms final : public AttributeMap {
@@ -145,6 +177,13 @@ public:
     float compute(ResampledSegment const & segment) noexcept (false) override;
 };
 
+class Sum final : public AttributeMap {
+public:
+    Sum(void* dst, std::size_t size) : AttributeMap(dst, size) {}
+
+    float compute(ResampledSegment const & segment) noexcept (false) override;
+};
+
 class SumPos final : public AttributeMap {
 public:
     SumPos(void* dst, std::size_t size) : AttributeMap(dst, size) {}
@@ -163,8 +202,11 @@ void calc_attributes(
     SurfaceBoundedSubVolume const& src_subvolume,
     ResampledSegmentBlueprint const* dst_segment_blueprint,
     std::vector< std::unique_ptr< AttributeMap > >& attrs,
+    enum interpolation_method vertical_interpolation,
+    float min_valid_fraction,
     std::size_t from,
-    std::size_t to
+    std::size_t to,
+    attribute_coverage* coverage
 ) noexcept (false);
 
 #endif /* VDS_SLICE_ATTRIBUTE_HPP */
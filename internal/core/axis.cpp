@@ -1,5 +1,6 @@
 #include "axis.hpp"
 
+#include <cmath>
 #include <stdexcept>
 #include "exceptions.hpp"
 
@@ -15,6 +16,13 @@ Axis::Axis(
     m_axis_descriptor(layout->GetAxisDescriptor(dimension))
 {}
 
+Axis::Axis(
+    int const                                 dimension,
+    OpenVDS::VolumeDataAxisDescriptor const& descriptor
+) : m_dimension(dimension),
+    m_axis_descriptor(descriptor)
+{}
+
 float Axis::min() const noexcept(true) {
     return this->m_axis_descriptor.GetCoordinateMin();
 }
@@ -39,39 +47,67 @@ float Axis::stepsize() const noexcept (true) {
     return (this->max() - this->min()) / (this->nsamples() - 1);
 }
 
+/*
+ * OpenVDS describes an axis by its min, max and number of samples only, so
+ * the annotations are evenly spaced whenever the stepsize is defined at all.
+ * An axis of a single sample has no stepsize, and an axis where min equals
+ * max has no spacing, and neither can be numbered by stepsize.
+ */
+bool Axis::regular() const noexcept (true) {
+    if (this->nsamples() < 2) return false;
+
+    float const stepsize = this->stepsize();
+    return std::isfinite(stepsize) and stepsize != 0;
+}
+
 std::string Axis::name() const noexcept(true) {
     return this->m_axis_descriptor.GetName();
 }
 
+/*
+ * An axis of a single sample has no stepsize, so its only line is taken to
+ * be one unit wide.
+ */
 bool Axis::inrange(float coordinate) const noexcept(true) {
-    return (this->min() - 0.5 * this->stepsize()) <= coordinate &&
-           (this->max() + 0.5 * this->stepsize()) >  coordinate;
+    float const halfstep = this->nsamples() == 1 ? 0.5 : 0.5 * this->stepsize();
+    return (this->min() - halfstep) <= coordinate &&
+           (this->max() + halfstep) >  coordinate;
 }
 
 float Axis::to_sample_position(float coordinate) noexcept(false) {
+    if (this->nsamples() == 1) return coordinate - this->min();
     return this->m_axis_descriptor.CoordinateToSamplePosition(coordinate);
 }
 
-void Axis::assert_equal(Axis const& other) noexcept(false) {
-
-    if (this->nsamples() != other.nsamples()) {
-        throw detail::bad_request(
+/*
+ * Axes are compared in the order of the checks of assert_equal, which the
+ * dual-cube requests rely on. The compatibility check of two cubes reports
+ * the full comparison.
+ */
+AxisComparison Axis::compare(Axis const& other) const noexcept(false) {
+    AxisComparison comparison;
+
+    comparison.nsamples = this->nsamples() == other.nsamples();
+    if (not comparison.nsamples) {
+        comparison.mismatches.push_back(
             "Axis: " + this->name() +
             ": Mismatch in number of samples: " +
             utils::to_string_with_precision(this->nsamples()) +
             " != " + utils::to_string_with_precision(other.nsamples()));
     }
 
-    if (this->min() != other.min()) {
-        throw detail::bad_request(
+    comparison.min = this->min() == other.min();
+    if (not comparison.min) {
+        comparison.mismatches.push_back(
             "Axis: " + this->name() +
             ": Mismatch in min value: " +
             utils::to_string_with_precision(this->min()) +
             " != " + utils::to_string_with_precision(other.min()));
     }
 
-    if (this->max() != other.max()) {
-        throw detail::bad_request(
+    comparison.max = this->max() == other.max();
+    if (not comparison.max) {
+        comparison.mismatches.push_back(
             "Axis: " + this->name() +
             ": Mismatch in max value: " +
             utils::to_string_with_precision(this->max()) +
@@ -80,16 +116,18 @@ void Axis::assert_equal(Axis const& other) noexcept(false) {
 
     // Stepsize is a data integrity check.
     // If min,max and nsamples are equal stepsize is equal for consistent data.
-    if (this->stepsize() != other.stepsize()) {
-        throw detail::bad_request(
+    comparison.stepsize = this->stepsize() == other.stepsize();
+    if (not comparison.stepsize) {
+        comparison.mismatches.push_back(
             "Axis: " + this->name() +
             ": Mismatch in stepsize: " +
             std::to_string(this->stepsize()) +
             " != " + std::to_string(other.stepsize()));
     }
 
-    if (this->unit() != other.unit()) {
-        throw detail::bad_request(
+    comparison.unit = this->unit() == other.unit();
+    if (not comparison.unit) {
+        comparison.mismatches.push_back(
             "Axis: " + this->name() +
             ": Mismatch in unit: " +
             this->unit() +
@@ -98,11 +136,25 @@ void Axis::assert_equal(Axis const& other) noexcept(false) {
 
     // Ignore order of dimensions
 
-    if (this->name() != other.name()) {
-        throw detail::bad_request(
+    comparison.name = this->name() == other.name();
+    if (not comparison.name) {
+        comparison.mismatches.push_back(
             "Axis: " + this->name() +
             ": Mismatch in name: " +
             this->name() +
             " != " + other.name());
     }
+
+    return comparison;
+}
+
+void Axis::assert_equal(Axis const& other) noexcept(false) {
+    auto const comparison = this->compare(other);
+    if (not comparison.mismatches.empty()) {
+        throw detail::bad_request(
+            "incompatible_vds",
+            {},
+            comparison.mismatches.front()
+        );
+    }
 }
@@ -3,9 +3,26 @@
 
 #include <memory>
 #include <string>
+#include <vector>
 
 #include <OpenVDS/OpenVDS.h>
 
+/** Field by field comparison of two axes
+ *
+ * Every flag is true if the field matches. The mismatches are described in
+ * the order they are checked.
+ */
+struct AxisComparison {
+    bool nsamples;
+    bool min;
+    bool max;
+    bool stepsize;
+    bool unit;
+    bool name;
+
+    std::vector< std::string > mismatches;
+};
+
 class Axis {
 public:
     Axis(
@@ -13,12 +30,19 @@ public:
         int const dimension
     );
 
+    /* An axis that is not read from the layout, e.g. the inline of a 2D line */
+    Axis(
+        int const                                 dimension,
+        OpenVDS::VolumeDataAxisDescriptor const& descriptor
+    );
+
     int nsamples() const noexcept(true);
 
     float min() const noexcept(true);
     float max() const noexcept(true);
 
     float stepsize() const noexcept (true);
+    bool regular() const noexcept (true);
 
     std::string unit() const noexcept(true);
     int dimension() const noexcept(true);
@@ -28,6 +52,7 @@ public:
     bool inrange(float coordinate) const noexcept(true);
     float to_sample_position(float coordinate) noexcept(false);
 
+    AxisComparison compare(Axis const& other) const noexcept(false);
     void assert_equal(Axis const& other) noexcept(false);
 
 private:
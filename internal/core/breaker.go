@@ -0,0 +1,200 @@
+package core
+
+import (
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+type CircuitState int
+
+const (
+	CircuitClosed CircuitState = iota
+	CircuitOpen
+	CircuitHalfOpen
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	default:
+		return "closed"
+	}
+}
+
+/** Receives notifications about circuit state transitions, e.g. for metrics
+ *
+ * The state is passed as a plain int (see CircuitState) such that observers
+ * need not depend on this package.
+ */
+type CircuitObserver interface {
+	CircuitStateChanged(host string, state int)
+}
+
+type circuit struct {
+	state    CircuitState
+	failures int
+	openedAt time.Time
+}
+
+/** Circuit breaker per storage host
+ *
+ * After Threshold consecutive transient failures against a host the circuit
+ * for that host opens, and requests fail fast with an UnavailableError rather
+ * than tying up workers against a host that is throttling or down. Once
+ * Cooldown has passed a single request is let through as a probe. If it
+ * succeeds the circuit closes again, otherwise it re-opens for another
+ * Cooldown.
+ *
+ * A nil breaker, or a Threshold of zero, lets everything through.
+ */
+type CircuitBreaker struct {
+	Threshold int
+	Cooldown  time.Duration
+	Observer  CircuitObserver
+
+	lock     sync.Mutex
+	circuits map[string]*circuit
+}
+
+func NewCircuitBreaker(
+	threshold int,
+	cooldown  time.Duration,
+	observer  CircuitObserver,
+) *CircuitBreaker {
+	return &CircuitBreaker{
+		Threshold: threshold,
+		Cooldown:  cooldown,
+		Observer:  observer,
+		circuits:  make(map[string]*circuit),
+	}
+}
+
+/*
+ * The storage host of a requested blob, i.e. the storage account for Azure
+ * and the bucket for S3 and GCS. Local files share a single (empty) key.
+ */
+func StorageHost(blob string) string {
+	blobUrl, err := url.Parse(blob)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(blobUrl.Hostname())
+}
+
+func (b *CircuitBreaker) enabled() bool {
+	return b != nil && b.Threshold > 0
+}
+
+/* Must be called with the lock held */
+func (b *CircuitBreaker) transition(host string, c *circuit, state CircuitState) {
+	if c.state == state {
+		return
+	}
+
+	log.Printf("Circuit for storage host %s: %v -> %v", host, c.state, state)
+	c.state = state
+	if b.Observer != nil {
+		b.Observer.CircuitStateChanged(host, int(state))
+	}
+}
+
+/** Check if a request against host may proceed
+ *
+ * Returns an UnavailableError if the circuit is open, or if it's half-open
+ * and the probe is already underway.
+ */
+func (b *CircuitBreaker) allow(host string) error {
+	if !b.enabled() {
+		return nil
+	}
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	c, ok := b.circuits[host]
+	if !ok || c.state == CircuitClosed {
+		return nil
+	}
+
+	remaining := b.Cooldown - time.Since(c.openedAt)
+	if c.state == CircuitOpen && remaining <= 0 {
+		b.transition(host, c, CircuitHalfOpen)
+		return nil
+	}
+
+	if remaining < time.Second {
+		remaining = time.Second
+	}
+	return NewUnavailableError(
+		fmt.Sprintf(
+			"storage host %s is currently unavailable, retry after %v",
+			host,
+			remaining.Round(time.Second),
+		),
+		remaining,
+	)
+}
+
+/** Record the outcome of a request against host
+ *
+ * Only transient errors count as failures. Anything else, e.g. a VDS that
+ * does not exist, says nothing about the health of the host.
+ *
+ * Nor does a request that ran out of time. If it was the probe of a
+ * half-open circuit, the circuit is opened without a new cooldown, such that
+ * the next request probes the host instead.
+ */
+func (b *CircuitBreaker) record(host string, err error) {
+	if !b.enabled() {
+		return
+	}
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	c, ok := b.circuits[host]
+	if _, deadline := err.(*DeadlineExceededError); deadline {
+		if ok && c.state == CircuitHalfOpen {
+			b.transition(host, c, CircuitOpen)
+		}
+		return
+	}
+	if !ok {
+		/* Healthy hosts are not tracked, to keep the number of circuits down */
+		if !isTransient(err) {
+			return
+		}
+		c = &circuit{}
+		b.circuits[host] = c
+	}
+
+	if !isTransient(err) {
+		b.transition(host, c, CircuitClosed)
+		delete(b.circuits, host)
+		return
+	}
+
+	c.failures++
+	if c.state == CircuitHalfOpen || c.failures >= b.Threshold {
+		c.openedAt = time.Now()
+		b.transition(host, c, CircuitOpen)
+	}
+}
+
+/** Run operation against host, guarded by the circuit breaker */
+func (b *CircuitBreaker) Do(host string, operation func() error) error {
+	if err := b.allow(host); err != nil {
+		return err
+	}
+
+	err := operation()
+	b.record(host, err)
+	return err
+}
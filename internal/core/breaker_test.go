@@ -0,0 +1,143 @@
+package core
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type transition struct {
+	host  string
+	state CircuitState
+}
+
+type recordingObserver struct {
+	transitions []transition
+}
+
+func (o *recordingObserver) CircuitStateChanged(host string, state int) {
+	o.transitions = append(o.transitions, transition{host, CircuitState(state)})
+}
+
+func TestCircuitBreakerOpensAfterThreshold(t *testing.T) {
+	observer := &recordingObserver{}
+	breaker := NewCircuitBreaker(2, time.Hour, observer)
+	unavailable := NewStorageError("Failed to read from VDS. 503 Server Busy", 503)
+	fail := func() error { return unavailable }
+
+	err := breaker.Do("throttled", fail)
+	require.Equal(t, unavailable, err)
+	err = breaker.Do("throttled", fail)
+	require.Equal(t, unavailable, err)
+
+	reads := 0
+	err = breaker.Do("throttled", func() error { reads++; return nil })
+	require.IsType(t, &UnavailableError{}, err)
+	require.ErrorContains(t, err, "throttled")
+	require.Equal(t, 0, reads, "Expected open circuit to fail fast")
+	require.Greater(t, err.(*UnavailableError).RetryAfter(), time.Duration(0))
+
+	err = breaker.Do("healthy", func() error { reads++; return nil })
+	require.NoError(t, err, "Other hosts should not be affected")
+	require.Equal(t, 1, reads)
+
+	require.Equal(t, []transition{{"throttled", CircuitOpen}}, observer.transitions)
+}
+
+func TestCircuitBreakerIgnoresPermanentErrors(t *testing.T) {
+	breaker := NewCircuitBreaker(1, time.Hour, nil)
+
+	notFound := NewNotFoundError("Could not open VDS: 404 The specified blob does not exist")
+	for i := 0; i < 3; i++ {
+		err := breaker.Do("host", func() error { return notFound })
+		require.Equal(t, notFound, err)
+	}
+}
+
+func TestCircuitBreakerProbe(t *testing.T) {
+	observer := &recordingObserver{}
+	breaker := NewCircuitBreaker(1, 10*time.Millisecond, observer)
+	unavailable := NewStorageError("Failed to read from VDS. 503 Server Busy", 503)
+
+	breaker.Do("host", func() error { return unavailable })
+	err := breaker.Do("host", func() error { return nil })
+	require.IsType(t, &UnavailableError{}, err)
+
+	time.Sleep(20 * time.Millisecond)
+
+	/* Failing probe re-opens the circuit */
+	err = breaker.Do("host", func() error { return unavailable })
+	require.Equal(t, unavailable, err)
+	err = breaker.Do("host", func() error { return nil })
+	require.IsType(t, &UnavailableError{}, err)
+
+	time.Sleep(20 * time.Millisecond)
+
+	/* Successful probe closes it */
+	err = breaker.Do("host", func() error { return nil })
+	require.NoError(t, err)
+	err = breaker.Do("host", func() error { return nil })
+	require.NoError(t, err)
+
+	expected := []transition{
+		{"host", CircuitOpen},
+		{"host", CircuitHalfOpen},
+		{"host", CircuitOpen},
+		{"host", CircuitHalfOpen},
+		{"host", CircuitClosed},
+	}
+	require.Equal(t, expected, observer.transitions)
+}
+
+func TestCircuitBreakerProbeOutOfTime(t *testing.T) {
+	observer := &recordingObserver{}
+	breaker := NewCircuitBreaker(1, 10*time.Millisecond, observer)
+	unavailable := NewStorageError("Failed to read from VDS. 503 Server Busy", 503)
+	deadline := NewDeadlineExceededError("deadline exceeded", true)
+
+	breaker.Do("host", func() error { return unavailable })
+	time.Sleep(20 * time.Millisecond)
+
+	/* A probe that runs out of time lets the next request probe right away */
+	err := breaker.Do("host", func() error { return deadline })
+	require.Equal(t, deadline, err)
+	err = breaker.Do("host", func() error { return nil })
+	require.NoError(t, err)
+
+	/* Nor does it count against a closed circuit */
+	err = breaker.Do("host", func() error { return deadline })
+	require.Equal(t, deadline, err)
+	err = breaker.Do("host", func() error { return nil })
+	require.NoError(t, err)
+
+	expected := []transition{
+		{"host", CircuitOpen},
+		{"host", CircuitHalfOpen},
+		{"host", CircuitOpen},
+		{"host", CircuitHalfOpen},
+		{"host", CircuitClosed},
+	}
+	require.Equal(t, expected, observer.transitions)
+}
+
+func TestCircuitBreakerDisabled(t *testing.T) {
+	unavailable := NewStorageError("Failed to read from VDS. 503 Server Busy", 503)
+
+	var breaker *CircuitBreaker
+	for i := 0; i < 3; i++ {
+		require.Equal(t, unavailable, breaker.Do("host", func() error { return unavailable }))
+	}
+
+	breaker = NewCircuitBreaker(0, time.Hour, nil)
+	for i := 0; i < 3; i++ {
+		require.Equal(t, unavailable, breaker.Do("host", func() error { return unavailable }))
+	}
+}
+
+func TestStorageHost(t *testing.T) {
+	require.Equal(t, "account.blob.core.windows.net",
+		StorageHost("https://Account.blob.core.windows.net:443/container/blob"))
+	require.Equal(t, "bucket", StorageHost("s3://bucket/key"))
+	require.Equal(t, "", StorageHost("../../testdata/well_known/well_known_default.vds"))
+}
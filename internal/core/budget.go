@@ -0,0 +1,144 @@
+package core
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+/** Receives notifications about the memory budget, e.g. for metrics */
+type MemoryBudgetObserver interface {
+	/* The bytes currently reserved, after every reservation and release */
+	MemoryReserved(bytes int64)
+
+	/* A reservation that did not fit in the budget */
+	MemoryRejected()
+}
+
+/** A global budget of memory for responses
+ *
+ * Requests reserve the size of their response before it is allocated, and
+ * release it once the response is written and cached. Reservations that
+ * would exceed Limit fail fast with an UnavailableError, such that the
+ * server sheds load rather than running out of memory. The response cache
+ * is not accounted for, and should be sized separately.
+ *
+ * A nil budget, or a Limit of zero, accepts every reservation. Once the
+ * budget is in use, Limit must only be changed through SetLimit.
+ */
+type MemoryBudget struct {
+	Limit    int64
+	Observer MemoryBudgetObserver
+
+	lock     sync.Mutex
+	reserved int64
+}
+
+func NewMemoryBudget(limit int64, observer MemoryBudgetObserver) *MemoryBudget {
+	return &MemoryBudget{Limit: limit, Observer: observer}
+}
+
+/* How long clients are asked to wait before retrying a rejected request */
+const memoryBudgetRetryAfter = time.Second
+
+/** Reserve size bytes of the budget
+ *
+ * The returned function releases the reservation. It must be called once
+ * the memory is no longer in use, and calling it more than once is a no-op.
+ */
+func (b *MemoryBudget) Reserve(size int64) (release func(), err error) {
+	if b == nil {
+		return func() {}, nil
+	}
+
+	b.lock.Lock()
+	if b.Limit <= 0 {
+		b.lock.Unlock()
+		return func() {}, nil
+	}
+	if b.reserved+size > b.Limit {
+		available := b.Limit - b.reserved
+		b.lock.Unlock()
+
+		if b.Observer != nil {
+			b.Observer.MemoryRejected()
+		}
+		return nil, NewUnavailableError(fmt.Sprintf(
+			"server busy, response of %d bytes exceeds available budget of %d bytes",
+			size,
+			available,
+		), memoryBudgetRetryAfter)
+	}
+	b.reserved += size
+	reserved := b.reserved
+	b.lock.Unlock()
+	b.observe(reserved)
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			b.lock.Lock()
+			b.reserved -= size
+			reserved := b.reserved
+			b.lock.Unlock()
+			b.observe(reserved)
+		})
+	}, nil
+}
+
+/** Reserve the size given by estimate, see Reserve
+ *
+ * Estimating the size may be costly, e.g. a metadata read of the VDS, so
+ * estimate is only called when the budget is in use. Errors from estimate are
+ * returned as is.
+ */
+func (b *MemoryBudget) ReserveEstimate(
+	estimate func() (int64, error),
+) (release func(), err error) {
+	if !b.enabled() {
+		return func() {}, nil
+	}
+
+	size, err := estimate()
+	if err != nil {
+		return nil, err
+	}
+	return b.Reserve(size)
+}
+
+/* Whether reservations are checked against a limit at all */
+func (b *MemoryBudget) enabled() bool {
+	if b == nil {
+		return false
+	}
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.Limit > 0
+}
+
+/** Change the limit of the budget
+ *
+ * Reservations already made are kept, even if they no longer fit, and new
+ * ones are rejected until enough of them are released.
+ */
+func (b *MemoryBudget) SetLimit(limit int64) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.Limit = limit
+}
+
+/** The bytes currently reserved */
+func (b *MemoryBudget) Reserved() int64 {
+	if b == nil {
+		return 0
+	}
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.reserved
+}
+
+func (b *MemoryBudget) observe(reserved int64) {
+	if b.Observer != nil {
+		b.Observer.MemoryReserved(reserved)
+	}
+}
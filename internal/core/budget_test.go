@@ -0,0 +1,90 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type budgetObserver struct {
+	reserved []int64
+	rejected int
+}
+
+func (o *budgetObserver) MemoryReserved(bytes int64) {
+	o.reserved = append(o.reserved, bytes)
+}
+
+func (o *budgetObserver) MemoryRejected() {
+	o.rejected++
+}
+
+func TestMemoryBudgetRejectsBeyondLimit(t *testing.T) {
+	observer := &budgetObserver{}
+	budget := NewMemoryBudget(100, observer)
+
+	release, err := budget.Reserve(60)
+	require.NoError(t, err)
+
+	_, err = budget.Reserve(50)
+	require.IsType(t, &UnavailableError{}, err)
+	require.EqualError(t, err,
+		"server busy, response of 50 bytes exceeds available budget of 40 bytes",
+	)
+	require.Equal(t, 1, observer.rejected)
+
+	release()
+	release()
+	require.Equal(t, int64(0), budget.Reserved(), "Release should be idempotent")
+
+	release, err = budget.Reserve(100)
+	require.NoError(t, err)
+	release()
+
+	require.Equal(t, []int64{60, 0, 100, 0}, observer.reserved)
+}
+
+func TestMemoryBudgetDisabled(t *testing.T) {
+	var budget *MemoryBudget
+	release, err := budget.Reserve(1 << 40)
+	require.NoError(t, err)
+	release()
+
+	budget = NewMemoryBudget(0, nil)
+	release, err = budget.Reserve(1 << 40)
+	require.NoError(t, err)
+	release()
+	require.Equal(t, int64(0), budget.Reserved())
+}
+
+func TestMemoryBudgetEstimatesOnlyWhenEnabled(t *testing.T) {
+	estimates := 0
+	estimate := func() (int64, error) {
+		estimates++
+		return 60, nil
+	}
+
+	var disabled *MemoryBudget
+	release, err := disabled.ReserveEstimate(estimate)
+	require.NoError(t, err)
+	release()
+
+	release, err = NewMemoryBudget(0, nil).ReserveEstimate(estimate)
+	require.NoError(t, err)
+	release()
+	require.Equal(t, 0, estimates, "Disabled budgets should not estimate")
+
+	budget := NewMemoryBudget(100, nil)
+	release, err = budget.ReserveEstimate(estimate)
+	require.NoError(t, err)
+	require.Equal(t, 1, estimates)
+	require.Equal(t, int64(60), budget.Reserved())
+	release()
+
+	failure := NewInvalidArgument("invalid lineno")
+	_, err = budget.ReserveEstimate(func() (int64, error) {
+		return 0, failure
+	})
+	require.Equal(t, failure, err)
+	require.Equal(t, int64(0), budget.Reserved())
+}
@@ -1,7 +1,10 @@
 #include "ctypes.h"
 #include "capi.h"
 
+#include <OpenVDS/OpenVDS.h>
+
 #include "cppapi.hpp"
+#include "nlohmann/json.hpp"
 
 #include "exceptions.hpp"
 #include "subvolume.hpp"
@@ -14,8 +17,15 @@ void response_delete(struct response* buf) {
     *buf = response {};
 }
 
+const char* openvds_version() {
+    return OpenVDS::GetOpenVDSVersion();
+}
+
 struct Context {
     std::string errmsg;
+    std::string errcode;
+    std::string errdetails;
+    int         errstatus;
 };
 
 Context* context_new() {
@@ -34,6 +44,21 @@ const char* errmsg(Context* ctx) {
     return ctx->errmsg.c_str();
 }
 
+const char* errcode(Context* ctx) {
+    if (not ctx) return nullptr;
+    return ctx->errcode.c_str();
+}
+
+const char* errdetails(Context* ctx) {
+    if (not ctx) return nullptr;
+    return ctx->errdetails.c_str();
+}
+
+int errstatus(Context* ctx) {
+    if (not ctx) return 0;
+    return ctx->errstatus;
+}
+
 int handle_exception(Context* ctx, std::exception_ptr eptr) {
     try {
         if (eptr) std::rethrow_exception(eptr);
@@ -41,8 +66,18 @@ int handle_exception(Context* ctx, std::exception_ptr eptr) {
         if (ctx) ctx->errmsg = e.what();
         return STATUS_NULLPTR_ERROR;
     } catch (const detail::bad_request& e) {
-        if (ctx) ctx->errmsg = e.what();
+        if (ctx) {
+            ctx->errmsg     = e.what();
+            ctx->errcode    = e.code;
+            ctx->errdetails = nlohmann::json(e.details).dump();
+        }
         return STATUS_BAD_REQUEST;
+    } catch (const detail::storage_error& e) {
+        if (ctx) {
+            ctx->errmsg    = e.what();
+            ctx->errstatus = e.status;
+        }
+        return STATUS_STORAGE_ERROR;
     } catch (const std::exception& e) {
         if (ctx) ctx->errmsg = e.what();
         return STATUS_RUNTIME_ERROR;
@@ -80,6 +115,24 @@ int datasource_free(Context* ctx, DataSource* ds){
     }
 }
 
+int datasource_stats(
+    Context* ctx,
+    DataSource* datasource,
+    struct request_stats* out
+) {
+    try {
+        if (not datasource) throw detail::nullptr_error("Invalid datasource");
+        if (not out) throw detail::nullptr_error("Invalid out pointer");
+
+        out->storage_bytes = datasource->storage_bytes();
+        out->storage_requests = datasource->storage_requests();
+        out->read_seconds = datasource->read_seconds();
+        return STATUS_OK;
+    } catch (...) {
+        return handle_exception(ctx, std::current_exception());
+    }
+}
+
 int regular_surface_new(
     Context* ctx,
     float* data,
@@ -120,6 +173,31 @@ int regular_surface_free(Context* ctx, RegularSurface* surface) {
     }
 }
 
+int regular_surface_mask(
+    Context* ctx,
+    DataSource* datasource,
+    RegularSurface* surface,
+    enum coordinate_system coordinate_system,
+    const float* polygon,
+    size_t nvertices
+) {
+    try {
+        if (not datasource) throw detail::nullptr_error("Invalid datasource");
+        if (not surface)    throw detail::nullptr_error("Invalid surface");
+
+        cppapi::mask_surface(
+            *datasource,
+            *surface,
+            coordinate_system,
+            polygon,
+            nvertices
+        );
+        return STATUS_OK;
+    } catch (...) {
+        return handle_exception(ctx, std::current_exception());
+    }
+}
+
 int subvolume_new(
     Context* ctx,
     DataSource* datasource,
@@ -172,15 +250,18 @@ int slice(
     DataSource* datasource,
     int lineno,
     axis_name ax,
+    enum coordinate_system lineno_system,
     struct Bound* bounds,
     size_t nbounds,
+    const float* fillvalue,
+    enum sample_format format,
     response* out
 ) {
     try {
         if (not out)        throw detail::nullptr_error("Invalid out pointer");
         if (not datasource) throw detail::nullptr_error("Invalid datasource");
 
-        Direction const direction(ax);
+        Direction const direction(ax, lineno_system);
 
         std::vector< Bound > slice_bounds;
         for (int i = 0; i < nbounds; ++i) {
@@ -188,7 +269,15 @@ int slice(
             bounds++;
         }
 
-        cppapi::slice(*datasource, direction, lineno, slice_bounds, out);
+        cppapi::slice(
+            *datasource,
+            direction,
+            lineno,
+            slice_bounds,
+            fillvalue,
+            format,
+            out
+        );
         return STATUS_OK;
     } catch (...) {
         return handle_exception(ctx, std::current_exception());
@@ -200,15 +289,101 @@ int slice_metadata(
     DataSource* datasource,
     int lineno,
     axis_name ax,
+    enum coordinate_system lineno_system,
+    struct Bound* bounds,
+    size_t nbounds,
+    const float* fillvalue,
+    enum sample_format format,
+    response* out
+) {
+    try {
+        if (not out)        throw detail::nullptr_error("Invalid out pointer");
+        if (not datasource) throw detail::nullptr_error("Invalid datasource");
+
+        Direction const direction(ax, lineno_system);
+
+        std::vector< Bound > slice_bounds;
+        for (int i = 0; i < nbounds; ++i) {
+            slice_bounds.push_back(*bounds);
+            bounds++;
+        }
+
+        cppapi::slice_metadata(
+            *datasource,
+            direction,
+            lineno,
+            slice_bounds,
+            fillvalue,
+            format,
+            out
+        );
+        return STATUS_OK;
+    } catch (...) {
+        return handle_exception(ctx, std::current_exception());
+    }
+}
+
+int slice_with_metadata(
+    Context* ctx,
+    DataSource* datasource,
+    int lineno,
+    axis_name ax,
+    enum coordinate_system lineno_system,
     struct Bound* bounds,
     size_t nbounds,
+    const float* fillvalue,
+    enum sample_format format,
+    response* data,
+    response* metadata
+) {
+    try {
+        if (not data)       throw detail::nullptr_error("Invalid out pointer");
+        if (not metadata)   throw detail::nullptr_error("Invalid out pointer");
+        if (not datasource) throw detail::nullptr_error("Invalid datasource");
+
+        Direction const direction(ax, lineno_system);
+
+        std::vector< Bound > slice_bounds;
+        for (int i = 0; i < nbounds; ++i) {
+            slice_bounds.push_back(*bounds);
+            bounds++;
+        }
+
+        cppapi::slice_with_metadata(
+            *datasource,
+            direction,
+            lineno,
+            slice_bounds,
+            fillvalue,
+            format,
+            data,
+            metadata
+        );
+        return STATUS_OK;
+    } catch (...) {
+        return handle_exception(ctx, std::current_exception());
+    }
+}
+
+int slice_rows(
+    Context* ctx,
+    DataSource* datasource,
+    int lineno,
+    axis_name ax,
+    enum coordinate_system lineno_system,
+    struct Bound* bounds,
+    size_t nbounds,
+    const float* fillvalue,
+    enum sample_format format,
+    int first,
+    int stride,
     response* out
 ) {
     try {
         if (not out)        throw detail::nullptr_error("Invalid out pointer");
         if (not datasource) throw detail::nullptr_error("Invalid datasource");
 
-        Direction const direction(ax);
+        Direction const direction(ax, lineno_system);
 
         std::vector< Bound > slice_bounds;
         for (int i = 0; i < nbounds; ++i) {
@@ -216,7 +391,17 @@ int slice_metadata(
             bounds++;
         }
 
-        cppapi::slice_metadata(*datasource, direction, lineno, slice_bounds, out);
+        cppapi::slice_rows(
+            *datasource,
+            direction,
+            lineno,
+            slice_bounds,
+            fillvalue,
+            format,
+            first,
+            stride,
+            out
+        );
         return STATUS_OK;
     } catch (...) {
         return handle_exception(ctx, std::current_exception());
@@ -255,14 +440,148 @@ int fence(
 int fence_metadata(
     Context* ctx,
     DataSource* datasource,
+    enum coordinate_system coordinate_system,
+    const float* coordinates,
     size_t npoints,
+    enum interpolation_method interpolation_method,
+    const float* fillValue,
     response* out
 ) {
     try {
         if (not out)        throw detail::nullptr_error("Invalid out pointer");
         if (not datasource) throw detail::nullptr_error("Invalid datasource");
 
-        cppapi::fence_metadata(*datasource, npoints, out);
+        cppapi::fence_metadata(
+            *datasource,
+            coordinate_system,
+            coordinates,
+            npoints,
+            interpolation_method,
+            fillValue,
+            out
+        );
+        return STATUS_OK;
+    } catch (...) {
+        return handle_exception(ctx, std::current_exception());
+    }
+}
+
+int fence_with_metadata(
+    Context* ctx,
+    DataSource* datasource,
+    enum coordinate_system coordinate_system,
+    const float* coordinates,
+    size_t npoints,
+    enum interpolation_method interpolation_method,
+    const float* fillValue,
+    response* data,
+    response* metadata
+) {
+    try {
+        if (not data)       throw detail::nullptr_error("Invalid out pointer");
+        if (not metadata)   throw detail::nullptr_error("Invalid out pointer");
+        if (not datasource) throw detail::nullptr_error("Invalid datasource");
+
+        cppapi::fence_with_metadata(
+            *datasource,
+            coordinate_system,
+            coordinates,
+            npoints,
+            interpolation_method,
+            fillValue,
+            data,
+            metadata
+        );
+        return STATUS_OK;
+    } catch (...) {
+        return handle_exception(ctx, std::current_exception());
+    }
+}
+
+int fence_headers(
+    Context* ctx,
+    DataSource* datasource,
+    enum coordinate_system coordinate_system,
+    const float* coordinates,
+    size_t npoints,
+    const char* const* fields,
+    size_t nfields,
+    const float* fillValue,
+    response* out
+) {
+    try {
+        if (not out)        throw detail::nullptr_error("Invalid out pointer");
+        if (not datasource) throw detail::nullptr_error("Invalid datasource");
+
+        std::vector< std::string > names(fields, fields + nfields);
+        cppapi::fence_headers(
+            *datasource,
+            coordinate_system,
+            coordinates,
+            npoints,
+            names,
+            fillValue,
+            out
+        );
+        return STATUS_OK;
+    } catch (...) {
+        return handle_exception(ctx, std::current_exception());
+    }
+}
+
+int sample_points(
+    Context* ctx,
+    DataSource* datasource,
+    enum coordinate_system coordinate_system,
+    const float* points,
+    size_t npoints,
+    enum interpolation_method interpolation_method,
+    float fillvalue,
+    response* values,
+    response* valid
+) {
+    try {
+        if (not values)     throw detail::nullptr_error("Invalid out pointer");
+        if (not valid)      throw detail::nullptr_error("Invalid out pointer");
+        if (not datasource) throw detail::nullptr_error("Invalid datasource");
+
+        cppapi::sample_points(
+            *datasource,
+            coordinate_system,
+            points,
+            npoints,
+            interpolation_method,
+            fillvalue,
+            values,
+            valid
+        );
+        return STATUS_OK;
+    } catch (...) {
+        return handle_exception(ctx, std::current_exception());
+    }
+}
+
+int resample_traces(
+    Context* ctx,
+    DataSource* datasource,
+    const float* traces,
+    size_t ntraces,
+    float interval,
+    enum interpolation_method interpolation_method,
+    response* out
+) {
+    try {
+        if (not out)        throw detail::nullptr_error("Invalid out pointer");
+        if (not datasource) throw detail::nullptr_error("Invalid datasource");
+
+        cppapi::resample_traces(
+            *datasource,
+            traces,
+            ntraces,
+            interval,
+            interpolation_method,
+            out
+        );
         return STATUS_OK;
     } catch (...) {
         return handle_exception(ctx, std::current_exception());
@@ -272,13 +591,20 @@ int fence_metadata(
 int metadata(
     Context* ctx,
     DataSource* datasource,
+    int include_import_info,
+    int include_layout,
     response* out
 ) {
     try {
         if (not out)        throw detail::nullptr_error("Invalid out pointer");
         if (not datasource) throw detail::nullptr_error("Invalid datasource");
 
-        cppapi::metadata(*datasource, out);
+        cppapi::metadata(
+            *datasource,
+            include_import_info != 0,
+            include_layout != 0,
+            out
+        );
         return STATUS_OK;
     } catch (...) {
         return handle_exception(ctx, std::current_exception());
@@ -328,6 +654,25 @@ int attribute_metadata(
     }
 }
 
+int compatibility(
+    Context* ctx,
+    DataSource* datasource_a,
+    DataSource* datasource_b,
+    double tolerance,
+    response* out
+) {
+    try {
+        if (not out)          throw detail::nullptr_error("Invalid out pointer");
+        if (not datasource_a) throw detail::nullptr_error("Invalid datasource");
+        if (not datasource_b) throw detail::nullptr_error("Invalid datasource");
+
+        cppapi::compatibility(*datasource_a, *datasource_b, tolerance, out);
+        return STATUS_OK;
+    } catch (...) {
+        return handle_exception(ctx, std::current_exception());
+    }
+}
+
 int attribute(
     Context* ctx,
     DataSource* datasource,
@@ -335,12 +680,16 @@ int attribute(
     enum attribute* attributes,
     size_t nattributes,
     float stepsize,
+    enum interpolation_method vertical_interpolation,
+    float min_valid_fraction,
     size_t from,
     size_t to,
-    void*  out
+    void*  out,
+    attribute_coverage* coverage
 ) {
     try {
         if (not out)           throw detail::nullptr_error("Invalid out pointer");
+        if (not coverage)      throw detail::nullptr_error("Invalid coverage pointer");
         if (not datasource)    throw detail::nullptr_error("Invalid datasource");
         if (not src_subvolume) throw detail::nullptr_error("Invalid subvolume");
 
@@ -366,9 +715,12 @@ int attribute(
             &dst_segment_blueprint,
             attributes,
             nattributes,
+            vertical_interpolation,
+            min_valid_fraction,
             from,
             to,
-            outs
+            outs,
+            coverage
         );
         return STATUS_OK;
     } catch (...) {
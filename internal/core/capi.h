@@ -12,7 +12,8 @@ enum status_code {
     STATUS_OK = 0,
     STATUS_NULLPTR_ERROR,
     STATUS_RUNTIME_ERROR,
-    STATUS_BAD_REQUEST
+    STATUS_BAD_REQUEST,
+    STATUS_STORAGE_ERROR
 };
 
 /** Carry additional context between caller and functions
@@ -39,8 +40,20 @@ int context_free(Context* ctx);
 /** Read out the last error msg set on the context */
 const char* errmsg(Context* ctx);
 
+/** Read out the code of the last error, empty if it has none */
+const char* errcode(Context* ctx);
+
+/** Read out the details of the last error, as a json object of strings */
+const char* errdetails(Context* ctx);
+
+/** Read out the storage status of the last error, 0 if it has none */
+int errstatus(Context* ctx);
+
 void response_delete(struct response*);
 
+/** Version string of the OpenVDS library in use */
+const char* openvds_version();
+
 struct DataSource;
 typedef struct DataSource DataSource;
 
@@ -54,6 +67,13 @@ int single_datasource_new(
 
 int datasource_free(Context* ctx, DataSource* f);
 
+/** Statistics for all reads done through the datasource so far */
+int datasource_stats(
+    Context* ctx,
+    DataSource* datasource,
+    struct request_stats* out
+);
+
 struct RegularSurface;
 typedef struct RegularSurface RegularSurface;
 
@@ -76,6 +96,20 @@ int regular_surface_free(
     RegularSurface* surface
 );
 
+/** Mask out every node of the surface that is outside the polygon
+ *
+ * The nodes outside are set to the fill value of the surface, such that they
+ * are not sampled. The polygon is given as [x y x y ...] in coordinate_system.
+ */
+int regular_surface_mask(
+    Context* ctx,
+    DataSource* datasource,
+    RegularSurface* surface,
+    enum coordinate_system coordinate_system,
+    const float* polygon,
+    size_t nvertices
+);
+
 struct SurfaceBoundedSubVolume;
 typedef struct SurfaceBoundedSubVolume SurfaceBoundedSubVolume;
 
@@ -96,6 +130,8 @@ int subvolume_free(
 int metadata(
     Context* ctx,
     DataSource* datasource,
+    int include_import_info,
+    int include_layout,
     response* out
 );
 
@@ -104,8 +140,11 @@ int slice(
     DataSource* datasource,
     int lineno,
     enum axis_name direction,
+    enum coordinate_system lineno_system,
     struct Bound* bounds,
     size_t nbounds,
+    const float* fillvalue,
+    enum sample_format format,
     response* out
 );
 
@@ -114,8 +153,50 @@ int slice_metadata(
     DataSource* datasource,
     int lineno,
     enum axis_name direction,
+    enum coordinate_system lineno_system,
+    struct Bound* bounds,
+    size_t nbounds,
+    const float* fillvalue,
+    enum sample_format format,
+    response* out
+);
+
+/** A slice and its metadata, from a single call
+ *
+ * The outputs are identical to those of slice and slice_metadata. Both must
+ * be deleted by the caller, also on failure.
+ */
+int slice_with_metadata(
+    Context* ctx,
+    DataSource* datasource,
+    int lineno,
+    enum axis_name direction,
+    enum coordinate_system lineno_system,
     struct Bound* bounds,
     size_t nbounds,
+    const float* fillvalue,
+    enum sample_format format,
+    response* data,
+    response* metadata
+);
+
+/** Every stride'th row of a slice, starting at row first
+ *
+ * The rows are those of the row-major data of slice, and are concatenated in
+ * the same layout. Only the requested rows are read from storage.
+ */
+int slice_rows(
+    Context* ctx,
+    DataSource* datasource,
+    int lineno,
+    enum axis_name direction,
+    enum coordinate_system lineno_system,
+    struct Bound* bounds,
+    size_t nbounds,
+    const float* fillvalue,
+    enum sample_format format,
+    int first,
+    int stride,
     response* out
 );
 
@@ -133,7 +214,82 @@ int fence(
 int fence_metadata(
     Context* ctx,
     DataSource* datasource,
+    enum coordinate_system coordinate_system,
+    const float* points,
     size_t npoints,
+    enum interpolation_method interpolation_method,
+    const float* fillValue,
+    response* out
+);
+
+/** A fence and its metadata, from a single call
+ *
+ * The outputs are identical to those of fence and fence_metadata. Both must
+ * be deleted by the caller, also on failure.
+ */
+int fence_with_metadata(
+    Context* ctx,
+    DataSource* datasource,
+    enum coordinate_system coordinate_system,
+    const float* points,
+    size_t npoints,
+    enum interpolation_method interpolation_method,
+    const float* fillValue,
+    response* data,
+    response* metadata
+);
+
+/** The trace headers of a fence, from the header channels of the cube
+ *
+ * out gets a row of nfields floats per point, one per field, from the trace
+ * nearest to the point. Points outside of the survey get fillValue, or fail
+ * the request if it is null, as for fence. out must be deleted by the
+ * caller, also on failure.
+ */
+int fence_headers(
+    Context* ctx,
+    DataSource* datasource,
+    enum coordinate_system coordinate_system,
+    const float* points,
+    size_t npoints,
+    const char* const* fields,
+    size_t nfields,
+    const float* fillValue,
+    response* out
+);
+
+/** Sample the cube at npoints scattered [x y z] points
+ *
+ * x and y are in coordinate_system, z in the unit of the vertical axis.
+ * values gets a float per point, and valid a byte per point, which is 0 for
+ * points outside of the cube or where the data is absent. The value of those
+ * points is fillvalue. Both must be deleted by the caller, also on failure.
+ */
+int sample_points(
+    Context* ctx,
+    DataSource* datasource,
+    enum coordinate_system coordinate_system,
+    const float* points,
+    size_t npoints,
+    enum interpolation_method interpolation_method,
+    float fillvalue,
+    response* values,
+    response* valid
+);
+
+/** Resample ntraces whole traces, as returned by fence, to interval
+ *
+ * The interval is in the unit of the vertical axis, and the new samples
+ * start at the first sample of the cube. out must be deleted by the caller,
+ * also on failure.
+ */
+int resample_traces(
+    Context* ctx,
+    DataSource* datasource,
+    const float* traces,
+    size_t ntraces,
+    float interval,
+    enum interpolation_method interpolation_method,
     response* out
 );
 
@@ -154,6 +310,19 @@ int attribute_metadata(
     response* out
 );
 
+/** Compare the geometry of two cubes
+ *
+ * The response is a json document describing, axis by axis, whether the two
+ * cubes match, and whether their world transforms agree within tolerance.
+ */
+int compatibility(
+    Context* ctx,
+    DataSource* datasource_a,
+    DataSource* datasource_b,
+    double tolerance,
+    response* out
+);
+
 /** Attribute calculation
 *
 * Output buffer
@@ -171,6 +340,20 @@ int attribute_metadata(
 * result.
 *
 * [1] https://pkg.go.dev/cmd/cgo#hdr-Passing_pointers
+*
+* Absent data
+* -----------
+*
+* Absent samples are left out of the attributes. Nodes where the fraction of
+* the window holding data is less than min_valid_fraction, or where there is
+* no data at all, are set to the fill value of the reference surface.
+*
+* Coverage
+* --------
+*
+* coverage is an array of nattributes, one per attribute, in which the nodes
+* in [from, to) that are set to the fill value are counted by reason. The
+* counts are added to whatever coverage already holds.
 */
 int attribute(
     Context* ctx,
@@ -179,9 +362,12 @@ int attribute(
     enum attribute* attributes,
     size_t nattributes,
     float stepsize,
+    enum interpolation_method vertical_interpolation,
+    float min_valid_fraction,
     size_t from,
     size_t to,
-    void* out
+    void* out,
+    attribute_coverage* coverage
 );
 
 int align_surfaces(
@@ -4,8 +4,16 @@ import (
 	"context"
 	"fmt"
 	"strings"
+	"net"
 	"net/url"
+	"path"
+	"path/filepath"
+	"sort"
+	"sync/atomic"
+	"time"
 
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
 	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blob"
 )
 
@@ -39,11 +47,15 @@ func srtContainsContainer(srt string) bool {
 func validateSasSrt(connection *AzureConnection) error {
 	query, err := url.ParseQuery(connection.sas)
 	if err != nil {
-		return NewInvalidArgument(fmt.Sprintf(
-			"illegal sas-token, was: '%s',  err: %v",
-			connection.sas,
-			err,
-		))
+		return NewCodedInvalidArgument(
+			"invalid_sas",
+			nil,
+			fmt.Sprintf(
+				"illegal sas-token, was: '%s',  err: %v",
+				connection.sas,
+				err,
+			),
+		)
 	}
 
 	if !query.Has("srt") {
@@ -54,10 +66,96 @@ func validateSasSrt(connection *AzureConnection) error {
 	if srtContainsObject(srt) && srtContainsContainer(srt) {
 		return nil
 	}
-	return NewInvalidArgument(fmt.Sprintf(
-		"invalid sas-token, expected 'c' and 'o' in 'srt', found: '%s'",
-		srt,
-	))
+	return NewCodedInvalidArgument(
+		"invalid_sas",
+		nil,
+		fmt.Sprintf(
+			"invalid sas-token, expected 'c' and 'o' in 'srt', found: '%s'",
+			srt,
+		),
+	)
+}
+
+/*
+ * Formats accepted by Azure for the signed expiry ('se') parameter [1].
+ *
+ * [1] https://learn.microsoft.com/en-us/rest/api/storageservices/formatting-datetime-values
+ */
+var sasTimeFormats = []string{
+	"2006-01-02T15:04:05Z",
+	"2006-01-02T15:04:05.0000000Z",
+	"2006-01-02T15:04Z",
+	"2006-01-02",
+}
+
+/* Parse a time of a sas-token, e.g. its signed expiry. False if malformed */
+func ParseSasTime(value string) (time.Time, bool) {
+	for _, format := range sasTimeFormats {
+		t, err := time.Parse(format, value)
+		if err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+/** Validate 'se' (Signed Expiry)
+ *
+ * Fail fast on sas-tokens that are already expired, or that expire within
+ * the grace period, instead of paying for a connection attempt that is bound
+ * to fail. The error message contains the expiry time, but never the token
+ * itself.
+ *
+ * Tokens without an 'se' parameter, or with an expiry we cannot make sense
+ * of, are let through and left for Azure to judge.
+ */
+func validateSasExpiry(connection *AzureConnection, grace time.Duration) error {
+	query, err := url.ParseQuery(connection.sas)
+	if err != nil || !query.Has("se") {
+		return nil
+	}
+
+	expiry, ok := ParseSasTime(query.Get("se"))
+	if !ok {
+		return nil
+	}
+
+	if time.Now().Add(grace).Before(expiry) {
+		return nil
+	}
+
+	msg := "sas-token expired"
+	if grace > 0 {
+		msg += fmt.Sprintf(" (or expires within %v)", grace)
+	}
+	msg += " at " + expiry.Format(time.RFC3339)
+	return NewCodedUnauthorizedError("sas_expired", nil, msg)
+}
+
+/** Options for VDSs in AWS S3
+ *
+ * All fields are optional. Without an access key the server's own (ambient)
+ * credentials are used, e.g. from the environment or an instance profile.
+ * Region overrides the region the server is configured with.
+ */
+type S3Options struct {
+	Region       string
+	AccessKeyId  string
+	SecretKey    string
+	SessionToken string
+}
+
+/** Credentials used to access a VDS
+ *
+ * For Azure exactly one of Sas and BearerToken is expected to be set. Sas is
+ * a shared access signature, while BearerToken is an Azure AD access token,
+ * typically obtained by the client through an on-behalf-of flow. S3 is only
+ * used for VDSs in AWS S3.
+ */
+type Credentials struct {
+	Sas         string
+	BearerToken string
+	S3          S3Options
 }
 
 type Connection interface {
@@ -66,22 +164,89 @@ type Connection interface {
 	IsAuthorizedToRead()    bool
 }
 
+/*
+ * Connections to backends other than Azure name themselves in error messages,
+ * as the messages from OpenVDS seldom make it clear which backend failed.
+ */
+type describedConnection interface {
+	describe() string
+}
+
 type AzureConnection struct {
 	blobPath  string
 	container string
 	host      string
+	endpoint  string
 	sas       string
+	token     string
 }
 
 func (c *AzureConnection) Url() string {
 	return fmt.Sprintf("azure://%s/%s", c.container, c.blobPath)
 }
 
+/*
+ * The endpoint requests are sent to. That is the host of the url, unless the
+ * server is configured to reach the storage account through another endpoint,
+ * e.g. a private link.
+ */
+func (c *AzureConnection) blobEndpoint() string {
+	if c.endpoint != "" {
+		return c.endpoint
+	}
+	return "https://" + c.host
+}
+
 func (c *AzureConnection) ConnectionString() string {
-	return fmt.Sprintf("BlobEndpoint=https://%s;SharedAccessSignature=?%s",
-		c.host,
-		c.sas,
+	parameters := []string{
+		fmt.Sprintf("BlobEndpoint=%s", c.blobEndpoint()),
+	}
+
+	if account := azureAccountName(c.host); account != "" {
+		parameters = append(parameters, fmt.Sprintf("AccountName=%s", account))
+	}
+
+	if c.token != "" {
+		parameters = append(parameters, fmt.Sprintf("BearerToken=%s", c.token))
+	} else {
+		parameters = append(
+			parameters,
+			fmt.Sprintf("SharedAccessSignature=?%s", c.sas),
+		)
+	}
+	return strings.Join(parameters, ";")
+}
+
+/** A TokenCredential that hands out the bearer token given by the client
+ *
+ * We don't know (nor care) when the token expires. Azure is the judge of
+ * whether it's still valid.
+ */
+type staticTokenCredential struct {
+	token string
+}
+
+func (c staticTokenCredential) GetToken(
+	ctx     context.Context,
+	options policy.TokenRequestOptions,
+) (azcore.AccessToken, error) {
+	return azcore.AccessToken{
+		Token:     c.token,
+		ExpiresOn: time.Now().Add(time.Hour),
+	}, nil
+}
+
+func (c *AzureConnection) newVolumeDataLayoutClient() (*blob.Client, error) {
+	url := fmt.Sprintf("%s/%s/%s/VolumeDataLayout",
+		c.blobEndpoint(),
+		c.container,
+		c.blobPath,
 	)
+
+	if c.token != "" {
+		return blob.NewClient(url, staticTokenCredential{token: c.token}, nil)
+	}
+	return blob.NewClientWithNoCredential(url + "?" + c.sas, nil)
 }
 
 /** Verify that the connection has enough access to read a VDS from Blob Store
@@ -97,6 +262,9 @@ func (c *AzureConnection) ConnectionString() string {
  * valid not only for the VolumeDataLayout blob, but all blobs in the same and
  * subsequent directories relative to the VolumeDataLayout blob.
  *
+ * Bearer tokens are not scoped to a resource in the same way, so for those
+ * the HEAD request alone decides.
+ *
  * [1] https://learn.microsoft.com/en-us/rest/api/storageservices/get-blob-properties
  */
 func (c *AzureConnection) IsAuthorizedToRead() bool {
@@ -109,16 +277,7 @@ func (c *AzureConnection) IsAuthorizedToRead() bool {
 		return false
 	}
 
-	client, err := blob.NewClientWithNoCredential(
-		fmt.Sprintf("https://%s/%s/%s/VolumeDataLayout?%s",
-			c.host,
-			c.container,
-			c.blobPath,
-			c.sas,
-		),
-		nil,
-	)
-
+	client, err := c.newVolumeDataLayoutClient()
 	if err != nil {
 		return false
 	}
@@ -128,16 +287,17 @@ func (c *AzureConnection) IsAuthorizedToRead() bool {
 }
 
 func NewAzureConnection(
-	blobPath  string,
-	container string,
-	host      string,
-	sas       string,
+	blobPath    string,
+	container   string,
+	host        string,
+	credentials Credentials,
 ) *AzureConnection {
 	return &AzureConnection{
 		blobPath:  blobPath,
 		container: container,
 		host:      host,
-		sas:       sas,
+		sas:       sanitizeSAS(credentials.Sas),
+		token:     credentials.BearerToken,
 	}
 }
 
@@ -170,20 +330,218 @@ func NewFileConnection(path string) *FileConnection {
  * OpenVDS.
  */
 func makeUrl(path string) (*url.URL, error) {
-	path = strings.TrimSuffix(path, "/")
+	path = strings.TrimRight(path, "/")
 	return url.Parse(path)
 }
 
+/*
+ * Hostnames are case-insensitive and may be given in their fully qualified
+ * form, i.e. with a trailing dot. Neither should make a difference when
+ * checking against the allowlist.
+ */
+func normalizeHostname(u *url.URL) string {
+	return strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+}
+
+func splitPath(path string) []string {
+	path = strings.Trim(path, "/")
+	if len(path) == 0 {
+		return []string{}
+	}
+	return strings.Split(path, "/")
+}
+
+/** Parse an allowlist pattern
+ *
+ * A pattern is a url, e.g. 'https://<account>.blob.core.windows.net/seismic/*'.
+ * Scheme and host must be spelled out in full, while each segment of the path
+ * (container, directories, blob) may contain wildcards as understood by
+ * path.Match. Bad patterns are a configuration error, and panics.
+ */
+func parseAllowlistPattern(pattern string) *url.URL {
+	u, err := checkAllowlistPattern(pattern)
+	if err != nil {
+		panic(err)
+	}
+	return u
+}
+
+/* Like parseAllowlistPattern, but bad patterns are returned as errors */
+func checkAllowlistPattern(pattern string) (*url.URL, error) {
+	pattern = strings.TrimSpace(pattern)
+	if len(pattern) == 0 {
+		return nil, fmt.Errorf("Empty storage-account not allowed")
+	}
+
+	u, err := url.Parse(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(u.Scheme) == 0 || len(u.Hostname()) == 0 {
+		return nil, fmt.Errorf(
+			"Storage-account '%s' must contain both scheme and host",
+			pattern,
+		)
+	}
+
+	for _, segment := range splitPath(u.Path) {
+		if _, err := path.Match(segment, ""); err != nil {
+			return nil, fmt.Errorf(
+				"Storage-account '%s' has a malformed pattern: %v",
+				pattern,
+				err,
+			)
+		}
+	}
+
+	return u, nil
+}
+
+/** Check if the requested url is covered by an allowlist pattern
+ *
+ * Scheme and hostname are compared case-insensitively, while the path is
+ * matched segment by segment against the pattern. A pattern covers
+ * everything beneath it, so 'https://<account>/seismic' allows all blobs in
+ * the 'seismic' container.
+ *
+ * Requests with relative segments ('.' and '..') are never matched by a
+ * pattern with a path, as they could be used to escape the allowed
+ * container.
+ */
+func matchesPattern(pattern *url.URL, requested *url.URL) bool {
+	if !strings.EqualFold(requested.Scheme, pattern.Scheme) {
+		return false
+	}
+
+	if normalizeHostname(requested) != normalizeHostname(pattern) {
+		return false
+	}
+
+	if pattern.Port() != "" && pattern.Port() != requested.Port() {
+		return false
+	}
+
+	patternSegments := splitPath(pattern.Path)
+	if len(patternSegments) == 0 {
+		return true
+	}
+
+	requestedSegments := splitPath(requested.Path)
+	if len(requestedSegments) < len(patternSegments) {
+		return false
+	}
+
+	for _, segment := range requestedSegments {
+		if segment == "." || segment == ".." {
+			return false
+		}
+	}
+
+	for i, segment := range patternSegments {
+		match, err := path.Match(segment, requestedSegments[i])
+		if err != nil || !match {
+			return false
+		}
+	}
+	return true
+}
+
+/** The storage accounts that VDSs may be read from
+ *
+ * Shared by the connection makers of all storages, and can be replaced while
+ * the server is running, see Set. A nil allowlist, like an empty one, allows
+ * everything, except for VDSs read with the server's own credentials, see
+ * checkListed.
+ *
+ * Safe for concurrent use.
+ */
+type Allowlist struct {
+	patterns atomic.Value
+}
+
+func NewAllowlist(patterns []string) (*Allowlist, error) {
+	allowlist := &Allowlist{}
+	return allowlist, allowlist.Set(patterns)
+}
+
+/** Replace the patterns of the allowlist, see parseAllowlistPattern
+ *
+ * Either all patterns are replaced, or, if any of them is bad, none.
+ */
+func (a *Allowlist) Set(patterns []string) error {
+	parsed := []*url.URL{}
+	for _, pattern := range patterns {
+		u, err := checkAllowlistPattern(pattern)
+		if err != nil {
+			return err
+		}
+		parsed = append(parsed, u)
+	}
+	a.patterns.Store(parsed)
+	return nil
+}
+
+func (a *Allowlist) check(requested *url.URL) error {
+	return isAllowed(a.load(), requested)
+}
+
+/** Like check, but an empty allowlist allows nothing
+ *
+ * For VDSs read with the server's own (ambient) credentials, where an empty
+ * allowlist would give access to everything the server itself can read.
+ */
+func (a *Allowlist) checkListed(requested *url.URL) error {
+	if len(a.load()) == 0 {
+		return NewCodedForbiddenError(
+			"storage_account_not_allowed",
+			nil,
+			fmt.Sprintf(
+				"%s bucket '%s' can not be read with the server's credentials, " +
+				"as the allowlist is empty. Contact the system admin to get " +
+				"your bucket on the allowlist",
+				requested.Scheme,
+				requested.Hostname(),
+			),
+		)
+	}
+	return a.check(requested)
+}
+
+func (a *Allowlist) load() []*url.URL {
+	if a == nil {
+		return nil
+	}
+	patterns, _ := a.patterns.Load().([]*url.URL)
+	return patterns
+}
+
+/** Check the requested url against the allowlist
+ *
+ * An empty allowlist allows everything. The error names the rejected host and
+ * container only, the rest of the url has no business in an error message.
+ */
 func isAllowed(allowlist []*url.URL, requested *url.URL) error {
+	if len(allowlist) == 0 {
+		return nil
+	}
+
 	for _, candidate := range allowlist {
-		if strings.EqualFold(requested.Hostname(), candidate.Hostname()) {
+		if matchesPattern(candidate, requested) {
 			return nil
 		}
 	}
-	msg := "unsupported storage account: %s. This API is configured to work "  +
-		"with a pre-defined set of storage accounts. Contact the system admin " +
-		"to get your storage account on the allowlist"
-	return NewInvalidArgument(fmt.Sprintf(msg, requested.Host))
+
+	container, _ := splitAzureUrl(requested.Path)
+	msg := "unsupported storage account or container: %s/%s. This API is " +
+		"configured to work with a pre-defined set of storage accounts and " +
+		"containers. Contact the system admin to get your storage account on " +
+		"the allowlist"
+	return NewCodedForbiddenError(
+		"storage_account_not_allowed",
+		nil,
+		fmt.Sprintf(msg, requested.Host, container),
+	)
 }
 /*
  * Strip leading ? if present from the input SAS token
@@ -198,40 +556,122 @@ func splitAzureUrl(path string) (string, string) {
 	return container, blobPath
 }
 
-type ConnectionMaker func(blob, sas string) (Connection, error)
+/** The storage account name of a blob host
+ *
+ * Whatever the dns suffix, be it the public cloud (blob.core.windows.net), a
+ * sovereign cloud (e.g. blob.core.usgovcloudapi.net) or a private link
+ * (privatelink.blob.core.windows.net), the account is the first label of the
+ * host. Hosts given as ip addresses don't name the account, and give none.
+ */
+func azureAccountName(host string) string {
+	hostname := strings.TrimSuffix(host, ".")
+	if h, _, err := net.SplitHostPort(hostname); err == nil {
+		hostname = h
+	}
 
-func MakeAzureConnection(accounts []string) ConnectionMaker {
-	var allowlist []*url.URL
-	for _, account := range accounts {
-		account = strings.TrimSpace(account)
-		if len(account) == 0 {
-			panic("Empty storage-account not allowed")
-		}
-		url, err := url.Parse(account)
-		if err != nil {
-			panic(err)
-		}
+	if net.ParseIP(hostname) != nil {
+		return ""
+	}
+	account, _, _ := strings.Cut(hostname, ".")
+	return strings.ToLower(account)
+}
+
+/** Parse the blob endpoint override
+ *
+ * The endpoint is a url, e.g. 'https://{account}.privatelink.blob.core.windows.net',
+ * where {account} is replaced by the storage account of the requested blob.
+ * An empty endpoint means no override. Bad endpoints are a configuration
+ * error, and panics.
+ */
+func parseBlobEndpoint(endpoint string) string {
+	endpoint = strings.TrimRight(strings.TrimSpace(endpoint), "/")
+	if len(endpoint) == 0 {
+		return ""
+	}
 
-		allowlist = append(allowlist, url)
+	u, err := url.Parse(strings.ReplaceAll(endpoint, "{account}", "account"))
+	if err != nil {
+		panic(err)
 	}
 
-	return func(blob string, sas string) (Connection, error) {
+	if len(u.Scheme) == 0 || len(u.Hostname()) == 0 {
+		panic(fmt.Sprintf(
+			"Blob endpoint '%s' must contain both scheme and host",
+			endpoint,
+		))
+	}
+
+	if u.RawQuery != "" || u.Path != "" {
+		panic(fmt.Sprintf(
+			"Blob endpoint '%s' must not contain a path or query",
+			endpoint,
+		))
+	}
+	return endpoint
+}
+
+/*
+ * Split a blob url, i.e. https://<host>/<container>/<blob>, into container and
+ * blob. The host may be anything, as long as it's there.
+ */
+func splitBlobUrl(blobUrl *url.URL) (string, string, error) {
+	container, blobPath := splitAzureUrl(blobUrl.Path)
+	if blobUrl.Hostname() == "" || container == "" || blobPath == "" {
+		return "", "", NewInvalidArgument(fmt.Sprintf(
+			"invalid blob url, expected %s://<host>/<container>/<blob>",
+			blobUrl.Scheme,
+		))
+	}
+	return container, blobPath, nil
+}
+
+type ConnectionMaker func(blob string, credentials Credentials) (Connection, error)
+
+/** Make a ConnectionMaker for Azure Blob Store
+ *
+ * Only blobs allowed by 'allowlist' are accepted, see Allowlist. Sas-tokens
+ * that expire within 'sasExpiryGrace' are rejected up front.
+ *
+ * Blobs are read from the host of their url, unless 'blobEndpoint' is given,
+ * see parseBlobEndpoint. The allowlist is always checked against the url as
+ * requested.
+ */
+func MakeAzureConnection(
+	allowlist      *Allowlist,
+	sasExpiryGrace time.Duration,
+	blobEndpoint   string,
+) ConnectionMaker {
+	blobEndpoint = parseBlobEndpoint(blobEndpoint)
+
+	return func(blob string, credentials Credentials) (Connection, error) {
 		blobUrl, err := makeUrl(blob)
 		if err != nil {
 			return nil, NewInvalidArgument(err.Error())
 		}
 
-		if err := isAllowed(allowlist, blobUrl); err != nil {
+		if err := allowlist.check(blobUrl); err != nil {
+			return nil, err
+		}
+
+		container, blobPath, err := splitBlobUrl(blobUrl)
+		if err != nil {
 			return nil, err
 		}
 
-		container, blobPath := splitAzureUrl(blobUrl.Path)
 		connection := NewAzureConnection(
 			blobPath,
 			container,
 			blobUrl.Host,
-			sanitizeSAS(sas),
+			credentials,
 		)
+		account := azureAccountName(blobUrl.Host)
+		if account == "" && strings.Contains(blobEndpoint, "{account}") {
+			return nil, NewInvalidArgument(fmt.Sprintf(
+				"unable to tell the storage account from the host '%s'",
+				blobUrl.Host,
+			))
+		}
+		connection.endpoint = strings.ReplaceAll(blobEndpoint, "{account}", account)
 
 		/*
 		 * OpenVDS (v3.0.3) segfaults on sas-tokens where the srt-field (allowed
@@ -245,6 +685,388 @@ func MakeAzureConnection(accounts []string) ConnectionMaker {
 			return nil, err
 		}
 
+		if err := validateSasExpiry(connection, sasExpiryGrace); err != nil {
+			return nil, err
+		}
+
 		return connection, nil
 	}
 }
+
+func outsideRoot(root string, path string) bool {
+	relative, err := filepath.Rel(root, path)
+	return err != nil ||
+		relative == ".." ||
+		strings.HasPrefix(relative, ".." + string(filepath.Separator))
+}
+
+/*
+ * Resolve the symlinks of path, as far as it exists. The part that does not
+ * exist is kept as is, such that missing files are left for OpenVDS to
+ * report.
+ */
+func evalExistingSymlinks(path string) string {
+	resolved, err := filepath.EvalSymlinks(path)
+	if err == nil {
+		return resolved
+	}
+
+	parent := filepath.Dir(path)
+	if parent == path {
+		return path
+	}
+	return filepath.Join(evalExistingSymlinks(parent), filepath.Base(path))
+}
+
+/*
+ * Resolve a local path and make sure it's contained within root. Relative
+ * paths are resolved against the current working directory. Symlinks are
+ * followed, and must not lead out of realRoot, the root with its own
+ * symlinks resolved.
+ */
+func resolveLocalPath(
+	root      string,
+	realRoot  string,
+	localPath string,
+) (string, error) {
+	absolute, err := filepath.Abs(localPath)
+	if err != nil {
+		return "", NewInvalidArgument(err.Error())
+	}
+
+	if outsideRoot(root, absolute) ||
+		outsideRoot(realRoot, evalExistingSymlinks(absolute)) {
+		return "", NewCodedInvalidArgument(
+			"path_outside_root",
+			nil,
+			fmt.Sprintf(
+				"local path '%s' is outside of the configured root",
+				localPath,
+			),
+		)
+	}
+	return absolute, nil
+}
+
+/** Make a ConnectionMaker for VDSs on the local filesystem
+ *
+ * Urls with the file:// scheme and bare paths are opened from the local
+ * filesystem, as long as they resolve to somewhere beneath 'root', also when
+ * symlinks are followed. Everything else is handed over to 'fallback', or
+ * rejected if there is none.
+ *
+ * Local files need no credentials. Whatever is passed is ignored.
+ *
+ * This is meant for development and testing, e.g. against test cubes that
+ * have been downloaded locally, and should not be turned on in production.
+ */
+func MakeLocalConnection(root string, fallback ConnectionMaker) ConnectionMaker {
+	root, err := filepath.Abs(root)
+	if err != nil {
+		panic(err)
+	}
+	realRoot := evalExistingSymlinks(root)
+
+	return func(blob string, credentials Credentials) (Connection, error) {
+		blobUrl, err := url.Parse(blob)
+		if err != nil {
+			return nil, NewInvalidArgument(err.Error())
+		}
+
+		if blobUrl.Scheme != "" && !strings.EqualFold(blobUrl.Scheme, "file") {
+			if fallback == nil {
+				return nil, NewCodedInvalidArgument(
+					"unsupported_scheme",
+					ErrorDetails{"scheme": blobUrl.Scheme},
+					fmt.Sprintf(
+						"unsupported scheme '%s', only local files are supported",
+						blobUrl.Scheme,
+					),
+				)
+			}
+			return fallback(blob, credentials)
+		}
+
+		/*
+		 * Mirror how OpenVDS reads file urls, such that both 'file:///abs/path'
+		 * and 'file://relative/path' are understood.
+		 */
+		localPath := blob
+		if blobUrl.Scheme != "" {
+			localPath = blobUrl.Host + blobUrl.Path
+		}
+
+		localPath, err = resolveLocalPath(
+			root,
+			realRoot,
+			strings.TrimSuffix(localPath, "/"),
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		return NewFileConnection("file://" + localPath), nil
+	}
+}
+
+type S3Connection struct {
+	bucket   string
+	key      string
+	region   string
+	endpoint string
+	options  S3Options
+}
+
+func (c *S3Connection) Url() string {
+	return fmt.Sprintf("s3://%s/%s", c.bucket, c.key)
+}
+
+func (c *S3Connection) ConnectionString() string {
+	parameters := []struct {
+		key   string
+		value string
+	}{
+		{ "Region",           c.region               },
+		{ "EndpointOverride", c.endpoint             },
+		{ "AccessKeyId",      c.options.AccessKeyId  },
+		{ "SecretKey",        c.options.SecretKey    },
+		{ "SessionToken",     c.options.SessionToken },
+	}
+
+	var out []string
+	for _, parameter := range parameters {
+		if parameter.value == "" {
+			continue
+		}
+		out = append(out, fmt.Sprintf("%s=%s", parameter.key, parameter.value))
+	}
+	return strings.Join(out, ";")
+}
+
+/** Verify that the connection has enough access to read a VDS from S3
+ *
+ * Checking the credentials, be it those of the client or the server's own,
+ * would require signing a request towards S3, which we leave to OpenVDS.
+ * Instead the VDS is opened, which reads its VolumeDataLayout with the
+ * credentials of the connection, like the HEAD request for Azure.
+ */
+func (c *S3Connection) IsAuthorizedToRead() bool {
+	return canOpen(c)
+}
+
+func (c *S3Connection) describe() string {
+	return fmt.Sprintf("s3 bucket '%s'", c.bucket)
+}
+
+func NewS3Connection(
+	bucket   string,
+	key      string,
+	region   string,
+	endpoint string,
+	options  S3Options,
+) *S3Connection {
+	if options.Region != "" {
+		region = options.Region
+	}
+	return &S3Connection{
+		bucket:   bucket,
+		key:      key,
+		region:   region,
+		endpoint: endpoint,
+		options:  options,
+	}
+}
+
+type GSConnection struct {
+	bucket string
+	key    string
+}
+
+func (c *GSConnection) Url() string {
+	return fmt.Sprintf("gs://%s/%s", c.bucket, c.key)
+}
+
+func (c *GSConnection) ConnectionString() string {
+	return ""
+}
+
+/** Verify that the server's own credentials can read the VDS
+ *
+ * Google Cloud Storage is always accessed with the server's own (ambient)
+ * credentials, which may have lost access since the response was cached.
+ * Like S3, see S3Connection.IsAuthorizedToRead.
+ */
+func (c *GSConnection) IsAuthorizedToRead() bool {
+	return canOpen(c)
+}
+
+func (c *GSConnection) describe() string {
+	return fmt.Sprintf("gs bucket '%s'", c.bucket)
+}
+
+func NewGSConnection(bucket string, key string) *GSConnection {
+	return &GSConnection{ bucket: bucket, key: key }
+}
+
+/* Open and close the VDS, which reads it with the credentials of conn */
+func canOpen(conn Connection) bool {
+	handle, err := NewDSHandle(conn)
+	if err != nil {
+		return false
+	}
+	handle.Close()
+	return true
+}
+
+/*
+ * Split a bucket url, i.e. s3://<bucket>/<key> or gs://<bucket>/<key>, into
+ * bucket and key.
+ */
+func splitBucketUrl(blobUrl *url.URL) (string, string, error) {
+	bucket := blobUrl.Hostname()
+	key := strings.Trim(blobUrl.Path, "/")
+	if bucket == "" || key == "" {
+		return "", "", NewCodedInvalidArgument(
+			"invalid_url",
+			ErrorDetails{"scheme": blobUrl.Scheme},
+			fmt.Sprintf(
+				"invalid %s url, expected %s://<bucket>/<key>",
+				blobUrl.Scheme,
+				blobUrl.Scheme,
+			),
+		)
+	}
+	return bucket, key, nil
+}
+
+/** Make a ConnectionMaker for AWS S3
+ *
+ * Only buckets allowed by 'allowlist' are accepted, see MakeAzureConnection.
+ * Requests without credentials of their own are read with the server's
+ * credentials, and only from buckets that are explicitly listed, see
+ * Allowlist.checkListed. 'region' and 'endpoint' are passed on to OpenVDS, where
+ * 'endpoint' allows for S3-compatible storage other than AWS.
+ */
+func MakeS3Connection(
+	allowlist *Allowlist,
+	region    string,
+	endpoint  string,
+) ConnectionMaker {
+	return func(blob string, credentials Credentials) (Connection, error) {
+		blobUrl, err := makeUrl(blob)
+		if err != nil {
+			return nil, NewInvalidArgument(err.Error())
+		}
+
+		check := allowlist.check
+		if credentials.S3.AccessKeyId == "" {
+			check = allowlist.checkListed
+		}
+		if err := check(blobUrl); err != nil {
+			return nil, err
+		}
+
+		bucket, key, err := splitBucketUrl(blobUrl)
+		if err != nil {
+			return nil, err
+		}
+
+		if credentials.Sas != "" || credentials.BearerToken != "" {
+			return nil, NewCodedInvalidArgument(
+				"unsupported_credentials",
+				nil,
+				fmt.Sprintf(
+					"s3 bucket '%s': sas-tokens and bearer tokens are not " +
+					"supported for S3, use the s3 credentials instead",
+					bucket,
+				),
+			)
+		}
+
+		options := credentials.S3
+		if (options.AccessKeyId == "") != (options.SecretKey == "") {
+			return nil, NewCodedInvalidArgument(
+				"incomplete_credentials",
+				nil,
+				fmt.Sprintf(
+					"s3 bucket '%s': accessKeyId and secretKey must be given " +
+					"together",
+					bucket,
+				),
+			)
+		}
+
+		return NewS3Connection(bucket, key, region, endpoint, options), nil
+	}
+}
+
+/** Make a ConnectionMaker for Google Cloud Storage
+ *
+ * The server's own credentials are used, so only buckets that are explicitly
+ * listed by 'allowlist' are accepted, see Allowlist.checkListed. Any
+ * credentials in the request are rejected.
+ */
+func MakeGSConnection(allowlist *Allowlist) ConnectionMaker {
+	return func(blob string, credentials Credentials) (Connection, error) {
+		blobUrl, err := makeUrl(blob)
+		if err != nil {
+			return nil, NewInvalidArgument(err.Error())
+		}
+
+		if err := allowlist.checkListed(blobUrl); err != nil {
+			return nil, err
+		}
+
+		bucket, key, err := splitBucketUrl(blobUrl)
+		if err != nil {
+			return nil, err
+		}
+
+		if credentials != (Credentials{}) {
+			return nil, NewCodedInvalidArgument(
+				"unsupported_credentials",
+				nil,
+				fmt.Sprintf(
+					"gs bucket '%s': credentials in the request are not " +
+					"supported for Google Cloud Storage",
+					bucket,
+				),
+			)
+		}
+
+		return NewGSConnection(bucket, key), nil
+	}
+}
+
+/** Make a ConnectionMaker that dispatches on the url scheme
+ *
+ * 'makers' maps url schemes, e.g. 'https' or 's3', to the ConnectionMaker
+ * responsible for them. Schemes are case-insensitive.
+ */
+func MakeConnection(makers map[string]ConnectionMaker) ConnectionMaker {
+	return func(blob string, credentials Credentials) (Connection, error) {
+		blobUrl, err := url.Parse(blob)
+		if err != nil {
+			return nil, NewInvalidArgument(err.Error())
+		}
+
+		maker, ok := makers[strings.ToLower(blobUrl.Scheme)]
+		if !ok {
+			var supported []string
+			for scheme := range makers {
+				supported = append(supported, scheme)
+			}
+			sort.Strings(supported)
+			return nil, NewCodedInvalidArgument(
+				"unsupported_scheme",
+				ErrorDetails{"scheme": blobUrl.Scheme},
+				fmt.Sprintf(
+					"unsupported url scheme '%s', supported schemes are: %s",
+					blobUrl.Scheme,
+					strings.Join(supported, ", "),
+				),
+			)
+		}
+		return maker(blob, credentials)
+	}
+}
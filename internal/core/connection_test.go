@@ -0,0 +1,608 @@
+package core
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSasExpiry(t *testing.T) {
+	format := "2006-01-02T15:04:05Z"
+	past := time.Now().Add(-time.Hour).UTC().Format(format)
+	soon := time.Now().Add(time.Minute).UTC().Format(format)
+	future := time.Now().Add(24 * time.Hour).UTC().Format(format)
+
+	testCases := []struct {
+		name        string
+		sas         string
+		grace       time.Duration
+		shouldError bool
+		err         string
+	}{
+		{
+			name:        "Expired token",
+			sas:         fmt.Sprintf("sp=r&se=%s&sig=secret", past),
+			shouldError: true,
+		},
+		{
+			name:        "Token expires within grace period",
+			sas:         fmt.Sprintf("sp=r&se=%s&sig=secret", soon),
+			grace:       10 * time.Minute,
+			shouldError: true,
+			err:         "sas-token expired (or expires within 10m0s) at ",
+		},
+		{
+			name:        "Token expires after grace period",
+			sas:         fmt.Sprintf("sp=r&se=%s&sig=secret", future),
+			grace:       10 * time.Minute,
+			shouldError: false,
+		},
+		{
+			name:        "Date-only expiry",
+			sas:         "sp=r&se=2020-01-01&sig=secret",
+			shouldError: true,
+			err:         "sas-token expired at 2020-01-01T00:00:00Z",
+		},
+		{
+			name:        "No expiry",
+			sas:         "sp=r&sig=secret",
+			shouldError: false,
+		},
+		{
+			name:        "Unparsable expiry",
+			sas:         "sp=r&se=tomorrow&sig=secret",
+			shouldError: false,
+		},
+	}
+
+	for _, testCase := range testCases {
+		credentials := Credentials{Sas: testCase.sas}
+		conn := NewAzureConnection("blob", "container", "host", credentials)
+		err := validateSasExpiry(conn, testCase.grace)
+
+		if !testCase.shouldError {
+			require.NoErrorf(t, err, "[%s]", testCase.name)
+			continue
+		}
+
+		require.IsTypef(t, &UnauthorizedError{}, err, "[%s]", testCase.name)
+		require.ErrorContainsf(t, err, "expired", "[%s]", testCase.name)
+		require.ErrorContainsf(t, err, testCase.err, "[%s]", testCase.name)
+		require.NotContainsf(t, err.Error(), "secret",
+			"[%s] Error should not contain the token", testCase.name)
+	}
+}
+
+func TestAllowlist(t *testing.T) {
+	allowlist := []*url.URL{
+		parseAllowlistPattern("https://acct1.blob.core.windows.net/seismic/*"),
+		parseAllowlistPattern("https://acct2.blob.core.windows.net"),
+		parseAllowlistPattern("https://acct3.blob.core.windows.net/survey-*/vds"),
+	}
+
+	testCases := []struct {
+		name    string
+		url     string
+		allowed bool
+	}{
+		{
+			name:    "Blob in allowed container",
+			url:     "https://acct1.blob.core.windows.net/seismic/blob",
+			allowed: true,
+		},
+		{
+			name:    "Nested blob in allowed container",
+			url:     "https://acct1.blob.core.windows.net/seismic/dir/blob",
+			allowed: true,
+		},
+		{
+			name:    "Blob in other container",
+			url:     "https://acct1.blob.core.windows.net/private/blob",
+			allowed: false,
+		},
+		{
+			name:    "Container only",
+			url:     "https://acct1.blob.core.windows.net/seismic",
+			allowed: false,
+		},
+		{
+			name:    "Container is case-sensitive",
+			url:     "https://acct1.blob.core.windows.net/SEISMIC/blob",
+			allowed: false,
+		},
+		{
+			name:    "Upper-case scheme and host",
+			url:     "HTTPS://ACCT1.BLOB.CORE.WINDOWS.NET/seismic/blob",
+			allowed: true,
+		},
+		{
+			name:    "Fully qualified host",
+			url:     "https://acct1.blob.core.windows.net./seismic/blob",
+			allowed: true,
+		},
+		{
+			name:    "Other scheme",
+			url:     "http://acct1.blob.core.windows.net/seismic/blob",
+			allowed: false,
+		},
+		{
+			name:    "Allowed host as subdomain",
+			url:     "https://acct1.blob.core.windows.net.evil.com/seismic/blob",
+			allowed: false,
+		},
+		{
+			name:    "Allowed host as userinfo",
+			url:     "https://acct1.blob.core.windows.net@evil.com/seismic/blob",
+			allowed: false,
+		},
+		{
+			name:    "Allowed host in path",
+			url:     "https://evil.com/acct1.blob.core.windows.net/seismic/blob",
+			allowed: false,
+		},
+		{
+			name:    "Relative segments",
+			url:     "https://acct1.blob.core.windows.net/seismic/../private/blob",
+			allowed: false,
+		},
+		{
+			name:    "Encoded relative segments",
+			url:     "https://acct1.blob.core.windows.net/seismic/%2E%2E/private/blob",
+			allowed: false,
+		},
+		{
+			name:    "Any container in allowed account",
+			url:     "https://Acct2.blob.core.windows.net/any/blob",
+			allowed: true,
+		},
+		{
+			name:    "Wildcard in container",
+			url:     "https://acct3.blob.core.windows.net/survey-2023/vds/blob",
+			allowed: true,
+		},
+		{
+			name:    "Wildcard in container, wrong path",
+			url:     "https://acct3.blob.core.windows.net/survey-2023/other/blob",
+			allowed: false,
+		},
+		{
+			name:    "Unknown account",
+			url:     "https://acct4.blob.core.windows.net/seismic/blob",
+			allowed: false,
+		},
+	}
+
+	for _, testCase := range testCases {
+		requested, err := makeUrl(testCase.url)
+		require.NoErrorf(t, err, "[%s]", testCase.name)
+
+		err = isAllowed(allowlist, requested)
+		if testCase.allowed {
+			require.NoErrorf(t, err, "[%s]", testCase.name)
+			continue
+		}
+
+		require.IsTypef(t, &ForbiddenError{}, err, "[%s]", testCase.name)
+		require.ErrorContainsf(t, err, requested.Host, "[%s]", testCase.name)
+	}
+}
+
+func TestEmptyAllowlistAllowsAll(t *testing.T) {
+	requested, err := makeUrl("https://any.blob.core.windows.net/container/blob")
+	require.NoError(t, err)
+	require.NoError(t, isAllowed(nil, requested))
+}
+
+func TestMalformedAllowlistPattern(t *testing.T) {
+	patterns := []string{
+		"",
+		"acct.blob.core.windows.net",
+		"https://acct.blob.core.windows.net/[container",
+	}
+
+	for _, pattern := range patterns {
+		require.Panicsf(t, func() { parseAllowlistPattern(pattern) },
+			"Expected pattern '%s' to panic", pattern)
+	}
+}
+
+func TestAllowlistReplace(t *testing.T) {
+	requested, err := makeUrl("https://acct2.blob.core.windows.net/seismic/blob")
+	require.NoError(t, err)
+
+	allowlist, err := NewAllowlist([]string{"https://acct1.blob.core.windows.net"})
+	require.NoError(t, err)
+	require.IsType(t, &ForbiddenError{}, allowlist.check(requested))
+
+	err = allowlist.Set([]string{"https://acct2.blob.core.windows.net"})
+	require.NoError(t, err)
+	require.NoError(t, allowlist.check(requested))
+
+	err = allowlist.Set([]string{
+		"https://acct1.blob.core.windows.net",
+		"acct3.blob.core.windows.net",
+	})
+	require.ErrorContains(t, err, "must contain both scheme and host")
+	require.NoError(t, allowlist.check(requested),
+		"Expected the allowlist to be kept when a pattern is bad")
+
+	var unset *Allowlist
+	require.NoError(t, unset.check(requested))
+}
+
+func TestLocalConnection(t *testing.T) {
+	root, err := filepath.Abs("../../testdata")
+	require.NoError(t, err)
+
+	makeConnection := MakeLocalConnection("../../testdata", nil)
+
+	testCases := []struct {
+		name     string
+		blob     string
+		expected string
+	}{
+		{
+			name:     "Relative path",
+			blob:     "../../testdata/well_known/well_known_default.vds",
+			expected: "file://" + root + "/well_known/well_known_default.vds",
+		},
+		{
+			name:     "Absolute path",
+			blob:     root + "/well_known/well_known_default.vds",
+			expected: "file://" + root + "/well_known/well_known_default.vds",
+		},
+		{
+			name:     "File url",
+			blob:     "file://" + root + "/well_known/well_known_default.vds",
+			expected: "file://" + root + "/well_known/well_known_default.vds",
+		},
+		{
+			name:     "Relative file url",
+			blob:     "file://../../testdata/well_known/well_known_default.vds",
+			expected: "file://" + root + "/well_known/well_known_default.vds",
+		},
+		{
+			name:     "Trailing slash",
+			blob:     root + "/well_known/well_known_default.vds/",
+			expected: "file://" + root + "/well_known/well_known_default.vds",
+		},
+	}
+
+	for _, testCase := range testCases {
+		conn, err := makeConnection(testCase.blob, Credentials{Sas: "n/a"})
+		require.NoErrorf(t, err, "[%s]", testCase.name)
+		require.Equalf(t, testCase.expected, conn.Url(), "[%s]", testCase.name)
+	}
+}
+
+func TestLocalConnectionOutsideRoot(t *testing.T) {
+	root, err := filepath.Abs("../../testdata")
+	require.NoError(t, err)
+
+	makeConnection := MakeLocalConnection("../../testdata", nil)
+
+	blobs := []string{
+		"/etc/passwd",
+		"file:///etc/passwd",
+		root + "/../go.mod",
+		root + "/well_known/../../go.mod",
+		"file://" + root + "/%2E%2E/go.mod",
+		root + "-sibling/file.vds",
+		"https://account.blob.core.windows.net/container/blob",
+	}
+
+	for _, blob := range blobs {
+		_, err := makeConnection(blob, Credentials{Sas: "n/a"})
+		require.IsTypef(t, &InvalidArgument{}, err, "Expected '%s' to be rejected", blob)
+	}
+}
+
+func TestLocalConnectionSymlinks(t *testing.T) {
+	root := t.TempDir()
+	outside := t.TempDir()
+
+	require.NoError(t, os.Mkdir(filepath.Join(root, "cubes"), 0o755))
+	require.NoError(t, os.Symlink(outside, filepath.Join(root, "escape")))
+	require.NoError(t, os.Symlink(
+		filepath.Join(root, "cubes"),
+		filepath.Join(root, "inside"),
+	))
+
+	makeConnection := MakeLocalConnection(root, nil)
+
+	rejected := []string{
+		root + "/escape/file.vds",
+		"file://" + root + "/escape/file.vds",
+		root + "/escape",
+	}
+	for _, blob := range rejected {
+		_, err := makeConnection(blob, Credentials{})
+		require.IsTypef(t, &InvalidArgument{}, err, "Expected '%s' to be rejected", blob)
+		require.Equal(t, "path_outside_root", err.(*InvalidArgument).Code())
+	}
+
+	accepted := []string{
+		root + "/inside/file.vds",
+		root + "/cubes/missing/file.vds",
+	}
+	for _, blob := range accepted {
+		_, err := makeConnection(blob, Credentials{})
+		require.NoErrorf(t, err, "Expected '%s' to be accepted", blob)
+	}
+}
+
+func TestLocalConnectionFallback(t *testing.T) {
+	fallbackCalled := false
+	fallback := func(blob string, credentials Credentials) (Connection, error) {
+		fallbackCalled = true
+		return NewAzureConnection("blob", "container", "host", credentials), nil
+	}
+
+	makeConnection := MakeLocalConnection("../../testdata", fallback)
+	_, err := makeConnection(
+		"https://account.blob.core.windows.net/container/blob",
+		Credentials{Sas: "sas"},
+	)
+	require.NoError(t, err)
+	require.True(t, fallbackCalled)
+}
+
+func TestAzureConnection(t *testing.T) {
+	testCases := []struct {
+		name     string
+		blob     string
+		endpoint string
+		expected string
+	}{
+		{
+			name:     "Public cloud",
+			blob:     "https://account.blob.core.windows.net/container/path/to/vds",
+			expected: "BlobEndpoint=https://account.blob.core.windows.net;AccountName=account",
+		},
+		{
+			name:     "Sovereign cloud",
+			blob:     "https://account.blob.core.usgovcloudapi.net/container/path/to/vds",
+			expected: "BlobEndpoint=https://account.blob.core.usgovcloudapi.net;AccountName=account",
+		},
+		{
+			name:     "Private link",
+			blob:     "https://account.privatelink.blob.core.windows.net/container/path/to/vds",
+			expected: "BlobEndpoint=https://account.privatelink.blob.core.windows.net;AccountName=account",
+		},
+		{
+			name:     "Trailing slash",
+			blob:     "https://account.blob.core.windows.net/container/path/to/vds/",
+			expected: "BlobEndpoint=https://account.blob.core.windows.net;AccountName=account",
+		},
+		{
+			name:     "Ip address",
+			blob:     "https://10.0.0.4/container/path/to/vds",
+			expected: "BlobEndpoint=https://10.0.0.4",
+		},
+		{
+			name:     "Endpoint override",
+			blob:     "https://account.blob.core.windows.net/container/path/to/vds",
+			endpoint: "https://{account}.privatelink.blob.core.windows.net/",
+			expected: "BlobEndpoint=https://account.privatelink.blob.core.windows.net;AccountName=account",
+		},
+		{
+			name:     "Fixed endpoint override",
+			blob:     "https://account.blob.core.windows.net/container/path/to/vds",
+			endpoint: "https://10.0.0.4",
+			expected: "BlobEndpoint=https://10.0.0.4;AccountName=account",
+		},
+	}
+
+	for _, testCase := range testCases {
+		makeConnection := MakeAzureConnection(nil, 0, testCase.endpoint)
+		conn, err := makeConnection(testCase.blob, Credentials{Sas: "sp=r&sig=sas"})
+		require.NoErrorf(t, err, "[%s]", testCase.name)
+		require.Equalf(t, "azure://container/path/to/vds", conn.Url(),
+			"[%s]", testCase.name)
+		require.Equalf(t,
+			testCase.expected+";SharedAccessSignature=?sp=r&sig=sas",
+			conn.ConnectionString(),
+			"[%s]", testCase.name,
+		)
+	}
+}
+
+func TestAzureConnectionErrors(t *testing.T) {
+	blobs := []string{
+		"https://account.blob.core.windows.net",
+		"https://account.blob.core.windows.net/container",
+		"https://account.blob.core.windows.net/container/",
+		"https:///container/blob",
+	}
+
+	makeConnection := MakeAzureConnection(nil, 0, "")
+	for _, blob := range blobs {
+		_, err := makeConnection(blob, Credentials{Sas: "sp=r&sig=sas"})
+		require.IsTypef(t, &InvalidArgument{}, err, "Expected '%s' to be rejected", blob)
+	}
+
+	makeConnection = MakeAzureConnection(
+		nil,
+		0,
+		"https://{account}.privatelink.blob.core.windows.net",
+	)
+	_, err := makeConnection(
+		"https://10.0.0.4/container/blob",
+		Credentials{Sas: "sp=r&sig=sas"},
+	)
+	require.IsType(t, &InvalidArgument{}, err)
+}
+
+func TestMalformedBlobEndpoint(t *testing.T) {
+	endpoints := []string{
+		"account.privatelink.blob.core.windows.net",
+		"https://account.privatelink.blob.core.windows.net/container",
+		"https://account.privatelink.blob.core.windows.net?sas",
+	}
+
+	for _, endpoint := range endpoints {
+		require.Panicsf(t, func() { MakeAzureConnection(nil, 0, endpoint) },
+			"Expected '%s' to panic", endpoint)
+	}
+}
+
+func TestS3Connection(t *testing.T) {
+	allowlist, err := NewAllowlist([]string{"s3://bucket"})
+	require.NoError(t, err)
+
+	makeConnection := MakeS3Connection(allowlist, "eu-north-1", "")
+
+	testCases := []struct {
+		name       string
+		blob       string
+		options    S3Options
+		expected   string
+	}{
+		{
+			name:     "Ambient credentials",
+			blob:     "s3://bucket/path/to/vds",
+			expected: "Region=eu-north-1",
+		},
+		{
+			name:     "Region from request",
+			blob:     "s3://bucket/path/to/vds",
+			options:  S3Options{Region: "us-east-1"},
+			expected: "Region=us-east-1",
+		},
+		{
+			name:    "Credentials from request",
+			blob:    "s3://bucket/path/to/vds",
+			options: S3Options{
+				AccessKeyId:  "id",
+				SecretKey:    "secret",
+				SessionToken: "session",
+			},
+			expected: "Region=eu-north-1;AccessKeyId=id;SecretKey=secret;SessionToken=session",
+		},
+	}
+
+	for _, testCase := range testCases {
+		conn, err := makeConnection(testCase.blob, Credentials{S3: testCase.options})
+		require.NoErrorf(t, err, "[%s]", testCase.name)
+		require.Equalf(t, "s3://bucket/path/to/vds", conn.Url(), "[%s]", testCase.name)
+		require.Equalf(t, testCase.expected, conn.ConnectionString(),
+			"[%s]", testCase.name)
+	}
+}
+
+func TestAmbientCredentialsRequireAllowlist(t *testing.T) {
+	empty, err := NewAllowlist([]string{})
+	require.NoError(t, err)
+
+	for _, allowlist := range []*Allowlist{nil, empty} {
+		_, err := MakeS3Connection(allowlist, "", "")("s3://bucket/vds", Credentials{})
+		require.IsType(t, &ForbiddenError{}, err)
+		require.ErrorContains(t, err, "s3 bucket 'bucket'")
+
+		_, err = MakeGSConnection(allowlist)("gs://bucket/vds", Credentials{})
+		require.IsType(t, &ForbiddenError{}, err)
+		require.ErrorContains(t, err, "gs bucket 'bucket'")
+
+		/* The client's own credentials are not limited to listed buckets */
+		credentials := Credentials{S3: S3Options{AccessKeyId: "id", SecretKey: "secret"}}
+		_, err = MakeS3Connection(allowlist, "", "")("s3://bucket/vds", credentials)
+		require.NoError(t, err)
+	}
+}
+
+func TestS3ConnectionErrors(t *testing.T) {
+	allowlist, err := NewAllowlist([]string{"s3://allowed"})
+	require.NoError(t, err)
+
+	makeConnection := MakeS3Connection(allowlist, "eu-north-1", "")
+
+	testCases := []struct {
+		name        string
+		blob        string
+		credentials Credentials
+		expected    error
+	}{
+		{
+			name:     "Missing key",
+			blob:     "s3://allowed",
+			expected: &InvalidArgument{},
+		},
+		{
+			name:     "Bucket not on allowlist",
+			blob:     "s3://other/vds",
+			expected: &ForbiddenError{},
+		},
+		{
+			name:        "Sas token",
+			blob:        "s3://allowed/vds",
+			credentials: Credentials{Sas: "sp=r&sig=secret"},
+			expected:    &InvalidArgument{},
+		},
+		{
+			name:        "Secret key without access key",
+			blob:        "s3://allowed/vds",
+			credentials: Credentials{S3: S3Options{SecretKey: "secret"}},
+			expected:    &InvalidArgument{},
+		},
+	}
+
+	for _, testCase := range testCases {
+		_, err := makeConnection(testCase.blob, testCase.credentials)
+		require.IsTypef(t, testCase.expected, err, "[%s]", testCase.name)
+		require.NotContainsf(t, err.Error(), "secret", "[%s]", testCase.name)
+	}
+}
+
+func TestGSConnection(t *testing.T) {
+	allowlist, err := NewAllowlist([]string{"gs://bucket"})
+	require.NoError(t, err)
+
+	makeConnection := MakeGSConnection(allowlist)
+
+	conn, err := makeConnection("gs://bucket/path/to/vds", Credentials{})
+	require.NoError(t, err)
+	require.Equal(t, "gs://bucket/path/to/vds", conn.Url())
+	require.Equal(t, "", conn.ConnectionString())
+
+	_, err = makeConnection("gs://bucket/path/to/vds", Credentials{Sas: "sas"})
+	require.IsType(t, &InvalidArgument{}, err)
+	require.ErrorContains(t, err, "gs bucket 'bucket'")
+}
+
+func TestConnectionSchemeDispatch(t *testing.T) {
+	allowlist, err := NewAllowlist([]string{"s3://bucket", "gs://bucket"})
+	require.NoError(t, err)
+
+	makeConnection := MakeConnection(map[string]ConnectionMaker{
+		"s3": MakeS3Connection(allowlist, "", ""),
+		"gs": MakeGSConnection(allowlist),
+	})
+
+	conn, err := makeConnection("S3://bucket/vds", Credentials{})
+	require.NoError(t, err)
+	require.IsType(t, &S3Connection{}, conn)
+
+	conn, err = makeConnection("gs://bucket/vds", Credentials{})
+	require.NoError(t, err)
+	require.IsType(t, &GSConnection{}, conn)
+
+	_, err = makeConnection("ftp://host/vds", Credentials{})
+	require.IsType(t, &InvalidArgument{}, err)
+	require.ErrorContains(t, err, "gs, s3")
+}
+
+func TestOpenErrorNamesBucket(t *testing.T) {
+	conn := NewS3Connection("bucket", "vds", "", "", S3Options{})
+	err := classifyOpenError(
+		conn,
+		describeOpenError(conn, "Could not open VDS: NoSuchKey"),
+	)
+
+	require.IsType(t, &NotFoundError{}, err)
+	require.Equal(t, "Could not open VDS in s3 bucket 'bucket': NoSuchKey", err.Error())
+}
@@ -9,9 +9,13 @@ package core
 */
 import "C"
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
+	"math"
+	"strconv"
 	"strings"
+	"time"
 	"unsafe"
 )
 
@@ -46,11 +50,21 @@ type Axis struct {
 	// Number of samples along the axis
 	Samples int `json:"samples" example:"1600"`
 
-	// Distance from one sample to the next
+	// Distance from one sample to the next, in annotated values. E.g. an
+	// inline axis numbered 1, 3, 5 has a stepsize of 2. Linenos are given
+	// by min + n * stepsize.
 	StepSize float64 `json:"stepsize" example:"4.0"`
 
-	// Axis units
+	// Whether the annotations are evenly spaced by stepsize. False for axes
+	// of a single sample, which have no stepsize.
+	Regular bool `json:"regular" example:"true"`
+
+	// Axis units, normalized to one of: ms, s, us, m, ft, usft, m/s, ft/s,
+	// usft/s or unitless. Units that are not recognized are given as is.
 	Unit string `json:"unit" example:"ms"`
+
+	// Axis units as given by the VDS
+	RawUnit string `json:"rawUnit" example:"Milliseconds"`
 } // @name Axis
 
 // @Description Geometrical plane with depth/time datapoints
@@ -80,6 +94,21 @@ type RegularSurface struct {
 	// Additionally, the fillValue is used for any point of the surface that
 	// falls outside the bounds of the seismic volume.
 	FillValue *float32 `json:"fillValue" binding:"required" example:"-999.25"`
+
+	// Only use every rowStep'th row of values, starting with the first, e.g.
+	// for a coarser attribute map of a large surface. The increment between
+	// the rows, yinc, is scaled accordingly. A trailing partial step is
+	// dropped, i.e. a surface of 10 rows with rowStep 4 is decimated to rows
+	// 0 and 4.
+	//
+	// Defaults to 1, i.e. every row
+	RowStep *int `json:"rowStep,omitempty" example:"4"`
+
+	// Only use every colStep'th column of values. Like rowStep, but for the
+	// columns and xinc.
+	//
+	// Defaults to 1, i.e. every column
+	ColStep *int `json:"colStep,omitempty" example:"4"`
 } // @name RegularSurface
 
 // @Description The bounding box of the survey, defined by its 4 corner
@@ -112,8 +141,73 @@ type Bound struct {
 	// Upper bound - inclusive
 	// Upper bound must be greater or equal to lower bound
 	Upper *int `json:"upper" binding:"required" example:"200"`
+
+	// How lower and upper are interpreted. Valid options: index and
+	// annotation. Defaults to index for i, j and k, and annotation for the
+	// other directions, as for SliceRequest.LinenoMode. Annotations are
+	// line numbers or vertical values, e.g. inline 1, 3 or 5 for a cube with
+	// inlines 1 to 5 in steps of 2, where the indices are 0, 1 and 2.
+	Mode string `json:"mode,omitempty" example:"annotation"`
 } // @name SliceBound
 
+/** A fill value, which unlike plain json numbers can be NaN
+ *
+ * json has no NaN, so it is written as the string "nan" instead. The string
+ * is case-insensitive when read. The type is only used for fields named
+ * fillValue, which lets errors name the field, as encoding/json does not
+ * tell unmarshalers where in the document they are.
+ */
+type FillValue float32
+
+func (f *FillValue) UnmarshalJSON(data []byte) error {
+	var value float32
+	if err := json.Unmarshal(data, &value); err == nil {
+		*f = FillValue(value)
+		return nil
+	}
+
+	var str string
+	if err := json.Unmarshal(data, &str); err == nil && strings.EqualFold(str, "nan") {
+		*f = FillValue(math.NaN())
+		return nil
+	}
+
+	got := string(data)
+	if len(got) > 40 {
+		got = got[:40] + "..."
+	}
+	return NewCodedInvalidArgument(
+		"type_mismatch",
+		ErrorDetails{"field": "fillValue"},
+		fmt.Sprintf("field 'fillValue' must be a number or \"nan\", got %s", got),
+	)
+}
+
+func (f FillValue) MarshalJSON() ([]byte, error) {
+	if math.IsNaN(float64(f)) {
+		return []byte(`"nan"`), nil
+	}
+	return json.Marshal(float32(f))
+}
+
+/** The fill value a request is answered with
+ *
+ * The first of the candidates that is given, in order of precedence, e.g.
+ * the fill value of the request before the default of the server. Nil if
+ * none is given, in which case absent data is left as stored by OpenVDS.
+ *
+ * Every endpoint replaces absent data, such as dead traces, with the
+ * resolved fill value, and reports it as fillValue in the metadata.
+ */
+func ResolveFillValue(candidates ...*float32) *float32 {
+	for _, candidate := range candidates {
+		if candidate != nil {
+			return candidate
+		}
+	}
+	return nil
+}
+
 // @Description Slice metadata
 type SliceMetadata struct {
 	Array
@@ -133,6 +227,11 @@ type SliceMetadata struct {
 	// is a linestring, while for time/depth slices this is a polygon. If the
 	// slice is not cropped, the polygon is the bounding box of the cube.
 	Geospatial [][]float64 `json:"geospatial"`
+
+	// The value absent data is replaced by, as given in the request. NaN is
+	// given as the string "nan". Null if no fill value was requested, in
+	// which case absent data is as stored by OpenVDS.
+	FillValue *FillValue `json:"fillValue" swaggertype:"number" example:"-999.25"`
 } // @name SliceMetadata
 
 // @Description Metadata
@@ -143,8 +242,14 @@ type Metadata struct {
 	// The original input file name
 	InputFileName string `json:"inputFileName" example:"file.segy"`
 
-	// Import time stamp in ISO8601 format
-	ImportTimeStamp string `json:"importTimeStamp" example:"2021-02-18T21:54:42.123Z"`
+	// Import time stamp in ISO8601 format. Left out if the VDS does not
+	// have it.
+	ImportTimeStamp string `json:"importTimeStamp,omitempty" example:"2021-02-18T21:54:42.123Z"`
+
+	// Textual header of the SEG-Y the VDS was imported from, as 3200
+	// characters (40 lines of 80), decoded from EBCDIC where needed. Only
+	// given with includeImportInfo, and left out if the VDS does not have it.
+	SegyTextHeader string `json:"segyTextHeader,omitempty" example:"C 1 CLIENT..."`
 
 	// Bounding box
 	BoundingBox BoundingBox `json:"boundingBox"`
@@ -153,130 +258,598 @@ type Metadata struct {
 	//
 	// Describes the axes of the requested 2-dimensional slice.
 	Axis []*Axis `json:"axis"`
+
+	// How the VDS is laid out in storage. Only given with includeLayout.
+	Layout *Layout `json:"layout,omitempty"`
+
+	// The trace header channels of the VDS, which fences can return as
+	// headerFields. Only given for VDSs that have any.
+	HeaderChannels []string `json:"headerChannels,omitempty" example:"CDP,Offset"`
+
+	// The format the data is stored in. Data is returned as float32
+	// regardless, see raw of the SliceRequest model for the stored values.
+	StoredFormat StoredFormat `json:"storedFormat"`
 } // @name Metadata
 
+// @Description The format the data of a VDS is stored in
+type StoredFormat struct {
+	// Format of the stored values, one of 1Bit, U8, U16, U32, U64, R32 or
+	// R64
+	Format string `json:"format" example:"U8"`
+
+	// Stored integers map to the values they represent as
+	// value = stored * integerScale + integerOffset. 1 for data stored as
+	// floats.
+	IntegerScale float64 `json:"integerScale" example:"0.1"`
+
+	// See integerScale. 0 for data stored as floats.
+	IntegerOffset float64 `json:"integerOffset" example:"100"`
+} // @name StoredFormat
+
+// @Description The storage layout of a VDS
+type Layout struct {
+	// Size of the bricks the VDS is stored in, in samples, along every axis
+	// in the same order as axis
+	BrickSize []int `json:"brickSize" example:"64,64,64"`
+
+	// Number of levels of detail stored in addition to the full resolution
+	LodLevels int `json:"lodLevels" example:"4"`
+
+	// Compression method of the bricks, e.g. None, Zip or Wavelet. Left out
+	// if the method is unknown.
+	Compression string `json:"compression,omitempty" example:"None"`
+
+	// Error tolerance of the compression. Only given for lossy methods.
+	CompressionTolerance *float32 `json:"compressionTolerance,omitempty" example:"0.01"`
+
+	// Every channel stored in the VDS, the first of which is the data
+	Channels []Channel `json:"channels"`
+} // @name Layout
+
+// @Description A channel of a VDS
+type Channel struct {
+	// Name of the channel
+	Name string `json:"name" example:"Amplitude"`
+
+	// Format the values are stored in, e.g. U8 or R32
+	Format string `json:"format,omitempty" example:"R32"`
+
+	// Unit of the values. Left out if the channel has none.
+	Unit string `json:"unit,omitempty" example:"ms"`
+
+	// The range of the values, as estimated on import
+	ValueRange [2]float32 `json:"valueRange" example:"-1,1"`
+
+	// The value that marks absent data. Left out if the channel has none.
+	NoValue *float32 `json:"noValue,omitempty" example:"-999.25"`
+} // @name Channel
+
 // @Description Fence metadata
 type FenceMetadata struct {
 	Array
+
+	// The (i, j) index of the trace every coordinate snapped to, for the
+	// nearest_trace interpolation only. Coordinates outside of the survey
+	// snapped to no trace, which is given as null.
+	Indices [][]int `json:"indices,omitempty" swaggertype:"array,array,integer"`
+
+	// The interval between samples of the returned traces, in the vertical
+	// unit of the request. Only given for fences resampled with resampleTo,
+	// where the number of samples per trace is the second dimension of shape.
+	SampleInterval *float32 `json:"sampleInterval,omitempty" example:"1"`
+
+	// The value absent data, and coordinates outside of the cube, are
+	// replaced by, as given in the request. NaN is given as the string
+	// "nan". Null if no fill value was requested, in which case absent data
+	// is as stored by OpenVDS.
+	FillValue *FillValue `json:"fillValue" swaggertype:"number" example:"-999.25"`
+
+	// The trace headers, the second data part. Only given for fences that
+	// ask for headerFields.
+	Headers *FenceHeaders `json:"headers,omitempty"`
 } // @name FenceMetadata
 
+// @Description Trace headers of a fence
+type FenceHeaders struct {
+	// One row per coordinate, and one column per field, from the trace
+	// nearest to the coordinate
+	Array
+
+	// The header field of every column, as named in the request
+	Fields []string `json:"fields" example:"CDP,Offset"`
+} // @name FenceHeaders
+
+// @Description Sample metadata
+type SampleMetadata struct {
+	// The sampled values, the first data part. One value per point, in the
+	// order of the points.
+	Array
+
+	// The validity flags, the second data part. One byte per point, which
+	// is 1 where the point was sampled, and 0 where it is outside of the
+	// cube or where the data is absent.
+	Valid Array `json:"valid"`
+
+	// The value given for every point that is not valid. NaN is given as
+	// the string "nan".
+	FillValue *FillValue `json:"fillValue" swaggertype:"number" example:"-999.25"`
+} // @name SampleMetadata
+
 // @Description Attribute metadata
 type AttributeMetadata struct {
+	// Deprecated: use the shape and format of every part in parts, as
+	// attributes may come to differ in shape. Kept for older clients.
 	Array
+
+	// A descriptor of every attribute data part, in the order of the parts.
+	// Only given along with the attributes.
+	Parts []AttributePart `json:"parts,omitempty"`
+
+	// The increments of the surface the attributes were computed on. Only
+	// given for decimated surfaces, i.e. with rowStep or colStep, where they
+	// are the increments of the request scaled by the steps.
+	Xinc *float32 `json:"xinc,omitempty" example:"32.48"`
+	Yinc *float32 `json:"yinc,omitempty" example:"-4.08"`
+
+	// The outcome of every requested attribute, in the order they were
+	// requested. Only given for partial requests, where there is a data part
+	// for every attribute with status "ok", in order.
+	Attributes []AttributeStatus `json:"attributes,omitempty"`
+
+	// The band of rows the attributes cover, for requests with rowOffset.
+	// The shape is then that of the band rather than the whole surface.
+	Band *AttributeBand `json:"band,omitempty"`
+
+	// The surface fill value, which is given for every node where the
+	// attributes could not be computed. That is nodes where the surface
+	// itself is missing, outside of the polygon, and nodes with too little
+	// data in the window, see minValidFraction.
+	FillValue *FillValue `json:"fillValue" swaggertype:"number" example:"-999.25"`
 } // @name AttributeMetadata
 
-func GetAxis(direction string) (int, error) {
-	switch direction {
-	case "i":
-		return AxisI, nil
-	case "j":
-		return AxisJ, nil
-	case "k":
-		return AxisK, nil
-	case "inline":
-		return AxisInline, nil
-	case "crossline":
-		return AxisCrossline, nil
-	case "depth":
-		return AxisDepth, nil
-	case "time":
-		return AxisTime, nil
-	case "sample":
-		return AxisSample, nil
-	default:
+// @Description Where a band of rows is in the surface the attributes are
+// @Description computed on
+type AttributeBand struct {
+	// The first row of the band
+	RowOffset int `json:"rowOffset" example:"1000"`
+
+	// The number of rows in the band
+	RowCount int `json:"rowCount" example:"500"`
+
+	// The number of rows in the whole surface, after decimation
+	Nrows int `json:"nrows" example:"4000"`
+} // @name AttributeBand
+
+// @Description A single attribute data part of a response
+type AttributePart struct {
+	// The attribute, by its lowercase name
+	Name string `json:"name" example:"rms"`
+
+	Array
+
+	// How many nodes of the attribute have a value, and why the rest were
+	// given the fill value
+	Coverage AttributeCoverage `json:"coverage"`
+} // @name AttributePart
+
+// @Description How many nodes of an attribute have a value, and why the rest
+// @Description were given the fill value
+type AttributeCoverage struct {
+	// The number of nodes in the part, i.e. in the surface or band
+	Total int `json:"total" example:"1000"`
+
+	// The number of nodes with a value
+	Valid int `json:"valid" example:"900"`
+
+	// The nodes given the fill value, by reason. These add up to total -
+	// valid.
+	Fill AttributeFillCounts `json:"fill"`
+} // @name AttributeCoverage
+
+// @Description The number of nodes given the fill value, by reason
+type AttributeFillCounts struct {
+	// The surface itself is missing at the node, or the node is outside of
+	// the polygon. Between surfaces, either surface may be missing.
+	MissingSurface int `json:"missingSurface" example:"40"`
+
+	// The node is outside of the survey
+	OutsideSurvey int `json:"outsideSurvey" example:"50"`
+
+	// There is no data in the window at all, e.g. for dead traces
+	DeadTraces int `json:"deadTraces" example:"10"`
+
+	// There is some data in the window, but less than minValidFraction of it
+	BelowMinValidFraction int `json:"belowMinValidFraction" example:"0"`
+
+	// There is enough data in the window, but the attribute is still
+	// undefined, e.g. value when the sample at the surface itself is absent
+	Undefined int `json:"undefined" example:"0"`
+} // @name AttributeFillCounts
+
+// @Description The outcome of a single attribute of a partial request
+type AttributeStatus struct {
+	// The attribute, as requested
+	Name string `json:"name" example:"min"`
+
+	// "ok", or why the attribute was rejected
+	Status string `json:"status" example:"ok"`
+} // @name AttributeStatus
+
+/** A named option accepted by the API
+ *
+ * The lists of options below are the single source of truth for which
+ * directions, coordinate systems, interpolation methods and attributes core
+ * understands. They are used both to look up the C enum values and to report
+ * the valid options back to the user, be that in error messages or through
+ * the capabilities of the server.
+ */
+type option struct {
+	name  string
+	value int
+}
+
+var axisOptions = []option{
+	{"i", AxisI},
+	{"j", AxisJ},
+	{"k", AxisK},
+	{"inline", AxisInline},
+	{"crossline", AxisCrossline},
+	{"depth", AxisDepth},
+	{"time", AxisTime},
+	{"sample", AxisSample},
+}
+
+var linenoModeOptions = []option{
+	{"index", CoordinateSystemIndex},
+	{"annotation", CoordinateSystemAnnotation},
+}
+
+var coordinateSystemOptions = []option{
+	{"ij", CoordinateSystemIndex},
+	{"ilxl", CoordinateSystemAnnotation},
+	{"cdp", CoordinateSystemCdp},
+}
+
+var interpolationOptions = []option{
+	{"nearest", C.NEAREST},
+	{"linear", C.LINEAR},
+	{"cubic", C.CUBIC},
+	{"angular", C.ANGULAR},
+	{"triangular", C.TRIANGULAR},
+}
+
+/*
+ * Fences can in addition be read from the nearest trace, uninterpolated, or
+ * not interpolated at all, which requires every coordinate to be at a trace
+ */
+var fenceInterpolationOptions = append(
+	append([]option{}, interpolationOptions...),
+	option{"nearest_trace", C.NEAREST_TRACE},
+	option{"none", C.NO_INTERPOLATION},
+)
+
+/* The interpolation method of requests that do not give one, see Defaults */
+const defaultInterpolation = "nearest"
+
+/* The vertical interpolation method of requests that do not give one */
+const defaultVerticalInterpolation = "cubic"
+
+/* The subset of interpolation methods that apply along a single trace */
+var verticalInterpolationOptions = []option{
+	{"nearest", C.NEAREST},
+	{"linear", C.LINEAR},
+	{"cubic", C.CUBIC},
+}
+
+var attributeOptions = []option{
+	{"samplevalue", C.VALUE},
+	{"min", C.MIN},
+	{"min_at", C.MINAT},
+	{"max", C.MAX},
+	{"max_at", C.MAXAT},
+	{"maxabs", C.MAXABS},
+	{"maxabs_at", C.MAXABSAT},
+	{"maxabs_signed", C.MAXABSSIGNED},
+	{"mean", C.MEAN},
+	{"meanabs", C.MEANABS},
+	{"meanpos", C.MEANPOS},
+	{"meanneg", C.MEANNEG},
+	{"median", C.MEDIAN},
+	{"rms", C.RMS},
+	{"var", C.VAR},
+	{"sd", C.SD},
+	{"sumpos", C.SUMPOS},
+	{"sumneg", C.SUMNEG},
+	{"sum", C.SUM},
+	{"mad", C.MAD},
+}
+
+func lookupOption(options []option, name string) (int, bool) {
+	for _, option := range options {
+		if option.name == name {
+			return option.value, true
+		}
+	}
+	return -1, false
+}
+
+func optionNames(options []option) []string {
+	names := make([]string, len(options))
+	for i, option := range options {
+		names[i] = option.name
+	}
+	return names
+}
+
+/** Join options as a human readable enumeration, e.g. "a, b or c" */
+func enumerate(options []string) string {
+	if len(options) < 2 {
+		return strings.Join(options, "")
+	}
+	last := len(options) - 1
+	return strings.Join(options[:last], ", ") + " or " + options[last]
+}
+
+// Valid options for SliceRequest.Direction and Bound.Direction
+func Directions() []string {
+	return optionNames(axisOptions)
+}
+
+// The direction of axis in the fixed vocabulary, the inverse of GetAxis
+func AxisName(axis int) string {
+	for _, option := range axisOptions {
+		if option.value == axis {
+			return option.name
+		}
+	}
+	return ""
+}
+
+// Valid options for SliceRequest.LinenoMode. The empty string defaults to
+// the convention of the direction and is not listed.
+func LinenoModes() []string {
+	return optionNames(linenoModeOptions)
+}
+
+// Valid options for FenceRequest.CoordinateSystem
+func CoordinateSystems() []string {
+	return optionNames(coordinateSystemOptions)
+}
+
+// Valid interpolation methods. The empty string defaults to nearest and is
+// not listed.
+func InterpolationMethods() []string {
+	return optionNames(interpolationOptions)
+}
+
+// Valid interpolation methods for fences. The empty string defaults to
+// nearest and is not listed.
+func FenceInterpolationMethods() []string {
+	return optionNames(fenceInterpolationOptions)
+}
+
+// Valid vertical interpolation methods for the attribute endpoints. The
+// empty string defaults to cubic and is not listed.
+func VerticalInterpolationMethods() []string {
+	return optionNames(verticalInterpolationOptions)
+}
+
+// Valid attributes for the attribute endpoints
+func AttributeTypes() []string {
+	return optionNames(attributeOptions)
+}
+
+/** The axis of a direction
+ *
+ * Besides the fixed vocabulary of axisOptions, a direction can refer to one
+ * of the given axes of a cube, as listed in its metadata. Either by the
+ * position of the axis in the list, e.g. "0", or by its annotation, ignoring
+ * case, e.g. "Inline". Positions are read as i, j and k, and annotations as
+ * inline, crossline and sample, such that linenos default to indices and
+ * annotations respectively. The fixed vocabulary takes precedence, so a cube
+ * with an axis annotated "I" can not be sliced along it by annotation.
+ */
+func GetAxis(direction string, axes ...*Axis) (int, error) {
+	axis, ok := lookupOption(axisOptions, direction)
+	if ok {
+		return axis, nil
+	}
+
+	axis, ok = lookupCubeAxis(axes, direction)
+	if !ok {
 		options := "i, j, k, inline, crossline or depth/time/sample"
+		if len(axes) > 0 {
+			options = fmt.Sprintf(
+				"i, j, k, inline, crossline, depth/time/sample, "+
+					"the axis annotations of the cube (%s) or the axis "+
+					"positions 0 to %d",
+				strings.Join(axisAnnotations(axes), ", "),
+				len(axes)-1,
+			)
+		}
 		msg := "invalid direction '%s', valid options are: %s"
-		return -1, NewInvalidArgument(fmt.Sprintf(msg, direction, options))
+		return -1, NewCodedInvalidArgument(
+			"invalid_direction",
+			ErrorDetails{"direction": direction},
+			fmt.Sprintf(msg, direction, options),
+		)
 	}
+	return axis, nil
+}
+
+/*
+ * The axis at a position, or with an annotation, in the axes of a cube. A 2D
+ * line has no inline axis, and its axes are crossline and sample.
+ */
+func lookupCubeAxis(axes []*Axis, direction string) (int, bool) {
+	indexAxes := []int{AxisI, AxisJ, AxisK}
+	annotationAxes := []int{AxisInline, AxisCrossline, AxisSample}
+	if len(axes) == 0 || len(axes) > len(indexAxes) {
+		return -1, false
+	}
+	indexAxes = indexAxes[len(indexAxes)-len(axes):]
+	annotationAxes = annotationAxes[len(annotationAxes)-len(axes):]
+
+	position, err := strconv.Atoi(strings.TrimSpace(direction))
+	if err == nil {
+		if position < 0 || position >= len(axes) {
+			return -1, false
+		}
+		return indexAxes[position], true
+	}
+
+	for i, axis := range axes {
+		if strings.EqualFold(axis.Annotation, direction) {
+			return annotationAxes[i], true
+		}
+	}
+	return -1, false
+}
+
+func axisAnnotations(axes []*Axis) []string {
+	annotations := make([]string, len(axes))
+	for i, axis := range axes {
+		annotations[i] = axis.Annotation
+	}
+	return annotations
+}
+
+/** How the lineno of a slice along axis is interpreted
+ *
+ * Linenos are indices along i, j and k, and annotations along the other
+ * directions, unless mode says otherwise.
+ */
+func GetLinenoSystem(axis int, mode string) (int, error) {
+	if mode == "" {
+		switch axis {
+		case AxisI, AxisJ, AxisK:
+			return CoordinateSystemIndex, nil
+		default:
+			return CoordinateSystemAnnotation, nil
+		}
+	}
+
+	system, ok := lookupOption(linenoModeOptions, strings.ToLower(mode))
+	if !ok {
+		options := enumerate(LinenoModes())
+		msg := "invalid lineno mode '%s', valid options are: %s"
+		return -1, NewCodedInvalidArgument(
+			"invalid_lineno_mode",
+			ErrorDetails{"linenoMode": mode},
+			fmt.Sprintf(msg, mode, options),
+		)
+	}
+	return system, nil
 }
 
 func GetCoordinateSystem(coordinateSystem string) (int, error) {
-	switch strings.ToLower(coordinateSystem) {
-	case "ij":
-		return CoordinateSystemIndex, nil
-	case "ilxl":
-		return CoordinateSystemAnnotation, nil
-	case "cdp":
-		return CoordinateSystemCdp, nil
-	default:
-		options := "ij, ilxl, cdp"
+	system, ok := lookupOption(
+		coordinateSystemOptions,
+		strings.ToLower(coordinateSystem),
+	)
+	if !ok {
+		options := strings.Join(CoordinateSystems(), ", ")
 		msg := "coordinate system not recognized: '%s', valid options are: %s"
-		return -1, NewInvalidArgument(fmt.Sprintf(msg, coordinateSystem, options))
+		return -1, NewCodedInvalidArgument(
+			"invalid_coordinate_system",
+			ErrorDetails{"coordinateSystem": coordinateSystem},
+			fmt.Sprintf(msg, coordinateSystem, options),
+		)
 	}
+	return system, nil
 }
 
+/** Interpolation method, nearest if not given
+ *
+ * Deployments with a default of their own resolve it with
+ * Defaults.InterpolationMethod.
+ */
 func GetInterpolationMethod(interpolation string) (int, error) {
-	switch strings.ToLower(interpolation) {
-	case "":
-		fallthrough
-	case "nearest":
-		return C.NEAREST, nil
-	case "linear":
-		return C.LINEAR, nil
-	case "cubic":
-		return C.CUBIC, nil
-	case "angular":
-		return C.ANGULAR, nil
-	case "triangular":
-		return C.TRIANGULAR, nil
-	default:
-		options := "nearest, linear, cubic, angular or triangular"
-		msg := "invalid interpolation method '%s', valid options are: %s"
-		return -1, NewInvalidArgument(fmt.Sprintf(msg, interpolation, options))
+	return Defaults{}.InterpolationMethod(interpolation)
+}
+
+/** Interpolation method for fences, which also accept nearest_trace and none */
+func GetFenceInterpolationMethod(interpolation string) (int, error) {
+	return Defaults{}.FenceInterpolationMethod(interpolation)
+}
+
+/** Look up an interpolation method, case-insensitively
+ *
+ * The empty string resolves to fallback, the default of the deployment, and
+ * the error lists the valid options along with the default.
+ */
+func getInterpolationMethod(
+	options []option,
+	interpolation string,
+	fallback string,
+) (int, error) {
+	name := interpolation
+	if name == "" {
+		name = fallback
+	}
+
+	method, ok := lookupOption(options, strings.ToLower(name))
+	if !ok {
+		valid := enumerate(optionNames(options))
+		msg := "invalid interpolation method '%s', valid options are: %s. " +
+			"Defaults to %s when not given"
+		return -1, NewCodedInvalidArgument(
+			"invalid_interpolation",
+			ErrorDetails{"interpolation": interpolation},
+			fmt.Sprintf(msg, interpolation, valid, fallback),
+		)
+	}
+	return method, nil
+}
+
+/** Interpolation method for re-sampling traces within a vertical window
+ *
+ * Unlike GetInterpolationMethod this defaults to cubic (modified makima),
+ * and only accepts the methods that make sense along a single trace. The
+ * error message names the vertical interpolation explicitly, as the
+ * attribute endpoints take a horizontal interpolation method as well.
+ */
+func GetVerticalInterpolationMethod(interpolation string) (int, error) {
+	name := interpolation
+	if name == "" {
+		name = defaultVerticalInterpolation
+	}
+
+	method, ok := lookupOption(
+		verticalInterpolationOptions,
+		strings.ToLower(name),
+	)
+	if ok {
+		return method, nil
 	}
+
+	options := enumerate(VerticalInterpolationMethods())
+	msg := "invalid vertical interpolation method '%s', valid options are: " +
+		"%s. Defaults to %s when not given. Horizontal interpolation is set " +
+		"by 'interpolation'"
+	return -1, NewCodedInvalidArgument(
+		"invalid_vertical_interpolation",
+		ErrorDetails{"verticalInterpolation": interpolation},
+		fmt.Sprintf(msg, interpolation, options, defaultVerticalInterpolation),
+	)
 }
 
 func GetAttributeType(attribute string) (int, error) {
-	switch strings.ToLower(attribute) {
-	case "samplevalue":
-		return C.VALUE, nil
-	case "min":
-		return C.MIN, nil
-	case "min_at":
-		return C.MINAT, nil
-	case "max":
-		return C.MAX, nil
-	case "max_at":
-		return C.MAXAT, nil
-	case "maxabs":
-		return C.MAXABS, nil
-	case "maxabs_at":
-		return C.MAXABSAT, nil
-	case "mean":
-		return C.MEAN, nil
-	case "meanabs":
-		return C.MEANABS, nil
-	case "meanpos":
-		return C.MEANPOS, nil
-	case "meanneg":
-		return C.MEANNEG, nil
-	case "median":
-		return C.MEDIAN, nil
-	case "rms":
-		return C.RMS, nil
-	case "var":
-		return C.VAR, nil
-	case "sd":
-		return C.SD, nil
-	case "sumpos":
-		return C.SUMPOS, nil
-	case "sumneg":
-		return C.SUMNEG, nil
-	case "":
-		fallthrough
-	default:
-		options := []string{
-			"samplevalue", "min", "min_at", "max", "max_at", "maxabs", "maxabs_at",
-			"mean", "meanabs", "meanpos", "meanneg", "median", "rms", "var", "sd",
-			"sumpos", "sumneg",
-		}
+	id, ok := lookupOption(attributeOptions, strings.ToLower(attribute))
+	if !ok {
 		msg := "invalid attribute '%s', valid options are: %s"
-		return -1, NewInvalidArgument(fmt.Sprintf(
-			msg,
-			attribute,
-			strings.Join(options, ", "),
-		))
+		return -1, NewCodedInvalidArgument(
+			"invalid_attribute",
+			ErrorDetails{"attribute": attribute},
+			fmt.Sprintf(msg, attribute, strings.Join(AttributeTypes(), ", ")),
+		)
 	}
+	return id, nil
+}
+
+/** Version of the OpenVDS library core is linked against */
+func OpenVDSVersion() string {
+	return C.GoString(C.openvds_version())
 }
 
 /** Translate C status codes into Go error types */
@@ -293,7 +866,14 @@ func toError(status C.int, ctx *C.Context) error {
 	case C.STATUS_RUNTIME_ERROR:
 		return NewInternalError(msg)
 	case C.STATUS_BAD_REQUEST:
-		return NewInvalidArgument(msg)
+		var details ErrorDetails
+		json.Unmarshal([]byte(C.GoString(C.errdetails(ctx))), &details)
+		if len(details) == 0 {
+			details = nil
+		}
+		return NewCodedInvalidArgument(C.GoString(C.errcode(ctx)), details, msg)
+	case C.STATUS_STORAGE_ERROR:
+		return NewStorageError(msg, int(C.errstatus(ctx)))
 	default:
 		return errors.New(msg)
 	}
@@ -320,22 +900,176 @@ func (r *cRegularSurface) Close() error {
 	return nil
 }
 
-func (surface *RegularSurface) toCdata(shift float32) ([]C.float, error) {
+/* Rows of inconsistent length beyond this are summarized by a count */
+const maxReportedRows = 20
+
+/** Check that the surface values make up a proper, non-empty grid
+ *
+ * All rows are compared against the length of row 0, and every deviating row
+ * is reported in a single error, such that clients with messy input can fix
+ * everything in one go rather than one row at a time.
+ *
+ * Values must be finite, except for occurrences of the fillValue. A NaN (or
+ * infinite) fillValue is legitimate, and so are the values that match it.
+ */
+func (surface *RegularSurface) Validate() error {
 	nrows := len(surface.Values)
+	if nrows == 0 {
+		return NewCodedInvalidArgument("empty_surface", nil, "Surface has no rows")
+	}
 	ncols := len(surface.Values[0])
 
-	cdata := make([]C.float, nrows*ncols)
+	var deviants []string
+	ndeviants := 0
+	for i, row := range surface.Values {
+		if len(row) == ncols {
+			continue
+		}
+		ndeviants++
+		if len(deviants) < maxReportedRows {
+			deviants = append(
+				deviants,
+				fmt.Sprintf("Row %d has %d elements", i, len(row)),
+			)
+		}
+	}
+
+	if ndeviants > 0 {
+		msg := fmt.Sprintf(
+			"Surface rows are not of the same length. Row 0 has %d elements. %s",
+			ncols,
+			strings.Join(deviants, ". "),
+		)
+		if ndeviants > len(deviants) {
+			msg += fmt.Sprintf(". ...and %d more", ndeviants-len(deviants))
+		}
+		return NewCodedInvalidArgument("inconsistent_surface_rows", nil, msg)
+	}
+
+	if ncols == 0 {
+		return NewCodedInvalidArgument(
+			"empty_surface",
+			nil,
+			"Surface rows have no elements",
+		)
+	}
 
 	for i, row := range surface.Values {
-		if len(row) != ncols {
-			msg := fmt.Sprintf(
-				"Surface rows are not of the same length. "+
-					"Row 0 has %d elements. Row %d has %d elements",
-				ncols, i, len(row),
+		for j, value := range row {
+			if isFinite(value) || isFillValue(value, surface.FillValue) {
+				continue
+			}
+			return NewCodedInvalidArgument(
+				"invalid_surface_value",
+				ErrorDetails{
+					"row":    strconv.Itoa(i),
+					"column": strconv.Itoa(j),
+				},
+				fmt.Sprintf(
+					"invalid surface value %v at row %d, column %d, "+
+						"expected finite values or fillValue",
+					value, i, j,
+				),
 			)
-			return nil, NewInvalidArgument(msg)
 		}
+	}
+	return nil
+}
 
+/* The step of a decimated surface, which defaults to 1 */
+func surfaceStep(name string, step *int) (int, error) {
+	if step == nil {
+		return 1, nil
+	}
+	if *step < 1 {
+		return 0, NewInvalidArgument(fmt.Sprintf(
+			"%s must be at least 1, was %d",
+			name,
+			*step,
+		))
+	}
+	return *step, nil
+}
+
+/* Whether the surface is decimated, i.e. has a step other than 1 */
+func (surface *RegularSurface) isDecimated() bool {
+	return (surface.RowStep != nil && *surface.RowStep != 1) ||
+		(surface.ColStep != nil && *surface.ColStep != 1)
+}
+
+/** The surface at every rowStep'th row and colStep'th column
+ *
+ * The increments are scaled by the steps, while the origin is unchanged as
+ * the first row and column are always kept. A trailing partial step is
+ * dropped. The returned surface has no steps. Expects a surface that has
+ * passed Validate().
+ */
+func (surface RegularSurface) Decimate() (RegularSurface, error) {
+	rowStep, err := surfaceStep("rowStep", surface.RowStep)
+	if err != nil {
+		return RegularSurface{}, err
+	}
+	colStep, err := surfaceStep("colStep", surface.ColStep)
+	if err != nil {
+		return RegularSurface{}, err
+	}
+	surface.RowStep = nil
+	surface.ColStep = nil
+	if rowStep == 1 && colStep == 1 {
+		return surface, nil
+	}
+
+	nrows := len(surface.Values) / rowStep
+	ncols := len(surface.Values[0]) / colStep
+	if nrows == 0 || ncols == 0 {
+		return RegularSurface{}, NewInvalidArgument(fmt.Sprintf(
+			"Surface of %d rows and %d columns is empty when decimated "+
+				"with rowStep %d and colStep %d",
+			len(surface.Values),
+			len(surface.Values[0]),
+			rowStep,
+			colStep,
+		))
+	}
+
+	values := make([][]float32, nrows)
+	for i := range values {
+		row := surface.Values[i*rowStep]
+		values[i] = make([]float32, ncols)
+		for j := range values[i] {
+			values[i][j] = row[j*colStep]
+		}
+	}
+
+	surface.Values = values
+	surface.Xinc *= float32(colStep)
+	surface.Yinc *= float32(rowStep)
+	return surface, nil
+}
+
+func isFinite(value float32) bool {
+	return !math.IsNaN(float64(value)) && !math.IsInf(float64(value), 0)
+}
+
+/* Like value == *fillValue, except that NaN is considered equal to NaN */
+func isFillValue(value float32, fillValue *float32) bool {
+	if fillValue == nil {
+		return false
+	}
+	if math.IsNaN(float64(*fillValue)) {
+		return math.IsNaN(float64(value))
+	}
+	return value == *fillValue
+}
+
+/* Expects a surface that has passed Validate() */
+func (surface *RegularSurface) toCdata(shift float32) ([]C.float, error) {
+	nrows := len(surface.Values)
+	ncols := len(surface.Values[0])
+
+	cdata := make([]C.float, nrows*ncols)
+
+	for i, row := range surface.Values {
 		for j, value := range row {
 			if value == *surface.FillValue {
 				cdata[i*ncols+j] = C.float(value)
@@ -377,31 +1111,81 @@ func (surface *RegularSurface) toCRegularSurface(cdata []C.float) (cRegularSurfa
 	return cRegularSurface{cSurface: cSurface, cData: cdata}, nil
 }
 
-type DSHandle struct {
+/** A VDS opened by OpenVDS, the DSHandle of every real cube */
+type VdsHandle struct {
 	dataSource *C.struct_DataSource
 	ctx        *C.struct_Context
+	workers    *WorkerPool
+}
+
+/** The handle, computing attributes with the workers of pool
+ *
+ * Without a pool, i.e. with a nil pool, every request computes its
+ * attributes on goroutines of its own.
+ */
+func (v VdsHandle) WithWorkerPool(pool *WorkerPool) DSHandle {
+	v.workers = pool
+	return v
 }
 
-func (v DSHandle) DataSource() *C.struct_DataSource {
+func (v VdsHandle) DataSource() *C.struct_DataSource {
 	return v.dataSource
 }
 
-func (v DSHandle) context() *C.struct_Context {
+func (v VdsHandle) context() *C.struct_Context {
 	return v.ctx
 }
 
-func (v DSHandle) Error(status C.int) error {
+func (v VdsHandle) Error(status C.int) error {
 	return toError(status, v.context())
 }
 
-func (v DSHandle) Close() error {
+func (v VdsHandle) Close() error {
 	defer C.context_free(v.ctx)
 
 	cerr := C.datasource_free(v.ctx, v.dataSource)
 	return toError(cerr, v.ctx)
 }
 
-func NewDSHandle(conn Connection) (DSHandle, error) {
+/** Statistics about the work done through a handle */
+type RequestStats struct {
+	/* Estimated number of bytes fetched from storage. The estimate is the
+	 * uncompressed size of every chunk that has been read from at least once.
+	 */
+	StorageBytes int64
+	/* Estimated number of requests to storage, counted like StorageBytes */
+	StorageRequests int64
+	/* Time spent reading, i.e. waiting for OpenVDS to fetch and decompress
+	 * the chunks, which OpenVDS does not tell apart.
+	 */
+	ReadTime time.Duration
+}
+
+/* The statistics of both, as of two attempts at the same request */
+func (s RequestStats) Add(other RequestStats) RequestStats {
+	return RequestStats{
+		StorageBytes:    s.StorageBytes + other.StorageBytes,
+		StorageRequests: s.StorageRequests + other.StorageRequests,
+		ReadTime:        s.ReadTime + other.ReadTime,
+	}
+}
+
+/* Statistics for all reads done through the handle so far */
+func (v VdsHandle) Stats() (RequestStats, error) {
+	var stats C.struct_request_stats
+	cerr := C.datasource_stats(v.context(), v.DataSource(), &stats)
+	if err := v.Error(cerr); err != nil {
+		return RequestStats{}, err
+	}
+
+	return RequestStats{
+		StorageBytes:    int64(stats.storage_bytes),
+		StorageRequests: int64(stats.storage_requests),
+		ReadTime:        time.Duration(float64(stats.read_seconds) * float64(time.Second)),
+	}, nil
+}
+
+func NewDSHandle(conn Connection) (VdsHandle, error) {
 	curl := C.CString(conn.Url())
 	defer C.free(unsafe.Pointer(curl))
 
@@ -415,15 +1199,50 @@ func NewDSHandle(conn Connection) (DSHandle, error) {
 
 	if err := toError(cerr, cctx); err != nil {
 		defer C.context_free(cctx)
-		return DSHandle{}, err
+		if strings.HasPrefix(err.Error(), "Could not open VDS") {
+			var status int
+			if storageErr, ok := err.(*InternalError); ok {
+				status = storageErr.status
+			}
+			err = classifyOpenError(conn, describeOpenError(conn, err.Error()))
+			if internal, ok := err.(*InternalError); ok {
+				internal.status = status
+			}
+		}
+		return VdsHandle{}, err
 	}
 
-	return DSHandle{dataSource: dataSource, ctx: cctx}, nil
+	return VdsHandle{dataSource: dataSource, ctx: cctx}, nil
 }
 
-func (v DSHandle) GetMetadata() ([]byte, error) {
+/** Metadata of the VDS
+ *
+ * With includeImportInfo, the textual header of the SEG-Y the VDS was
+ * imported from is included too, if the VDS has it. With includeLayout, the
+ * storage layout of the VDS is included too.
+ */
+func (v VdsHandle) GetMetadata(
+	includeImportInfo bool,
+	includeLayout bool,
+) ([]byte, error) {
+	var cIncludeImportInfo C.int
+	if includeImportInfo {
+		cIncludeImportInfo = 1
+	}
+
+	var cIncludeLayout C.int
+	if includeLayout {
+		cIncludeLayout = 1
+	}
+
 	var result C.struct_response
-	cerr := C.metadata(v.context(), v.DataSource(), &result)
+	cerr := C.metadata(
+		v.context(),
+		v.DataSource(),
+		cIncludeImportInfo,
+		cIncludeLayout,
+		&result,
+	)
 
 	defer C.response_delete(&result)
 
@@ -432,5 +1251,22 @@ func (v DSHandle) GetMetadata() ([]byte, error) {
 	}
 
 	buf := C.GoBytes(unsafe.Pointer(result.data), C.int(result.size))
-	return buf, nil
+	return normalizeMetadataUnits(buf)
+}
+
+/* The axes of the cube, in the order of the metadata */
+func (v VdsHandle) Axes() ([]*Axis, error) {
+	buf, err := v.GetMetadata(false, false)
+	if err != nil {
+		return nil, err
+	}
+
+	var metadata Metadata
+	if err := json.Unmarshal(buf, &metadata); err != nil {
+		return nil, NewInternalError(err.Error())
+	}
+	if len(metadata.Axis) == 0 {
+		return nil, NewInternalError("expected axes in metadata, got none")
+	}
+	return metadata.Axis, nil
 }
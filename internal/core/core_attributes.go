@@ -7,13 +7,128 @@ package core
 */
 import "C"
 import (
+	"encoding/json"
 	"fmt"
 	"math"
+	"strings"
 	"unsafe"
 )
 
 
-func (v DSHandle) GetAttributeMetadata(data [][]float32) ([]byte, error) {
+/** Metadata of the attributes of a surface
+ *
+ * Like GetAttributeMetadata, but for the surface the attributes are
+ * actually computed on, i.e. after decimation. Decimated surfaces also get
+ * their effective increments, and the fill value of the surface is given.
+ */
+func (v VdsHandle) GetSurfaceAttributeMetadata(
+	surface RegularSurface,
+) ([]byte, error) {
+	metadata, err := v.surfaceAttributeMetadata(surface, nil)
+	if err != nil {
+		return nil, err
+	}
+	return marshalAttributeMetadata(metadata)
+}
+
+func (v VdsHandle) surfaceAttributeMetadata(
+	surface RegularSurface,
+	band *SurfaceBand,
+) (*AttributeMetadata, error) {
+	if err := surface.Validate(); err != nil {
+		return nil, err
+	}
+
+	decimated, err := surface.Decimate()
+	if err != nil {
+		return nil, err
+	}
+
+	values := decimated.Values
+	from, to := 0, len(values)
+	if band != nil {
+		from, to, err = band.rows(len(values))
+		if err != nil {
+			return nil, err
+		}
+		values = values[from:to]
+	}
+
+	buf, err := v.GetAttributeMetadata(values)
+	if err != nil {
+		return nil, err
+	}
+
+	var metadata AttributeMetadata
+	if err := json.Unmarshal(buf, &metadata); err != nil {
+		return nil, NewInternalError(err.Error())
+	}
+	if surface.FillValue != nil {
+		fillValue := FillValue(*surface.FillValue)
+		metadata.FillValue = &fillValue
+	}
+	if surface.isDecimated() {
+		metadata.Xinc = &decimated.Xinc
+		metadata.Yinc = &decimated.Yinc
+	}
+	if band != nil {
+		metadata.Band = &AttributeBand{
+			RowOffset: from,
+			RowCount:  to - from,
+			Nrows:     len(decimated.Values),
+		}
+	}
+	return &metadata, nil
+}
+
+/** Metadata of the attributes of a surface, with a descriptor per attribute
+ *
+ * Every attribute is a data part of its own, and is described by its name,
+ * by which it was requested, and its own shape and format. All attributes
+ * have the shape of the surface today, which is also given at the top level
+ * for clients that predate the descriptors. With a band, the shape is that
+ * of the band. The coverage of the parts is only known once the attributes
+ * are computed, see withCoverage.
+ */
+func (v VdsHandle) attributeMetadataWithParts(
+	surface RegularSurface,
+	attributes []string,
+	band *SurfaceBand,
+) (*AttributeMetadata, error) {
+	metadata, err := v.surfaceAttributeMetadata(surface, band)
+	if err != nil {
+		return nil, err
+	}
+
+	metadata.Parts = make([]AttributePart, len(attributes))
+	for i, attribute := range attributes {
+		metadata.Parts[i] = AttributePart{
+			Name:  strings.ToLower(attribute),
+			Array: metadata.Array,
+		}
+	}
+	return metadata, nil
+}
+
+/* Marshal the metadata with the coverage of every part, in order */
+func (metadata *AttributeMetadata) withCoverage(
+	coverage []AttributeCoverage,
+) ([]byte, error) {
+	for i := range metadata.Parts {
+		metadata.Parts[i].Coverage = coverage[i]
+	}
+	return marshalAttributeMetadata(metadata)
+}
+
+func marshalAttributeMetadata(metadata *AttributeMetadata) ([]byte, error) {
+	out, err := json.Marshal(metadata)
+	if err != nil {
+		return nil, NewInternalError(err.Error())
+	}
+	return out, nil
+}
+
+func (v VdsHandle) GetAttributeMetadata(data [][]float32) ([]byte, error) {
 	var result C.struct_response
 	cerr := C.attribute_metadata(
 		v.context(),
@@ -33,17 +148,151 @@ func (v DSHandle) GetAttributeMetadata(data [][]float32) ([]byte, error) {
 	return buf, nil
 }
 
-func (v DSHandle) GetAttributesAlongSurface(
+/** A polygon, which limits what part of a surface attributes are computed for
+ *
+ * The vertices are [x y] pairs in the coordinate system, which is one of the
+ * CoordinateSystem* constants. The polygon is closed implicitly.
+ */
+type Polygon struct {
+	CoordinateSystem int
+	Vertices         [][]float32
+}
+
+/* Set the nodes of the surface outside the polygon to its fill value */
+func (v VdsHandle) maskSurface(surface cRegularSurface, polygon Polygon) error {
+	if len(polygon.Vertices) < 3 {
+		return NewInvalidArgument(fmt.Sprintf(
+			"Polygon must have at least 3 vertices, got %d",
+			len(polygon.Vertices),
+		))
+	}
+
+	cvertices, err := toCCoordinates(polygon.Vertices)
+	if err != nil {
+		return err
+	}
+
+	cerr := C.regular_surface_mask(
+		v.context(),
+		v.DataSource(),
+		surface.get(),
+		C.enum_coordinate_system(polygon.CoordinateSystem),
+		&cvertices[0],
+		C.size_t(len(polygon.Vertices)),
+	)
+	return v.Error(cerr)
+}
+
+/** A band of rows, which limits what part of a surface attributes are
+ * computed and returned for
+ *
+ * The rows are those of the surface the attributes are computed on, i.e.
+ * after decimation. A band reaching past the last row is cut short.
+ */
+type SurfaceBand struct {
+	RowOffset int
+	RowCount  int
+}
+
+/* The rows [from, to) of a surface of nrows rows that the band covers */
+func (band SurfaceBand) rows(nrows int) (from int, to int, err error) {
+	if band.RowOffset < 0 || band.RowOffset >= nrows {
+		return 0, 0, NewInvalidArgument(fmt.Sprintf(
+			"rowOffset %d is out of range, the surface has %d rows",
+			band.RowOffset,
+			nrows,
+		))
+	}
+	if band.RowCount < 1 {
+		return 0, 0, NewInvalidArgument(fmt.Sprintf(
+			"rowCount must be at least 1, was %d",
+			band.RowCount,
+		))
+	}
+	to = band.RowOffset + min(band.RowCount, nrows-band.RowOffset)
+	return band.RowOffset, to, nil
+}
+
+/* The surface with the rows outside of [from, to) set to its fill value */
+func (surface RegularSurface) maskRows(from int, to int) RegularSurface {
+	var fill []float32
+	values := make([][]float32, len(surface.Values))
+	for i := range values {
+		if from <= i && i < to {
+			values[i] = surface.Values[i]
+			continue
+		}
+		if fill == nil {
+			fill = make([]float32, len(surface.Values[0]))
+			for j := range fill {
+				fill[j] = *surface.FillValue
+			}
+		}
+		values[i] = fill
+	}
+	surface.Values = values
+	return surface
+}
+
+/** Attributes along a surface
+ *
+ * There is one data part per attribute, in exactly the order of attributes.
+ * That order is kept by everything downstream, and is the order the parts are
+ * described in the metadata, see attributeMetadataWithParts.
+ */
+func (v VdsHandle) GetAttributesAlongSurface(
 	referenceSurface RegularSurface,
 	above float32,
 	below float32,
 	stepsize float32,
 	attributes []string,
 	interpolation int,
+	verticalInterpolation int,
 ) ([][]byte, error) {
+	data, _, err := v.getAttributesAlongSurface(
+		referenceSurface,
+		above,
+		below,
+		stepsize,
+		attributes,
+		interpolation,
+		verticalInterpolation,
+		nil,
+		nil,
+		0,
+	)
+	return data, err
+}
+
+/*
+ * With a polygon, the nodes of the reference surface outside of it are
+ * masked out, such that the cube is not sampled for them. A node is skipped
+ * if any of the reference, top and bottom surface is missing at it, so
+ * masking the reference surface is enough.
+ *
+ * A band is computed the same way, by masking out the rows outside of it,
+ * such that the attributes of the band are exactly those of the whole
+ * surface. Only the rows of the band are returned.
+ */
+func (v VdsHandle) getAttributesAlongSurface(
+	referenceSurface RegularSurface,
+	above float32,
+	below float32,
+	stepsize float32,
+	attributes []string,
+	interpolation int,
+	verticalInterpolation int,
+	polygon *Polygon,
+	band *SurfaceBand,
+	minValidFraction float32,
+) ([][]byte, []AttributeCoverage, error) {
 	targetAttributes, err := v.normalizeAttributes(attributes)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+
+	if err := validateMinValidFraction(minValidFraction); err != nil {
+		return nil, nil, err
 	}
 
 	if above < 0 || below < 0 {
@@ -52,66 +301,325 @@ func (v DSHandle) GetAttributesAlongSurface(
 				"Above was %f, below was %f",
 			above, below,
 		)
-		return nil, NewInvalidArgument(msg)
+		return nil, nil, NewCodedInvalidArgument(
+			"invalid_vertical_window",
+			nil,
+			msg,
+		)
+	}
+
+	if err := referenceSurface.Validate(); err != nil {
+		return nil, nil, err
+	}
+
+	referenceSurface, err = referenceSurface.Decimate()
+	if err != nil {
+		return nil, nil, err
 	}
 
 	var nrows = len(referenceSurface.Values)
 	var ncols = len(referenceSurface.Values[0])
 
+	from, to := 0, nrows
+	if band != nil {
+		from, to, err = band.rows(nrows)
+		if err != nil {
+			return nil, nil, err
+		}
+		referenceSurface = referenceSurface.maskRows(from, to)
+	}
+
 	cReferenceSurfaceData, err := referenceSurface.toCdata(0)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	cReferenceSurface, err := referenceSurface.toCRegularSurface(cReferenceSurfaceData)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	defer cReferenceSurface.Close()
 
+	if polygon != nil {
+		if err := v.maskSurface(cReferenceSurface, *polygon); err != nil {
+			return nil, nil, err
+		}
+	}
+
 	cTopSurfaceData, err := referenceSurface.toCdata(-above)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	cTopSurface, err := referenceSurface.toCRegularSurface(cTopSurfaceData)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	defer cTopSurface.Close()
 
 	cBottomSurfaceData, err := referenceSurface.toCdata(below)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	cBottomSurface, err := referenceSurface.toCRegularSurface(cBottomSurfaceData)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	defer cBottomSurface.Close()
 
-	return v.getAttributes(
+	data, coverage, err := v.getAttributes(
 		cReferenceSurface,
 		cTopSurface,
 		cBottomSurface,
 		nrows,
 		ncols,
+		from,
+		to,
 		targetAttributes,
 		interpolation,
+		verticalInterpolation,
+		stepsize,
+		minValidFraction,
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if band != nil {
+		rowsize := ncols * 4
+		for i := range data {
+			data[i] = data[i][from*rowsize : to*rowsize]
+		}
+	}
+	return data, coverage, nil
+}
+
+/** Attributes along a surface and their metadata
+ *
+ * Equivalent to GetSurfaceAttributeMetadata followed by
+ * GetAttributesAlongSurface, but the metadata also describes every
+ * attribute part. With a polygon, attributes are only computed
+ * for the nodes inside of it, while the rest get the fill value. The shape
+ * is still that of the whole surface. Nodes where less than
+ * minValidFraction of the window holds data also get the fill value.
+ * With a band, only the rows of it are computed and returned, and the shape
+ * is that of the band.
+ * Apart from the coverage of every part, which is counted as the attributes
+ * are computed, the metadata only depends on the shape of the surface, so
+ * there is no validation to share, and the two are not merged any further
+ * in core.
+ */
+func (v VdsHandle) GetAttributesAlongSurfaceWithMetadata(
+	referenceSurface RegularSurface,
+	above float32,
+	below float32,
+	stepsize float32,
+	attributes []string,
+	interpolation int,
+	verticalInterpolation int,
+	polygon *Polygon,
+	band *SurfaceBand,
+	minValidFraction float32,
+) (data [][]byte, metadata []byte, err error) {
+	withParts, err := v.attributeMetadataWithParts(
+		referenceSurface,
+		attributes,
+		band,
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	data, coverage, err := v.getAttributesAlongSurface(
+		referenceSurface,
+		above,
+		below,
 		stepsize,
+		attributes,
+		interpolation,
+		verticalInterpolation,
+		polygon,
+		band,
+		minValidFraction,
 	)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	metadata, err = withParts.withCoverage(coverage)
+	if err != nil {
+		return nil, nil, err
+	}
+	return data, metadata, nil
 }
 
-func (v DSHandle) GetAttributesBetweenSurfaces(
+/** Attributes between surfaces
+ *
+ * The data parts are in the order of attributes, like for
+ * GetAttributesAlongSurface.
+ */
+func (v VdsHandle) GetAttributesBetweenSurfaces(
 	primarySurface RegularSurface,
 	secondarySurface RegularSurface,
 	stepsize float32,
 	attributes []string,
 	interpolation int,
+	verticalInterpolation int,
 ) ([][]byte, error) {
+	data, _, err := v.getAttributesBetweenSurfaces(
+		primarySurface,
+		secondarySurface,
+		stepsize,
+		attributes,
+		interpolation,
+		verticalInterpolation,
+		0,
+	)
+	return data, err
+}
+
+/** The world (cdp) coordinates of the node at row, col of the surface */
+func (surface RegularSurface) toCdp(row int, col int) (x float64, y float64) {
+	rad := float64(*surface.Rotation) * math.Pi / 180
+	xinc := float64(surface.Xinc)
+	yinc := float64(surface.Yinc)
+
+	x = float64(*surface.Xori) +
+		float64(row)*xinc*math.Cos(rad) - float64(col)*yinc*math.Sin(rad)
+	y = float64(*surface.Yori) +
+		float64(row)*xinc*math.Sin(rad) + float64(col)*yinc*math.Cos(rad)
+	return x, y
+}
+
+/* The fractional row and column of the world coordinates x, y. See toCdp */
+func (surface RegularSurface) fromCdp(x float64, y float64) (row float64, col float64) {
+	rad := float64(*surface.Rotation) * math.Pi / 180
+	dx := x - float64(*surface.Xori)
+	dy := y - float64(*surface.Yori)
+
+	row = (math.Cos(rad)*dx + math.Sin(rad)*dy) / float64(surface.Xinc)
+	col = (-math.Sin(rad)*dx + math.Cos(rad)*dy) / float64(surface.Yinc)
+	return row, col
+}
+
+/** Whether the surface has a node nearest to the world coordinates x, y
+ *
+ * Like align_surfaces, which pairs every node of the primary surface with
+ * the nearest node of the secondary surface, if any.
+ */
+func (surface RegularSurface) hasNodeNear(x float64, y float64) bool {
+	row, col := surface.fromCdp(x, y)
+	row = math.Round(row)
+	col = math.Round(col)
+
+	return 0 <= row && row < float64(len(surface.Values)) &&
+		0 <= col && col < float64(len(surface.Values[0]))
+}
+
+/** How the grids of the surfaces differ, with the values of both */
+func surfaceGridMismatches(primary RegularSurface, secondary RegularSurface) []string {
+	properties := []struct {
+		name      string
+		primary   interface{}
+		secondary interface{}
+	}{
+		{"nrows", len(primary.Values), len(secondary.Values)},
+		{"ncols", len(primary.Values[0]), len(secondary.Values[0])},
+		{"xori", *primary.Xori, *secondary.Xori},
+		{"yori", *primary.Yori, *secondary.Yori},
+		{"xinc", primary.Xinc, secondary.Xinc},
+		{"yinc", primary.Yinc, secondary.Yinc},
+		{"rotation", *primary.Rotation, *secondary.Rotation},
+	}
+
+	mismatches := []string{}
+	for _, property := range properties {
+		if property.primary == property.secondary {
+			continue
+		}
+		mismatches = append(mismatches, fmt.Sprintf(
+			"%s (primary: %v, secondary: %v)",
+			property.name,
+			property.primary,
+			property.secondary,
+		))
+	}
+	return mismatches
+}
+
+/** Verify that the secondary surface lies over the primary surface
+ *
+ * The surfaces need not share a grid, as every node of the primary surface
+ * is paired with the nearest node of the secondary surface. When no node of
+ * the primary surface has such a pair the response would be all fill
+ * values, which is rather reported as an error that lists how the grids
+ * differ. Nodes holding the fill value are ignored, and a primary surface
+ * of only fill values is left alone.
+ */
+func validateSurfacesOverlap(primary RegularSurface, secondary RegularSurface) error {
+	defined := false
+	for row, values := range primary.Values {
+		for col, value := range values {
+			if isFillValue(value, primary.FillValue) {
+				continue
+			}
+			defined = true
+			if secondary.hasNodeNear(primary.toCdp(row, col)) {
+				return nil
+			}
+		}
+	}
+	if !defined {
+		return nil
+	}
+
+	return NewCodedInvalidArgument(
+		"surfaces_do_not_overlap",
+		nil,
+		fmt.Sprintf(
+			"Surfaces do not overlap, no point of the primary surface has "+
+				"a nearest point on the secondary surface. The grids differ "+
+				"in: %s",
+			strings.Join(surfaceGridMismatches(primary, secondary), ", "),
+		),
+	)
+}
+
+func (v VdsHandle) getAttributesBetweenSurfaces(
+	primarySurface RegularSurface,
+	secondarySurface RegularSurface,
+	stepsize float32,
+	attributes []string,
+	interpolation int,
+	verticalInterpolation int,
+	minValidFraction float32,
+) ([][]byte, []AttributeCoverage, error) {
 	targetAttributes, err := v.normalizeAttributes(attributes)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+
+	if err := validateMinValidFraction(minValidFraction); err != nil {
+		return nil, nil, err
+	}
+
+	if err := primarySurface.Validate(); err != nil {
+		return nil, nil, err
+	}
+	if err := secondarySurface.Validate(); err != nil {
+		return nil, nil, err
+	}
+	if err := validateSurfacesOverlap(primarySurface, secondarySurface); err != nil {
+		return nil, nil, err
+	}
+
+	primarySurface, err = primarySurface.Decimate()
+	if err != nil {
+		return nil, nil, err
+	}
+	secondarySurface, err = secondarySurface.Decimate()
+	if err != nil {
+		return nil, nil, err
 	}
 
 	var nrows = len(primarySurface.Values)
@@ -120,28 +628,28 @@ func (v DSHandle) GetAttributesBetweenSurfaces(
 
 	cPrimarySurfaceData, err := primarySurface.toCdata(0)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	cPrimarySurface, err := primarySurface.toCRegularSurface(cPrimarySurfaceData)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	defer cPrimarySurface.Close()
 
 	cSecondarySurfaceData, err := secondarySurface.toCdata(0)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	cSecondarySurface, err := secondarySurface.toCRegularSurface(cSecondarySurfaceData)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	defer cSecondarySurface.Close()
 
 	cAlignedSurfaceData := make([]C.float, hsize)
 	cAlignedSurface, err := primarySurface.toCRegularSurface(cAlignedSurfaceData)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	defer cAlignedSurface.Close()
 
@@ -156,7 +664,7 @@ func (v DSHandle) GetAttributesBetweenSurfaces(
 	)
 
 	if err := v.Error(cerr); err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	var cTopSurface cRegularSurface
@@ -176,22 +684,70 @@ func (v DSHandle) GetAttributesBetweenSurfaces(
 		cBottomSurface,
 		nrows,
 		ncols,
+		0,
+		nrows,
 		targetAttributes,
 		interpolation,
+		verticalInterpolation,
 		stepsize,
+		minValidFraction,
 	)
 }
 
-func (v DSHandle) getAttributes(
+/** Attributes between surfaces and their metadata
+ *
+ * Equivalent to GetSurfaceAttributeMetadata followed by
+ * GetAttributesBetweenSurfaces, like GetAttributesAlongSurfaceWithMetadata.
+ */
+func (v VdsHandle) GetAttributesBetweenSurfacesWithMetadata(
+	primarySurface RegularSurface,
+	secondarySurface RegularSurface,
+	stepsize float32,
+	attributes []string,
+	interpolation int,
+	verticalInterpolation int,
+	minValidFraction float32,
+) (data [][]byte, metadata []byte, err error) {
+	withParts, err := v.attributeMetadataWithParts(primarySurface, attributes, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	data, coverage, err := v.getAttributesBetweenSurfaces(
+		primarySurface,
+		secondarySurface,
+		stepsize,
+		attributes,
+		interpolation,
+		verticalInterpolation,
+		minValidFraction,
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	metadata, err = withParts.withCoverage(coverage)
+	if err != nil {
+		return nil, nil, err
+	}
+	return data, metadata, nil
+}
+
+/* The attributes of the rows [fromRow, toRow) of the surfaces */
+func (v VdsHandle) getAttributes(
 	cReferenceSurface cRegularSurface,
 	cTopSurface cRegularSurface,
 	cBottomSurface cRegularSurface,
 	nrows int,
 	ncols int,
+	fromRow int,
+	toRow int,
 	targetAttributes []int,
 	interpolation int,
+	verticalInterpolation int,
 	stepsize float32,
-) ([][]byte, error) {
+	minValidFraction float32,
+) ([][]byte, []AttributeCoverage, error) {
 	var hsize = nrows * ncols
 
 	var cSubVolume *C.struct_SurfaceBoundedSubVolume
@@ -207,7 +763,7 @@ func (v DSHandle) getAttributes(
 	)
 
 	if err := toError(cerr, cCtx); err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	defer C.subvolume_free(cCtx, cSubVolume)
 
@@ -218,18 +774,37 @@ func (v DSHandle) getAttributes(
 		interpolation,
 	)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	return v.calculateAttributes(
 		cSubVolume,
 		hsize,
+		fromRow*ncols,
+		toRow*ncols,
 		targetAttributes,
+		verticalInterpolation,
 		stepsize,
+		minValidFraction,
 	)
 }
 
-func (v DSHandle) normalizeAttributes(
+/*
+ * Absent data in the cube is left out of the attributes. minValidFraction is
+ * the fraction of the window that must hold data for the attributes to be
+ * computed at all, where 0 requires just a single sample.
+ */
+func validateMinValidFraction(minValidFraction float32) error {
+	if minValidFraction < 0 || minValidFraction > 1 {
+		return NewInvalidArgument(fmt.Sprintf(
+			"minValidFraction must be between 0 and 1, got %v",
+			minValidFraction,
+		))
+	}
+	return nil
+}
+
+func (v VdsHandle) normalizeAttributes(
 	attributes []string,
 ) ([]int, error) {
 	var targetAttributes []int
@@ -243,6 +818,24 @@ func (v DSHandle) normalizeAttributes(
 	return targetAttributes, nil
 }
 
+/** Look up every attribute, rather than failing on the first invalid one
+ *
+ * errs has an entry for every attribute, which is nil for the valid ones.
+ * valid holds the valid attributes, in order, by their canonical name, and
+ * can be passed on to the GetAttributes* functions as is.
+ */
+func ResolveAttributes(attributes []string) (valid []string, errs []error) {
+	errs = make([]error, len(attributes))
+	for i, attr := range attributes {
+		if _, err := GetAttributeType(attr); err != nil {
+			errs[i] = err
+			continue
+		}
+		valid = append(valid, strings.ToLower(attr))
+	}
+	return valid, errs
+}
+
 func min(a, b int) int {
 	if a < b {
 		return a
@@ -257,7 +850,7 @@ func max(a, b int) int {
 	return b
 }
 
-func (v DSHandle) fetchSubvolume(
+func (v VdsHandle) fetchSubvolume(
 	cSubVolume *C.struct_SurfaceBoundedSubVolume,
 	nrows int,
 	ncols int,
@@ -324,12 +917,27 @@ func (v DSHandle) fetchSubvolume(
 	return nil
 }
 
-func (v DSHandle) calculateAttributes(
+/*
+ * The work is split by the nodes of the surface, never by attribute, into
+ * tasks that are run by the worker pool of the handle. Every attribute has
+ * its own slot in the buffer, by its position in targetAttributes, and every
+ * task writes only its own nodes, so the result does not depend on how the
+ * tasks are scheduled.
+ *
+ * Only the nodes [first, last) are computed, the rest of the buffer is left
+ * zeroed. The coverage of every attribute is counted by the routines as they
+ * go, each in its own slots, and summed up once they are done.
+ */
+func (v VdsHandle) calculateAttributes(
 	cSubVolume *C.struct_SurfaceBoundedSubVolume,
 	hsize int,
+	first int,
+	last int,
 	targetAttributes []int,
+	verticalInterpolation int,
 	stepsize float32,
-) ([][]byte, error) {
+	minValidFraction float32,
+) ([][]byte, []AttributeCoverage, error) {
 
 	cAttributes := make([]C.enum_attribute, len(targetAttributes))
 	for i := range targetAttributes {
@@ -340,54 +948,49 @@ func (v DSHandle) calculateAttributes(
 	var mapsize = hsize * 4
 	buffer := make([]byte, mapsize*nAttributes)
 
-	maxConcurrency := 32
-	windowsPerRoutine := int(math.Ceil(float64(hsize) / float64(maxConcurrency)))
+	nodesPerTask := v.workers.nodesPerTask(last - first)
 
-	errs := make(chan error, maxConcurrency)
+	var tasks []func() error
+	var cCoverage [][]C.struct_attribute_coverage
 
-	from := 0
-	remaining := hsize
-	nRoutines := 0
+	from := first
+	remaining := last - first
 	for remaining > 0 {
-		nRoutines++
-
-		size := min(windowsPerRoutine, remaining)
+		size := min(nodesPerTask, remaining)
 		to := from + size
 
-		go func(from, to int) {
-			var cCtx = C.context_new()
-			defer C.context_free(cCtx)
+		coverage := make([]C.struct_attribute_coverage, nAttributes)
+		cCoverage = append(cCoverage, coverage)
 
-			cErr := C.attribute(
-				cCtx,
-				v.DataSource(),
-				cSubVolume,
-				&cAttributes[0],
-				C.size_t(nAttributes),
-				C.float(stepsize),
-				C.size_t(from),
-				C.size_t(to),
-				unsafe.Pointer(&buffer[0]),
-			)
+		tasks = append(tasks, func(from, to int) func() error {
+			return func() error {
+				var cCtx = C.context_new()
+				defer C.context_free(cCtx)
 
-			errs <- toError(cErr, cCtx)
-		}(from, to)
+				cErr := C.attribute(
+					cCtx,
+					v.DataSource(),
+					cSubVolume,
+					&cAttributes[0],
+					C.size_t(nAttributes),
+					C.float(stepsize),
+					C.enum_interpolation_method(verticalInterpolation),
+					C.float(minValidFraction),
+					C.size_t(from),
+					C.size_t(to),
+					unsafe.Pointer(&buffer[0]),
+					&coverage[0],
+				)
+				return toError(cErr, cCtx)
+			}
+		}(from, to))
 
 		from = to
 		remaining -= size
 	}
 
-	// Wait for all gorutines to finish and collect any errors
-	var computeErrors []error
-	for i := 0; i < nRoutines; i++ {
-		err := <-errs
-		if err != nil {
-			computeErrors = append(computeErrors, err)
-		}
-	}
-
-	if len(computeErrors) > 0 {
-		return nil, computeErrors[0]
+	if err := v.workers.Run(tasks); err != nil {
+		return nil, nil, err
 	}
 
 	out := make([][]byte, nAttributes)
@@ -395,5 +998,30 @@ func (v DSHandle) calculateAttributes(
 		out[i] = buffer[i*mapsize : (i+1)*mapsize]
 	}
 
-	return out, nil
+	coverage := make([]AttributeCoverage, nAttributes)
+	for i := range coverage {
+		coverage[i].Total = last - first
+		for _, counts := range cCoverage {
+			coverage[i].Fill.add(counts[i])
+		}
+		coverage[i].Valid = coverage[i].Total - coverage[i].Fill.total()
+	}
+
+	return out, coverage, nil
+}
+
+func (counts *AttributeFillCounts) add(c C.struct_attribute_coverage) {
+	counts.MissingSurface += int(c.missing_surface)
+	counts.OutsideSurvey += int(c.outside_survey)
+	counts.DeadTraces += int(c.dead_traces)
+	counts.BelowMinValidFraction += int(c.below_min_valid_fraction)
+	counts.Undefined += int(c.undefined)
+}
+
+func (counts AttributeFillCounts) total() int {
+	return counts.MissingSurface +
+		counts.OutsideSurvey +
+		counts.DeadTraces +
+		counts.BelowMinValidFraction +
+		counts.Undefined
 }
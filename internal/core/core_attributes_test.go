@@ -3,7 +3,9 @@ package core
 import (
 	"bytes"
 	"encoding/json"
+	"fmt"
 	"math"
+	"os"
 	"sort"
 	"testing"
 
@@ -45,6 +47,7 @@ func TestSurfaceUnalignedWithSeismic(t *testing.T) {
 	}
 
 	interpolationMethod, _ := GetInterpolationMethod("nearest")
+	verticalInterpolation, _ := GetVerticalInterpolationMethod("")
 
 	handle, _ := NewDSHandle(samples10)
 	defer handle.Close()
@@ -55,6 +58,7 @@ func TestSurfaceUnalignedWithSeismic(t *testing.T) {
 		stepsize,
 		targetAttributes,
 		interpolationMethod,
+		verticalInterpolation,
 	)
 	require.Len(t, buf, len(targetAttributes), "Wrong number of attributes")
 	require.NoErrorf(t, err, "Failed to fetch horizon")
@@ -120,6 +124,7 @@ func TestSurfaceWindowVerticalBounds(t *testing.T) {
 	for _, testcase := range testcases {
 		surface := samples10Surface(testcase.values)
 		interpolationMethod, _ := GetInterpolationMethod("nearest")
+		verticalInterpolation, _ := GetVerticalInterpolationMethod("")
 		handle, _ := NewDSHandle(samples10)
 		defer handle.Close()
 		_, boundsErr := handle.GetAttributesAlongSurface(
@@ -129,6 +134,7 @@ func TestSurfaceWindowVerticalBounds(t *testing.T) {
 			stepsize,
 			targetAttributes,
 			interpolationMethod,
+			verticalInterpolation,
 		)
 
 		if testcase.inbounds {
@@ -146,6 +152,10 @@ func TestSurfaceWindowVerticalBounds(t *testing.T) {
 				testcase.name,
 				testcase.values[0][0],
 			)
+			require.IsTypef(t, &InvalidArgument{}, boundsErr,
+				"[%s] Out of bound horizon is a client error",
+				testcase.name,
+			)
 		}
 	}
 }
@@ -194,6 +204,7 @@ func TestSurfaceHorizontalBounds(t *testing.T) {
 
 	targetAttributes := []string{"samplevalue"}
 	interpolationMethod, _ := GetInterpolationMethod("nearest")
+	verticalInterpolation, _ := GetVerticalInterpolationMethod("")
 	const above = float32(4.0)
 	const below = float32(4.0)
 	const stepsize = float32(4.0)
@@ -276,6 +287,7 @@ func TestSurfaceHorizontalBounds(t *testing.T) {
 			stepsize,
 			targetAttributes,
 			interpolationMethod,
+			verticalInterpolation,
 		)
 		require.NoErrorf(t, err,
 			"[%s] Failed to fetch horizon, err: %v",
@@ -307,6 +319,7 @@ func TestAttribute(t *testing.T) {
 		"max_at",
 		"maxabs",
 		"maxabs_at",
+		"maxabs_signed",
 		"mean",
 		"meanabs",
 		"meanpos",
@@ -317,6 +330,8 @@ func TestAttribute(t *testing.T) {
 		"sd",
 		"sumpos",
 		"sumneg",
+		"sum",
+		"mad",
 	}
 	expected := [][]float32{
 		{-0.5, 0.5, -8.5, 6.5, fillValue, -16.5, fillValue, fillValue},                        // samplevalue
@@ -326,6 +341,7 @@ func TestAttribute(t *testing.T) {
 		{28, 12, 28, 28, fillValue, 12, fillValue, fillValue},                                 // max_at
 		{2.5, 2.5, 12.5, 10.5, fillValue, 24.5, fillValue, fillValue},                         // maxabs
 		{12, 12, 12, 28, fillValue, 28, fillValue, fillValue},                                 // maxabs_at
+		{-2.5, 2.5, -12.5, 10.5, fillValue, -24.5, fillValue, fillValue},                      // maxabs_signed
 		{-0.5, 0.5, -8.5, 6.5, fillValue, -16.5, fillValue, fillValue},                        // mean
 		{1.3, 1.3, 8.5, 6.5, fillValue, 16.5, fillValue, fillValue},                           // meanabs
 		{1, 1.5, 0, 6.5, fillValue, 0, fillValue, fillValue},                                  // meanpos
@@ -336,6 +352,8 @@ func TestAttribute(t *testing.T) {
 		{1.4142135, 1.4142135, 2.828427, 2.828427, fillValue, 5.656854, fillValue, fillValue}, // sd
 		{2, 4.5, 0, 32.5, fillValue, 0, fillValue, fillValue},                                 // sumpos
 		{-4.5, -2, -42.5, 0, fillValue, -82.5, fillValue, fillValue},                          // sumneg
+		{-2.5, 2.5, -42.5, 32.5, fillValue, -82.5, fillValue, fillValue},                      // sum
+		{1, 1, 2, 2, fillValue, 4, fillValue, fillValue},                                      // mad
 	}
 
 	values := [][]float32{
@@ -348,6 +366,7 @@ func TestAttribute(t *testing.T) {
 	surface := samples10Surface(values)
 
 	interpolationMethod, _ := GetInterpolationMethod("nearest")
+	verticalInterpolation, _ := GetVerticalInterpolationMethod("")
 	const above = float32(8.0)
 	const below = float32(8.0)
 	const stepsize = float32(4.0)
@@ -361,6 +380,7 @@ func TestAttribute(t *testing.T) {
 		stepsize,
 		targetAttributes,
 		interpolationMethod,
+		verticalInterpolation,
 	)
 	require.NoErrorf(t, err, "Failed to fetch horizon, err %v", err)
 	require.Len(t, buf, len(targetAttributes),
@@ -400,6 +420,7 @@ func TestAttributeMedianForEvenSampleValue(t *testing.T) {
 	surface := samples10Surface(values)
 
 	interpolationMethod, _ := GetInterpolationMethod("nearest")
+	verticalInterpolation, _ := GetVerticalInterpolationMethod("")
 	const above = float32(8.0)
 	const below = float32(4.0)
 	const stepsize = float32(4.0)
@@ -413,6 +434,7 @@ func TestAttributeMedianForEvenSampleValue(t *testing.T) {
 		stepsize,
 		targetAttributes,
 		interpolationMethod,
+		verticalInterpolation,
 	)
 	require.NoErrorf(t, err, "Failed to fetch horizon, err %v", err)
 	require.Len(t, buf, len(targetAttributes),
@@ -474,6 +496,7 @@ func TestAttributesAboveBelowStepSizeIgnoredForSampleValue(t *testing.T) {
 
 	targetAttributes := []string{"samplevalue"}
 	interpolationMethod, _ := GetInterpolationMethod("nearest")
+	verticalInterpolation, _ := GetVerticalInterpolationMethod("")
 
 	surface := samples10Surface(values)
 
@@ -487,6 +510,7 @@ func TestAttributesAboveBelowStepSizeIgnoredForSampleValue(t *testing.T) {
 			testCase.stepsize,
 			targetAttributes,
 			interpolationMethod,
+			verticalInterpolation,
 		)
 		require.NoErrorf(t, err,
 			"[%s] Failed to fetch horizon, err: %v", testCase.name, err,
@@ -582,6 +606,7 @@ func TestAttributeSubsamplingAligned(t *testing.T) {
 	const above = float32(8)
 	const below = float32(4)
 	interpolationMethod, _ := GetInterpolationMethod("nearest")
+	verticalInterpolation, _ := GetVerticalInterpolationMethod("")
 	targetAttributes := []string{"min", "max"}
 
 	values := [][]float32{
@@ -608,6 +633,7 @@ func TestAttributeSubsamplingAligned(t *testing.T) {
 			testCase.stepsize,
 			targetAttributes,
 			interpolationMethod,
+			verticalInterpolation,
 		)
 		require.NoErrorf(t, err,
 			"[%s] Failed to fetch horizon, err: %v", testCase.name, err,
@@ -681,6 +707,7 @@ func TestAttributesUnalignedAndSubsampled(t *testing.T) {
 
 	targetAttributes := []string{"min", "max", "mean"}
 	interpolationMethod, _ := GetInterpolationMethod("nearest")
+	verticalInterpolation, _ := GetVerticalInterpolationMethod("")
 	values := [][]float32{{21}}
 
 	surface := samples10Surface(values)
@@ -694,6 +721,7 @@ func TestAttributesUnalignedAndSubsampled(t *testing.T) {
 		stepsize,
 		targetAttributes,
 		interpolationMethod,
+		verticalInterpolation,
 	)
 	require.NoErrorf(t, err, "Failed to fetch horizon, err: %v", err)
 	require.Len(t, buf, len(targetAttributes),
@@ -788,6 +816,7 @@ func TestAttributesUnaligned(t *testing.T) {
 
 	targetAttributes := []string{"min", "max", "mean"}
 	interpolationMethod, _ := GetInterpolationMethod("nearest")
+	verticalInterpolation, _ := GetVerticalInterpolationMethod("")
 
 	for _, testCase := range testCases {
 		values := [][]float32{{20 + testCase.offset}}
@@ -803,6 +832,7 @@ func TestAttributesUnaligned(t *testing.T) {
 			stepsize,
 			targetAttributes,
 			interpolationMethod,
+			verticalInterpolation,
 		)
 		require.NoErrorf(t, err,
 			"[%s] Failed to fetch horizon, err: %v", testCase.name, err,
@@ -843,6 +873,7 @@ func TestAttributesSupersampling(t *testing.T) {
 
 	targetAttributes := []string{"samplevalue", "min", "max", "mean"}
 	interpolationMethod, _ := GetInterpolationMethod("nearest")
+	verticalInterpolation, _ := GetVerticalInterpolationMethod("")
 	values := [][]float32{{26}}
 
 	surface := samples10Surface(values)
@@ -856,6 +887,7 @@ func TestAttributesSupersampling(t *testing.T) {
 		stepsize,
 		targetAttributes,
 		interpolationMethod,
+		verticalInterpolation,
 	)
 	require.NoErrorf(t, err, "Failed to fetch horizon, err: %v", err)
 	require.Len(t, buf, len(targetAttributes),
@@ -892,6 +924,7 @@ func TestAttributesEverythingUnaligned(t *testing.T) {
 
 	targetAttributes := []string{"samplevalue", "min", "max", "mean"}
 	interpolationMethod, _ := GetInterpolationMethod("nearest")
+	verticalInterpolation, _ := GetVerticalInterpolationMethod("")
 	values := [][]float32{{26}}
 
 	surface := samples10Surface(values)
@@ -905,6 +938,7 @@ func TestAttributesEverythingUnaligned(t *testing.T) {
 		stepsize,
 		targetAttributes,
 		interpolationMethod,
+		verticalInterpolation,
 	)
 	require.NoErrorf(t, err, "Failed to fetch horizon, err: %v", err)
 	require.Len(t, buf, len(targetAttributes),
@@ -953,6 +987,7 @@ func TestInvalidAboveBelow(t *testing.T) {
 	for _, testcase := range testcases {
 		surface := samples10Surface(values)
 		interpolationMethod, _ := GetInterpolationMethod("nearest")
+		verticalInterpolation, _ := GetVerticalInterpolationMethod("")
 		handle, _ := NewDSHandle(samples10)
 		defer handle.Close()
 		_, boundsErr := handle.GetAttributesAlongSurface(
@@ -962,6 +997,7 @@ func TestInvalidAboveBelow(t *testing.T) {
 			stepsize,
 			targetAttributes,
 			interpolationMethod,
+			verticalInterpolation,
 		)
 
 		require.ErrorContainsf(t, boundsErr,
@@ -980,7 +1016,7 @@ func TestAttributeMetadata(t *testing.T) {
 		{10, 10, 10, 10, 10, 10},
 	}
 	expected := AttributeMetadata{
-		Array{
+		Array: Array{
 			Format: "<f4",
 			Shape:  []int{2, 6},
 		},
@@ -1009,16 +1045,20 @@ func TestAttributeBetweenSurfaces(t *testing.T) {
 		"max_at":      {32, 20, 20, 18, fillValue, 12, fillValue, fillValue},
 		"maxabs":      {2.5, 0.5, 8.5, 5.5, fillValue, 24.5, fillValue, fillValue},
 		"maxabs_at":   {32, 20, 20, 18, fillValue, 28, fillValue, fillValue},
-		"mean":        {0.5, 0, -8.5, 5.5, fillValue, -16.5, fillValue, fillValue},
-		"meanabs":     {1.3, 0.5, 8.5, 5.5, fillValue, 16.5, fillValue, fillValue},
-		"meanpos":     {1.5, 0.5, 0, 5.5, fillValue, 0, fillValue, fillValue},
-		"meanneg":     {-1, -0.5, -8.5, 0, fillValue, -16.5, fillValue, fillValue},
-		"median":      {0.5, 0, -8.5, 5.5, fillValue, -16.5, fillValue, fillValue},
-		"rms":         {1.5, 0.5, 8.5, 5.5, fillValue, 17.442764, fillValue, fillValue},
-		"var":         {2, 0.25, 0, 0, fillValue, 32, fillValue, fillValue},
-		"sd":          {1.4142135, 0.5, 0, 0, fillValue, 5.656854, fillValue, fillValue},
-		"sumpos":      {4.5, 0.5, 0, 5.5, fillValue, 0, fillValue, fillValue},
-		"sumneg":      {-2, -0.5, -8.5, 0, fillValue, -82.5, fillValue, fillValue},
+		// Of -0.5 and 0.5 in the second window the first is returned
+		"maxabs_signed": {2.5, 0.5, -8.5, 5.5, fillValue, -24.5, fillValue, fillValue},
+		"mean":          {0.5, 0, -8.5, 5.5, fillValue, -16.5, fillValue, fillValue},
+		"meanabs":       {1.3, 0.5, 8.5, 5.5, fillValue, 16.5, fillValue, fillValue},
+		"meanpos":       {1.5, 0.5, 0, 5.5, fillValue, 0, fillValue, fillValue},
+		"meanneg":       {-1, -0.5, -8.5, 0, fillValue, -16.5, fillValue, fillValue},
+		"median":        {0.5, 0, -8.5, 5.5, fillValue, -16.5, fillValue, fillValue},
+		"rms":           {1.5, 0.5, 8.5, 5.5, fillValue, 17.442764, fillValue, fillValue},
+		"var":           {2, 0.25, 0, 0, fillValue, 32, fillValue, fillValue},
+		"sd":            {1.4142135, 0.5, 0, 0, fillValue, 5.656854, fillValue, fillValue},
+		"sumpos":        {4.5, 0.5, 0, 5.5, fillValue, 0, fillValue, fillValue},
+		"sumneg":        {-2, -0.5, -8.5, 0, fillValue, -82.5, fillValue, fillValue},
+		"sum":           {2.5, 0, -8.5, 5.5, fillValue, -82.5, fillValue, fillValue},
+		"mad":           {1, 0.5, 0, 0, fillValue, 4, fillValue, fillValue},
 	}
 
 	targetAttributes := make([]string, 0, len(expected))
@@ -1062,6 +1102,7 @@ func TestAttributeBetweenSurfaces(t *testing.T) {
 	}
 
 	interpolationMethod, _ := GetInterpolationMethod("nearest")
+	verticalInterpolation, _ := GetVerticalInterpolationMethod("")
 
 	handle, _ := NewDSHandle(samples10)
 	defer handle.Close()
@@ -1074,6 +1115,7 @@ func TestAttributeBetweenSurfaces(t *testing.T) {
 			stepsize,
 			targetAttributes,
 			interpolationMethod,
+			verticalInterpolation,
 		)
 		require.NoErrorf(t, err, "Failed to calculate attributes, err %v", err)
 		require.Len(t, buf, len(targetAttributes),
@@ -1098,12 +1140,116 @@ func TestAttributeBetweenSurfaces(t *testing.T) {
 	}
 }
 
+func TestAttributesBetweenSurfacesShapeMismatch(t *testing.T) {
+	primary := samples10Surface([][]float32{
+		{16, 20},
+		{20, 18},
+		{14, 12},
+		{12, 12},
+	})
+	/* The secondary surface only covers the first two rows of the primary */
+	secondary := samples10Surface([][]float32{
+		{32, 24},
+		{20, 18},
+	})
+	expected := []float32{-1.5, 0.5, -8.5, 5.5, fillValue, fillValue, fillValue, fillValue}
+
+	interpolationMethod, _ := GetInterpolationMethod("nearest")
+	verticalInterpolation, _ := GetVerticalInterpolationMethod("")
+
+	handle, _ := NewDSHandle(samples10)
+	defer handle.Close()
+
+	buf, err := handle.GetAttributesBetweenSurfaces(
+		primary,
+		secondary,
+		4.0,
+		[]string{"samplevalue"},
+		interpolationMethod,
+		verticalInterpolation,
+	)
+	require.NoError(t, err)
+
+	result, err := toFloat32(buf[0])
+	require.NoError(t, err)
+	require.InDeltaSlice(t, expected, *result, 0.000001)
+}
+
+func TestAttributesBetweenSurfacesGeometryMismatch(t *testing.T) {
+	primary := samples10Surface([][]float32{{16, 20}, {20, 18}})
+
+	xori := *primary.Xori + 10000
+	xinc := primary.Xinc * 2
+	secondary := samples10Surface([][]float32{{32, 24}, {20, 18}, {24, 24}})
+	secondary.Xori = &xori
+	secondary.Xinc = xinc
+
+	interpolationMethod, _ := GetInterpolationMethod("nearest")
+	verticalInterpolation, _ := GetVerticalInterpolationMethod("")
+
+	handle, _ := NewDSHandle(samples10)
+	defer handle.Close()
+
+	_, err := handle.GetAttributesBetweenSurfaces(
+		primary,
+		secondary,
+		4.0,
+		[]string{"samplevalue"},
+		interpolationMethod,
+		verticalInterpolation,
+	)
+	require.IsType(t, &InvalidArgument{}, err)
+	require.ErrorContains(t, err, "Surfaces do not overlap")
+	require.ErrorContains(t, err, "nrows (primary: 2, secondary: 3)")
+	require.ErrorContains(t, err, fmt.Sprintf(
+		"xori (primary: %v, secondary: %v)", *primary.Xori, xori,
+	))
+	require.ErrorContains(t, err, fmt.Sprintf(
+		"xinc (primary: %v, secondary: %v)", primary.Xinc, xinc,
+	))
+	require.NotContains(t, err.Error(), "yori")
+	require.NotContains(t, err.Error(), "rotation")
+}
+
+func TestAttributesBetweenSurfacesCrossing(t *testing.T) {
+	primary := samples10Surface([][]float32{
+		{16, 20},
+		{20, 18},
+	})
+	secondary := samples10Surface([][]float32{
+		{32, 24},
+		{10, 18},
+	})
+
+	interpolationMethod, _ := GetInterpolationMethod("nearest")
+	verticalInterpolation, _ := GetVerticalInterpolationMethod("")
+
+	handle, _ := NewDSHandle(samples10)
+	defer handle.Close()
+
+	_, err := handle.GetAttributesBetweenSurfaces(
+		primary,
+		secondary,
+		4.0,
+		[]string{"samplevalue"},
+		interpolationMethod,
+		verticalInterpolation,
+	)
+	require.IsType(t, &InvalidArgument{}, err)
+	require.ErrorContains(t, err,
+		"Surfaces intersect at primary surface point (1, 0): the primary "+
+			"surface is below the secondary surface there, but above it at "+
+			"point (0, 0)",
+	)
+}
+
 func TestAttributesInconsistentLength(t *testing.T) {
 	const above = float32(0)
 	const below = float32(0)
 	const stepsize = float32(4)
 	targetAttributes := []string{"samplevalue"}
 	interpolationMethod, _ := GetInterpolationMethod("nearest")
+	verticalInterpolation, _ := GetVerticalInterpolationMethod("")
 
 	goodValues := [][]float32{{20, 20, 20}, {20, 20, 20}}
 	badValues := [][]float32{{20, 20}, {20, 20, 20}}
@@ -1124,6 +1270,7 @@ func TestAttributesInconsistentLength(t *testing.T) {
 		stepsize,
 		targetAttributes,
 		interpolationMethod,
+		verticalInterpolation,
 	)
 	require.ErrorContains(t, err, errmsg, err)
 
@@ -1133,6 +1280,7 @@ func TestAttributesInconsistentLength(t *testing.T) {
 		stepsize,
 		targetAttributes,
 		interpolationMethod,
+		verticalInterpolation,
 	)
 	require.ErrorContains(t, err, errmsg, err)
 
@@ -1142,16 +1290,109 @@ func TestAttributesInconsistentLength(t *testing.T) {
 		stepsize,
 		targetAttributes,
 		interpolationMethod,
+		verticalInterpolation,
 	)
 	require.ErrorContains(t, err, errmsg, err)
 }
 
+func TestSurfaceValidation(t *testing.T) {
+	manyRows := [][]float32{{1, 1}}
+	for i := 0; i < 25; i++ {
+		manyRows = append(manyRows, []float32{1})
+	}
+
+	testcases := []struct {
+		name   string
+		values [][]float32
+		errmsg string
+	}{
+		{
+			name:   "Consistent rows",
+			values: [][]float32{{1, 2}, {3, 4}},
+			errmsg: "",
+		},
+		{
+			name:   "All deviating rows are reported",
+			values: [][]float32{{1, 2}, {1}, {1, 2}, {1, 2, 3}, {}},
+			errmsg: "Surface rows are not of the same length. " +
+				"Row 0 has 2 elements. Row 1 has 1 elements. " +
+				"Row 3 has 3 elements. Row 4 has 0 elements",
+		},
+		{
+			name:   "Deviating rows are capped",
+			values: manyRows,
+			errmsg: "Row 19 has 1 elements. Row 20 has 1 elements. ...and 5 more",
+		},
+		{
+			name:   "No rows",
+			values: [][]float32{},
+			errmsg: "Surface has no rows",
+		},
+		{
+			name:   "Empty rows",
+			values: [][]float32{{}, {}},
+			errmsg: "Surface rows have no elements",
+		},
+	}
+
+	for _, testcase := range testcases {
+		surface := samples10Surface(testcase.values)
+		err := surface.Validate()
+		if testcase.errmsg == "" {
+			require.NoErrorf(t, err, "[%s]", testcase.name)
+			continue
+		}
+		require.IsTypef(t, &InvalidArgument{}, err, "[%s]", testcase.name)
+		require.ErrorContainsf(t, err, testcase.errmsg, "[%s]", testcase.name)
+	}
+
+	handle, _ := NewDSHandle(samples10)
+	defer handle.Close()
+
+	interpolationMethod, _ := GetInterpolationMethod("nearest")
+	verticalInterpolation, _ := GetVerticalInterpolationMethod("")
+	_, err := handle.GetAttributesAlongSurface(
+		samples10Surface([][]float32{}),
+		0,
+		0,
+		4,
+		[]string{"samplevalue"},
+		interpolationMethod,
+		verticalInterpolation,
+	)
+	require.ErrorContains(t, err, "Surface has no rows")
+}
+
+func TestSurfaceValidationNonFinite(t *testing.T) {
+	nan := float32(math.NaN())
+	inf := float32(math.Inf(1))
+
+	surface := samples10Surface([][]float32{{1, 1}, {1, nan}})
+	err := surface.Validate()
+	require.IsType(t, &InvalidArgument{}, err)
+	require.ErrorContains(t, err, "invalid surface value NaN at row 1, column 1")
+
+	surface = samples10Surface([][]float32{{inf, 1}, {1, 1}})
+	err = surface.Validate()
+	require.ErrorContains(t, err, "invalid surface value +Inf at row 0, column 0")
+
+	/* NaN is a legitimate fillValue, and so are its occurrences */
+	surface = samples10Surface([][]float32{{1, nan}, {nan, 1}})
+	surface.FillValue = &nan
+	require.NoError(t, surface.Validate())
+
+	surface = samples10Surface([][]float32{{1, inf}, {1, 1}})
+	surface.FillValue = &inf
+	require.NoError(t, surface.Validate())
+}
+
 func TestAttributesAllFill(t *testing.T) {
 	const above = float32(0)
 	const below = float32(0)
 	const stepsize = float32(4)
 	targetAttributes := []string{"samplevalue", "min"}
 	interpolationMethod, _ := GetInterpolationMethod("nearest")
+	verticalInterpolation, _ := GetVerticalInterpolationMethod("")
 
 	fillValues := [][]float32{{fillValue, fillValue, fillValue}, {fillValue, fillValue, fillValue}}
 	fillSurface := samples10Surface(fillValues)
@@ -1167,6 +1408,7 @@ func TestAttributesAllFill(t *testing.T) {
 		stepsize,
 		targetAttributes,
 		interpolationMethod,
+		verticalInterpolation,
 	)
 	require.NoErrorf(t, err,
 		"Along: Failed to calculate attributes, err: %v",
@@ -1179,6 +1421,7 @@ func TestAttributesAllFill(t *testing.T) {
 		stepsize,
 		targetAttributes,
 		interpolationMethod,
+		verticalInterpolation,
 	)
 	require.NoErrorf(t, err,
 		"Between: Failed to calculate attributes, err: %v",
@@ -1198,3 +1441,854 @@ func TestAttributesAllFill(t *testing.T) {
 		require.Equalf(t, expected, *between, "[%v]", attr)
 	}
 }
+
+/** Re-sampling of the trace within the window follows the vertical
+ *  interpolation method, independently of the horizontal one
+ *
+ *  The trace is linear, such that linear and cubic (modified makima)
+ *  interpolation agree, while nearest snaps to the seismic samples at 4ms
+ *  intervals. Ties are rounded half up.
+ */
+func TestAttributesVerticalInterpolation(t *testing.T) {
+	testCases := []struct {
+		name          string
+		interpolation string
+		surface       float32
+		expected      [][]float32
+	}{
+		{
+			name:          "default",
+			interpolation: "",
+			surface:       21,
+			expected:      [][]float32{{-2.25}, {0.75}, {-0.75}},
+		},
+		{
+			name:          "cubic",
+			interpolation: "cubic",
+			surface:       21,
+			expected:      [][]float32{{-2.25}, {0.75}, {-0.75}},
+		},
+		{
+			name:          "linear",
+			interpolation: "linear",
+			surface:       21,
+			expected:      [][]float32{{-2.25}, {0.75}, {-0.75}},
+		},
+		{
+			name:          "nearest",
+			interpolation: "nearest",
+			surface:       21,
+			expected:      [][]float32{{-2.5}, {0.5}, {-1.0}},
+		},
+		{
+			name:          "nearest, half up",
+			interpolation: "nearest",
+			surface:       22,
+			expected:      [][]float32{{-1.5}, {1.5}, {0.0}},
+		},
+	}
+
+	const above = float32(8)
+	const below = float32(4)
+	const stepsize = float32(4)
+
+	targetAttributes := []string{"min", "max", "mean"}
+	interpolationMethod, _ := GetInterpolationMethod("cubic")
+
+	handle, _ := NewDSHandle(samples10)
+	defer handle.Close()
+
+	for _, testCase := range testCases {
+		verticalInterpolation, err := GetVerticalInterpolationMethod(
+			testCase.interpolation,
+		)
+		require.NoErrorf(t, err, "[%s]", testCase.name)
+
+		surface := samples10Surface([][]float32{{testCase.surface}})
+
+		buf, err := handle.GetAttributesAlongSurface(
+			surface,
+			above,
+			below,
+			stepsize,
+			targetAttributes,
+			interpolationMethod,
+			verticalInterpolation,
+		)
+		require.NoErrorf(t, err, "[%s] Failed to fetch horizon", testCase.name)
+		require.Len(t, buf, len(targetAttributes),
+			"Incorrect number of attributes returned",
+		)
+
+		for i, attr := range buf {
+			result, err := toFloat32(attr)
+			require.NoErrorf(t, err, "[%s] Couldn't convert to float32", testCase.name)
+
+			require.InDeltaSlicef(
+				t,
+				testCase.expected[i],
+				*result,
+				0.000001,
+				"[%s][%s]\nExpected: %v\nActual:   %v",
+				testCase.name,
+				targetAttributes[i],
+				testCase.expected[i],
+				*result,
+			)
+		}
+	}
+}
+
+func TestVerticalInterpolationDefaultIsCubic(t *testing.T) {
+	defaultInterpolation, _ := GetVerticalInterpolationMethod("")
+	cubicInterpolation, _ := GetInterpolationMethod("cubic")
+
+	require.Equalf(t, defaultInterpolation, cubicInterpolation,
+		"Default vertical interpolation is not cubic",
+	)
+
+	for _, name := range []string{"CuBiC", "Cubic", "CUBIC"} {
+		interpolation, err := GetVerticalInterpolationMethod(name)
+		require.NoErrorf(t, err, "[%s]", name)
+		require.Equalf(t, cubicInterpolation, interpolation, "[%s]", name)
+	}
+}
+
+func TestInvalidVerticalInterpolationMethod(t *testing.T) {
+	for _, interpolation := range []string{"sand", "angular", "triangular"} {
+		expected := NewCodedInvalidArgument(
+			"invalid_vertical_interpolation",
+			ErrorDetails{"verticalInterpolation": interpolation},
+			fmt.Sprintf(
+				"invalid vertical interpolation method '%s', valid options "+
+					"are: nearest, linear or cubic. Defaults to cubic when "+
+					"not given. Horizontal interpolation is set by "+
+					"'interpolation'",
+				interpolation,
+			),
+		)
+
+		_, err := GetVerticalInterpolationMethod(interpolation)
+		require.Equal(t, expected, err)
+	}
+}
+
+func TestDecimateSurface(t *testing.T) {
+	rotation := float32(0)
+	xori := float32(1)
+	yori := float32(2)
+	fillValue := float32(-999.25)
+	rowStep := 2
+	colStep := 3
+
+	surface := RegularSurface{
+		Values: [][]float32{
+			{0, 1, 2, 3, 4, 5, 6},
+			{10, 11, 12, 13, 14, 15, 16},
+			{20, 21, 22, 23, 24, 25, 26},
+			{30, 31, 32, 33, 34, 35, 36},
+			{40, 41, 42, 43, 44, 45, 46},
+		},
+		Rotation:  &rotation,
+		Xori:      &xori,
+		Yori:      &yori,
+		Xinc:      2,
+		Yinc:      -4,
+		FillValue: &fillValue,
+		RowStep:   &rowStep,
+		ColStep:   &colStep,
+	}
+
+	decimated, err := surface.Decimate()
+	require.NoError(t, err)
+	require.Equal(t, [][]float32{
+		{0, 3},
+		{20, 23},
+	}, decimated.Values, "Expected trailing partial steps to be dropped")
+	require.Equal(t, float32(6), decimated.Xinc)
+	require.Equal(t, float32(-8), decimated.Yinc)
+	require.Equal(t, xori, *decimated.Xori)
+	require.Equal(t, yori, *decimated.Yori)
+	require.Nil(t, decimated.RowStep)
+	require.Nil(t, decimated.ColStep)
+
+	for _, step := range []int{0, -1} {
+		invalid := surface
+		invalid.ColStep = &step
+		_, err = invalid.Decimate()
+		require.IsType(t, &InvalidArgument{}, err)
+		require.ErrorContains(t, err, "colStep must be at least 1")
+	}
+
+	tooLarge := 6
+	surface.RowStep = &tooLarge
+	_, err = surface.Decimate()
+	require.IsType(t, &InvalidArgument{}, err)
+	require.ErrorContains(t, err, "empty when decimated")
+}
+
+func TestDecimatedAttributes(t *testing.T) {
+	step := 2
+
+	full := samples10Surface([][]float32{
+		{20, 20, 24, 24},
+		{20, 20, 24, 24},
+		{28, 28, 32, 32},
+		{28, 28, 32, 32},
+	})
+	decimated := full
+	decimated.RowStep = &step
+	decimated.ColStep = &step
+
+	coarse := samples10Surface([][]float32{
+		{20, 24},
+		{28, 32},
+	})
+	coarse.Xinc *= 2
+	coarse.Yinc *= 2
+
+	interpolation, _ := GetInterpolationMethod("nearest")
+	verticalInterpolation, _ := GetVerticalInterpolationMethod("")
+	attributes := []string{"samplevalue", "max"}
+
+	handle, _ := NewDSHandle(samples10)
+	defer handle.Close()
+
+	data, metadata, err := handle.GetAttributesAlongSurfaceWithMetadata(
+		decimated,
+		8,
+		8,
+		0,
+		attributes,
+		interpolation,
+		verticalInterpolation,
+		nil,
+		nil,
+		0,
+	)
+	require.NoError(t, err)
+
+	expected, err := handle.GetAttributesAlongSurface(
+		coarse,
+		8,
+		8,
+		0,
+		attributes,
+		interpolation,
+		verticalInterpolation,
+	)
+	require.NoError(t, err)
+	require.Equal(t, expected, data)
+
+	var meta AttributeMetadata
+	require.NoError(t, json.Unmarshal(metadata, &meta))
+	require.Equal(t, []int{2, 2}, meta.Shape)
+	require.Equal(t, coarse.Xinc, *meta.Xinc)
+	require.Equal(t, coarse.Yinc, *meta.Yinc)
+
+	_, metadata, err = handle.GetAttributesAlongSurfaceWithMetadata(
+		full,
+		8,
+		8,
+		0,
+		attributes,
+		interpolation,
+		verticalInterpolation,
+		nil,
+		nil,
+		0,
+	)
+	require.NoError(t, err)
+	require.NotContains(t, string(metadata), "xinc",
+		"Expected increments only for decimated surfaces")
+}
+
+func TestPolygonMaskedAttributes(t *testing.T) {
+	surface := samples10Surface([][]float32{
+		{20, 20},
+		{20, 20},
+	})
+	interpolation, _ := GetInterpolationMethod("nearest")
+	verticalInterpolation, _ := GetVerticalInterpolationMethod("")
+	attributes := []string{"samplevalue", "max"}
+	ij, _ := GetCoordinateSystem("ij")
+
+	/* Covers the node at the origin of the surface only */
+	polygon := Polygon{
+		CoordinateSystem: ij,
+		Vertices: [][]float32{
+			{-0.5, -0.5},
+			{0.5, -0.5},
+			{0.5, 0.5},
+			{-0.5, 0.5},
+		},
+	}
+
+	handle, _ := NewDSHandle(samples10)
+	defer handle.Close()
+
+	expected, err := handle.GetAttributesAlongSurface(
+		surface,
+		8,
+		8,
+		0,
+		attributes,
+		interpolation,
+		verticalInterpolation,
+	)
+	require.NoError(t, err)
+
+	data, _, err := handle.GetAttributesAlongSurfaceWithMetadata(
+		surface,
+		8,
+		8,
+		0,
+		attributes,
+		interpolation,
+		verticalInterpolation,
+		&polygon,
+		nil,
+		0,
+	)
+	require.NoError(t, err)
+	require.Len(t, data, len(attributes))
+
+	for i, attribute := range attributes {
+		want, err := toFloat32(expected[i])
+		require.NoError(t, err)
+		got, err := toFloat32(data[i])
+		require.NoError(t, err)
+
+		require.Len(t, *got, len(*want))
+		require.Equal(t, (*want)[0], (*got)[0],
+			"[%s] Expected node inside polygon to be unaffected", attribute)
+		for j := 1; j < len(*got); j++ {
+			require.Equal(t, fillValue, (*got)[j],
+				"[%s] Expected node %d outside polygon to be masked", attribute, j)
+		}
+	}
+
+	polygon.Vertices = polygon.Vertices[:2]
+	_, _, err = handle.GetAttributesAlongSurfaceWithMetadata(
+		surface,
+		8,
+		8,
+		0,
+		attributes,
+		interpolation,
+		verticalInterpolation,
+		&polygon,
+		nil,
+		0,
+	)
+	require.ErrorContains(t, err, "at least 3 vertices")
+	require.IsType(t, &InvalidArgument{}, err)
+}
+
+func TestAttributesAlongSurfaceBand(t *testing.T) {
+	surface := samples10Surface([][]float32{
+		{20, 20},
+		{20, 24},
+		{24, 24},
+	})
+	interpolation, _ := GetInterpolationMethod("nearest")
+	verticalInterpolation, _ := GetVerticalInterpolationMethod("")
+	attributes := []string{"samplevalue", "max"}
+
+	handle, _ := NewDSHandle(samples10)
+	defer handle.Close()
+
+	expected, err := handle.GetAttributesAlongSurface(
+		surface,
+		8,
+		8,
+		0,
+		attributes,
+		interpolation,
+		verticalInterpolation,
+	)
+	require.NoError(t, err)
+
+	testCases := []struct {
+		band SurfaceBand
+		from int
+		to   int
+	}{
+		{band: SurfaceBand{RowOffset: 0, RowCount: 1}, from: 0, to: 1},
+		{band: SurfaceBand{RowOffset: 1, RowCount: 1}, from: 1, to: 2},
+		{band: SurfaceBand{RowOffset: 1, RowCount: 5}, from: 1, to: 3},
+	}
+
+	const rowsize = 2 * 4
+	for _, testCase := range testCases {
+		band := testCase.band
+		data, metadata, err := handle.GetAttributesAlongSurfaceWithMetadata(
+			surface,
+			8,
+			8,
+			0,
+			attributes,
+			interpolation,
+			verticalInterpolation,
+			nil,
+			&band,
+			0,
+		)
+		require.NoErrorf(t, err, "[band: %v]", band)
+		require.Len(t, data, len(attributes))
+		for i := range attributes {
+			require.Equalf(t,
+				expected[i][testCase.from*rowsize:testCase.to*rowsize],
+				data[i],
+				"[band: %v] Expected the rows of the whole surface",
+				band,
+			)
+		}
+
+		var meta AttributeMetadata
+		require.NoError(t, json.Unmarshal(metadata, &meta))
+		require.Equal(t, []int{testCase.to - testCase.from, 2}, meta.Shape)
+		require.Equal(t, AttributeBand{
+			RowOffset: testCase.from,
+			RowCount:  testCase.to - testCase.from,
+			Nrows:     3,
+		}, *meta.Band)
+	}
+
+	for _, band := range []SurfaceBand{
+		{RowOffset: 3, RowCount: 1},
+		{RowOffset: -1, RowCount: 1},
+	} {
+		_, _, err = handle.GetAttributesAlongSurfaceWithMetadata(
+			surface,
+			8,
+			8,
+			0,
+			attributes,
+			interpolation,
+			verticalInterpolation,
+			nil,
+			&band,
+			0,
+		)
+		require.ErrorContainsf(t, err, "the surface has 3 rows", "[band: %v]", band)
+		require.IsType(t, &InvalidArgument{}, err)
+	}
+
+	band := SurfaceBand{RowOffset: 0, RowCount: 0}
+	_, _, err = handle.GetAttributesAlongSurfaceWithMetadata(
+		surface,
+		8,
+		8,
+		0,
+		attributes,
+		interpolation,
+		verticalInterpolation,
+		nil,
+		&band,
+		0,
+	)
+	require.ErrorContains(t, err, "rowCount must be at least 1")
+	require.IsType(t, &InvalidArgument{}, err)
+}
+
+/*
+ * The dead traces cube has x = inline and y = crossline. The surface covers
+ * inlines 62-65, where 64 and 65 have no data, so their nodes get the fill
+ * value rather than attributes of absent data.
+ */
+func TestAttributesDeadTraces(t *testing.T) {
+	if _, err := os.Stat(deadTracesPath); err != nil {
+		t.Skipf("%s not found, generate it with make_dead_traces.py", deadTracesPath)
+	}
+
+	handle, err := NewDSHandle(make_connection("dead_traces/dead_traces.vds"))
+	require.NoError(t, err)
+	defer handle.Close()
+
+	rotation := float32(0)
+	xori := float32(62)
+	yori := float32(0)
+	surface := RegularSurface{
+		Values:    [][]float32{{8, 8}, {8, 8}, {8, 8}, {8, 8}},
+		Rotation:  &rotation,
+		Xori:      &xori,
+		Yori:      &yori,
+		Xinc:      1,
+		Yinc:      1,
+		FillValue: &fillValue,
+	}
+
+	attributes := []string{"samplevalue", "min", "mean", "rms"}
+	expected := []float32{620, 621, 630, 631, fillValue, fillValue, fillValue, fillValue}
+
+	interpolation, _ := GetInterpolationMethod("nearest")
+	verticalInterpolation, _ := GetVerticalInterpolationMethod("")
+
+	for _, minValidFraction := range []float32{0, 1} {
+		data, metadata, err := handle.GetAttributesAlongSurfaceWithMetadata(
+			surface,
+			4,
+			4,
+			0,
+			attributes,
+			interpolation,
+			verticalInterpolation,
+			nil,
+			nil,
+			minValidFraction,
+		)
+		require.NoErrorf(t, err, "[minValidFraction: %v]", minValidFraction)
+
+		for i, attribute := range attributes {
+			values, err := toFloat32(data[i])
+			require.NoError(t, err)
+			require.Equalf(t, expected, *values,
+				"[minValidFraction: %v, attribute: %s]", minValidFraction, attribute)
+		}
+
+		var meta AttributeMetadata
+		require.NoError(t, json.Unmarshal(metadata, &meta))
+		require.Equal(t, FillValue(fillValue), *meta.FillValue)
+
+		for _, part := range meta.Parts {
+			require.Equalf(t,
+				AttributeCoverage{
+					Total: 8,
+					Valid: 4,
+					Fill:  AttributeFillCounts{DeadTraces: 4},
+				},
+				part.Coverage,
+				"[minValidFraction: %v, attribute: %s]",
+				minValidFraction,
+				part.Name,
+			)
+		}
+	}
+}
+
+func TestAttributesMinValidFractionOutOfRange(t *testing.T) {
+	handle, _ := NewDSHandle(samples10)
+	defer handle.Close()
+
+	interpolation, _ := GetInterpolationMethod("nearest")
+	verticalInterpolation, _ := GetVerticalInterpolationMethod("")
+	surface := samples10Surface([][]float32{{20, 20}, {20, 20}})
+
+	for _, minValidFraction := range []float32{-0.1, 1.5} {
+		_, _, err := handle.GetAttributesAlongSurfaceWithMetadata(
+			surface,
+			4,
+			4,
+			0,
+			[]string{"min"},
+			interpolation,
+			verticalInterpolation,
+			nil,
+			nil,
+			minValidFraction,
+		)
+		require.ErrorContainsf(t, err, "minValidFraction must be between 0 and 1",
+			"[along: %v]", minValidFraction)
+		require.IsType(t, &InvalidArgument{}, err)
+
+		_, _, err = handle.GetAttributesBetweenSurfacesWithMetadata(
+			surface,
+			surface,
+			0,
+			[]string{"min"},
+			interpolation,
+			verticalInterpolation,
+			minValidFraction,
+		)
+		require.ErrorContainsf(t, err, "minValidFraction must be between 0 and 1",
+			"[between: %v]", minValidFraction)
+		require.IsType(t, &InvalidArgument{}, err)
+	}
+}
+
+func TestAttributeMetadataParts(t *testing.T) {
+	handle, _ := NewDSHandle(samples10)
+	defer handle.Close()
+
+	interpolation, _ := GetInterpolationMethod("nearest")
+	verticalInterpolation, _ := GetVerticalInterpolationMethod("")
+	surface := samples10Surface([][]float32{{20, 20}, {20, 20}, {20, 20}})
+	attributes := []string{"RMS", "min", "samplevalue"}
+
+	array := Array{Format: "<f4", Shape: []int{3, 2}}
+	coverage := AttributeCoverage{Total: 6, Valid: 6}
+	expected := []AttributePart{
+		{Name: "rms", Array: array, Coverage: coverage},
+		{Name: "min", Array: array, Coverage: coverage},
+		{Name: "samplevalue", Array: array, Coverage: coverage},
+	}
+
+	_, along, err := handle.GetAttributesAlongSurfaceWithMetadata(
+		surface,
+		8,
+		8,
+		0,
+		attributes,
+		interpolation,
+		verticalInterpolation,
+		nil,
+		nil,
+		0,
+	)
+	require.NoError(t, err)
+
+	_, between, err := handle.GetAttributesBetweenSurfacesWithMetadata(
+		surface,
+		surface,
+		0,
+		attributes,
+		interpolation,
+		verticalInterpolation,
+		0,
+	)
+	require.NoError(t, err)
+
+	for _, metadata := range [][]byte{along, between} {
+		var meta AttributeMetadata
+		require.NoError(t, json.Unmarshal(metadata, &meta))
+		require.Equal(t, expected, meta.Parts)
+		require.Equal(t, array, meta.Array,
+			"Expected the top-level shape and format to be kept")
+	}
+
+	metadata, err := handle.GetSurfaceAttributeMetadata(surface)
+	require.NoError(t, err)
+	require.NotContains(t, string(metadata), "parts",
+		"Expected parts only along with the attributes")
+}
+
+/*
+ * The traces of well_known increase by one per sample, from 100 + 4 * (2i + j)
+ * at inline index i and crossline index j, so that every window in the test is
+ * the base of its trace plus the indices of the samples in it.
+ */
+func TestAttributeSumMedianMad(t *testing.T) {
+	handle, _ := NewDSHandle(well_known)
+	defer handle.Close()
+
+	interpolation, _ := GetInterpolationMethod("nearest")
+	verticalInterpolation, _ := GetVerticalInterpolationMethod("")
+	attributes := []string{"sum", "median", "mad"}
+
+	surface := func(depth float32) RegularSurface {
+		return RegularSurface{
+			Values:    [][]float32{{depth, depth}, {depth, depth}, {depth, depth}},
+			Rotation:  &well_known_grid.rotation,
+			Xori:      &well_known_grid.xori,
+			Yori:      &well_known_grid.yori,
+			Xinc:      well_known_grid.xinc,
+			Yinc:      well_known_grid.yinc,
+			FillValue: &fillValue,
+		}
+	}
+
+	testcases := []struct {
+		name     string
+		depth    float32
+		above    float32
+		below    float32
+		expected map[string][]float32
+	}{
+		{
+			name:  "Single sample",
+			depth: 8,
+			expected: map[string][]float32{
+				"sum":    {101, 105, 109, 113, 117, 121},
+				"median": {101, 105, 109, 113, 117, 121},
+				"mad":    {0, 0, 0, 0, 0, 0},
+			},
+		},
+		{
+			name:  "Two samples",
+			depth: 8,
+			above: 4,
+			expected: map[string][]float32{
+				"sum":    {201, 209, 217, 225, 233, 241},
+				"median": {100.5, 104.5, 108.5, 112.5, 116.5, 120.5},
+				"mad":    {0.5, 0.5, 0.5, 0.5, 0.5, 0.5},
+			},
+		},
+		{
+			name:  "Odd number of samples",
+			depth: 8,
+			above: 4,
+			below: 4,
+			expected: map[string][]float32{
+				"sum":    {303, 315, 327, 339, 351, 363},
+				"median": {101, 105, 109, 113, 117, 121},
+				"mad":    {1, 1, 1, 1, 1, 1},
+			},
+		},
+		{
+			name:  "Even number of samples",
+			depth: 8,
+			above: 4,
+			below: 8,
+			expected: map[string][]float32{
+				"sum":    {406, 422, 438, 454, 470, 486},
+				"median": {101.5, 105.5, 109.5, 113.5, 117.5, 121.5},
+				"mad":    {1, 1, 1, 1, 1, 1},
+			},
+		},
+	}
+
+	for _, testcase := range testcases {
+		buf, err := handle.GetAttributesAlongSurface(
+			surface(testcase.depth),
+			testcase.above,
+			testcase.below,
+			0,
+			attributes,
+			interpolation,
+			verticalInterpolation,
+		)
+		require.NoErrorf(t, err, "[%s]", testcase.name)
+		require.Len(t, buf, len(attributes))
+
+		for i, attr := range buf {
+			result, err := toFloat32(attr)
+			require.NoError(t, err)
+			require.InDeltaSlicef(
+				t,
+				testcase.expected[attributes[i]],
+				*result,
+				0.000001,
+				"[%s, attribute: %s]",
+				testcase.name,
+				attributes[i],
+			)
+		}
+	}
+
+	/* Between 4 and 12 ms the windows are the first three samples */
+	buf, err := handle.GetAttributesBetweenSurfaces(
+		surface(4),
+		surface(12),
+		0,
+		attributes,
+		interpolation,
+		verticalInterpolation,
+	)
+	require.NoError(t, err)
+	expected := [][]float32{
+		{303, 315, 327, 339, 351, 363},
+		{101, 105, 109, 113, 117, 121},
+		{1, 1, 1, 1, 1, 1},
+	}
+	for i, attr := range buf {
+		result, err := toFloat32(attr)
+		require.NoError(t, err)
+		require.InDeltaSlicef(t, expected[i], *result, 0.000001,
+			"[between, attribute: %s]", attributes[i])
+	}
+}
+
+/*
+ * The surface has a row more than well_known has inlines, so it hangs off the
+ * edge of the survey, and a single node of it is missing.
+ */
+func TestAttributeCoverage(t *testing.T) {
+	handle, _ := NewDSHandle(well_known)
+	defer handle.Close()
+
+	interpolation, _ := GetInterpolationMethod("nearest")
+	verticalInterpolation, _ := GetVerticalInterpolationMethod("")
+	surface := RegularSurface{
+		Values:    [][]float32{{fillValue, 8}, {8, 8}, {8, 8}, {8, 8}},
+		Rotation:  &well_known_grid.rotation,
+		Xori:      &well_known_grid.xori,
+		Yori:      &well_known_grid.yori,
+		Xinc:      well_known_grid.xinc,
+		Yinc:      well_known_grid.yinc,
+		FillValue: &fillValue,
+	}
+	attributes := []string{"samplevalue", "min", "rms"}
+
+	testcases := []struct {
+		name     string
+		band     *SurfaceBand
+		expected AttributeCoverage
+		values   []float32
+	}{
+		{
+			name: "Whole surface",
+			expected: AttributeCoverage{
+				Total: 8,
+				Valid: 5,
+				Fill: AttributeFillCounts{
+					MissingSurface: 1,
+					OutsideSurvey:  2,
+				},
+			},
+			values: []float32{
+				fillValue, 105,
+				109, 113,
+				117, 121,
+				fillValue, fillValue,
+			},
+		},
+		{
+			name: "Band at the edge",
+			band: &SurfaceBand{RowOffset: 2, RowCount: 2},
+			expected: AttributeCoverage{
+				Total: 4,
+				Valid: 2,
+				Fill:  AttributeFillCounts{OutsideSurvey: 2},
+			},
+			values: []float32{117, 121, fillValue, fillValue},
+		},
+	}
+
+	for _, testcase := range testcases {
+		data, metadata, err := handle.GetAttributesAlongSurfaceWithMetadata(
+			surface,
+			0,
+			0,
+			0,
+			attributes,
+			interpolation,
+			verticalInterpolation,
+			nil,
+			testcase.band,
+			0,
+		)
+		require.NoErrorf(t, err, "[%s]", testcase.name)
+
+		values, err := toFloat32(data[0])
+		require.NoError(t, err)
+		require.Equalf(t, testcase.values, *values,
+			"[%s] Expected the fill values to match the coverage", testcase.name)
+
+		var meta AttributeMetadata
+		require.NoError(t, json.Unmarshal(metadata, &meta))
+		require.Len(t, meta.Parts, len(attributes))
+		for _, part := range meta.Parts {
+			require.Equalf(t, testcase.expected, part.Coverage,
+				"[%s, attribute: %s]", testcase.name, part.Name)
+		}
+	}
+
+	_, metadata, err := handle.GetAttributesBetweenSurfacesWithMetadata(
+		surface,
+		surface,
+		0,
+		attributes,
+		interpolation,
+		verticalInterpolation,
+		0,
+	)
+	require.NoError(t, err)
+
+	var meta AttributeMetadata
+	require.NoError(t, json.Unmarshal(metadata, &meta))
+	for _, part := range meta.Parts {
+		require.Equalf(t, testcases[0].expected, part.Coverage,
+			"[between surfaces, attribute: %s]", part.Name)
+	}
+}
@@ -0,0 +1,109 @@
+package core
+
+/*
+#include <capi.h>
+#include <ctypes.h>
+#include <stdlib.h>
+*/
+import "C"
+import (
+	"unsafe"
+)
+
+// @Description A numeric property of two cubes
+type NumberComparison struct {
+	// Value in cube a
+	A float64 `json:"a" example:"1"`
+
+	// Value in cube b
+	B float64 `json:"b" example:"3"`
+
+	// b - a
+	Difference float64 `json:"difference" example:"2"`
+
+	// Whether the two are equal
+	Match bool `json:"match" example:"false"`
+} // @name NumberComparison
+
+// @Description A textual property of two cubes
+type TextComparison struct {
+	// Value in cube a
+	A string `json:"a" example:"ms"`
+
+	// Value in cube b
+	B string `json:"b" example:"ms"`
+
+	// Whether the two are equal
+	Match bool `json:"match" example:"true"`
+} // @name TextComparison
+
+// @Description Comparison of an axis of two cubes
+type AxisCompatibility struct {
+	Annotation TextComparison   `json:"annotation"`
+	Min        NumberComparison `json:"min"`
+	Max        NumberComparison `json:"max"`
+	Samples    NumberComparison `json:"samples"`
+	StepSize   NumberComparison `json:"stepsize"`
+	Unit       TextComparison   `json:"unit"`
+
+	// Description of every mismatch, as they would be reported by requests
+	// that combine the two cubes
+	Mismatches []string `json:"mismatches" example:"Axis: Inline: Mismatch in min value: 1.00 != 3.00"`
+
+	// Whether the axes match in every property
+	Compatible bool `json:"compatible" example:"false"`
+} // @name AxisCompatibility
+
+// @Description Comparison of the world transforms of two cubes
+type WorldCompatibility struct {
+	// Distance between the corresponding corners of the bounding boxes of
+	// the two cubes, in world coordinates
+	CornerDistances []float64 `json:"cornerDistances" example:"0,0,0.5,0.5"`
+
+	// The largest of the corner distances
+	MaxDistance float64 `json:"maxDistance" example:"0.5"`
+
+	// The largest corner distance the transforms are considered to agree by
+	Tolerance float64 `json:"tolerance" example:"0.01"`
+
+	// Whether the transforms agree within the tolerance
+	Compatible bool `json:"compatible" example:"false"`
+} // @name WorldCompatibility
+
+// @Description Geometrical compatibility of two cubes
+type Compatibility struct {
+	// Comparison of the inline, crossline and sample axes, in that order
+	Axis []AxisCompatibility `json:"axis"`
+
+	World WorldCompatibility `json:"world"`
+
+	// Whether the cubes are compatible, i.e. all axes and the world
+	// transforms match
+	Compatible bool `json:"compatible" example:"false"`
+} // @name Compatibility
+
+/** Compare the geometry of two cubes
+ *
+ * The axes are compared by the same checks that requests combining two cubes
+ * use. The world transforms agree if no corner of the bounding boxes is
+ * further apart than tolerance.
+ */
+func GetCompatibility(a VdsHandle, b VdsHandle, tolerance float64) ([]byte, error) {
+	var result C.struct_response
+	cerr := C.compatibility(
+		a.context(),
+		a.DataSource(),
+		b.DataSource(),
+		C.double(tolerance),
+		&result,
+	)
+
+	defer C.response_delete(&result)
+
+	if err := a.Error(cerr); err != nil {
+		return nil, err
+	}
+
+	buf := C.GoBytes(unsafe.Pointer(result.data), C.int(result.size))
+	return buf, nil
+}
@@ -0,0 +1,110 @@
+package core
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func getCompatibility(
+	t *testing.T,
+	a Connection,
+	b Connection,
+	tolerance float64,
+) Compatibility {
+	handleA, err := NewDSHandle(a)
+	require.NoError(t, err)
+	defer handleA.Close()
+
+	handleB, err := NewDSHandle(b)
+	require.NoError(t, err)
+	defer handleB.Close()
+
+	buf, err := GetCompatibility(handleA, handleB, tolerance)
+	require.NoError(t, err)
+
+	var compatibility Compatibility
+	err = json.Unmarshal(buf, &compatibility)
+	require.NoError(t, err)
+	return compatibility
+}
+
+func TestCompatibilityOfIdenticalGeometry(t *testing.T) {
+	doubleValue := make_connection("10_samples/10_double_value.vds")
+	compatibility := getCompatibility(t, samples10, doubleValue, 0.01)
+
+	require.True(t, compatibility.Compatible)
+	require.True(t, compatibility.World.Compatible)
+	require.Equal(t, 0.0, compatibility.World.MaxDistance)
+	require.Len(t, compatibility.Axis, 3)
+	for _, axis := range compatibility.Axis {
+		require.True(t, axis.Compatible)
+		require.Empty(t, axis.Mismatches)
+		require.Equal(t, 0.0, axis.Min.Difference)
+	}
+}
+
+func TestCompatibilityOfShiftedAxis(t *testing.T) {
+	missOffset := make_connection("10_samples/10_miss_offset.vds")
+	compatibility := getCompatibility(t, samples10, missOffset, 0.01)
+
+	require.False(t, compatibility.Compatible)
+	require.True(t, compatibility.Axis[0].Compatible)
+	require.True(t, compatibility.Axis[2].Compatible)
+
+	crossline := compatibility.Axis[1]
+	require.False(t, crossline.Compatible)
+	require.Equal(t, "Crossline", crossline.Annotation.A)
+	require.True(t, crossline.Annotation.Match)
+	require.Equal(t,
+		NumberComparison{A: 10, B: 12, Difference: 2, Match: false},
+		crossline.Min,
+	)
+	require.Equal(t,
+		NumberComparison{A: 11, B: 13, Difference: 2, Match: false},
+		crossline.Max,
+	)
+	require.True(t, crossline.Samples.Match)
+	require.Equal(t,
+		[]string{
+			"Axis: Crossline: Mismatch in min value: 10.00 != 12.00",
+			"Axis: Crossline: Mismatch in max value: 11.00 != 13.00",
+		},
+		crossline.Mismatches,
+	)
+}
+
+func TestCompatibilityOfMissingSamples(t *testing.T) {
+	missingSamples := make_connection("10_samples/10_missing_samples.vds")
+	compatibility := getCompatibility(t, samples10, missingSamples, 0.01)
+
+	require.False(t, compatibility.Compatible)
+
+	sample := compatibility.Axis[2]
+	require.False(t, sample.Compatible)
+	require.Equal(t,
+		NumberComparison{A: 10, B: 8, Difference: -2, Match: false},
+		sample.Samples,
+	)
+	require.Equal(t,
+		NumberComparison{A: 40, B: 32, Difference: -8, Match: false},
+		sample.Max,
+	)
+	require.True(t, sample.Min.Match)
+}
+
+func TestCompatibilityWorldTolerance(t *testing.T) {
+	missingIline := make_connection("10_samples/10_missing_iline.vds")
+
+	compatibility := getCompatibility(t, samples10, missingIline, 0.01)
+	require.False(t, compatibility.World.Compatible)
+	require.Len(t, compatibility.World.CornerDistances, 4)
+	require.Greater(t, compatibility.World.MaxDistance, 0.01)
+	require.Equal(t, 0.01, compatibility.World.Tolerance)
+
+	tolerance := compatibility.World.MaxDistance
+	compatibility = getCompatibility(t, samples10, missingIline, tolerance)
+	require.True(t, compatibility.World.Compatible)
+	require.False(t, compatibility.Compatible, "Inline axis still differs")
+}
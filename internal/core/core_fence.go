@@ -7,16 +7,14 @@ package core
 */
 import "C"
 import (
+	"encoding/json"
 	"fmt"
+	"strconv"
 	"unsafe"
 )
 
-func (v DSHandle) GetFence(
-	coordinateSystem int,
-	coordinates [][]float32,
-	interpolation int,
-	fillValue *float32,
-) ([]byte, error) {
+/** Flatten fence coordinates into the [x y x y ...] layout core expects */
+func toCCoordinates(coordinates [][]float32) ([]C.float, error) {
 	coordinate_len := 2
 	ccoordinates := make([]C.float, len(coordinates)*coordinate_len)
 	for i := range coordinates {
@@ -27,13 +25,30 @@ func (v DSHandle) GetFence(
 				coordinates[i],
 				i,
 			)
-			return nil, NewInvalidArgument(msg)
+			return nil, NewCodedInvalidArgument(
+				"invalid_coordinate",
+				ErrorDetails{"position": strconv.Itoa(i)},
+				msg,
+			)
 		}
 
 		for j := range coordinates[i] {
 			ccoordinates[i*coordinate_len+j] = C.float(coordinates[i][j])
 		}
 	}
+	return ccoordinates, nil
+}
+
+func (v VdsHandle) GetFence(
+	coordinateSystem int,
+	coordinates [][]float32,
+	interpolation int,
+	fillValue *float32,
+) ([]byte, error) {
+	ccoordinates, err := toCCoordinates(coordinates)
+	if err != nil {
+		return nil, err
+	}
 
 	var result C.struct_response
 	cerr := C.fence(
@@ -57,12 +72,36 @@ func (v DSHandle) GetFence(
 	return buf, nil
 }
 
-func (v DSHandle) GetFenceMetadata(coordinates [][]float32) ([]byte, error) {
+/** Metadata of a fence
+ *
+ * The coordinates are only transformed for the nearest_trace interpolation,
+ * whose metadata holds the indices of the traces they snapped to.
+ */
+func (v VdsHandle) GetFenceMetadata(
+	coordinateSystem int,
+	coordinates [][]float32,
+	interpolation int,
+	fillValue *float32,
+) ([]byte, error) {
+	ccoordinates, err := toCCoordinates(coordinates)
+	if err != nil {
+		return nil, err
+	}
+
+	var cpoints *C.float
+	if len(ccoordinates) > 0 {
+		cpoints = &ccoordinates[0]
+	}
+
 	var result C.struct_response
 	cerr := C.fence_metadata(
 		v.context(),
 		v.DataSource(),
+		C.enum_coordinate_system(coordinateSystem),
+		cpoints,
 		C.size_t(len(coordinates)),
+		C.enum_interpolation_method(interpolation),
+		(*C.float)(fillValue),
 		&result,
 	)
 
@@ -75,3 +114,238 @@ func (v DSHandle) GetFenceMetadata(coordinates [][]float32) ([]byte, error) {
 	buf := C.GoBytes(unsafe.Pointer(result.data), C.int(result.size))
 	return buf, nil
 }
+
+/** A fence and its metadata
+ *
+ * Equivalent to GetFenceMetadata followed by GetFence, but the coordinates
+ * are only converted and, for nearest_trace, snapped to traces once.
+ */
+func (v VdsHandle) GetFenceWithMetadata(
+	coordinateSystem int,
+	coordinates [][]float32,
+	interpolation int,
+	fillValue *float32,
+) (data []byte, metadata []byte, err error) {
+	ccoordinates, err := toCCoordinates(coordinates)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var cpoints *C.float
+	if len(ccoordinates) > 0 {
+		cpoints = &ccoordinates[0]
+	}
+
+	var cData C.struct_response
+	var cMetadata C.struct_response
+	cerr := C.fence_with_metadata(
+		v.context(),
+		v.DataSource(),
+		C.enum_coordinate_system(coordinateSystem),
+		cpoints,
+		C.size_t(len(coordinates)),
+		C.enum_interpolation_method(interpolation),
+		(*C.float)(fillValue),
+		&cData,
+		&cMetadata,
+	)
+
+	defer C.response_delete(&cData)
+	defer C.response_delete(&cMetadata)
+
+	if err := v.Error(cerr); err != nil {
+		return nil, nil, err
+	}
+
+	metadata = C.GoBytes(unsafe.Pointer(cMetadata.data), C.int(cMetadata.size))
+	data = C.GoBytes(unsafe.Pointer(cData.data), C.int(cData.size))
+	return data, metadata, nil
+}
+
+/** The vertical axis of the cube, which is always the last one */
+func (v VdsHandle) sampleAxis() (*Axis, error) {
+	axes, err := v.Axes()
+	if err != nil {
+		return nil, err
+	}
+	return axes[len(axes)-1], nil
+}
+
+/* The number of samples of a trace resampled to interval, in the cube unit */
+func resampledTraceLength(sampleAxis *Axis, interval float32) int {
+	return int((sampleAxis.Max-sampleAxis.Min)/float64(interval)+1e-3) + 1
+}
+
+/** The number of samples per trace of a fence resampled to interval
+ *
+ * interval is in the unit of conversion, and is not validated.
+ */
+func (v VdsHandle) ResampledTraceLength(
+	interval float32,
+	conversion VerticalUnitConversion,
+) (int, error) {
+	sampleAxis, err := v.sampleAxis()
+	if err != nil {
+		return 0, err
+	}
+	return resampledTraceLength(sampleAxis, conversion.ToNative(interval)), nil
+}
+
+/** Resample the traces of a fence to interval
+ *
+ * data and metadata are those of a fence, and are returned with the traces
+ * resampled. interval is in the unit of conversion, and must be at least 1/8
+ * of the sample interval of the cube. The traces are resampled with the
+ * horizontal interpolation of the fence, where nearest_trace resamples as
+ * nearest. The other fence interpolations have no vertical counterpart.
+ */
+func (v VdsHandle) ResampleFence(
+	data []byte,
+	metadata []byte,
+	interval float32,
+	interpolation int,
+	conversion VerticalUnitConversion,
+) ([]byte, []byte, error) {
+	switch interpolation {
+	case C.NEAREST, C.LINEAR, C.CUBIC:
+	case C.NEAREST_TRACE:
+		interpolation = C.NEAREST
+	default:
+		return nil, nil, NewInvalidArgument(
+			"resampleTo requires interpolation nearest, linear, cubic or nearest_trace",
+		)
+	}
+
+	if interval <= 0 {
+		return nil, nil, NewInvalidArgument(
+			fmt.Sprintf("resampleTo must be positive, got %v", interval),
+		)
+	}
+
+	sampleAxis, err := v.sampleAxis()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	native := conversion.ToNative(interval)
+	if float64(native) < sampleAxis.StepSize/8 {
+		return nil, nil, NewInvalidArgument(fmt.Sprintf(
+			"resampleTo must be at least %v, 1/8 of the sample interval of the cube, got %v",
+			conversion.FromNative(sampleAxis.StepSize/8),
+			interval,
+		))
+	}
+
+	var fence FenceMetadata
+	if err := json.Unmarshal(metadata, &fence); err != nil {
+		return nil, nil, NewInternalError(err.Error())
+	}
+	ntraces := fence.Shape[0]
+
+	var ctraces *C.float
+	if len(data) > 0 {
+		ctraces = (*C.float)(unsafe.Pointer(&data[0]))
+	}
+
+	var result C.struct_response
+	cerr := C.resample_traces(
+		v.context(),
+		v.DataSource(),
+		ctraces,
+		C.size_t(ntraces),
+		C.float(native),
+		C.enum_interpolation_method(interpolation),
+		&result,
+	)
+
+	defer C.response_delete(&result)
+
+	if err := v.Error(cerr); err != nil {
+		return nil, nil, err
+	}
+
+	resampled := C.GoBytes(unsafe.Pointer(result.data), C.int(result.size))
+
+	/* An empty fence has no traces to count the samples of */
+	nsamples := resampledTraceLength(sampleAxis, native)
+	if ntraces > 0 {
+		nsamples = len(resampled) / 4 / ntraces
+	}
+	fence.Shape = []int{ntraces, nsamples}
+	fence.SampleInterval = &interval
+
+	metadata, err = json.Marshal(fence)
+	if err != nil {
+		return nil, nil, NewInternalError(err.Error())
+	}
+	return resampled, metadata, nil
+}
+
+/** The trace headers of a fence
+ *
+ * A row of float32 per coordinate, one per field, from the header channels
+ * of the trace nearest to the coordinate. Fields are matched by name,
+ * ignoring case. Coordinates outside of the survey get fillValue, or fail
+ * the request if it is nil.
+ */
+func (v VdsHandle) GetFenceHeaders(
+	coordinateSystem int,
+	coordinates [][]float32,
+	fields []string,
+	fillValue *float32,
+) ([]byte, error) {
+	ccoordinates, err := toCCoordinates(coordinates)
+	if err != nil {
+		return nil, err
+	}
+	if len(ccoordinates) == 0 || len(fields) == 0 {
+		return []byte{}, nil
+	}
+
+	cfields := make([]*C.char, len(fields))
+	for i, field := range fields {
+		cfields[i] = C.CString(field)
+		defer C.free(unsafe.Pointer(cfields[i]))
+	}
+
+	var result C.struct_response
+	cerr := C.fence_headers(
+		v.context(),
+		v.DataSource(),
+		C.enum_coordinate_system(coordinateSystem),
+		&ccoordinates[0],
+		C.size_t(len(coordinates)),
+		&cfields[0],
+		C.size_t(len(fields)),
+		(*C.float)(fillValue),
+		&result,
+	)
+
+	defer C.response_delete(&result)
+
+	if err := v.Error(cerr); err != nil {
+		return nil, err
+	}
+
+	buf := C.GoBytes(unsafe.Pointer(result.data), C.int(result.size))
+	return buf, nil
+}
+
+/* The fence metadata with the trace headers of fields as its second part */
+func FenceHeadersMetadata(metadata []byte, fields []string) ([]byte, error) {
+	var fence FenceMetadata
+	if err := json.Unmarshal(metadata, &fence); err != nil {
+		return nil, NewInternalError(err.Error())
+	}
+
+	fence.Headers = &FenceHeaders{
+		Array:  Array{Format: "<f4", Shape: []int{fence.Shape[0], len(fields)}},
+		Fields: fields,
+	}
+
+	out, err := json.Marshal(fence)
+	if err != nil {
+		return nil, NewInternalError(err.Error())
+	}
+	return out, nil
+}
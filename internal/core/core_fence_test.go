@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"os"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -337,10 +338,15 @@ func TestFenceInterpolationDefaultIsNearest(t *testing.T) {
 
 func TestInvalidInterpolationMethod(t *testing.T) {
 	options := "nearest, linear, cubic, angular or triangular"
-	expected := NewInvalidArgument(fmt.Sprintf(
-		"invalid interpolation method 'sand', valid options are: %s",
-		options,
-	))
+	expected := NewCodedInvalidArgument(
+		"invalid_interpolation",
+		ErrorDetails{"interpolation": "sand"},
+		fmt.Sprintf(
+			"invalid interpolation method 'sand', valid options are: %s. "+
+				"Defaults to nearest when not given",
+			options,
+		),
+	)
 
 	interpolation := "sand"
 	_, err := GetInterpolationMethod(interpolation)
@@ -350,23 +356,40 @@ func TestInvalidInterpolationMethod(t *testing.T) {
 
 func TestFenceInterpolationCaseInsensitive(t *testing.T) {
 	expectedInterpolation, _ := GetInterpolationMethod("cubic")
-	interpolation, _ := GetInterpolationMethod("CuBiC")
+	for _, name := range []string{"CuBiC", "Cubic", "CUBIC"} {
+		interpolation, err := GetInterpolationMethod(name)
+		require.NoErrorf(t, err, "[%s]", name)
+		require.Equalf(t, expectedInterpolation, interpolation, "[%s]", name)
+
+		interpolation, err = GetFenceInterpolationMethod(name)
+		require.NoErrorf(t, err, "[%s]", name)
+		require.Equalf(t, expectedInterpolation, interpolation, "[%s]", name)
+	}
 
-	require.Equal(t, interpolation, expectedInterpolation)
+	expectedInterpolation, _ = GetFenceInterpolationMethod("nearest_trace")
+	interpolation, err := GetFenceInterpolationMethod("Nearest_Trace")
+	require.NoError(t, err)
+	require.Equal(t, expectedInterpolation, interpolation)
 }
 
 func TestFenceMetadata(t *testing.T) {
 	coordinates := [][]float32{{5, 10}, {5, 10}, {1, 11}, {2, 11}, {4, 11}}
 	expected := FenceMetadata{
-		Array{
+		Array: Array{
 			Format: "<f4",
 			Shape:  []int{5, 4},
 		},
 	}
+	interpolationMethod, _ := GetFenceInterpolationMethod("nearest")
 
 	handle, _ := NewDSHandle(well_known)
 	defer handle.Close()
-	buf, err := handle.GetFenceMetadata(coordinates)
+	buf, err := handle.GetFenceMetadata(
+		CoordinateSystemAnnotation,
+		coordinates,
+		interpolationMethod,
+		nil,
+	)
 	require.NoErrorf(t, err, "Failed to retrieve fence metadata, err %v", err)
 
 	var meta FenceMetadata
@@ -377,3 +400,537 @@ func TestFenceMetadata(t *testing.T) {
 
 	require.Equal(t, expected, meta)
 }
+
+func TestInvalidFenceInterpolationMethod(t *testing.T) {
+	options := "nearest, linear, cubic, angular, triangular, nearest_trace or none"
+	expected := NewCodedInvalidArgument(
+		"invalid_interpolation",
+		ErrorDetails{"interpolation": "sand"},
+		fmt.Sprintf(
+			"invalid interpolation method 'sand', valid options are: %s. "+
+				"Defaults to nearest when not given",
+			options,
+		),
+	)
+
+	_, err := GetFenceInterpolationMethod("sand")
+	require.Equal(t, expected, err)
+
+	_, err = GetInterpolationMethod("nearest_trace")
+	require.ErrorContains(t, err, "invalid interpolation method 'nearest_trace'")
+}
+
+/** Interpolation none reads the traces as is, as long as every coordinate is
+ *  within a thousandth of the trace spacing of a trace. Inlines are 2 apart
+ *  in the well_known cube, and crosslines 1.
+ */
+func TestFenceNoInterpolation(t *testing.T) {
+	interpolationMethod, err := GetFenceInterpolationMethod("none")
+	require.NoError(t, err)
+	nearestTrace, _ := GetFenceInterpolationMethod("nearest_trace")
+
+	handle, _ := NewDSHandle(well_known)
+	defer handle.Close()
+
+	valid := []struct {
+		coordinateSystem int
+		coordinates      [][]float32
+	}{
+		{
+			coordinateSystem: CoordinateSystemIndex,
+			coordinates:      [][]float32{{1, 0}, {0, 1}, {2, 0}},
+		},
+		{
+			coordinateSystem: CoordinateSystemAnnotation,
+			coordinates:      [][]float32{{3, 10}, {1, 11}, {5, 10}},
+		},
+		{
+			coordinateSystem: CoordinateSystemAnnotation,
+			coordinates:      [][]float32{{3.0015, 10}, {1, 10.9995}, {4.999, 10}},
+		},
+		{
+			coordinateSystem: CoordinateSystemCdp,
+			coordinates:      [][]float32{{8, 4}, {0, 3}, {14, 8}},
+		},
+	}
+
+	expected, err := handle.GetFence(
+		CoordinateSystemAnnotation,
+		[][]float32{{3, 10}, {1, 11}, {5, 10}},
+		nearestTrace,
+		nil,
+	)
+	require.NoError(t, err)
+
+	for _, testcase := range valid {
+		buf, err := handle.GetFence(
+			testcase.coordinateSystem,
+			testcase.coordinates,
+			interpolationMethod,
+			nil,
+		)
+		require.NoErrorf(t, err, "%v", testcase.coordinates)
+		require.Equalf(t, expected, buf, "%v", testcase.coordinates)
+	}
+
+	invalid := []struct {
+		coordinateSystem int
+		coordinates      [][]float32
+		expected         string
+	}{
+		{
+			coordinateSystem: CoordinateSystemAnnotation,
+			coordinates:      [][]float32{{3, 10}, {3, 10.01}},
+			expected: "Coordinate (3.000000,10.010000) at position 1 is not at a trace, " +
+				"which interpolation 'none' requires. The nearest trace is " +
+				"inline 3.00, crossline 10.00",
+		},
+		{
+			coordinateSystem: CoordinateSystemAnnotation,
+			coordinates:      [][]float32{{3.003, 11}},
+			expected:         "at position 0 is not at a trace",
+		},
+		{
+			coordinateSystem: CoordinateSystemIndex,
+			coordinates:      [][]float32{{1, 0}, {1, 0}, {1, 0.5}},
+			expected:         "at position 2 is not at a trace",
+		},
+		{
+			coordinateSystem: CoordinateSystemCdp,
+			coordinates:      [][]float32{{8.1, 4}},
+			expected:         "The nearest trace is inline 3.00, crossline 10.00",
+		},
+	}
+
+	for _, testcase := range invalid {
+		_, err := handle.GetFence(
+			testcase.coordinateSystem,
+			testcase.coordinates,
+			interpolationMethod,
+			nil,
+		)
+		require.ErrorContainsf(t, err, testcase.expected, "%v", testcase.coordinates)
+		require.IsTypef(t, &InvalidArgument{}, err, "%v", testcase.coordinates)
+	}
+
+	_, err = GetInterpolationMethod("none")
+	require.ErrorContains(t, err, "invalid interpolation method 'none'")
+}
+
+/** Traces read with nearest_trace are bit-for-bit equal to the same traces
+ *  read through a slice. Ties snap half up, and consecutive coordinates that
+ *  snap to the same trace are all returned.
+ */
+func TestFenceNearestTraceMatchesSlice(t *testing.T) {
+	coordinates := [][]float32{
+		{3.9, 10.4},
+		{3.1, 10.2},
+		{2.1, 10.6},
+		{4, 10.5},
+		{1, 11},
+	}
+	expectedIndices := [][]int{{1, 0}, {1, 0}, {1, 1}, {2, 1}, {0, 1}}
+	inlines := []int{1, 3, 5}
+
+	interpolationMethod, err := GetFenceInterpolationMethod("nearest_trace")
+	require.NoError(t, err)
+
+	handle, _ := NewDSHandle(well_known)
+	defer handle.Close()
+
+	buf, err := handle.GetFence(
+		CoordinateSystemAnnotation,
+		coordinates,
+		interpolationMethod,
+		nil,
+	)
+	require.NoError(t, err)
+
+	metabuf, err := handle.GetFenceMetadata(
+		CoordinateSystemAnnotation,
+		coordinates,
+		interpolationMethod,
+		nil,
+	)
+	require.NoError(t, err)
+
+	var meta FenceMetadata
+	err = json.Unmarshal(metabuf, &meta)
+	require.NoError(t, err)
+	require.Equal(t, expectedIndices, meta.Indices)
+	require.Equal(t, []int{len(coordinates), 4}, meta.Shape)
+
+	traceSize := 4 * 4 // 4 samples of float32
+	require.Len(t, buf, len(coordinates)*traceSize)
+
+	for i, index := range expectedIndices {
+		slice, err := handle.GetSlice(
+			inlines[index[0]],
+			AxisInline,
+			CoordinateSystemAnnotation,
+			[]Bound{},
+			nil,
+		)
+		require.NoError(t, err)
+
+		expected := slice[index[1]*traceSize : (index[1]+1)*traceSize]
+		actual := buf[i*traceSize : (i+1)*traceSize]
+		require.Equalf(t, expected, actual,
+			"Trace of coordinate %v differs from slice", coordinates[i],
+		)
+	}
+}
+
+func TestFenceNearestTraceOutOfBounds(t *testing.T) {
+	coordinates := [][]float32{{3, 10}, {-1, 10}, {3, 12}}
+
+	interpolationMethod, _ := GetFenceInterpolationMethod("nearest_trace")
+
+	handle, _ := NewDSHandle(well_known)
+	defer handle.Close()
+
+	_, err := handle.GetFence(
+		CoordinateSystemAnnotation,
+		coordinates,
+		interpolationMethod,
+		nil,
+	)
+	require.ErrorContains(t, err, "is out of boundaries in dimension 0")
+
+	buf, err := handle.GetFence(
+		CoordinateSystemAnnotation,
+		coordinates,
+		interpolationMethod,
+		&fillValue,
+	)
+	require.NoError(t, err)
+
+	fence, err := toFloat32(buf)
+	require.NoError(t, err)
+	expected := []float32{
+		108, 109, 110, 111, // il: 3, xl: 10
+		fillValue, fillValue, fillValue, fillValue,
+		fillValue, fillValue, fillValue, fillValue,
+	}
+	require.Equal(t, expected, *fence)
+
+	metabuf, err := handle.GetFenceMetadata(
+		CoordinateSystemAnnotation,
+		coordinates,
+		interpolationMethod,
+		&fillValue,
+	)
+	require.NoError(t, err)
+
+	var meta FenceMetadata
+	err = json.Unmarshal(metabuf, &meta)
+	require.NoError(t, err)
+	require.Equal(t, [][]int{{1, 0}, nil, nil}, meta.Indices)
+	require.Equal(t, FillValue(fillValue), *meta.FillValue)
+}
+
+func TestFenceFillValueDeadTraces(t *testing.T) {
+	if _, err := os.Stat(deadTracesPath); err != nil {
+		t.Skipf("%s not found, generate it with make_dead_traces.py", deadTracesPath)
+	}
+
+	handle, err := NewDSHandle(make_connection("dead_traces/dead_traces.vds"))
+	require.NoError(t, err)
+	defer handle.Close()
+
+	coordinates := [][]float32{{62, 1}, {64, 0}, {127, 1}}
+	expected := []float32{
+		621, 621, 621, 621,
+		fillValue, fillValue, fillValue, fillValue,
+		fillValue, fillValue, fillValue, fillValue,
+	}
+
+	for _, method := range []string{"nearest", "linear", "nearest_trace"} {
+		interpolation, err := GetFenceInterpolationMethod(method)
+		require.NoError(t, err)
+
+		data, metadata, err := handle.GetFenceWithMetadata(
+			CoordinateSystemIndex,
+			coordinates,
+			interpolation,
+			&fillValue,
+		)
+		require.NoErrorf(t, err, "[%s]", method)
+
+		fence, err := toFloat32(data)
+		require.NoError(t, err)
+		require.Equalf(t, expected, *fence, "[%s] Incorrect fence", method)
+
+		var meta FenceMetadata
+		require.NoError(t, json.Unmarshal(metadata, &meta))
+		require.Equalf(t, FillValue(fillValue), *meta.FillValue, "[%s]", method)
+	}
+}
+
+func TestFenceHeadersMetadata(t *testing.T) {
+	metadata := []byte(
+		`{"shape": [3, 4], "format": "<f4", "fillValue": "nan", ` +
+			`"indices": [[1, 0], null, [2, 1]]}`,
+	)
+
+	out, err := FenceHeadersMetadata(metadata, []string{"cdp", "Offset"})
+	require.NoError(t, err)
+
+	expected := `{
+		"shape": [3, 4],
+		"format": "<f4",
+		"fillValue": "nan",
+		"indices": [[1, 0], null, [2, 1]],
+		"headers": {"shape": [3, 2], "format": "<f4", "fields": ["cdp", "Offset"]}
+	}`
+	require.JSONEq(t, expected, string(out))
+}
+
+func TestFenceHeadersNoHeaderChannels(t *testing.T) {
+	handle, _ := NewDSHandle(well_known)
+	defer handle.Close()
+
+	_, err := handle.GetFenceHeaders(
+		CoordinateSystemAnnotation,
+		[][]float32{{3, 10}},
+		[]string{"cdp"},
+		nil,
+	)
+	require.IsType(t, &InvalidArgument{}, err)
+	require.ErrorContains(t, err, "Header field 'cdp' is not in the VDS")
+	require.ErrorContains(t, err, "no header channels")
+}
+
+func TestFenceWithMetadataMatchesSeparateCalls(t *testing.T) {
+	fillValue := float32(-999.25)
+
+	testcases := []struct {
+		name          string
+		coordinates   [][]float32
+		interpolation string
+		fillValue     *float32
+	}{
+		{
+			name:          "linear",
+			coordinates:   [][]float32{{3, 10}, {1, 11.5}},
+			interpolation: "linear",
+		},
+		{
+			name:          "nearest trace",
+			coordinates:   [][]float32{{3.9, 10.4}, {3.1, 10.2}, {1, 11}},
+			interpolation: "nearest_trace",
+		},
+		{
+			name:          "nearest trace with fill value",
+			coordinates:   [][]float32{{3, 10}, {-1, 10}},
+			interpolation: "nearest_trace",
+			fillValue:     &fillValue,
+		},
+		{
+			name:          "out of bounds",
+			coordinates:   [][]float32{{3, 10}, {3, 12}},
+			interpolation: "nearest_trace",
+		},
+	}
+
+	handle, _ := NewDSHandle(well_known)
+	defer handle.Close()
+
+	for _, testcase := range testcases {
+		interpolation, err := GetFenceInterpolationMethod(testcase.interpolation)
+		require.NoError(t, err)
+
+		expectedMetadata, metadataErr := handle.GetFenceMetadata(
+			CoordinateSystemAnnotation,
+			testcase.coordinates,
+			interpolation,
+			testcase.fillValue,
+		)
+		expectedData, dataErr := handle.GetFence(
+			CoordinateSystemAnnotation,
+			testcase.coordinates,
+			interpolation,
+			testcase.fillValue,
+		)
+
+		data, metadata, err := handle.GetFenceWithMetadata(
+			CoordinateSystemAnnotation,
+			testcase.coordinates,
+			interpolation,
+			testcase.fillValue,
+		)
+
+		expectedErr := metadataErr
+		if expectedErr == nil {
+			expectedErr = dataErr
+		}
+		if expectedErr != nil {
+			require.EqualErrorf(t, err, expectedErr.Error(),
+				"[case: %v]", testcase.name)
+			continue
+		}
+
+		require.NoErrorf(t, err, "[case: %v]", testcase.name)
+		require.Equalf(t, expectedMetadata, metadata, "[case: %v]", testcase.name)
+		require.Equalf(t, expectedData, data, "[case: %v]", testcase.name)
+	}
+}
+
+func BenchmarkFenceSeparateCalls(b *testing.B) {
+	coordinates := [][]float32{{3.9, 10.4}, {3.1, 10.2}, {2.1, 10.6}, {1, 11}}
+	interpolation, _ := GetFenceInterpolationMethod("nearest_trace")
+
+	handle, _ := NewDSHandle(well_known)
+	defer handle.Close()
+
+	for i := 0; i < b.N; i++ {
+		_, err := handle.GetFenceMetadata(
+			CoordinateSystemAnnotation,
+			coordinates,
+			interpolation,
+			nil,
+		)
+		require.NoError(b, err)
+		_, err = handle.GetFence(
+			CoordinateSystemAnnotation,
+			coordinates,
+			interpolation,
+			nil,
+		)
+		require.NoError(b, err)
+	}
+}
+
+func BenchmarkFenceWithMetadata(b *testing.B) {
+	coordinates := [][]float32{{3.9, 10.4}, {3.1, 10.2}, {2.1, 10.6}, {1, 11}}
+	interpolation, _ := GetFenceInterpolationMethod("nearest_trace")
+
+	handle, _ := NewDSHandle(well_known)
+	defer handle.Close()
+
+	for i := 0; i < b.N; i++ {
+		_, _, err := handle.GetFenceWithMetadata(
+			CoordinateSystemAnnotation,
+			coordinates,
+			interpolation,
+			nil,
+		)
+		require.NoError(b, err)
+	}
+}
+
+func TestFenceResample(t *testing.T) {
+	testcases := []struct {
+		interpolation string
+		interval      float32
+		unit          string
+		expected      []float32
+	}{
+		{
+			interpolation: "linear",
+			interval:      2,
+			expected:      []float32{108, 108.5, 109, 109.5, 110, 110.5, 111},
+		},
+		{
+			interpolation: "linear",
+			interval:      0.002,
+			unit:          "s",
+			expected:      []float32{108, 108.5, 109, 109.5, 110, 110.5, 111},
+		},
+		{
+			interpolation: "nearest",
+			interval:      2,
+			expected:      []float32{108, 109, 109, 110, 110, 111, 111},
+		},
+		{
+			interpolation: "nearest_trace",
+			interval:      8,
+			expected:      []float32{108, 110},
+		},
+	}
+
+	for _, testcase := range testcases {
+		handle, _ := NewDSHandle(well_known)
+		defer handle.Close()
+
+		interpolation, _ := GetFenceInterpolationMethod(testcase.interpolation)
+		conversion, err := handle.VerticalUnitConversion(testcase.unit)
+		require.NoError(t, err)
+
+		data, metadata, err := handle.GetFenceWithMetadata(
+			CoordinateSystemAnnotation,
+			[][]float32{{3, 10}},
+			interpolation,
+			nil,
+		)
+		require.NoError(t, err)
+
+		data, metadata, err = handle.ResampleFence(
+			data,
+			metadata,
+			testcase.interval,
+			interpolation,
+			conversion,
+		)
+		require.NoErrorf(t, err, "[%s %v]", testcase.interpolation, testcase.interval)
+
+		fence, err := toFloat32(data)
+		require.NoError(t, err)
+		require.Equalf(t, testcase.expected, *fence,
+			"[%s %v] Incorrect fence", testcase.interpolation, testcase.interval)
+
+		var meta FenceMetadata
+		require.NoError(t, json.Unmarshal(metadata, &meta))
+		require.Equal(t, []int{1, len(testcase.expected)}, meta.Shape)
+		require.Equal(t, testcase.interval, *meta.SampleInterval)
+	}
+}
+
+func TestFenceResampleInvalid(t *testing.T) {
+	testcases := []struct {
+		interpolation string
+		interval      float32
+		err           string
+	}{
+		{
+			interpolation: "linear",
+			interval:      0.25,
+			err:           "resampleTo must be at least 0.5, 1/8 of the sample interval of the cube, got 0.25",
+		},
+		{
+			interpolation: "linear",
+			interval:      0,
+			err:           "resampleTo must be positive, got 0",
+		},
+		{
+			interpolation: "angular",
+			interval:      2,
+			err:           "resampleTo requires interpolation nearest, linear, cubic or nearest_trace",
+		},
+	}
+
+	for _, testcase := range testcases {
+		handle, _ := NewDSHandle(well_known)
+		defer handle.Close()
+
+		interpolation, _ := GetFenceInterpolationMethod(testcase.interpolation)
+		conversion, _ := NewVerticalUnitConversion("", "")
+
+		data, metadata, err := handle.GetFenceWithMetadata(
+			CoordinateSystemAnnotation,
+			[][]float32{{3, 10}},
+			interpolation,
+			nil,
+		)
+		require.NoError(t, err)
+
+		_, _, err = handle.ResampleFence(
+			data,
+			metadata,
+			testcase.interval,
+			interpolation,
+			conversion,
+		)
+		require.ErrorContains(t, err, testcase.err)
+	}
+}
@@ -85,3 +85,74 @@ func TestOnly3DSupported(t *testing.T) {
 
 	require.ErrorContains(t, err, "3 dimensions, got 4")
 }
+
+func TestOpenErrorClassification(t *testing.T) {
+	azure := NewAzureConnection(
+		"blob",
+		"container",
+		"account.blob.core.windows.net",
+		Credentials{Sas: "sas"},
+	)
+
+	testCases := []struct {
+		name     string
+		conn     Connection
+		msg      string
+		expected error
+	}{
+		{
+			name:     "Blob does not exist",
+			conn:     azure,
+			msg:      "Could not open VDS: Error on downloading VolumeDataLayout object: 404 The specified blob does not exist.",
+			expected: &NotFoundError{},
+		},
+		{
+			name:     "Container does not exist",
+			conn:     azure,
+			msg:      "Could not open VDS: ContainerNotFound",
+			expected: &NotFoundError{},
+		},
+		{
+			name:     "Missing credentials",
+			conn:     azure,
+			msg:      "Could not open VDS: 401 Server failed to authenticate the request.",
+			expected: &UnauthorizedError{},
+		},
+		{
+			name:     "Anonymous access",
+			conn:     azure,
+			msg:      "Could not open VDS: 409 Public access is not permitted on this storage account.",
+			expected: &UnauthorizedError{},
+		},
+		{
+			name:     "Expired signature",
+			conn:     azure,
+			msg:      "Could not open VDS: 403 Signature not valid in the specified time frame",
+			expected: &UnauthorizedError{},
+		},
+		{
+			name:     "Insufficient access",
+			conn:     azure,
+			msg:      "Could not open VDS: 403 Server failed to authenticate the request.",
+			expected: &ForbiddenError{},
+		},
+		{
+			name:     "Local file does not exist",
+			conn:     NewFileConnection("file://does/not/exist.vds"),
+			msg:      "Could not open VDS: something went wrong",
+			expected: &NotFoundError{},
+		},
+		{
+			name:     "Unrecognized error",
+			conn:     azure,
+			msg:      "Could not open VDS: something went wrong",
+			expected: &InternalError{},
+		},
+	}
+
+	for _, testCase := range testCases {
+		err := classifyOpenError(testCase.conn, testCase.msg)
+		require.IsTypef(t, testCase.expected, err, "[%s]", testCase.name)
+		require.Equalf(t, testCase.msg, err.Error(), "[%s]", testCase.name)
+	}
+}
@@ -0,0 +1,191 @@
+package core
+
+import (
+	"encoding/json"
+	"math"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const line2dPath = "../../testdata/2d_line/2d_line_default.vds"
+
+var line2d = make_connection("2d_line/2d_line_default.vds")
+
+/*
+ * The 2D line fixture needs an OpenVDS with 2D import, see
+ * testdata/2d_line/convert_2d_line.sh. The tests are skipped without it.
+ */
+func open2dLine(t *testing.T) VdsHandle {
+	if _, err := os.Stat(line2dPath); err != nil {
+		t.Skipf("2D line fixture %s is not generated", line2dPath)
+	}
+
+	handle, err := NewDSHandle(line2d)
+	require.NoError(t, err)
+	t.Cleanup(func() { handle.Close() })
+	return handle
+}
+
+func TestLineMetadata(t *testing.T) {
+	handle := open2dLine(t)
+
+	buf, err := handle.GetMetadata(false, false)
+	require.NoError(t, err)
+
+	var meta Metadata
+	require.NoError(t, json.Unmarshal(buf, &meta))
+
+	require.Len(t, meta.Axis, 2, "Expected no inline axis for a 2D line")
+	require.Equal(t, 5, meta.Axis[0].Samples)
+	require.Equal(t, 10.0, meta.Axis[0].Min)
+	require.Equal(t, 14.0, meta.Axis[0].Max)
+	require.Equal(t, 4, meta.Axis[1].Samples)
+
+	require.Equal(t, BoundingBox{
+		Cdp:  [][]float64{{2, 0}, {2, 0}, {14, 8}, {14, 8}},
+		Ilxl: [][]float64{{0, 10}, {0, 10}, {0, 14}, {0, 14}},
+		Ij:   [][]float64{{0, 0}, {0, 0}, {0, 4}, {0, 4}},
+	}, meta.BoundingBox)
+}
+
+func TestLineSlice(t *testing.T) {
+	handle := open2dLine(t)
+
+	testcases := []struct {
+		name      string
+		lineno    int
+		direction int
+		expected  []float32
+	}{
+		{
+			name:      "crossline",
+			lineno:    11,
+			direction: AxisCrossline,
+			expected:  []float32{104, 105, 106, 107},
+		},
+		{
+			name:      "j",
+			lineno:    1,
+			direction: AxisJ,
+			expected:  []float32{104, 105, 106, 107},
+		},
+		{
+			name:      "time",
+			lineno:    8,
+			direction: AxisTime,
+			expected:  []float32{101, 105, 109, 113, 117},
+		},
+	}
+
+	for _, testcase := range testcases {
+		buf, err := handle.GetSlice(
+			testcase.lineno,
+			testcase.direction,
+			linenoSystem(testcase.direction),
+			[]Bound{},
+			nil,
+		)
+		require.NoErrorf(t, err, "[case: %v]", testcase.name)
+
+		slice, err := toFloat32(buf)
+		require.NoErrorf(t, err, "[case: %v]", testcase.name)
+		require.Equalf(t, testcase.expected, *slice, "[case: %v]", testcase.name)
+	}
+
+	for _, direction := range []int{AxisInline, AxisI} {
+		_, err := handle.GetSlice(0, direction, linenoSystem(direction), nil, nil)
+		require.ErrorContains(t, err, "does not exist in a 2D line")
+	}
+}
+
+func TestLineFence(t *testing.T) {
+	handle := open2dLine(t)
+
+	expected := []float32{
+		104, 105, 106, 107, // cdp: 11
+		112, 113, 114, 115, // cdp: 13
+	}
+
+	testcases := []struct {
+		coordinateSystem int
+		coordinates      [][]float32
+	}{
+		{
+			coordinateSystem: CoordinateSystemIndex,
+			coordinates:      [][]float32{{0, 1}, {0, 3}},
+		},
+		{
+			coordinateSystem: CoordinateSystemAnnotation,
+			coordinates:      [][]float32{{0, 11}, {0, 13}},
+		},
+		{
+			coordinateSystem: CoordinateSystemCdp,
+			coordinates:      [][]float32{{5.4, 2.2}, {10.9, 5.8}},
+		},
+	}
+	interpolation, _ := GetInterpolationMethod("nearest")
+
+	for _, testcase := range testcases {
+		buf, err := handle.GetFence(
+			testcase.coordinateSystem,
+			testcase.coordinates,
+			interpolation,
+			nil,
+		)
+		require.NoErrorf(t, err, "[system: %v]", testcase.coordinateSystem)
+
+		fence, err := toFloat32(buf)
+		require.NoError(t, err)
+		require.Equalf(t, expected, *fence, "[system: %v]", testcase.coordinateSystem)
+	}
+
+	/* Far off the line, but nearest to its first trace */
+	buf, err := handle.GetFence(
+		CoordinateSystemCdp,
+		[][]float32{{-100, -100}},
+		interpolation,
+		&fillValue,
+	)
+	require.NoError(t, err)
+	fence, err := toFloat32(buf)
+	require.NoError(t, err)
+	require.Equal(t, []float32{fillValue, fillValue, fillValue, fillValue}, *fence)
+}
+
+func TestLineAttributesAlongSurface(t *testing.T) {
+	handle := open2dLine(t)
+
+	/* A single row along the line, with a node at every trace */
+	rotation := float32(math.Atan2(2, 3) * 180 / math.Pi)
+	xori := float32(2)
+	yori := float32(0)
+	surface := RegularSurface{
+		Values:    [][]float32{{8, 8, 8, 8, 8}},
+		Rotation:  &rotation,
+		Xori:      &xori,
+		Yori:      &yori,
+		Xinc:      float32(math.Hypot(3, 2)),
+		Yinc:      1,
+		FillValue: &fillValue,
+	}
+
+	interpolation, _ := GetInterpolationMethod("nearest")
+	verticalInterpolation, _ := GetVerticalInterpolationMethod("")
+
+	buf, err := handle.GetAttributesAlongSurface(
+		surface,
+		0,
+		0,
+		0,
+		[]string{"samplevalue"},
+		interpolation,
+		verticalInterpolation,
+	)
+	require.NoError(t, err)
+
+	values, err := toFloat32(buf[0])
+	require.NoError(t, err)
+	require.Equal(t, []float32{101, 105, 109, 113, 117}, *values)
+}
@@ -2,6 +2,7 @@ package core
 
 import (
 	"encoding/json"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -10,9 +11,9 @@ import (
 func TestMetadata(t *testing.T) {
 	expected := Metadata{
 		Axis: []*Axis{
-			{Annotation: "Inline", Min: 1, Max: 5, Samples: 3, StepSize: 2, Unit: "unitless"},
-			{Annotation: "Crossline", Min: 10, Max: 11, Samples: 2, StepSize: 1, Unit: "unitless"},
-			{Annotation: "Sample", Min: 4, Max: 16, Samples: 4, StepSize: 4, Unit: "ms"},
+			{Annotation: "Inline", Min: 1, Max: 5, Samples: 3, StepSize: 2, Regular: true, Unit: "unitless", RawUnit: "unitless"},
+			{Annotation: "Crossline", Min: 10, Max: 11, Samples: 2, StepSize: 1, Regular: true, Unit: "unitless", RawUnit: "unitless"},
+			{Annotation: "Sample", Min: 4, Max: 16, Samples: 4, StepSize: 4, Regular: true, Unit: "ms", RawUnit: "ms"},
 		},
 		BoundingBox: BoundingBox{
 			Cdp:  [][]float64{{2, 0}, {14, 8}, {12, 11}, {0, 3}},
@@ -22,11 +23,12 @@ func TestMetadata(t *testing.T) {
 		Crs:             "utmXX",
 		InputFileName:   "well_known.segy",
 		ImportTimeStamp: `^\d{4}-\d{2}-\d{2}[A-Z]\d{2}:\d{2}:\d{2}\.\d{3}[A-Z]$`,
+		StoredFormat:    StoredFormat{Format: "R32", IntegerScale: 1},
 	}
 
 	handle, _ := NewDSHandle(well_known)
 	defer handle.Close()
-	buf, err := handle.GetMetadata()
+	buf, err := handle.GetMetadata(false, false)
 	require.NoErrorf(t, err, "Failed to retrieve metadata, err %v", err)
 
 	var meta Metadata
@@ -43,15 +45,15 @@ func TestMetadata(t *testing.T) {
 
 func TestMetadataCustomAxisOrder(t *testing.T) {
 	expected := []*Axis{
-		{Annotation: "Inline", Min: 1, Max: 5, Samples: 3, StepSize: 2, Unit: "unitless"},
-		{Annotation: "Crossline", Min: 10, Max: 11, Samples: 2, StepSize: 1, Unit: "unitless"},
-		{Annotation: "Sample", Min: 4, Max: 16, Samples: 4, StepSize: 4, Unit: "ms"},
+		{Annotation: "Inline", Min: 1, Max: 5, Samples: 3, StepSize: 2, Regular: true, Unit: "unitless", RawUnit: "unitless"},
+		{Annotation: "Crossline", Min: 10, Max: 11, Samples: 2, StepSize: 1, Regular: true, Unit: "unitless", RawUnit: "unitless"},
+		{Annotation: "Sample", Min: 4, Max: 16, Samples: 4, StepSize: 4, Regular: true, Unit: "ms", RawUnit: "ms"},
 	}
 	handle, err := NewDSHandle(well_known_custom_axis_order)
 	require.NoErrorf(t, err, "Failed to open vds file")
 
 	defer handle.Close()
-	buf, err := handle.GetMetadata()
+	buf, err := handle.GetMetadata(false, false)
 	require.NoErrorf(t, err, "Failed to retrieve metadata")
 
 	var meta Metadata
@@ -65,3 +67,83 @@ func TestMetadataAxesNames(t *testing.T) {
 	_, err := NewDSHandle(invalid_axes_names)
 	require.ErrorContains(t, err, expected)
 }
+
+func TestMetadataImportInfo(t *testing.T) {
+	handle, _ := NewDSHandle(well_known)
+	defer handle.Close()
+
+	buf, err := handle.GetMetadata(true, false)
+	require.NoErrorf(t, err, "Failed to retrieve metadata, err %v", err)
+
+	var meta Metadata
+	err = json.Unmarshal(buf, &meta)
+	require.NoErrorf(t, err, "Failed to unmarshall response, err: %v", err)
+
+	require.Equal(t, "well_known.segy", meta.InputFileName)
+	require.NotEmpty(t, meta.ImportTimeStamp)
+
+	header := meta.SegyTextHeader
+	require.Len(t, header, 3200)
+	require.Equal(t,
+		"C 1 DATE 2023-02-28",
+		strings.TrimRight(header[0:80], " "),
+	)
+	require.Equal(t,
+		"C 3 Written by libsegyio (python)",
+		strings.TrimRight(header[160:240], " "),
+	)
+}
+
+func TestMetadataWithoutImportInfo(t *testing.T) {
+	handle, _ := NewDSHandle(well_known)
+	defer handle.Close()
+
+	buf, err := handle.GetMetadata(false, false)
+	require.NoErrorf(t, err, "Failed to retrieve metadata, err %v", err)
+
+	var meta map[string]interface{}
+	err = json.Unmarshal(buf, &meta)
+	require.NoErrorf(t, err, "Failed to unmarshall response, err: %v", err)
+
+	require.Contains(t, meta, "importTimeStamp")
+	require.NotContains(t, meta, "segyTextHeader")
+}
+
+func TestMetadataLayout(t *testing.T) {
+	expected := &Layout{
+		BrickSize:   []int{64, 64, 64},
+		LodLevels:   4,
+		Compression: "None",
+		Channels: []Channel{
+			{Name: "Amplitude", Format: "R32", ValueRange: [2]float32{108, 115}},
+			{Name: "Trace", Format: "U8", ValueRange: [2]float32{0, 1}},
+			{Name: "SEGYTraceHeader", Format: "U8", ValueRange: [2]float32{0, 255}},
+		},
+	}
+
+	handle, _ := NewDSHandle(well_known)
+	defer handle.Close()
+
+	buf, err := handle.GetMetadata(false, true)
+	require.NoErrorf(t, err, "Failed to retrieve metadata, err %v", err)
+
+	var meta Metadata
+	err = json.Unmarshal(buf, &meta)
+	require.NoErrorf(t, err, "Failed to unmarshall response, err: %v", err)
+
+	require.Equal(t, expected, meta.Layout)
+}
+
+func TestMetadataWithoutLayout(t *testing.T) {
+	handle, _ := NewDSHandle(well_known)
+	defer handle.Close()
+
+	buf, err := handle.GetMetadata(false, false)
+	require.NoErrorf(t, err, "Failed to retrieve metadata, err %v", err)
+
+	var meta map[string]interface{}
+	err = json.Unmarshal(buf, &meta)
+	require.NoErrorf(t, err, "Failed to unmarshall response, err: %v", err)
+
+	require.NotContains(t, meta, "layout")
+}
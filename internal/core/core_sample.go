@@ -0,0 +1,112 @@
+package core
+
+/*
+#include <capi.h>
+#include <ctypes.h>
+#include <stdlib.h>
+*/
+import "C"
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"unsafe"
+)
+
+/** Flatten sample points into the [x y z x y z ...] layout core expects
+ *
+ * The z values are converted to the unit of the cube.
+ */
+func toCPoints(
+	points [][]float32,
+	conversion VerticalUnitConversion,
+) ([]C.float, error) {
+	point_len := 3
+	cpoints := make([]C.float, len(points)*point_len)
+	for i := range points {
+		if len(points[i]) != point_len {
+			msg := fmt.Sprintf(
+				"invalid coordinate %v at position %d, expected [x y z] triplet",
+				points[i],
+				i,
+			)
+			return nil, NewCodedInvalidArgument(
+				"invalid_coordinate",
+				ErrorDetails{"position": strconv.Itoa(i)},
+				msg,
+			)
+		}
+
+		cpoints[i*point_len+0] = C.float(points[i][0])
+		cpoints[i*point_len+1] = C.float(points[i][1])
+		cpoints[i*point_len+2] = C.float(conversion.ToNative(points[i][2]))
+	}
+	return cpoints, nil
+}
+
+/** The cube sampled at scattered points, and its metadata
+ *
+ * The data is two parts, the values and their validity flags, as described
+ * by SampleMetadata. Points that are not valid are given fillValue, or NaN
+ * if it is nil.
+ */
+func (v VdsHandle) GetSamplesWithMetadata(
+	coordinateSystem int,
+	points [][]float32,
+	interpolation int,
+	fillValue *float32,
+	conversion VerticalUnitConversion,
+) (data [][]byte, metadata []byte, err error) {
+	cpoints, err := toCPoints(points, conversion)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var cpointsPtr *C.float
+	if len(cpoints) > 0 {
+		cpointsPtr = &cpoints[0]
+	}
+
+	fill := FillValue(math.NaN())
+	if fillValue != nil {
+		fill = FillValue(*fillValue)
+	}
+
+	var cValues C.struct_response
+	var cValid C.struct_response
+	cerr := C.sample_points(
+		v.context(),
+		v.DataSource(),
+		C.enum_coordinate_system(coordinateSystem),
+		cpointsPtr,
+		C.size_t(len(points)),
+		C.enum_interpolation_method(interpolation),
+		C.float(fill),
+		&cValues,
+		&cValid,
+	)
+
+	defer C.response_delete(&cValues)
+	defer C.response_delete(&cValid)
+
+	if err := v.Error(cerr); err != nil {
+		return nil, nil, err
+	}
+
+	shape := []int{len(points)}
+	metadata, err = json.Marshal(SampleMetadata{
+		Array:     Array{Format: "<f4", Shape: shape},
+		Valid:     Array{Format: "|u1", Shape: shape},
+		FillValue: &fill,
+	})
+	if err != nil {
+		return nil, nil, NewInternalError(err.Error())
+	}
+
+	data = [][]byte{
+		C.GoBytes(unsafe.Pointer(cValues.data), C.int(cValues.size)),
+		C.GoBytes(unsafe.Pointer(cValid.data), C.int(cValid.size)),
+	}
+	return data, metadata, nil
+}
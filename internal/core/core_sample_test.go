@@ -0,0 +1,142 @@
+package core
+
+import (
+	"encoding/json"
+	"math"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSamplePoints(t *testing.T) {
+	expectedValues := []float32{109, 107, 116, fillValue, fillValue}
+	expectedValid := []byte{1, 1, 1, 0, 0}
+
+	testcases := []struct {
+		coordinate_system int
+		coordinates       [][]float32
+	}{
+		{
+			coordinate_system: CoordinateSystemIndex,
+			coordinates: [][]float32{
+				{1, 0, 8}, {0, 1, 16}, {2, 0, 4}, {3, 0, 8}, {1, 0, 20},
+			},
+		},
+		{
+			coordinate_system: CoordinateSystemAnnotation,
+			coordinates: [][]float32{
+				{3, 10, 8}, {1, 11, 16}, {5, 10, 4}, {7, 10, 8}, {3, 10, 20},
+			},
+		},
+		{
+			coordinate_system: CoordinateSystemCdp,
+			coordinates: [][]float32{
+				{8, 4, 8}, {0, 3, 16}, {14, 8, 4}, {20, 12, 8}, {8, 4, 20},
+			},
+		},
+	}
+	interpolation, _ := GetInterpolationMethod("nearest")
+	conversion, _ := NewVerticalUnitConversion("", "")
+
+	for _, testcase := range testcases {
+		handle, _ := NewDSHandle(well_known)
+		defer handle.Close()
+		data, metadata, err := handle.GetSamplesWithMetadata(
+			testcase.coordinate_system,
+			testcase.coordinates,
+			interpolation,
+			&fillValue,
+			conversion,
+		)
+		require.NoErrorf(t, err, "[coordinate_system: %v]", testcase.coordinate_system)
+		require.Len(t, data, 2)
+
+		values, err := toFloat32(data[0])
+		require.NoError(t, err)
+		require.Equalf(t, expectedValues, *values,
+			"[coordinate_system: %v] Incorrect values", testcase.coordinate_system)
+		require.Equalf(t, expectedValid, data[1],
+			"[coordinate_system: %v] Incorrect validity", testcase.coordinate_system)
+
+		var meta SampleMetadata
+		require.NoError(t, json.Unmarshal(metadata, &meta))
+		require.Equal(t, []int{5}, meta.Shape)
+		require.Equal(t, "<f4", meta.Format)
+		require.Equal(t, []int{5}, meta.Valid.Shape)
+		require.Equal(t, "|u1", meta.Valid.Format)
+		require.Equal(t, FillValue(fillValue), *meta.FillValue)
+	}
+}
+
+func TestSamplePointsVerticalUnit(t *testing.T) {
+	handle, _ := NewDSHandle(well_known)
+	defer handle.Close()
+
+	interpolation, _ := GetInterpolationMethod("nearest")
+	conversion, err := handle.VerticalUnitConversion("s")
+	require.NoError(t, err)
+
+	data, _, err := handle.GetSamplesWithMetadata(
+		CoordinateSystemAnnotation,
+		[][]float32{{3, 10, 0.008}},
+		interpolation,
+		nil,
+		conversion,
+	)
+	require.NoError(t, err)
+
+	values, err := toFloat32(data[0])
+	require.NoError(t, err)
+	require.Equal(t, []float32{109}, *values)
+}
+
+func TestSamplePointsInvalidTriplet(t *testing.T) {
+	handle, _ := NewDSHandle(well_known)
+	defer handle.Close()
+
+	interpolation, _ := GetInterpolationMethod("nearest")
+	conversion, _ := NewVerticalUnitConversion("", "")
+
+	_, _, err := handle.GetSamplesWithMetadata(
+		CoordinateSystemIndex,
+		[][]float32{{0, 0, 4}, {1, 0}},
+		interpolation,
+		nil,
+		conversion,
+	)
+	require.ErrorContains(t, err,
+		"invalid coordinate [1 0] at position 1, expected [x y z] triplet",
+	)
+}
+
+func TestSamplePointsDeadTraces(t *testing.T) {
+	if _, err := os.Stat(deadTracesPath); err != nil {
+		t.Skipf("%s not found, generate it with make_dead_traces.py", deadTracesPath)
+	}
+
+	handle, err := NewDSHandle(make_connection("dead_traces/dead_traces.vds"))
+	require.NoError(t, err)
+	defer handle.Close()
+
+	interpolation, _ := GetInterpolationMethod("nearest")
+	conversion, _ := NewVerticalUnitConversion("", "")
+
+	data, metadata, err := handle.GetSamplesWithMetadata(
+		CoordinateSystemCdp,
+		[][]float32{{62, 1, 8}, {64, 0, 8}},
+		interpolation,
+		nil,
+		conversion,
+	)
+	require.NoError(t, err)
+
+	values, err := toFloat32(data[0])
+	require.NoError(t, err)
+	require.Equal(t, float32(621), (*values)[0])
+	require.Equal(t, []byte{1, 0}, data[1])
+
+	var meta SampleMetadata
+	require.NoError(t, json.Unmarshal(metadata, &meta))
+	require.True(t, math.IsNaN(float64(*meta.FillValue)))
+}
@@ -7,28 +7,39 @@ package core
 */
 import "C"
 import (
+	"fmt"
 	"unsafe"
 )
 
+/** The axis of a bound, and whether it is given as indices or annotations */
+func ResolveBound(bound Bound) (axis int, system int, err error) {
+	axis, err = GetAxis(*bound.Direction)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	system, err = GetLinenoSystem(axis, bound.Mode)
+	if err != nil {
+		options := enumerate(LinenoModes())
+		msg := "invalid bound mode '%s', valid options are: %s"
+		return 0, 0, NewInvalidArgument(fmt.Sprintf(msg, bound.Mode, options))
+	}
+	return axis, system, nil
+}
+
 func newCSliceBounds(bounds []Bound) ([]C.struct_Bound, error) {
 	var cBounds []C.struct_Bound
 	for _, bound := range bounds {
-		lower := *bound.Lower
-		upper := *bound.Upper
-		axisID, err := GetAxis(*bound.Direction)
+		axisID, system, err := ResolveBound(bound)
 		if err != nil {
 			return nil, err
 		}
 
-		if upper < lower {
-			msg := "Upper bound must be >= than lower bound"
-			return nil, NewInvalidArgument(msg)
-		}
-
 		cBound := C.struct_Bound{
-			C.int(lower),
-			C.int(upper),
+			C.int(*bound.Lower),
+			C.int(*bound.Upper),
 			C.enum_axis_name(axisID),
+			C.enum_coordinate_system(system),
 		}
 		cBounds = append(cBounds, cBound)
 	}
@@ -36,7 +47,17 @@ func newCSliceBounds(bounds []Bound) ([]C.struct_Bound, error) {
 	return cBounds, nil
 }
 
-func (v DSHandle) GetSlice(lineno, direction int, bounds []Bound) ([]byte, error) {
+/*
+ * Absent data is replaced by fillValue, unless it's nil, in which case it's
+ * left as stored by OpenVDS.
+ */
+func (v VdsHandle) GetSlice(
+	lineno int,
+	direction int,
+	linenoSystem int,
+	bounds []Bound,
+	fillValue *float32,
+) ([]byte, error) {
 	var result C.struct_response
 
 	cBounds, err := newCSliceBounds(bounds)
@@ -54,8 +75,11 @@ func (v DSHandle) GetSlice(lineno, direction int, bounds []Bound) ([]byte, error
 		v.DataSource(),
 		C.int(lineno),
 		C.enum_axis_name(direction),
+		C.enum_coordinate_system(linenoSystem),
 		bound,
 		C.size_t(len(cBounds)),
+		(*C.float)(fillValue),
+		C.FLOAT32,
 		&result,
 	)
 
@@ -68,10 +92,47 @@ func (v DSHandle) GetSlice(lineno, direction int, bounds []Bound) ([]byte, error
 	return buf, nil
 }
 
-func (v DSHandle) GetSliceMetadata(
+func (v VdsHandle) GetSliceMetadata(
 	lineno int,
 	direction int,
+	linenoSystem int,
 	bounds []Bound,
+	fillValue *float32,
+) ([]byte, error) {
+	return v.getSliceMetadata(
+		lineno,
+		direction,
+		linenoSystem,
+		bounds,
+		fillValue,
+		C.FLOAT32,
+	)
+}
+
+/* Metadata of a slice as stored, see GetStoredSliceWithMetadata */
+func (v VdsHandle) GetStoredSliceMetadata(
+	lineno int,
+	direction int,
+	linenoSystem int,
+	bounds []Bound,
+) ([]byte, error) {
+	return v.getSliceMetadata(
+		lineno,
+		direction,
+		linenoSystem,
+		bounds,
+		nil,
+		C.STORED,
+	)
+}
+
+func (v VdsHandle) getSliceMetadata(
+	lineno int,
+	direction int,
+	linenoSystem int,
+	bounds []Bound,
+	fillValue *float32,
+	format C.enum_sample_format,
 ) ([]byte, error) {
 	var result C.struct_response
 
@@ -90,8 +151,11 @@ func (v DSHandle) GetSliceMetadata(
 		v.DataSource(),
 		C.int(lineno),
 		C.enum_axis_name(direction),
+		C.enum_coordinate_system(linenoSystem),
 		bound,
 		C.size_t(len(cBounds)),
+		(*C.float)(fillValue),
+		format,
 		&result,
 	)
 
@@ -101,6 +165,328 @@ func (v DSHandle) GetSliceMetadata(
 		return nil, err
 	}
 
+	buf := C.GoBytes(unsafe.Pointer(result.data), C.int(result.size))
+	return normalizeSliceMetadataUnits(buf)
+}
+
+/** A slice and its metadata
+ *
+ * Equivalent to GetSliceMetadata followed by GetSlice, but the request is
+ * only validated once, and both are read in a single call into core.
+ */
+func (v VdsHandle) GetSliceWithMetadata(
+	lineno int,
+	direction int,
+	linenoSystem int,
+	bounds []Bound,
+	fillValue *float32,
+) (data []byte, metadata []byte, err error) {
+	return v.getSliceWithMetadata(
+		lineno,
+		direction,
+		linenoSystem,
+		bounds,
+		fillValue,
+		C.FLOAT32,
+	)
+}
+
+/** A slice and its metadata, with the samples as stored in the VDS
+ *
+ * The samples are not converted to float32, and keep the quantization of
+ * cubes stored as integers. The format of the metadata tells which format
+ * they are in, and the storedFormat of the VDS metadata how they map to the
+ * values they represent. Absent data is left as stored, as it can not be
+ * told apart from data in the stored format.
+ */
+func (v VdsHandle) GetStoredSliceWithMetadata(
+	lineno int,
+	direction int,
+	linenoSystem int,
+	bounds []Bound,
+) (data []byte, metadata []byte, err error) {
+	return v.getSliceWithMetadata(
+		lineno,
+		direction,
+		linenoSystem,
+		bounds,
+		nil,
+		C.STORED,
+	)
+}
+
+func (v VdsHandle) getSliceWithMetadata(
+	lineno int,
+	direction int,
+	linenoSystem int,
+	bounds []Bound,
+	fillValue *float32,
+	format C.enum_sample_format,
+) (data []byte, metadata []byte, err error) {
+	cBounds, err := newCSliceBounds(bounds)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var bound *C.struct_Bound
+	if len(cBounds) > 0 {
+		bound = &cBounds[0]
+	}
+
+	var cData C.struct_response
+	var cMetadata C.struct_response
+	cerr := C.slice_with_metadata(
+		v.context(),
+		v.DataSource(),
+		C.int(lineno),
+		C.enum_axis_name(direction),
+		C.enum_coordinate_system(linenoSystem),
+		bound,
+		C.size_t(len(cBounds)),
+		(*C.float)(fillValue),
+		format,
+		&cData,
+		&cMetadata,
+	)
+
+	defer C.response_delete(&cData)
+	defer C.response_delete(&cMetadata)
+
+	if err := v.Error(cerr); err != nil {
+		return nil, nil, err
+	}
+
+	metadata, err = normalizeSliceMetadataUnits(
+		C.GoBytes(unsafe.Pointer(cMetadata.data), C.int(cMetadata.size)),
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	data = C.GoBytes(unsafe.Pointer(cData.data), C.int(cData.size))
+	return data, metadata, nil
+}
+
+/** Every stride'th row of a slice, starting at row first
+ *
+ * The rows are those of the data of GetSlice, i.e. along the first axis of
+ * its shape, and are returned in the same layout. Only the requested rows are
+ * read from storage. Rows past the end of the slice are not an error, there
+ * are just no more rows to return.
+ */
+func (v VdsHandle) GetSliceRows(
+	lineno int,
+	direction int,
+	linenoSystem int,
+	bounds []Bound,
+	fillValue *float32,
+	first int,
+	stride int,
+) ([]byte, error) {
+	return v.getSliceRows(
+		lineno,
+		direction,
+		linenoSystem,
+		bounds,
+		fillValue,
+		C.FLOAT32,
+		first,
+		stride,
+	)
+}
+
+/* Rows of a slice as stored, see GetSliceRows and GetStoredSliceWithMetadata */
+func (v VdsHandle) GetStoredSliceRows(
+	lineno int,
+	direction int,
+	linenoSystem int,
+	bounds []Bound,
+	first int,
+	stride int,
+) ([]byte, error) {
+	return v.getSliceRows(
+		lineno,
+		direction,
+		linenoSystem,
+		bounds,
+		nil,
+		C.STORED,
+		first,
+		stride,
+	)
+}
+
+func (v VdsHandle) getSliceRows(
+	lineno int,
+	direction int,
+	linenoSystem int,
+	bounds []Bound,
+	fillValue *float32,
+	format C.enum_sample_format,
+	first int,
+	stride int,
+) ([]byte, error) {
+	var result C.struct_response
+
+	cBounds, err := newCSliceBounds(bounds)
+	if err != nil {
+		return nil, err
+	}
+
+	var bound *C.struct_Bound
+	if len(cBounds) > 0 {
+		bound = &cBounds[0]
+	}
+
+	cerr := C.slice_rows(
+		v.context(),
+		v.DataSource(),
+		C.int(lineno),
+		C.enum_axis_name(direction),
+		C.enum_coordinate_system(linenoSystem),
+		bound,
+		C.size_t(len(cBounds)),
+		(*C.float)(fillValue),
+		format,
+		C.int(first),
+		C.int(stride),
+		&result,
+	)
+
+	defer C.response_delete(&result)
+	if err := v.Error(cerr); err != nil {
+		return nil, err
+	}
+
 	buf := C.GoBytes(unsafe.Pointer(result.data), C.int(result.size))
 	return buf, nil
 }
+
+/** Split a slice into progressively finer passes
+ *
+ * The first pass holds every strides[0]'th sample along both axes. Every
+ * following pass holds the samples at every strides[n]'th row and column
+ * that are not already in an earlier pass. Samples within a pass are in
+ * row-major order, like in the slice itself.
+ *
+ * Every stride must be a multiple of the next, and the last stride must be 1,
+ * such that the passes together hold every sample of the slice exactly once.
+ * shape is the shape of the slice, as given by its metadata, and itemsize the
+ * size of a single sample in bytes.
+ */
+func InterlaceSlice(
+	data []byte,
+	shape []int,
+	itemsize int,
+	strides []int,
+) ([][]byte, error) {
+	passes := make([][]byte, 0, len(strides))
+	for n := range strides {
+		pass, err := SlicePass(data, shape, itemsize, strides, n)
+		if err != nil {
+			return nil, err
+		}
+		passes = append(passes, pass)
+	}
+	return passes, nil
+}
+
+/** Pass n of InterlaceSlice
+ *
+ * Only the rows given by PassRows for passes 0 through n are read from data,
+ * such that a pass can be made as soon as its rows are read, without waiting
+ * for the rest of the slice.
+ */
+func SlicePass(
+	data []byte,
+	shape []int,
+	itemsize int,
+	strides []int,
+	n int,
+) ([]byte, error) {
+	if err := validateInterlace(data, shape, itemsize, strides); err != nil {
+		return nil, err
+	}
+	rows, columns := shape[0], shape[1]
+
+	stride := strides[n]
+	pass := make([]byte, 0, PassSize(shape, strides, n)*itemsize)
+	for row := 0; row < rows; row += stride {
+		for column := 0; column < columns; column += stride {
+			if n > 0 {
+				previous := strides[n-1]
+				if row%previous == 0 && column%previous == 0 {
+					continue
+				}
+			}
+			offset := (row*columns + column) * itemsize
+			pass = append(pass, data[offset:offset+itemsize]...)
+		}
+	}
+	return pass, nil
+}
+
+/** The number of samples in pass n of InterlaceSlice */
+func PassSize(shape []int, strides []int, n int) int {
+	grid := func(stride int) int {
+		return ((shape[0] + stride - 1) / stride) * ((shape[1] + stride - 1) / stride)
+	}
+	if n == 0 {
+		return grid(strides[0])
+	}
+	return grid(strides[n]) - grid(strides[n-1])
+}
+
+/** The rows of the slice pass n of InterlaceSlice needs, that no earlier pass did
+ *
+ * The rows are given as the first row and stride of GetSliceRows, of which
+ * there can be several per pass. Pass n has samples from every strides[n]'th
+ * row, of which the earlier passes have read every strides[n-1]'th.
+ */
+func PassRows(strides []int, n int) [][2]int {
+	if n == 0 {
+		return [][2]int{{0, strides[0]}}
+	}
+	rows := [][2]int{}
+	for first := strides[n]; first < strides[n-1]; first += strides[n] {
+		rows = append(rows, [2]int{first, strides[n-1]})
+	}
+	return rows
+}
+
+func validateInterlace(
+	data []byte,
+	shape []int,
+	itemsize int,
+	strides []int,
+) error {
+	if len(shape) != 2 {
+		msg := fmt.Sprintf("Expected a 2D slice, got shape %v", shape)
+		return NewInternalError(msg)
+	}
+	rows, columns := shape[0], shape[1]
+	if len(data) != rows*columns*itemsize {
+		msg := fmt.Sprintf(
+			"Slice of shape %v has %d bytes, expected %d",
+			shape,
+			len(data),
+			rows*columns*itemsize,
+		)
+		return NewInternalError(msg)
+	}
+
+	if len(strides) == 0 || strides[len(strides)-1] != 1 {
+		return NewInternalError("The last stride must be 1")
+	}
+	for i := 1; i < len(strides); i++ {
+		if strides[i] < 1 || strides[i-1]%strides[i] != 0 {
+			msg := fmt.Sprintf(
+				"Stride %d is not a multiple of stride %d",
+				strides[i-1],
+				strides[i],
+			)
+			return NewInternalError(msg)
+		}
+	}
+	return nil
+}
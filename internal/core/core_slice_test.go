@@ -3,12 +3,20 @@ package core
 import (
 	"bytes"
 	"encoding/json"
+	"math"
+	"os"
 	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/require"
 )
 
+/* The lineno system of direction, by its own convention */
+func linenoSystem(direction int) int {
+	system, _ := GetLinenoSystem(direction, "")
+	return system
+}
+
 func TestSliceData(t *testing.T) {
 	il := []float32{
 		108, 109, 110, 111, // il: 3, xl: 10, samples: all
@@ -47,7 +55,9 @@ func TestSliceData(t *testing.T) {
 		buf, err := handle.GetSlice(
 			testcase.lineno,
 			testcase.direction,
+			linenoSystem(testcase.direction),
 			[]Bound{},
+			nil,
 		)
 		require.NoErrorf(t, err,
 			"[case: %v] Failed to fetch slice, err: %v",
@@ -88,7 +98,9 @@ func TestSliceOutOfBounds(t *testing.T) {
 		_, err := handle.GetSlice(
 			testcase.lineno,
 			testcase.direction,
+			linenoSystem(testcase.direction),
 			[]Bound{},
+			nil,
 		)
 
 		require.ErrorContains(t, err, "Invalid lineno")
@@ -112,13 +124,370 @@ func TestSliceStepSizedLineno(t *testing.T) {
 		_, err := handle.GetSlice(
 			testcase.lineno,
 			testcase.direction,
+			linenoSystem(testcase.direction),
 			[]Bound{},
+			nil,
 		)
 
 		require.ErrorContains(t, err, "Invalid lineno")
 	}
 }
 
+func TestSliceLinenoMode(t *testing.T) {
+	il := []float32{
+		108, 109, 110, 111, // il: 3, xl: 10, samples: all
+		112, 113, 114, 115, // il: 3, xl: 11, samples: all
+	}
+
+	testcases := []struct {
+		name      string
+		direction int
+		mode      string
+		lineno    int
+	}{
+		{name: "inline by annotation", direction: AxisInline, mode: "annotation", lineno: 3},
+		{name: "inline by index", direction: AxisInline, mode: "index", lineno: 1},
+		{name: "i by index", direction: AxisI, mode: "index", lineno: 1},
+		{name: "i by annotation", direction: AxisI, mode: "annotation", lineno: 3},
+	}
+
+	handle, _ := NewDSHandle(well_known)
+	defer handle.Close()
+	for _, testcase := range testcases {
+		system, err := GetLinenoSystem(testcase.direction, testcase.mode)
+		require.NoError(t, err, testcase.name)
+
+		buf, err := handle.GetSlice(
+			testcase.lineno,
+			testcase.direction,
+			system,
+			[]Bound{},
+			nil,
+		)
+		require.NoError(t, err, testcase.name)
+
+		slice, err := toFloat32(buf)
+		require.NoError(t, err, testcase.name)
+		require.Equal(t, il, *slice, testcase.name)
+	}
+}
+
+func TestGetAxisOfCube(t *testing.T) {
+	cube := []*Axis{
+		{Annotation: "I"},
+		{Annotation: "Crossline"},
+		{Annotation: "TWT"},
+	}
+	line := []*Axis{
+		{Annotation: "Crossline"},
+		{Annotation: "Sample"},
+	}
+
+	testcases := []struct {
+		direction string
+		axes      []*Axis
+		expected  int
+	}{
+		/* The fixed vocabulary takes precedence over the annotation "I" */
+		{direction: "i", axes: cube, expected: AxisI},
+		{direction: "crossline", axes: cube, expected: AxisCrossline},
+		{direction: "twt", axes: cube, expected: AxisSample},
+		{direction: "TWT", axes: cube, expected: AxisSample},
+		{direction: "0", axes: cube, expected: AxisI},
+		{direction: "2", axes: cube, expected: AxisK},
+		{direction: "0", axes: line, expected: AxisJ},
+		{direction: "1", axes: line, expected: AxisK},
+	}
+
+	for _, testcase := range testcases {
+		axis, err := GetAxis(testcase.direction, testcase.axes...)
+		require.NoErrorf(t, err, "[%s]", testcase.direction)
+		require.Equalf(t, testcase.expected, axis, "[%s]", testcase.direction)
+	}
+
+	for _, direction := range []string{"3", "-1", "offset"} {
+		_, err := GetAxis(direction, cube...)
+		require.IsTypef(t, &InvalidArgument{}, err, "[%s]", direction)
+		require.ErrorContainsf(t, err, "(I, Crossline, TWT)", "[%s]", direction)
+		require.ErrorContainsf(t, err, "positions 0 to 2", "[%s]", direction)
+	}
+
+	_, err := GetAxis("0")
+	require.ErrorContains(t, err, "invalid direction '0'")
+}
+
+func TestSliceLinenoModeOutOfBounds(t *testing.T) {
+	handle, _ := NewDSHandle(well_known)
+	defer handle.Close()
+
+	_, err := handle.GetSlice(3, AxisInline, CoordinateSystemIndex, []Bound{}, nil)
+	require.EqualError(t, err,
+		"Invalid lineno: 3, valid range: [0:2:1]. The lineno is interpreted "+
+			"as an index, as an annotation the valid range is [1.00:5.00:2.00]",
+	)
+
+	_, err = handle.GetSlice(0, AxisI, CoordinateSystemAnnotation, []Bound{}, nil)
+	require.EqualError(t, err,
+		"Invalid lineno: 0, valid range: [1.00:5.00:2.00]. The lineno is "+
+			"interpreted as an annotation, as an index the valid range is [0:2:1]",
+	)
+}
+
+/*
+ * The inlines of well_known are numbered 1, 3 and 5, so annotation and index
+ * differ both in offset and step
+ */
+func TestSliceBoundModes(t *testing.T) {
+	newBound := func(direction, mode string, lower, upper int) Bound {
+		return Bound{
+			Direction: &direction,
+			Lower:     &lower,
+			Upper:     &upper,
+			Mode:      mode,
+		}
+	}
+
+	testcases := []struct {
+		name       string
+		annotation Bound
+		index      Bound
+	}{
+		{
+			name:       "inline",
+			annotation: newBound("inline", "", 3, 5),
+			index:      newBound("inline", "index", 1, 2),
+		},
+		{
+			name:       "i",
+			annotation: newBound("i", "annotation", 3, 5),
+			index:      newBound("i", "", 1, 2),
+		},
+		{
+			name:       "time",
+			annotation: newBound("time", "", 8, 12),
+			index:      newBound("k", "", 1, 2),
+		},
+		{
+			name:       "sample as index",
+			annotation: newBound("sample", "", 8, 12),
+			index:      newBound("sample", "index", 1, 2),
+		},
+	}
+
+	handle, _ := NewDSHandle(well_known)
+	defer handle.Close()
+
+	for _, testcase := range testcases {
+		expected, err := handle.GetSlice(
+			10,
+			AxisCrossline,
+			CoordinateSystemAnnotation,
+			[]Bound{testcase.annotation},
+			nil,
+		)
+		require.NoError(t, err, testcase.name)
+
+		actual, err := handle.GetSlice(
+			10,
+			AxisCrossline,
+			CoordinateSystemAnnotation,
+			[]Bound{testcase.index},
+			nil,
+		)
+		require.NoError(t, err, testcase.name)
+		require.Equal(t, expected, actual, testcase.name)
+
+		expectedMetadata, err := handle.GetSliceMetadata(
+			10,
+			AxisCrossline,
+			CoordinateSystemAnnotation,
+			[]Bound{testcase.annotation},
+			nil,
+		)
+		require.NoError(t, err, testcase.name)
+
+		actualMetadata, err := handle.GetSliceMetadata(
+			10,
+			AxisCrossline,
+			CoordinateSystemAnnotation,
+			[]Bound{testcase.index},
+			nil,
+		)
+		require.NoError(t, err, testcase.name)
+		require.JSONEq(t,
+			string(expectedMetadata),
+			string(actualMetadata),
+			testcase.name,
+		)
+	}
+}
+
+func TestSliceBoundModeOutOfBounds(t *testing.T) {
+	handle, _ := NewDSHandle(well_known)
+	defer handle.Close()
+
+	direction := "inline"
+	lower, upper := 0, 2
+	bound := Bound{Direction: &direction, Lower: &lower, Upper: &upper}
+
+	_, err := handle.GetSlice(10, AxisCrossline, CoordinateSystemAnnotation, []Bound{bound}, nil)
+	require.IsType(t, &InvalidArgument{}, err)
+	require.EqualError(t, err,
+		"Invalid lower bound: 0, valid range: [1.00:5.00:2.00]. The lower "+
+			"bound is interpreted as an annotation, as an index the valid "+
+			"range is [0:2:1]",
+	)
+
+	bound.Mode = "index"
+	lower, upper = 1, 3
+	_, err = handle.GetSlice(10, AxisCrossline, CoordinateSystemAnnotation, []Bound{bound}, nil)
+	require.IsType(t, &InvalidArgument{}, err)
+	require.EqualError(t, err,
+		"Invalid upper bound: 3, valid range: [0:2:1]. The upper bound is "+
+			"interpreted as an index, as an annotation the valid range is "+
+			"[1.00:5.00:2.00]",
+	)
+
+	bound.Mode = "cdp"
+	_, err = handle.GetSlice(10, AxisCrossline, CoordinateSystemAnnotation, []Bound{bound}, nil)
+	require.IsType(t, &InvalidArgument{}, err)
+	require.EqualError(t, err,
+		"invalid bound mode 'cdp', valid options are: index or annotation",
+	)
+}
+
+/*
+ * Bounds are inclusive, so lower == upper selects a single line, while a lower
+ * bound above the upper selects nothing and is rejected with the index ranges
+ * the bounds resolved to. Bounds in the slice direction are ignored, also
+ * when they are empty.
+ */
+func TestSliceEmptyBounds(t *testing.T) {
+	newBound := func(direction string, lower, upper int) Bound {
+		return Bound{Direction: &direction, Lower: &lower, Upper: &upper}
+	}
+
+	testcases := []struct {
+		name          string
+		bounds        []Bound
+		expectedSlice []float32
+		expectedShape []int
+		expectedErr   string
+	}{
+		{
+			name:          "lower == upper selects a single line",
+			bounds:        []Bound{newBound("inline", 3, 3)},
+			expectedSlice: []float32{108, 109, 110, 111},
+			expectedShape: []int{1, 4},
+		},
+		{
+			name:          "lower == upper along the vertical axis",
+			bounds:        []Bound{newBound("time", 8, 8)},
+			expectedSlice: []float32{101, 109, 117},
+			expectedShape: []int{3, 1},
+		},
+		{
+			name:   "lower > upper as annotations",
+			bounds: []Bound{newBound("inline", 5, 3)},
+			expectedErr: "Bounds select no samples: Inline index 2 of the " +
+				"lower bound is above index 1 of the upper bound. Resolved " +
+				"index ranges (inclusive): Inline [2:1], Crossline [0:0], " +
+				"Sample [0:3]",
+		},
+		{
+			name:   "lower > upper as indices",
+			bounds: []Bound{newBound("k", 3, 1)},
+			expectedErr: "Bounds select no samples: Sample index 3 of the " +
+				"lower bound is above index 1 of the upper bound. Resolved " +
+				"index ranges (inclusive): Inline [0:2], Crossline [0:0], " +
+				"Sample [3:1]",
+		},
+		{
+			name: "lower > upper along both axes names the first",
+			bounds: []Bound{
+				newBound("time", 16, 4),
+				newBound("i", 2, 0),
+			},
+			expectedErr: "Bounds select no samples: Inline index 2 of the " +
+				"lower bound is above index 0 of the upper bound. Resolved " +
+				"index ranges (inclusive): Inline [2:0], Crossline [0:0], " +
+				"Sample [3:0]",
+		},
+		{
+			name: "an empty bound is replaced by the last bound",
+			bounds: []Bound{
+				newBound("time", 12, 8),
+				newBound("time", 8, 8),
+			},
+			expectedSlice: []float32{101, 109, 117},
+			expectedShape: []int{3, 1},
+		},
+		{
+			name:   "an empty bound in the slice direction is ignored",
+			bounds: []Bound{newBound("crossline", 11, 10)},
+			expectedSlice: []float32{
+				100, 101, 102, 103,
+				108, 109, 110, 111,
+				116, 117, 118, 119,
+			},
+			expectedShape: []int{3, 4},
+		},
+	}
+
+	handle, _ := NewDSHandle(well_known)
+	defer handle.Close()
+
+	for _, testcase := range testcases {
+		buf, err := handle.GetSlice(
+			10,
+			AxisCrossline,
+			CoordinateSystemAnnotation,
+			testcase.bounds,
+			nil,
+		)
+		metadata, metadataErr := handle.GetSliceMetadata(
+			10,
+			AxisCrossline,
+			CoordinateSystemAnnotation,
+			testcase.bounds,
+			nil,
+		)
+
+		if testcase.expectedErr != "" {
+			require.IsType(t, &InvalidArgument{}, err, testcase.name)
+			require.EqualError(t, err, testcase.expectedErr, testcase.name)
+			require.EqualError(t, metadataErr, testcase.expectedErr, testcase.name)
+			continue
+		}
+
+		require.NoError(t, err, testcase.name)
+		require.NoError(t, metadataErr, testcase.name)
+		slice, err := toFloat32(buf)
+		require.NoError(t, err, testcase.name)
+		require.Equal(t, testcase.expectedSlice, *slice, testcase.name)
+
+		var meta SliceMetadata
+		require.NoError(t, json.Unmarshal(metadata, &meta), testcase.name)
+		require.Equal(t, testcase.expectedShape, meta.Shape, testcase.name)
+	}
+}
+
+func TestGetLinenoSystem(t *testing.T) {
+	system, err := GetLinenoSystem(AxisTime, "")
+	require.NoError(t, err)
+	require.Equal(t, CoordinateSystemAnnotation, system)
+
+	system, err = GetLinenoSystem(AxisTime, "Index")
+	require.NoError(t, err)
+	require.Equal(t, CoordinateSystemIndex, system)
+
+	_, err = GetLinenoSystem(AxisI, "cdp")
+	require.IsType(t, &InvalidArgument{}, err)
+	require.EqualError(t, err,
+		"invalid lineno mode 'cdp', valid options are: index or annotation",
+	)
+}
+
 func TestSliceInvalidAxis(t *testing.T) {
 	testcases := []struct {
 		name      string
@@ -131,7 +500,13 @@ func TestSliceInvalidAxis(t *testing.T) {
 	for _, testcase := range testcases {
 		handle, _ := NewDSHandle(well_known)
 		defer handle.Close()
-		_, err := handle.GetSlice(0, testcase.direction, []Bound{})
+		_, err := handle.GetSlice(
+			0,
+			testcase.direction,
+			linenoSystem(testcase.direction),
+			[]Bound{},
+			nil,
+		)
 
 		require.ErrorContains(t, err, "Unhandled axis")
 	}
@@ -160,7 +535,9 @@ func TestSliceBounds(t *testing.T) {
 			Max:        max,
 			Samples:    samples,
 			StepSize:   stepsize,
+			Regular:    true,
 			Unit:       unit,
+			RawUnit:    unit,
 		}
 	}
 
@@ -374,7 +751,9 @@ func TestSliceBounds(t *testing.T) {
 		buf, err := handle.GetSlice(
 			testCase.lineno,
 			direction,
+			linenoSystem(direction),
 			testCase.bounds,
+			nil,
 		)
 
 		require.IsTypef(t, testCase.expectedErr, err,
@@ -394,7 +773,9 @@ func TestSliceBounds(t *testing.T) {
 		buf, err = handle.GetSliceMetadata(
 			testCase.lineno,
 			direction,
+			linenoSystem(direction),
 			testCase.bounds,
+			nil,
 		)
 		require.NoError(t, err,
 			"[case: %v] Failed to get slice metadata, err: %v",
@@ -441,7 +822,9 @@ func TestDepthAxis(t *testing.T) {
 		{
 			name:      "Depth",
 			direction: AxisDepth,
-			err: NewInvalidArgument(
+			err: NewCodedInvalidArgument(
+				"incompatible_vertical_axis",
+				nil,
 				"Cannot fetch depth slice for VDS file with vertical axis unit: ms",
 			),
 		},
@@ -450,7 +833,13 @@ func TestDepthAxis(t *testing.T) {
 	for _, testcase := range testcases {
 		handle, _ := NewDSHandle(well_known)
 		defer handle.Close()
-		_, err := handle.GetSlice(0, testcase.direction, []Bound{})
+		_, err := handle.GetSlice(
+			0,
+			testcase.direction,
+			linenoSystem(testcase.direction),
+			[]Bound{},
+			nil,
+		)
 
 		require.Equal(t, err, testcase.err)
 	}
@@ -463,14 +852,14 @@ func TestSliceMetadata(t *testing.T) {
 		Array: Array{
 			Format: "<f4",
 		},
-		X:          Axis{Annotation: "Sample", Min: 4, Max: 16, Samples: 4, StepSize: 4, Unit: "ms"},
-		Y:          Axis{Annotation: "Inline", Min: 1, Max: 5, Samples: 3, StepSize: 2, Unit: "unitless"},
+		X:          Axis{Annotation: "Sample", Min: 4, Max: 16, Samples: 4, StepSize: 4, Regular: true, Unit: "ms", RawUnit: "ms"},
+		Y:          Axis{Annotation: "Inline", Min: 1, Max: 5, Samples: 3, StepSize: 2, Regular: true, Unit: "unitless", RawUnit: "unitless"},
 		Geospatial: [][]float64{{0, 3}, {12, 11}},
 		Shape:      []int{3, 4},
 	}
 	handle, _ := NewDSHandle(well_known)
 	defer handle.Close()
-	buf, err := handle.GetSliceMetadata(lineno, direction, []Bound{})
+	buf, err := handle.GetSliceMetadata(lineno, direction, linenoSystem(direction), []Bound{}, nil)
 	require.NoErrorf(t, err, "Failed to retrieve slice metadata, err %v", err)
 
 	var meta SliceMetadata
@@ -534,7 +923,9 @@ func TestSliceMetadataAxisOrdering(t *testing.T) {
 		buf, err := handle.GetSliceMetadata(
 			testcase.lineno,
 			testcase.direction,
+			linenoSystem(testcase.direction),
 			[]Bound{},
+			nil,
 		)
 		require.NoErrorf(t, err,
 			"[case: %v] Failed to get slice metadata, err: %v",
@@ -607,7 +998,9 @@ func TestSliceGeospatial(t *testing.T) {
 		buf, err := handle.GetSliceMetadata(
 			testcase.lineno,
 			testcase.direction,
+			linenoSystem(testcase.direction),
 			[]Bound{},
+			nil,
 		)
 		require.NoError(t, err,
 			"[case: %v] Failed to get slice metadata, err: %v",
@@ -632,3 +1025,583 @@ func TestSliceGeospatial(t *testing.T) {
 		)
 	}
 }
+
+func TestInterlaceSlice(t *testing.T) {
+	data := []byte{}
+	for i := 0; i < 30; i++ {
+		data = append(data, byte(i))
+	}
+
+	passes, err := InterlaceSlice(data, []int{5, 6}, 1, []int{4, 2, 1})
+	require.NoError(t, err)
+
+	expected := [][]byte{
+		{0, 4, 24, 28},
+		{2, 12, 14, 16, 26},
+		{
+			1, 3, 5,
+			6, 7, 8, 9, 10, 11,
+			13, 15, 17,
+			18, 19, 20, 21, 22, 23,
+			25, 27, 29,
+		},
+	}
+	require.Equal(t, expected, passes)
+}
+
+func TestInterlaceSliceItemsize(t *testing.T) {
+	data := []byte{0, 1, 2, 3, 4, 5, 6, 7}
+
+	passes, err := InterlaceSlice(data, []int{2, 2}, 2, []int{2, 1})
+	require.NoError(t, err)
+
+	expected := [][]byte{{0, 1}, {2, 3, 4, 5, 6, 7}}
+	require.Equal(t, expected, passes)
+}
+
+func TestInterlaceSliceErrors(t *testing.T) {
+	testcases := []struct {
+		name    string
+		shape   []int
+		strides []int
+		err     string
+	}{
+		{
+			name:    "Wrong dimensions",
+			shape:   []int{2, 2, 1},
+			strides: []int{1},
+			err:     "Expected a 2D slice, got shape [2 2 1]",
+		},
+		{
+			name:    "Wrong size",
+			shape:   []int{2, 3},
+			strides: []int{1},
+			err:     "Slice of shape [2 3] has 4 bytes, expected 6",
+		},
+		{
+			name:    "Last stride is not 1",
+			shape:   []int{2, 2},
+			strides: []int{4, 2},
+			err:     "The last stride must be 1",
+		},
+		{
+			name:    "Stride is not a multiple",
+			shape:   []int{2, 2},
+			strides: []int{3, 2, 1},
+			err:     "Stride 3 is not a multiple of stride 2",
+		},
+	}
+
+	for _, testcase := range testcases {
+		_, err := InterlaceSlice([]byte{0, 1, 2, 3}, testcase.shape, 1, testcase.strides)
+		require.ErrorContainsf(t, err, testcase.err, "[%s]", testcase.name)
+	}
+}
+
+func TestSlicePassFromPassRows(t *testing.T) {
+	data := []byte{}
+	for i := 0; i < 30; i++ {
+		data = append(data, byte(i))
+	}
+	shape := []int{5, 6}
+	strides := []int{4, 2, 1}
+
+	expected, err := InterlaceSlice(data, shape, 1, strides)
+	require.NoError(t, err)
+
+	/* Only the rows of the passes so far are filled in */
+	partial := make([]byte, len(data))
+	for n := range strides {
+		for _, rows := range PassRows(strides, n) {
+			for row := rows[0]; row < shape[0]; row += rows[1] {
+				copy(partial[row*6:(row+1)*6], data[row*6:(row+1)*6])
+			}
+		}
+
+		pass, err := SlicePass(partial, shape, 1, strides, n)
+		require.NoError(t, err)
+		require.Equalf(t, expected[n], pass, "[pass %d]", n)
+		require.Lenf(t, pass, PassSize(shape, strides, n), "[pass %d]", n)
+	}
+}
+
+func TestPassRows(t *testing.T) {
+	strides := []int{8, 4, 2, 1}
+	expected := [][][2]int{{{0, 8}}, {{4, 8}}, {{2, 4}}, {{1, 2}}}
+	for n := range strides {
+		require.Equalf(t, expected[n], PassRows(strides, n), "[pass %d]", n)
+	}
+
+	require.Equal(t, [][2]int{{1, 4}, {2, 4}, {3, 4}}, PassRows([]int{4, 1}, 1))
+}
+
+func TestSliceRows(t *testing.T) {
+	testcases := []struct {
+		name      string
+		direction int
+		lineno    int
+	}{
+		{name: "Inline", direction: AxisI, lineno: 1},
+		{name: "Crossline", direction: AxisJ, lineno: 0},
+		{name: "Sample", direction: AxisK, lineno: 2},
+	}
+
+	handle, _ := NewDSHandle(well_known)
+	defer handle.Close()
+
+	for _, testcase := range testcases {
+		metadata, err := handle.GetSliceMetadata(
+			testcase.lineno,
+			testcase.direction,
+			CoordinateSystemIndex,
+			[]Bound{},
+			nil,
+		)
+		require.NoErrorf(t, err, "[%s]", testcase.name)
+		var array Array
+		require.NoError(t, json.Unmarshal(metadata, &array))
+		rowsize := array.Shape[1] * 4
+
+		slice, err := handle.GetSlice(
+			testcase.lineno,
+			testcase.direction,
+			CoordinateSystemIndex,
+			[]Bound{},
+			nil,
+		)
+		require.NoErrorf(t, err, "[%s]", testcase.name)
+
+		for _, rows := range [][2]int{{0, 1}, {0, 2}, {1, 2}, {1, 8}, {8, 8}} {
+			first, stride := rows[0], rows[1]
+			expected := []byte{}
+			for row := first; row < array.Shape[0]; row += stride {
+				expected = append(expected, slice[row*rowsize:(row+1)*rowsize]...)
+			}
+
+			buf, err := handle.GetSliceRows(
+				testcase.lineno,
+				testcase.direction,
+				CoordinateSystemIndex,
+				[]Bound{},
+				nil,
+				first,
+				stride,
+			)
+			require.NoErrorf(t, err, "[%s]", testcase.name)
+			require.Equalf(t, expected, buf,
+				"[%s] Wrong rows from %d by %d", testcase.name, first, stride)
+		}
+	}
+
+	_, err := handle.GetSliceRows(0, AxisI, CoordinateSystemIndex, []Bound{}, nil, 0, 0)
+	require.ErrorContains(t, err, "Invalid rows: first row 0, stride 0")
+}
+
+func TestSliceFillValueWithoutAbsentData(t *testing.T) {
+	expected := []float32{
+		108, 109, 110, 111, // il: 3, xl: 10, samples: all
+		112, 113, 114, 115, // il: 3, xl: 11, samples: all
+	}
+
+	handle, _ := NewDSHandle(well_known)
+	defer handle.Close()
+
+	buf, err := handle.GetSlice(3, AxisInline, CoordinateSystemAnnotation, []Bound{}, &fillValue)
+	require.NoError(t, err)
+
+	slice, err := toFloat32(buf)
+	require.NoError(t, err)
+	require.Equal(t, expected, *slice)
+
+	buf, err = handle.GetSliceMetadata(
+		3,
+		AxisInline,
+		CoordinateSystemAnnotation,
+		[]Bound{},
+		&fillValue,
+	)
+	require.NoError(t, err)
+
+	var metadata SliceMetadata
+	err = json.Unmarshal(buf, &metadata)
+	require.NoError(t, err)
+	require.NotNil(t, metadata.FillValue)
+	require.Equal(t, FillValue(fillValue), *metadata.FillValue)
+}
+
+func TestSliceMetadataFillValue(t *testing.T) {
+	nan := float32(math.NaN())
+	testcases := []struct {
+		name      string
+		fillValue *float32
+		expected  string
+	}{
+		{name: "None", fillValue: nil, expected: `"fillValue":null`},
+		{name: "Number", fillValue: &fillValue, expected: `"fillValue":-999.25`},
+		{name: "NaN", fillValue: &nan, expected: `"fillValue":"nan"`},
+	}
+
+	handle, _ := NewDSHandle(well_known)
+	defer handle.Close()
+
+	for _, testcase := range testcases {
+		buf, err := handle.GetSliceMetadata(
+			3,
+			AxisInline,
+			CoordinateSystemAnnotation,
+			[]Bound{},
+			testcase.fillValue,
+		)
+		require.NoErrorf(t, err, "[%s]", testcase.name)
+		require.Containsf(t, string(buf), testcase.expected, "[%s]", testcase.name)
+	}
+}
+
+/*
+ * Inlines 64-127 of the dead traces cube have no data. The file is generated
+ * by testdata/scripts/make_dead_traces.py.
+ */
+const deadTracesPath = "../../testdata/dead_traces/dead_traces.vds"
+
+func TestSliceFillValueDeadTraces(t *testing.T) {
+	if _, err := os.Stat(deadTracesPath); err != nil {
+		t.Skipf("%s not found, generate it with make_dead_traces.py", deadTracesPath)
+	}
+
+	handle, err := NewDSHandle(make_connection("dead_traces/dead_traces.vds"))
+	require.NoError(t, err)
+	defer handle.Close()
+
+	isFill := func(value float32, fill float32) bool {
+		if math.IsNaN(float64(fill)) {
+			return math.IsNaN(float64(value))
+		}
+		return value == fill
+	}
+
+	for _, fill := range []float32{fillValue, 0, float32(math.NaN())} {
+		buf, err := handle.GetSlice(0, AxisK, CoordinateSystemIndex, []Bound{}, &fill)
+		require.NoErrorf(t, err, "[fill: %v]", fill)
+
+		slice, err := toFloat32(buf)
+		require.NoErrorf(t, err, "[fill: %v]", fill)
+		require.Lenf(t, *slice, 128*2, "[fill: %v]", fill)
+
+		for i, value := range *slice {
+			il, xl := i/2, i%2
+			if il < 64 {
+				require.Equalf(t, float32(il*10+xl), value,
+					"[fill: %v] Wrong value of live trace (%d, %d)", fill, il, xl)
+				continue
+			}
+			require.Truef(t, isFill(value, fill),
+				"[fill: %v] Dead trace (%d, %d) is %v", fill, il, xl, value)
+		}
+	}
+}
+
+/*
+ * Slices spanning both chunks of the dead traces cube along the inline axis
+ * are read as tiles, and must be byte-identical to the same slice read as
+ * one sub-slice per chunk, which are read in a single request each.
+ */
+func TestSliceTilesDeadTraces(t *testing.T) {
+	if _, err := os.Stat(deadTracesPath); err != nil {
+		t.Skipf("%s not found, generate it with make_dead_traces.py", deadTracesPath)
+	}
+
+	handle, err := NewDSHandle(make_connection("dead_traces/dead_traces.vds"))
+	require.NoError(t, err)
+	defer handle.Close()
+
+	inlines := func(lower, upper int) []Bound {
+		direction := "i"
+		return []Bound{{Direction: &direction, Lower: &lower, Upper: &upper}}
+	}
+
+	testcases := []struct {
+		name      string
+		direction int
+		lineno    int
+	}{
+		{name: "crossline", direction: AxisJ, lineno: 1},
+		{name: "sample", direction: AxisK, lineno: 2},
+	}
+
+	for _, testcase := range testcases {
+		for _, fill := range []*float32{nil, &fillValue} {
+			tiled, err := handle.GetSlice(
+				testcase.lineno,
+				testcase.direction,
+				CoordinateSystemIndex,
+				[]Bound{},
+				fill,
+			)
+			require.NoErrorf(t, err, "[%s]", testcase.name)
+
+			single := []byte{}
+			for _, chunk := range [][2]int{{0, 63}, {64, 127}} {
+				buf, err := handle.GetSlice(
+					testcase.lineno,
+					testcase.direction,
+					CoordinateSystemIndex,
+					inlines(chunk[0], chunk[1]),
+					fill,
+				)
+				require.NoErrorf(t, err, "[%s]", testcase.name)
+				single = append(single, buf...)
+			}
+
+			require.Equalf(t, single, tiled,
+				"[%s] Tiled slice differs from single requests", testcase.name)
+		}
+	}
+}
+
+/*
+ * Every endpoint answers the same dead trace with the same fill value, and
+ * reports it in the metadata
+ */
+func TestFillValueAcrossEndpointsDeadTraces(t *testing.T) {
+	if _, err := os.Stat(deadTracesPath); err != nil {
+		t.Skipf("%s not found, generate it with make_dead_traces.py", deadTracesPath)
+	}
+
+	handle, err := NewDSHandle(make_connection("dead_traces/dead_traces.vds"))
+	require.NoError(t, err)
+	defer handle.Close()
+
+	/* The second sample of trace (100, 1), which is dead */
+	buf, metadata, err := handle.GetSliceWithMetadata(
+		100,
+		AxisI,
+		CoordinateSystemIndex,
+		[]Bound{},
+		&fillValue,
+	)
+	require.NoError(t, err)
+	slice, err := toFloat32(buf)
+	require.NoError(t, err)
+	var sliceMeta SliceMetadata
+	require.NoError(t, json.Unmarshal(metadata, &sliceMeta))
+
+	fenceInterpolation, _ := GetFenceInterpolationMethod("nearest")
+	buf, metadata, err = handle.GetFenceWithMetadata(
+		CoordinateSystemIndex,
+		[][]float32{{100, 1}},
+		fenceInterpolation,
+		&fillValue,
+	)
+	require.NoError(t, err)
+	fence, err := toFloat32(buf)
+	require.NoError(t, err)
+	var fenceMeta FenceMetadata
+	require.NoError(t, json.Unmarshal(metadata, &fenceMeta))
+
+	interpolation, _ := GetInterpolationMethod("nearest")
+	conversion, _ := NewVerticalUnitConversion("", "")
+	samples, metadata, err := handle.GetSamplesWithMetadata(
+		CoordinateSystemCdp,
+		[][]float32{{100, 1, 8}},
+		interpolation,
+		&fillValue,
+		conversion,
+	)
+	require.NoError(t, err)
+	sample, err := toFloat32(samples[0])
+	require.NoError(t, err)
+	var sampleMeta SampleMetadata
+	require.NoError(t, json.Unmarshal(metadata, &sampleMeta))
+
+	values := map[string]float32{
+		"slice":  (*slice)[1*4+1],
+		"fence":  (*fence)[1],
+		"sample": (*sample)[0],
+	}
+	for endpoint, value := range values {
+		require.Equalf(t, fillValue, value, "[%s] Wrong value of dead trace", endpoint)
+	}
+
+	reported := map[string]*FillValue{
+		"slice":  sliceMeta.FillValue,
+		"fence":  fenceMeta.FillValue,
+		"sample": sampleMeta.FillValue,
+	}
+	for endpoint, value := range reported {
+		require.NotNilf(t, value, "[%s] Fill value not reported", endpoint)
+		require.Equalf(t, FillValue(fillValue), *value, "[%s]", endpoint)
+	}
+}
+
+func TestFillValueJSON(t *testing.T) {
+	testcases := []struct {
+		json     string
+		expected float32
+	}{
+		{json: `-999.25`, expected: -999.25},
+		{json: `0`, expected: 0},
+		{json: `"nan"`, expected: float32(math.NaN())},
+		{json: `"NaN"`, expected: float32(math.NaN())},
+	}
+
+	for _, testcase := range testcases {
+		var value FillValue
+		err := json.Unmarshal([]byte(testcase.json), &value)
+		require.NoErrorf(t, err, "[%s]", testcase.json)
+
+		if math.IsNaN(float64(testcase.expected)) {
+			require.Truef(t, math.IsNaN(float64(value)), "[%s]", testcase.json)
+		} else {
+			require.Equalf(t, FillValue(testcase.expected), value, "[%s]", testcase.json)
+		}
+
+		out, err := json.Marshal(value)
+		require.NoErrorf(t, err, "[%s]", testcase.json)
+		var roundtrip FillValue
+		err = json.Unmarshal(out, &roundtrip)
+		require.NoErrorf(t, err, "[%s]", testcase.json)
+	}
+
+	for _, invalid := range []string{`"inf"`, `true`, `[1]`, `{}`} {
+		var value FillValue
+		err := json.Unmarshal([]byte(invalid), &value)
+		require.ErrorContainsf(t, err,
+			`field 'fillValue' must be a number or "nan"`, "[%s]", invalid)
+	}
+}
+
+func TestSliceWithMetadataMatchesSeparateCalls(t *testing.T) {
+	newBound := func(direction string, lower, upper int) Bound {
+		return Bound{Direction: &direction, Lower: &lower, Upper: &upper}
+	}
+	fillValue := float32(-999.25)
+
+	testcases := []struct {
+		name      string
+		lineno    int
+		direction int
+		bounds    []Bound
+		fillValue *float32
+	}{
+		{name: "inline", lineno: 3, direction: AxisInline},
+		{name: "k", lineno: 1, direction: AxisK, fillValue: &fillValue},
+		{
+			name:      "bounded crossline",
+			lineno:    10,
+			direction: AxisCrossline,
+			bounds:    []Bound{newBound("inline", 3, 5)},
+		},
+		{name: "out of bounds", lineno: 4, direction: AxisInline},
+		{
+			name:      "invalid vertical bound",
+			lineno:    3,
+			direction: AxisInline,
+			bounds:    []Bound{newBound("depth", 4, 8)},
+		},
+	}
+
+	handle, _ := NewDSHandle(well_known)
+	defer handle.Close()
+
+	for _, testcase := range testcases {
+		system := linenoSystem(testcase.direction)
+
+		expectedMetadata, metadataErr := handle.GetSliceMetadata(
+			testcase.lineno,
+			testcase.direction,
+			system,
+			testcase.bounds,
+			testcase.fillValue,
+		)
+		expectedData, dataErr := handle.GetSlice(
+			testcase.lineno,
+			testcase.direction,
+			system,
+			testcase.bounds,
+			testcase.fillValue,
+		)
+
+		data, metadata, err := handle.GetSliceWithMetadata(
+			testcase.lineno,
+			testcase.direction,
+			system,
+			testcase.bounds,
+			testcase.fillValue,
+		)
+
+		expectedErr := metadataErr
+		if expectedErr == nil {
+			expectedErr = dataErr
+		}
+		if expectedErr != nil {
+			require.EqualErrorf(t, err, expectedErr.Error(),
+				"[case: %v]", testcase.name)
+			continue
+		}
+
+		require.NoErrorf(t, err, "[case: %v]", testcase.name)
+		require.Equalf(t, expectedMetadata, metadata, "[case: %v]", testcase.name)
+		require.Equalf(t, expectedData, data, "[case: %v]", testcase.name)
+	}
+}
+
+func BenchmarkSliceSeparateCalls(b *testing.B) {
+	handle, _ := NewDSHandle(well_known)
+	defer handle.Close()
+	system := linenoSystem(AxisInline)
+
+	for i := 0; i < b.N; i++ {
+		_, err := handle.GetSliceMetadata(3, AxisInline, system, []Bound{}, nil)
+		require.NoError(b, err)
+		_, err = handle.GetSlice(3, AxisInline, system, []Bound{}, nil)
+		require.NoError(b, err)
+	}
+}
+
+func BenchmarkSliceWithMetadata(b *testing.B) {
+	handle, _ := NewDSHandle(well_known)
+	defer handle.Close()
+	system := linenoSystem(AxisInline)
+
+	for i := 0; i < b.N; i++ {
+		_, _, err := handle.GetSliceWithMetadata(
+			3,
+			AxisInline,
+			system,
+			[]Bound{},
+			nil,
+		)
+		require.NoError(b, err)
+	}
+}
+
+/*
+ * The large cube spans 8 bricks along every axis of a slice. The file is
+ * generated by testdata/scripts/make_large_cube.py, and is not checked in.
+ */
+const largeCubePath = "../../testdata/large_cube/large_cube.vds"
+
+func BenchmarkSliceLargeCube(b *testing.B) {
+	if _, err := os.Stat(largeCubePath); err != nil {
+		b.Skipf("%s not found, generate it with make_large_cube.py", largeCubePath)
+	}
+
+	handle, err := NewDSHandle(make_connection("large_cube/large_cube.vds"))
+	require.NoError(b, err)
+	defer handle.Close()
+
+	for _, direction := range []int{AxisI, AxisJ, AxisK} {
+		b.Run(AxisName(direction), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				_, err := handle.GetSlice(
+					100,
+					direction,
+					CoordinateSystemIndex,
+					[]Bound{},
+					nil,
+				)
+				require.NoError(b, err)
+			}
+		})
+	}
+}
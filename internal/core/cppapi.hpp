@@ -1,6 +1,10 @@
 #ifndef VDS_SLICE_CPPAPI_HPP
 #define VDS_SLICE_CPPAPI_HPP
 
+#include <array>
+#include <optional>
+#include <string>
+#include <utility>
 #include <vector>
 
 #include "ctypes.h"
@@ -9,15 +13,22 @@
 #include "datasource.hpp"
 #include "direction.hpp"
 #include "regularsurface.hpp"
+#include "subcube.hpp"
 #include "subvolume.hpp"
 
 namespace cppapi {
 
+/**
+ * Slices in format STORED are returned as stored in the VDS, and fillvalue
+ * is ignored, as absent data can not be told apart in the stored format.
+ */
 void slice(
     DataSource& datasource,
     Direction const direction,
     int lineno,
     std::vector< Bound > const& bounds,
+    const float* fillvalue,
+    enum sample_format format,
     response* out
 ) noexcept (false);
 
@@ -31,6 +42,151 @@ void fence(
     response* out
 ) noexcept (false);
 
+/**
+ * A slice and its metadata in one go. The bounds of the slice are computed
+ * and validated once, and both outputs are identical to those of slice and
+ * slice_metadata.
+ */
+void slice_with_metadata(
+    DataSource& datasource,
+    Direction const direction,
+    int lineno,
+    std::vector< Bound > const& bounds,
+    const float* fillvalue,
+    enum sample_format format,
+    response* data,
+    response* metadata
+) noexcept (false);
+
+/**
+ * Every stride'th row of a slice, starting at row first. The rows are read
+ * one at a time, such that rows that are not requested are not read.
+ */
+void slice_rows(
+    DataSource& datasource,
+    Direction const direction,
+    int lineno,
+    std::vector< Bound > const& bounds,
+    const float* fillvalue,
+    enum sample_format format,
+    int first,
+    int stride,
+    response* out
+) noexcept (false);
+
+/**
+ * A fence and its metadata in one go. For nearest_trace, the coordinates are
+ * only snapped to traces once. Both outputs are identical to those of fence
+ * and fence_metadata.
+ */
+void fence_with_metadata(
+    DataSource& datasource,
+    enum coordinate_system coordinate_system,
+    const float* coordinates,
+    size_t npoints,
+    enum interpolation_method interpolation_method,
+    const float* fillValue,
+    response* data,
+    response* metadata
+) noexcept (false);
+
+/**
+ * The trace headers of a fence, as npoints rows of one float per field.
+ * Every coordinate is snapped to its nearest trace, as with nearest_trace,
+ * and coordinates outside of the survey are given fillValue. Fields are
+ * header channels of the cube, matched by name ignoring case, and a field
+ * the cube does not have is an error listing the ones it has.
+ */
+void fence_headers(
+    DataSource& datasource,
+    enum coordinate_system coordinate_system,
+    const float* coordinates,
+    size_t npoints,
+    std::vector< std::string > const& fields,
+    const float* fillValue,
+    response* out
+) noexcept (false);
+
+/**
+ * The trace header channels of the cube, as (name, channel) pairs. These are
+ * the channels with a single value per trace, which some imports keep
+ * fields of the SEG-Y trace headers in, such as the CDP or the offset.
+ */
+std::vector< std::pair< std::string, int > > header_channels(
+    MetadataHandle const& metadata
+) noexcept (false);
+
+/**
+ * Sample the cube at npoints [x y z] points, with x and y in
+ * coordinate_system and z in the unit of the vertical axis. Points outside
+ * of the cube, and points where the data is absent, are flagged as invalid
+ * and given fillvalue.
+ */
+void sample_points(
+    DataSource& datasource,
+    enum coordinate_system coordinate_system,
+    const float* points,
+    size_t npoints,
+    enum interpolation_method interpolation_method,
+    float fillvalue,
+    response* values,
+    response* valid
+) noexcept (false);
+
+/**
+ * Resample ntraces whole traces to interval, in the unit of the vertical
+ * axis. The new samples start at the first sample of the cube, and end at
+ * the last one that is within the cube. Supported interpolation methods are
+ * those of resampling segments, i.e. nearest, linear and cubic.
+ */
+void resample_traces(
+    DataSource& datasource,
+    const float* traces,
+    size_t ntraces,
+    float interval,
+    enum interpolation_method interpolation_method,
+    response* out
+) noexcept (false);
+
+/** The bounds of a slice, i.e. the whole cube constrained to the slice */
+SubCube slice_bounds(
+    MetadataHandle const& metadata,
+    Direction const direction,
+    int lineno,
+    std::vector< Bound > const& bounds
+) noexcept (false);
+
+/**
+ * Snap fence coordinates to the (inline, crossline) index of the nearest
+ * trace, rounding half up. Coordinates outside of the survey are an error,
+ * unless fillValue is given, in which case they snap to no trace.
+ *
+ * If exact, coordinates must already be at a trace, within a small
+ * tolerance, and the first one that is not is an error.
+ */
+std::vector< std::optional< std::array< int, 2 > > > snap_to_traces(
+    MetadataHandle const& metadata,
+    enum coordinate_system coordinate_system,
+    const float* coordinates,
+    size_t npoints,
+    const float* fillValue,
+    bool exact = false
+) noexcept (false);
+
+/**
+ * Set every node of the surface that is outside the polygon to the fill
+ * value of the surface, such that the node is considered missing and never
+ * sampled. The polygon is given as nvertices [x y] pairs in
+ * coordinate_system, and nodes are tested against it by the even-odd rule.
+ */
+void mask_surface(
+    DataSource& datasource,
+    RegularSurface& surface,
+    enum coordinate_system coordinate_system,
+    const float* polygon,
+    size_t nvertices
+) noexcept (false);
+
 void fetch_subvolume(
     DataSource& datasource,
     SurfaceBoundedSubVolume& subvolume,
@@ -44,9 +200,12 @@ void attributes(
     ResampledSegmentBlueprint const* dst_segment_blueprint,
     enum attribute* attributes,
     std::size_t nattributes,
+    enum interpolation_method vertical_interpolation,
+    float min_valid_fraction,
     std::size_t from,
     std::size_t to,
-    void** out
+    void** out,
+    attribute_coverage* coverage
 ) noexcept (false);
 
 /**
@@ -81,18 +240,57 @@ void slice_metadata(
     Direction const direction,
     int lineno,
     std::vector< Bound > const& bounds,
+    const float* fillvalue,
+    enum sample_format format,
+    response* out
+) noexcept (false);
+
+/* Metadata of a slice with bounds as given by slice_bounds */
+void slice_metadata(
+    DataSource& datasource,
+    Direction const direction,
+    int lineno,
+    SubCube const& bounds,
+    const float* fillvalue,
+    enum sample_format format,
     response* out
 ) noexcept (false);
 
 
 void fence_metadata(
     DataSource& datasource,
+    enum coordinate_system coordinate_system,
+    const float* coordinates,
     size_t npoints,
+    enum interpolation_method interpolation_method,
+    const float* fillValue,
+    response* out
+) noexcept (false);
+
+/**
+ * Metadata of a fence of npoints coordinates. traces are the traces a
+ * nearest_trace fence snapped to, as given by snap_to_traces, and nullptr for
+ * other interpolations.
+ */
+void fence_metadata(
+    DataSource& datasource,
+    size_t npoints,
+    std::vector< std::optional< std::array< int, 2 > > > const* traces,
+    const float* fillValue,
     response* out
 ) noexcept (false);
 
 void metadata(
     DataSource& datasource,
+    bool include_import_info,
+    bool include_layout,
+    response* out
+) noexcept (false);
+
+void compatibility(
+    DataSource& datasource_a,
+    DataSource& datasource_b,
+    double tolerance,
     response* out
 ) noexcept (false);
 
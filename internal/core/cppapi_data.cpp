@@ -1,8 +1,15 @@
 #include "ctypes.h"
 
+#include <algorithm>
+#include <array>
+#include <cctype>
+#include <cmath>
 #include <cstdint>
+#include <limits>
+#include <optional>
 #include <string>
 #include <memory>
+#include <vector>
 
 #include <OpenVDS/OpenVDS.h>
 #include <OpenVDS/KnownMetadata.h>
@@ -10,6 +17,7 @@
 
 #include "attribute.hpp"
 #include "axis.hpp"
+#include "cppapi.hpp"
 #include "datasource.hpp"
 #include "direction.hpp"
 #include "exceptions.hpp"
@@ -35,6 +43,17 @@ bool equal(const char* lhs, const char* rhs) {
     return std::strcmp(lhs, rhs) == 0;
 }
 
+bool equal_ignore_case(std::string const& lhs, std::string const& rhs) {
+    return lhs.size() == rhs.size() and std::equal(
+        lhs.begin(),
+        lhs.end(),
+        rhs.begin(),
+        [](unsigned char a, unsigned char b) {
+            return std::tolower(a) == std::tolower(b);
+        }
+    );
+}
+
 /** Validate the request against the vds' vertical axis
  *
  * Requests for Time and Depth are checked against the axis name and unit of
@@ -65,6 +84,8 @@ void validate_vertical_axis(
             not equal(vdsname, Label::Sample())
         ) {
             throw detail::bad_request(
+                "incompatible_vertical_axis",
+                {},
                 "Cannot fetch depth slice for VDS file with vertical axis label: "  + name
             );
         }
@@ -75,6 +96,8 @@ void validate_vertical_axis(
             not equal(vdsunit, Unit::USSurveyFoot())
         ) {
             throw detail::bad_request(
+                "incompatible_vertical_axis",
+                {},
                 "Cannot fetch depth slice for VDS file with vertical axis unit: "  + unit
             );
         }
@@ -86,6 +109,8 @@ void validate_vertical_axis(
             not equal(vdsname, Label::Sample())
         ) {
             throw detail::bad_request(
+                "incompatible_vertical_axis",
+                {},
                 "Cannot fetch time slice for VDS file with vertical axis label: "  + name
             );
         }
@@ -94,6 +119,8 @@ void validate_vertical_axis(
             not equal(vdsunit, Unit::Second())
         ) {
             throw detail::bad_request(
+                "incompatible_vertical_axis",
+                {},
                 "Cannot fetch time slice for VDS file with vertical axis unit: "  + unit
             );
         }
@@ -125,19 +152,140 @@ void append(std::vector< std::unique_ptr< AttributeMap > >& vec, T obj) {
     vec.push_back( std::unique_ptr< T >( new T( std::move(obj) ) ) );
 }
 
-} // namespace
+/** Transform a fence coordinate to annotation (inline, crossline)
+ *
+ * 2D lines have no grid, so their indices are mapped by the axes directly,
+ * and world coordinates are snapped to the nearest trace.
+ */
+OpenVDS::Vector< double, 3 > to_annotation(
+    MetadataHandle const& metadata,
+    OpenVDS::IJKCoordinateTransformer const& coordinate_transformer,
+    enum coordinate_system coordinate_system,
+    const float x,
+    const float y
+) {
+    switch (coordinate_system) {
+        case INDEX: {
+            if (not metadata.is_2d())
+                return coordinate_transformer.IJKPositionToAnnotation({x, y, 0});
 
-namespace cppapi {
+            Axis const xline = metadata.xline();
+            double const stepsize = xline.nsamples() > 1 ? xline.stepsize() : 0;
+            return OpenVDS::Vector<double, 3> {x, xline.min() + y * stepsize, 0};
+        }
+        case ANNOTATION:
+            return OpenVDS::Vector<double, 3> {x, y, 0};
+        case CDP:
+            return ::world_to_annotation(metadata, coordinate_transformer, x, y);
+        default: {
+            throw std::runtime_error("Unhandled coordinate system");
+        }
+    }
+}
 
-void slice(
+detail::bad_request out_of_boundaries(
+    const float x,
+    const float y,
+    const int dimension
+) {
+    const std::string coordinate_str =
+        "(" +utils::to_string_with_precision(x, 6) + "," +
+        utils::to_string_with_precision(y, 6) + ")";
+    return detail::bad_request(
+        "coordinate_out_of_bounds",
+        {},
+        "Coordinate " + coordinate_str + " is out of boundaries "+
+        "in dimension "+ std::to_string(dimension)+ "."
+    );
+}
+
+/**
+ * Fence of whole traces, read directly from the VDS the same way a slice is,
+ * such that the samples are untouched by any interpolation. Consecutive
+ * coordinates that snap to the same trace only read it once.
+ */
+void fence_nearest_trace(
     DataSource& handle,
-    Direction const direction,
-    int lineno,
-    std::vector< Bound > const& slicebounds,
+    std::vector< std::optional< std::array< int, 2 > > > const& traces,
+    const float* fillValue,
     response* out
 ) {
     MetadataHandle const& metadata = handle.get_metadata();
-    Axis const& axis = metadata.get_axis(direction);
+    std::size_t const npoints = traces.size();
+
+    Axis const& inline_axis    = metadata.iline();
+    Axis const& crossline_axis = metadata.xline();
+    auto nsamples              = metadata.sample().nsamples();
+
+    SubCube trace(metadata);
+    trace.bounds.upper[   inline_axis.dimension()] = 1;
+    trace.bounds.upper[crossline_axis.dimension()] = 1;
+
+    std::int64_t const trace_size = handle.subcube_buffer_size(trace);
+    std::int64_t const size = trace_size * npoints;
+
+    std::unique_ptr< char[] > data(new char[size]);
+
+    std::vector< std::size_t > noval_indicies;
+    for (std::size_t i = 0; i < npoints; ++i) {
+        char* dst = data.get() + i * trace_size;
+
+        if (not traces[i]) {
+            noval_indicies.push_back(i * nsamples);
+            continue;
+        }
+
+        if (i > 0 and traces[i - 1] == traces[i]) {
+            std::memcpy(dst, dst - trace_size, trace_size);
+            continue;
+        }
+
+        auto const [iline, xline] = *traces[i];
+        trace.bounds.lower[   inline_axis.dimension()] = iline;
+        trace.bounds.upper[   inline_axis.dimension()] = iline + 1;
+        trace.bounds.lower[crossline_axis.dimension()] = xline;
+        trace.bounds.upper[crossline_axis.dimension()] = xline + 1;
+
+        handle.read_subcube(dst, trace_size, trace, fillValue);
+    }
+
+    if (!noval_indicies.empty()){
+        write_fillvalue(data.get(), noval_indicies, nsamples, *fillValue);
+    }
+    return to_response(std::move(data), size, out);
+}
+
+/** Whether point is inside the polygon, by the even-odd rule */
+bool inside_polygon(std::vector< Point > const& polygon, Point const point) {
+    bool inside = false;
+    for (std::size_t i = 0, j = polygon.size() - 1; i < polygon.size(); j = i++) {
+        Point const& a = polygon[i];
+        Point const& b = polygon[j];
+
+        bool const crosses = (a.y > point.y) != (b.y > point.y);
+        if (crosses and
+            point.x < (b.x - a.x) * (point.y - a.y) / (b.y - a.y) + a.x)
+        {
+            inside = not inside;
+        }
+    }
+    return inside;
+}
+
+/* Check that the directions of the slice and its bounds fit the cube */
+void validate_slice(
+    MetadataHandle const& metadata,
+    Direction const direction,
+    std::vector< Bound > const& slicebounds
+) {
+    /* The single inline of a 2D line is the whole line, not a slice of it */
+    if (metadata.is_2d() and direction.is_iline()) {
+        throw detail::bad_request(
+            "Direction " + direction.to_string() + " does not exist in a "
+            "2D line, slice along the crossline (trace) or the sample axis "
+            "instead"
+        );
+    }
 
     if (direction.is_sample()) {
         validate_vertical_axis(metadata.sample(), direction);
@@ -147,19 +295,193 @@ void slice(
         auto bound_dir = Direction(bound.name);
         validate_vertical_axis(metadata.sample(), bound_dir);
     }
+}
 
-    SubCube bounds(metadata);
-    bounds.constrain(metadata, slicebounds);
-    bounds.set_slice(axis, lineno, direction.coordinate_system());
+void read_slice(
+    DataSource& handle,
+    SubCube const& bounds,
+    const float* fillvalue,
+    enum sample_format format,
+    response* out
+) {
+    if (format == STORED) {
+        std::int64_t const size = handle.stored_subcube_buffer_size(bounds);
+
+        std::unique_ptr< char[] > data(new char[size]);
+        handle.read_stored_subcube(data.get(), size, bounds);
+
+        return to_response(std::move(data), size, out);
+    }
 
     std::int64_t const size = handle.subcube_buffer_size(bounds);
 
     std::unique_ptr< char[] > data(new char[size]);
-    handle.read_subcube(data.get(), size, bounds);
+    handle.read_subcube(data.get(), size, bounds, fillvalue);
+
+    return to_response(std::move(data), size, out);
+}
+
+/*
+ * The rows of a slice run along the outermost of the two dimensions that span
+ * it, as the innermost dimension runs fastest in the data of read_slice
+ */
+int row_dimension(int slice_dimension) {
+    for (int dimension = 2; dimension >= 0; --dimension) {
+        if (dimension != slice_dimension) return dimension;
+    }
+    throw std::runtime_error("A slice has no rows");
+}
+
+void read_slice_rows(
+    DataSource& handle,
+    SubCube const& bounds,
+    int dimension,
+    int first,
+    int stride,
+    const float* fillvalue,
+    enum sample_format format,
+    response* out
+) {
+    if (first < 0 or stride < 1) {
+        throw detail::bad_request(
+            "Invalid rows: first row " + std::to_string(first) +
+            ", stride " + std::to_string(stride)
+        );
+    }
+
+    std::vector< SubCube > rows;
+    int const upper = bounds.bounds.upper[dimension];
+    for (int row = bounds.bounds.lower[dimension] + first; row < upper; row += stride) {
+        SubCube subcube = bounds;
+        subcube.bounds.lower[dimension] = row;
+        subcube.bounds.upper[dimension] = row + 1;
+        rows.push_back(subcube);
+    }
+
+    auto row_size = [&](SubCube const& row) {
+        return format == STORED
+            ? handle.stored_subcube_buffer_size(row)
+            : handle.subcube_buffer_size(row);
+    };
+
+    std::int64_t size = 0;
+    for (auto const& row : rows) {
+        size += row_size(row);
+    }
+
+    std::unique_ptr< char[] > data(new char[size]);
+    std::int64_t offset = 0;
+    for (auto const& row : rows) {
+        std::int64_t const rowsize = row_size(row);
+        if (format == STORED) {
+            handle.read_stored_subcube(data.get() + offset, rowsize, row);
+        } else {
+            handle.read_subcube(data.get() + offset, rowsize, row, fillvalue);
+        }
+        offset += rowsize;
+    }
 
     return to_response(std::move(data), size, out);
 }
 
+} // namespace
+
+namespace cppapi {
+
+SubCube slice_bounds(
+    MetadataHandle const& metadata,
+    Direction const direction,
+    int lineno,
+    std::vector< Bound > const& slicebounds
+) {
+    Axis const& axis = metadata.get_axis(direction);
+
+    SubCube bounds(metadata);
+    bounds.constrain(metadata, slicebounds);
+    bounds.set_slice(axis, lineno, direction.coordinate_system());
+    bounds.require_nonempty(metadata);
+    return bounds;
+}
+
+void slice(
+    DataSource& handle,
+    Direction const direction,
+    int lineno,
+    std::vector< Bound > const& slicebounds,
+    const float* fillvalue,
+    enum sample_format format,
+    response* out
+) {
+    MetadataHandle const& metadata = handle.get_metadata();
+    ::validate_slice(metadata, direction, slicebounds);
+
+    SubCube const bounds = slice_bounds(metadata, direction, lineno, slicebounds);
+    return ::read_slice(handle, bounds, fillvalue, format, out);
+}
+
+void slice_with_metadata(
+    DataSource& handle,
+    Direction const direction,
+    int lineno,
+    std::vector< Bound > const& slicebounds,
+    const float* fillvalue,
+    enum sample_format format,
+    response* data,
+    response* metadata
+) {
+    MetadataHandle const& metadata_handle = handle.get_metadata();
+    SubCube const bounds = slice_bounds(
+        metadata_handle,
+        direction,
+        lineno,
+        slicebounds
+    );
+
+    /*
+     * Same order as slice_metadata followed by slice, such that invalid
+     * requests fail with the same error
+     */
+    slice_metadata(
+        handle,
+        direction,
+        lineno,
+        bounds,
+        fillvalue,
+        format,
+        metadata
+    );
+    ::validate_slice(metadata_handle, direction, slicebounds);
+    return ::read_slice(handle, bounds, fillvalue, format, data);
+}
+
+void slice_rows(
+    DataSource& handle,
+    Direction const direction,
+    int lineno,
+    std::vector< Bound > const& slicebounds,
+    const float* fillvalue,
+    enum sample_format format,
+    int first,
+    int stride,
+    response* out
+) {
+    MetadataHandle const& metadata = handle.get_metadata();
+    ::validate_slice(metadata, direction, slicebounds);
+
+    SubCube const bounds = slice_bounds(metadata, direction, lineno, slicebounds);
+    int const dimension = ::row_dimension(metadata.get_axis(direction).dimension());
+    return ::read_slice_rows(
+        handle,
+        bounds,
+        dimension,
+        first,
+        stride,
+        fillvalue,
+        format,
+        out
+    );
+}
+
 void fence(
     DataSource& handle,
     enum coordinate_system coordinate_system,
@@ -169,6 +491,24 @@ void fence(
     const float* fillValue,
     response* out
 ) {
+    /*
+     * Exact trace positions need no interpolation, so the traces are read as
+     * is, just like nearest_trace
+     */
+    if (interpolation_method == NEAREST_TRACE or
+        interpolation_method == NO_INTERPOLATION)
+    {
+        auto const traces = snap_to_traces(
+            handle.get_metadata(),
+            coordinate_system,
+            coordinates,
+            npoints,
+            fillValue,
+            interpolation_method == NO_INTERPOLATION
+        );
+        return ::fence_nearest_trace(handle, traces, fillValue, out);
+    }
+
     MetadataHandle const& metadata = handle.get_metadata();
 
     std::vector< std::size_t > noval_indicies;
@@ -177,17 +517,7 @@ void fence(
 
     auto coordinate_transformer = metadata.coordinate_transformer();
     auto transform_coordinate = [&] (const float x, const float y) {
-        switch (coordinate_system) {
-            case INDEX:
-                return coordinate_transformer.IJKPositionToAnnotation({x, y, 0});
-            case ANNOTATION:
-                return OpenVDS::Vector<double, 3> {x, y, 0};
-            case CDP:
-                return coordinate_transformer.WorldToAnnotation({x, y, 0});
-            default: {
-                throw std::runtime_error("Unhandled coordinate system");
-            }
-        }
+        return ::to_annotation(metadata, coordinate_transformer, coordinate_system, x, y);
     };
     Axis inline_axis    = metadata.iline();
     Axis crossline_axis = metadata.xline();
@@ -203,13 +533,7 @@ void fence(
         auto validate_boundary = [&] (const int voxel, Axis const& axis) {
             if (!axis.inrange(coordinate[voxel])) {
                 if (fillValue == nullptr) {
-                    const std::string coordinate_str =
-                        "(" +utils::to_string_with_precision(x, 6) + "," +
-                        utils::to_string_with_precision(y, 6) + ")";
-                    throw detail::bad_request(
-                        "Coordinate " + coordinate_str + " is out of boundaries "+
-                        "in dimension "+ std::to_string(voxel)+ "."
-                    );
+                    throw ::out_of_boundaries(x, y, voxel);
                 }
                 noval_indicies.push_back(i * nsamples);
             }
@@ -231,7 +555,8 @@ void fence(
         size,
         coords.get(),
         npoints,
-        interpolation_method
+        interpolation_method,
+        fillValue
     );
     if (!noval_indicies.empty()){
             write_fillvalue(data.get(), noval_indicies, nsamples, *fillValue);
@@ -239,6 +564,387 @@ void fence(
     return to_response(std::move(data), size, out);
 }
 
+void fence_with_metadata(
+    DataSource& handle,
+    enum coordinate_system coordinate_system,
+    const float* coordinates,
+    size_t npoints,
+    enum interpolation_method interpolation_method,
+    const float* fillValue,
+    response* data,
+    response* metadata
+) {
+    if (interpolation_method != NEAREST_TRACE) {
+        fence_metadata(handle, npoints, nullptr, fillValue, metadata);
+        return fence(
+            handle,
+            coordinate_system,
+            coordinates,
+            npoints,
+            interpolation_method,
+            fillValue,
+            data
+        );
+    }
+
+    auto const traces = snap_to_traces(
+        handle.get_metadata(),
+        coordinate_system,
+        coordinates,
+        npoints,
+        fillValue
+    );
+    fence_metadata(handle, npoints, &traces, fillValue, metadata);
+    return ::fence_nearest_trace(handle, traces, fillValue, data);
+}
+
+std::vector< std::pair< std::string, int > > header_channels(
+    MetadataHandle const& metadata
+) {
+    OpenVDS::VolumeDataLayout const& layout = metadata.layout();
+
+    std::vector< std::pair< std::string, int > > channels;
+    for (int channel = 0; channel < layout.GetChannelCount(); ++channel) {
+        using Mapping = OpenVDS::VolumeDataMapping;
+        if (layout.GetChannelMapping(channel) != Mapping::PerTrace) continue;
+        if (layout.GetChannelMappedValueCount(channel) != 1)         continue;
+
+        channels.emplace_back(layout.GetChannelName(channel), channel);
+    }
+    return channels;
+}
+
+void fence_headers(
+    DataSource& datasource,
+    enum coordinate_system coordinate_system,
+    const float* coordinates,
+    size_t npoints,
+    std::vector< std::string > const& fields,
+    const float* fillValue,
+    response* out
+) {
+    MetadataHandle const& metadata = datasource.get_metadata();
+    auto const available = header_channels(metadata);
+
+    std::vector< int > channels;
+    for (auto const& field : fields) {
+        auto const match = std::find_if(
+            available.begin(),
+            available.end(),
+            [&field](auto const& channel) {
+                return ::equal_ignore_case(channel.first, field);
+            }
+        );
+        if (match != available.end()) {
+            channels.push_back(match->second);
+            continue;
+        }
+
+        if (available.empty()) {
+            throw detail::bad_request(
+                "Header field '" + field + "' is not in the VDS, which has no "
+                "header channels"
+            );
+        }
+        std::string names;
+        for (auto const& channel : available) {
+            if (not names.empty()) names += ", ";
+            names += channel.first;
+        }
+        throw detail::bad_request(
+            "Header field '" + field + "' is not in the VDS, available header "
+            "channels are: " + names
+        );
+    }
+
+    auto const traces = snap_to_traces(
+        metadata,
+        coordinate_system,
+        coordinates,
+        npoints,
+        fillValue
+    );
+
+    Axis const& inline_axis    = metadata.iline();
+    Axis const& crossline_axis = metadata.xline();
+    SubCube trace(metadata);
+
+    std::size_t const nfields = channels.size();
+    std::int64_t const size = npoints * nfields * sizeof(float);
+    std::unique_ptr< char[] > data(new char[size]);
+    float* const values = reinterpret_cast< float* >(data.get());
+
+    for (std::size_t i = 0; i < npoints; ++i) {
+        float* const row = values + i * nfields;
+
+        if (not traces[i]) {
+            std::fill(row, row + nfields, *fillValue);
+            continue;
+        }
+
+        if (i > 0 and traces[i - 1] == traces[i]) {
+            std::copy(row - nfields, row, row);
+            continue;
+        }
+
+        auto const [iline, xline] = *traces[i];
+        trace.bounds.lower[   inline_axis.dimension()] = iline;
+        trace.bounds.upper[   inline_axis.dimension()] = iline + 1;
+        trace.bounds.lower[crossline_axis.dimension()] = xline;
+        trace.bounds.upper[crossline_axis.dimension()] = xline + 1;
+
+        for (std::size_t field = 0; field < nfields; ++field) {
+            row[field] = datasource.read_trace_header(channels[field], trace);
+        }
+    }
+    return to_response(std::move(data), size, out);
+}
+
+void sample_points(
+    DataSource& handle,
+    enum coordinate_system coordinate_system,
+    const float* points,
+    size_t npoints,
+    enum interpolation_method interpolation_method,
+    float fillvalue,
+    response* values,
+    response* valid
+) {
+    MetadataHandle const& metadata = handle.get_metadata();
+    auto coordinate_transformer = metadata.coordinate_transformer();
+
+    Axis inline_axis    = metadata.iline();
+    Axis crossline_axis = metadata.xline();
+    Axis samples_axis   = metadata.sample();
+
+    std::unique_ptr< voxel[] > samples(new voxel[npoints]{{0}});
+    std::unique_ptr< char[] > flags(new char[npoints]);
+
+    for (size_t i = 0; i < npoints; i++) {
+        const float x = *(points++);
+        const float y = *(points++);
+        const float z = *(points++);
+
+        auto coordinate = ::to_annotation(
+            metadata,
+            coordinate_transformer,
+            coordinate_system,
+            x,
+            y
+        );
+
+        bool const inside = inline_axis.inrange(coordinate[0])    and
+                            crossline_axis.inrange(coordinate[1]) and
+                            samples_axis.inrange(z);
+        flags[i] = inside;
+
+        /* Points outside are left at the origin, and overwritten below */
+        if (not inside) continue;
+
+        samples[i][   inline_axis.dimension()] = inline_axis.to_sample_position(coordinate[0]);
+        samples[i][crossline_axis.dimension()] = crossline_axis.to_sample_position(coordinate[1]);
+        samples[i][  samples_axis.dimension()] = samples_axis.to_sample_position(z);
+    }
+
+    std::int64_t const size = handle.samples_buffer_size(npoints);
+    std::unique_ptr< char[] > data(new char[size]);
+
+    /* Absent data is read as NaN, such that it can be flagged as invalid */
+    float const absent = std::numeric_limits< float >::quiet_NaN();
+    handle.read_samples(
+        data.get(),
+        size,
+        samples.get(),
+        npoints,
+        interpolation_method,
+        &absent
+    );
+
+    float* samplevalues = reinterpret_cast< float* >(data.get());
+    for (size_t i = 0; i < npoints; i++) {
+        if (flags[i] and not std::isnan(samplevalues[i])) continue;
+
+        flags[i] = false;
+        samplevalues[i] = fillvalue;
+    }
+
+    to_response(std::move(data), size, values);
+    return to_response(std::move(flags), npoints, valid);
+}
+
+void resample_traces(
+    DataSource& handle,
+    const float* traces,
+    size_t ntraces,
+    float interval,
+    enum interpolation_method interpolation_method,
+    response* out
+) {
+    if (interval <= 0) {
+        throw detail::bad_request("Sample interval must be positive");
+    }
+
+    MetadataHandle const& metadata = handle.get_metadata();
+    Axis const& sample_axis = metadata.sample();
+
+    std::size_t const src_nsamples = sample_axis.nsamples();
+    std::size_t const dst_nsamples =
+        floor_with_tolerance((sample_axis.max() - sample_axis.min()) / interval) + 1;
+
+    std::vector< double > src_points(src_nsamples);
+    for (std::size_t i = 0; i < src_nsamples; ++i) {
+        src_points[i] = sample_axis.min() + i * sample_axis.stepsize();
+    }
+
+    std::vector< double > dst_points(dst_nsamples);
+    for (std::size_t i = 0; i < dst_nsamples; ++i) {
+        dst_points[i] = sample_axis.min() + i * double(interval);
+    }
+
+    std::int64_t const size = ntraces * dst_nsamples * sizeof(float);
+    std::unique_ptr< char[] > data(new char[size]);
+    float* dst = reinterpret_cast< float* >(data.get());
+
+    std::vector< double > resampled(dst_nsamples);
+    for (std::size_t i = 0; i < ntraces; ++i) {
+        float const* trace = traces + i * src_nsamples;
+        ::resample(
+            src_points,
+            std::vector< double >(trace, trace + src_nsamples),
+            dst_points,
+            interpolation_method,
+            resampled.begin()
+        );
+        std::copy(resampled.begin(), resampled.end(), dst + i * dst_nsamples);
+    }
+
+    return to_response(std::move(data), size, out);
+}
+
+std::vector< std::optional< std::array< int, 2 > > > snap_to_traces(
+    MetadataHandle const& metadata,
+    enum coordinate_system coordinate_system,
+    const float* coordinates,
+    size_t npoints,
+    const float* fillValue,
+    bool exact
+) {
+    auto coordinate_transformer = metadata.coordinate_transformer();
+    Axis inline_axis    = metadata.iline();
+    Axis crossline_axis = metadata.xline();
+
+    /* Sample positions are centered on the lines, so round half up */
+    auto snap = [] (Axis& axis, double coordinate) {
+        int const index = std::floor(axis.to_sample_position(coordinate) + 0.5);
+        return std::min(std::max(index, 0), axis.nsamples() - 1);
+    };
+
+    /*
+     * Fraction of the distance between traces a coordinate may be off by and
+     * still be at the trace, which absorbs the round-off of float32
+     * coordinates and of the cdp transform
+     */
+    double const tolerance = 1e-3;
+    auto on_trace = [&] (Axis& axis, double coordinate, int index) {
+        return std::abs(axis.to_sample_position(coordinate) - index) <= tolerance;
+    };
+
+    std::vector< std::optional< std::array< int, 2 > > > traces;
+    traces.reserve(npoints);
+    for (size_t i = 0; i < npoints; i++) {
+        const float x = *(coordinates++);
+        const float y = *(coordinates++);
+
+        auto coordinate = ::to_annotation(
+            metadata,
+            coordinate_transformer,
+            coordinate_system,
+            x,
+            y
+        );
+
+        int outside = -1;
+        if      (!inline_axis.inrange(coordinate[0]))    outside = 0;
+        else if (!crossline_axis.inrange(coordinate[1])) outside = 1;
+
+        if (outside != -1) {
+            if (fillValue == nullptr) {
+                throw ::out_of_boundaries(x, y, outside);
+            }
+            traces.push_back(std::nullopt);
+            continue;
+        }
+
+        int const iline = snap(inline_axis,    coordinate[0]);
+        int const xline = snap(crossline_axis, coordinate[1]);
+
+        if (exact and (not on_trace(inline_axis,    coordinate[0], iline) or
+                       not on_trace(crossline_axis, coordinate[1], xline)))
+        {
+            auto annotation = [] (Axis const& axis, int index) {
+                return utils::to_string_with_precision(
+                    axis.min() + index * axis.stepsize()
+                );
+            };
+            throw detail::bad_request(
+                "Coordinate (" + utils::to_string_with_precision(x, 6) + "," +
+                utils::to_string_with_precision(y, 6) + ") at position " +
+                std::to_string(i) + " is not at a trace, which interpolation "
+                "'none' requires. The nearest trace is inline " +
+                annotation(inline_axis, iline) + ", crossline " +
+                annotation(crossline_axis, xline)
+            );
+        }
+
+        traces.push_back(std::array< int, 2 >{ iline, xline });
+    }
+    return traces;
+}
+
+void mask_surface(
+    DataSource& handle,
+    RegularSurface& surface,
+    enum coordinate_system coordinate_system,
+    const float* polygon,
+    size_t nvertices
+) {
+    if (nvertices < 3) {
+        throw detail::bad_request(
+            "Polygon must have at least 3 vertices, got " +
+            std::to_string(nvertices)
+        );
+    }
+
+    MetadataHandle const& metadata = handle.get_metadata();
+    if (metadata.is_2d()) {
+        throw detail::bad_request("Polygon masks are not supported for 2D lines");
+    }
+    auto transform = metadata.coordinate_transformer();
+
+    /* The polygon and the nodes are compared in annotation, i.e. (il, xl) */
+    std::vector< Point > vertices;
+    vertices.reserve(nvertices);
+    for (std::size_t i = 0; i < nvertices; ++i) {
+        auto const vertex = ::to_annotation(
+            metadata,
+            transform,
+            coordinate_system,
+            polygon[2 * i],
+            polygon[2 * i + 1]
+        );
+        vertices.push_back({vertex[0], vertex[1]});
+    }
+
+    auto const& grid = surface.grid();
+    for (std::size_t i = 0; i < surface.size(); ++i) {
+        auto const cdp = grid.to_cdp(i);
+        auto const node = transform.WorldToAnnotation({cdp.x, cdp.y, 0});
+
+        if (not ::inside_polygon(vertices, {node[0], node[1]})) {
+            surface[i] = surface.fillvalue();
+        }
+    }
+}
 
 void fetch_subvolume(
     DataSource& handle,
@@ -281,7 +987,12 @@ void fetch_subvolume(
         {
             auto row = horizontal_grid.row(i);
             auto col = horizontal_grid.col(i);
-            throw std::runtime_error(
+            throw detail::bad_request(
+                "vertical_window_out_of_bounds",
+                {
+                    { "row",    std::to_string(row) },
+                    { "column", std::to_string(col) },
+                },
                 "Vertical window is out of vertical bounds at"
                 " row: " + std::to_string(row) +
                 " col:" + std::to_string(col) +
@@ -293,7 +1004,7 @@ void fetch_subvolume(
         }
 
         auto const cdp = horizontal_grid.to_cdp(i);
-        auto ij = transform.WorldToAnnotation({cdp.x, cdp.y, 0});
+        auto ij = ::world_to_annotation(metadata, transform, cdp.x, cdp.y);
 
         ij[0]  = iline.to_sample_position(ij[0]);
         ij[1]  = xline.to_sample_position(ij[1]);
@@ -314,12 +1025,18 @@ void fetch_subvolume(
 
     auto const size = handle.samples_buffer_size(nsamples);
 
+    /*
+     * Absent data is read as NaN, such that the attribute calculations can
+     * tell it apart from actual samples
+     */
+    float const absent = std::numeric_limits< float >::quiet_NaN();
     handle.read_samples(
         subvolume.data(from),
         size,
         samples.get(),
         nsamples,
-        interpolation
+        interpolation,
+        &absent
     );
 }
 
@@ -329,9 +1046,12 @@ void attributes(
     ResampledSegmentBlueprint const* dst_segment_blueprint,
     enum attribute* attributes,
     std::size_t nattributes,
+    enum interpolation_method vertical_interpolation,
+    float min_valid_fraction,
     std::size_t from,
     std::size_t to,
-    void** out
+    void** out,
+    attribute_coverage* coverage
 ) {
     std::size_t size = src_subvolume.horizontal_grid().size() * sizeof(float);
 
@@ -346,6 +1066,10 @@ void attributes(
             case MAXAT:    { append(attrs,   MaxAt(dst, size)     );   break; }
             case MAXABS:   { append(attrs,   MaxAbs(dst, size)    );   break; }
             case MAXABSAT: { append(attrs,   MaxAbsAt(dst, size)  );   break; }
+            case MAXABSSIGNED: {
+                append(attrs, MaxAbsSigned(dst, size));
+                break;
+            }
             case MEAN:     { append(attrs,   Mean(dst, size)      );   break; }
             case MEANABS:  { append(attrs,   MeanAbs(dst, size)   );   break; }
             case MEANPOS:  { append(attrs,   MeanPos(dst, size)   );   break; }
@@ -356,6 +1080,8 @@ void attributes(
             case SD:       { append(attrs,   Sd(dst, size)        );   break; }
             case SUMPOS:   { append(attrs,   SumPos(dst, size)    );   break; }
             case SUMNEG:   { append(attrs,   SumNeg(dst, size)    );   break; }
+            case SUM:      { append(attrs,   Sum(dst, size)       );   break; }
+            case MAD:      { append(attrs,   Mad(dst, size)       );   break; }
 
             default:
                 throw std::runtime_error("Attribute not implemented");
@@ -363,31 +1089,53 @@ void attributes(
         ++attributes;
     }
 
-    calc_attributes(src_subvolume, dst_segment_blueprint, attrs, from, to);
+    calc_attributes(
+        src_subvolume,
+        dst_segment_blueprint,
+        attrs,
+        vertical_interpolation,
+        min_valid_fraction,
+        from,
+        to,
+        coverage
+    );
 }
 
 namespace {
 
 struct SurfacesCrossoverValidator {
-    // assuming that samples axis in the file has positive increasing values
-    bool have_crossed(float primary, float secondary) {
-        if (primary > secondary) {
-            this->primary_is_bottom = true;
-        } else if (primary < secondary) {
-            this->primary_is_top = true;
-        } else { //primary == secondary
+    /*
+     * The first point where the surfaces differ decides which one is on top,
+     * and the surfaces have crossed at any point where they are the other way
+     * around. Assuming that samples axis in the file has positive increasing
+     * values.
+     */
+    bool have_crossed(float primary, float secondary, std::size_t i) {
+        if (primary == secondary) return false;
+
+        bool const top = primary < secondary;
+        if (not this->ordered) {
+            this->ordered = true;
+            this->primary_is_top = top;
+            this->ordered_at = i;
             return false;
         }
-        return this->primary_is_top == this->primary_is_bottom;
+        return top != this->primary_is_top;
     }
 
     bool is_primary_top() {
         return this->primary_is_top;
     }
 
+    /* The point that decided which surface is on top */
+    std::size_t decided_at() {
+        return this->ordered_at;
+    }
+
 private:
+    bool ordered = false;
     bool primary_is_top = false;
-    bool primary_is_bottom = false;
+    std::size_t ordered_at = 0;
 };
 
 } //namespace
@@ -431,12 +1179,24 @@ void align_surfaces(
 
         aligned[i] = secondary_value;
 
-        if (surfaces.have_crossed(primary[i], aligned[i])) {
-            std::size_t row = primary.grid().row(i);
-            std::size_t col = primary.grid().col(i);
-            throw detail::bad_request("Surfaces intersect at primary surface point ("
-                                        + std::to_string(row) + ", "
-                                        + std::to_string(col) + ")");
+        if (surfaces.have_crossed(primary[i], aligned[i], i)) {
+            auto point = [&primary](std::size_t i) {
+                return "(" + std::to_string(primary.grid().row(i)) + ", "
+                           + std::to_string(primary.grid().col(i)) + ")";
+            };
+            std::string const here  = surfaces.is_primary_top() ? "below" : "above";
+            std::string const there = surfaces.is_primary_top() ? "above" : "below";
+            throw detail::bad_request(
+                "surfaces_intersect",
+                {
+                    { "row",    std::to_string(primary.grid().row(i)) },
+                    { "column", std::to_string(primary.grid().col(i)) },
+                },
+                "Surfaces intersect at primary surface point "
+                                        + point(i) + ": the primary surface is "
+                                        + here + " the secondary surface there, "
+                                        + "but " + there + " it at point "
+                                        + point(surfaces.decided_at()));
         }
 
     }
@@ -1,5 +1,8 @@
 #include "ctypes.h"
 
+#include <algorithm>
+#include <cmath>
+
 #include "nlohmann/json.hpp"
 
 #include <OpenVDS/OpenVDS.h>
@@ -8,6 +11,7 @@
 
 #include "axis.hpp"
 #include "boundingbox.hpp"
+#include "cppapi.hpp"
 #include "datahandle.hpp"
 #include "datasource.hpp"
 #include "direction.hpp"
@@ -24,6 +28,8 @@ std::string fmtstr(OpenVDS::VolumeDataFormat format) {
      *
      * We also assume that server code is run on a little-endian machine.
      *
+     * Slices can also be returned as stored, see stored_fmtstr.
+     *
      * [1] https://community.opengroup.org/osdu/platform/domain-data-mgmt-services/seismic/open-vds/-/issues/156#note_165511
      */
     switch (format) {
@@ -52,8 +58,14 @@ nlohmann::json json_axis(
 
     int dim = axis.dimension();
 
-    float min = axis.min() + axis.stepsize() * lower[dim];
-    float max = axis.min() + axis.stepsize() * (upper[dim] - 1); // inclusive
+    /* An axis of a single sample, like the inline of a 2D line, has no stepsize */
+    auto coordinate = [&](int index) {
+        if (axis.nsamples() == 1) return axis.min();
+        return axis.min() + axis.stepsize() * index;
+    };
+
+    float min = coordinate(lower[dim]);
+    float max = coordinate(upper[dim] - 1); // inclusive
     std::size_t samples = upper[dim] - lower[dim];
 
     nlohmann::json doc;
@@ -63,11 +75,64 @@ nlohmann::json json_axis(
         { "max",        max             },
         { "samples",    samples         },
         { "stepsize",   axis.stepsize() },
+        { "regular",    axis.regular()  },
         { "unit",       axis.unit()     },
     };
     return doc;
 }
 
+/*
+ * Any slice of a 2D line is along (a part of) the line, so its horizontal
+ * extent is the linestring from its first to its last trace. Lines without
+ * trace coordinates have no known extent.
+ */
+nlohmann::json json_line_geospatial(
+    MetadataHandle const& metadata,
+    SubCube const& bounds
+) {
+    auto const& traces = metadata.trace_coordinates();
+    if (traces.empty()) return nlohmann::json::array();
+
+    int const dim = metadata.xline().dimension();
+    auto const& first = traces[bounds.bounds.lower[dim]];
+    auto const& last  = traces[bounds.bounds.upper[dim] - 1];
+    return {
+        { first[0], first[1] },
+        { last[0],  last[1]  },
+    };
+}
+
+/*
+ * The bounding box of a 2D line is degenerate, with the corners at its first
+ * and last trace, in the same order as the corners of a 3D volume.
+ */
+nlohmann::json json_line_bounding_box(MetadataHandle const& metadata) {
+    Axis const& xline = metadata.xline();
+    int const last = xline.nsamples() - 1;
+
+    nlohmann::json bbox;
+    bbox["ij"] = {{0, 0}, {0, 0}, {0, last}, {0, last}};
+    bbox["ilxl"] = {
+        {0, int(xline.min())},
+        {0, int(xline.min())},
+        {0, int(xline.max())},
+        {0, int(xline.max())},
+    };
+
+    auto const& traces = metadata.trace_coordinates();
+    if (traces.empty()) {
+        bbox["cdp"] = nlohmann::json::array();
+    } else {
+        bbox["cdp"] = {
+            { traces.front()[0], traces.front()[1] },
+            { traces.front()[0], traces.front()[1] },
+            { traces.back()[0],  traces.back()[1]  },
+            { traces.back()[0],  traces.back()[1]  },
+        };
+    }
+    return bbox;
+}
+
 nlohmann::json json_slice_geospatial(
     MetadataHandle const& metadata,
     Direction const direction,
@@ -75,6 +140,10 @@ nlohmann::json json_slice_geospatial(
     int lineno,
     SubCube const& bounds
 ) {
+    if (metadata.is_2d()) {
+        return json_line_geospatial(metadata, bounds);
+    }
+
     auto const& transformer = metadata.coordinate_transformer();
 
     auto const lower = transformer.VoxelIndexToIJKIndex({
@@ -138,6 +207,209 @@ nlohmann::json json_slice_geospatial(
     }
 }
 
+/*
+ * The fill value absent data is replaced by, null if it is left as stored.
+ * json has no NaN, so it is written as a string, as it is requested.
+ */
+nlohmann::json json_fill_value(const float* fillvalue) {
+    if (fillvalue == nullptr)    return nullptr;
+    if (std::isnan(*fillvalue))  return "nan";
+    return *fillvalue;
+}
+
+/* A numeric field of two cubes, and how far apart they are */
+nlohmann::json json_compare(double a, double b, bool match) {
+    return {
+        { "a",          a     },
+        { "b",          b     },
+        { "difference", b - a },
+        { "match",      match },
+    };
+}
+
+nlohmann::json json_compare(
+    std::string const& a,
+    std::string const& b,
+    bool match
+) {
+    return {
+        { "a",     a     },
+        { "b",     b     },
+        { "match", match },
+    };
+}
+
+/* Comparison of two axes, by the same checks as dual-cube requests use */
+nlohmann::json json_axis_compatibility(Axis const& a, Axis const& b) {
+    auto const comparison = a.compare(b);
+    return {
+        { "annotation", json_compare(a.name(), b.name(), comparison.name)           },
+        { "min",        json_compare(a.min(), b.min(), comparison.min)               },
+        { "max",        json_compare(a.max(), b.max(), comparison.max)               },
+        { "samples",    json_compare(a.nsamples(), b.nsamples(), comparison.nsamples) },
+        { "stepsize",   json_compare(a.stepsize(), b.stepsize(), comparison.stepsize) },
+        { "unit",       json_compare(a.unit(), b.unit(), comparison.unit)             },
+        { "mismatches", comparison.mismatches                                       },
+        { "compatible", comparison.mismatches.empty()                               },
+    };
+}
+
+/*
+ * The world transforms of two cubes are compared by the corners of their
+ * bounding boxes, which agree if none are further apart than the tolerance.
+ */
+nlohmann::json json_world_compatibility(
+    MetadataHandle const& a,
+    MetadataHandle const& b,
+    double tolerance
+) {
+    auto const corners_a = a.bounding_box().world();
+    auto const corners_b = b.bounding_box().world();
+
+    std::vector< double > distances;
+    for (std::size_t i = 0; i < corners_a.size(); ++i) {
+        distances.push_back(std::hypot(
+            corners_b[i].first  - corners_a[i].first,
+            corners_b[i].second - corners_a[i].second
+        ));
+    }
+    double const max_distance = *std::max_element(
+        distances.begin(),
+        distances.end()
+    );
+
+    return {
+        { "cornerDistances", distances                   },
+        { "maxDistance",     max_distance                },
+        { "tolerance",       tolerance                   },
+        { "compatible",      max_distance <= tolerance   },
+    };
+}
+
+/* Name of the compression method, or empty if it is unknown */
+std::string compression_name(OpenVDS::CompressionMethod method) {
+    using Method = OpenVDS::CompressionMethod;
+    switch (method) {
+        case Method::None:                          return "None";
+        case Method::Wavelet:                       return "Wavelet";
+        case Method::RLE:                           return "RLE";
+        case Method::Zip:                           return "Zip";
+        case Method::WaveletNormalizeBlock:         return "WaveletNormalizeBlock";
+        case Method::WaveletLossless:               return "WaveletLossless";
+        case Method::WaveletNormalizeBlockLossless: return "WaveletNormalizeBlockLossless";
+        default:                                    return "";
+    }
+}
+
+/* Only the wavelet methods that are not lossless have a tolerance */
+bool is_lossy(OpenVDS::CompressionMethod method) {
+    using Method = OpenVDS::CompressionMethod;
+    return method == Method::Wavelet or method == Method::WaveletNormalizeBlock;
+}
+
+/* Name of the format a channel is stored in, or empty if it is unknown */
+std::string channel_format_name(OpenVDS::VolumeDataFormat format) {
+    using Format = OpenVDS::VolumeDataFormat;
+    switch (format) {
+        case Format::Format_1Bit: return "1Bit";
+        case Format::Format_U8:   return "U8";
+        case Format::Format_U16:  return "U16";
+        case Format::Format_U32:  return "U32";
+        case Format::Format_U64:  return "U64";
+        case Format::Format_R32:  return "R32";
+        case Format::Format_R64:  return "R64";
+        default:                  return "";
+    }
+}
+
+/*
+ * The format the data is stored in, and how stored integers map to the values
+ * they represent, i.e. value = stored * integerScale + integerOffset. Data
+ * stored as floats has a scale of 1 and an offset of 0.
+ */
+nlohmann::json json_stored_format(OpenVDS::VolumeDataLayout const& layout) {
+    int const channel = 0;
+
+    nlohmann::json doc;
+    doc["format"] = channel_format_name(layout.GetChannelFormat(channel));
+    doc["integerScale"] = layout.GetChannelIntegerScale(channel);
+    doc["integerOffset"] = layout.GetChannelIntegerOffset(channel);
+    return doc;
+}
+
+/*
+ * The numpy-style format of a slice returned as stored. Every format but
+ * 1Bit has a numpy counterpart.
+ */
+std::string stored_fmtstr(OpenVDS::VolumeDataFormat format) {
+    using Format = OpenVDS::VolumeDataFormat;
+    switch (format) {
+        case Format::Format_U8:  return "<u1";
+        case Format::Format_U16: return "<u2";
+        case Format::Format_U32: return "<u4";
+        case Format::Format_U64: return "<u8";
+        case Format::Format_R32: return "<f4";
+        case Format::Format_R64: return "<f8";
+        default: {
+            throw detail::bad_request(
+                "Data stored as " + channel_format_name(format) +
+                " can not be returned raw"
+            );
+        }
+    }
+}
+
+/*
+ * The storage layout of the VDS. The brick size is given per axis, in the
+ * same order as the axes of the metadata. Fields the VDS does not define,
+ * like the unit of a unitless channel, are left out.
+ */
+nlohmann::json json_layout(DataSource const& datasource) {
+    MetadataHandle const& metadata = datasource.get_metadata();
+    OpenVDS::VolumeDataLayout const& layout = metadata.layout();
+    auto const descriptor = layout.GetLayoutDescriptor();
+
+    /* Chunks are bricks, i.e. cubes in 3D volumes and squares in 2D lines */
+    int const brick = 1 << int(descriptor.GetBrickSize());
+    int const naxes = metadata.is_2d() ? 2 : 3;
+
+    nlohmann::json doc;
+    doc["brickSize"] = std::vector< int >(naxes, brick);
+    doc["lodLevels"] = int(descriptor.GetLODLevels());
+
+    auto const method = datasource.compression_method();
+    auto const compression = compression_name(method);
+    if (not compression.empty())
+        doc["compression"] = compression;
+    if (is_lossy(method))
+        doc["compressionTolerance"] = datasource.compression_tolerance();
+
+    doc["channels"] = nlohmann::json::array();
+    for (int channel = 0; channel < layout.GetChannelCount(); ++channel) {
+        nlohmann::json desc;
+        desc["name"] = std::string(layout.GetChannelName(channel));
+
+        auto const format = channel_format_name(layout.GetChannelFormat(channel));
+        if (not format.empty())
+            desc["format"] = format;
+
+        std::string const unit = layout.GetChannelUnit(channel);
+        if (not unit.empty())
+            desc["unit"] = unit;
+
+        desc["valueRange"] = {
+            layout.GetChannelValueRangeMin(channel),
+            layout.GetChannelValueRangeMax(channel),
+        };
+
+        if (layout.IsChannelUseNoValue(channel))
+            desc["noValue"] = layout.GetChannelNoValue(channel);
+
+        doc["channels"].push_back(desc);
+    }
+    return doc;
+}
+
 } // namespace
 
 namespace cppapi {
@@ -147,22 +419,49 @@ void slice_metadata(
     Direction const direction,
     int lineno,
     std::vector< Bound > const& slicebounds,
+    const float* fillvalue,
+    enum sample_format format,
+    response* out
+) {
+    MetadataHandle const& metadata = datasource.get_metadata();
+    SubCube const bounds = slice_bounds(metadata, direction, lineno, slicebounds);
+
+    return slice_metadata(
+        datasource,
+        direction,
+        lineno,
+        bounds,
+        fillvalue,
+        format,
+        out
+    );
+}
+
+void slice_metadata(
+    DataSource& datasource,
+    Direction const direction,
+    int lineno,
+    SubCube const& bounds,
+    const float* fillvalue,
+    enum sample_format format,
     response* out
 ) {
     MetadataHandle const& metadata = datasource.get_metadata();
     auto const& axis = metadata.get_axis(direction);
 
     nlohmann::json meta;
-    meta["format"] = fmtstr(DataHandle::format());
+    if (format == STORED) {
+        meta["format"] = stored_fmtstr(datasource.stored_format());
+        meta["fillValue"] = json_fill_value(nullptr);
+    } else {
+        meta["format"] = fmtstr(DataHandle::format());
+        meta["fillValue"] = json_fill_value(fillvalue);
+    }
 
     Axis const& inline_axis = metadata.iline();
     Axis const& crossline_axis = metadata.xline();
     Axis const& sample_axis = metadata.sample();
 
-    SubCube bounds(metadata);
-    bounds.constrain(metadata, slicebounds);
-    bounds.set_slice(axis, lineno, direction.coordinate_system());
-
     auto const& lower = bounds.bounds.lower;
     auto const& upper = bounds.bounds.upper;
 
@@ -195,9 +494,34 @@ void slice_metadata(
     return to_response(meta, out);
 }
 
+void fence_metadata(
+    DataSource& datasource,
+    enum coordinate_system coordinate_system,
+    const float* coordinates,
+    size_t npoints,
+    enum interpolation_method interpolation_method,
+    const float* fillValue,
+    response* out
+) {
+    if (interpolation_method != NEAREST_TRACE) {
+        return fence_metadata(datasource, npoints, nullptr, fillValue, out);
+    }
+
+    auto const traces = snap_to_traces(
+        datasource.get_metadata(),
+        coordinate_system,
+        coordinates,
+        npoints,
+        fillValue
+    );
+    return fence_metadata(datasource, npoints, &traces, fillValue, out);
+}
+
 void fence_metadata(
     DataSource& datasource,
     size_t npoints,
+    std::vector< std::optional< std::array< int, 2 > > > const* traces,
+    const float* fillValue,
     response* out
 ) {
     MetadataHandle const& metadata = datasource.get_metadata();
@@ -206,28 +530,59 @@ void fence_metadata(
     Axis const& sample_axis = metadata.sample();
     meta["shape"] = nlohmann::json::array({npoints, sample_axis.nsamples() });
     meta["format"] = fmtstr(DataHandle::format());
+    meta["fillValue"] = json_fill_value(fillValue);
+
+    if (traces) {
+        meta["indices"] = nlohmann::json::array();
+        for (auto const& trace : *traces) {
+            if (trace) meta["indices"].push_back(*trace);
+            else       meta["indices"].push_back(nullptr);
+        }
+    }
 
     return to_response(meta, out);
 }
 
-void metadata(DataSource& datasource, response* out) {
+void metadata(
+    DataSource& datasource,
+    bool include_import_info,
+    bool include_layout,
+    response* out
+) {
     MetadataHandle const& metadata = datasource.get_metadata();
 
     nlohmann::json meta;
 
-    meta["crs"]             = metadata.crs();
-    meta["inputFileName"]   = metadata.input_filename();
-    meta["importTimeStamp"] = metadata.import_time_stamp();
+    meta["crs"]           = metadata.crs();
+    meta["inputFileName"] = metadata.input_filename();
+
+    auto const time_stamp = metadata.import_time_stamp();
+    if (not time_stamp.empty())
+        meta["importTimeStamp"] = time_stamp;
+
+    /* The text header is 3200 bytes, so only given when asked for */
+    if (include_import_info) {
+        auto const text_header = metadata.segy_text_header();
+        if (not text_header.empty())
+            meta["segyTextHeader"] = text_header;
+    }
 
-    auto bbox = metadata.bounding_box();
-    meta["boundingBox"]["ij"]   = bbox.index();
-    meta["boundingBox"]["cdp"]  = bbox.world();
-    meta["boundingBox"]["ilxl"] = bbox.annotation();
+    if (metadata.is_2d()) {
+        meta["boundingBox"] = json_line_bounding_box(metadata);
+    } else {
+        auto bbox = metadata.bounding_box();
+        meta["boundingBox"]["ij"]   = bbox.index();
+        meta["boundingBox"]["cdp"]  = bbox.world();
+        meta["boundingBox"]["ilxl"] = bbox.annotation();
+    }
 
     SubCube volume(metadata);
 
-    Axis const& inline_axis = metadata.iline();
-    meta["axis"].push_back(json_axis(inline_axis, volume));
+    /* The inline of a 2D line is made up, and not one of its axes */
+    if (not metadata.is_2d()) {
+        Axis const& inline_axis = metadata.iline();
+        meta["axis"].push_back(json_axis(inline_axis, volume));
+    }
 
     Axis const& crossline_axis = metadata.xline();
     meta["axis"].push_back(json_axis(crossline_axis, volume));
@@ -235,6 +590,45 @@ void metadata(DataSource& datasource, response* out) {
     Axis const& sample_axis = metadata.sample();
     meta["axis"].push_back(json_axis(sample_axis, volume));
 
+    /* Only listed for the cubes that have any, see fence headerFields */
+    auto const headers = header_channels(metadata);
+    if (not headers.empty()) {
+        meta["headerChannels"] = nlohmann::json::array();
+        for (auto const& header : headers)
+            meta["headerChannels"].push_back(header.first);
+    }
+
+    meta["storedFormat"] = json_stored_format(metadata.layout());
+
+    if (include_layout)
+        meta["layout"] = json_layout(datasource);
+
+    return to_response(meta, out);
+}
+
+void compatibility(
+    DataSource& datasource_a,
+    DataSource& datasource_b,
+    double tolerance,
+    response* out
+) {
+    MetadataHandle const& a = datasource_a.get_metadata();
+    MetadataHandle const& b = datasource_b.get_metadata();
+
+    nlohmann::json meta;
+    meta["axis"] = nlohmann::json::array({
+        json_axis_compatibility(a.iline(),  b.iline()),
+        json_axis_compatibility(a.xline(),  b.xline()),
+        json_axis_compatibility(a.sample(), b.sample()),
+    });
+    meta["world"] = json_world_compatibility(a, b, tolerance);
+
+    bool compatible = meta["world"]["compatible"].get< bool >();
+    for (auto const& axis : meta["axis"]) {
+        compatible = compatible and axis["compatible"].get< bool >();
+    }
+    meta["compatible"] = compatible;
+
     return to_response(meta, out);
 }
 
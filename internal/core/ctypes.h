@@ -8,6 +8,17 @@ struct response {
 };
 typedef struct response response;
 
+/** Statistics about the work done on behalf of a request */
+struct request_stats {
+    /* Estimated number of bytes fetched from storage */
+    long long storage_bytes;
+    /* Estimated number of requests to storage */
+    long long storage_requests;
+    /* Seconds spent reading, i.e. fetching and decompressing */
+    double read_seconds;
+};
+typedef struct request_stats request_stats;
+
 enum axis_name {
     I         = 0,
     J         = 1,
@@ -30,7 +41,19 @@ enum interpolation_method {
     LINEAR,
     CUBIC,
     ANGULAR,
-    TRIANGULAR
+    TRIANGULAR,
+    /* Snap to the nearest trace and read it as is. Fence only */
+    NEAREST_TRACE,
+    /* Read traces as is, but only at exact trace positions. Fence only */
+    NO_INTERPOLATION
+};
+
+/* The format the samples of a slice are returned in */
+enum sample_format {
+    /* 32-bit floats, converted from the stored format by OpenVDS */
+    FLOAT32 = 0,
+    /* As stored in the VDS, without conversion */
+    STORED  = 1,
 };
 
 enum attribute {
@@ -50,13 +73,37 @@ enum attribute {
     VAR,
     SD,
     SUMPOS,
-    SUMNEG
+    SUMNEG,
+    SUM,
+    MAD,
+    MAXABSSIGNED
+};
+
+/**
+ * Why the nodes of an attribute were set to the fill value, as the number of
+ * nodes per reason
+ */
+struct attribute_coverage {
+    /* The reference, top or bottom surface is missing at the node */
+    size_t missing_surface;
+    /* The node is outside of the survey */
+    size_t outside_survey;
+    /* There is no data in the window at all, e.g. for dead traces */
+    size_t dead_traces;
+    /* Less than min_valid_fraction of the window holds data */
+    size_t below_min_valid_fraction;
+    /* The attribute is undefined for the data, e.g. value when the reference
+     * sample itself is absent */
+    size_t undefined;
 };
+typedef struct attribute_coverage attribute_coverage;
 
 struct Bound {
     int lower;
     int upper;
     enum axis_name name;
+    /* Whether lower and upper are indices or annotations */
+    enum coordinate_system system;
 };
 
 #endif // VDS_SLICE_CTYPES_H
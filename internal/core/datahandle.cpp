@@ -1,10 +1,16 @@
 #include "datahandle.hpp"
 
+#include <algorithm>
+#include <chrono>
+#include <cmath>
+#include <deque>
 #include <stdexcept>
+#include <vector>
 
 #include <OpenVDS/KnownMetadata.h>
 #include <OpenVDS/OpenVDS.h>
 
+#include "exceptions.hpp"
 #include "metadatahandle.hpp"
 #include "subcube.hpp"
 
@@ -24,6 +30,56 @@ OpenVDS::InterpolationMethod to_interpolation(interpolation_method interpolation
     }
 }
 
+/* Size of a single value of the format, in bytes */
+int format_size(OpenVDS::VolumeDataFormat format) {
+    switch (format)
+    {
+        case OpenVDS::VolumeDataFormat::Format_1Bit:
+        case OpenVDS::VolumeDataFormat::Format_U8:
+            return 1;
+        case OpenVDS::VolumeDataFormat::Format_U16:
+            return 2;
+        case OpenVDS::VolumeDataFormat::Format_R64:
+        case OpenVDS::VolumeDataFormat::Format_U64:
+            return 8;
+        default:
+            return 4;
+    }
+}
+
+/* Adds the time from its construction to its destruction to total */
+class ReadTimer {
+public:
+    explicit ReadTimer(std::chrono::steady_clock::duration& total)
+        : m_total(total)
+        , m_start(std::chrono::steady_clock::now())
+    {}
+
+    ~ReadTimer() {
+        this->m_total += std::chrono::steady_clock::now() - this->m_start;
+    }
+
+private:
+    std::chrono::steady_clock::duration& m_total;
+    std::chrono::steady_clock::time_point m_start;
+};
+
+/*
+ * A read that failed, with the status and reason of the failed download as
+ * the IOManager reports it
+ */
+detail::storage_error read_error(
+    OpenVDS::VolumeDataAccessManager& access_manager,
+    std::string msg
+) {
+    int status = 0;
+    const char* reason = nullptr;
+    if (access_manager.GetCurrentDownloadError(&status, &reason) and reason) {
+        msg += " " + std::to_string(status) + " " + reason;
+    }
+    return detail::storage_error(status, msg);
+}
+
 } /* namespace */
 
 DataHandle* make_datahandle(
@@ -33,7 +89,10 @@ DataHandle* make_datahandle(
     OpenVDS::Error error;
     auto handle = OpenVDS::Open(url, credentials, error);
     if(error.code != 0) {
-        throw std::runtime_error("Could not open VDS: " + error.string);
+        throw detail::storage_error(
+            error.code,
+            "Could not open VDS: " + error.string
+        );
     }
     return new DataHandle(std::move(handle));
 }
@@ -42,7 +101,69 @@ DataHandle::DataHandle(OpenVDS::VDSHandle handle)
     : m_file_handle(handle)
     , m_access_manager(OpenVDS::GetAccessManager(handle))
     , m_metadata(m_access_manager.GetVolumeDataLayout())
-{}
+{
+    auto const* layout = this->m_access_manager.GetVolumeDataLayout();
+
+    /*
+     * 2D lines are stored as 2D volumes, and are read as volumes of a single
+     * inline, in the dimension that is missing
+     */
+    this->m_dimensions = this->m_metadata.is_2d()
+        ? OpenVDS::Dimensions_01
+        : OpenVDS::Dimensions_012;
+
+    /* Chunks are bricks, i.e. cubes in 3D volumes and squares in 2D lines */
+    this->m_brick_size = 1 << int(layout->GetLayoutDescriptor().GetBrickSize());
+
+    std::int64_t const brick = this->m_brick_size;
+    std::int64_t const depth = this->m_metadata.is_2d() ? 1 : brick;
+    this->m_chunk_bytes = brick * brick * depth * ::format_size(
+        layout->GetChannelFormat(DataHandle::channel)
+    );
+}
+
+std::int64_t DataHandle::storage_bytes() const noexcept (true) {
+    return std::int64_t(this->m_fetched_chunks.size()) * this->m_chunk_bytes;
+}
+
+std::int64_t DataHandle::storage_requests() const noexcept (true) {
+    return std::int64_t(this->m_fetched_chunks.size());
+}
+
+double DataHandle::read_seconds() const noexcept (true) {
+    return std::chrono::duration< double >(this->m_read_time).count();
+}
+
+OpenVDS::CompressionMethod DataHandle::compression_method() const noexcept (false) {
+    return OpenVDS::GetCompressionMethod(this->m_file_handle);
+}
+
+float DataHandle::compression_tolerance() const noexcept (false) {
+    return OpenVDS::GetCompressionTolerance(this->m_file_handle);
+}
+
+/* Record that the chunk, given by its index in every dimension, is read */
+void DataHandle::fetch_chunk(int const chunk[3]) noexcept (false) {
+    std::int64_t key = 0;
+    for (int dimension = 2; dimension >= 0; --dimension) {
+        key = (key << 21) | std::int64_t(chunk[dimension]);
+    }
+
+    /* Consecutive reads tend to be in the same chunk, e.g. along a trace */
+    if (key == this->m_last_chunk) return;
+    this->m_last_chunk = key;
+    this->m_fetched_chunks.insert(key);
+}
+
+/* Record that the chunk holding the voxel position is read */
+void DataHandle::fetch_chunk_at(float const* position) noexcept (false) {
+    int chunk[3];
+    for (int dimension = 0; dimension < 3; ++dimension) {
+        int const index = std::max(0, int(std::floor(position[dimension])));
+        chunk[dimension] = index / this->m_brick_size;
+    }
+    this->fetch_chunk(chunk);
+}
 
 MetadataHandle const& DataHandle::get_metadata() const noexcept (true) {
     return this->m_metadata;
@@ -53,17 +174,41 @@ OpenVDS::VolumeDataFormat DataHandle::format() noexcept (true) {
      * We always want to request data in OpenVDS::VolumeDataFormat::Format_R32
      * format for slice. For fence documentation says: "The traces/samples are
      * always in 32-bit floating point format."
+     *
+     * OpenVDS converts from the format the data is stored in. Integer formats
+     * are mapped to the values they represent by the integer scale and
+     * offset of the channel, i.e. value = stored * scale + offset, such that
+     * quantized cubes are read as floats just like cubes stored as floats.
      */
     return OpenVDS::VolumeDataFormat::Format_R32;
 }
 
+OpenVDS::VolumeDataFormat DataHandle::stored_format() const noexcept (false) {
+    return this->m_access_manager.GetVolumeDataLayout()->GetChannelFormat(
+        DataHandle::channel
+    );
+}
+
 std::int64_t DataHandle::subcube_buffer_size(
     SubCube const& subcube
+) noexcept (false) {
+    return this->subcube_buffer_size(subcube, DataHandle::format());
+}
+
+std::int64_t DataHandle::stored_subcube_buffer_size(
+    SubCube const& subcube
+) noexcept (false) {
+    return this->subcube_buffer_size(subcube, this->stored_format());
+}
+
+std::int64_t DataHandle::subcube_buffer_size(
+    SubCube const& subcube,
+    OpenVDS::VolumeDataFormat format
 ) noexcept (false) {
     std::int64_t size = this->m_access_manager.GetVolumeSubsetBufferSize(
         subcube.bounds.lower,
         subcube.bounds.upper,
-        DataHandle::format(),
+        format,
         DataHandle::lod_level,
         DataHandle::channel
     );
@@ -72,25 +217,192 @@ std::int64_t DataHandle::subcube_buffer_size(
 }
 
 void DataHandle::read_subcube(
+    void * const buffer,
+    std::int64_t size,
+    SubCube const& subcube,
+    float const* fillvalue
+) noexcept (false) {
+    return this->read_subcube(
+        buffer,
+        size,
+        subcube,
+        DataHandle::format(),
+        fillvalue
+    );
+}
+
+void DataHandle::read_stored_subcube(
     void * const buffer,
     std::int64_t size,
     SubCube const& subcube
 ) noexcept (false) {
-    auto request = this->m_access_manager.RequestVolumeSubset(
+    return this->read_subcube(
         buffer,
         size,
-        OpenVDS::Dimensions_012,
+        subcube,
+        this->stored_format(),
+        nullptr
+    );
+}
+
+void DataHandle::read_subcube(
+    void * const buffer,
+    std::int64_t size,
+    SubCube const& subcube,
+    OpenVDS::VolumeDataFormat format,
+    float const* fillvalue
+) noexcept (false) {
+    auto request = [&](char* const tile_buffer, std::int64_t tile_size, SubCube const& tile) {
+        return fillvalue == nullptr
+            ? this->m_access_manager.RequestVolumeSubset(
+                tile_buffer,
+                tile_size,
+                this->m_dimensions,
+                DataHandle::lod_level,
+                DataHandle::channel,
+                tile.bounds.lower,
+                tile.bounds.upper,
+                format
+            )
+            : this->m_access_manager.RequestVolumeSubset(
+                tile_buffer,
+                tile_size,
+                this->m_dimensions,
+                DataHandle::lod_level,
+                DataHandle::channel,
+                tile.bounds.lower,
+                tile.bounds.upper,
+                format,
+                *fillvalue
+            );
+    };
+
+    std::vector< SubCube > const tiles = this->tiles(subcube);
+    std::vector< std::int64_t > offsets;
+    std::int64_t offset = 0;
+    for (auto const& tile : tiles) {
+        offsets.push_back(offset);
+        offset += this->subcube_buffer_size(tile, format);
+    }
+    if (offset != size) {
+        throw std::runtime_error(
+            "Expected the tiles of the subcube to fill the buffer of "
+            + std::to_string(size) + " bytes, they fill " + std::to_string(offset)
+        );
+    }
+
+    ::ReadTimer const timer(this->m_read_time);
+    char* const out = static_cast< char* >(buffer);
+    std::deque< decltype(request(out, size, subcube)) > in_flight;
+    bool success = true;
+    /*
+     * Every request writes into the buffer, so all of them are waited for
+     * before returning, also when one of them fails
+     */
+    auto wait_for_oldest = [&]() {
+        success = in_flight.front().get()->WaitForCompletion() and success;
+        in_flight.pop_front();
+    };
+
+    try {
+        for (std::size_t i = 0; i < tiles.size(); ++i) {
+            if (in_flight.size() == DataHandle::max_tiles_in_flight) {
+                wait_for_oldest();
+            }
+            std::int64_t const end = i + 1 < tiles.size() ? offsets[i + 1] : size;
+            in_flight.push_back(request(out + offsets[i], end - offsets[i], tiles[i]));
+        }
+    } catch (...) {
+        while (not in_flight.empty()) wait_for_oldest();
+        throw;
+    }
+    while (not in_flight.empty()) wait_for_oldest();
+
+    if (!success) {
+        throw ::read_error(this->m_access_manager, "Failed to read from VDS.");
+    }
+
+    int first[3];
+    int last[3];
+    for (int dimension = 0; dimension < 3; ++dimension) {
+        first[dimension] = subcube.bounds.lower[dimension] / this->m_brick_size;
+        last[dimension] = (subcube.bounds.upper[dimension] - 1) / this->m_brick_size;
+    }
+
+    int chunk[3];
+    for (chunk[2] = first[2]; chunk[2] <= last[2]; ++chunk[2]) {
+        for (chunk[1] = first[1]; chunk[1] <= last[1]; ++chunk[1]) {
+            for (chunk[0] = first[0]; chunk[0] <= last[0]; ++chunk[0]) {
+                this->fetch_chunk(chunk);
+            }
+        }
+    }
+}
+
+std::vector< SubCube > DataHandle::tiles(
+    SubCube const& subcube
+) const noexcept (false) {
+    int outermost = -1;
+    for (int dimension = 2; dimension >= 0; --dimension) {
+        if (subcube.bounds.upper[dimension] - subcube.bounds.lower[dimension] > 1) {
+            outermost = dimension;
+            break;
+        }
+    }
+    if (outermost == -1) return { subcube };
+
+    int const lower = subcube.bounds.lower[outermost];
+    int const upper = subcube.bounds.upper[outermost];
+    if (lower / this->m_brick_size == (upper - 1) / this->m_brick_size) {
+        return { subcube };
+    }
+
+    std::vector< SubCube > tiles;
+    for (int first = lower; first < upper;) {
+        int const next_brick = (first / this->m_brick_size + 1) * this->m_brick_size;
+        int const last = std::min(next_brick, upper);
+
+        SubCube tile = subcube;
+        tile.bounds.lower[outermost] = first;
+        tile.bounds.upper[outermost] = last;
+        tiles.push_back(tile);
+
+        first = last;
+    }
+    return tiles;
+}
+
+float DataHandle::read_trace_header(
+    int const channel,
+    SubCube const& trace
+) noexcept (false) {
+    /* Channels mapped per trace hold a single value along the trace */
+    SubCube header = trace;
+    int const dimension = this->get_metadata().sample().dimension();
+    header.bounds.lower[dimension] = 0;
+    header.bounds.upper[dimension] = 1;
+
+    float value;
+    ::ReadTimer const timer(this->m_read_time);
+    auto request = this->m_access_manager.RequestVolumeSubset(
+        &value,
+        sizeof(value),
+        this->m_dimensions,
         DataHandle::lod_level,
-        DataHandle::channel,
-        subcube.bounds.lower,
-        subcube.bounds.upper,
+        channel,
+        header.bounds.lower,
+        header.bounds.upper,
         DataHandle::format()
     );
     bool const success = request.get()->WaitForCompletion();
 
     if (!success) {
-        throw std::runtime_error("Failed to read from VDS.");
+        throw ::read_error(
+            this->m_access_manager,
+            "Failed to read trace header from VDS."
+        );
     }
+    return value;
 }
 
 std::int64_t DataHandle::traces_buffer_size(std::size_t const ntraces) noexcept (false) {
@@ -103,25 +415,55 @@ void DataHandle::read_traces(
     std::int64_t const              size,
     voxel const*                    coordinates,
     std::size_t const               ntraces,
-    enum interpolation_method const interpolation_method
+    enum interpolation_method const interpolation_method,
+    float const*                    fillvalue
 ) noexcept (false) {
     int const dimension = this->get_metadata().sample().dimension();
 
-    auto request = this->m_access_manager.RequestVolumeTraces(
-        (float*)buffer,
-        size,
-        OpenVDS::Dimensions_012,
-        DataHandle::lod_level,
-        DataHandle::channel,
-        coordinates,
-        ntraces,
-        ::to_interpolation(interpolation_method),
-        dimension
-    );
+    ::ReadTimer const timer(this->m_read_time);
+    auto request = fillvalue == nullptr
+        ? this->m_access_manager.RequestVolumeTraces(
+            (float*)buffer,
+            size,
+            this->m_dimensions,
+            DataHandle::lod_level,
+            DataHandle::channel,
+            coordinates,
+            ntraces,
+            ::to_interpolation(interpolation_method),
+            dimension
+        )
+        : this->m_access_manager.RequestVolumeTraces(
+            (float*)buffer,
+            size,
+            this->m_dimensions,
+            DataHandle::lod_level,
+            DataHandle::channel,
+            coordinates,
+            ntraces,
+            ::to_interpolation(interpolation_method),
+            dimension,
+            *fillvalue
+        );
     bool const success = request.get()->WaitForCompletion();
 
     if (!success) {
-        throw std::runtime_error("Failed to read from VDS.");
+        throw ::read_error(this->m_access_manager, "Failed to read from VDS.");
+    }
+
+    /* Whole traces are read, i.e. every chunk along the trace dimension */
+    int const nsamples = this->get_metadata().sample().nsamples();
+    int const nchunks = (nsamples + this->m_brick_size - 1) / this->m_brick_size;
+    for (std::size_t i = 0; i < ntraces; ++i) {
+        int chunk[3];
+        for (int d = 0; d < 3; ++d) {
+            int const index = std::max(0, int(std::floor(coordinates[i][d])));
+            chunk[d] = index / this->m_brick_size;
+        }
+
+        for (chunk[dimension] = 0; chunk[dimension] < nchunks; ++chunk[dimension]) {
+            this->fetch_chunk(chunk);
+        }
     }
 }
 
@@ -139,21 +481,39 @@ void DataHandle::read_samples(
     std::int64_t const              size,
     voxel const*                    samples,
     std::size_t const               nsamples,
-    enum interpolation_method const interpolation_method
+    enum interpolation_method const interpolation_method,
+    float const*                    fillvalue
 ) noexcept (false) {
-    auto request = this->m_access_manager.RequestVolumeSamples(
-        (float*)buffer,
-        size,
-        OpenVDS::Dimensions_012,
-        DataHandle::lod_level,
-        DataHandle::channel,
-        samples,
-        nsamples,
-        ::to_interpolation(interpolation_method)
-    );
+    ::ReadTimer const timer(this->m_read_time);
+    auto request = fillvalue == nullptr
+        ? this->m_access_manager.RequestVolumeSamples(
+            (float*)buffer,
+            size,
+            this->m_dimensions,
+            DataHandle::lod_level,
+            DataHandle::channel,
+            samples,
+            nsamples,
+            ::to_interpolation(interpolation_method)
+        )
+        : this->m_access_manager.RequestVolumeSamples(
+            (float*)buffer,
+            size,
+            this->m_dimensions,
+            DataHandle::lod_level,
+            DataHandle::channel,
+            samples,
+            nsamples,
+            ::to_interpolation(interpolation_method),
+            *fillvalue
+        );
 
     bool const success = request.get()->WaitForCompletion();
     if (!success) {
-        throw std::runtime_error("Failed to read from VDS.");
+        throw ::read_error(this->m_access_manager, "Failed to read from VDS.");
+    }
+
+    for (std::size_t i = 0; i < nsamples; ++i) {
+        this->fetch_chunk_at(samples[i]);
     }
 }
@@ -1,8 +1,12 @@
 #ifndef VDS_SLICE_DATAHANDLE_HPP
 #define VDS_SLICE_DATAHANDLE_HPP
 
+#include <chrono>
+#include <cstdint>
 #include <memory>
 #include <string>
+#include <unordered_set>
+#include <vector>
 
 #include <OpenVDS/OpenVDS.h>
 
@@ -20,9 +24,36 @@ public:
 
     static OpenVDS::VolumeDataFormat format() noexcept (true);
 
+    /* The format the data is stored in, see read_stored_subcube */
+    OpenVDS::VolumeDataFormat stored_format() const noexcept (false);
+
     std::int64_t subcube_buffer_size(SubCube const& subcube) noexcept (false);
 
+    /*
+     * Absent data, i.e. regions of the VDS that have no data, is replaced
+     * by fillvalue, unless it is null.
+     *
+     * Subcubes that span more than one brick along their outermost dimension
+     * are read as tiles, see tiles(), which are requested concurrently such
+     * that their bricks are decompressed in parallel. The data is identical
+     * to that of a single request.
+     */
     void read_subcube(
+        void * const buffer,
+        std::int64_t size,
+        SubCube const& subcube,
+        float const* fillvalue
+    ) noexcept (false);
+
+    std::int64_t stored_subcube_buffer_size(
+        SubCube const& subcube
+    ) noexcept (false);
+
+    /*
+     * Like read_subcube, but in the format the data is stored in, without
+     * conversion to float. Absent data is left as stored by OpenVDS.
+     */
+    void read_stored_subcube(
         void * const buffer,
         std::int64_t size,
         SubCube const& subcube
@@ -30,29 +61,110 @@ public:
 
     std::int64_t traces_buffer_size(std::size_t const ntraces) noexcept (false);
 
+    /*
+     * Absent data is replaced by fillvalue, unless it is null.
+     */
     void read_traces(
         void * const                    buffer,
         std::int64_t const              size,
         voxel const*                    coordinates,
         std::size_t const               ntraces,
-        enum interpolation_method const interpolation_method
+        enum interpolation_method const interpolation_method,
+        float const*                    fillvalue
     ) noexcept (false);
 
 
+    /*
+     * The value of a channel mapped per trace at a single trace, where the
+     * inline and crossline bounds of trace give the trace. Reads of header
+     * channels are not counted in storage_bytes.
+     */
+    float read_trace_header(
+        int const channel,
+        SubCube const& trace
+    ) noexcept (false);
+
     std::int64_t samples_buffer_size(std::size_t const nsamples) noexcept (false);
 
+    /*
+     * Absent data is replaced by fillvalue, unless it is null.
+     */
     void read_samples(
         void * const                    buffer,
         std::int64_t const              size,
         voxel const*                    samples,
         std::size_t const               nsamples,
-        enum interpolation_method const interpolation_method
+        enum interpolation_method const interpolation_method,
+        float const*                    fillvalue
     ) noexcept (false);
 
+    /*
+     * Estimated number of bytes fetched from storage by the reads through
+     * this handle, i.e. the uncompressed size of every chunk read at least
+     * once. Chunks that are compressed in storage are counted in full, and
+     * neighbouring chunks that are only touched by interpolation are not
+     * counted at all.
+     */
+    std::int64_t storage_bytes() const noexcept (true);
+
+    /*
+     * Estimated number of requests to storage by the reads through this
+     * handle, i.e. the number of chunks read at least once, as every chunk
+     * is a blob of its own that the OpenVDS cache of the handle fetches
+     * once. Counted like storage_bytes.
+     */
+    std::int64_t storage_requests() const noexcept (true);
+
+    /*
+     * Seconds spent in the reads through this handle, which is mostly the
+     * wait for OpenVDS to fetch and decompress the chunks. OpenVDS does not
+     * tell the two apart.
+     */
+    double read_seconds() const noexcept (true);
+
+    /* The compression of the chunks in storage, and its error tolerance */
+    OpenVDS::CompressionMethod compression_method() const noexcept (false);
+    float compression_tolerance() const noexcept (false);
+
 private:
     OpenVDS::ScopedVDSHandle m_file_handle;
     OpenVDS::VolumeDataAccessManager m_access_manager;
     SingleMetadataHandle m_metadata;
+    OpenVDS::DimensionsND m_dimensions;
+
+    int m_brick_size;
+    std::int64_t m_chunk_bytes;
+    std::unordered_set< std::int64_t > m_fetched_chunks;
+    std::int64_t m_last_chunk = -1;
+    std::chrono::steady_clock::duration m_read_time{};
+
+    void fetch_chunk(int const chunk[3]) noexcept (false);
+
+    std::int64_t subcube_buffer_size(
+        SubCube const& subcube,
+        OpenVDS::VolumeDataFormat format
+    ) noexcept (false);
+
+    void read_subcube(
+        void * const buffer,
+        std::int64_t size,
+        SubCube const& subcube,
+        OpenVDS::VolumeDataFormat format,
+        float const* fillvalue
+    ) noexcept (false);
+
+    /*
+     * The subcube split into bands along its outermost dimension of more
+     * than one sample, with the bands aligned to the bricks. The outermost
+     * dimension varies the slowest in the buffer of the subcube, so every
+     * band is a contiguous range of the buffer, in order. A subcube within
+     * a single band is returned as is.
+     */
+    std::vector< SubCube > tiles(SubCube const& subcube) const noexcept (false);
+
+    /* Most tiles of a subcube that are requested at the same time */
+    static std::size_t constexpr max_tiles_in_flight = 32;
+    void fetch_chunk_at(float const* position) noexcept (false);
 
     static int constexpr lod_level = 0;
     static int constexpr channel = 0;
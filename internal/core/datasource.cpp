@@ -1,3 +1,5 @@
+#include <cmath>
+
 #include "exceptions.hpp"
 #include "datasource.hpp"
 #include "datahandle.hpp"
@@ -26,9 +28,12 @@ void SingleDataSource::read_samples(
     std::int64_t const size,
     voxel const* samples,
     std::size_t const nsamples,
-    enum interpolation_method const interpolation_method
+    enum interpolation_method const interpolation_method,
+    float const* fillvalue
 ) noexcept(false) {
-    return this->handle->read_samples(buffer, size, samples, nsamples, interpolation_method);
+    return this->handle->read_samples(
+        buffer, size, samples, nsamples, interpolation_method, fillvalue
+    );
 }
 
 std::int64_t SingleDataSource::subcube_buffer_size(SubCube const& subcube) noexcept(false) {
@@ -36,11 +41,28 @@ std::int64_t SingleDataSource::subcube_buffer_size(SubCube const& subcube) noexc
 }
 
 void SingleDataSource::read_subcube(
+    void* const buffer,
+    std::int64_t size,
+    SubCube const& subcube,
+    float const* fillvalue
+) noexcept(false) {
+    this->handle->read_subcube(buffer, size, subcube, fillvalue);
+}
+
+OpenVDS::VolumeDataFormat SingleDataSource::stored_format() const noexcept(false) {
+    return this->handle->stored_format();
+}
+
+std::int64_t SingleDataSource::stored_subcube_buffer_size(SubCube const& subcube) noexcept(false) {
+    return this->handle->stored_subcube_buffer_size(subcube);
+}
+
+void SingleDataSource::read_stored_subcube(
     void* const buffer,
     std::int64_t size,
     SubCube const& subcube
 ) noexcept(false) {
-    this->handle->read_subcube(buffer, size, subcube);
+    this->handle->read_stored_subcube(buffer, size, subcube);
 }
 
 std::int64_t SingleDataSource::traces_buffer_size(std::size_t const ntraces) noexcept(false) {
@@ -52,10 +74,45 @@ void SingleDataSource::read_traces(
     std::int64_t const size,
     voxel const* coordinates,
     std::size_t const ntraces,
-    enum interpolation_method const interpolation_method
+    enum interpolation_method const interpolation_method,
+    float const* fillvalue
 ) noexcept(false) {
 
-    this->handle->read_traces(buffer, size, coordinates, ntraces, interpolation_method);
+    this->handle->read_traces(
+        buffer,
+        size,
+        coordinates,
+        ntraces,
+        interpolation_method,
+        fillvalue
+    );
+}
+
+float SingleDataSource::read_trace_header(
+    int const channel,
+    SubCube const& trace
+) noexcept(false) {
+    return this->handle->read_trace_header(channel, trace);
+}
+
+std::int64_t SingleDataSource::storage_bytes() const noexcept(true) {
+    return this->handle->storage_bytes();
+}
+
+std::int64_t SingleDataSource::storage_requests() const noexcept(true) {
+    return this->handle->storage_requests();
+}
+
+double SingleDataSource::read_seconds() const noexcept(true) {
+    return this->handle->read_seconds();
+}
+
+OpenVDS::CompressionMethod SingleDataSource::compression_method() const noexcept(false) {
+    return this->handle->compression_method();
+}
+
+float SingleDataSource::compression_tolerance() const noexcept(false) {
+    return this->handle->compression_tolerance();
 }
 
 SingleDataSource* make_single_datasource(
@@ -99,7 +156,9 @@ std::int64_t DoubleDataSource::samples_buffer_size(std::size_t const nsamples) n
     std::int64_t size_a = this->handle_A->samples_buffer_size(nsamples);
     std::int64_t size_b = this->handle_B->samples_buffer_size(nsamples);
     if (size_a != size_b) {
-        throw detail::bad_request("Mismatch in sample buffer size");
+        throw detail::bad_request(
+            "incompatible_vds", {}, "Mismatch in sample buffer size"
+        );
     }
     return size_a;
 }
@@ -109,15 +168,20 @@ void DoubleDataSource::read_samples(
     std::int64_t const size,
     voxel const* samples,
     std::size_t const nsamples,
-    interpolation_method const interpolation_method
+    interpolation_method const interpolation_method,
+    float const* fillvalue
 ) noexcept(false) {
-
+    float* const buffer_A = (float*)buffer;
     std::vector<float> buffer_B(nsamples);
 
-    this->handle_A->read_samples((float*)buffer, size, samples, nsamples, interpolation_method);
-    this->handle_B->read_samples(buffer_B.data(), size, samples, nsamples, interpolation_method);
+    this->handle_A->read_samples(
+        buffer_A, size, samples, nsamples, interpolation_method, fillvalue
+    );
+    this->handle_B->read_samples(
+        buffer_B.data(), size, samples, nsamples, interpolation_method, fillvalue
+    );
 
-    this->binary_operator((float*)buffer, buffer_B.data(), nsamples);
+    this->combine(buffer_A, buffer_B.data(), nsamples, fillvalue);
 }
 
 std::int64_t DoubleDataSource::subcube_buffer_size(SubCube const& subcube) noexcept(false) {
@@ -125,21 +189,71 @@ std::int64_t DoubleDataSource::subcube_buffer_size(SubCube const& subcube) noexc
     std::int64_t size_a = this->handle_A->subcube_buffer_size(subcube);
     std::int64_t size_b = this->handle_B->subcube_buffer_size(subcube);
     if (size_a != size_b) {
-        throw detail::bad_request("Mismatch in subcube buffer size");
+        throw detail::bad_request(
+            "incompatible_vds", {}, "Mismatch in subcube buffer size"
+        );
     }
     return size_a;
 }
 
 void DoubleDataSource::read_subcube(
+    void* const buffer,
+    std::int64_t size,
+    SubCube const& subcube,
+    float const* fillvalue
+) noexcept(false) {
+    std::size_t const nsamples = (int)size / sizeof(float);
+    float* const buffer_A = (float*)buffer;
+    std::vector<float> buffer_B(nsamples);
+    this->handle_A->read_subcube(buffer_A, size, subcube, fillvalue);
+    this->handle_B->read_subcube(buffer_B.data(), size, subcube, fillvalue);
+
+    this->combine(buffer_A, buffer_B.data(), nsamples, fillvalue);
+}
+
+/* The cubes are combined as floats, so there is no stored format to return */
+OpenVDS::VolumeDataFormat DoubleDataSource::stored_format() const noexcept(false) {
+    return OpenVDS::VolumeDataFormat::Format_R32;
+}
+
+std::int64_t DoubleDataSource::stored_subcube_buffer_size(SubCube const& subcube) noexcept(false) {
+    throw detail::bad_request("Data of combined cubes can not be returned raw");
+}
+
+void DoubleDataSource::read_stored_subcube(
     void* const buffer,
     std::int64_t size,
     SubCube const& subcube
 ) noexcept(false) {
-    std::vector<float> buffer_B((int)size / sizeof(float));
-    this->handle_A->read_subcube((float*)buffer, size, subcube);
-    this->handle_B->read_subcube(buffer_B.data(), size, subcube);
+    throw detail::bad_request("Data of combined cubes can not be returned raw");
+}
+
+void DoubleDataSource::combine(
+    float* buffer_A,
+    float const* buffer_B,
+    std::size_t nsamples,
+    float const* fillvalue
+) noexcept(false) {
+    if (fillvalue == nullptr) {
+        this->binary_operator(buffer_A, buffer_B, nsamples);
+        return;
+    }
 
-    this->binary_operator((float*)buffer, buffer_B.data(), (int)size / sizeof(float));
+    /* Data that is absent in either source is absent in the result */
+    auto is_fill = [fillvalue](float value) {
+        if (std::isnan(*fillvalue)) return std::isnan(value);
+        return value == *fillvalue;
+    };
+    std::vector<bool> absent(nsamples);
+    for (std::size_t i = 0; i < nsamples; ++i) {
+        absent[i] = is_fill(buffer_A[i]) or is_fill(buffer_B[i]);
+    }
+
+    this->binary_operator(buffer_A, buffer_B, nsamples);
+
+    for (std::size_t i = 0; i < nsamples; ++i) {
+        if (absent[i]) buffer_A[i] = *fillvalue;
+    }
 }
 
 std::int64_t DoubleDataSource::traces_buffer_size(
@@ -149,7 +263,9 @@ std::int64_t DoubleDataSource::traces_buffer_size(
     std::int64_t size_a = this->handle_A->traces_buffer_size(ntraces);
     std::int64_t size_b = this->handle_B->traces_buffer_size(ntraces);
     if (size_a != size_b) {
-        throw detail::bad_request("Mismatch in trace buffer size");
+        throw detail::bad_request(
+            "incompatible_vds", {}, "Mismatch in trace buffer size"
+        );
     }
     return size_a;
 }
@@ -159,15 +275,17 @@ void DoubleDataSource::read_traces(
     std::int64_t const size,
     voxel const* coordinates,
     std::size_t const ntraces,
-    interpolation_method const interpolation_method
+    interpolation_method const interpolation_method,
+    float const* fillvalue
 ) noexcept(false) {
+    std::size_t const nsamples = (int)size / sizeof(float);
+    float* const buffer_A = (float*)buffer;
+    std::vector<float> buffer_B(nsamples);
 
-    std::vector<float> buffer_B((int)size / sizeof(float));
-
-    this->handle_A->read_traces((float*)buffer, size, coordinates, ntraces, interpolation_method);
-    this->handle_B->read_traces(buffer_B.data(), size, coordinates, ntraces, interpolation_method);
+    this->handle_A->read_traces(buffer_A, size, coordinates, ntraces, interpolation_method, fillvalue);
+    this->handle_B->read_traces(buffer_B.data(), size, coordinates, ntraces, interpolation_method, fillvalue);
 
-    this->binary_operator((float*)buffer, buffer_B.data(), (int)size / sizeof(float));
+    this->combine(buffer_A, buffer_B.data(), nsamples, fillvalue);
 }
 
 
@@ -205,3 +323,30 @@ void inplace_division(float* buffer_A, const float* buffer_B, std::size_t nsampl
         buffer_A[i] /= buffer_B[i];
     }
 }
+
+float DoubleDataSource::read_trace_header(
+    int const channel,
+    SubCube const& trace
+) noexcept(false) {
+    return this->handle_A->read_trace_header(channel, trace);
+}
+
+std::int64_t DoubleDataSource::storage_bytes() const noexcept(true) {
+    return this->handle_A->storage_bytes() + this->handle_B->storage_bytes();
+}
+
+std::int64_t DoubleDataSource::storage_requests() const noexcept(true) {
+    return this->handle_A->storage_requests() + this->handle_B->storage_requests();
+}
+
+double DoubleDataSource::read_seconds() const noexcept(true) {
+    return this->handle_A->read_seconds() + this->handle_B->read_seconds();
+}
+
+OpenVDS::CompressionMethod DoubleDataSource::compression_method() const noexcept(false) {
+    throw std::runtime_error("Not implemented");
+}
+
+float DoubleDataSource::compression_tolerance() const noexcept(false) {
+    throw std::runtime_error("Not implemented");
+}
@@ -22,11 +22,24 @@ public:
         std::int64_t const size,
         voxel const *samples,
         std::size_t const nsamples,
-        enum interpolation_method const interpolation_method) noexcept(false) = 0;
+        enum interpolation_method const interpolation_method,
+        float const *fillvalue) noexcept(false) = 0;
 
     virtual std::int64_t subcube_buffer_size(SubCube const &subcube) noexcept(false) = 0;
 
     virtual void read_subcube(
+        void *const buffer,
+        std::int64_t size,
+        SubCube const &subcube,
+        float const *fillvalue) noexcept(false) = 0;
+
+    /* The format the data is stored in, see read_stored_subcube */
+    virtual OpenVDS::VolumeDataFormat stored_format() const noexcept(false) = 0;
+
+    virtual std::int64_t stored_subcube_buffer_size(SubCube const &subcube) noexcept(false) = 0;
+
+    /* The subcube as stored, see DataHandle::read_stored_subcube */
+    virtual void read_stored_subcube(
         void *const buffer,
         std::int64_t size,
         SubCube const &subcube) noexcept(false) = 0;
@@ -38,7 +51,22 @@ public:
         std::int64_t const size,
         voxel const *coordinates,
         std::size_t const ntraces,
-        enum interpolation_method const interpolation_method) noexcept(false) = 0;
+        enum interpolation_method const interpolation_method,
+        float const *fillvalue) noexcept(false) = 0;
+
+    virtual float read_trace_header(
+        int const channel,
+        SubCube const &trace) noexcept(false) = 0;
+
+    /* Estimated number of bytes fetched from storage by the reads so far */
+    virtual std::int64_t storage_bytes() const noexcept(true) = 0;
+    /* Estimated number of requests to storage by the reads so far */
+    virtual std::int64_t storage_requests() const noexcept(true) = 0;
+    /* Seconds spent reading so far, see DataHandle::read_seconds */
+    virtual double read_seconds() const noexcept(true) = 0;
+
+    virtual OpenVDS::CompressionMethod compression_method() const noexcept(false) = 0;
+    virtual float compression_tolerance() const noexcept(false) = 0;
 };
 
 class SingleDataSource : public DataSource {
@@ -57,11 +85,22 @@ public:
         std::int64_t const size,
         voxel const *samples,
         std::size_t const nsamples,
-        enum interpolation_method const interpolation_method) noexcept(false);
+        enum interpolation_method const interpolation_method,
+        float const *fillvalue) noexcept(false);
 
     std::int64_t subcube_buffer_size(SubCube const &subcube) noexcept(false);
 
     void read_subcube(
+        void *const buffer,
+        std::int64_t size,
+        SubCube const &subcube,
+        float const *fillvalue) noexcept(false);
+
+    OpenVDS::VolumeDataFormat stored_format() const noexcept(false);
+
+    std::int64_t stored_subcube_buffer_size(SubCube const &subcube) noexcept(false);
+
+    void read_stored_subcube(
         void *const buffer,
         std::int64_t size,
         SubCube const &subcube) noexcept(false);
@@ -73,7 +112,19 @@ public:
         std::int64_t const size,
         voxel const *coordinates,
         std::size_t const ntraces,
-        enum interpolation_method const interpolation_method) noexcept(false);
+        enum interpolation_method const interpolation_method,
+        float const *fillvalue) noexcept(false);
+
+    float read_trace_header(
+        int const channel,
+        SubCube const &trace) noexcept(false);
+
+    std::int64_t storage_bytes() const noexcept(true);
+    std::int64_t storage_requests() const noexcept(true);
+    double read_seconds() const noexcept(true);
+
+    OpenVDS::CompressionMethod compression_method() const noexcept(false);
+    float compression_tolerance() const noexcept(false);
 
 private:
     DataHandle *handle;
@@ -104,11 +155,22 @@ public:
         std::int64_t const size,
         voxel const *samples,
         std::size_t const nsamples,
-        enum interpolation_method const interpolation_method) noexcept(false);
+        enum interpolation_method const interpolation_method,
+        float const *fillvalue) noexcept(false);
 
     std::int64_t subcube_buffer_size(SubCube const &subcube) noexcept(false);
 
     void read_subcube(
+        void *const buffer,
+        std::int64_t size,
+        SubCube const &subcube,
+        float const *fillvalue) noexcept(false);
+
+    OpenVDS::VolumeDataFormat stored_format() const noexcept(false);
+
+    std::int64_t stored_subcube_buffer_size(SubCube const &subcube) noexcept(false);
+
+    void read_stored_subcube(
         void *const buffer,
         std::int64_t size,
         SubCube const &subcube) noexcept(false);
@@ -120,13 +182,37 @@ public:
         std::int64_t const size,
         voxel const *coordinates,
         std::size_t const ntraces,
-        enum interpolation_method const interpolation_method) noexcept(false);
+        enum interpolation_method const interpolation_method,
+        float const *fillvalue) noexcept(false);
+
+    /* The trace headers of the first cube, which the metadata is of too */
+    float read_trace_header(
+        int const channel,
+        SubCube const &trace) noexcept(false);
+
+    std::int64_t storage_bytes() const noexcept(true);
+    std::int64_t storage_requests() const noexcept(true);
+    double read_seconds() const noexcept(true);
+
+    OpenVDS::CompressionMethod compression_method() const noexcept(false);
+    float compression_tolerance() const noexcept(false);
 
 private:
     DataSource *handle_A;
     DataSource *handle_B;
     MetadataHandle *metadata;
     binary_function binary_operator;
+
+    /*
+     * Applies the binary operator to buffer_A and buffer_B, storing the result
+     * in buffer_A. Data that is absent in either source, i.e. equal to
+     * fillvalue, is absent in the result.
+     */
+    void combine(
+        float *buffer_A,
+        float const *buffer_B,
+        std::size_t nsamples,
+        float const *fillvalue) noexcept(false);
 };
 
 DoubleDataSource *make_double_datasource(
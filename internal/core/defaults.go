@@ -0,0 +1,81 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+)
+
+/** Defaults of the options requests may leave out
+ *
+ * Organizations have their own conventions, e.g. cubic interpolation
+ * everywhere, or absent data as -999.25, so every deployment configures its
+ * own. Options given by a request always take precedence. The zero value is
+ * the default of the server itself: nearest interpolation, and no fill value,
+ * which leaves absent data as stored by OpenVDS.
+ */
+type Defaults struct {
+	// Horizontal interpolation method, nearest if empty
+	Interpolation string
+	// Fill value of absent data, none if nil
+	FillValue *float32
+}
+
+/** Defaults of a deployment, with the interpolation method checked
+ *
+ * The interpolation method must be valid for every endpoint, so the fence
+ * only methods nearest_trace and none can not be the default.
+ */
+func NewDefaults(interpolation string, fillValue *float32) (Defaults, error) {
+	name := strings.ToLower(strings.TrimSpace(interpolation))
+	if name != "" {
+		if _, ok := lookupOption(interpolationOptions, name); !ok {
+			msg := "invalid default interpolation method '%s', valid " +
+				"options are: %s"
+			return Defaults{}, NewInvalidArgument(fmt.Sprintf(
+				msg,
+				interpolation,
+				enumerate(InterpolationMethods()),
+			))
+		}
+	}
+	return Defaults{Interpolation: name, FillValue: fillValue}, nil
+}
+
+/* The interpolation method of requests that do not give one */
+func (d Defaults) DefaultInterpolation() string {
+	if d.Interpolation == "" {
+		return defaultInterpolation
+	}
+	return d.Interpolation
+}
+
+/* The interpolation method a request is answered with */
+func (d Defaults) ResolveInterpolation(interpolation string) string {
+	if strings.TrimSpace(interpolation) == "" {
+		return d.DefaultInterpolation()
+	}
+	return interpolation
+}
+
+/* See GetInterpolationMethod */
+func (d Defaults) InterpolationMethod(interpolation string) (int, error) {
+	return getInterpolationMethod(
+		interpolationOptions,
+		interpolation,
+		d.DefaultInterpolation(),
+	)
+}
+
+/* See GetFenceInterpolationMethod */
+func (d Defaults) FenceInterpolationMethod(interpolation string) (int, error) {
+	return getInterpolationMethod(
+		fenceInterpolationOptions,
+		interpolation,
+		d.DefaultInterpolation(),
+	)
+}
+
+/* The fill value of the request, or the default if it has none */
+func (d Defaults) ResolveFillValue(fillValue *float32) *float32 {
+	return ResolveFillValue(fillValue, d.FillValue)
+}
@@ -0,0 +1,70 @@
+package core
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewDefaults(t *testing.T) {
+	defaults, err := NewDefaults(" Cubic ", nil)
+	require.NoError(t, err)
+	require.Equal(t, "cubic", defaults.Interpolation)
+
+	defaults, err = NewDefaults("", nil)
+	require.NoError(t, err)
+	require.Equal(t, "nearest", defaults.DefaultInterpolation())
+
+	for _, interpolation := range []string{"nearest_trace", "none", "bogus"} {
+		_, err := NewDefaults(interpolation, nil)
+		require.IsType(t, &InvalidArgument{}, err, interpolation)
+		require.Contains(t, err.Error(), "invalid default interpolation method", interpolation)
+	}
+}
+
+func TestDefaultsInterpolation(t *testing.T) {
+	cubic, _ := GetInterpolationMethod("cubic")
+	linear, _ := GetInterpolationMethod("linear")
+	nearest, _ := GetInterpolationMethod("nearest")
+	fenceCubic, _ := GetFenceInterpolationMethod("cubic")
+
+	defaults := Defaults{Interpolation: "cubic"}
+
+	method, err := defaults.InterpolationMethod("")
+	require.NoError(t, err)
+	require.Equal(t, cubic, method)
+
+	method, err = defaults.InterpolationMethod("Linear")
+	require.NoError(t, err)
+	require.Equal(t, linear, method)
+
+	method, err = defaults.FenceInterpolationMethod("")
+	require.NoError(t, err)
+	require.Equal(t, fenceCubic, method)
+
+	method, err = Defaults{}.InterpolationMethod("")
+	require.NoError(t, err)
+	require.Equal(t, nearest, method)
+
+	require.Equal(t, "cubic", defaults.ResolveInterpolation(" "))
+	require.Equal(t, "linear", defaults.ResolveInterpolation("linear"))
+	require.Equal(t, "nearest", Defaults{}.ResolveInterpolation(""))
+}
+
+func TestDefaultsFillValue(t *testing.T) {
+	fillValue := float32(-999.25)
+	nan := float32(math.NaN())
+	zero := float32(0)
+
+	require.Nil(t, Defaults{}.ResolveFillValue(nil))
+	require.Equal(t, &zero, Defaults{}.ResolveFillValue(&zero))
+
+	defaults := Defaults{FillValue: &fillValue}
+	require.Equal(t, &fillValue, defaults.ResolveFillValue(nil))
+	require.Equal(t, &zero, defaults.ResolveFillValue(&zero))
+
+	resolved := Defaults{FillValue: &nan}.ResolveFillValue(nil)
+	require.NotNil(t, resolved)
+	require.True(t, math.IsNaN(float64(*resolved)))
+}
@@ -6,8 +6,10 @@
 
 #include "ctypes.h"
 
-enum coordinate_system Direction::coordinate_system() const noexcept(false) {
-    switch (this->name()) {
+namespace {
+
+enum coordinate_system default_coordinate_system(enum axis_name const axis_name) {
+    switch (axis_name) {
         case I:
         case J:
         case K:
@@ -24,6 +26,17 @@ enum coordinate_system Direction::coordinate_system() const noexcept(false) {
     }
 }
 
+} /* namespace */
+
+Direction::Direction(enum axis_name const axis_name)
+    : m_axis_name(axis_name),
+      m_coordinate_system(default_coordinate_system(axis_name))
+{}
+
+enum coordinate_system Direction::coordinate_system() const noexcept(false) {
+    return this->m_coordinate_system;
+}
+
 std::string Direction::to_string() const noexcept(false) {
     switch (this->name()) {
         case I:         return std::string( OpenVDS::KnownAxisNames::I()         );
@@ -5,9 +5,18 @@
 
 #include "ctypes.h"
 
+/** Direction of a slice or bound
+ *
+ * Linenos along i, j and k are indices, and annotations along the other
+ * directions, unless the coordinate system is given explicitly.
+ */
 class Direction {
 public:
-    explicit Direction(enum axis_name const axis_name) : m_axis_name(axis_name) {}
+    explicit Direction(enum axis_name const axis_name);
+    Direction(
+        enum axis_name const         axis_name,
+        enum coordinate_system const system
+    ) : m_axis_name(axis_name), m_coordinate_system(system) {}
 
     enum coordinate_system coordinate_system() const noexcept (false);
     std::string            to_string()         const noexcept (false);
@@ -17,7 +26,8 @@ public:
     bool is_xline()  const noexcept (true);
     bool is_sample() const noexcept (true);
 private:
-    enum axis_name const m_axis_name;
+    enum axis_name const         m_axis_name;
+    enum coordinate_system const m_coordinate_system;
 };
 
 
@@ -1,6 +1,46 @@
 package core
 
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+)
+
+/** Details of an error, handed back to clients together with its code */
+type ErrorDetails map[string]string
+
+/** A stable, machine readable code of an error, and its details
+ *
+ * The code is set where the error is created, as only there is it known what
+ * went wrong. Codes are part of the API. Messages may be reworded freely, but
+ * codes must never change meaning, and every code must be registered with
+ * the api, see api.errorCodes. Errors without a code are handed back with the
+ * default code of their type.
+ */
+type ErrorCode struct {
+	code    string
+	details ErrorDetails
+}
+
+func (e ErrorCode) Code() string {
+	return e.code
+}
+
+func (e ErrorCode) Details() ErrorDetails {
+	return e.details
+}
+
+/* An error that may have a code, see ErrorCode */
+type CodedError interface {
+	error
+	Code() string
+	Details() ErrorDetails
+}
+
 type InvalidArgument struct {
+	ErrorCode
 	message string
 }
 
@@ -14,8 +54,22 @@ func NewInvalidArgument(msg string) *InvalidArgument {
 	}
 }
 
+/* InvalidArgument with a code, see ErrorCode */
+func NewCodedInvalidArgument(
+	code    string,
+	details ErrorDetails,
+	msg     string,
+) *InvalidArgument {
+	return &InvalidArgument{
+		ErrorCode: ErrorCode{ code: code, details: details },
+		message:   msg,
+	}
+}
+
 type InternalError struct {
+	ErrorCode
 	message string
+	status  int
 }
 
 func (e *InternalError) Error() string {
@@ -25,3 +79,272 @@ func (e *InternalError) Error() string {
 func NewInternalError(msg string) *InternalError {
 	return &InternalError{ message: msg }
 }
+
+/** A failed request to storage, with the status reported by OpenVDS
+ *
+ * The status is the one of the IOManager, which is the HTTP status for the
+ * cloud backends, or 0 if it is unknown. It decides whether the request is
+ * worth retrying, see isTransient.
+ */
+func NewStorageError(msg string, status int) *InternalError {
+	return &InternalError{ message: msg, status: status }
+}
+
+/** The requested VDS (or the storage container holding it) does not exist */
+type NotFoundError struct {
+	ErrorCode
+	message string
+}
+
+func (e *NotFoundError) Error() string {
+	return e.message
+}
+
+func NewNotFoundError(msg string) *NotFoundError {
+	return &NotFoundError{ message: msg }
+}
+
+/* NotFoundError with a code, see ErrorCode */
+func NewCodedNotFoundError(
+	code    string,
+	details ErrorDetails,
+	msg     string,
+) *NotFoundError {
+	return &NotFoundError{
+		ErrorCode: ErrorCode{ code: code, details: details },
+		message:   msg,
+	}
+}
+
+/** The credentials are missing, malformed or expired */
+type UnauthorizedError struct {
+	ErrorCode
+	message string
+}
+
+func (e *UnauthorizedError) Error() string {
+	return e.message
+}
+
+func NewUnauthorizedError(msg string) *UnauthorizedError {
+	return &UnauthorizedError{ message: msg }
+}
+
+/* UnauthorizedError with a code, see ErrorCode */
+func NewCodedUnauthorizedError(
+	code    string,
+	details ErrorDetails,
+	msg     string,
+) *UnauthorizedError {
+	return &UnauthorizedError{
+		ErrorCode: ErrorCode{ code: code, details: details },
+		message:   msg,
+	}
+}
+
+/** The credentials are valid, but do not grant access to the VDS */
+type ForbiddenError struct {
+	ErrorCode
+	message string
+}
+
+func (e *ForbiddenError) Error() string {
+	return e.message
+}
+
+func NewForbiddenError(msg string) *ForbiddenError {
+	return &ForbiddenError{ message: msg }
+}
+
+/* ForbiddenError with a code, see ErrorCode */
+func NewCodedForbiddenError(
+	code    string,
+	details ErrorDetails,
+	msg     string,
+) *ForbiddenError {
+	return &ForbiddenError{
+		ErrorCode: ErrorCode{ code: code, details: details },
+		message:   msg,
+	}
+}
+
+/** The request body is larger than the server accepts */
+type PayloadTooLargeError struct {
+	ErrorCode
+	message string
+}
+
+func (e *PayloadTooLargeError) Error() string {
+	return e.message
+}
+
+func NewPayloadTooLargeError(msg string) *PayloadTooLargeError {
+	return &PayloadTooLargeError{ message: msg }
+}
+
+/* PayloadTooLargeError with a code, see ErrorCode */
+func NewCodedPayloadTooLargeError(
+	code    string,
+	details ErrorDetails,
+	msg     string,
+) *PayloadTooLargeError {
+	return &PayloadTooLargeError{
+		ErrorCode: ErrorCode{ code: code, details: details },
+		message:   msg,
+	}
+}
+
+/** The storage backend is unavailable, try again after RetryAfter */
+type UnavailableError struct {
+	ErrorCode
+	message    string
+	retryAfter time.Duration
+}
+
+func (e *UnavailableError) Error() string {
+	return e.message
+}
+
+func (e *UnavailableError) RetryAfter() time.Duration {
+	return e.retryAfter
+}
+
+func NewUnavailableError(msg string, retryAfter time.Duration) *UnavailableError {
+	return &UnavailableError{ message: msg, retryAfter: retryAfter }
+}
+
+/** The deadline of the request passed before it completed
+ *
+ * The deadline is either given by the client, or is the request timeout of
+ * the server, which Client tells apart.
+ */
+type DeadlineExceededError struct {
+	ErrorCode
+	message string
+	client  bool
+}
+
+func (e *DeadlineExceededError) Error() string {
+	return e.message
+}
+
+func (e *DeadlineExceededError) Client() bool {
+	return e.client
+}
+
+func NewDeadlineExceededError(msg string, client bool) *DeadlineExceededError {
+	return &DeadlineExceededError{ message: msg, client: client }
+}
+
+/* DeadlineExceededError with a code, see ErrorCode */
+func NewCodedDeadlineExceededError(
+	code    string,
+	details ErrorDetails,
+	msg     string,
+	client  bool,
+) *DeadlineExceededError {
+	return &DeadlineExceededError{
+		ErrorCode: ErrorCode{ code: code, details: details },
+		message:   msg,
+		client:    client,
+	}
+}
+
+var (
+	statusUnauthorized = regexp.MustCompile(`\b401\b`)
+	statusForbidden    = regexp.MustCompile(`\b403\b`)
+	statusNotFound     = regexp.MustCompile(`\b404\b`)
+)
+
+func containsAny(msg string, substrings []string) bool {
+	for _, substring := range substrings {
+		if strings.Contains(msg, substring) {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+ * Name the backend and bucket in the error message, for connections that
+ * describe themselves. The 'Could not open VDS' prefix is kept intact.
+ */
+func describeOpenError(conn Connection, msg string) string {
+	described, ok := conn.(describedConnection)
+	if !ok {
+		return msg
+	}
+
+	prefix := "Could not open VDS"
+	return fmt.Sprintf("%s in %s%s",
+		prefix,
+		described.describe(),
+		strings.TrimPrefix(msg, prefix),
+	)
+}
+
+/** Classify a failure to open a VDS
+ *
+ * OpenVDS reports all failures to open a VDS in the same way, with the
+ * underlying reason given only as part of the error message. For Azure the
+ * message contains the HTTP status and description returned by Blob Store,
+ * e.g. "404 The specified blob does not exist". This function inspects the
+ * message in order to tell apart VDSs that do not exist, requests with
+ * missing or expired credentials, and credentials with insufficient access,
+ * from genuine internal errors.
+ *
+ * S3 reports the same in form of error codes, e.g. "NoSuchKey" and
+ * "AccessDenied", which are classified accordingly.
+ *
+ * Expired tokens are reported by Azure as 403 (AuthenticationFailed), but
+ * from the user's point of view they are no different from missing
+ * credentials, hence they are classified as unauthorized.
+ */
+func classifyOpenError(conn Connection, msg string) error {
+	lower := strings.ToLower(msg)
+
+	expired := []string{
+		"expired",
+		"not valid in the specified time frame",
+	}
+	anonymous := []string{
+		"public access is not permitted",
+		"invalidaccesskeyid",
+		"signaturedoesnotmatch",
+	}
+	denied := []string{
+		"accessdenied",
+	}
+	missing := []string{
+		"does not exist",
+		"not found",
+		"notfound",
+		"no such file",
+		"nosuchkey",
+		"nosuchbucket",
+	}
+
+	switch {
+	case containsAny(lower, expired):
+		return NewCodedUnauthorizedError("sas_expired", nil, msg)
+	case statusUnauthorized.MatchString(msg) || containsAny(lower, anonymous):
+		return NewUnauthorizedError(msg)
+	case statusForbidden.MatchString(msg) || containsAny(lower, denied):
+		return NewForbiddenError(msg)
+	case statusNotFound.MatchString(msg) || containsAny(lower, missing):
+		return NewNotFoundError(msg)
+	}
+
+	/*
+	 * OpenVDS gives no consistent message for local files that do not exist,
+	 * so check the filesystem directly.
+	 */
+	if strings.HasPrefix(conn.Url(), "file://") {
+		path := strings.TrimPrefix(conn.Url(), "file://")
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			return NewNotFoundError(msg)
+		}
+	}
+
+	return NewInternalError(msg)
+}
@@ -1,7 +1,10 @@
 #ifndef VDS_SLICE_EXCEPTIONS_H
 #define VDS_SLICE_EXCEPTIONS_H
 
+#include <map>
 #include <stdexcept>
+#include <string>
+#include <utility>
 
 namespace detail {
 
@@ -9,8 +12,38 @@ struct nullptr_error : public std::runtime_error {
     using std::runtime_error::runtime_error;
 };
 
+/*
+ * The code and details are handed back to clients as is, see ErrorCode in
+ * errors.go. Requests that fail without a code get the default one.
+ */
 struct bad_request : public std::runtime_error {
     using std::runtime_error::runtime_error;
+
+    bad_request(
+        std::string code,
+        std::map< std::string, std::string > details,
+        std::string const& msg
+    ) : std::runtime_error(msg),
+        code(std::move(code)),
+        details(std::move(details))
+    {}
+
+    std::string code;
+    std::map< std::string, std::string > details;
+};
+
+/*
+ * A request to storage that failed. The status is that reported by the
+ * IOManager of OpenVDS, which for the cloud backends is the HTTP status of
+ * the response, or 0 if unknown. Transient failures are told apart by it,
+ * see isTransient in retry.go.
+ */
+struct storage_error : public std::runtime_error {
+    storage_error(int status, std::string const& msg)
+        : std::runtime_error(msg), status(status)
+    {}
+
+    int status;
 };
 
 } // namespace detail
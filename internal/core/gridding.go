@@ -0,0 +1,312 @@
+package core
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// @Description Surface given as scattered (x, y, z) points, e.g. a horizon
+// @Description exported as XYZ, that the server grids onto a regular grid
+type PointSurface struct {
+	// The points of the surface as (x, y, z) triplets, where x and y are
+	// world (cdp) coordinates and z is the depth or time, like the values of
+	// a regular surface. Every row of an XYZ or CSV export is one point.
+	// Points with z == fillValue are ignored.
+	Points [][]float32 `json:"points" binding:"required"`
+
+	// Rotation of the X-axis (East) of the grid, counter-clockwise, in degrees
+	Rotation *float32 `json:"rotation" binding:"required" example:"33.78"`
+
+	// X-coordinate of the origin of the grid
+	Xori *float32 `json:"xori" binding:"required" example:"-324.1"`
+
+	// Y-coordinate of the origin of the grid
+	Yori *float32 `json:"yori" binding:"required" example:"6721.33"`
+
+	// X-increment - The physical distance between the columns of the grid
+	Xinc float32 `json:"xinc" binding:"required" example:"8.12"`
+
+	// Y-increment - The physical distance between the rows of the grid
+	Yinc float32 `json:"yinc" binding:"required" example:"-1.02"`
+
+	// Number of rows of the grid
+	Nrows int `json:"nrows" binding:"required" example:"100"`
+
+	// Number of columns of the grid
+	Ncols int `json:"ncols" binding:"required" example:"200"`
+
+	// Fill value of the gridded surface, see RegularSurface.FillValue. Nodes
+	// that have no points within searchRadius get the fill value.
+	FillValue *float32 `json:"fillValue" binding:"required" example:"-999.25"`
+
+	// How the points are gridded, one of:
+	// nearest         : The value of the nearest point
+	// inversedistance : The mean of the points, weighted by their inverse
+	//                   distance to the node raised to power
+	//
+	// Only points within searchRadius of the node are considered. Defaults to
+	// nearest.
+	Gridding string `json:"gridding,omitempty" example:"inversedistance"`
+
+	// Max distance from a node to the points that make up its value, in
+	// world units
+	SearchRadius float32 `json:"searchRadius" binding:"required" example:"25"`
+
+	// Power of the inverse distance weights. Only applies to inversedistance
+	// gridding.
+	//
+	// Defaults to 2
+	Power *float32 `json:"power,omitempty" example:"2"`
+} // @name PointSurface
+
+const (
+	GriddingNearest = iota
+	GriddingInverseDistance
+)
+
+var griddingOptions = []option{
+	{"nearest", GriddingNearest},
+	{"inversedistance", GriddingInverseDistance},
+}
+
+// Valid options for PointSurface.Gridding
+func GriddingMethods() []string {
+	return optionNames(griddingOptions)
+}
+
+func getGriddingMethod(gridding string) (int, error) {
+	if gridding == "" {
+		return GriddingNearest, nil
+	}
+
+	method, ok := lookupOption(griddingOptions, strings.ToLower(gridding))
+	if !ok {
+		valid := enumerate(optionNames(griddingOptions))
+		msg := "invalid gridding method '%s', valid options are: %s"
+		return -1, NewInvalidArgument(fmt.Sprintf(msg, gridding, valid))
+	}
+	return method, nil
+}
+
+/* Number of nodes of the grid the points are gridded onto */
+func (surface *PointSurface) Nodes() int {
+	return surface.Nrows * surface.Ncols
+}
+
+/*
+ * Most nodes visited by the gridding, summed over all points, such that a
+ * search radius of many increments can't turn a modest request into hours of
+ * gridding
+ */
+const maxGriddingVisits = 100000000
+
+/* Nodes within searchRadius of a point, along the rows and the columns */
+func (surface *PointSurface) searchWindow() (rows float64, cols float64) {
+	/* The rows are spaced by xinc and the columns by yinc, see toCdp */
+	rowRadius := float64(surface.SearchRadius) / math.Abs(float64(surface.Xinc))
+	colRadius := float64(surface.SearchRadius) / math.Abs(float64(surface.Yinc))
+
+	rows = math.Min(2*math.Floor(rowRadius)+1, float64(surface.Nrows))
+	cols = math.Min(2*math.Floor(colRadius)+1, float64(surface.Ncols))
+	return rows, cols
+}
+
+/** Check that the points and the grid make up a surface that can be gridded
+ *
+ * Like RegularSurface.Validate, every point must be finite, except for z
+ * values that match the fill value.
+ */
+func (surface *PointSurface) Validate() error {
+	if surface.Nrows < 1 || surface.Ncols < 1 {
+		return NewInvalidArgument(fmt.Sprintf(
+			"Point surface grid must have at least one row and column, was "+
+				"nrows: %d, ncols: %d",
+			surface.Nrows,
+			surface.Ncols,
+		))
+	}
+
+	if !isFinite(surface.Xinc) || surface.Xinc == 0 ||
+		!isFinite(surface.Yinc) || surface.Yinc == 0 {
+		return NewInvalidArgument(fmt.Sprintf(
+			"Point surface grid increments must be finite and non-zero, was "+
+				"xinc: %v, yinc: %v",
+			surface.Xinc,
+			surface.Yinc,
+		))
+	}
+
+	if !isFinite(surface.SearchRadius) || surface.SearchRadius <= 0 {
+		return NewInvalidArgument(fmt.Sprintf(
+			"searchRadius must be finite and positive, was %v",
+			surface.SearchRadius,
+		))
+	}
+
+	if surface.Power != nil && (!isFinite(*surface.Power) || *surface.Power <= 0) {
+		return NewInvalidArgument(fmt.Sprintf(
+			"power must be finite and positive, was %v",
+			*surface.Power,
+		))
+	}
+
+	if _, err := getGriddingMethod(surface.Gridding); err != nil {
+		return err
+	}
+
+	rows, cols := surface.searchWindow()
+	if rows*cols*float64(len(surface.Points)) > maxGriddingVisits {
+		return NewInvalidArgument(fmt.Sprintf(
+			"searchRadius %v spans %v x %v nodes, which is too many for %d "+
+				"points. Use a smaller searchRadius or fewer points",
+			surface.SearchRadius,
+			rows,
+			cols,
+			len(surface.Points),
+		))
+	}
+
+	if len(surface.Points) == 0 {
+		return NewInvalidArgument("Point surface has no points")
+	}
+
+	for i, point := range surface.Points {
+		if len(point) != 3 {
+			return NewInvalidArgument(fmt.Sprintf(
+				"invalid point %v at position %d, expected (x, y, z)",
+				point,
+				i,
+			))
+		}
+		if !isFinite(point[0]) || !isFinite(point[1]) ||
+			!(isFinite(point[2]) || isFillValue(point[2], surface.FillValue)) {
+			return NewInvalidArgument(fmt.Sprintf(
+				"invalid point %v at position %d, expected finite values",
+				point,
+				i,
+			))
+		}
+	}
+	return nil
+}
+
+/* What is known about the value of a node, as the points are visited */
+type griddedNode struct {
+	/* Distance to, and value of, the nearest point so far */
+	nearest float64
+	value   float64
+	/* Sums of the inverse distance weights, and of the weighted values */
+	weights  float64
+	weighted float64
+	/* Sum and number of the points exactly at the node */
+	exact  float64
+	nexact int
+}
+
+/** Grid the points onto a regular surface
+ *
+ * Every point contributes to the nodes within searchRadius of it, and the
+ * value of a node is computed by the gridding method from the points that
+ * contribute to it. Points exactly at a node, i.e. at distance zero, decide
+ * the value of it alone, as their inverse distance weight is infinite. Nodes
+ * that no point contributes to get the fill value.
+ *
+ * The surface must be valid, see Validate.
+ */
+func (surface *PointSurface) Grid() (RegularSurface, error) {
+	method, err := getGriddingMethod(surface.Gridding)
+	if err != nil {
+		return RegularSurface{}, err
+	}
+
+	power := 2.0
+	if surface.Power != nil {
+		power = float64(*surface.Power)
+	}
+
+	gridded := RegularSurface{
+		Values:    make([][]float32, surface.Nrows),
+		Rotation:  surface.Rotation,
+		Xori:      surface.Xori,
+		Yori:      surface.Yori,
+		Xinc:      surface.Xinc,
+		Yinc:      surface.Yinc,
+		FillValue: surface.FillValue,
+	}
+
+	nodes := make([]griddedNode, surface.Nodes())
+	for i := range nodes {
+		nodes[i].nearest = math.Inf(1)
+	}
+
+	radius := float64(surface.SearchRadius)
+	rowRadius := radius / math.Abs(float64(surface.Xinc))
+	colRadius := radius / math.Abs(float64(surface.Yinc))
+	lastRow := float64(surface.Nrows - 1)
+	lastCol := float64(surface.Ncols - 1)
+
+	for _, point := range surface.Points {
+		if isFillValue(point[2], surface.FillValue) {
+			continue
+		}
+		x := float64(point[0])
+		y := float64(point[1])
+		z := float64(point[2])
+
+		row, col := gridded.fromCdp(x, y)
+		/* Also keeps points far off the grid from overflowing the indices */
+		if row+rowRadius < 0 || row-rowRadius > lastRow ||
+			col+colRadius < 0 || col-colRadius > lastCol {
+			continue
+		}
+		fromRow := int(math.Max(0, math.Ceil(row-rowRadius)))
+		toRow := int(math.Min(lastRow, math.Floor(row+rowRadius)))
+		fromCol := int(math.Max(0, math.Ceil(col-colRadius)))
+		toCol := int(math.Min(lastCol, math.Floor(col+colRadius)))
+
+		for r := fromRow; r <= toRow; r++ {
+			for c := fromCol; c <= toCol; c++ {
+				nodeX, nodeY := gridded.toCdp(r, c)
+				distance := math.Hypot(x-nodeX, y-nodeY)
+				if distance > radius {
+					continue
+				}
+
+				node := &nodes[r*surface.Ncols+c]
+				if distance == 0 {
+					node.exact += z
+					node.nexact++
+				}
+				if distance < node.nearest {
+					node.nearest = distance
+					node.value = z
+				}
+				if distance > 0 {
+					weight := math.Pow(distance, -power)
+					node.weights += weight
+					node.weighted += weight * z
+				}
+			}
+		}
+	}
+
+	for r := range gridded.Values {
+		row := make([]float32, surface.Ncols)
+		for c := range row {
+			node := nodes[r*surface.Ncols+c]
+			switch {
+			case math.IsInf(node.nearest, 1):
+				row[c] = *surface.FillValue
+			case method == GriddingNearest:
+				row[c] = float32(node.value)
+			case node.nexact > 0:
+				row[c] = float32(node.exact / float64(node.nexact))
+			default:
+				row[c] = float32(node.weighted / node.weights)
+			}
+		}
+		gridded.Values[r] = row
+	}
+	return gridded, nil
+}
@@ -0,0 +1,271 @@
+package core
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func float32Ptr(value float32) *float32 {
+	return &value
+}
+
+/* A rotated reference surface, gridded onto by the tests */
+func griddingReference() RegularSurface {
+	return RegularSurface{
+		Values: [][]float32{
+			{10, 11, 12, 13},
+			{20, 21, 22, 23},
+			{30, 31, 32, 33},
+		},
+		Rotation:  float32Ptr(30),
+		Xori:      float32Ptr(100),
+		Yori:      float32Ptr(200),
+		Xinc:      4,
+		Yinc:      2,
+		FillValue: float32Ptr(-999.25),
+	}
+}
+
+/* The grid of the reference, without points */
+func griddingPointSurface(gridding string, radius float32) PointSurface {
+	reference := griddingReference()
+	return PointSurface{
+		Rotation:     reference.Rotation,
+		Xori:         reference.Xori,
+		Yori:         reference.Yori,
+		Xinc:         reference.Xinc,
+		Yinc:         reference.Yinc,
+		Nrows:        len(reference.Values),
+		Ncols:        len(reference.Values[0]),
+		FillValue:    reference.FillValue,
+		Gridding:     gridding,
+		SearchRadius: radius,
+	}
+}
+
+/* A point at the node of the reference, offset by dx, dy */
+func griddingPoint(row int, col int, dx float64, dy float64) []float32 {
+	reference := griddingReference()
+	x, y := reference.toCdp(row, col)
+	return []float32{
+		float32(x + dx),
+		float32(y + dy),
+		reference.Values[row][col],
+	}
+}
+
+func gridPoints(t *testing.T, surface PointSurface) RegularSurface {
+	require.NoError(t, surface.Validate())
+	gridded, err := surface.Grid()
+	require.NoError(t, err)
+	return gridded
+}
+
+func TestGridPointsAtNodes(t *testing.T) {
+	reference := griddingReference()
+
+	for _, gridding := range []string{"", "nearest", "InverseDistance"} {
+		surface := griddingPointSurface(gridding, 1)
+		for row := range reference.Values {
+			for col := range reference.Values[row] {
+				surface.Points = append(surface.Points, griddingPoint(row, col, 0, 0))
+			}
+		}
+
+		gridded := gridPoints(t, surface)
+		require.Equal(t, *reference.Rotation, *gridded.Rotation)
+		require.Equal(t, *reference.Xori, *gridded.Xori)
+		require.Equal(t, *reference.Yori, *gridded.Yori)
+		require.Equal(t, reference.Xinc, gridded.Xinc)
+		require.Equal(t, reference.Yinc, gridded.Yinc)
+		require.Equal(t, *reference.FillValue, *gridded.FillValue)
+		require.Len(t, gridded.Values, len(reference.Values))
+		for row := range reference.Values {
+			require.InDeltaSlicef(t,
+				reference.Values[row],
+				gridded.Values[row],
+				1e-4,
+				"[%s] Expected points at the nodes to reproduce the reference",
+				gridding,
+			)
+		}
+	}
+}
+
+func TestGridPointsNearest(t *testing.T) {
+	reference := griddingReference()
+
+	surface := griddingPointSurface("nearest", 1.5)
+	for row := range reference.Values {
+		for col := range reference.Values[row] {
+			/* A decoy further away from the node, of another value */
+			decoy := griddingPoint(row, col, -1.2, 0)
+			decoy[2] = 0
+			surface.Points = append(surface.Points,
+				decoy,
+				griddingPoint(row, col, 0.5, 0.5),
+			)
+		}
+	}
+
+	gridded := gridPoints(t, surface)
+	for row := range reference.Values {
+		require.InDeltaSlice(t, reference.Values[row], gridded.Values[row], 1e-4)
+	}
+}
+
+func TestGridPointsInverseDistance(t *testing.T) {
+	reference := griddingReference()
+	fill := *reference.FillValue
+
+	surface := griddingPointSurface("inversedistance", 1)
+	/* Twice as far from the node as the other point, i.e. a 1/4 weight */
+	far := griddingPoint(0, 0, 0.8, 0)
+	far[2] = 5
+	surface.Points = [][]float32{griddingPoint(0, 0, 0, 0.4), far}
+
+	gridded := gridPoints(t, surface)
+	expected := (reference.Values[0][0]*1 + 5*0.25) / 1.25
+	require.InDelta(t, expected, gridded.Values[0][0], 1e-3)
+
+	surface.Power = float32Ptr(1)
+	gridded = gridPoints(t, surface)
+	expected = (reference.Values[0][0]*1 + 5*0.5) / 1.5
+	require.InDelta(t, expected, gridded.Values[0][0], 1e-3)
+
+	for row := range gridded.Values {
+		for col := range gridded.Values[row] {
+			if row == 0 && col == 0 {
+				continue
+			}
+			require.Equalf(t, fill, gridded.Values[row][col],
+				"Expected node (%d, %d), without points within the radius, "+
+					"to be the fill value", row, col)
+		}
+	}
+}
+
+func TestGridPointsIgnoresFillValues(t *testing.T) {
+	reference := griddingReference()
+
+	surface := griddingPointSurface("nearest", 1)
+	missing := griddingPoint(1, 1, 0, 0)
+	missing[2] = *reference.FillValue
+	surface.Points = [][]float32{missing, griddingPoint(1, 1, 0.5, 0)}
+
+	gridded := gridPoints(t, surface)
+	require.Equal(t, reference.Values[1][1], gridded.Values[1][1],
+		"Expected points of the fill value to be ignored")
+}
+
+func TestGridPointsNaNFillValue(t *testing.T) {
+	surface := griddingPointSurface("nearest", 1)
+	surface.FillValue = float32Ptr(float32(math.NaN()))
+	missing := griddingPoint(0, 0, 0, 0)
+	missing[2] = float32(math.NaN())
+	surface.Points = [][]float32{missing}
+
+	gridded := gridPoints(t, surface)
+	for _, row := range gridded.Values {
+		for _, value := range row {
+			require.True(t, math.IsNaN(float64(value)))
+		}
+	}
+}
+
+func TestGridPointsFarOffTheGrid(t *testing.T) {
+	surface := griddingPointSurface("nearest", 1)
+	surface.Points = [][]float32{{3e38, -3e38, 1}, griddingPoint(2, 3, 0, 0)}
+
+	gridded := gridPoints(t, surface)
+	require.Equal(t, float32(33), gridded.Values[2][3])
+}
+
+func TestPointSurfaceValidation(t *testing.T) {
+	point := griddingPoint(0, 0, 0, 0)
+
+	testCases := []struct {
+		name     string
+		modify   func(surface *PointSurface)
+		expected string
+	}{
+		{
+			name:     "No points",
+			modify:   func(surface *PointSurface) { surface.Points = nil },
+			expected: "Point surface has no points",
+		},
+		{
+			name: "Not a triplet",
+			modify: func(surface *PointSurface) {
+				surface.Points = [][]float32{point, {1, 2}}
+			},
+			expected: "invalid point [1 2] at position 1, expected (x, y, z)",
+		},
+		{
+			name: "Infinite coordinate",
+			modify: func(surface *PointSurface) {
+				surface.Points = [][]float32{{float32(math.Inf(1)), 2, 3}}
+			},
+			expected: "invalid point [+Inf 2 3] at position 0, expected finite values",
+		},
+		{
+			name: "NaN value",
+			modify: func(surface *PointSurface) {
+				surface.Points = [][]float32{{1, 2, float32(math.NaN())}}
+			},
+			expected: "invalid point [1 2 NaN] at position 0, expected finite values",
+		},
+		{
+			name:     "No rows",
+			modify:   func(surface *PointSurface) { surface.Nrows = 0 },
+			expected: "Point surface grid must have at least one row and column",
+		},
+		{
+			name:     "Zero increment",
+			modify:   func(surface *PointSurface) { surface.Yinc = 0 },
+			expected: "Point surface grid increments must be finite and non-zero",
+		},
+		{
+			name:     "Negative radius",
+			modify:   func(surface *PointSurface) { surface.SearchRadius = -1 },
+			expected: "searchRadius must be finite and positive, was -1",
+		},
+		{
+			name:     "Zero power",
+			modify:   func(surface *PointSurface) { surface.Power = float32Ptr(0) },
+			expected: "power must be finite and positive, was 0",
+		},
+		{
+			name:   "Unknown gridding",
+			modify: func(surface *PointSurface) { surface.Gridding = "kriging" },
+			expected: "invalid gridding method 'kriging', valid options are: " +
+				"nearest or inversedistance",
+		},
+		{
+			name: "Search radius too large",
+			modify: func(surface *PointSurface) {
+				surface.Nrows = 10000
+				surface.Ncols = 10000
+				surface.SearchRadius = 1000
+				surface.Points = make([][]float32, 1000)
+				for i := range surface.Points {
+					surface.Points[i] = point
+				}
+			},
+			expected: "searchRadius 1000 spans 501 x 1001 nodes, which is too " +
+				"many for 1000 points",
+		},
+	}
+
+	for _, testCase := range testCases {
+		surface := griddingPointSurface("", 1)
+		surface.Points = [][]float32{point}
+		testCase.modify(&surface)
+
+		err := surface.Validate()
+		require.ErrorContainsf(t, err, testCase.expected, "[%s]", testCase.name)
+		require.IsTypef(t, &InvalidArgument{}, err, "[%s]", testCase.name)
+	}
+}
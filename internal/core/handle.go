@@ -0,0 +1,169 @@
+package core
+
+/** Everything the endpoints read from a cube
+ *
+ * VdsHandle implements it for VDSs opened by OpenVDS. Other implementations,
+ * like the in-memory cubes of the vdstest package, must give the same
+ * data and metadata for the same requests, and fail with the same error
+ * types, e.g. InvalidArgument for requests that do not fit the cube.
+ */
+type DSHandle interface {
+	/** The handle, computing attributes with the workers of pool */
+	WithWorkerPool(pool *WorkerPool) DSHandle
+	Close() error
+	/** Statistics for all reads done through the handle so far */
+	Stats() (RequestStats, error)
+
+	GetMetadata(includeImportInfo bool, includeLayout bool) ([]byte, error)
+	Axes() ([]*Axis, error)
+	VerticalUnitConversion(requested string) (VerticalUnitConversion, error)
+
+	GetSlice(
+		lineno int,
+		direction int,
+		linenoSystem int,
+		bounds []Bound,
+		fillValue *float32,
+	) ([]byte, error)
+	GetSliceMetadata(
+		lineno int,
+		direction int,
+		linenoSystem int,
+		bounds []Bound,
+		fillValue *float32,
+	) ([]byte, error)
+	GetSliceWithMetadata(
+		lineno int,
+		direction int,
+		linenoSystem int,
+		bounds []Bound,
+		fillValue *float32,
+	) (data []byte, metadata []byte, err error)
+	GetStoredSliceMetadata(
+		lineno int,
+		direction int,
+		linenoSystem int,
+		bounds []Bound,
+	) ([]byte, error)
+	GetStoredSliceWithMetadata(
+		lineno int,
+		direction int,
+		linenoSystem int,
+		bounds []Bound,
+	) (data []byte, metadata []byte, err error)
+	/** Every stride'th row of a slice, starting at row first */
+	GetSliceRows(
+		lineno int,
+		direction int,
+		linenoSystem int,
+		bounds []Bound,
+		fillValue *float32,
+		first int,
+		stride int,
+	) ([]byte, error)
+	GetStoredSliceRows(
+		lineno int,
+		direction int,
+		linenoSystem int,
+		bounds []Bound,
+		first int,
+		stride int,
+	) ([]byte, error)
+
+	GetFence(
+		coordinateSystem int,
+		coordinates [][]float32,
+		interpolation int,
+		fillValue *float32,
+	) ([]byte, error)
+	GetFenceMetadata(
+		coordinateSystem int,
+		coordinates [][]float32,
+		interpolation int,
+		fillValue *float32,
+	) ([]byte, error)
+	GetFenceWithMetadata(
+		coordinateSystem int,
+		coordinates [][]float32,
+		interpolation int,
+		fillValue *float32,
+	) (data []byte, metadata []byte, err error)
+	GetFenceHeaders(
+		coordinateSystem int,
+		coordinates [][]float32,
+		fields []string,
+		fillValue *float32,
+	) ([]byte, error)
+	ResampledTraceLength(
+		interval float32,
+		conversion VerticalUnitConversion,
+	) (int, error)
+	ResampleFence(
+		data []byte,
+		metadata []byte,
+		interval float32,
+		interpolation int,
+		conversion VerticalUnitConversion,
+	) ([]byte, []byte, error)
+
+	GetSamplesWithMetadata(
+		coordinateSystem int,
+		points [][]float32,
+		interpolation int,
+		fillValue *float32,
+		conversion VerticalUnitConversion,
+	) (data [][]byte, metadata []byte, err error)
+
+	GetAttributesAlongSurface(
+		referenceSurface RegularSurface,
+		above float32,
+		below float32,
+		stepsize float32,
+		attributes []string,
+		interpolation int,
+		verticalInterpolation int,
+	) ([][]byte, error)
+	GetAttributesAlongSurfaceWithMetadata(
+		referenceSurface RegularSurface,
+		above float32,
+		below float32,
+		stepsize float32,
+		attributes []string,
+		interpolation int,
+		verticalInterpolation int,
+		polygon *Polygon,
+		band *SurfaceBand,
+		minValidFraction float32,
+	) (data [][]byte, metadata []byte, err error)
+	GetAttributesBetweenSurfaces(
+		primarySurface RegularSurface,
+		secondarySurface RegularSurface,
+		stepsize float32,
+		attributes []string,
+		interpolation int,
+		verticalInterpolation int,
+	) ([][]byte, error)
+	GetAttributesBetweenSurfacesWithMetadata(
+		primarySurface RegularSurface,
+		secondarySurface RegularSurface,
+		stepsize float32,
+		attributes []string,
+		interpolation int,
+		verticalInterpolation int,
+		minValidFraction float32,
+	) (data [][]byte, metadata []byte, err error)
+}
+
+var _ DSHandle = VdsHandle{}
+
+/** Opens the cube a connection points to, e.g. OpenDSHandle */
+type HandleOpener func(conn Connection) (DSHandle, error)
+
+/** Open the cube with OpenVDS, NewDSHandle as a HandleOpener */
+func OpenDSHandle(conn Connection) (DSHandle, error) {
+	handle, err := NewDSHandle(conn)
+	if err != nil {
+		return nil, err
+	}
+	return handle, nil
+}
@@ -1,5 +1,6 @@
 #include "metadatahandle.hpp"
 
+#include <cmath>
 #include <stdexcept>
 #include <list>
 #include <utility>
@@ -9,13 +10,157 @@
 #include "axis.hpp"
 #include "boundingbox.hpp"
 #include "direction.hpp"
+#include "exceptions.hpp"
+
+namespace {
+
+/*
+ * EBCDIC (code page 037) to ASCII, for the characters that have an ASCII
+ * equivalent. Anything else is given as a space.
+ */
+char ebcdic_to_ascii(unsigned char c) {
+    if (c >= 0x81 and c <= 0x89) return 'a' + (c - 0x81);
+    if (c >= 0x91 and c <= 0x99) return 'j' + (c - 0x91);
+    if (c >= 0xA2 and c <= 0xA9) return 's' + (c - 0xA2);
+    if (c >= 0xC1 and c <= 0xC9) return 'A' + (c - 0xC1);
+    if (c >= 0xD1 and c <= 0xD9) return 'J' + (c - 0xD1);
+    if (c >= 0xE2 and c <= 0xE9) return 'S' + (c - 0xE2);
+    if (c >= 0xF0 and c <= 0xF9) return '0' + (c - 0xF0);
+
+    switch (c) {
+        case 0x4B: return '.';
+        case 0x4C: return '<';
+        case 0x4D: return '(';
+        case 0x4E: return '+';
+        case 0x4F: return '|';
+        case 0x50: return '&';
+        case 0x5A: return '!';
+        case 0x5B: return '$';
+        case 0x5C: return '*';
+        case 0x5D: return ')';
+        case 0x5E: return ';';
+        case 0x60: return '-';
+        case 0x61: return '/';
+        case 0x6B: return ',';
+        case 0x6C: return '%';
+        case 0x6D: return '_';
+        case 0x6E: return '>';
+        case 0x6F: return '?';
+        case 0x79: return '`';
+        case 0x7A: return ':';
+        case 0x7B: return '#';
+        case 0x7C: return '@';
+        case 0x7D: return '\'';
+        case 0x7E: return '=';
+        case 0x7F: return '"';
+        case 0xA1: return '~';
+        case 0xB0: return '^';
+        case 0xBA: return '[';
+        case 0xBB: return ']';
+        case 0xC0: return '{';
+        case 0xD0: return '}';
+        case 0xE0: return '\\';
+        default:   return ' ';
+    }
+}
+
+/*
+ * The textual header of SEG-Y is EBCDIC by the standard, but ASCII is common
+ * in practice. ASCII is 7-bit, so any byte with the high bit set means EBCDIC.
+ * Non-printable characters are given as spaces, so the header is always valid
+ * as a json string.
+ */
+std::string decode_text_header(unsigned char const* data, std::size_t size) {
+    bool ebcdic = false;
+    for (std::size_t i = 0; i < size; ++i) {
+        if (data[i] & 0x80) {
+            ebcdic = true;
+            break;
+        }
+    }
+
+    std::string header(size, ' ');
+    for (std::size_t i = 0; i < size; ++i) {
+        char c = ebcdic ? ebcdic_to_ascii(data[i]) : char(data[i]);
+        if (c >= 0x20 and c < 0x7F) header[i] = c;
+    }
+    return header;
+}
+
+/*
+ * Checked before the axes are looked up, as a VDS of any other
+ * dimensionality would fail on missing axes, which is less to the point.
+ */
+OpenVDS::VolumeDataLayout const* validate_dimensionality(
+    OpenVDS::VolumeDataLayout const* const layout
+) {
+    int const dimensionality = layout->GetDimensionality();
+    if (dimensionality != 2 and dimensionality != 3) {
+        throw std::runtime_error(
+            "Unsupported VDS, expected 2 or 3 dimensions, got " +
+            std::to_string(dimensionality)
+        );
+    }
+    return layout;
+}
+
+/*
+ * 2D lines store the world coordinates of their traces as a blob of (x, y)
+ * pairs of doubles. Lines without them, or with a different number of them
+ * than there are traces, get none, and can't be read by world coordinates.
+ */
+std::vector< std::array< double, 2 > > read_trace_coordinates(
+    OpenVDS::VolumeDataLayout const* const layout,
+    int const ntraces
+) {
+    auto const key = OpenVDS::KnownMetadata::TraceCoordinates();
+    if (not layout->IsMetadataBLOBAvailable(key.GetCategory(), key.GetName()))
+        return {};
+
+    void const* data = nullptr;
+    std::size_t size = 0;
+    layout->GetMetadataBLOB(key.GetCategory(), key.GetName(), &data, &size);
+    if (size != ntraces * 2 * sizeof(double))
+        return {};
+
+    auto const* xy = static_cast< double const* >(data);
+    std::vector< std::array< double, 2 > > coordinates(ntraces);
+    for (int i = 0; i < ntraces; ++i) {
+        coordinates[i] = { xy[2 * i], xy[2 * i + 1] };
+    }
+    return coordinates;
+}
+
+} // namespace
 
 SingleMetadataHandle::SingleMetadataHandle(OpenVDS::VolumeDataLayout const* const layout)
-    : m_layout(layout),
-      m_iline(Axis(layout, get_dimension({std::string(OpenVDS::KnownAxisNames::Inline())}))),
-      m_xline(Axis(layout, get_dimension({std::string(OpenVDS::KnownAxisNames::Crossline())}))),
+    : m_layout(::validate_dimensionality(layout)),
+      m_iline(this->make_iline()),
+      m_xline(Axis(layout, get_dimension({std::string(OpenVDS::KnownAxisNames::Crossline()), "CDP", "Trace"}))),
       m_sample(Axis(layout, get_dimension({std::string(OpenVDS::KnownAxisNames::Sample()), std::string(OpenVDS::KnownAxisNames::Depth()), std::string(OpenVDS::KnownAxisNames::Time())}))) {
-    this->dimension_validation();
+    if (this->is_2d()) {
+        this->m_trace_coordinates = ::read_trace_coordinates(
+            layout,
+            this->m_xline.nsamples()
+        );
+    }
+}
+
+/*
+ * The inline of a 2D line is put in the dimension the layout does not have,
+ * which OpenVDS reads as a dimension of a single sample.
+ */
+Axis SingleMetadataHandle::make_iline() const {
+    if (this->is_2d()) {
+        return Axis(2, OpenVDS::VolumeDataAxisDescriptor(
+            1,
+            OpenVDS::KnownAxisNames::Inline(),
+            "",
+            0,
+            0
+        ));
+    }
+    return Axis(this->m_layout, get_dimension({std::string(OpenVDS::KnownAxisNames::Inline())}));
 }
 
 Axis SingleMetadataHandle::iline() const noexcept(true) {
@@ -43,7 +188,21 @@ Axis SingleMetadataHandle::get_axis(
     throw std::runtime_error("Unhandled axis");
 }
 
+bool SingleMetadataHandle::is_2d() const noexcept(true) {
+    return this->m_layout->GetDimensionality() == 2;
+}
+
+std::vector< std::array< double, 2 > > const&
+SingleMetadataHandle::trace_coordinates() const noexcept(true) {
+    return this->m_trace_coordinates;
+}
+
+/* The bounding box of a 2D line is given by its traces, see cppapi::metadata */
 BoundingBox SingleMetadataHandle::bounding_box() const noexcept(false) {
+    if (this->is_2d()) {
+        throw detail::bad_request("2D lines have no bounding box grid");
+    }
+
     return BoundingBox(
         this->iline().nsamples(),
         this->xline().nsamples(),
@@ -63,20 +222,36 @@ std::string SingleMetadataHandle::input_filename() const noexcept(false) {
 
 std::string SingleMetadataHandle::import_time_stamp() const noexcept(false) {
     auto const time_stamp = OpenVDS::KnownMetadata::ImportInformationImportTimeStamp();
+    if (not this->m_layout->IsMetadataStringAvailable(time_stamp.GetCategory(), time_stamp.GetName()))
+        return "";
     return this->m_layout->GetMetadataString(time_stamp.GetCategory(), time_stamp.GetName());
 }
 
+/*
+ * The textual header of the SEG-Y the VDS was imported from, decoded to
+ * ASCII. Empty if the VDS does not have one.
+ */
+std::string SingleMetadataHandle::segy_text_header() const noexcept(false) {
+    auto const text_header = OpenVDS::KnownMetadata::SEGYTextHeader();
+    if (not this->m_layout->IsMetadataBLOBAvailable(text_header.GetCategory(), text_header.GetName()))
+        return "";
+
+    void const* data = nullptr;
+    std::size_t size = 0;
+    this->m_layout->GetMetadataBLOB(text_header.GetCategory(), text_header.GetName(), &data, &size);
+    return decode_text_header(static_cast< unsigned char const* >(data), size);
+}
+
+OpenVDS::VolumeDataLayout const& SingleMetadataHandle::layout() const noexcept(false) {
+    return *this->m_layout;
+}
+
 OpenVDS::IJKCoordinateTransformer SingleMetadataHandle::coordinate_transformer() const noexcept(false) {
     return OpenVDS::IJKCoordinateTransformer(this->m_layout);
 }
 
 void SingleMetadataHandle::dimension_validation() const {
-    if (this->m_layout->GetDimensionality() != 3) {
-        throw std::runtime_error(
-            "Unsupported VDS, expected 3 dimensions, got " +
-            std::to_string(this->m_layout->GetDimensionality())
-        );
-    }
+    ::validate_dimensionality(this->m_layout);
 }
 
 int SingleMetadataHandle::get_dimension(std::vector<std::string> const& names) const {
@@ -129,6 +304,15 @@ Axis DoubleMetadataHandle::get_axis(
     throw std::runtime_error("Unhandled axis");
 }
 
+bool DoubleMetadataHandle::is_2d() const noexcept(true) {
+    return this->m_handle_A->is_2d();
+}
+
+std::vector< std::array< double, 2 > > const&
+DoubleMetadataHandle::trace_coordinates() const noexcept(true) {
+    return this->m_handle_A->trace_coordinates();
+}
+
 BoundingBox DoubleMetadataHandle::bounding_box() const noexcept(false) {
     throw std::runtime_error("Not implemented");
 }
@@ -145,6 +329,14 @@ std::string DoubleMetadataHandle::import_time_stamp() const noexcept(false) {
     throw std::runtime_error("Not implemented");
 }
 
+std::string DoubleMetadataHandle::segy_text_header() const noexcept(false) {
+    throw std::runtime_error("Not implemented");
+}
+
+OpenVDS::VolumeDataLayout const& DoubleMetadataHandle::layout() const noexcept(false) {
+    throw std::runtime_error("Not implemented");
+}
+
 OpenVDS::IJKCoordinateTransformer DoubleMetadataHandle::coordinate_transformer() const noexcept(false) {
     throw std::runtime_error("Not implemented");
 }
@@ -160,3 +352,52 @@ void DoubleMetadataHandle::validate_metadata() const noexcept(false) {
     this->m_handle_A->xline().assert_equal(this->m_handle_B->xline());
     this->m_handle_A->sample().assert_equal(this->m_handle_B->sample());
 }
+
+OpenVDS::DoubleVector3 world_to_annotation(
+    MetadataHandle const& metadata,
+    OpenVDS::IJKCoordinateTransformer const& transformer,
+    double const x,
+    double const y
+) noexcept(false) {
+    if (not metadata.is_2d()) {
+        return transformer.WorldToAnnotation({x, y, 0});
+    }
+
+    auto const& traces = metadata.trace_coordinates();
+    if (traces.empty()) {
+        throw detail::bad_request(
+            "The 2D line has no trace coordinates, and can't be read by "
+            "world (cdp) coordinates"
+        );
+    }
+
+    auto distance = [&](std::size_t trace) {
+        return std::hypot(traces[trace][0] - x, traces[trace][1] - y);
+    };
+
+    std::size_t nearest = 0;
+    for (std::size_t trace = 1; trace < traces.size(); ++trace) {
+        if (distance(trace) < distance(nearest)) nearest = trace;
+    }
+
+    Axis const xline = metadata.xline();
+    double annotation = xline.min();
+    if (nearest > 0) {
+        annotation += nearest * xline.stepsize();
+    }
+
+    if (traces.size() > 1) {
+        std::size_t const neighbour = nearest == 0 ? 1 : nearest - 1;
+        double const spacing = std::hypot(
+            traces[neighbour][0] - traces[nearest][0],
+            traces[neighbour][1] - traces[nearest][1]
+        );
+
+        /* One line length before the first trace is outside any line */
+        if (distance(nearest) > spacing) {
+            annotation = xline.min() - std::abs(xline.max() - xline.min()) - 1;
+        }
+    }
+
+    return {0, annotation, 0};
+}
@@ -1,9 +1,12 @@
 #ifndef VDS_SLICE_METADATAHANDLE_HPP
 #define VDS_SLICE_METADATAHANDLE_HPP
 
+#include <array>
 #include <string>
+#include <vector>
 
 #include <OpenVDS/OpenVDS.h>
+#include <OpenVDS/IJKCoordinateTransformer.h>
 
 #include "axis.hpp"
 #include "boundingbox.hpp"
@@ -17,10 +20,24 @@ public:
     virtual Axis sample() const noexcept(true) = 0;
     virtual Axis get_axis(Direction const direction) const noexcept(false) = 0;
 
+    /*
+     * 2D lines have no inline. They are given a single inline, numbered 0,
+     * such that they can be read like any other volume.
+     */
+    virtual bool is_2d() const noexcept(true) = 0;
+
+    /* World coordinates (x, y) of every trace of a 2D line, in order */
+    virtual std::vector< std::array< double, 2 > > const& trace_coordinates()
+        const noexcept(true) = 0;
+
     virtual BoundingBox bounding_box() const noexcept(false) = 0;
     virtual std::string crs() const noexcept(false) = 0;
     virtual std::string input_filename() const noexcept(false) = 0;
     virtual std::string import_time_stamp() const noexcept(false) = 0;
+    virtual std::string segy_text_header() const noexcept(false) = 0;
+
+    /* How the VDS is laid out in storage, i.e. its bricks and channels */
+    virtual OpenVDS::VolumeDataLayout const& layout() const noexcept(false) = 0;
 
     virtual OpenVDS::IJKCoordinateTransformer coordinate_transformer() const noexcept(false) = 0;
 protected:
@@ -36,10 +53,17 @@ public:
     Axis sample() const noexcept(true);
     Axis get_axis(Direction const direction) const noexcept(false);
 
+    bool is_2d() const noexcept(true);
+    std::vector< std::array< double, 2 > > const& trace_coordinates()
+        const noexcept(true);
+
     BoundingBox bounding_box() const noexcept(false);
     std::string crs() const noexcept(false);
     std::string input_filename() const noexcept(false);
     std::string import_time_stamp() const noexcept(false);
+    std::string segy_text_header() const noexcept(false);
+
+    OpenVDS::VolumeDataLayout const& layout() const noexcept(false);
 
     OpenVDS::IJKCoordinateTransformer coordinate_transformer() const noexcept(false);
 protected:
@@ -52,7 +76,10 @@ private:
     Axis m_xline;
     Axis m_sample;
 
+    std::vector< std::array< double, 2 > > m_trace_coordinates;
+
     int get_dimension(std::vector<std::string> const& names) const;
+    Axis make_iline() const;
 };
 
 class DoubleMetadataHandle : public MetadataHandle {
@@ -67,10 +94,17 @@ public:
     Axis sample() const noexcept(true);
     Axis get_axis(Direction const direction) const noexcept(false);
 
+    bool is_2d() const noexcept(true);
+    std::vector< std::array< double, 2 > > const& trace_coordinates()
+        const noexcept(true);
+
     BoundingBox bounding_box() const noexcept(false);
     std::string crs() const noexcept(false);
     std::string input_filename() const noexcept(false);
     std::string import_time_stamp() const noexcept(false);
+    std::string segy_text_header() const noexcept(false);
+
+    OpenVDS::VolumeDataLayout const& layout() const noexcept(false);
 
     OpenVDS::IJKCoordinateTransformer coordinate_transformer() const noexcept(false);
 protected:
@@ -81,4 +115,19 @@ private:
 
     void validate_metadata() const noexcept(false);
 };
+
+/** Annotation (inline, crossline) of the world coordinate (x, y)
+ *
+ * For 3D volumes this is given by the transformer. A 2D line has no grid, so
+ * the coordinate is snapped to the nearest trace of the line instead, unless
+ * it is further from that trace than the traces are apart. Such coordinates
+ * are given an annotation outside of the line.
+ */
+OpenVDS::DoubleVector3 world_to_annotation(
+    MetadataHandle const& metadata,
+    OpenVDS::IJKCoordinateTransformer const& transformer,
+    double const x,
+    double const y
+) noexcept(false);
+
 #endif /* VDS_SLICE_METADATAHANDLE_HPP */
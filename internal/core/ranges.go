@@ -0,0 +1,149 @@
+package core
+
+import (
+	"encoding/json"
+	"math"
+	"strings"
+)
+
+// @Description The valid linenos along an axis, min + n * step up to max
+type LinenoRange struct {
+	// First valid lineno
+	Min float64 `json:"min" example:"1"`
+
+	// Last valid lineno
+	Max float64 `json:"max" example:"5"`
+
+	// Distance from one lineno to the next. Zero for axes of a single
+	// sample.
+	Step float64 `json:"step" example:"2"`
+} // @name LinenoRange
+
+// @Description The valid linenos of a direction, as both indices and annotations
+type DirectionRange struct {
+	// The direction, as given in requests
+	Direction string `json:"direction" example:"inline"`
+
+	// Position of the axis of the direction in the axes of the metadata
+	Axis int `json:"axis" example:"0"`
+
+	// The valid linenos in lineno mode index
+	Index LinenoRange `json:"index"`
+
+	// The valid linenos in lineno mode annotation
+	Annotation LinenoRange `json:"annotation"`
+} // @name DirectionRange
+
+/* The indices of the samples along the axis */
+func (a *Axis) IndexRange() LinenoRange {
+	return LinenoRange{Min: 0, Max: float64(a.Samples - 1), Step: 1}
+}
+
+/* The annotations of the samples along the axis */
+func (a *Axis) AnnotationRange() LinenoRange {
+	return LinenoRange{Min: a.Min, Max: a.Max, Step: a.StepSize}
+}
+
+/* The annotation of the sample at index */
+func (a *Axis) AnnotationAt(index int) float64 {
+	return a.Min + float64(index)*a.StepSize
+}
+
+/** The index of the sample at annotation
+ *
+ * False if there is no sample at the annotation, i.e. if it is outside the
+ * axis or between two samples. The inverse of AnnotationAt.
+ */
+func (a *Axis) IndexOf(annotation float64) (int, bool) {
+	if a.Samples == 1 || a.StepSize == 0 {
+		return 0, annotation == a.Min
+	}
+
+	position := (annotation - a.Min) / a.StepSize
+	if position < -0.5 {
+		return 0, false
+	}
+	index := int(position + 0.5)
+	if index >= a.Samples {
+		return 0, false
+	}
+	/* Annotations are computed, so they are compared with some slack */
+	offset := math.Abs(a.AnnotationAt(index) - annotation)
+	return index, offset <= 1e-6*math.Abs(a.StepSize)
+}
+
+/** The position of the axis of a direction in the axes of a cube
+ *
+ * False if the cube has no such axis, i.e. inline for a 2D line, or if the
+ * vertical axis can not be sliced by the direction, i.e. depth for a time
+ * cube. The vertical axis is checked like the slice endpoint does, see
+ * validate_vertical_axis.
+ */
+func directionAxis(axes []*Axis, axis int) (int, bool) {
+	first := 3 - len(axes)
+	var position int
+	switch axis {
+	case AxisI, AxisInline:
+		position = 0 - first
+	case AxisJ, AxisCrossline:
+		position = 1 - first
+	default:
+		position = 2 - first
+	}
+	if position < 0 || position >= len(axes) {
+		return -1, false
+	}
+
+	if axis != AxisDepth && axis != AxisTime {
+		return position, true
+	}
+
+	vertical := axes[position]
+	name := strings.ToLower(vertical.Annotation)
+	unit, ok := lookupVerticalUnit(vertical.Unit)
+	if !ok {
+		return -1, false
+	}
+
+	quantity := "time"
+	if axis == AxisDepth {
+		quantity = "depth"
+	}
+	if name != quantity && name != "sample" {
+		return -1, false
+	}
+	return position, unit.quantity == quantity
+}
+
+/** The valid linenos of every direction the cube can be sliced by
+ *
+ * Directions are listed in the order of Directions(). Directions that name
+ * the axes of the cube by position or annotation are left out, as they are
+ * aliases of the listed ones, see GetAxis.
+ */
+func GetRanges(metadata []byte) ([]DirectionRange, error) {
+	var meta Metadata
+	if err := json.Unmarshal(metadata, &meta); err != nil {
+		return nil, NewInternalError(err.Error())
+	}
+	if len(meta.Axis) == 0 || len(meta.Axis) > 3 {
+		return nil, NewInternalError("expected 2 or 3 axes in metadata")
+	}
+
+	ranges := []DirectionRange{}
+	for _, option := range axisOptions {
+		position, ok := directionAxis(meta.Axis, option.value)
+		if !ok {
+			continue
+		}
+
+		axis := meta.Axis[position]
+		ranges = append(ranges, DirectionRange{
+			Direction:  option.name,
+			Axis:       position,
+			Index:      axis.IndexRange(),
+			Annotation: axis.AnnotationRange(),
+		})
+	}
+	return ranges, nil
+}
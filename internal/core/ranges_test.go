@@ -0,0 +1,107 @@
+package core
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAxisIndexOf(t *testing.T) {
+	axis := Axis{Min: 1, Max: 5, Samples: 3, StepSize: 2}
+
+	testcases := []struct {
+		annotation float64
+		index      int
+		ok         bool
+	}{
+		{annotation: 1, index: 0, ok: true},
+		{annotation: 3, index: 1, ok: true},
+		{annotation: 5, index: 2, ok: true},
+		{annotation: 2, ok: false},
+		{annotation: -1, ok: false},
+		{annotation: 7, ok: false},
+	}
+
+	for _, testcase := range testcases {
+		index, ok := axis.IndexOf(testcase.annotation)
+		require.Equalf(t, testcase.ok, ok, "annotation %v", testcase.annotation)
+		if !ok {
+			continue
+		}
+		require.Equalf(t, testcase.index, index, "annotation %v", testcase.annotation)
+		require.Equal(t, testcase.annotation, axis.AnnotationAt(index))
+	}
+
+	single := Axis{Min: 4, Max: 4, Samples: 1}
+	index, ok := single.IndexOf(4)
+	require.True(t, ok)
+	require.Equal(t, 0, index)
+	_, ok = single.IndexOf(5)
+	require.False(t, ok)
+}
+
+func TestGetRanges(t *testing.T) {
+	inline := &Axis{Annotation: "Inline", Min: 1, Max: 5, Samples: 3, StepSize: 2}
+	crossline := &Axis{Annotation: "Crossline", Min: 10, Max: 11, Samples: 2, StepSize: 1}
+
+	testcases := []struct {
+		name       string
+		axes       []*Axis
+		directions []string
+	}{
+		{
+			name: "Time cube",
+			axes: []*Axis{
+				inline,
+				crossline,
+				{Annotation: "Time", Min: 4, Max: 16, Samples: 4, StepSize: 4, Unit: "ms"},
+			},
+			directions: []string{"i", "j", "k", "inline", "crossline", "time", "sample"},
+		},
+		{
+			name: "Depth cube",
+			axes: []*Axis{
+				inline,
+				crossline,
+				{Annotation: "Depth", Min: 4, Max: 16, Samples: 4, StepSize: 4, Unit: "m"},
+			},
+			directions: []string{"i", "j", "k", "inline", "crossline", "depth", "sample"},
+		},
+		{
+			name: "Sample axis of unknown unit",
+			axes: []*Axis{
+				inline,
+				crossline,
+				{Annotation: "Sample", Min: 4, Max: 16, Samples: 4, StepSize: 4, Unit: "furlongs"},
+			},
+			directions: []string{"i", "j", "k", "inline", "crossline", "sample"},
+		},
+		{
+			name: "2D line",
+			axes: []*Axis{
+				crossline,
+				{Annotation: "Sample", Min: 4, Max: 16, Samples: 4, StepSize: 4, Unit: "ms"},
+			},
+			directions: []string{"j", "k", "crossline", "time", "sample"},
+		},
+	}
+
+	for _, testcase := range testcases {
+		metadata, err := json.Marshal(Metadata{Axis: testcase.axes})
+		require.NoError(t, err)
+
+		ranges, err := GetRanges(metadata)
+		require.NoError(t, err, testcase.name)
+
+		directions := make([]string, len(ranges))
+		for i, r := range ranges {
+			directions[i] = r.Direction
+
+			axis := testcase.axes[r.Axis]
+			require.Equalf(t, axis.IndexRange(), r.Index, testcase.name)
+			require.Equalf(t, axis.AnnotationRange(), r.Annotation, testcase.name)
+		}
+		require.Equalf(t, testcase.directions, directions, testcase.name)
+	}
+}
@@ -0,0 +1,210 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+/** What an alias resolves to
+ *
+ * The sas is a server-side credential, used for requests that carry no
+ * credentials of their own.
+ */
+type Resolution struct {
+	Url string `json:"url" yaml:"url"`
+	Sas string `json:"sas,omitempty" yaml:"sas,omitempty"`
+}
+
+/** Translates aliases, e.g. survey names or catalogue ids, into VDS urls */
+type VdsResolver interface {
+	Resolve(alias string) (Resolution, error)
+}
+
+/** Check if vds is an alias rather than a url or a path
+ *
+ * Anything with a scheme or a path separator is taken to be a url or a path,
+ * such that aliases never shadow a VDS that could be opened directly.
+ */
+func IsVdsAlias(vds string) bool {
+	vds = strings.TrimSpace(vds)
+	if vds == "" {
+		return false
+	}
+	return !strings.Contains(vds, "://") && !strings.ContainsAny(vds, `/\`)
+}
+
+/** Resolves aliases from a fixed mapping */
+type StaticResolver map[string]Resolution
+
+/** Read the mapping of a static resolver from a file
+ *
+ * The file is a YAML (or JSON) mapping from alias to url and, optionally, sas:
+ *
+ *     survey-a:
+ *       url: https://account.blob.core.windows.net/container/blob
+ *       sas: sp=r&sig=...
+ */
+func NewStaticResolver(path string) (StaticResolver, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	resolver := StaticResolver{}
+	err = yaml.Unmarshal(content, &resolver)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	for alias, resolution := range resolver {
+		if strings.TrimSpace(resolution.Url) == "" {
+			return nil, fmt.Errorf("%s: alias '%s' has no url", path, alias)
+		}
+	}
+	return resolver, nil
+}
+
+func (r StaticResolver) Resolve(alias string) (Resolution, error) {
+	resolution, ok := r[alias]
+	if !ok {
+		return Resolution{}, fmt.Errorf("no such alias")
+	}
+	return resolution, nil
+}
+
+/** Resolves aliases by looking them up in an HTTP catalogue
+ *
+ * The alias is appended to the base url of the catalogue, which answers with
+ * the Resolution as json, or 404 for unknown aliases.
+ */
+type catalogueResolver struct {
+	base   string
+	client *http.Client
+}
+
+func NewCatalogueResolver(base string, timeout time.Duration) VdsResolver {
+	return &catalogueResolver{
+		base:   strings.TrimSuffix(base, "/"),
+		client: &http.Client{Timeout: timeout},
+	}
+}
+
+func (r *catalogueResolver) Resolve(alias string) (Resolution, error) {
+	var resolution Resolution
+
+	request, err := http.NewRequest(
+		http.MethodGet,
+		r.base+"/"+url.PathEscape(alias),
+		nil,
+	)
+	if err != nil {
+		return resolution, err
+	}
+	request.Header.Set("Accept", "application/json")
+
+	response, err := r.client.Do(request)
+	if err != nil {
+		return resolution, fmt.Errorf("catalogue is unavailable")
+	}
+	defer response.Body.Close()
+
+	switch response.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return resolution, fmt.Errorf("not found in the catalogue")
+	default:
+		return resolution, fmt.Errorf(
+			"catalogue responded with status %d",
+			response.StatusCode,
+		)
+	}
+
+	err = json.NewDecoder(response.Body).Decode(&resolution)
+	if err != nil || strings.TrimSpace(resolution.Url) == "" {
+		return Resolution{}, fmt.Errorf("catalogue gave no url")
+	}
+	return resolution, nil
+}
+
+/* Max number of resolutions held by a caching resolver */
+const resolverCacheEntries = 1024
+
+type cachedResolution struct {
+	resolution Resolution
+	expires    time.Time
+}
+
+/** Remembers the resolutions of another resolver for ttl
+ *
+ * Only successful resolutions are cached, such that aliases that are added to
+ * the catalogue become available right away.
+ */
+type cachingResolver struct {
+	resolver VdsResolver
+	ttl      time.Duration
+	now      func() time.Time
+
+	lock    sync.Mutex
+	entries map[string]cachedResolution
+}
+
+func NewCachingResolver(resolver VdsResolver, ttl time.Duration) VdsResolver {
+	return &cachingResolver{
+		resolver: resolver,
+		ttl:      ttl,
+		now:      time.Now,
+		entries:  map[string]cachedResolution{},
+	}
+}
+
+func (r *cachingResolver) lookup(alias string) (Resolution, bool) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	entry, ok := r.entries[alias]
+	if !ok || !r.now().Before(entry.expires) {
+		return Resolution{}, false
+	}
+	return entry.resolution, true
+}
+
+func (r *cachingResolver) store(alias string, resolution Resolution) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	now := r.now()
+	if len(r.entries) >= resolverCacheEntries {
+		for key, entry := range r.entries {
+			if !now.Before(entry.expires) {
+				delete(r.entries, key)
+			}
+		}
+	}
+	if len(r.entries) >= resolverCacheEntries {
+		return
+	}
+	r.entries[alias] = cachedResolution{
+		resolution: resolution,
+		expires:    now.Add(r.ttl),
+	}
+}
+
+func (r *cachingResolver) Resolve(alias string) (Resolution, error) {
+	if resolution, ok := r.lookup(alias); ok {
+		return resolution, nil
+	}
+
+	resolution, err := r.resolver.Resolve(alias)
+	if err != nil {
+		return resolution, err
+	}
+	r.store(alias, resolution)
+	return resolution, nil
+}
@@ -0,0 +1,153 @@
+package core
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsVdsAlias(t *testing.T) {
+	testcases := []struct {
+		vds      string
+		expected bool
+	}{
+		{vds: "survey-a", expected: true},
+		{vds: "3f2c6a1e-5d9b-4e0f-9c61-0a6e2b7c8d90", expected: true},
+		{vds: "well_known.vds", expected: true},
+		{vds: "https://account.blob.core.windows.net/container/blob", expected: false},
+		{vds: "s3://bucket/blob", expected: false},
+		{vds: "../../testdata/well_known/well_known_default.vds", expected: false},
+		{vds: `testdata\well_known.vds`, expected: false},
+		{vds: "", expected: false},
+		{vds: "  ", expected: false},
+	}
+
+	for _, testcase := range testcases {
+		require.Equal(t,
+			testcase.expected,
+			IsVdsAlias(testcase.vds),
+			"vds '%s'",
+			testcase.vds,
+		)
+	}
+}
+
+func TestStaticResolver(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "aliases.yaml")
+	content := "survey-a:\n" +
+		"  url: https://account.blob.core.windows.net/container/a\n" +
+		"  sas: sp=r&sig=secret\n" +
+		"survey-b:\n" +
+		"  url: https://account.blob.core.windows.net/container/b\n"
+	require.NoError(t, os.WriteFile(path, []byte(content), 0600))
+
+	resolver, err := NewStaticResolver(path)
+	require.NoError(t, err)
+
+	resolution, err := resolver.Resolve("survey-a")
+	require.NoError(t, err)
+	require.Equal(t, Resolution{
+		Url: "https://account.blob.core.windows.net/container/a",
+		Sas: "sp=r&sig=secret",
+	}, resolution)
+
+	resolution, err = resolver.Resolve("survey-b")
+	require.NoError(t, err)
+	require.Empty(t, resolution.Sas)
+
+	_, err = resolver.Resolve("survey-c")
+	require.ErrorContains(t, err, "no such alias")
+}
+
+func TestStaticResolverErrors(t *testing.T) {
+	dir := t.TempDir()
+
+	_, err := NewStaticResolver(filepath.Join(dir, "missing.yaml"))
+	require.Error(t, err)
+
+	path := filepath.Join(dir, "aliases.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("survey-a:\n  sas: x\n"), 0600))
+	_, err = NewStaticResolver(path)
+	require.ErrorContains(t, err, "alias 'survey-a' has no url")
+}
+
+func TestCatalogueResolver(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/surveys/survey a":
+				fmt.Fprint(w, `{"url": "https://account.blob.core.windows.net/c/a", "sas": "sig=x"}`)
+			case "/surveys/empty":
+				fmt.Fprint(w, `{}`)
+			case "/surveys/broken":
+				w.WriteHeader(http.StatusInternalServerError)
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		},
+	))
+	defer server.Close()
+
+	resolver := NewCatalogueResolver(server.URL+"/surveys/", time.Second)
+
+	resolution, err := resolver.Resolve("survey a")
+	require.NoError(t, err)
+	require.Equal(t, Resolution{
+		Url: "https://account.blob.core.windows.net/c/a",
+		Sas: "sig=x",
+	}, resolution)
+
+	_, err = resolver.Resolve("unknown")
+	require.ErrorContains(t, err, "not found in the catalogue")
+
+	_, err = resolver.Resolve("empty")
+	require.ErrorContains(t, err, "catalogue gave no url")
+
+	_, err = resolver.Resolve("broken")
+	require.ErrorContains(t, err, "catalogue responded with status 500")
+}
+
+type countingResolver struct {
+	calls int
+	fail  bool
+}
+
+func (r *countingResolver) Resolve(alias string) (Resolution, error) {
+	r.calls++
+	if r.fail {
+		return Resolution{}, fmt.Errorf("no such alias")
+	}
+	return Resolution{Url: "https://account.blob.core.windows.net/c/" + alias}, nil
+}
+
+func TestCachingResolver(t *testing.T) {
+	now := time.Now()
+	inner := &countingResolver{}
+	resolver := NewCachingResolver(inner, time.Minute).(*cachingResolver)
+	resolver.now = func() time.Time { return now }
+
+	first, err := resolver.Resolve("a")
+	require.NoError(t, err)
+	second, err := resolver.Resolve("a")
+	require.NoError(t, err)
+	require.Equal(t, first, second)
+	require.Equal(t, 1, inner.calls, "Expected the second lookup to be cached")
+
+	now = now.Add(time.Minute)
+	_, err = resolver.Resolve("a")
+	require.NoError(t, err)
+	require.Equal(t, 2, inner.calls, "Expected the resolution to expire")
+
+	inner.fail = true
+	_, err = resolver.Resolve("b")
+	require.Error(t, err)
+	_, err = resolver.Resolve("b")
+	require.Error(t, err)
+	require.Equal(t, 4, inner.calls, "Expected failures not to be cached")
+}
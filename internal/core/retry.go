@@ -0,0 +1,448 @@
+package core
+
+import (
+	"context"
+	"time"
+)
+
+/** Receives notifications about retries, e.g. for metrics */
+type RetryObserver interface {
+	RetryAttempted()
+	RetriesExhausted()
+}
+
+/** Policy for retrying operations that fail with transient errors
+ *
+ * Retries is the number of additional attempts after the first one. The wait
+ * before the first retry is Backoff, and it is doubled for every subsequent
+ * retry. The zero value disables retries altogether.
+ */
+type RetryPolicy struct {
+	Retries  int
+	Backoff  time.Duration
+	Observer RetryObserver
+}
+
+/** Storage statuses worth retrying, i.e. timeouts, throttling and server errors */
+var transientStatus = map[int]bool{
+	408: true,
+	429: true,
+	500: true,
+	502: true,
+	503: true,
+	504: true,
+}
+
+/** Check if an error is known to be transient
+ *
+ * Blob Store occasionally fails individual chunk reads with 500/503, throttles
+ * with 429, or the read simply times out. Those are told apart by the status
+ * OpenVDS reports for the failed request, see NewStorageError. Anything that
+ * has already been classified (not found, unauthorized, ..) will fail the
+ * same way on the next attempt.
+ */
+func isTransient(err error) bool {
+	storageErr, ok := err.(*InternalError)
+	if !ok {
+		return false
+	}
+	return transientStatus[storageErr.status]
+}
+
+/** Check if an error is due to storage being unavailable for the time being
+ *
+ * That is the transient errors that are retried, and the requests that fail
+ * fast because the circuit of the storage host is open.
+ */
+func IsStorageTransient(err error) bool {
+	if _, ok := err.(*UnavailableError); ok {
+		return true
+	}
+	return isTransient(err)
+}
+
+func (p RetryPolicy) attempted() {
+	if p.Observer != nil {
+		p.Observer.RetryAttempted()
+	}
+}
+
+func (p RetryPolicy) exhausted() {
+	if p.Observer != nil && p.Retries > 0 {
+		p.Observer.RetriesExhausted()
+	}
+}
+
+/** Run operation, retrying it on transient errors
+ *
+ * The context is honoured, such that retries never extend a request beyond
+ * its deadline. If the next backoff would end after the deadline, or the
+ * context is cancelled while waiting, the last error is returned right away.
+ */
+func (p RetryPolicy) do(ctx context.Context, operation func() error) error {
+	backoff := p.Backoff
+	for attempt := 0; ; attempt++ {
+		err := operation()
+		if err == nil || !isTransient(err) {
+			return err
+		}
+
+		if attempt >= p.Retries {
+			p.exhausted()
+			return err
+		}
+
+		deadline, ok := ctx.Deadline()
+		if ok && time.Now().Add(backoff).After(deadline) {
+			p.exhausted()
+			return err
+		}
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			p.exhausted()
+			return err
+		case <-timer.C:
+		}
+
+		p.attempted()
+		backoff *= 2
+	}
+}
+
+/** Open the cube conn points to, retrying the open and every read from it
+ *
+ * Reads are retried one by one, such that a transient failure of a single
+ * read does not start the whole request over. The retries of the handle's
+ * reads honour ctx too.
+ */
+func (p RetryPolicy) Open(
+	ctx context.Context,
+	open HandleOpener,
+	conn Connection,
+) (DSHandle, error) {
+	var handle DSHandle
+	err := p.do(ctx, func() error {
+		var err error
+		handle, err = open(conn)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return retryingHandle{DSHandle: handle, policy: p, ctx: ctx}, nil
+}
+
+/** Open the VDS conn points to with OpenVDS, retrying transient failures
+ *
+ * Unlike Open, reads from the handle are not retried. Meant for requests
+ * that only need what is read when opening, like the layout.
+ */
+func (p RetryPolicy) OpenVds(
+	ctx context.Context,
+	conn Connection,
+) (VdsHandle, error) {
+	var handle VdsHandle
+	err := p.do(ctx, func() error {
+		var err error
+		handle, err = NewDSHandle(conn)
+		return err
+	})
+	return handle, err
+}
+
+/** A DSHandle that retries its reads from storage, see RetryPolicy.Open */
+type retryingHandle struct {
+	DSHandle
+	policy RetryPolicy
+	ctx    context.Context
+}
+
+func (h retryingHandle) WithWorkerPool(pool *WorkerPool) DSHandle {
+	h.DSHandle = h.DSHandle.WithWorkerPool(pool)
+	return h
+}
+
+func (h retryingHandle) read(operation func() error) error {
+	return h.policy.do(h.ctx, operation)
+}
+
+func (h retryingHandle) GetSlice(
+	lineno int,
+	direction int,
+	linenoSystem int,
+	bounds []Bound,
+	fillValue *float32,
+) (data []byte, err error) {
+	err = h.read(func() error {
+		data, err = h.DSHandle.GetSlice(
+			lineno,
+			direction,
+			linenoSystem,
+			bounds,
+			fillValue,
+		)
+		return err
+	})
+	return data, err
+}
+
+func (h retryingHandle) GetSliceWithMetadata(
+	lineno int,
+	direction int,
+	linenoSystem int,
+	bounds []Bound,
+	fillValue *float32,
+) (data []byte, metadata []byte, err error) {
+	err = h.read(func() error {
+		data, metadata, err = h.DSHandle.GetSliceWithMetadata(
+			lineno,
+			direction,
+			linenoSystem,
+			bounds,
+			fillValue,
+		)
+		return err
+	})
+	return data, metadata, err
+}
+
+func (h retryingHandle) GetStoredSliceWithMetadata(
+	lineno int,
+	direction int,
+	linenoSystem int,
+	bounds []Bound,
+) (data []byte, metadata []byte, err error) {
+	err = h.read(func() error {
+		data, metadata, err = h.DSHandle.GetStoredSliceWithMetadata(
+			lineno,
+			direction,
+			linenoSystem,
+			bounds,
+		)
+		return err
+	})
+	return data, metadata, err
+}
+
+func (h retryingHandle) GetSliceRows(
+	lineno int,
+	direction int,
+	linenoSystem int,
+	bounds []Bound,
+	fillValue *float32,
+	first int,
+	stride int,
+) (data []byte, err error) {
+	err = h.read(func() error {
+		data, err = h.DSHandle.GetSliceRows(
+			lineno,
+			direction,
+			linenoSystem,
+			bounds,
+			fillValue,
+			first,
+			stride,
+		)
+		return err
+	})
+	return data, err
+}
+
+func (h retryingHandle) GetStoredSliceRows(
+	lineno int,
+	direction int,
+	linenoSystem int,
+	bounds []Bound,
+	first int,
+	stride int,
+) (data []byte, err error) {
+	err = h.read(func() error {
+		data, err = h.DSHandle.GetStoredSliceRows(
+			lineno,
+			direction,
+			linenoSystem,
+			bounds,
+			first,
+			stride,
+		)
+		return err
+	})
+	return data, err
+}
+
+func (h retryingHandle) GetFence(
+	coordinateSystem int,
+	coordinates [][]float32,
+	interpolation int,
+	fillValue *float32,
+) (data []byte, err error) {
+	err = h.read(func() error {
+		data, err = h.DSHandle.GetFence(
+			coordinateSystem,
+			coordinates,
+			interpolation,
+			fillValue,
+		)
+		return err
+	})
+	return data, err
+}
+
+func (h retryingHandle) GetFenceWithMetadata(
+	coordinateSystem int,
+	coordinates [][]float32,
+	interpolation int,
+	fillValue *float32,
+) (data []byte, metadata []byte, err error) {
+	err = h.read(func() error {
+		data, metadata, err = h.DSHandle.GetFenceWithMetadata(
+			coordinateSystem,
+			coordinates,
+			interpolation,
+			fillValue,
+		)
+		return err
+	})
+	return data, metadata, err
+}
+
+func (h retryingHandle) GetFenceHeaders(
+	coordinateSystem int,
+	coordinates [][]float32,
+	fields []string,
+	fillValue *float32,
+) (data []byte, err error) {
+	err = h.read(func() error {
+		data, err = h.DSHandle.GetFenceHeaders(
+			coordinateSystem,
+			coordinates,
+			fields,
+			fillValue,
+		)
+		return err
+	})
+	return data, err
+}
+
+func (h retryingHandle) GetSamplesWithMetadata(
+	coordinateSystem int,
+	points [][]float32,
+	interpolation int,
+	fillValue *float32,
+	conversion VerticalUnitConversion,
+) (data [][]byte, metadata []byte, err error) {
+	err = h.read(func() error {
+		data, metadata, err = h.DSHandle.GetSamplesWithMetadata(
+			coordinateSystem,
+			points,
+			interpolation,
+			fillValue,
+			conversion,
+		)
+		return err
+	})
+	return data, metadata, err
+}
+
+func (h retryingHandle) GetAttributesAlongSurface(
+	referenceSurface RegularSurface,
+	above float32,
+	below float32,
+	stepsize float32,
+	attributes []string,
+	interpolation int,
+	verticalInterpolation int,
+) (data [][]byte, err error) {
+	err = h.read(func() error {
+		data, err = h.DSHandle.GetAttributesAlongSurface(
+			referenceSurface,
+			above,
+			below,
+			stepsize,
+			attributes,
+			interpolation,
+			verticalInterpolation,
+		)
+		return err
+	})
+	return data, err
+}
+
+func (h retryingHandle) GetAttributesAlongSurfaceWithMetadata(
+	referenceSurface RegularSurface,
+	above float32,
+	below float32,
+	stepsize float32,
+	attributes []string,
+	interpolation int,
+	verticalInterpolation int,
+	polygon *Polygon,
+	band *SurfaceBand,
+	minValidFraction float32,
+) (data [][]byte, metadata []byte, err error) {
+	err = h.read(func() error {
+		data, metadata, err = h.DSHandle.GetAttributesAlongSurfaceWithMetadata(
+			referenceSurface,
+			above,
+			below,
+			stepsize,
+			attributes,
+			interpolation,
+			verticalInterpolation,
+			polygon,
+			band,
+			minValidFraction,
+		)
+		return err
+	})
+	return data, metadata, err
+}
+
+func (h retryingHandle) GetAttributesBetweenSurfaces(
+	primarySurface RegularSurface,
+	secondarySurface RegularSurface,
+	stepsize float32,
+	attributes []string,
+	interpolation int,
+	verticalInterpolation int,
+) (data [][]byte, err error) {
+	err = h.read(func() error {
+		data, err = h.DSHandle.GetAttributesBetweenSurfaces(
+			primarySurface,
+			secondarySurface,
+			stepsize,
+			attributes,
+			interpolation,
+			verticalInterpolation,
+		)
+		return err
+	})
+	return data, err
+}
+
+func (h retryingHandle) GetAttributesBetweenSurfacesWithMetadata(
+	primarySurface RegularSurface,
+	secondarySurface RegularSurface,
+	stepsize float32,
+	attributes []string,
+	interpolation int,
+	verticalInterpolation int,
+	minValidFraction float32,
+) (data [][]byte, metadata []byte, err error) {
+	err = h.read(func() error {
+		data, metadata, err = h.DSHandle.GetAttributesBetweenSurfacesWithMetadata(
+			primarySurface,
+			secondarySurface,
+			stepsize,
+			attributes,
+			interpolation,
+			verticalInterpolation,
+			minValidFraction,
+		)
+		return err
+	})
+	return data, metadata, err
+}
@@ -0,0 +1,263 @@
+package core
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type countingObserver struct {
+	attempted int
+	exhausted int
+}
+
+func (o *countingObserver) RetryAttempted() {
+	o.attempted++
+}
+
+func (o *countingObserver) RetriesExhausted() {
+	o.exhausted++
+}
+
+/** A fake connection to storage that fails the first n reads with err
+ *
+ * Opening never fails, such that retries of the reads can be told apart from
+ * starting the request over.
+ */
+type flakyConnection struct {
+	failures int
+	err      error
+	opens    int
+	reads    int
+}
+
+func (c *flakyConnection) open(Connection) (DSHandle, error) {
+	c.opens++
+	return flakyHandle{conn: c}, nil
+}
+
+func (c *flakyConnection) read() error {
+	c.reads++
+	if c.reads <= c.failures {
+		return c.err
+	}
+	return nil
+}
+
+/** A handle reading slices from a flakyConnection, nothing else is implemented */
+type flakyHandle struct {
+	DSHandle
+	conn *flakyConnection
+}
+
+func (h flakyHandle) WithWorkerPool(*WorkerPool) DSHandle {
+	return h
+}
+
+func (h flakyHandle) GetSlice(
+	lineno int,
+	direction int,
+	linenoSystem int,
+	bounds []Bound,
+	fillValue *float32,
+) ([]byte, error) {
+	if err := h.conn.read(); err != nil {
+		return nil, err
+	}
+	return []byte{}, nil
+}
+
+func readSlice(policy RetryPolicy, ctx context.Context, conn *flakyConnection) error {
+	handle, err := policy.Open(ctx, conn.open, nil)
+	if err != nil {
+		return err
+	}
+	_, err = handle.WithWorkerPool(nil).GetSlice(0, AxisInline, AxisInline, nil, nil)
+	return err
+}
+
+func TestRetryPolicy(t *testing.T) {
+	unavailable := NewStorageError("Failed to read from VDS. 503 Server Busy", 503)
+
+	testCases := []struct {
+		name              string
+		retries           int
+		failures          int
+		err               error
+		expectedReads     int
+		expectedAttempted int
+		expectedExhausted int
+		shouldError       bool
+	}{
+		{
+			name:          "No failures",
+			retries:       2,
+			expectedReads: 1,
+		},
+		{
+			name:              "Transient failures within retries",
+			retries:           2,
+			failures:          2,
+			err:               unavailable,
+			expectedReads:     3,
+			expectedAttempted: 2,
+		},
+		{
+			name:              "Transient failures exhaust retries",
+			retries:           2,
+			failures:          5,
+			err:               unavailable,
+			expectedReads:     3,
+			expectedAttempted: 2,
+			expectedExhausted: 1,
+			shouldError:       true,
+		},
+		{
+			name:              "Timeouts are transient",
+			retries:           1,
+			failures:          1,
+			err:               NewStorageError("Failed to read from VDS. 504", 504),
+			expectedReads:     2,
+			expectedAttempted: 1,
+		},
+		{
+			name:              "Throttling is transient",
+			retries:           1,
+			failures:          1,
+			err:               NewStorageError("Failed to read from VDS. 429", 429),
+			expectedReads:     2,
+			expectedAttempted: 1,
+		},
+		{
+			name:          "Retries disabled",
+			retries:       0,
+			failures:      1,
+			err:           unavailable,
+			expectedReads: 1,
+			shouldError:   true,
+		},
+		{
+			name:          "Permanent failures are not retried",
+			retries:       2,
+			failures:      1,
+			err:           NewStorageError("Failed to read from VDS. 400", 400),
+			expectedReads: 1,
+			shouldError:   true,
+		},
+		{
+			name:          "Failures of unknown status are not retried",
+			retries:       2,
+			failures:      1,
+			err:           NewStorageError("Failed to read from VDS. corrupt data", 0),
+			expectedReads: 1,
+			shouldError:   true,
+		},
+		{
+			name:          "Statuses in the message are ignored",
+			retries:       2,
+			failures:      1,
+			err:           NewInternalError("Failed to read from VDS. 503"),
+			expectedReads: 1,
+			shouldError:   true,
+		},
+		{
+			name:          "Classified failures are not retried",
+			retries:       2,
+			failures:      1,
+			err:           NewNotFoundError("Could not open VDS: 503"),
+			expectedReads: 1,
+			shouldError:   true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		observer := &countingObserver{}
+		policy := RetryPolicy{
+			Retries:  testCase.retries,
+			Backoff:  time.Millisecond,
+			Observer: observer,
+		}
+		conn := &flakyConnection{failures: testCase.failures, err: testCase.err}
+
+		err := readSlice(policy, context.Background(), conn)
+		if testCase.shouldError {
+			require.Equalf(t, testCase.err, err, "[%s]", testCase.name)
+		} else {
+			require.NoErrorf(t, err, "[%s]", testCase.name)
+		}
+
+		require.Equalf(t, 1, conn.opens,
+			"[%s] Reads should be retried without reopening", testCase.name)
+		require.Equalf(t, testCase.expectedReads, conn.reads,
+			"[%s] Wrong number of reads", testCase.name)
+		require.Equalf(t, testCase.expectedAttempted, observer.attempted,
+			"[%s] Wrong number of retries attempted", testCase.name)
+		require.Equalf(t, testCase.expectedExhausted, observer.exhausted,
+			"[%s] Wrong number of retries exhausted", testCase.name)
+	}
+}
+
+func TestRetryPolicyHonoursDeadline(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	observer := &countingObserver{}
+	policy := RetryPolicy{
+		Retries:  10,
+		Backoff:  time.Second,
+		Observer: observer,
+	}
+	conn := &flakyConnection{
+		failures: 10,
+		err:      NewStorageError("Failed to read from VDS. 503 Server Busy", 503),
+	}
+
+	start := time.Now()
+	err := readSlice(policy, ctx, conn)
+
+	require.Error(t, err)
+	require.Less(t, time.Since(start), time.Second, "Retry outlived the deadline")
+	require.Equal(t, 1, conn.reads)
+	require.Equal(t, 1, observer.exhausted)
+}
+
+func TestRetryPolicyHonoursCancellation(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	policy := RetryPolicy{Retries: 10, Backoff: time.Second}
+	conn := &flakyConnection{
+		failures: 10,
+		err:      NewStorageError("Failed to read from VDS. 503 Server Busy", 503),
+	}
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+	}()
+
+	start := time.Now()
+	err := readSlice(policy, ctx, conn)
+
+	require.Error(t, err)
+	require.Less(t, time.Since(start), time.Second, "Retry ignored cancellation")
+	require.Equal(t, 1, conn.reads)
+}
+
+func TestRetryPolicyRetriesOpen(t *testing.T) {
+	opens := 0
+	open := func(Connection) (DSHandle, error) {
+		opens++
+		if opens == 1 {
+			return nil, NewStorageError("Could not open VDS: 503 Server Busy", 503)
+		}
+		return flakyHandle{conn: &flakyConnection{}}, nil
+	}
+
+	policy := RetryPolicy{Retries: 2, Backoff: time.Millisecond}
+	_, err := policy.Open(context.Background(), open, nil)
+
+	require.NoError(t, err)
+	require.Equal(t, 2, opens)
+}
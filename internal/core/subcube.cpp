@@ -1,6 +1,9 @@
 #include "subcube.hpp"
 
+#include <map>
 #include <stdexcept>
+#include <string>
+#include <vector>
 
 #include "axis.hpp"
 #include "exceptions.hpp"
@@ -10,9 +13,61 @@
 
 namespace {
 
+struct Range {
+    std::string min;
+    std::string max;
+    std::string stepsize;
+
+    std::string to_string() const {
+        return "[" + min + ":" + max + ":" + stepsize + "]";
+    }
+};
+
+Range annotation_range(Axis const& axis) {
+    return Range {
+        utils::to_string_with_precision(axis.min()),
+        utils::to_string_with_precision(axis.max()),
+        utils::to_string_with_precision(axis.stepsize())
+    };
+}
+
+Range index_range(Axis const& axis) {
+    return Range { "0", std::to_string(axis.nsamples() - 1), "1" };
+}
+
+/*
+ * Only the lineno has a code of its own, bounds out of range are given the
+ * default one
+ */
+detail::bad_request line_out_of_range(
+    std::string const& name,
+    int lineno,
+    Range const& valid,
+    std::string const& msg
+) {
+    if (name != "lineno") return detail::bad_request(msg);
+
+    return detail::bad_request(
+        "lineno_out_of_range",
+        {
+            { "lineno",   std::to_string(lineno) },
+            { "min",      valid.min              },
+            { "max",      valid.max              },
+            { "stepsize", valid.stepsize         },
+        },
+        msg
+    );
+}
+
+/*
+ * Users tend to mix up indices and annotations, so the error gives the valid
+ * range in both, and says which one the value was taken to be. The name is
+ * that of the value in the request, e.g. lineno or lower bound.
+ */
 int lineno_annotation_to_voxel(
     int lineno,
-    Axis const& axis
+    Axis const& axis,
+    std::string const& name
 ) {
     float min    = axis.min();
     float max    = axis.max();
@@ -21,11 +76,14 @@ int lineno_annotation_to_voxel(
     float voxelline = (lineno - min) / stepsize;
 
     if (lineno < min || lineno > max || std::floor(voxelline) != voxelline) {
-        throw detail::bad_request(
-            "Invalid lineno: " + std::to_string(lineno) +
-            ", valid range: [" + utils::to_string_with_precision(min) +
-            ":" + utils::to_string_with_precision(max) +
-            ":" + utils::to_string_with_precision(stepsize) + "]"
+        throw line_out_of_range(
+            name,
+            lineno,
+            annotation_range(axis),
+            "Invalid " + name + ": " + std::to_string(lineno) +
+            ", valid range: " + annotation_range(axis).to_string() +
+            ". The " + name + " is interpreted as an annotation, as an " +
+            "index the valid range is " + index_range(axis).to_string()
         );
     }
 
@@ -34,18 +92,22 @@ int lineno_annotation_to_voxel(
 
 int lineno_index_to_voxel(
     int lineno,
-    Axis const& axis
+    Axis const& axis,
+    std::string const& name
 ) {
     /* Line-numbers in IJK match Voxel - do bound checking and return*/
     int min = 0;
     int max = axis.nsamples() - 1;
 
     if (lineno < min || lineno > max) {
-        throw detail::bad_request(
-            "Invalid lineno: " + std::to_string(lineno) +
-            ", valid range: [" + std::to_string(min) +
-            ":" + std::to_string(max) +
-            ":1]"
+        throw line_out_of_range(
+            name,
+            lineno,
+            index_range(axis),
+            "Invalid " + name + ": " + std::to_string(lineno) +
+            ", valid range: " + index_range(axis).to_string() +
+            ". The " + name + " is interpreted as an index, as an " +
+            "annotation the valid range is " + annotation_range(axis).to_string()
         );
     }
 
@@ -55,14 +117,15 @@ int lineno_index_to_voxel(
 int to_voxel(
     Axis const& axis,
     int const lineno,
-    enum coordinate_system const system
+    enum coordinate_system const system,
+    std::string const& name
 ) {
     switch (system) {
         case ANNOTATION: {
-            return ::lineno_annotation_to_voxel(lineno, axis);
+            return ::lineno_annotation_to_voxel(lineno, axis, name);
         }
         case INDEX: {
-            return ::lineno_index_to_voxel(lineno, axis);
+            return ::lineno_index_to_voxel(lineno, axis, name);
         }
         default: {
             throw std::runtime_error("Unhandled coordinate system");
@@ -70,6 +133,10 @@ int to_voxel(
     }
 }
 
+std::string resolved_range(int lower, int upper) {
+    return "[" + std::to_string(lower) + ":" + std::to_string(upper) + "]";
+}
+
 } /* namespace */
 
 SubCube::SubCube(MetadataHandle const& metadata) {
@@ -88,24 +155,71 @@ void SubCube::constrain(
     std::vector< Bound > const& bounds
 ) noexcept (false) {
     for (auto const& bound : bounds) {
-        auto direction = Direction(bound.name);
+        auto direction = Direction(bound.name, bound.system);
         auto system = direction.coordinate_system();
         auto axis = metadata.get_axis(direction);
 
-        auto lower = ::to_voxel(axis, bound.lower, system);
-        auto upper = ::to_voxel(axis, bound.upper, system);
+        auto lower = ::to_voxel(axis, bound.lower, system, "lower bound");
+        auto upper = ::to_voxel(axis, bound.upper, system, "upper bound");
 
         this->bounds.lower[ axis.dimension() ] = lower;
         this->bounds.upper[ axis.dimension() ] = upper + 1; // inclusive
     }
 }
 
+/*
+ * The bounds are inclusive, so a lower bound equal to the upper selects a
+ * single line, and only a lower bound above the upper selects nothing.
+ * Checked on the resolved indices, once the last bound of every axis has
+ * taken precedence and the slice is set, such that the error tells what was
+ * actually selected.
+ */
+void SubCube::require_nonempty(
+    MetadataHandle const& metadata
+) const noexcept (false) {
+    std::string selection;
+    std::string reason;
+    std::map< std::string, std::string > details;
+    std::vector< Axis > const axes {
+        metadata.iline(),
+        metadata.xline(),
+        metadata.sample()
+    };
+    for (auto const& axis : axes) {
+        int const lower = this->bounds.lower[axis.dimension()];
+        int const upper = this->bounds.upper[axis.dimension()] - 1;
+
+        if (not selection.empty()) selection += ", ";
+        selection += axis.name() + " " + ::resolved_range(lower, upper);
+
+        if (lower > upper and reason.empty()) {
+            reason = axis.name() + " index " + std::to_string(lower) +
+                     " of the lower bound is above index " +
+                     std::to_string(upper) + " of the upper bound";
+            details = {
+                { "axis",  axis.name()            },
+                { "lower", std::to_string(lower)  },
+                { "upper", std::to_string(upper)  },
+            };
+        }
+    }
+
+    if (not reason.empty()) {
+        throw detail::bad_request(
+            "empty_selection",
+            details,
+            "Bounds select no samples: " + reason +
+            ". Resolved index ranges (inclusive): " + selection
+        );
+    }
+}
+
 void SubCube::set_slice(
     Axis const&                  axis,
     int const                    lineno,
     enum coordinate_system const coordinate_system
 ) {
-    int voxelline = ::to_voxel(axis, lineno, coordinate_system);
+    int voxelline = ::to_voxel(axis, lineno, coordinate_system, "lineno");
 
     this->bounds.lower[axis.dimension()] = voxelline;
     this->bounds.upper[axis.dimension()] = voxelline + 1;
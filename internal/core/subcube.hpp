@@ -25,6 +25,9 @@ struct SubCube {
         MetadataHandle const& metadata,
         std::vector< Bound > const& bounds
     ) noexcept (false);
+
+    /* Throws bad_request if the subcube is empty along any axis */
+    void require_nonempty(MetadataHandle const& metadata) const noexcept (false);
 };
 
 #endif /* VDS_SLICE_SUBCUBE_HPP */
@@ -1,7 +1,9 @@
+#include <algorithm>
 #include <cmath>
 #include <stdexcept>
 
 #include "axis.hpp"
+#include "exceptions.hpp"
 #include "subvolume.hpp"
 
 #include <boost/math/interpolators/makima.hpp>
@@ -85,13 +87,13 @@ SurfaceBoundedSubVolume* make_subvolume(
             reference_depth < top_depth ||
             reference_depth > bottom_depth
         ) {
-            throw std::runtime_error(
+            throw detail::bad_request(
                 "Planes are not ordered as top <= reference <= bottom"
             );
         }
 
         auto const cdp = horizontal_grid.to_cdp(i);
-        auto ij = transform.WorldToAnnotation({cdp.x, cdp.y, 0});
+        auto ij = ::world_to_annotation(metadata, transform, cdp.x, cdp.y);
 
         if (not iline.inrange(ij[0]) or not xline.inrange(ij[1])) {
             subvolume->m_segment_offsets[i + 1] = subvolume->m_segment_offsets[i];
@@ -123,7 +125,26 @@ void SurfaceBoundedSubVolume::reinitialize(
     segment.reinitialize(m_ref[index], m_top[index], m_bottom[index]);
 }
 
-void resample(RawSegment const& src_segment, ResampledSegment& dst_segment) {
+namespace {
+
+/**
+ * Index of the source point at or right after position, such that position
+ * lies between the points at index - 1 and index. Positions outside of the
+ * source points are clamped to the first or last interval.
+ */
+std::size_t upper_index(std::vector<double> const& points, double position) {
+    auto it = std::upper_bound(points.begin(), points.end(), position);
+    std::size_t index = std::distance(points.begin(), it);
+    return std::min(std::max(index, std::size_t(1)), points.size() - 1);
+}
+
+} // namespace
+
+void resample(
+    RawSegment const& src_segment,
+    ResampledSegment& dst_segment,
+    enum interpolation_method interpolation
+) {
     /**
      * Interpolation and attribute calculation should be performed on
      * doubles to avoid loss of precision in these intermediate steps.
@@ -140,11 +161,66 @@ void resample(RawSegment const& src_segment, ResampledSegment& dst_segment) {
 
     std::vector<double> src_data(src_segment.begin(), src_segment.end());
 
-    auto spline = makima<std::vector<double>>(std::move(src_points), std::move(src_data));
+    return resample(
+        std::move(src_points),
+        std::move(src_data),
+        dst_points,
+        interpolation,
+        dst_segment.begin()
+    );
+}
 
-    auto dst = dst_segment.begin();
-    for (int j = 0; j < dst_points.size(); ++j) {
-        *dst = spline(dst_points.at(j));
-        std::advance(dst, 1);
+void resample(
+    std::vector<double> src_points,
+    std::vector<double> src_data,
+    std::vector<double> const& dst_points,
+    enum interpolation_method interpolation,
+    std::vector<double>::iterator dst
+) {
+    switch (interpolation) {
+        case CUBIC: {
+            auto spline = makima<std::vector<double>>(std::move(src_points), std::move(src_data));
+
+            for (int j = 0; j < dst_points.size(); ++j) {
+                *dst = spline(dst_points.at(j));
+                std::advance(dst, 1);
+            }
+            return;
+        }
+        case LINEAR: {
+            for (int j = 0; j < dst_points.size(); ++j) {
+                double const position = dst_points.at(j);
+                if (src_points.size() == 1) {
+                    *dst = src_data.front();
+                } else {
+                    std::size_t const hi = upper_index(src_points, position);
+                    std::size_t const lo = hi - 1;
+                    double const weight =
+                        (position - src_points[lo]) / (src_points[hi] - src_points[lo]);
+                    *dst = src_data[lo] + weight * (src_data[hi] - src_data[lo]);
+                }
+                std::advance(dst, 1);
+            }
+            return;
+        }
+        case NEAREST: {
+            for (int j = 0; j < dst_points.size(); ++j) {
+                double const position = dst_points.at(j);
+                if (src_points.size() == 1) {
+                    *dst = src_data.front();
+                } else {
+                    std::size_t const hi = upper_index(src_points, position);
+                    std::size_t const lo = hi - 1;
+                    /* Ties are rounded half up, like the horizontal nearest */
+                    bool const closer_to_hi =
+                        src_points[hi] - position <= position - src_points[lo];
+                    *dst = src_data[closer_to_hi ? hi : lo];
+                }
+                std::advance(dst, 1);
+            }
+            return;
+        }
+        default:
+            throw std::runtime_error("Unhandled vertical interpolation method");
     }
 }
@@ -6,6 +6,8 @@
 #include <stdexcept>
 #include <vector>
 
+#include "ctypes.h"
+#include "exceptions.hpp"
 #include "metadatahandle.hpp"
 #include "regularsurface.hpp"
 
@@ -43,7 +45,7 @@ public:
     SegmentBlueprint(float stepsize, std::size_t margin)
         : m_stepsize(stepsize), m_margin(margin) {
         if (stepsize <= 0) {
-            throw std::runtime_error("Stepsize must be positive");
+            throw detail::bad_request("Stepsize must be positive");
         }
     }
 
@@ -393,6 +395,16 @@ public:
         return m_segment_offsets[index] == m_segment_offsets[index + 1];
     }
 
+    /**
+     * Whether any of the reference, top and bottom surface is missing at
+     * index. Such segments are empty, as are those outside of the survey.
+     */
+    bool is_surface_missing(std::size_t index) const {
+        return m_ref[index] == m_ref.fillvalue() ||
+               m_top[index] == m_top.fillvalue() ||
+               m_bottom[index] == m_bottom.fillvalue();
+    }
+
     float* data(std::size_t from_segment) noexcept {
         return this->m_data.data() + m_segment_offsets[from_segment];
     }
@@ -453,7 +465,28 @@ SurfaceBoundedSubVolume* make_subvolume(
 
 /**
  * Resamples source segment into destination.
+ *
+ * Supported interpolation methods are nearest, linear and cubic, where cubic
+ * is modified makima.
  */
-void resample(RawSegment const& src_segment, ResampledSegment& dst_segment);
+void resample(
+    RawSegment const& src_segment,
+    ResampledSegment& dst_segment,
+    enum interpolation_method interpolation
+);
+
+/**
+ * Resamples src_data, sampled at src_points, at dst_points, and writes the
+ * result to dst. The source points must be sorted.
+ *
+ * Supports the same interpolation methods as resampling of segments.
+ */
+void resample(
+    std::vector<double> src_points,
+    std::vector<double> src_data,
+    std::vector<double> const& dst_points,
+    enum interpolation_method interpolation,
+    std::vector<double>::iterator dst
+);
 
 #endif /* VDS_SLICE_SUBVOLUME_HPP */
@@ -0,0 +1,353 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+)
+
+/** Spellings of units found in VDS files, by their canonical name
+ *
+ * The canonical names are the ones OpenVDS itself uses for its known units.
+ * Spellings are matched case-insensitively, and with surrounding whitespace
+ * trimmed.
+ */
+var unitSpellings = []struct {
+	canonical string
+	spellings []string
+}{
+	{"ms", []string{"ms", "msec", "millisecond", "milliseconds"}},
+	{"s", []string{"s", "sec", "second", "seconds"}},
+	{"us", []string{"us", "usec", "microsecond", "microseconds"}},
+	{"m", []string{"m", "meter", "meters", "metre", "metres"}},
+	{"ft", []string{"ft", "foot", "feet"}},
+	{"usft", []string{"usft", "us survey foot", "us survey feet", "ftus"}},
+	{"m/s", []string{"m/s", "meters per second", "metres per second"}},
+	{"ft/s", []string{"ft/s", "feet per second"}},
+	{"usft/s", []string{"usft/s", "us survey feet per second"}},
+	{"unitless", []string{"unitless", "none"}},
+}
+
+/** The canonical name of a unit
+ *
+ * Units that are not recognized are returned as is, such that nothing is
+ * lost for units that are not in the table.
+ */
+func NormalizeUnit(raw string) string {
+	name := strings.ToLower(strings.TrimSpace(raw))
+	for _, unit := range unitSpellings {
+		for _, spelling := range unit.spellings {
+			if name == spelling {
+				return unit.canonical
+			}
+		}
+	}
+	return raw
+}
+
+/* Normalize the unit of an axis as given by the VDS, keeping the original */
+func (a *Axis) normalizeUnit() {
+	a.RawUnit = a.Unit
+	a.Unit = NormalizeUnit(a.Unit)
+}
+
+func normalizeMetadataUnits(buf []byte) ([]byte, error) {
+	var metadata Metadata
+	if err := json.Unmarshal(buf, &metadata); err != nil {
+		return nil, NewInternalError(err.Error())
+	}
+
+	for _, axis := range metadata.Axis {
+		axis.normalizeUnit()
+	}
+
+	out, err := json.Marshal(metadata)
+	if err != nil {
+		return nil, NewInternalError(err.Error())
+	}
+	return out, nil
+}
+
+func normalizeSliceMetadataUnits(buf []byte) ([]byte, error) {
+	var metadata SliceMetadata
+	if err := json.Unmarshal(buf, &metadata); err != nil {
+		return nil, NewInternalError(err.Error())
+	}
+
+	metadata.X.normalizeUnit()
+	metadata.Y.normalizeUnit()
+
+	out, err := json.Marshal(metadata)
+	if err != nil {
+		return nil, NewInternalError(err.Error())
+	}
+	return out, nil
+}
+
+/** A unit of the vertical axis
+ *
+ * The scale is the size of the unit in the base unit of its quantity, which
+ * is milliseconds for time and meters for depth.
+ */
+type verticalUnit struct {
+	name     string
+	quantity string
+	scale    float64
+}
+
+/** Units of the vertical axis that can be converted between
+ *
+ * Values convert between units of the same quantity by the ratio of their
+ * scales. Time and depth can not be converted between.
+ *
+ *     quantity | unit | scale
+ *     ---------+------+-------------------------------
+ *     time     | ms   | 1
+ *     time     | s    | 1000
+ *     depth    | m    | 1
+ *     depth    | ft   | 0.3048
+ *     depth    | usft | 1200 / 3937 (US survey foot)
+ *
+ * usft is only understood as the unit of a cube, it can not be requested.
+ */
+var verticalUnits = []verticalUnit{
+	{"ms", "time", 1},
+	{"s", "time", 1000},
+	{"m", "depth", 1},
+	{"ft", "depth", 0.3048},
+	{"usft", "depth", 1200.0 / 3937.0},
+}
+
+var requestableVerticalUnits = []string{"ms", "s", "m", "ft"}
+
+func lookupVerticalUnit(name string) (verticalUnit, bool) {
+	for _, unit := range verticalUnits {
+		if unit.name == strings.ToLower(name) {
+			return unit, true
+		}
+	}
+	return verticalUnit{}, false
+}
+
+// Valid options for the verticalUnit of requests
+func VerticalUnits() []string {
+	return requestableVerticalUnits
+}
+
+/** Conversion between the vertical unit of a cube and a requested unit
+ *
+ * The zero value is not usable, get one from NewVerticalUnitConversion or
+ * DSHandle.VerticalUnitConversion.
+ */
+type VerticalUnitConversion struct {
+	native    string
+	requested string
+	/* Value in the native unit = value in the requested unit * factor */
+	factor float64
+}
+
+/** Make the conversion from native, the unit of the cube, to requested
+ *
+ * An empty requested unit means no conversion. Conversion between time and
+ * depth, or from a cube with a unit that is not understood, is an error.
+ */
+func NewVerticalUnitConversion(
+	native string,
+	requested string,
+) (VerticalUnitConversion, error) {
+	if requested == "" {
+		return VerticalUnitConversion{native, native, 1}, nil
+	}
+
+	to, ok := lookupVerticalUnit(requested)
+	if !ok || to.name == "usft" {
+		msg := "invalid vertical unit '%s', valid options are: %s"
+		return VerticalUnitConversion{}, NewCodedInvalidArgument(
+			"invalid_vertical_unit",
+			ErrorDetails{"verticalUnit": requested},
+			fmt.Sprintf(msg, requested, enumerate(VerticalUnits())),
+		)
+	}
+
+	from, ok := lookupVerticalUnit(native)
+	if !ok {
+		if strings.EqualFold(native, to.name) {
+			return VerticalUnitConversion{native, native, 1}, nil
+		}
+		msg := "cannot convert vertical axis from '%s' to '%s': " +
+			"the unit of the cube is not understood"
+		return VerticalUnitConversion{}, NewCodedInvalidArgument(
+			"vertical_unit_mismatch",
+			ErrorDetails{"from": native, "to": to.name},
+			fmt.Sprintf(msg, native, to.name),
+		)
+	}
+
+	if from.quantity != to.quantity {
+		msg := "cannot convert vertical axis from '%s' to '%s': " +
+			"%s can not be converted to %s"
+		return VerticalUnitConversion{}, NewCodedInvalidArgument(
+			"vertical_unit_mismatch",
+			ErrorDetails{"from": from.name, "to": to.name},
+			fmt.Sprintf(msg, from.name, to.name, from.quantity, to.quantity),
+		)
+	}
+
+	return VerticalUnitConversion{from.name, to.name, to.scale / from.scale}, nil
+}
+
+/** The requested unit, which converted values are given in */
+func (c VerticalUnitConversion) Unit() string {
+	return c.requested
+}
+
+/** Whether the conversion changes any values */
+func (c VerticalUnitConversion) IsIdentity() bool {
+	return c.factor == 1
+}
+
+/** Convert a value in the requested unit to the unit of the cube */
+func (c VerticalUnitConversion) ToNative(value float32) float32 {
+	return float32(float64(value) * c.factor)
+}
+
+/** Convert a value in the unit of the cube to the requested unit */
+func (c VerticalUnitConversion) FromNative(value float64) float64 {
+	return value / c.factor
+}
+
+/** Convert a whole-numbered request parameter to the unit of the cube
+ *
+ * Linenos and bounds are integers, so the converted value must be a whole
+ * number too. The name of the parameter is used in the error message.
+ */
+func (c VerticalUnitConversion) ToNativeInt(name string, value int) (int, error) {
+	converted := float64(value) * c.factor
+	rounded := math.Round(converted)
+	if math.Abs(converted-rounded) > 1e-6*math.Max(1, math.Abs(converted)) {
+		msg := "%s %d %s is %g %s, which is not a whole number in the " +
+			"unit of the cube"
+		return 0, NewInvalidArgument(fmt.Sprintf(
+			msg,
+			name,
+			value,
+			c.requested,
+			converted,
+			c.native,
+		))
+	}
+	return int(rounded), nil
+}
+
+/** Report the axis in the requested unit
+ *
+ * The values no longer are in the unit of the VDS, so the raw unit is the
+ * requested one too.
+ */
+func (c VerticalUnitConversion) ConvertAxis(axis *Axis) {
+	axis.Min = c.FromNative(axis.Min)
+	axis.Max = c.FromNative(axis.Max)
+	axis.StepSize = c.FromNative(axis.StepSize)
+	axis.Unit = c.requested
+	axis.RawUnit = c.requested
+}
+
+/** Whether an axis, by its annotation, is the vertical axis */
+func IsVerticalAxis(axis Axis) bool {
+	for _, name := range []string{"sample", "time", "depth"} {
+		if strings.EqualFold(axis.Annotation, name) {
+			return true
+		}
+	}
+	return false
+}
+
+/** Report the vertical axis of slice metadata in the requested unit
+ *
+ * Slices along the vertical axis have no vertical axis in their metadata,
+ * in which case it is returned as is.
+ */
+func (c VerticalUnitConversion) ConvertSliceMetadata(buf []byte) ([]byte, error) {
+	if c.IsIdentity() && c.native == c.requested {
+		return buf, nil
+	}
+
+	var metadata SliceMetadata
+	if err := json.Unmarshal(buf, &metadata); err != nil {
+		return nil, NewInternalError(err.Error())
+	}
+
+	converted := false
+	for _, axis := range []*Axis{&metadata.X, &metadata.Y} {
+		if IsVerticalAxis(*axis) {
+			c.ConvertAxis(axis)
+			converted = true
+		}
+	}
+	if !converted {
+		return buf, nil
+	}
+
+	out, err := json.Marshal(metadata)
+	if err != nil {
+		return nil, NewInternalError(err.Error())
+	}
+	return out, nil
+}
+
+/** Copy of the surface with its values in the unit of the cube
+ *
+ * Values equal to the fill value of the surface are not converted.
+ */
+func (c VerticalUnitConversion) SurfaceToNative(surface RegularSurface) RegularSurface {
+	if c.IsIdentity() {
+		return surface
+	}
+
+	values := make([][]float32, len(surface.Values))
+	for i, row := range surface.Values {
+		values[i] = make([]float32, len(row))
+		for j, value := range row {
+			if surface.FillValue != nil && value == *surface.FillValue {
+				values[i][j] = value
+				continue
+			}
+			values[i][j] = c.ToNative(value)
+		}
+	}
+	surface.Values = values
+	return surface
+}
+
+/** Conversion from the vertical unit of the cube to the requested one
+ *
+ * The metadata is only read if a unit is requested.
+ */
+func (v VdsHandle) VerticalUnitConversion(
+	requested string,
+) (VerticalUnitConversion, error) {
+	if requested == "" {
+		return NewVerticalUnitConversion("", "")
+	}
+
+	buf, err := v.GetMetadata(false, false)
+	if err != nil {
+		return VerticalUnitConversion{}, err
+	}
+
+	var metadata Metadata
+	if err := json.Unmarshal(buf, &metadata); err != nil {
+		return VerticalUnitConversion{}, NewInternalError(err.Error())
+	}
+	/* 2D lines have no inline axis, but the vertical axis is always last */
+	naxes := len(metadata.Axis)
+	if naxes != 2 && naxes != 3 {
+		return VerticalUnitConversion{}, NewInternalError(fmt.Sprintf(
+			"expected 2 or 3 axes in metadata, got %d",
+			naxes,
+		))
+	}
+
+	return NewVerticalUnitConversion(metadata.Axis[naxes-1].Unit, requested)
+}
@@ -0,0 +1,205 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalizeUnit(t *testing.T) {
+	testcases := []struct {
+		raw      string
+		expected string
+	}{
+		{raw: "ms", expected: "ms"},
+		{raw: "Milliseconds", expected: "ms"},
+		{raw: "msec", expected: "ms"},
+		{raw: " MS ", expected: "ms"},
+		{raw: "s", expected: "s"},
+		{raw: "Seconds", expected: "s"},
+		{raw: "us", expected: "us"},
+		{raw: "Microseconds", expected: "us"},
+		{raw: "m", expected: "m"},
+		{raw: "meter", expected: "m"},
+		{raw: "Metres", expected: "m"},
+		{raw: "ft", expected: "ft"},
+		{raw: "Feet", expected: "ft"},
+		{raw: "foot", expected: "ft"},
+		{raw: "usft", expected: "usft"},
+		{raw: "US Survey Foot", expected: "usft"},
+		{raw: "m/s", expected: "m/s"},
+		{raw: "Meters per second", expected: "m/s"},
+		{raw: "ft/s", expected: "ft/s"},
+		{raw: "usft/s", expected: "usft/s"},
+		{raw: "unitless", expected: "unitless"},
+		{raw: "None", expected: "unitless"},
+		{raw: "furlongs", expected: "furlongs"},
+		{raw: "", expected: ""},
+	}
+
+	for _, testcase := range testcases {
+		require.Equal(t,
+			testcase.expected,
+			NormalizeUnit(testcase.raw),
+			"raw unit '%s'",
+			testcase.raw,
+		)
+	}
+}
+
+func TestNormalizeAxisUnit(t *testing.T) {
+	axis := Axis{Annotation: "Sample", Unit: "Milliseconds"}
+	axis.normalizeUnit()
+	require.Equal(t, "ms", axis.Unit)
+	require.Equal(t, "Milliseconds", axis.RawUnit)
+
+	axis = Axis{Annotation: "Sample", Unit: "furlongs"}
+	axis.normalizeUnit()
+	require.Equal(t, "furlongs", axis.Unit)
+	require.Equal(t, "furlongs", axis.RawUnit)
+}
+
+func TestVerticalUnitConversion(t *testing.T) {
+	testcases := []struct {
+		native    string
+		requested string
+		/* Value in the native unit of 1 in the requested unit */
+		expected float64
+	}{
+		{native: "ms", requested: "ms", expected: 1},
+		{native: "ms", requested: "s", expected: 1000},
+		{native: "s", requested: "ms", expected: 0.001},
+		{native: "s", requested: "s", expected: 1},
+		{native: "m", requested: "m", expected: 1},
+		{native: "m", requested: "ft", expected: 0.3048},
+		{native: "ft", requested: "m", expected: 1 / 0.3048},
+		{native: "ft", requested: "ft", expected: 1},
+		{native: "usft", requested: "m", expected: 3937.0 / 1200.0},
+		{native: "usft", requested: "ft", expected: 0.3048 * 3937.0 / 1200.0},
+		{native: "ms", requested: "", expected: 1},
+		{native: "m", requested: "", expected: 1},
+	}
+
+	for _, testcase := range testcases {
+		name := fmt.Sprintf("%s to '%s'", testcase.native, testcase.requested)
+		conversion, err := NewVerticalUnitConversion(
+			testcase.native,
+			testcase.requested,
+		)
+		require.NoError(t, err, name)
+		require.InDelta(t,
+			testcase.expected,
+			conversion.ToNative(1),
+			1e-6*testcase.expected,
+			name,
+		)
+		require.InDelta(t, 1, conversion.FromNative(testcase.expected), 1e-6, name)
+	}
+}
+
+func TestVerticalUnitConversionErrors(t *testing.T) {
+	testcases := []struct {
+		native    string
+		requested string
+		expected  string
+	}{
+		{
+			native:    "ms",
+			requested: "m",
+			expected: "cannot convert vertical axis from 'ms' to 'm': " +
+				"time can not be converted to depth",
+		},
+		{
+			native:    "s",
+			requested: "ft",
+			expected: "cannot convert vertical axis from 's' to 'ft': " +
+				"time can not be converted to depth",
+		},
+		{
+			native:    "m",
+			requested: "ms",
+			expected: "cannot convert vertical axis from 'm' to 'ms': " +
+				"depth can not be converted to time",
+		},
+		{
+			native:    "ft",
+			requested: "s",
+			expected: "cannot convert vertical axis from 'ft' to 's': " +
+				"depth can not be converted to time",
+		},
+		{
+			native:    "unitless",
+			requested: "ms",
+			expected: "cannot convert vertical axis from 'unitless' to 'ms': " +
+				"the unit of the cube is not understood",
+		},
+		{
+			native:    "ms",
+			requested: "hours",
+			expected: "invalid vertical unit 'hours', valid options are: " +
+				"ms, s, m or ft",
+		},
+		{
+			native:    "usft",
+			requested: "usft",
+			expected: "invalid vertical unit 'usft', valid options are: " +
+				"ms, s, m or ft",
+		},
+	}
+
+	for _, testcase := range testcases {
+		_, err := NewVerticalUnitConversion(testcase.native, testcase.requested)
+		require.IsType(t, &InvalidArgument{}, err)
+		require.Equal(t, testcase.expected, err.Error())
+	}
+}
+
+func TestVerticalUnitToNativeInt(t *testing.T) {
+	conversion, err := NewVerticalUnitConversion("ms", "s")
+	require.NoError(t, err)
+
+	value, err := conversion.ToNativeInt("lineno", 2)
+	require.NoError(t, err)
+	require.Equal(t, 2000, value)
+
+	conversion, err = NewVerticalUnitConversion("s", "ms")
+	require.NoError(t, err)
+
+	_, err = conversion.ToNativeInt("lineno", 4)
+	require.IsType(t, &InvalidArgument{}, err)
+	require.Equal(t,
+		"lineno 4 ms is 0.004 s, which is not a whole number in the unit of the cube",
+		err.Error(),
+	)
+}
+
+func TestSliceMetadataVerticalUnit(t *testing.T) {
+	handle, _ := NewDSHandle(well_known)
+	defer handle.Close()
+
+	conversion, err := handle.VerticalUnitConversion("s")
+	require.NoError(t, err)
+
+	buf, err := handle.GetSliceMetadata(1, AxisJ, CoordinateSystemIndex, []Bound{}, nil)
+	require.NoError(t, err)
+
+	buf, err = conversion.ConvertSliceMetadata(buf)
+	require.NoError(t, err)
+
+	var meta SliceMetadata
+	err = json.Unmarshal(buf, &meta)
+	require.NoError(t, err)
+
+	require.Equal(t, "s", meta.X.Unit)
+	require.Equal(t, "s", meta.X.RawUnit)
+	require.InDelta(t, 0.004, meta.X.Min, 1e-9)
+	require.InDelta(t, 0.016, meta.X.Max, 1e-9)
+	require.InDelta(t, 0.004, meta.X.StepSize, 1e-9)
+	require.Equal(t, 4, meta.X.Samples)
+	require.Equal(t, "unitless", meta.Y.Unit)
+
+	_, err = handle.VerticalUnitConversion("m")
+	require.IsType(t, &InvalidArgument{}, err)
+}
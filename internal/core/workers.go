@@ -0,0 +1,181 @@
+package core
+
+import (
+	"runtime"
+	"sync"
+)
+
+/** Receives notifications about the worker pool, e.g. for metrics
+ *
+ * The notifications are made with the pool locked, such that they arrive in
+ * the order the changes were made.
+ */
+type WorkerPoolObserver interface {
+	/* The number of busy workers, and the size of the pool */
+	WorkersBusy(busy int, workers int)
+
+	/* The number of tasks waiting for a worker */
+	TasksQueued(tasks int)
+}
+
+/** A pool of workers shared by the attribute computations of all requests
+ *
+ * Requests hand their work over as a list of tasks, and the workers take
+ * tasks from the requests in turn, one at a time, such that a small request
+ * is not queued behind every task of a large one. The tasks of a request are
+ * started in the order they are given.
+ *
+ * A nil pool runs the tasks of every request on goroutines of their own, all
+ * at once, which is how attributes were computed before the pool.
+ */
+type WorkerPool struct {
+	Observer WorkerPoolObserver
+
+	lock    sync.Mutex
+	ready   *sync.Cond
+	workers int
+	busy    int
+	queued  int
+	/* Requests with tasks that are not yet started, in the order they are served */
+	requests []*poolRequest
+}
+
+type poolRequest struct {
+	tasks []func() error
+	errs  []error
+	next  int
+	done  sync.WaitGroup
+}
+
+/** A pool of workers, as many as there are CPUs if workers is zero
+ *
+ * The workers live as long as the process.
+ */
+func NewWorkerPool(workers int, observer WorkerPoolObserver) *WorkerPool {
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	pool := &WorkerPool{Observer: observer, workers: workers}
+	pool.ready = sync.NewCond(&pool.lock)
+	for i := 0; i < workers; i++ {
+		go pool.work()
+	}
+	return pool
+}
+
+/** The number of workers of the pool */
+func (p *WorkerPool) Workers() int {
+	return p.workers
+}
+
+/** Run the tasks, and wait for all of them to complete
+ *
+ * The error is that of the first task that failed, in the order the tasks
+ * are given, regardless of the order they complete in.
+ */
+func (p *WorkerPool) Run(tasks []func() error) error {
+	errs := make([]error, len(tasks))
+	if len(tasks) == 0 {
+		return nil
+	}
+
+	if p == nil {
+		var done sync.WaitGroup
+		done.Add(len(tasks))
+		for i, task := range tasks {
+			go func(i int, task func() error) {
+				defer done.Done()
+				errs[i] = task()
+			}(i, task)
+		}
+		done.Wait()
+		return firstError(errs)
+	}
+
+	request := &poolRequest{tasks: tasks, errs: errs}
+	request.done.Add(len(tasks))
+
+	p.lock.Lock()
+	p.requests = append(p.requests, request)
+	p.queued += len(tasks)
+	p.observeQueued()
+	p.lock.Unlock()
+	p.ready.Broadcast()
+
+	request.done.Wait()
+	return firstError(errs)
+}
+
+func (p *WorkerPool) work() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	for {
+		for len(p.requests) == 0 {
+			p.ready.Wait()
+		}
+
+		/* The request goes to the back of the line, unless this is its last task */
+		request := p.requests[0]
+		index := request.next
+		request.next++
+		p.requests = p.requests[1:]
+		if request.next < len(request.tasks) {
+			p.requests = append(p.requests, request)
+		}
+
+		p.queued--
+		p.busy++
+		p.observeQueued()
+		p.observeBusy()
+		p.lock.Unlock()
+
+		request.errs[index] = request.tasks[index]()
+		request.done.Done()
+
+		p.lock.Lock()
+		p.busy--
+		p.observeBusy()
+	}
+}
+
+func (p *WorkerPool) observeQueued() {
+	if p.Observer != nil {
+		p.Observer.TasksQueued(p.queued)
+	}
+}
+
+func (p *WorkerPool) observeBusy() {
+	if p.Observer != nil {
+		p.Observer.WorkersBusy(p.busy, p.workers)
+	}
+}
+
+/* The smallest and largest number of surface nodes of a task */
+const (
+	minNodesPerTask = 64
+	maxNodesPerTask = 1024
+)
+
+/** The number of surface nodes per task of an attribute computation
+ *
+ * Enough tasks to keep every worker busy, but small enough that the tasks of
+ * other requests are not held back for long. Without a pool the nodes are
+ * split in 32 parts.
+ */
+func (p *WorkerPool) nodesPerTask(nodes int) int {
+	if p == nil {
+		return (nodes + 31) / 32
+	}
+	perWorker := (nodes + p.workers - 1) / p.workers
+	return min(max(perWorker, minNodesPerTask), maxNodesPerTask)
+}
+
+func firstError(errs []error) error {
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+package core
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type poolObserver struct {
+	lock    sync.Mutex
+	maxBusy int
+	workers int
+	queued  []int
+}
+
+func (o *poolObserver) WorkersBusy(busy int, workers int) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	o.maxBusy = max(o.maxBusy, busy)
+	o.workers = workers
+}
+
+func (o *poolObserver) TasksQueued(tasks int) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	o.queued = append(o.queued, tasks)
+}
+
+func TestWorkerPoolFirstErrorInOrder(t *testing.T) {
+	for _, pool := range []*WorkerPool{nil, NewWorkerPool(2, nil)} {
+		tasks := make([]func() error, 10)
+		for i := range tasks {
+			i := i
+			tasks[i] = func() error {
+				if i == 3 || i == 7 {
+					return fmt.Errorf("task %d", i)
+				}
+				return nil
+			}
+		}
+		require.EqualError(t, pool.Run(tasks), "task 3")
+		require.NoError(t, pool.Run(nil))
+	}
+}
+
+func TestWorkerPoolRunsEveryTask(t *testing.T) {
+	observer := &poolObserver{}
+	pool := NewWorkerPool(3, observer)
+
+	results := make([]int, 100)
+	tasks := make([]func() error, len(results))
+	for i := range tasks {
+		i := i
+		tasks[i] = func() error {
+			results[i] = i * i
+			return nil
+		}
+	}
+	require.NoError(t, pool.Run(tasks))
+
+	for i, result := range results {
+		require.Equal(t, i*i, result)
+	}
+
+	observer.lock.Lock()
+	defer observer.lock.Unlock()
+	require.Equal(t, 3, observer.workers)
+	require.LessOrEqual(t, observer.maxBusy, 3)
+	require.Equal(t, 100, observer.queued[0])
+	require.Equal(t, 0, observer.queued[len(observer.queued)-1])
+}
+
+/*
+ * With a single worker held up by the large request, the small request that
+ * arrives later gets the next turn rather than waiting for every remaining
+ * task of the large one.
+ */
+func TestWorkerPoolTakesTurns(t *testing.T) {
+	pool := NewWorkerPool(1, nil)
+
+	var lock sync.Mutex
+	var order []string
+	record := func(name string) func() error {
+		return func() error {
+			lock.Lock()
+			defer lock.Unlock()
+			order = append(order, name)
+			return nil
+		}
+	}
+
+	started := make(chan struct{})
+	proceed := make(chan struct{})
+	large := []func() error{
+		func() error {
+			close(started)
+			<-proceed
+			return record("large")()
+		},
+		record("large"),
+		record("large"),
+		record("large"),
+	}
+
+	done := make(chan error)
+	go func() { done <- pool.Run(large) }()
+	<-started
+
+	go func() { done <- pool.Run([]func() error{record("small")}) }()
+	for {
+		pool.lock.Lock()
+		queued := pool.queued
+		pool.lock.Unlock()
+		if queued == 4 {
+			break
+		}
+	}
+	close(proceed)
+
+	require.NoError(t, <-done)
+	require.NoError(t, <-done)
+	require.Equal(t, []string{"large", "large", "small", "large", "large"}, order)
+}
+
+func TestNodesPerTask(t *testing.T) {
+	var pool *WorkerPool
+	require.Equal(t, 4, pool.nodesPerTask(100))
+	require.Equal(t, 1, pool.nodesPerTask(1))
+
+	pool = &WorkerPool{workers: 8}
+	require.Equal(t, minNodesPerTask, pool.nodesPerTask(100))
+	require.Equal(t, 250, pool.nodesPerTask(2000))
+	require.Equal(t, maxNodesPerTask, pool.nodesPerTask(1000000))
+}
@@ -6,6 +6,8 @@ import (
 	"strings"
 
 	"github.com/gin-gonic/gin"
+
+	"github.com/equinor/vds-slice/internal/clientip"
 )
 
 func FormattedLogger() gin.HandlerFunc {
@@ -27,6 +29,11 @@ func FormattedLogger() gin.HandlerFunc {
 		 */
 		path := strings.Split(param.Path, "?")[0]
 
+		/* Prefer the IP resolved with regard to the trusted proxies */
+		if ip, ok := param.Keys[clientip.Key].(string); ok && ip != "" {
+			param.ClientIP = ip
+		}
+
 		request := param.Keys["request"]
 		if request == nil {
 			request = ""
@@ -0,0 +1,108 @@
+package logging
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/equinor/vds-slice/internal/clientip"
+)
+
+/** Lets through at most limit events per minute
+ *
+ * The minutes are fixed windows. Events that are not let through are counted,
+ * such that the next event that is can tell how many were dropped.
+ */
+type sampler struct {
+	limit int
+	now   func() time.Time
+
+	lock       sync.Mutex
+	window     time.Time
+	count      int
+	suppressed int
+}
+
+/* Check if an event is let through, and how many were dropped before it */
+func (s *sampler) allow() (bool, int) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	window := s.now().Truncate(time.Minute)
+	if window.After(s.window) {
+		s.window = window
+		s.count = 0
+	}
+
+	if s.count >= s.limit {
+		s.suppressed++
+		return false, 0
+	}
+	s.count++
+
+	suppressed := s.suppressed
+	s.suppressed = 0
+	return true, suppressed
+}
+
+/** Log requests that take longer than threshold
+ *
+ * Slow requests get a dedicated slow_request record, in addition to the
+ * regular access log line, with the request as set by prepareRequestLogging,
+ * which is stripped of the sas, and the work done against storage, as set by
+ * the endpoint. At most perMinute records are written per
+ * minute, such that an incident where every request is slow does not flood
+ * the log. The number of slow requests that were left out is given in the
+ * next record.
+ */
+func NewSlowRequestLogger(threshold time.Duration, perMinute int) gin.HandlerFunc {
+	sampler := &sampler{limit: perMinute, now: time.Now}
+
+	return func(ctx *gin.Context) {
+		start := time.Now()
+		ctx.Next()
+
+		latency := time.Since(start)
+		if latency < threshold {
+			return
+		}
+
+		ok, suppressed := sampler.allow()
+		if !ok {
+			return
+		}
+
+		ip := ctx.ClientIP()
+		if resolved := ctx.GetString(clientip.Key); resolved != "" {
+			ip = resolved
+		}
+
+		size := ctx.Writer.Size()
+		if size < 0 {
+			size = 0
+		}
+
+		fmt.Fprintf(gin.DefaultWriter,
+			"[SLOW] %v | slow_request | %3d | %13v | %15s | %-7s %#v | "+
+				"size: %d | cache-hit: %t | storage-requests: %d | "+
+				"storage-bytes: %d | storage-read: %v | suppressed: %d\n"+
+				"request: %s\n",
+			start.Format(time.RFC1123),
+			ctx.Writer.Status(),
+			latency.Truncate(time.Millisecond),
+			ip,
+			ctx.Request.Method,
+			/* Without the query, which may hold the sas */
+			ctx.Request.URL.Path,
+			size,
+			ctx.GetBool("cache-hit"),
+			ctx.GetInt64("storage-requests"),
+			ctx.GetInt64("storage-bytes"),
+			ctx.GetDuration("storage-read-time").Truncate(time.Millisecond),
+			suppressed,
+			ctx.GetString("request"),
+		)
+	}
+}
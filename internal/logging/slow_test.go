@@ -0,0 +1,80 @@
+package logging
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSampler(t *testing.T) {
+	now := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+	s := &sampler{limit: 2, now: func() time.Time { return now }}
+
+	for i := 0; i < 2; i++ {
+		ok, suppressed := s.allow()
+		require.True(t, ok)
+		require.Equal(t, 0, suppressed)
+	}
+	for i := 0; i < 3; i++ {
+		ok, _ := s.allow()
+		require.False(t, ok, "Expected the limit to be reached")
+	}
+
+	now = now.Add(time.Minute)
+	ok, suppressed := s.allow()
+	require.True(t, ok, "Expected a new minute to reset the limit")
+	require.Equal(t, 3, suppressed)
+
+	ok, suppressed = s.allow()
+	require.True(t, ok)
+	require.Equal(t, 0, suppressed)
+}
+
+func serveSlow(t *testing.T, threshold time.Duration, target string) string {
+	out := &bytes.Buffer{}
+	defaultWriter := gin.DefaultWriter
+	gin.DefaultWriter = out
+	defer func() { gin.DefaultWriter = defaultWriter }()
+
+	gin.SetMode(gin.TestMode)
+	app := gin.New()
+	app.Use(NewSlowRequestLogger(threshold, 10))
+	app.GET("/slice", func(ctx *gin.Context) {
+		ctx.Set("request", `{"vds":"https://account.blob.core.windows.net/c/b"}`)
+		ctx.Set("storage-requests", int64(3))
+		ctx.Set("storage-bytes", int64(3*1024*1024))
+		ctx.Set("storage-read-time", 2500*time.Microsecond)
+		time.Sleep(5 * time.Millisecond)
+		ctx.String(http.StatusOK, "data")
+	})
+
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	return out.String()
+}
+
+func TestSlowRequestLogged(t *testing.T) {
+	record := serveSlow(t, time.Millisecond, "/slice?sas=sig%3Dsecret")
+
+	require.Contains(t, record, "| slow_request | 200 |")
+	require.Contains(t, record, `GET     "/slice"`)
+	require.Contains(t, record, "size: 4 | cache-hit: false | "+
+		"storage-requests: 3 | storage-bytes: 3145728 | storage-read: 2ms | "+
+		"suppressed: 0",
+	)
+	require.Contains(t, record,
+		`request: {"vds":"https://account.blob.core.windows.net/c/b"}`,
+	)
+	require.NotContains(t, record, "secret")
+}
+
+func TestFastRequestNotLogged(t *testing.T) {
+	record := serveSlow(t, time.Minute, "/slice")
+	require.Empty(t, record)
+}
@@ -2,12 +2,21 @@ package metrics
 
 
 import (
+	"context"
+	"regexp"
 	"time"
 	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
 )
 
 const (
@@ -18,13 +27,52 @@ const (
 	mb = 1024*kb
 )
 
+/*
+ * Native histograms, scraped by Prometheus 2.40 and later. Each bucket is at
+ * most 10% wider than the previous. The number of buckets is capped, such
+ * that the histograms are reset at most once an hour if requests are spread
+ * too thinly. The classic buckets are kept for older setups.
+ */
+const (
+	nativeHistogramBucketFactor     = 1.1
+	nativeHistogramMaxBucketNumber  = 100
+	nativeHistogramMinResetDuration = time.Hour
+)
+
 type Metrics struct {
 	registry *prometheus.Registry
 
 	// Custom metics
 	requestDurations *prometheus.HistogramVec
 	responseSizes    *prometheus.HistogramVec
+	storageBytes     *prometheus.CounterVec
+	storageRequests  *prometheus.CounterVec
+	storageReads     *prometheus.HistogramVec
 	requestCount     *prometheus.CounterVec
+	retriesAttempted prometheus.Counter
+	retriesExhausted prometheus.Counter
+	circuitState     *prometheus.GaugeVec
+	throttled        *prometheus.CounterVec
+	memoryReserved   prometheus.Gauge
+	memoryRejected   prometheus.Counter
+	staleServed      prometheus.Counter
+	deadlines        *prometheus.CounterVec
+	workers          prometheus.Gauge
+	workersBusy      prometheus.Gauge
+	tasksQueued      prometheus.Gauge
+
+	// Request size metrics
+	fenceCoordinates *prometheus.HistogramVec
+	surfacePoints    *prometheus.HistogramVec
+	samplePoints     *prometheus.HistogramVec
+	sliceSamples     *prometheus.HistogramVec
+	attributeWindow  *prometheus.HistogramVec
+	fenceBatches     *prometheus.HistogramVec
+}
+
+/* Coarse buckets of powers of ten, from 1 to 10^n */
+func powersOfTen(n int) []float64 {
+	return prometheus.ExponentialBuckets(1, 10, n+1)
 }
 
 /** Create a new metric instance
@@ -40,50 +88,567 @@ func NewMetrics() *Metrics {
 			Name:    "vdsslice_durations_histogram_seconds",
 			Help:    "VDSslice latency distributions.",
 			Buckets: []float64{100*ms, 500*ms, 1*s, 2*s, 5*s, 20*s, 1*m, 2*m},
-		}, []string{"path", "status", "cachehit"}),
+
+			NativeHistogramBucketFactor:     nativeHistogramBucketFactor,
+			NativeHistogramMaxBucketNumber:  nativeHistogramMaxBucketNumber,
+			NativeHistogramMinResetDuration: nativeHistogramMinResetDuration,
+		}, []string{"path", "version", "rpc", "status", "cachehit"}),
 
 		responseSizes: prometheus.NewHistogramVec(prometheus.HistogramOpts{
 			Name:    "vdsslice_response_sizes_histogram_bytes",
 			Help:    "VDSslice response size distributions.",
 			Buckets: []float64{100*kb, 1*mb, 5*mb, 10*mb, 20*mb, 50*mb, 100*mb, 200*mb},
-		}, []string{"path", "status"}),
+
+			NativeHistogramBucketFactor:     nativeHistogramBucketFactor,
+			NativeHistogramMaxBucketNumber:  nativeHistogramMaxBucketNumber,
+			NativeHistogramMinResetDuration: nativeHistogramMinResetDuration,
+		}, []string{"path", "version", "rpc", "status", "cachehit"}),
+
+		storageBytes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "vdsslice_storage_bytes",
+			Help: "VDSslice estimated number of bytes fetched from storage, " +
+				"i.e. the uncompressed size of the chunks that were read. " +
+				"Responses served from cache fetch nothing.",
+		}, []string{"path", "version", "rpc", "cachehit"}),
+
+		storageRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "vdsslice_storage_requests",
+			Help: "VDSslice estimated number of requests to storage, " +
+				"i.e. the number of chunks that were read. " +
+				"Responses served from cache request nothing.",
+		}, []string{"path", "version", "rpc", "cachehit"}),
+
+		storageReads: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name: "vdsslice_storage_read_seconds",
+			Help: "VDSslice time spent per request reading from storage, " +
+				"i.e. fetching and decompressing chunks in OpenVDS. " +
+				"Requests that read nothing are not observed.",
+			Buckets: []float64{10*ms, 50*ms, 100*ms, 500*ms, 1*s, 5*s, 20*s, 1*m},
+
+			NativeHistogramBucketFactor:     nativeHistogramBucketFactor,
+			NativeHistogramMaxBucketNumber:  nativeHistogramMaxBucketNumber,
+			NativeHistogramMinResetDuration: nativeHistogramMinResetDuration,
+		}, []string{"path", "version", "rpc"}),
 
 		requestCount: prometheus.NewCounterVec(prometheus.CounterOpts{
 			Name: "vdsslice_number_of_requests",
 			Help: "VDSslice number of requests.",
-		}, []string{"method", "path"}),
+		}, []string{"method", "path", "version", "rpc"}),
+
+		retriesAttempted: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "vdsslice_retries_attempted",
+			Help: "VDSslice number of retries after transient storage errors.",
+		}),
+
+		retriesExhausted: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "vdsslice_retries_exhausted",
+			Help: "VDSslice number of requests that failed after all retries.",
+		}),
+
+		circuitState: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "vdsslice_circuit_state",
+			Help: "VDSslice circuit breaker state per storage host. " +
+				"0 is closed, 1 is open and 2 is half-open.",
+		}, []string{"host"}),
+
+		throttled: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "vdsslice_throttled_requests",
+			Help: "VDSslice number of requests rejected by the rate limiter.",
+		}, []string{"keyclass"}),
+
+		memoryReserved: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "vdsslice_memory_reserved_bytes",
+			Help: "VDSslice bytes currently reserved for responses against the memory budget.",
+		}),
+
+		memoryRejected: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "vdsslice_memory_rejections",
+			Help: "VDSslice number of requests rejected as their response exceeded " +
+				"the available memory budget.",
+		}),
+
+		staleServed: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "vdsslice_stale_responses",
+			Help: "VDSslice number of responses served stale from the cache, " +
+				"as storage failed with a transient error.",
+		}),
+
+		deadlines: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "vdsslice_deadlines_exceeded",
+			Help: "VDSslice number of requests that failed as their deadline " +
+				"passed. The source is client for deadlines given by the " +
+				"client, and server for the request timeout of the server.",
+		}, []string{"path", "version", "rpc", "source"}),
+
+		workers: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "vdsslice_attribute_workers",
+			Help: "VDSslice number of workers computing attributes.",
+		}),
+
+		workersBusy: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "vdsslice_attribute_workers_busy",
+			Help: "VDSslice number of workers currently computing attributes. " +
+				"Divided by vdsslice_attribute_workers it is the utilization " +
+				"of the pool.",
+		}),
+
+		tasksQueued: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "vdsslice_attribute_tasks_queued",
+			Help: "VDSslice number of attribute tasks waiting for a worker, " +
+				"across all requests. Every task is a part of the surface.",
+		}),
+
+		fenceCoordinates: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name: "vdsslice_fence_coordinates",
+			Help: "VDSslice number of coordinates per successful fence request.",
+			Buckets: powersOfTen(6),
+		}, []string{"endpoint"}),
+
+		surfacePoints: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name: "vdsslice_surface_points",
+			Help: "VDSslice number of points of the surface per successful " +
+				"attribute request, i.e. the number of values per attribute. " +
+				"For attributes between surfaces, this is the primary surface.",
+			Buckets: powersOfTen(8),
+		}, []string{"endpoint"}),
+
+		samplePoints: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name: "vdsslice_sample_points",
+			Help: "VDSslice number of points per successful sample request.",
+			Buckets: powersOfTen(6),
+		}, []string{"endpoint"}),
+
+		sliceSamples: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name: "vdsslice_slice_samples",
+			Help: "VDSslice number of samples per successful slice request, " +
+				"i.e. the area of the slice after bounds are applied.",
+			Buckets: powersOfTen(9),
+		}, []string{"endpoint"}),
+
+		attributeWindow: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name: "vdsslice_attribute_window",
+			Help: "VDSslice length of the vertical window, above + below, per " +
+				"successful attribute along surface request. In the vertical " +
+				"unit of the request, e.g. ms or m.",
+			Buckets: powersOfTen(4),
+		}, []string{"endpoint"}),
+
+		fenceBatches: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name: "vdsslice_fence_batches",
+			Help: "VDSslice number of batches per successful batched fence " +
+				"request. Fences that are processed whole are not counted.",
+			Buckets: powersOfTen(3),
+		}, []string{"endpoint"}),
 	}
 
 	registry.MustRegister(metrics.requestDurations)
 	registry.MustRegister(metrics.responseSizes)
+	registry.MustRegister(metrics.storageBytes)
+	registry.MustRegister(metrics.storageRequests)
+	registry.MustRegister(metrics.storageReads)
 	registry.MustRegister(metrics.requestCount)
+	registry.MustRegister(metrics.retriesAttempted)
+	registry.MustRegister(metrics.retriesExhausted)
+	registry.MustRegister(metrics.circuitState)
+	registry.MustRegister(metrics.throttled)
+	registry.MustRegister(metrics.memoryReserved)
+	registry.MustRegister(metrics.memoryRejected)
+	registry.MustRegister(metrics.staleServed)
+	registry.MustRegister(metrics.deadlines)
+	registry.MustRegister(metrics.workers)
+	registry.MustRegister(metrics.workersBusy)
+	registry.MustRegister(metrics.tasksQueued)
+	registry.MustRegister(metrics.fenceCoordinates)
+	registry.MustRegister(metrics.surfacePoints)
+	registry.MustRegister(metrics.samplePoints)
+	registry.MustRegister(metrics.sliceSamples)
+	registry.MustRegister(metrics.attributeWindow)
+	registry.MustRegister(metrics.fenceBatches)
 
 	return metrics;
 }
 
-/** New gin middleware for writing prometheus metrics */
+/** Count a retry after a transient storage error */
+func (m *Metrics) RetryAttempted() {
+	m.retriesAttempted.Inc()
+}
+
+/** Count a request that still failed after all retries */
+func (m *Metrics) RetriesExhausted() {
+	m.retriesExhausted.Inc()
+}
+
+/*
+ * Storage hosts are reduced to their first label, i.e. the storage account or
+ * bucket name, and truncated in order to keep the label cardinality and size
+ * in check.
+ */
+func hostLabel(host string) string {
+	const maxLength = 24
+	label := strings.SplitN(host, ".", 2)[0]
+	if len(label) > maxLength {
+		label = label[:maxLength]
+	}
+	return label
+}
+
+/** Export the circuit breaker state of a storage host */
+func (m *Metrics) CircuitStateChanged(host string, state int) {
+	m.circuitState.WithLabelValues(hostLabel(host)).Set(float64(state))
+}
+
+/** Export the bytes currently reserved against the memory budget */
+func (m *Metrics) MemoryReserved(bytes int64) {
+	m.memoryReserved.Set(float64(bytes))
+}
+
+/** Count a request rejected by the memory budget */
+func (m *Metrics) MemoryRejected() {
+	m.memoryRejected.Inc()
+}
+
+/** Export the busy workers and the size of the attribute worker pool */
+func (m *Metrics) WorkersBusy(busy int, workers int) {
+	m.workersBusy.Set(float64(busy))
+	m.workers.Set(float64(workers))
+}
+
+/** Export the number of attribute tasks waiting for a worker */
+func (m *Metrics) TasksQueued(tasks int) {
+	m.tasksQueued.Set(float64(tasks))
+}
+
+/** Count a response served stale as storage is down */
+func (m *Metrics) StaleServed() {
+	m.staleServed.Inc()
+}
+
+/** Record the number of coordinates of a fence request */
+func (m *Metrics) FenceCoordinates(endpoint string, count int) {
+	m.fenceCoordinates.WithLabelValues(endpoint).Observe(float64(count))
+}
+
+/** Record the number of points of the surface of an attribute request */
+func (m *Metrics) SurfacePoints(endpoint string, count int) {
+	m.surfacePoints.WithLabelValues(endpoint).Observe(float64(count))
+}
+
+/** Record the number of points of a sample request */
+func (m *Metrics) SamplePoints(endpoint string, count int) {
+	m.samplePoints.WithLabelValues(endpoint).Observe(float64(count))
+}
+
+/** Record the number of samples of a slice request */
+func (m *Metrics) SliceSamples(endpoint string, count int) {
+	m.sliceSamples.WithLabelValues(endpoint).Observe(float64(count))
+}
+
+/** Record the length of the window of an attribute request */
+func (m *Metrics) AttributeWindow(endpoint string, length float64) {
+	m.attributeWindow.WithLabelValues(endpoint).Observe(length)
+}
+
+/** Record the number of batches of a batched fence request */
+func (m *Metrics) FenceBatches(endpoint string, count int) {
+	m.fenceBatches.WithLabelValues(endpoint).Observe(float64(count))
+}
+
+/** Count a request rejected by the rate limiter */
+func (m *Metrics) RequestThrottled(keyClass string) {
+	m.throttled.WithLabelValues(keyClass).Inc()
+}
+
+var versionPrefix = regexp.MustCompile(`^/(v[0-9]+)(/.*)$`)
+
+/*
+ * The versioned routes, e.g. /v1/slice, are labeled with the path without the
+ * version prefix, i.e. /slice, and the version in a separate label. Such that
+ * the same endpoint aggregates across versions, while versions can still be
+ * told apart. Unprefixed paths are labeled as "unversioned".
+ */
+func pathLabels(path string) (string, string) {
+	match := versionPrefix.FindStringSubmatch(path)
+	if match == nil {
+		return path, "unversioned"
+	}
+	return match[2], match[1]
+}
+
+/*
+ * Requests are labeled by the route they matched, e.g. /v1/slice or
+ * /swagger/*any, rather than by their path, such that typos and probes can't
+ * grow the number of time series. Requests that matched no route, i.e. 404s
+ * and 405s, are all labeled as unmatched.
+ */
+func routeLabels(route string) (string, string) {
+	if route == "" {
+		return "unmatched", "unversioned"
+	}
+	return pathLabels(route)
+}
+
+type request struct {
+	method   string
+	path     string
+	version  string
+	rpc      string
+	status   string
+	cachehit bool
+	size     int
+	/* Estimated bytes fetched from storage */
+	storageBytes int64
+	/* Estimated number of requests to storage */
+	storageRequests int64
+	/* Time spent reading from storage */
+	storageRead time.Duration
+	duration    time.Duration
+	traceId     string
+	/* Where the deadline came from, if the request ran out of time */
+	deadline string
+}
+
+/** Trace id of the OpenTelemetry span in ctx, or empty if there is none */
+func traceId(ctx context.Context) string {
+	spanContext := trace.SpanContextFromContext(ctx)
+	if !spanContext.HasTraceID() {
+		return ""
+	}
+	return spanContext.TraceID().String()
+}
+
+/*
+ * Observations of traced requests carry the trace id as an exemplar, which
+ * links the bucket they fall in to the trace. Exemplars are only exposed in
+ * the OpenMetrics format.
+ */
+func observe(observer prometheus.Observer, value float64, traceId string) {
+	exemplarObserver, ok := observer.(prometheus.ExemplarObserver)
+	if !ok || traceId == "" {
+		observer.Observe(value)
+		return
+	}
+	exemplarObserver.ObserveWithExemplar(
+		value,
+		prometheus.Labels{"trace_id": traceId},
+	)
+}
+
+func (m *Metrics) observe(r request) {
+	cachehit := strconv.FormatBool(r.cachehit)
+
+	observe(m.requestDurations.WithLabelValues(
+		r.path,
+		r.version,
+		r.rpc,
+		r.status,
+		cachehit,
+	), r.duration.Seconds(), r.traceId)
+
+	observe(m.responseSizes.WithLabelValues(
+		r.path,
+		r.version,
+		r.rpc,
+		r.status,
+		cachehit,
+	), float64(r.size), r.traceId)
+
+	m.storageBytes.WithLabelValues(
+		r.path,
+		r.version,
+		r.rpc,
+		cachehit,
+	).Add(float64(r.storageBytes))
+
+	m.storageRequests.WithLabelValues(
+		r.path,
+		r.version,
+		r.rpc,
+		cachehit,
+	).Add(float64(r.storageRequests))
+
+	if r.storageRead > 0 {
+		observe(m.storageReads.WithLabelValues(
+			r.path,
+			r.version,
+			r.rpc,
+		), r.storageRead.Seconds(), r.traceId)
+	}
+
+	m.requestCount.WithLabelValues(r.method, r.path, r.version, r.rpc).Inc()
+
+	if r.deadline != "" {
+		m.deadlines.WithLabelValues(
+			r.path,
+			r.version,
+			r.rpc,
+			r.deadline,
+		).Inc()
+	}
+}
+
+/** New gin middleware for writing prometheus metrics
+ *
+ * Http requests have an empty rpc label.
+ */
 func NewGinMiddleware(metrics *Metrics) gin.HandlerFunc {
 	return func(ctx *gin.Context) {
 		start := time.Now()
 		ctx.Next()
 
-		go func() {
-			path     := ctx.Request.URL.Path
-			method   := ctx.Request.Method
-			status   := strconv.Itoa(ctx.Writer.Status())
-			size     := float64(ctx.Writer.Size())
-			cachehit := strconv.FormatBool(ctx.GetBool("cache-hit"))
-			duration := time.Since(start).Seconds()
+		/*
+		 * The context is reused by gin once the handler returns, so
+		 * everything is read from it before observing in the background
+		 */
+		path, version := routeLabels(ctx.FullPath())
+		observed := request{
+			method:          ctx.Request.Method,
+			path:            path,
+			version:         version,
+			status:          strconv.Itoa(ctx.Writer.Status()),
+			cachehit:        ctx.GetBool("cache-hit"),
+			size:            ctx.Writer.Size(),
+			storageBytes:    ctx.GetInt64("storage-bytes"),
+			storageRequests: ctx.GetInt64("storage-requests"),
+			storageRead:     ctx.GetDuration("storage-read-time"),
+			duration:        time.Since(start),
+			traceId:         traceId(ctx.Request.Context()),
+			deadline:        ctx.GetString("deadline-exceeded"),
+		}
+		go metrics.observe(observed)
+	}
+}
 
-			metrics.requestDurations.WithLabelValues(
-				path,
-				status,
-				cachehit,
-			).Observe(duration)
+/*
+ * Full grpc method names are on the form /package.Service/Rpc, where the
+ * package ends with the version, e.g. /vdsslice.v1.VdsSlice/Slice.
+ */
+var grpcMethod = regexp.MustCompile(`^/(?:.*\.)?(v[0-9]+)\.[^/]*/(.*)$`)
 
-			metrics.responseSizes.WithLabelValues(path, status).Observe(size)
-			metrics.requestCount.WithLabelValues(method, path).Inc()
-		}()
+func grpcLabels(fullMethod string) (string, string) {
+	match := grpcMethod.FindStringSubmatch(fullMethod)
+	if match == nil {
+		return "unversioned", fullMethod
+	}
+	return match[1], match[2]
+}
+
+/* Grpc deadlines are given by the client, as grpc-timeout */
+func grpcDeadline(err error) string {
+	if status.Code(err) == codes.DeadlineExceeded {
+		return "client"
+	}
+	return ""
+}
+
+/** New grpc interceptor for writing prometheus metrics for unary rpcs
+ *
+ * Grpc requests have an empty path label, and the status is the grpc status
+ * code.
+ */
+func NewGrpcUnaryInterceptor(metrics *Metrics) grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req interface{},
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (interface{}, error) {
+		start := time.Now()
+		resp, err := handler(ctx, req)
+
+		size := 0
+		if message, ok := resp.(proto.Message); ok && err == nil {
+			size = proto.Size(message)
+		}
+
+		version, rpc := grpcLabels(info.FullMethod)
+		go metrics.observe(request{
+			method:   "GRPC",
+			version:  version,
+			rpc:      rpc,
+			status:   status.Code(err).String(),
+			size:     size,
+			duration: time.Since(start),
+			traceId:  traceId(ctx),
+			deadline: grpcDeadline(err),
+		})
+		return resp, err
+	}
+}
+
+/** Metadata the server sets on responses that are served from cache */
+const cacheHitMetadata = "x-cache-hit"
+
+/** Metadata the server sets with the estimated bytes fetched from storage */
+const storageBytesMetadata = "x-storage-bytes"
+
+/** Metadata the server sets with the estimated number of requests to storage */
+const storageRequestsMetadata = "x-storage-requests"
+
+/** Metadata the server sets with the seconds spent reading from storage */
+const storageReadMetadata = "x-storage-read-seconds"
+
+/* Server stream that keeps track of what is sent, for the metrics */
+type observedStream struct {
+	grpc.ServerStream
+	size            int
+	cachehit        bool
+	storageBytes    int64
+	storageRequests int64
+	storageRead     time.Duration
+}
+
+func (s *observedStream) SendMsg(m interface{}) error {
+	if message, ok := m.(proto.Message); ok {
+		s.size += proto.Size(message)
+	}
+	return s.ServerStream.SendMsg(m)
+}
+
+func (s *observedStream) SetHeader(md metadata.MD) error {
+	if len(md.Get(cacheHitMetadata)) > 0 {
+		s.cachehit = true
+	}
+	if values := md.Get(storageBytesMetadata); len(values) > 0 {
+		s.storageBytes, _ = strconv.ParseInt(values[0], 10, 64)
+	}
+	if values := md.Get(storageRequestsMetadata); len(values) > 0 {
+		s.storageRequests, _ = strconv.ParseInt(values[0], 10, 64)
+	}
+	if values := md.Get(storageReadMetadata); len(values) > 0 {
+		seconds, _ := strconv.ParseFloat(values[0], 64)
+		s.storageRead = time.Duration(seconds * float64(time.Second))
+	}
+	return s.ServerStream.SetHeader(md)
+}
+
+/** New grpc interceptor for writing prometheus metrics for streaming rpcs */
+func NewGrpcStreamInterceptor(metrics *Metrics) grpc.StreamServerInterceptor {
+	return func(
+		srv interface{},
+		stream grpc.ServerStream,
+		info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler,
+	) error {
+		start := time.Now()
+		observed := &observedStream{ServerStream: stream}
+		err := handler(srv, observed)
+
+		version, rpc := grpcLabels(info.FullMethod)
+		go metrics.observe(request{
+			method:          "GRPC",
+			version:         version,
+			rpc:             rpc,
+			status:          status.Code(err).String(),
+			cachehit:        observed.cachehit,
+			size:            observed.size,
+			storageBytes:    observed.storageBytes,
+			storageRequests: observed.storageRequests,
+			storageRead:     observed.storageRead,
+			duration:        time.Since(start),
+			traceId:         traceId(stream.Context()),
+			deadline:        grpcDeadline(err),
+		})
+		return err
 	}
 }
 
@@ -91,6 +656,10 @@ func NewGinMiddleware(metrics *Metrics) gin.HandlerFunc {
  *
  * A tiny helper that sets up a handle for promethus and wraps it in
  * a gin handler function such that it can be applied to a gin app.
+ *
+ * OpenMetrics must stay enabled, as it's the only text format that carries
+ * exemplars. Native histograms are only exposed in the protobuf format, which
+ * Prometheus negotiates on its own.
  */
 func NewGinHandler(metrics *Metrics) gin.HandlerFunc {
 	return gin.WrapH(
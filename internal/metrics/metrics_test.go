@@ -0,0 +1,409 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+const testTraceId = "0af7651916cd43dd8448eb211c80319c"
+
+func tracedContext(t *testing.T) context.Context {
+	traceId, err := trace.TraceIDFromHex(testTraceId)
+	require.NoError(t, err)
+	spanId, err := trace.SpanIDFromHex("b7ad6b7169203331")
+	require.NoError(t, err)
+
+	spanContext := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    traceId,
+		SpanID:     spanId,
+		TraceFlags: trace.FlagsSampled,
+	})
+	return trace.ContextWithSpanContext(context.Background(), spanContext)
+}
+
+func scrape(t *testing.T, metrics *Metrics, accept string) string {
+	gin.SetMode(gin.TestMode)
+	app := gin.New()
+	app.GET("/metrics", NewGinHandler(metrics))
+
+	request := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	request.Header.Set("Accept", accept)
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, request)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	body, err := io.ReadAll(w.Body)
+	require.NoError(t, err)
+	return string(body)
+}
+
+func TestExemplars(t *testing.T) {
+	metrics := NewMetrics()
+	metrics.observe(request{
+		method:   http.MethodPost,
+		path:     "/slice",
+		version:  "v1",
+		status:   "200",
+		size:     2 * kb,
+		duration: 300 * time.Millisecond,
+		traceId:  traceId(tracedContext(t)),
+	})
+
+	body := scrape(t, metrics, "application/openmetrics-text; version=0.0.1")
+	require.Regexp(t,
+		`vdsslice_durations_histogram_seconds_bucket\{.*le="0.5"\} 1 `+
+			`# \{trace_id="`+testTraceId+`"\} 0.3 `,
+		body,
+	)
+	require.Regexp(t,
+		`vdsslice_response_sizes_histogram_bytes_bucket\{.*le="102400.0"\} 1 `+
+			`# \{trace_id="`+testTraceId+`"\} 2048.0 `,
+		body,
+	)
+
+	body = scrape(t, metrics, "text/plain")
+	require.NotContains(t, body, "trace_id", "Text format has no exemplars")
+}
+
+func TestNoExemplarWithoutTrace(t *testing.T) {
+	require.Empty(t, traceId(context.Background()))
+
+	metrics := NewMetrics()
+	metrics.observe(request{
+		path:     "/slice",
+		version:  "v1",
+		status:   "200",
+		duration: 300 * time.Millisecond,
+	})
+
+	body := scrape(t, metrics, "application/openmetrics-text; version=0.0.1")
+	require.Contains(t, body, "vdsslice_durations_histogram_seconds_bucket")
+	require.NotContains(t, body, "trace_id")
+}
+
+func TestNativeHistograms(t *testing.T) {
+	metrics := NewMetrics()
+	metrics.observe(request{
+		path:     "/slice",
+		version:  "v1",
+		status:   "200",
+		size:     2 * kb,
+		duration: 300 * time.Millisecond,
+	})
+
+	families, err := metrics.registry.Gather()
+	require.NoError(t, err)
+
+	histograms := 0
+	for _, family := range families {
+		for _, metric := range family.GetMetric() {
+			histogram := metric.GetHistogram()
+			if histogram == nil {
+				continue
+			}
+			histograms++
+			require.NotEmpty(t, histogram.GetPositiveSpan(),
+				"Expected native buckets in %s", family.GetName())
+			require.NotEmpty(t, histogram.GetBucket(),
+				"Expected classic buckets in %s", family.GetName())
+		}
+	}
+	require.Equal(t, 2, histograms)
+}
+
+func TestRouteLabels(t *testing.T) {
+	testcases := []struct {
+		route   string
+		path    string
+		version string
+	}{
+		{route: "/slice", path: "/slice", version: "unversioned"},
+		{route: "/v1/slice", path: "/slice", version: "v1"},
+		{route: "/swagger/*any", path: "/swagger/*any", version: "unversioned"},
+		{route: "", path: "unmatched", version: "unversioned"},
+	}
+
+	for _, testcase := range testcases {
+		path, version := routeLabels(testcase.route)
+		require.Equal(t, testcase.path, path, "route '%s'", testcase.route)
+		require.Equal(t, testcase.version, version, "route '%s'", testcase.route)
+	}
+}
+
+func TestPathLabelsAreBounded(t *testing.T) {
+	metrics := NewMetrics()
+	observe := NewGinMiddleware(metrics)
+
+	gin.SetMode(gin.TestMode)
+	app := gin.New()
+	app.HandleMethodNotAllowed = true
+	app.NoRoute(observe, func(ctx *gin.Context) { ctx.Status(http.StatusNotFound) })
+	app.NoMethod(observe, func(ctx *gin.Context) {
+		ctx.Status(http.StatusMethodNotAllowed)
+	})
+
+	handler := func(ctx *gin.Context) { ctx.Status(http.StatusOK) }
+	app.Group("/", observe).GET("slice", handler)
+	app.Group("/v1", observe).GET("slice", handler)
+
+	paths := []string{"/slice", "/v1/slice"}
+	for i := 0; i < 50; i++ {
+		paths = append(paths,
+			fmt.Sprintf("/slice%d", i),
+			fmt.Sprintf("/v1/fence/%d", i),
+			fmt.Sprintf("/%x", i*7919),
+		)
+	}
+	for _, path := range paths {
+		app.ServeHTTP(
+			httptest.NewRecorder(),
+			httptest.NewRequest(http.MethodGet, path, nil),
+		)
+	}
+	app.ServeHTTP(
+		httptest.NewRecorder(),
+		httptest.NewRequest(http.MethodDelete, "/slice", nil),
+	)
+
+	/* Requests are observed in the background */
+	var labels map[string]float64
+	require.Eventually(t, func() bool {
+		labels = requestCounts(t, metrics)
+		total := 0.0
+		for _, count := range labels {
+			total += count
+		}
+		return total == float64(len(paths)+1)
+	}, time.Second, 10*time.Millisecond)
+
+	require.Equal(t, map[string]float64{
+		"GET /slice unversioned":       1,
+		"GET /slice v1":                1,
+		"GET unmatched unversioned":    float64(len(paths) - 2),
+		"DELETE unmatched unversioned": 1,
+	}, labels)
+}
+
+/* The request counts by method, path and version */
+func requestCounts(t *testing.T, metrics *Metrics) map[string]float64 {
+	families, err := metrics.registry.Gather()
+	require.NoError(t, err)
+
+	counts := map[string]float64{}
+	for _, family := range families {
+		if family.GetName() != "vdsslice_number_of_requests" {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			labels := map[string]string{}
+			for _, label := range metric.GetLabel() {
+				labels[label.GetName()] = label.GetValue()
+			}
+			key := labels["method"] + " " + labels["path"] + " " + labels["version"]
+			counts[key] = metric.GetCounter().GetValue()
+		}
+	}
+	return counts
+}
+
+func TestRequestSizeMetrics(t *testing.T) {
+	metrics := NewMetrics()
+	metrics.FenceCoordinates("fence", 250)
+	metrics.SurfacePoints("attributes/surface/along", 6)
+	metrics.SamplePoints("sample", 3)
+	metrics.SliceSamples("slice", 8)
+	metrics.AttributeWindow("attributes/surface/along", 12)
+	metrics.FenceBatches("fence", 3)
+
+	body := scrape(t, metrics, "text/plain")
+	for _, expected := range []string{
+		`vdsslice_fence_coordinates_bucket{endpoint="fence",le="100"} 0`,
+		`vdsslice_fence_coordinates_bucket{endpoint="fence",le="1000"} 1`,
+		`vdsslice_fence_coordinates_bucket{endpoint="fence",le="1e+06"} 1`,
+		`vdsslice_surface_points_bucket{endpoint="attributes/surface/along",le="10"} 1`,
+		`vdsslice_sample_points_bucket{endpoint="sample",le="10"} 1`,
+		`vdsslice_slice_samples_bucket{endpoint="slice",le="1"} 0`,
+		`vdsslice_slice_samples_bucket{endpoint="slice",le="10"} 1`,
+		`vdsslice_attribute_window_sum{endpoint="attributes/surface/along"} 12`,
+		`vdsslice_fence_batches_bucket{endpoint="fence",le="10"} 1`,
+	} {
+		require.Contains(t, body, expected)
+	}
+}
+
+func TestStorageBytes(t *testing.T) {
+	metrics := NewMetrics()
+	observe := NewGinMiddleware(metrics)
+
+	gin.SetMode(gin.TestMode)
+	app := gin.New()
+	app.GET("/v1/slice", observe, func(ctx *gin.Context) {
+		if ctx.Query("cached") != "" {
+			ctx.Set("cache-hit", true)
+		} else {
+			ctx.Set("storage-bytes", int64(3*mb))
+		}
+		ctx.String(http.StatusOK, "data")
+	})
+
+	for _, target := range []string{"/v1/slice", "/v1/slice", "/v1/slice?cached=1"} {
+		app.ServeHTTP(
+			httptest.NewRecorder(),
+			httptest.NewRequest(http.MethodGet, target, nil),
+		)
+	}
+
+	/* Requests are observed in the background */
+	require.Eventually(t, func() bool {
+		body := scrape(t, metrics, "text/plain")
+		return strings.Contains(body,
+			`vdsslice_storage_bytes{cachehit="false",path="/slice",rpc="",version="v1"} 6.291456e+06`,
+		) && strings.Contains(body,
+			`vdsslice_storage_bytes{cachehit="true",path="/slice",rpc="",version="v1"} 0`,
+		) && strings.Contains(body,
+			`vdsslice_response_sizes_histogram_bytes_count{cachehit="true",path="/slice",rpc="",status="200",version="v1"} 1`,
+		)
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestStorageRequestsAndReads(t *testing.T) {
+	metrics := NewMetrics()
+	observe := NewGinMiddleware(metrics)
+
+	gin.SetMode(gin.TestMode)
+	app := gin.New()
+	app.GET("/v1/slice", observe, func(ctx *gin.Context) {
+		if ctx.Query("cached") != "" {
+			ctx.Set("cache-hit", true)
+		} else {
+			ctx.Set("storage-requests", int64(4))
+			ctx.Set("storage-read-time", 300*time.Millisecond)
+		}
+		ctx.String(http.StatusOK, "data")
+	})
+
+	for _, target := range []string{"/v1/slice", "/v1/slice", "/v1/slice?cached=1"} {
+		app.ServeHTTP(
+			httptest.NewRecorder(),
+			httptest.NewRequest(http.MethodGet, target, nil),
+		)
+	}
+
+	/* Requests are observed in the background */
+	require.Eventually(t, func() bool {
+		body := scrape(t, metrics, "text/plain")
+		return strings.Contains(body,
+			`vdsslice_storage_requests{cachehit="false",path="/slice",rpc="",version="v1"} 8`,
+		) && strings.Contains(body,
+			`vdsslice_storage_requests{cachehit="true",path="/slice",rpc="",version="v1"} 0`,
+		) && strings.Contains(body,
+			`vdsslice_storage_read_seconds_bucket{path="/slice",rpc="",version="v1",le="0.5"} 2`,
+		) && strings.Contains(body,
+			`vdsslice_storage_read_seconds_count{path="/slice",rpc="",version="v1"} 2`,
+		)
+	}, time.Second, 10*time.Millisecond)
+}
+
+/* Server stream that accepts headers, and nothing else */
+type headerStream struct {
+	grpc.ServerStream
+}
+
+func (headerStream) SetHeader(metadata.MD) error {
+	return nil
+}
+
+func TestObservedStreamStorageHeaders(t *testing.T) {
+	stream := &observedStream{ServerStream: headerStream{}}
+	err := stream.SetHeader(metadata.Pairs(
+		storageBytesMetadata, "1024",
+		storageRequestsMetadata, "2",
+		storageReadMetadata, "0.25",
+	))
+	require.NoError(t, err)
+
+	require.Equal(t, int64(1024), stream.storageBytes)
+	require.Equal(t, int64(2), stream.storageRequests)
+	require.Equal(t, 250*time.Millisecond, stream.storageRead)
+}
+
+func TestMemoryBudgetMetrics(t *testing.T) {
+	metrics := NewMetrics()
+	metrics.MemoryReserved(1024)
+	metrics.MemoryRejected()
+	metrics.MemoryRejected()
+
+	body := scrape(t, metrics, "text/plain")
+	require.Contains(t, body, "vdsslice_memory_reserved_bytes 1024")
+	require.Contains(t, body, "vdsslice_memory_rejections 2")
+}
+
+func TestWorkerPoolMetrics(t *testing.T) {
+	metrics := NewMetrics()
+	metrics.WorkersBusy(3, 8)
+	metrics.TasksQueued(12)
+
+	body := scrape(t, metrics, "text/plain")
+	require.Contains(t, body, "vdsslice_attribute_workers 8")
+	require.Contains(t, body, "vdsslice_attribute_workers_busy 3")
+	require.Contains(t, body, "vdsslice_attribute_tasks_queued 12")
+}
+
+func TestStaleServedMetric(t *testing.T) {
+	metrics := NewMetrics()
+	metrics.StaleServed()
+
+	body := scrape(t, metrics, "text/plain")
+	require.Contains(t, body, "vdsslice_stale_responses 1")
+}
+
+func TestDeadlinesExceeded(t *testing.T) {
+	metrics := NewMetrics()
+	observe := NewGinMiddleware(metrics)
+
+	gin.SetMode(gin.TestMode)
+	app := gin.New()
+	app.GET("/v1/slice", observe, func(ctx *gin.Context) {
+		if source := ctx.Query("deadline"); source != "" {
+			ctx.Set("deadline-exceeded", source)
+			ctx.Status(http.StatusGatewayTimeout)
+			return
+		}
+		ctx.String(http.StatusOK, "data")
+	})
+
+	targets := []string{
+		"/v1/slice",
+		"/v1/slice?deadline=client",
+		"/v1/slice?deadline=client",
+		"/v1/slice?deadline=server",
+	}
+	for _, target := range targets {
+		app.ServeHTTP(
+			httptest.NewRecorder(),
+			httptest.NewRequest(http.MethodGet, target, nil),
+		)
+	}
+
+	/* Requests are observed in the background */
+	require.Eventually(t, func() bool {
+		body := scrape(t, metrics, "text/plain")
+		return strings.Contains(body,
+			`vdsslice_deadlines_exceeded{path="/slice",rpc="",source="client",version="v1"} 2`,
+		) && strings.Contains(body,
+			`vdsslice_deadlines_exceeded{path="/slice",rpc="",source="server",version="v1"} 1`,
+		)
+	}, time.Second, 10*time.Millisecond)
+}
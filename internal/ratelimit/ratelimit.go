@@ -0,0 +1,195 @@
+package ratelimit
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/equinor/vds-slice/internal/clientip"
+)
+
+/** Receives notifications about throttled requests, e.g. for metrics */
+type Observer interface {
+	RequestThrottled(keyClass string)
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+/** Token bucket rate limiter, with one bucket per client
+ *
+ * Every client gets a bucket that holds up to 'burst' tokens and is refilled
+ * at 'rate' tokens per second. A request costs one token. Buckets that have
+ * been idle long enough to be full again are forgotten, such that memory use
+ * is bounded by the number of active clients.
+ *
+ * A rate of zero lets every request through, such that the limiter can be
+ * installed up front and turned on later, see SetRate.
+ *
+ * Safe for concurrent use.
+ */
+type Limiter struct {
+	rate  float64
+	burst float64
+
+	lock      sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+func NewLimiter(rate float64, burst int) *Limiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &Limiter{
+		rate:      rate,
+		burst:     float64(burst),
+		buckets:   make(map[string]*bucket),
+		lastSweep: time.Now(),
+		now:       time.Now,
+	}
+}
+
+/** Change the rate and burst of the limiter
+ *
+ * The buckets of the clients are kept, and are refilled at the new rate up
+ * to the new burst from now on.
+ */
+func (l *Limiter) SetRate(rate float64, burst int) {
+	if burst < 1 {
+		burst = 1
+	}
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.rate = rate
+	l.burst = float64(burst)
+}
+
+/* Time for an empty bucket to fill up completely */
+func (l *Limiter) fillTime() time.Duration {
+	return time.Duration(l.burst / l.rate * float64(time.Second))
+}
+
+/* Must be called with the lock held */
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < l.fillTime() {
+		return
+	}
+	for key, b := range l.buckets {
+		if now.Sub(b.last) >= l.fillTime() {
+			delete(l.buckets, key)
+		}
+	}
+	l.lastSweep = now
+}
+
+/** Take a token from the bucket of key
+ *
+ * Returns true if the request may proceed. Otherwise it returns false, and
+ * how long until the next token is available.
+ */
+func (l *Limiter) Allow(key string) (bool, time.Duration) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if l.rate <= 0 {
+		return true, 0
+	}
+
+	now := l.now()
+	l.sweep(now)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: l.burst, last: now}
+		l.buckets[key] = b
+	}
+
+	elapsed := now.Sub(b.last).Seconds()
+	b.tokens = math.Min(l.burst, b.tokens+elapsed*l.rate)
+	b.last = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+
+	wait := (1 - b.tokens) / l.rate
+	return false, time.Duration(wait * float64(time.Second))
+}
+
+/*
+ * Paths that are never rate limited, such that health checks and metric
+ * scrapers keep working while clients are throttled.
+ */
+var exempt = map[string]bool{
+	"/":        true,
+	"/health":  true,
+	"/ready":   true,
+	"/metrics": true,
+}
+
+/** Identify the client of a request
+ *
+ * Clients are identified by the value of 'header', if set and present in the
+ * request, and by their IP otherwise. The key class tells which of the two
+ * was used. The IP is only taken from forwarding headers if the request comes
+ * through a trusted proxy, see clientip.
+ */
+func clientKey(ctx *gin.Context, header string) (key string, keyClass string) {
+	if header != "" {
+		if value := ctx.GetHeader(header); value != "" {
+			return "header:" + value, "header"
+		}
+	}
+	return "ip:" + clientip.Get(ctx), "ip"
+}
+
+/** New gin middleware for rate limiting
+ *
+ * Throttled requests are rejected with 429 and a Retry-After header. The body
+ * has the same form as all other error responses.
+ */
+func NewGinMiddleware(
+	limiter *Limiter,
+	header string,
+	observer Observer,
+) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if exempt[ctx.Request.URL.Path] {
+			ctx.Next()
+			return
+		}
+
+		key, keyClass := clientKey(ctx, header)
+		allowed, retryAfter := limiter.Allow(key)
+		if allowed {
+			ctx.Next()
+			return
+		}
+
+		if observer != nil {
+			observer.RequestThrottled(keyClass)
+		}
+
+		seconds := int(math.Ceil(retryAfter.Seconds()))
+		ctx.Header("Retry-After", strconv.Itoa(seconds))
+		ctx.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{
+			"error": fmt.Sprintf(
+				"Too many requests, retry after %d second(s)",
+				seconds,
+			),
+			"code":    "rate_limited",
+			"details": gin.H{"retryAfter": strconv.Itoa(seconds)},
+		})
+	}
+}
@@ -0,0 +1,205 @@
+package ratelimit
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+
+	"github.com/equinor/vds-slice/internal/clientip"
+)
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+func newTestLimiter(rate float64, burst int) (*Limiter, *fakeClock) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	limiter := NewLimiter(rate, burst)
+	limiter.now = clock.Now
+	limiter.lastSweep = clock.Now()
+	return limiter, clock
+}
+
+func TestLimiterBurstAndRefill(t *testing.T) {
+	limiter, clock := newTestLimiter(2, 3)
+
+	for i := 0; i < 3; i++ {
+		allowed, _ := limiter.Allow("client")
+		require.Truef(t, allowed, "Request %d should be within burst", i)
+	}
+
+	allowed, retryAfter := limiter.Allow("client")
+	require.False(t, allowed)
+	require.Equal(t, 500*time.Millisecond, retryAfter)
+
+	allowed, _ = limiter.Allow("other")
+	require.True(t, allowed, "Clients should not share buckets")
+
+	clock.Advance(500 * time.Millisecond)
+	allowed, _ = limiter.Allow("client")
+	require.True(t, allowed, "Expected bucket to be refilled")
+
+	allowed, _ = limiter.Allow("client")
+	require.False(t, allowed)
+}
+
+func TestLimiterForgetsIdleClients(t *testing.T) {
+	limiter, clock := newTestLimiter(1, 1)
+
+	for i := 0; i < 10; i++ {
+		limiter.Allow(fmt.Sprintf("client%d", i))
+	}
+	require.Len(t, limiter.buckets, 10)
+
+	clock.Advance(time.Second)
+	limiter.Allow("client0")
+	require.Len(t, limiter.buckets, 1)
+}
+
+func TestLimiterSetRate(t *testing.T) {
+	limiter, clock := newTestLimiter(0, 0)
+
+	for i := 0; i < 10; i++ {
+		allowed, _ := limiter.Allow("client")
+		require.Truef(t, allowed, "Request %d should not be limited at rate 0", i)
+	}
+
+	limiter.SetRate(1, 2)
+	for i := 0; i < 2; i++ {
+		allowed, _ := limiter.Allow("client")
+		require.Truef(t, allowed, "Request %d should be within the new burst", i)
+	}
+	allowed, retryAfter := limiter.Allow("client")
+	require.False(t, allowed)
+	require.Equal(t, time.Second, retryAfter)
+
+	limiter.SetRate(4, 2)
+	clock.Advance(250 * time.Millisecond)
+	allowed, _ = limiter.Allow("client")
+	require.True(t, allowed, "Expected the bucket to refill at the new rate")
+
+	limiter.SetRate(0, 0)
+	allowed, _ = limiter.Allow("client")
+	require.True(t, allowed, "Expected rate 0 to turn the limiter off")
+}
+
+func TestLimiterIsConcurrencySafe(t *testing.T) {
+	limiter := NewLimiter(1, 100)
+
+	var wg sync.WaitGroup
+	var lock sync.Mutex
+	allowed := 0
+	for i := 0; i < 200; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if ok, _ := limiter.Allow("client"); ok {
+				lock.Lock()
+				allowed++
+				lock.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	require.LessOrEqual(t, allowed, 101)
+	require.GreaterOrEqual(t, allowed, 100)
+}
+
+type countingObserver struct {
+	throttled map[string]int
+}
+
+func (o *countingObserver) RequestThrottled(keyClass string) {
+	o.throttled[keyClass]++
+}
+
+func TestMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	observer := &countingObserver{throttled: map[string]int{}}
+	app := gin.New()
+	app.Use(NewGinMiddleware(NewLimiter(1, 1), "X-Client-Id", observer))
+	app.GET("/", func(ctx *gin.Context) { ctx.Status(http.StatusOK) })
+	app.GET("/slice", func(ctx *gin.Context) { ctx.Status(http.StatusOK) })
+
+	request := func(path string, clientId string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, path, nil)
+		req.RemoteAddr = "10.0.0.1:1234"
+		if clientId != "" {
+			req.Header.Set("X-Client-Id", clientId)
+		}
+		app.ServeHTTP(w, req)
+		return w
+	}
+
+	require.Equal(t, http.StatusOK, request("/slice", "").Code)
+
+	w := request("/slice", "")
+	require.Equal(t, http.StatusTooManyRequests, w.Code)
+	require.Equal(t, "1", w.Header().Get("Retry-After"))
+
+	body := struct {
+		Error   string            `json:"error"`
+		Code    string            `json:"code"`
+		Details map[string]string `json:"details"`
+	}{}
+	err := json.Unmarshal(w.Body.Bytes(), &body)
+	require.NoError(t, err)
+	require.Contains(t, body.Error, "Too many requests")
+	require.Equal(t, "rate_limited", body.Code)
+	require.Equal(t, map[string]string{"retryAfter": "1"}, body.Details)
+
+	require.Equal(t, http.StatusOK, request("/", "").Code, "Health check should be exempt")
+
+	require.Equal(t, http.StatusOK, request("/slice", "client-a").Code)
+	require.Equal(t, http.StatusTooManyRequests, request("/slice", "client-a").Code)
+	require.Equal(t, http.StatusOK, request("/slice", "client-b").Code)
+
+	require.Equal(t, map[string]int{"ip": 1, "header": 1}, observer.throttled)
+}
+
+func TestMiddlewareIgnoresSpoofedForwardedFor(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	app := gin.New()
+	err := app.SetTrustedProxies([]string{"10.0.0.0/8"})
+	require.NoError(t, err)
+	app.Use(clientip.NewGinMiddleware())
+	app.Use(NewGinMiddleware(NewLimiter(1, 1), "", nil))
+	app.GET("/slice", func(ctx *gin.Context) { ctx.Status(http.StatusOK) })
+
+	request := func(peer string, forwardedFor string) int {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/slice", nil)
+		req.RemoteAddr = peer + ":1234"
+		req.Header.Set("X-Forwarded-For", forwardedFor)
+		app.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	/* An untrusted client can't get a fresh bucket by making up its IP */
+	require.Equal(t, http.StatusOK, request("203.0.113.7", "198.51.100.1"))
+	require.Equal(t, http.StatusTooManyRequests, request("203.0.113.7", "198.51.100.2"))
+
+	/* Clients behind the trusted proxy are told apart by their forwarded IP */
+	require.Equal(t, http.StatusOK, request("10.0.0.1", "198.51.100.1"))
+	require.Equal(t, http.StatusOK, request("10.0.0.1", "198.51.100.2"))
+	require.Equal(t, http.StatusTooManyRequests, request("10.0.0.1", "198.51.100.2"))
+}
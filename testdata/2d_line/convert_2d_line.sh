@@ -0,0 +1,8 @@
+ACCOUNT_NAME=
+SAS=
+
+python make_2d_line.py 2d_line.segy
+SEGYImport --2d --url "file://." --vdsfile 2d_line_default.vds 2d_line.segy --crs-wkt="utmXX"
+VDSCopy "2d_line_default.vds" "azureSAS://$ACCOUNT_NAME.blob.core.windows.net/testdata/2d_line/2d_line_default" --compression-method=None -d "Suffix=?$SAS"
+
+# or import directly to the cloud (and see note about decompression)
@@ -0,0 +1,44 @@
+import segyio
+import numpy as np
+import sys
+
+def create_line(path):
+    """ Create a 2D line with simple constant data.
+    | trace (cdp) | samples            | UTM coordinates |
+    |-------------|--------------------|-----------------|
+    | 10          | 100, 101, 102, 103 | x=2,  y=0       |
+    | 11          | 104, 105, 106, 107 | x=5,  y=2       |
+    | 12          | 108, 109, 110, 111 | x=8,  y=4       |
+    | 13          | 112, 113, 114, 115 | x=11, y=6       |
+    | 14          | 116, 117, 118, 119 | x=14, y=8       |
+    """
+    spec = segyio.spec()
+
+    spec.format = 1
+    spec.samples = [4, 8, 12, 16]
+    spec.tracecount = 5
+
+    # We use scaling constant of -10, meaning that values will be divided by 10
+    step_x = int(3 * 10)
+    step_y = int(2 * 10)
+    ori_x = int(2 * 10)
+    ori_y = int(0 * 10)
+
+    with segyio.create(path, spec) as f:
+        data = 100
+        for tr in range(spec.tracecount):
+            f.header[tr] = {
+                segyio.su.cdp: 10 + tr,
+                segyio.su.cdpx: tr * step_x + ori_x,
+                segyio.su.cdpy: tr * step_y + ori_y,
+                segyio.su.scalco: -10,
+                segyio.su.delrt: 4,
+            }
+            data = data + len(spec.samples)
+            f.trace[tr] = np.arange(start=data - len(spec.samples),
+                                    stop=data, step=1, dtype=np.single)
+
+
+if __name__ == "__main__":
+    path = sys.argv[1]
+    create_line(path)
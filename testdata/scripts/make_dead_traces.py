@@ -0,0 +1,65 @@
+import argparse
+from make_vds import *
+
+
+def make_dead_traces(filename: str) -> None:
+    """
+    Create a VDS file where half of the inlines have no data.
+
+    Args:
+    filename: The filename of the output VDS file.
+
+    Returns:
+    None.
+
+    The cube has 128 inlines, 2 crosslines and 4 samples, and is stored in
+    bricks of 64, such that inlines 0-63 and 64-127 are in separate chunks.
+    Only the first chunk is written, leaving inlines 64-127 as dead traces.
+    The written traces hold the value inline * 10 + crossline, in all samples.
+    """
+    ilines = range(128)
+    xlines = range(2)
+    samples = [4, 8, 12, 16]
+
+    data = np.array(
+        [
+            [[il * 10 + xl for _ in samples] for xl in xlines]
+            for il in ilines
+        ],
+        dtype=np.float32,
+    )
+    axes = [
+        Config.Axis.from_values(
+            samples,
+            openvds.KnownAxisNames.sample(),
+            openvds.KnownUnitNames.millisecond(),
+        ),
+        Config.Axis.from_values(
+            list(xlines),
+            openvds.KnownAxisNames.crossline(),
+            openvds.KnownUnitNames.unitless(),
+        ),
+        Config.Axis.from_values(
+            list(ilines),
+            openvds.KnownAxisNames.inline(),
+            openvds.KnownUnitNames.unitless(),
+        ),
+    ]
+
+    config = Config(data, axes)
+    create_vds(filename, config, absent_chunks={1})
+
+
+if __name__ == "__main__":
+    parser = argparse.ArgumentParser(
+        description="The following script will generate a VDS file with dead traces"
+    )
+    parser.add_argument(
+        "-v",
+        "--vdsfile",
+        type=str,
+        default="dead_traces.vds",
+        help="Name of the new vds file",
+    )
+    args = parser.parse_args()
+    make_dead_traces(filename=args.vdsfile)
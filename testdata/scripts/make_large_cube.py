@@ -0,0 +1,63 @@
+import argparse
+from make_vds import *
+
+
+def make_large_cube(filename: str) -> None:
+    """
+    Create a VDS file that is large enough to span many bricks.
+
+    Args:
+    filename: The filename of the output VDS file.
+
+    Returns:
+    None.
+
+    The cube has 512 inlines, 512 crosslines and 256 samples, stored in bricks
+    of 64, such that every slice spans 8 bricks along both of its axes. The
+    values are pseudo-random, but the same for every run. The cube is about
+    256 MB, and is meant for benchmarks rather than tests.
+    """
+    ilines = range(512)
+    xlines = range(512)
+    samples = [4 * i for i in range(256)]
+
+    rng = np.random.default_rng(seed=2403)
+    data = rng.standard_normal(
+        (len(ilines), len(xlines), len(samples)),
+        dtype=np.float32,
+    )
+    axes = [
+        Config.Axis.from_values(
+            samples,
+            openvds.KnownAxisNames.sample(),
+            openvds.KnownUnitNames.millisecond(),
+        ),
+        Config.Axis.from_values(
+            list(xlines),
+            openvds.KnownAxisNames.crossline(),
+            openvds.KnownUnitNames.unitless(),
+        ),
+        Config.Axis.from_values(
+            list(ilines),
+            openvds.KnownAxisNames.inline(),
+            openvds.KnownUnitNames.unitless(),
+        ),
+    ]
+
+    config = Config(data, axes)
+    create_vds(filename, config)
+
+
+if __name__ == "__main__":
+    parser = argparse.ArgumentParser(
+        description="The following script will generate a large VDS file for benchmarks"
+    )
+    parser.add_argument(
+        "-v",
+        "--vdsfile",
+        type=str,
+        default="large_cube.vds",
+        help="Name of the new vds file",
+    )
+    args = parser.parse_args()
+    make_large_cube(filename=args.vdsfile)
@@ -167,13 +167,26 @@ class Config:
         self.import_info = import_info
 
 
-def create_vds(vds_filename: str, config: Config) -> None:
+def create_vds(
+    vds_filename: str,
+    config: Config,
+    absent_chunks: typing.Container[int] = (),
+    format=openvds.VolumeDataChannelDescriptor.Format.Format_R32,
+    integer_scale: float = 1.0,
+    integer_offset: float = 0.0,
+) -> None:
     """
     Create a VDS file with specified metadata and data.
 
     Args:
     vds_filename (str): The filename of the output VDS file.
     config (Config): The configuration object for VDS.
+    absent_chunks (Container[int]): Chunks that are never written, such that
+        the VDS has no data in them.
+    format: The format the data is stored in. config.data holds the stored
+        values, i.e. already quantized for the integer formats.
+    integer_scale (float), integer_offset (float): How stored integers map to
+        the values they represent, value = stored * scale + offset.
 
     Returns:
     None.
@@ -207,12 +220,18 @@ def create_vds(vds_filename: str, config: Config) -> None:
     )
     channel_descriptors = [
         openvds.VolumeDataChannelDescriptor(
-            openvds.VolumeDataChannelDescriptor.Format.Format_R32,
+            format,
             openvds.VolumeDataChannelDescriptor.Components.Components_1,
             "Amplitude",  # Channel Name
             "",  # Unit
-            np.min(config.data),
-            np.max(config.data),
+            np.min(config.data) * integer_scale + integer_offset,
+            np.max(config.data) * integer_scale + integer_offset,
+            openvds.VolumeDataMapping.Direct,
+            1,  # Mapped value count
+            openvds.VolumeDataChannelDescriptor.Flags.Default,
+            0.0,  # No value
+            integer_scale,
+            integer_offset,
         )
     ]
 
@@ -288,6 +307,9 @@ def create_vds(vds_filename: str, config: Config) -> None:
     )
 
     for c in range(accessor.getChunkCount()):
+        if c in absent_chunks:
+            continue
+
         page = accessor.createPage(c)
         buf = np.array(page.getWritableBuffer(), copy=False)
         (min, max) = page.getMinMax()
@@ -0,0 +1,90 @@
+import argparse
+from make_vds import *
+
+
+# The formats of the variants, with how they quantize the values of
+# well_known, value = stored * scale + offset. OpenVDS has no signed integer or
+# half precision formats, cubes are quantized to U8 or U16 instead.
+FORMATS = {
+    "u8": (openvds.VolumeDataChannelDescriptor.Format.Format_U8, np.uint8, 0.3, 99.0),
+    "u16": (openvds.VolumeDataChannelDescriptor.Format.Format_U16, np.uint16, 0.001, 99.0),
+}
+
+
+def make_well_known_format(filename: str, variant: str) -> None:
+    """
+    Create a VDS file with the values of well_known, stored as integers.
+
+    Args:
+    filename: The filename of the output VDS file.
+    variant: One of the keys of FORMATS.
+
+    Returns:
+    None.
+
+    The cube has the axes and values of well_known, i.e. inlines 1, 3 and 5,
+    crosslines 10 and 11, samples 4, 8, 12 and 16 ms, and the values 100 to
+    123. The values are quantized by the integer scale and offset of the
+    variant, such that they are read back within half a scale of the
+    original. The values are not a multiple of the U8 scale, so the U8 variant
+    is lossy.
+    """
+    format, dtype, scale, offset = FORMATS[variant]
+
+    ilines = [1, 3, 5]
+    xlines = [10, 11]
+    samples = [4, 8, 12, 16]
+
+    values = np.arange(100, 124, dtype=np.float64).reshape(3, 2, 4)
+    data = np.round((values - offset) / scale).astype(dtype)
+
+    axes = [
+        Config.Axis.from_values(
+            samples,
+            openvds.KnownAxisNames.sample(),
+            openvds.KnownUnitNames.millisecond(),
+        ),
+        Config.Axis.from_values(
+            xlines,
+            openvds.KnownAxisNames.crossline(),
+            openvds.KnownUnitNames.unitless(),
+        ),
+        Config.Axis.from_values(
+            ilines,
+            openvds.KnownAxisNames.inline(),
+            openvds.KnownUnitNames.unitless(),
+        ),
+    ]
+
+    config = Config(data, axes)
+    create_vds(
+        filename,
+        config,
+        format=format,
+        integer_scale=scale,
+        integer_offset=offset,
+    )
+
+
+if __name__ == "__main__":
+    parser = argparse.ArgumentParser(
+        description="The following script will generate a VDS file with the "
+        "values of well_known, stored as integers"
+    )
+    parser.add_argument(
+        "-f",
+        "--format",
+        type=str,
+        choices=FORMATS.keys(),
+        required=True,
+        help="The format to store the values in",
+    )
+    parser.add_argument(
+        "-v",
+        "--vdsfile",
+        type=str,
+        help="Name of the new vds file, defaults to well_known_<format>.vds",
+    )
+    args = parser.parse_args()
+    filename = args.vdsfile or f"well_known_{args.format}.vds"
+    make_well_known_format(filename=filename, variant=args.format)
@@ -23,14 +23,14 @@ from shared_test_functions import *
     (
         "metadata",
         metadata_payload(vds=f'{STORAGE_ACCOUNT}/{CONTAINER}/notfound'),
-        http.HTTPStatus.INTERNAL_SERVER_ERROR,
+        http.HTTPStatus.NOT_FOUND,
         "The specified blob does not exist"
     ),
     (
         "attributes/surface/along",
         attributes_along_surface_payload(surface={}),
         http.HTTPStatus.BAD_REQUEST,
-        "Error:Field validation for"
+        "' is required"
     ),
     (
         "attributes/surface/between",
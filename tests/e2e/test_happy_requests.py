@@ -57,7 +57,8 @@ def test_slice(method):
         "y": {"annotation": "Crossline", "max": 11.0, "min": 10.0, "samples" : 2, "stepsize": 1.0, "unit": "unitless"},
         "shape": [ 2, 4],
         "format": "<f4",
-        "geospatial": [[14.0, 8.0], [12.0, 11.0]]
+        "geospatial": [[14.0, 8.0], [12.0, 11.0]],
+        "fillValue": null
     }
     """)
     assert meta == expected_meta
@@ -27,7 +27,7 @@ from azure.storage import filedatalake
 def test_assure_no_unauthorized_access(path, payload, sas, allowed_error_messages):
     payload.update({"sas": sas})
     res = send_request(path, "post", payload)
-    assert res.status_code == http.HTTPStatus.INTERNAL_SERVER_ERROR
+    assert res.status_code == http.HTTPStatus.UNAUTHORIZED
     error_body = json.loads(res.content)['error']
     assert any([error_msg in error_body for error_msg in allowed_error_messages]), \
         f'error body \'{error_body}\' does not contain any of the valid errors {allowed_error_messages}'
@@ -51,7 +51,7 @@ def test_assure_no_unauthorized_access(path, payload, sas, allowed_error_message
     (generate_blob_signature(
         STORAGE_ACCOUNT_NAME, CONTAINER, f'{VDS}/VolumeDataLayout', STORAGE_ACCOUNT_KEY,
         permission=blob.BlobSasPermissions(read=True)),
-     http.HTTPStatus.INTERNAL_SERVER_ERROR, "403 Server failed to authenticate the request"),
+     http.HTTPStatus.FORBIDDEN, "403 Server failed to authenticate the request"),
     pytest.param(
         generate_directory_signature(
             STORAGE_ACCOUNT_NAME, CONTAINER, VDS, STORAGE_ACCOUNT_KEY,
@@ -97,7 +97,7 @@ def test_assure_only_allowed_storage_accounts(path, payload):
         "vds": "https://dummy.blob.core.windows.net/container/blob",
     })
     res = send_request(path, "post", payload)
-    assert res.status_code == http.HTTPStatus.BAD_REQUEST
+    assert res.status_code == http.HTTPStatus.FORBIDDEN
     body = json.loads(res.content)
     assert "unsupported storage account" in body['error']
 
@@ -679,6 +679,11 @@ TEST_F(SurfaceAlignmentTest, IntersectingSurfaces)
         [&]() { cppapi::align_surfaces(primary, secondary, aligned, &primary_is_top); },
         testing::ThrowsMessage<std::runtime_error>(
             testing::HasSubstr("Surfaces intersect at primary surface point (2, 0)")));
+
+    EXPECT_THAT(
+        [&]() { cppapi::align_surfaces(primary, secondary, aligned, &primary_is_top); },
+        testing::ThrowsMessage<std::runtime_error>(
+            testing::HasSubstr("is below the secondary surface there, but above it at point (0, 1)")));
 }
 
 void inplace_subtraction(float* buffer_A, const float* buffer_B, std::size_t nsamples) noexcept(true) {
@@ -800,6 +805,7 @@ TEST_F(SliceFunctionTest, RequestingSliceData) {
         direction,
         lineno,
         slice_bounds,
+        nullptr,
         &response_data
     );
 
@@ -821,6 +827,7 @@ TEST_F(SliceFunctionTest, RequestingSliceDataSubtraction) {
         direction,
         lineno,
         slice_bounds,
+        nullptr,
         &response_data
     );
 
@@ -0,0 +1,28 @@
+package vds
+
+import (
+	"github.com/equinor/vds-slice/internal/core"
+)
+
+/*
+ * Errors are returned as one of the types below, such that callers can tell
+ * bad input apart from missing access or storage trouble with errors.As.
+ */
+
+/* The options are invalid for the VDS, e.g. a lineno out of range */
+type InvalidArgument = core.InvalidArgument
+
+/* Something unexpected went wrong within OpenVDS or this library */
+type InternalError = core.InternalError
+
+/** The VDS (or the storage container holding it) does not exist */
+type NotFoundError = core.NotFoundError
+
+/** The credentials are missing, malformed or expired */
+type UnauthorizedError = core.UnauthorizedError
+
+/** The credentials are valid, but do not grant access to the VDS */
+type ForbiddenError = core.ForbiddenError
+
+/** The storage backend is unavailable, try again after RetryAfter */
+type UnavailableError = core.UnavailableError
@@ -0,0 +1,64 @@
+package vds_test
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/equinor/vds-slice/vds"
+)
+
+func Example() {
+	ctx := context.Background()
+
+	handle, err := vds.Open(
+		ctx,
+		"https://account.blob.core.windows.net/container/blob",
+		vds.Credentials{Sas: "sv=..."},
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer handle.Close()
+
+	data, metadata, err := handle.Slice(ctx, vds.SliceOptions{
+		Direction: "inline",
+		Lineno:    1000,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(len(data), string(metadata))
+}
+
+func ExampleHandle_AttributesAlongSurface() {
+	ctx := context.Background()
+
+	handle, err := vds.Open(ctx, "/data/survey.vds", vds.Credentials{})
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer handle.Close()
+
+	rotation, xori, yori, fillValue := float32(33.69), float32(2), float32(0), float32(-999.25)
+	data, _, err := handle.AttributesAlongSurface(ctx, vds.AlongSurfaceOptions{
+		AttributeOptions: vds.AttributeOptions{
+			Attributes: []string{"min", "max", "rms"},
+		},
+		Surface: vds.RegularSurface{
+			Values:    [][]float32{{20, 20}, {24, 24}},
+			Rotation:  &rotation,
+			Xori:      &xori,
+			Yori:      &yori,
+			Xinc:      7.2111,
+			Yinc:      3.6056,
+			FillValue: &fillValue,
+		},
+		Above: 8,
+		Below: 8,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(len(data))
+}
@@ -0,0 +1,295 @@
+package vds
+
+import (
+	"context"
+
+	"github.com/equinor/vds-slice/internal/core"
+)
+
+/** Limits a slice along one axis, both bounds inclusive */
+type Bound struct {
+	/* One of Directions() */
+	Direction string
+	Lower     int
+	Upper     int
+}
+
+type SliceOptions struct {
+	/* The axis the slice is orthogonal to, one of Directions() */
+	Direction string
+
+	/* The line to slice along */
+	Lineno int
+
+	/** How Lineno is interpreted, one of LinenoModes()
+	 *
+	 * Defaults to indices for i, j and k, and annotations for the other
+	 * directions.
+	 */
+	LinenoMode string
+
+	/* Limit the slice along the other axes */
+	Bounds []Bound
+
+	/* Replaces absent data. Without it, absent data is left as stored */
+	FillValue *float32
+}
+
+/** A slice through the volume and its metadata
+ *
+ * The metadata is a SliceMetadata json document.
+ */
+func (h *Handle) Slice(
+	ctx context.Context,
+	options SliceOptions,
+) (data []byte, metadata []byte, err error) {
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	direction, err := core.GetAxis(options.Direction)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	linenoSystem, err := core.GetLinenoSystem(direction, options.LinenoMode)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	bounds := make([]core.Bound, len(options.Bounds))
+	for i := range options.Bounds {
+		bound := options.Bounds[i]
+		bounds[i] = core.Bound{
+			Direction: &bound.Direction,
+			Lower:     &bound.Lower,
+			Upper:     &bound.Upper,
+		}
+	}
+
+	return h.handle.GetSliceWithMetadata(
+		options.Lineno,
+		direction,
+		linenoSystem,
+		bounds,
+		options.FillValue,
+	)
+}
+
+type FenceOptions struct {
+	/* The system of the coordinates, one of CoordinateSystems() */
+	CoordinateSystem string
+
+	/* (x, y) points to read traces at */
+	Coordinates [][]float32
+
+	/* One of FenceInterpolationMethods(). Defaults to nearest */
+	Interpolation string
+
+	/* Used for coordinates outside the volume, which are errors without it */
+	FillValue *float32
+}
+
+/** Traces along an arbitrary path and their metadata
+ *
+ * The metadata is a FenceMetadata json document.
+ */
+func (h *Handle) Fence(
+	ctx context.Context,
+	options FenceOptions,
+) (data []byte, metadata []byte, err error) {
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	coordinateSystem, err := core.GetCoordinateSystem(options.CoordinateSystem)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	interpolation, err := core.GetFenceInterpolationMethod(options.Interpolation)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return h.handle.GetFenceWithMetadata(
+		coordinateSystem,
+		options.Coordinates,
+		interpolation,
+		options.FillValue,
+	)
+}
+
+/** A geometrical plane with depth/time values, see the server docs */
+type RegularSurface = core.RegularSurface
+
+/** Limits attributes to the part of a surface inside a closed polygon */
+type Polygon struct {
+	/* The system of the vertices, one of CoordinateSystems() */
+	CoordinateSystem string
+
+	/* (x, y) vertices, at least 3 */
+	Vertices [][]float32
+}
+
+type AttributeOptions struct {
+	/* The attributes to compute, from AttributeTypes() */
+	Attributes []string
+
+	/* Horizontal interpolation, one of InterpolationMethods() */
+	Interpolation string
+
+	/* Re-sampling within the window, one of VerticalInterpolationMethods() */
+	VerticalInterpolation string
+
+	/* Distance between samples in the window. Defaults to that of the VDS */
+	Stepsize float32
+
+	/*
+	 * Nodes where less than this fraction of the window holds data get the
+	 * fill value. Absent data is always left out of the attributes.
+	 */
+	MinValidFraction float32
+}
+
+type AlongSurfaceOptions struct {
+	AttributeOptions
+
+	Surface RegularSurface
+
+	/* The window around the surface, in the vertical unit of the VDS */
+	Above float32
+	Below float32
+
+	/* Only compute attributes inside the polygon */
+	Polygon *Polygon
+}
+
+/** Attributes in a window along a surface and their metadata
+ *
+ * There is one data buffer per attribute, in the order they were asked for.
+ * The metadata is an AttributeMetadata json document.
+ */
+func (h *Handle) AttributesAlongSurface(
+	ctx context.Context,
+	options AlongSurfaceOptions,
+) (data [][]byte, metadata []byte, err error) {
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	interpolation, verticalInterpolation, err := options.interpolation()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var polygon *core.Polygon
+	if options.Polygon != nil {
+		coordinateSystem, err := core.GetCoordinateSystem(
+			options.Polygon.CoordinateSystem,
+		)
+		if err != nil {
+			return nil, nil, err
+		}
+		polygon = &core.Polygon{
+			CoordinateSystem: coordinateSystem,
+			Vertices:         options.Polygon.Vertices,
+		}
+	}
+
+	return h.handle.GetAttributesAlongSurfaceWithMetadata(
+		options.Surface,
+		options.Above,
+		options.Below,
+		options.Stepsize,
+		options.Attributes,
+		interpolation,
+		verticalInterpolation,
+		polygon,
+		nil,
+		options.MinValidFraction,
+	)
+}
+
+type BetweenSurfacesOptions struct {
+	AttributeOptions
+
+	/* The surface that defines the shape of the result */
+	PrimarySurface RegularSurface
+
+	/* The other side of the window, sampled at the nodes of the primary */
+	SecondarySurface RegularSurface
+}
+
+/** Attributes between two surfaces and their metadata
+ *
+ * Like AttributesAlongSurface, but the window is bounded by the surfaces.
+ */
+func (h *Handle) AttributesBetweenSurfaces(
+	ctx context.Context,
+	options BetweenSurfacesOptions,
+) (data [][]byte, metadata []byte, err error) {
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	interpolation, verticalInterpolation, err := options.interpolation()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return h.handle.GetAttributesBetweenSurfacesWithMetadata(
+		options.PrimarySurface,
+		options.SecondarySurface,
+		options.Stepsize,
+		options.Attributes,
+		interpolation,
+		verticalInterpolation,
+		options.MinValidFraction,
+	)
+}
+
+func (options AttributeOptions) interpolation() (int, int, error) {
+	interpolation, err := core.GetInterpolationMethod(options.Interpolation)
+	if err != nil {
+		return -1, -1, err
+	}
+
+	verticalInterpolation, err := core.GetVerticalInterpolationMethod(
+		options.VerticalInterpolation,
+	)
+	if err != nil {
+		return -1, -1, err
+	}
+	return interpolation, verticalInterpolation, nil
+}
+
+/* The valid values of the string options */
+
+func Directions() []string {
+	return core.Directions()
+}
+
+func LinenoModes() []string {
+	return core.LinenoModes()
+}
+
+func CoordinateSystems() []string {
+	return core.CoordinateSystems()
+}
+
+func InterpolationMethods() []string {
+	return core.InterpolationMethods()
+}
+
+func FenceInterpolationMethods() []string {
+	return core.FenceInterpolationMethods()
+}
+
+func VerticalInterpolationMethods() []string {
+	return core.VerticalInterpolationMethods()
+}
+
+func AttributeTypes() []string {
+	return core.AttributeTypes()
+}
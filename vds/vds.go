@@ -0,0 +1,127 @@
+/** Package vds reads slices, fences and surface attributes from OpenVDS volumes
+ *
+ * It is the library underneath the vds-slice server, for Go programs that
+ * want to read from a VDS directly rather than through HTTP. A volume is
+ * opened with Open, from an Azure Blob Store (https), AWS S3 (s3) or Google
+ * Cloud Storage (gs) url, or a local path. Every read takes an options
+ * struct, where the zero value of a field is its default.
+ *
+ * Data is returned as raw, little endian 4 byte floats. Its shape, along with
+ * everything else needed to interpret it, is in the json metadata returned
+ * alongside. The metadata documents are the same as the server returns, see
+ * the server's API documentation for their models.
+ *
+ * Reads block until OpenVDS is done. The context is checked before every
+ * read, but a read that has started is not interrupted by cancelling it.
+ */
+package vds
+
+import (
+	"context"
+
+	"github.com/equinor/vds-slice/internal/core"
+)
+
+/** Credentials used to access a VDS
+ *
+ * Sas or BearerToken for Azure, S3 for AWS S3. Google Cloud Storage and local
+ * files take no credentials, and VDSs are then read with the ambient
+ * credentials of the process.
+ */
+type Credentials = core.Credentials
+
+/** Access keys and region for AWS S3, see Credentials */
+type S3Options = core.S3Options
+
+/** Statistics about the reads done through a handle */
+type RequestStats = core.RequestStats
+
+/*
+ * Unlike the server, the library has no allowlist of storage accounts. It
+ * trusts the program it's linked into with whatever url it's given.
+ */
+var makeConnection = core.MakeLocalConnection(
+	"/",
+	core.MakeConnection(map[string]core.ConnectionMaker{
+		"https": core.MakeAzureConnection(nil, 0, ""),
+		"s3":    core.MakeS3Connection(nil, "", ""),
+		"gs":    core.MakeGSConnection(nil),
+	}),
+)
+
+/** An open VDS
+ *
+ * A handle is not safe for concurrent use. Open one per goroutine instead,
+ * which is cheap compared to the reads themselves. Close releases the
+ * resources held by OpenVDS, and must be called when done.
+ */
+type Handle struct {
+	handle core.DSHandle
+}
+
+/** Open the VDS at url
+ *
+ * url is either an https url to a VDS in Azure Blob Store, an s3:// or gs://
+ * url, or a path or file:// url on the local filesystem.
+ */
+func Open(ctx context.Context, url string, credentials Credentials) (*Handle, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	connection, err := makeConnection(url, credentials)
+	if err != nil {
+		return nil, err
+	}
+
+	handle, err := core.NewDSHandle(connection)
+	if err != nil {
+		return nil, err
+	}
+	return &Handle{handle: handle}, nil
+}
+
+/** A handle that reads through any core.DSHandle
+ *
+ * Meant for vdstest, which reads its in-memory cubes through it. Programs
+ * reading a VDS use Open instead.
+ */
+func NewHandle(handle core.DSHandle) *Handle {
+	return &Handle{handle: handle}
+}
+
+func (h *Handle) Close() error {
+	return h.handle.Close()
+}
+
+/* Statistics for all reads done through the handle so far */
+func (h *Handle) Stats() (RequestStats, error) {
+	return h.handle.Stats()
+}
+
+type MetadataOptions struct {
+	/* Include the textual header of the SEG-Y the VDS was imported from */
+	IncludeImportInfo bool
+
+	/* Include how the VDS is laid out in storage */
+	IncludeLayout bool
+}
+
+/** Metadata of the VDS, as a json document */
+func (h *Handle) Metadata(
+	ctx context.Context,
+	options MetadataOptions,
+) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return h.handle.GetMetadata(
+		options.IncludeImportInfo,
+		options.IncludeLayout,
+	)
+}
+
+/** Version of the OpenVDS library that is linked in */
+func OpenVDSVersion() string {
+	return core.OpenVDSVersion()
+}
@@ -0,0 +1,112 @@
+package vds_test
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/equinor/vds-slice/vds"
+)
+
+const wellKnown = "../testdata/well_known/well_known_default.vds"
+
+func open(t *testing.T) *vds.Handle {
+	handle, err := vds.Open(context.Background(), wellKnown, vds.Credentials{})
+	require.NoError(t, err)
+	t.Cleanup(func() { handle.Close() })
+	return handle
+}
+
+func toFloat32(t *testing.T, buf []byte) []float32 {
+	require.Zero(t, len(buf)%4)
+	out := make([]float32, len(buf)/4)
+	for i := range out {
+		out[i] = math.Float32frombits(binary.LittleEndian.Uint32(buf[i*4:]))
+	}
+	return out
+}
+
+func TestSlice(t *testing.T) {
+	handle := open(t)
+
+	data, metadata, err := handle.Slice(context.Background(), vds.SliceOptions{
+		Direction: "inline",
+		Lineno:    3,
+	})
+	require.NoError(t, err)
+	require.Equal(t, []float32{
+		108, 109, 110, 111, // il: 3, xl: 10, samples: all
+		112, 113, 114, 115, // il: 3, xl: 11, samples: all
+	}, toFloat32(t, data))
+
+	var meta struct {
+		Shape []int `json:"shape"`
+	}
+	require.NoError(t, json.Unmarshal(metadata, &meta))
+	require.Equal(t, []int{2, 4}, meta.Shape)
+
+	data, _, err = handle.Slice(context.Background(), vds.SliceOptions{
+		Direction: "inline",
+		Lineno:    3,
+		Bounds:    []vds.Bound{{Direction: "crossline", Lower: 11, Upper: 11}},
+	})
+	require.NoError(t, err)
+	require.Equal(t, []float32{112, 113, 114, 115}, toFloat32(t, data))
+}
+
+func TestFence(t *testing.T) {
+	handle := open(t)
+
+	data, _, err := handle.Fence(context.Background(), vds.FenceOptions{
+		CoordinateSystem: "ilxl",
+		Coordinates:      [][]float32{{3, 10}, {1, 10}},
+	})
+	require.NoError(t, err)
+	require.Equal(t, []float32{
+		108, 109, 110, 111, // il: 3, xl: 10, samples: all
+		100, 101, 102, 103, // il: 1, xl: 10, samples: all
+	}, toFloat32(t, data))
+}
+
+func TestInvalidOptions(t *testing.T) {
+	handle := open(t)
+
+	_, _, err := handle.Slice(context.Background(), vds.SliceOptions{
+		Direction: "sideways",
+	})
+	var invalid *vds.InvalidArgument
+	require.ErrorAs(t, err, &invalid)
+
+	_, _, err = handle.Fence(context.Background(), vds.FenceOptions{
+		CoordinateSystem: "cdp",
+		Coordinates:      [][]float32{{0, 0}},
+		Interpolation:    "sideways",
+	})
+	require.ErrorAs(t, err, &invalid)
+}
+
+func TestCancelledContext(t *testing.T) {
+	handle := open(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, _, err := handle.Slice(ctx, vds.SliceOptions{Direction: "inline", Lineno: 3})
+	require.ErrorIs(t, err, context.Canceled)
+
+	_, err = vds.Open(ctx, wellKnown, vds.Credentials{})
+	require.ErrorIs(t, err, context.Canceled)
+}
+
+func TestOpenMissingFile(t *testing.T) {
+	_, err := vds.Open(
+		context.Background(),
+		"../testdata/does_not_exist.vds",
+		vds.Credentials{},
+	)
+	require.Error(t, err)
+}
@@ -0,0 +1,402 @@
+/** In-memory cubes, for tests of code that reads cubes through vds.Handle
+ *
+ * A Cube is a small regular 3D cube held in memory, which serves slices,
+ * fences and metadata like a VDS with the same geometry and samples would.
+ * The data parts are byte for byte those of OpenVDS, and so is the metadata
+ * for geometries whose annotations and coordinates are exact in float32, as
+ * for WellKnown. NewHandle reads a cube through the same vds.Handle as
+ * vds.Open gives for a VDS:
+ *
+ *     handle := vdstest.NewHandle(vdstest.WellKnown())
+ *     data, metadata, err := handle.Slice(ctx, vds.SliceOptions{
+ *         Direction: "inline",
+ *         Lineno:    3,
+ *     })
+ *
+ * Storage plugs cubes into an api.Endpoint in place of blobs:
+ *
+ *     storage := vdstest.Storage{"well_known": vdstest.WellKnown()}
+ *     endpoint := api.Endpoint{
+ *         MakeVdsConnection: storage.MakeConnection(),
+ *         OpenHandle:        storage.Open,
+ *         Cache:             cache.NewNoCache(),
+ *     }
+ *
+ * Only the nearest, nearest_trace and none interpolations are supported, and
+ * neither attributes, point samples, trace headers nor resampled fences are,
+ * which fail with an InternalError that says so. Attributes along and between
+ * surfaces are not computed at all, so the parity test of cmd/query covers
+ * slices, fences and metadata only.
+ */
+package vdstest
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+
+	"github.com/equinor/vds-slice/internal/core"
+	"github.com/equinor/vds-slice/vds"
+)
+
+/* The lines of an axis, min + n * step for n up to count */
+type Line struct {
+	Min   float64
+	Step  float64
+	Count int
+}
+
+func (l Line) annotation(index int) float64 {
+	return l.Min + float64(index)*l.Step
+}
+
+func (l Line) max() float64 {
+	return l.annotation(l.Count - 1)
+}
+
+/** The shape and position of a cube
+ *
+ * The world (cdp) position of the trace at index (i, j) is
+ * Origin + i * IStep + j * JStep.
+ */
+type Geometry struct {
+	Inlines    Line
+	Crosslines Line
+	Samples    Line
+
+	// Unit of the samples, as a VDS gives it, e.g. ms or m
+	SampleUnit string
+
+	Origin [2]float64
+	IStep  [2]float64
+	JStep  [2]float64
+}
+
+/* The world position of the trace at index (i, j), which may be fractional */
+func (g Geometry) world(i, j float64) [2]float64 {
+	return [2]float64{
+		g.Origin[0] + i*g.IStep[0] + j*g.JStep[0],
+		g.Origin[1] + i*g.IStep[1] + j*g.JStep[1],
+	}
+}
+
+/* The index (i, j) of the world position, the inverse of world */
+func (g Geometry) index(x, y float64) (float64, float64) {
+	dx := x - g.Origin[0]
+	dy := y - g.Origin[1]
+	det := g.IStep[0]*g.JStep[1] - g.JStep[0]*g.IStep[1]
+	i := (dx*g.JStep[1] - dy*g.JStep[0]) / det
+	j := (dy*g.IStep[0] - dx*g.IStep[1]) / det
+	return i, j
+}
+
+/** A cube of samples in memory
+ *
+ * The samples are in inline, crossline, sample order, i.e. the sample at
+ * index (i, j, k) is Data[(i * crosslines + j) * samples + k].
+ */
+type Cube struct {
+	Geometry
+
+	Crs             string
+	InputFileName   string
+	ImportTimeStamp string
+
+	Data []float32
+}
+
+/* The value of the sample at index (i, j, k) of a new cube */
+type SampleFunc func(i, j, k int) float32
+
+func NewCube(geometry Geometry, value SampleFunc) *Cube {
+	ni := geometry.Inlines.Count
+	nj := geometry.Crosslines.Count
+	nk := geometry.Samples.Count
+
+	data := make([]float32, ni*nj*nk)
+	for i := 0; i < ni; i++ {
+		for j := 0; j < nj; j++ {
+			for k := 0; k < nk; k++ {
+				data[(i*nj+j)*nk+k] = value(i, j, k)
+			}
+		}
+	}
+	return &Cube{Geometry: geometry, Data: data}
+}
+
+/** The cube of testdata/well_known
+ *
+ * Inlines 1, 3 and 5, crosslines 10 and 11, and samples at 4, 8, 12 and
+ * 16 ms. The sample at index (i, j, k) is 100 + (2i + j) * 4 + k.
+ */
+func WellKnown() *Cube {
+	geometry := Geometry{
+		Inlines:    Line{Min: 1, Step: 2, Count: 3},
+		Crosslines: Line{Min: 10, Step: 1, Count: 2},
+		Samples:    Line{Min: 4, Step: 4, Count: 4},
+		SampleUnit: "ms",
+		Origin:     [2]float64{2, 0},
+		IStep:      [2]float64{6, 4},
+		JStep:      [2]float64{-2, 3},
+	}
+	cube := NewCube(geometry, func(i, j, k int) float32 {
+		return float32(100 + (2*i+j)*4 + k)
+	})
+	cube.Crs = "utmXX"
+	cube.InputFileName = "well_known.segy"
+	cube.ImportTimeStamp = "2022-09-12T09:44:17.000Z"
+	return cube
+}
+
+/** The cube as a vds.Handle, read the same way as a VDS opened by vds.Open
+ *
+ * Reads the cube does not support, e.g. attributes, fail with an
+ * InternalError. Closing the handle does nothing.
+ */
+func NewHandle(cube *Cube) *vds.Handle {
+	return vds.NewHandle(cube)
+}
+
+func (c *Cube) sample(i, j, k int) float32 {
+	nj := c.Crosslines.Count
+	nk := c.Samples.Count
+	return c.Data[(i*nj+j)*nk+k]
+}
+
+/* The samples as little endian float32, like the data parts of responses */
+func toBytes(values []float32) []byte {
+	buf := make([]byte, len(values)*4)
+	for i, value := range values {
+		binary.LittleEndian.PutUint32(buf[i*4:], math.Float32bits(value))
+	}
+	return buf
+}
+
+/* Cubes by vds, which stand in for storage in an api.Endpoint */
+type Storage map[string]*Cube
+
+type connection struct {
+	url string
+}
+
+func (c connection) Url() string              { return c.url }
+func (c connection) ConnectionString() string { return "" }
+func (c connection) IsAuthorizedToRead() bool { return true }
+
+/** Connections to the cubes of the storage, regardless of credentials */
+func (s Storage) MakeConnection() core.ConnectionMaker {
+	return func(blob string, _ core.Credentials) (core.Connection, error) {
+		return connection{url: blob}, nil
+	}
+}
+
+/** Open the cube of the connection, as a core.HandleOpener */
+func (s Storage) Open(conn core.Connection) (core.DSHandle, error) {
+	cube, ok := s[conn.Url()]
+	if !ok {
+		return nil, core.NewNotFoundError(fmt.Sprintf(
+			"Could not open VDS: %s does not exist",
+			conn.Url(),
+		))
+	}
+	return cube, nil
+}
+
+/* The pool is of no use, as nothing is computed */
+func (c *Cube) WithWorkerPool(*core.WorkerPool) core.DSHandle {
+	return c
+}
+
+func (c *Cube) Close() error {
+	return nil
+}
+
+/* Nothing is read from storage */
+func (c *Cube) Stats() (core.RequestStats, error) {
+	return core.RequestStats{}, nil
+}
+
+/* The axes as in the metadata of a VDS */
+func (c *Cube) Axes() ([]*core.Axis, error) {
+	var whole subcube
+	for d, line := range c.lines() {
+		whole.upper[d] = line.Count
+	}
+
+	var axes []*core.Axis
+	for d := range whole.upper {
+		axis := c.sliceAxis(d, whole)
+		axes = append(axes, &axis)
+	}
+	return axes, nil
+}
+
+/** Metadata of the cube
+ *
+ * The cube has no SEG-Y text header, and its layout, given with
+ * includeLayout, is that of an uncompressed VDS of a single brick.
+ */
+func (c *Cube) GetMetadata(_ bool, includeLayout bool) ([]byte, error) {
+	ni := float64(c.Inlines.Count - 1)
+	nj := float64(c.Crosslines.Count - 1)
+	corners := [][2]float64{{0, 0}, {ni, 0}, {ni, nj}, {0, nj}}
+
+	var box core.BoundingBox
+	for _, corner := range corners {
+		world := c.world(corner[0], corner[1])
+		box.Ij = append(box.Ij, []float64{corner[0], corner[1]})
+		box.Ilxl = append(box.Ilxl, []float64{
+			c.Inlines.annotation(int(corner[0])),
+			c.Crosslines.annotation(int(corner[1])),
+		})
+		box.Cdp = append(box.Cdp, []float64{world[0], world[1]})
+	}
+
+	axes, _ := c.Axes()
+	metadata := core.Metadata{
+		Crs:             c.Crs,
+		InputFileName:   c.InputFileName,
+		ImportTimeStamp: c.ImportTimeStamp,
+		BoundingBox:     box,
+		Axis:            axes,
+		StoredFormat: core.StoredFormat{
+			Format:       "R32",
+			IntegerScale: 1,
+		},
+	}
+
+	if includeLayout {
+		low, high := float32(math.Inf(1)), float32(math.Inf(-1))
+		for _, value := range c.Data {
+			low = float32(math.Min(float64(low), float64(value)))
+			high = float32(math.Max(float64(high), float64(value)))
+		}
+		metadata.Layout = &core.Layout{
+			BrickSize: []int{
+				c.Inlines.Count,
+				c.Crosslines.Count,
+				c.Samples.Count,
+			},
+			Compression: "None",
+			Channels: []core.Channel{{
+				Name:       "Amplitude",
+				Format:     "R32",
+				ValueRange: [2]float32{low, high},
+			}},
+		}
+	}
+
+	buf, err := json.Marshal(metadata)
+	if err != nil {
+		return nil, core.NewInternalError(err.Error())
+	}
+	return buf, nil
+}
+
+func (c *Cube) VerticalUnitConversion(
+	requested string,
+) (core.VerticalUnitConversion, error) {
+	if requested == "" {
+		return core.NewVerticalUnitConversion("", "")
+	}
+	return core.NewVerticalUnitConversion(
+		core.NormalizeUnit(c.SampleUnit),
+		requested,
+	)
+}
+
+func unsupported(what string) error {
+	return core.NewInternalError(fmt.Sprintf(
+		"%s is not supported by the in-memory cubes of vdstest",
+		what,
+	))
+}
+
+func (c *Cube) GetFenceHeaders(
+	int,
+	[][]float32,
+	[]string,
+	*float32,
+) ([]byte, error) {
+	return nil, unsupported("Fence headers")
+}
+
+func (c *Cube) ResampledTraceLength(
+	float32,
+	core.VerticalUnitConversion,
+) (int, error) {
+	return 0, unsupported("Resampling")
+}
+
+func (c *Cube) ResampleFence(
+	[]byte,
+	[]byte,
+	float32,
+	int,
+	core.VerticalUnitConversion,
+) ([]byte, []byte, error) {
+	return nil, nil, unsupported("Resampling")
+}
+
+func (c *Cube) GetSamplesWithMetadata(
+	int,
+	[][]float32,
+	int,
+	*float32,
+	core.VerticalUnitConversion,
+) ([][]byte, []byte, error) {
+	return nil, nil, unsupported("Sampling points")
+}
+
+func (c *Cube) GetAttributesAlongSurface(
+	core.RegularSurface,
+	float32,
+	float32,
+	float32,
+	[]string,
+	int,
+	int,
+) ([][]byte, error) {
+	return nil, unsupported("Attributes")
+}
+
+func (c *Cube) GetAttributesAlongSurfaceWithMetadata(
+	core.RegularSurface,
+	float32,
+	float32,
+	float32,
+	[]string,
+	int,
+	int,
+	*core.Polygon,
+	*core.SurfaceBand,
+	float32,
+) ([][]byte, []byte, error) {
+	return nil, nil, unsupported("Attributes")
+}
+
+func (c *Cube) GetAttributesBetweenSurfaces(
+	core.RegularSurface,
+	core.RegularSurface,
+	float32,
+	[]string,
+	int,
+	int,
+) ([][]byte, error) {
+	return nil, unsupported("Attributes")
+}
+
+func (c *Cube) GetAttributesBetweenSurfacesWithMetadata(
+	core.RegularSurface,
+	core.RegularSurface,
+	float32,
+	[]string,
+	int,
+	int,
+	float32,
+) ([][]byte, []byte, error) {
+	return nil, nil, unsupported("Attributes")
+}
+
+var _ core.DSHandle = &Cube{}
@@ -0,0 +1,225 @@
+package vdstest
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/equinor/vds-slice/internal/core"
+)
+
+func TestWellKnownMetadata(t *testing.T) {
+	metadata, err := WellKnown().GetMetadata(false, false)
+	require.NoError(t, err)
+
+	expected := `{
+		"axis": [
+			{"annotation": "Inline", "max": 5.0, "min": 1.0, "samples" : 3, "stepsize":2, "regular": true, "unit": "unitless", "rawUnit": "unitless"},
+			{"annotation": "Crossline", "max": 11.0, "min": 10.0, "samples" : 2, "stepsize":1, "regular": true, "unit": "unitless", "rawUnit": "unitless"},
+			{"annotation": "Sample", "max": 16.0, "min": 4.0, "samples" : 4, "stepsize":4, "regular": true, "unit": "ms", "rawUnit": "ms"}
+		],
+		"boundingBox": {
+			"cdp": [[2,0],[14,8],[12,11],[0,3]],
+			"ilxl": [[1, 10], [5, 10], [5, 11], [1, 11]],
+			"ij": [[0, 0], [2, 0], [2, 1], [0, 1]]
+		},
+		"crs"            : "utmXX",
+		"inputFileName"  : "well_known.segy",
+		"storedFormat"   : {"format": "R32", "integerScale": 1, "integerOffset": 0},
+		"importTimeStamp": "2022-09-12T09:44:17.000Z"
+	}`
+	require.JSONEq(t, expected, string(metadata))
+}
+
+func TestSliceMetadata(t *testing.T) {
+	bound := func(direction string, lower, upper int) core.Bound {
+		return core.Bound{Direction: &direction, Lower: &lower, Upper: &upper}
+	}
+
+	metadata, err := WellKnown().GetSliceMetadata(
+		12,
+		core.AxisSample,
+		core.CoordinateSystemAnnotation,
+		[]core.Bound{bound("inline", 3, 5), bound("j", 1, 1)},
+		nil,
+	)
+	require.NoError(t, err)
+
+	expected := `{
+		"format": "<f4",
+		"x": {"annotation": "Crossline", "max": 11.0, "min": 11.0, "samples" : 1, "stepsize":1, "regular": true, "unit": "unitless", "rawUnit": "unitless"},
+		"y": {"annotation": "Inline", "max": 5.0, "min": 3.0, "samples" : 2, "stepsize":2, "regular": true, "unit": "unitless", "rawUnit": "unitless"},
+		"shape": [2, 1],
+		"geospatial": [[6, 7], [12, 11], [12, 11], [6, 7]],
+		"fillValue": null
+	}`
+	require.JSONEq(t, expected, string(metadata))
+}
+
+func TestSliceErrors(t *testing.T) {
+	bound := func(direction string, lower, upper int) core.Bound {
+		return core.Bound{Direction: &direction, Lower: &lower, Upper: &upper}
+	}
+
+	testCases := []struct {
+		name      string
+		lineno    int
+		direction int
+		bounds    []core.Bound
+		expected  string
+	}{
+		{
+			name:      "Lineno between inlines",
+			lineno:    2,
+			direction: core.AxisInline,
+			expected: "Invalid lineno: 2, valid range: [1.00:5.00:2.00]. " +
+				"The lineno is interpreted as an annotation, as an index " +
+				"the valid range is [0:2:1]",
+		},
+		{
+			name:      "Lower bound out of range",
+			lineno:    1,
+			direction: core.AxisInline,
+			bounds:    []core.Bound{bound("crossline", 9, 11)},
+			expected: "Invalid lower bound: 9, valid range: " +
+				"[10.00:11.00:1.00]. The lower bound is interpreted as an " +
+				"annotation, as an index the valid range is [0:1:1]",
+		},
+		{
+			name:      "Empty bounds",
+			lineno:    1,
+			direction: core.AxisInline,
+			bounds:    []core.Bound{bound("sample", 12, 8)},
+			expected: "Bounds select no samples: Sample index 2 of the " +
+				"lower bound is above index 1 of the upper bound. Resolved " +
+				"index ranges (inclusive): Inline [0:0], Crossline [0:1], " +
+				"Sample [2:1]",
+		},
+		{
+			name:      "Depth slice of a time cube",
+			lineno:    8,
+			direction: core.AxisDepth,
+			expected: "Cannot fetch depth slice for VDS file with vertical " +
+				"axis unit: ms",
+		},
+	}
+
+	for _, testCase := range testCases {
+		_, _, err := WellKnown().GetSliceWithMetadata(
+			testCase.lineno,
+			testCase.direction,
+			core.CoordinateSystemAnnotation,
+			testCase.bounds,
+			nil,
+		)
+		require.IsType(t, &core.InvalidArgument{}, err, testCase.name)
+		require.Equal(t, testCase.expected, err.Error(), testCase.name)
+	}
+}
+
+func TestFenceSnapsHalfUp(t *testing.T) {
+	nearestTrace, _ := core.GetFenceInterpolationMethod("nearest_trace")
+	fillValue := float32(-1)
+
+	metadata, err := WellKnown().GetFenceMetadata(
+		core.CoordinateSystemIndex,
+		[][]float32{{0.5, 0.49}, {-0.5, 1.49}, {2.49, 1.5}},
+		nearestTrace,
+		&fillValue,
+	)
+	require.NoError(t, err)
+
+	expected := `{
+		"fillValue": -1,
+		"format": "<f4",
+		"indices": [[1, 0], [0, 1], null],
+		"shape": [3, 4]
+	}`
+	require.JSONEq(t, expected, string(metadata))
+}
+
+func TestFenceErrors(t *testing.T) {
+	nearest, _ := core.GetFenceInterpolationMethod("nearest")
+	none, _ := core.GetFenceInterpolationMethod("none")
+	linear, _ := core.GetFenceInterpolationMethod("linear")
+
+	testCases := []struct {
+		name          string
+		coordinates   [][]float32
+		interpolation int
+		expected      string
+		errorType     interface{}
+	}{
+		{
+			name:          "Out of boundaries",
+			coordinates:   [][]float32{{0, 0}, {3, 0}},
+			interpolation: nearest,
+			expected:      "Coordinate (3.000000,0.000000) is out of boundaries in dimension 0.",
+			errorType:     &core.InvalidArgument{},
+		},
+		{
+			name:          "Not a pair",
+			coordinates:   [][]float32{{0, 0, 0}},
+			interpolation: nearest,
+			expected:      "invalid coordinate [0 0 0] at position 0, expected [x y] pair",
+			errorType:     &core.InvalidArgument{},
+		},
+		{
+			name:          "Between traces",
+			coordinates:   [][]float32{{1, 1}, {1.25, 0}},
+			interpolation: none,
+			expected: "Coordinate (1.250000,0.000000) at position 1 is not " +
+				"at a trace, which interpolation 'none' requires. The " +
+				"nearest trace is inline 3.00, crossline 10.00",
+			errorType: &core.InvalidArgument{},
+		},
+		{
+			name:          "Unsupported interpolation",
+			coordinates:   [][]float32{{1, 1}},
+			interpolation: linear,
+			expected:      "Interpolation other than nearest is not supported by the in-memory cubes of vdstest",
+			errorType:     &core.InternalError{},
+		},
+	}
+
+	for _, testCase := range testCases {
+		_, err := WellKnown().GetFence(
+			core.CoordinateSystemIndex,
+			testCase.coordinates,
+			testCase.interpolation,
+			nil,
+		)
+		require.IsType(t, testCase.errorType, err, testCase.name)
+		require.Equal(t, testCase.expected, err.Error(), testCase.name)
+	}
+}
+
+func TestAttributesUnsupported(t *testing.T) {
+	surface := core.RegularSurface{Values: [][]float32{{8}}}
+	attributes := []string{"samplevalue"}
+	expected := "Attributes is not supported by the in-memory cubes of vdstest"
+
+	_, err := WellKnown().GetAttributesAlongSurface(
+		surface, 4, 4, 4, attributes, 0, 1,
+	)
+	require.IsType(t, &core.InternalError{}, err)
+	require.Equal(t, expected, err.Error())
+
+	_, _, err = WellKnown().GetAttributesAlongSurfaceWithMetadata(
+		surface, 4, 4, 4, attributes, 0, 1, nil, nil, 0,
+	)
+	require.IsType(t, &core.InternalError{}, err)
+	require.Equal(t, expected, err.Error())
+
+	_, err = WellKnown().GetAttributesBetweenSurfaces(
+		surface, surface, 4, attributes, 0, 1,
+	)
+	require.IsType(t, &core.InternalError{}, err)
+	require.Equal(t, expected, err.Error())
+
+	_, _, err = WellKnown().GetAttributesBetweenSurfacesWithMetadata(
+		surface, surface, 4, attributes, 0, 1, 0,
+	)
+	require.IsType(t, &core.InternalError{}, err)
+	require.Equal(t, expected, err.Error())
+}
@@ -0,0 +1,107 @@
+package vdstest_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/equinor/vds-slice/internal/core"
+	"github.com/equinor/vds-slice/vds"
+	"github.com/equinor/vds-slice/vds/vdstest"
+)
+
+func toFloats(buf []byte) []float32 {
+	values := make([]float32, len(buf)/4)
+	binary.Read(bytes.NewReader(buf), binary.LittleEndian, values)
+	return values
+}
+
+func ExampleWellKnown() {
+	cube := vdstest.WellKnown()
+
+	data, _, err := cube.GetSliceWithMetadata(
+		3,
+		core.AxisInline,
+		core.CoordinateSystemAnnotation,
+		nil,
+		nil,
+	)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(toFloats(data))
+	// Output: [108 109 110 111 112 113 114 115]
+}
+
+func ExampleNewCube() {
+	geometry := vdstest.Geometry{
+		Inlines:    vdstest.Line{Min: 100, Step: 10, Count: 4},
+		Crosslines: vdstest.Line{Min: 1, Step: 1, Count: 3},
+		Samples:    vdstest.Line{Min: 0, Step: 2, Count: 2},
+		SampleUnit: "m",
+		Origin:     [2]float64{1000, 2000},
+		IStep:      [2]float64{25, 0},
+		JStep:      [2]float64{0, 25},
+	}
+	cube := vdstest.NewCube(geometry, func(i, j, k int) float32 {
+		return float32(i*100 + j*10 + k)
+	})
+
+	interpolation, _ := core.GetFenceInterpolationMethod("nearest_trace")
+	fillValue := float32(-999.25)
+	data, metadata, err := cube.GetFenceWithMetadata(
+		core.CoordinateSystemCdp,
+		[][]float32{{1026, 2049}, {5000, 5000}},
+		interpolation,
+		&fillValue,
+	)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(string(metadata))
+	fmt.Println(toFloats(data))
+	// Output:
+	// {"fillValue":-999.25,"format":"<f4","indices":[[1,2],null],"shape":[2,2]}
+	// [120 121 -999.25 -999.25]
+}
+
+func ExampleStorage() {
+	storage := vdstest.Storage{"well_known": vdstest.WellKnown()}
+
+	conn, _ := storage.MakeConnection()("well_known", core.Credentials{})
+	handle, err := storage.Open(conn)
+	if err != nil {
+		panic(err)
+	}
+	defer handle.Close()
+
+	axes, _ := handle.Axes()
+	for _, axis := range axes {
+		fmt.Println(axis.Annotation, axis.Min, axis.Max, axis.Samples)
+	}
+
+	conn, _ = storage.MakeConnection()("missing", core.Credentials{})
+	_, err = storage.Open(conn)
+	fmt.Println(err)
+	// Output:
+	// Inline 1 5 3
+	// Crossline 10 11 2
+	// Sample 4 16 4
+	// Could not open VDS: missing does not exist
+}
+
+func ExampleNewHandle() {
+	handle := vdstest.NewHandle(vdstest.WellKnown())
+	defer handle.Close()
+
+	data, _, err := handle.Slice(context.Background(), vds.SliceOptions{
+		Direction: "crossline",
+		Lineno:    11,
+	})
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(toFloats(data))
+	// Output: [104 105 106 107 112 113 114 115 120 121 122 123]
+}
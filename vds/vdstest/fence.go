@@ -0,0 +1,280 @@
+package vdstest
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/equinor/vds-slice/internal/core"
+)
+
+/** The position of a fence coordinate along the inline and crossline axes
+ *
+ * Positions are fractional indices, where the lines are at whole numbers.
+ */
+func (c *Cube) position(
+	coordinateSystem int,
+	x float32,
+	y float32,
+) ([2]float64, error) {
+	switch coordinateSystem {
+	case core.CoordinateSystemIndex:
+		return [2]float64{float64(x), float64(y)}, nil
+	case core.CoordinateSystemAnnotation:
+		return [2]float64{
+			(float64(x) - c.Inlines.Min) / c.Inlines.Step,
+			(float64(y) - c.Crosslines.Min) / c.Crosslines.Step,
+		}, nil
+	case core.CoordinateSystemCdp:
+		i, j := c.index(float64(x), float64(y))
+		return [2]float64{i, j}, nil
+	default:
+		return [2]float64{}, core.NewInternalError("Unhandled coordinate system")
+	}
+}
+
+/** Snap the fence to the nearest traces, as (i, j)
+ *
+ * Lines are centered on their position, so an axis of n lines covers the
+ * positions from -0.5 up to n - 0.5, and positions halfway between two lines
+ * snap to the upper one. Coordinates outside of the cube snap to no trace if
+ * there is a fill value. With exact, which is the interpolation none, every
+ * coordinate must be at a trace.
+ */
+func (c *Cube) snapToTraces(
+	coordinateSystem int,
+	coordinates [][]float32,
+	fillValue *float32,
+	exact bool,
+) ([]*[2]int, error) {
+	if err := validateCoordinates(coordinates); err != nil {
+		return nil, err
+	}
+
+	counts := [2]int{c.Inlines.Count, c.Crosslines.Count}
+	lines := [2]Line{c.Inlines, c.Crosslines}
+
+	traces := make([]*[2]int, 0, len(coordinates))
+	for n, coordinate := range coordinates {
+		x, y := coordinate[0], coordinate[1]
+		position, err := c.position(coordinateSystem, x, y)
+		if err != nil {
+			return nil, err
+		}
+
+		outside := -1
+		for d := range position {
+			p := position[d]
+			if p < -0.5 || p >= float64(counts[d])-0.5 {
+				outside = d
+				break
+			}
+		}
+		if outside != -1 {
+			if fillValue == nil {
+				return nil, core.NewCodedInvalidArgument(
+					"coordinate_out_of_bounds",
+					nil,
+					fmt.Sprintf(
+						"Coordinate (%.6f,%.6f) is out of boundaries in "+
+							"dimension %d.",
+						x,
+						y,
+						outside,
+					),
+				)
+			}
+			traces = append(traces, nil)
+			continue
+		}
+
+		var snapped [2]int
+		for d := range position {
+			index := int(math.Floor(position[d] + 0.5))
+			if index < 0 {
+				index = 0
+			}
+			if index > counts[d]-1 {
+				index = counts[d] - 1
+			}
+			snapped[d] = index
+		}
+
+		const tolerance = 1e-3
+		onTrace := math.Abs(position[0]-float64(snapped[0])) <= tolerance &&
+			math.Abs(position[1]-float64(snapped[1])) <= tolerance
+		if exact && !onTrace {
+			return nil, core.NewInvalidArgument(fmt.Sprintf(
+				"Coordinate (%.6f,%.6f) at position %d is not at a trace, "+
+					"which interpolation 'none' requires. The nearest trace "+
+					"is inline %.2f, crossline %.2f",
+				x,
+				y,
+				n,
+				lines[0].annotation(snapped[0]),
+				lines[1].annotation(snapped[1]),
+			))
+		}
+
+		traces = append(traces, &snapped)
+	}
+	return traces, nil
+}
+
+func validateCoordinates(coordinates [][]float32) error {
+	for i, coordinate := range coordinates {
+		if len(coordinate) != 2 {
+			return core.NewCodedInvalidArgument(
+				"invalid_coordinate",
+				core.ErrorDetails{"position": strconv.Itoa(i)},
+				fmt.Sprintf(
+					"invalid coordinate %v at position %d, expected [x y] pair",
+					coordinate,
+					i,
+				),
+			)
+		}
+	}
+	return nil
+}
+
+/** The interpolations the cube can read fences with
+ *
+ * Nearest reads the same samples as nearest_trace, as the samples of the
+ * trace are not interpolated vertically either.
+ */
+func (c *Cube) interpolation(interpolation int) (exact bool, err error) {
+	nearest, _ := core.GetFenceInterpolationMethod("nearest")
+	nearestTrace, _ := core.GetFenceInterpolationMethod("nearest_trace")
+	none, _ := core.GetFenceInterpolationMethod("none")
+
+	switch interpolation {
+	case nearest, nearestTrace:
+		return false, nil
+	case none:
+		return true, nil
+	default:
+		return false, unsupported("Interpolation other than nearest")
+	}
+}
+
+/* The samples of the traces, with the fill value in place of missing ones */
+func (c *Cube) readTraces(traces []*[2]int, fillValue *float32) []byte {
+	nk := c.Samples.Count
+	values := make([]float32, 0, len(traces)*nk)
+	for _, trace := range traces {
+		for k := 0; k < nk; k++ {
+			if trace == nil {
+				values = append(values, *fillValue)
+				continue
+			}
+			values = append(values, c.sample(trace[0], trace[1], k))
+		}
+	}
+	return toBytes(values)
+}
+
+/** Metadata of the fence, with the indices of the traces for nearest_trace
+ *
+ * The keys are sorted, as by OpenVDS backed cubes, which also give the
+ * traces outside of the cube as null.
+ */
+func (c *Cube) fenceMetadata(
+	traces []*[2]int,
+	npoints int,
+	fillValue *float32,
+) ([]byte, error) {
+	metadata := map[string]interface{}{
+		"shape":     []int{npoints, c.Samples.Count},
+		"format":    "<f4",
+		"fillValue": (*core.FillValue)(fillValue),
+	}
+	if traces != nil {
+		metadata["indices"] = traces
+	}
+
+	buf, err := json.Marshal(metadata)
+	if err != nil {
+		return nil, core.NewInternalError(err.Error())
+	}
+	return buf, nil
+}
+
+func (c *Cube) GetFence(
+	coordinateSystem int,
+	coordinates [][]float32,
+	interpolation int,
+	fillValue *float32,
+) ([]byte, error) {
+	exact, err := c.interpolation(interpolation)
+	if err != nil {
+		return nil, err
+	}
+
+	traces, err := c.snapToTraces(
+		coordinateSystem,
+		coordinates,
+		fillValue,
+		exact,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return c.readTraces(traces, fillValue), nil
+}
+
+func (c *Cube) GetFenceMetadata(
+	coordinateSystem int,
+	coordinates [][]float32,
+	interpolation int,
+	fillValue *float32,
+) ([]byte, error) {
+	if err := validateCoordinates(coordinates); err != nil {
+		return nil, err
+	}
+
+	nearestTrace, _ := core.GetFenceInterpolationMethod("nearest_trace")
+	if interpolation != nearestTrace {
+		return c.fenceMetadata(nil, len(coordinates), fillValue)
+	}
+
+	traces, err := c.snapToTraces(
+		coordinateSystem,
+		coordinates,
+		fillValue,
+		false,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return c.fenceMetadata(traces, len(coordinates), fillValue)
+}
+
+func (c *Cube) GetFenceWithMetadata(
+	coordinateSystem int,
+	coordinates [][]float32,
+	interpolation int,
+	fillValue *float32,
+) (data []byte, metadata []byte, err error) {
+	metadata, err = c.GetFenceMetadata(
+		coordinateSystem,
+		coordinates,
+		interpolation,
+		fillValue,
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	data, err = c.GetFence(
+		coordinateSystem,
+		coordinates,
+		interpolation,
+		fillValue,
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+	return data, metadata, nil
+}
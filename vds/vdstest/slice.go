@@ -0,0 +1,478 @@
+package vdstest
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/equinor/vds-slice/internal/core"
+)
+
+/* The lines of the cube a slice reads, lower to upper (exclusive) */
+type subcube struct {
+	lower [3]int
+	upper [3]int
+}
+
+func (c *Cube) lines() [3]Line {
+	return [3]Line{c.Inlines, c.Crosslines, c.Samples}
+}
+
+func (c *Cube) annotations() [3]string {
+	return [3]string{"Inline", "Crossline", "Sample"}
+}
+
+/* The dimension of the cube a direction is along, in inline, crossline,
+ * sample order
+ */
+func dimension(direction int) (int, error) {
+	switch direction {
+	case core.AxisI, core.AxisInline:
+		return 0, nil
+	case core.AxisJ, core.AxisCrossline:
+		return 1, nil
+	case core.AxisK, core.AxisDepth, core.AxisTime, core.AxisSample:
+		return 2, nil
+	default:
+		return -1, core.NewInternalError("Unhandled axis")
+	}
+}
+
+/* The stepsize as OpenVDS computes it, which is NaN for a single line */
+func stepsize(line Line) float32 {
+	min := float32(line.Min)
+	max := float32(line.max())
+	return (max - min) / float32(line.Count-1)
+}
+
+/* The valid range of a line, as OpenVDS backed cubes report it */
+type lineRange struct {
+	min      string
+	max      string
+	stepsize string
+}
+
+func (r lineRange) String() string {
+	return fmt.Sprintf("[%s:%s:%s]", r.min, r.max, r.stepsize)
+}
+
+func annotationRange(line Line) lineRange {
+	return lineRange{
+		min:      fmt.Sprintf("%.2f", float32(line.Min)),
+		max:      fmt.Sprintf("%.2f", float32(line.max())),
+		stepsize: fmt.Sprintf("%.2f", stepsize(line)),
+	}
+}
+
+func indexRange(line Line) lineRange {
+	return lineRange{min: "0", max: strconv.Itoa(line.Count - 1), stepsize: "1"}
+}
+
+/* Only the lineno has a code of its own, bounds out of range get the default */
+func outOfRange(name string, lineno int, valid lineRange, msg string) error {
+	if name != "lineno" {
+		return core.NewInvalidArgument(msg)
+	}
+	return core.NewCodedInvalidArgument(
+		"lineno_out_of_range",
+		core.ErrorDetails{
+			"lineno":   strconv.Itoa(lineno),
+			"min":      valid.min,
+			"max":      valid.max,
+			"stepsize": valid.stepsize,
+		},
+		msg,
+	)
+}
+
+/* The index of lineno along line, with the errors of OpenVDS backed cubes */
+func toVoxel(line Line, lineno int, system int, name string) (int, error) {
+	switch system {
+	case core.CoordinateSystemAnnotation:
+		min := float32(line.Min)
+		max := float32(line.max())
+		voxel := (float32(lineno) - min) / stepsize(line)
+
+		outside := float32(lineno) < min || float32(lineno) > max
+		if outside || float32(math.Floor(float64(voxel))) != voxel {
+			return 0, outOfRange(name, lineno, annotationRange(line), fmt.Sprintf(
+				"Invalid %s: %d, valid range: %s. The %s is interpreted "+
+					"as an annotation, as an index the valid range is %s",
+				name,
+				lineno,
+				annotationRange(line),
+				name,
+				indexRange(line),
+			))
+		}
+		return int(voxel), nil
+	case core.CoordinateSystemIndex:
+		if lineno < 0 || lineno > line.Count-1 {
+			return 0, outOfRange(name, lineno, indexRange(line), fmt.Sprintf(
+				"Invalid %s: %d, valid range: %s. The %s is interpreted "+
+					"as an index, as an annotation the valid range is %s",
+				name,
+				lineno,
+				indexRange(line),
+				name,
+				annotationRange(line),
+			))
+		}
+		return lineno, nil
+	default:
+		return 0, core.NewInternalError("Unhandled coordinate system")
+	}
+}
+
+/** The lines a slice reads
+ *
+ * Bounds are applied in order, such that the last bound along an axis takes
+ * precedence, and bounds along the direction of the slice are checked, but
+ * otherwise ignored.
+ */
+func (c *Cube) sliceBounds(
+	lineno int,
+	direction int,
+	linenoSystem int,
+	bounds []core.Bound,
+) (subcube, error) {
+	lines := c.lines()
+
+	var sub subcube
+	for d, line := range lines {
+		sub.upper[d] = line.Count
+	}
+
+	for _, bound := range bounds {
+		axis, system, err := core.ResolveBound(bound)
+		if err != nil {
+			return sub, err
+		}
+		d, err := dimension(axis)
+		if err != nil {
+			return sub, err
+		}
+
+		lower, err := toVoxel(lines[d], *bound.Lower, system, "lower bound")
+		if err != nil {
+			return sub, err
+		}
+		upper, err := toVoxel(lines[d], *bound.Upper, system, "upper bound")
+		if err != nil {
+			return sub, err
+		}
+		sub.lower[d] = lower
+		sub.upper[d] = upper + 1
+	}
+
+	d, err := dimension(direction)
+	if err != nil {
+		return sub, err
+	}
+	voxel, err := toVoxel(lines[d], lineno, linenoSystem, "lineno")
+	if err != nil {
+		return sub, err
+	}
+	sub.lower[d] = voxel
+	sub.upper[d] = voxel + 1
+
+	return sub, c.requireNonempty(sub)
+}
+
+func (c *Cube) requireNonempty(sub subcube) error {
+	var selection []string
+	var details core.ErrorDetails
+	reason := ""
+	for d, name := range c.annotations() {
+		lower := sub.lower[d]
+		upper := sub.upper[d] - 1
+		selection = append(
+			selection,
+			fmt.Sprintf("%s [%d:%d]", name, lower, upper),
+		)
+		if lower > upper && reason == "" {
+			reason = fmt.Sprintf(
+				"%s index %d of the lower bound is above index %d of the "+
+					"upper bound",
+				name,
+				lower,
+				upper,
+			)
+			details = core.ErrorDetails{
+				"axis":  name,
+				"lower": strconv.Itoa(lower),
+				"upper": strconv.Itoa(upper),
+			}
+		}
+	}
+
+	if reason != "" {
+		return core.NewCodedInvalidArgument(
+			"empty_selection",
+			details,
+			fmt.Sprintf(
+				"Bounds select no samples: %s. Resolved index ranges "+
+					"(inclusive): %s",
+				reason,
+				strings.Join(selection, ", "),
+			),
+		)
+	}
+	return nil
+}
+
+/* Time and depth slices, and bounds, need a vertical axis of that unit */
+func (c *Cube) validateVerticalAxis(direction int) error {
+	unit := c.SampleUnit
+	switch direction {
+	case core.AxisDepth:
+		if unit != "m" && unit != "ft" && unit != "usft" {
+			return core.NewCodedInvalidArgument(
+				"incompatible_vertical_axis",
+				nil,
+				"Cannot fetch depth slice for VDS file with vertical axis "+
+					"unit: "+unit,
+			)
+		}
+	case core.AxisTime:
+		if unit != "ms" && unit != "s" {
+			return core.NewCodedInvalidArgument(
+				"incompatible_vertical_axis",
+				nil,
+				"Cannot fetch time slice for VDS file with vertical axis "+
+					"unit: "+unit,
+			)
+		}
+	}
+	return nil
+}
+
+func (c *Cube) validateSlice(direction int, bounds []core.Bound) error {
+	if err := c.validateVerticalAxis(direction); err != nil {
+		return err
+	}
+	for _, bound := range bounds {
+		axis, _, err := core.ResolveBound(bound)
+		if err != nil {
+			return err
+		}
+		if err := c.validateVerticalAxis(axis); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/** The dimensions along x and y of slices along every dimension
+ *
+ * Inline slices are crossline by sample, crossline slices inline by sample
+ * and sample slices inline by crossline, as [y, x] in shape.
+ */
+var sliceDimensions = [3][2]int{{2, 1}, {2, 0}, {1, 0}}
+
+/* The samples of the subcube, with the sample index running fastest */
+func (c *Cube) read(sub subcube) []byte {
+	var values []float32
+	for i := sub.lower[0]; i < sub.upper[0]; i++ {
+		for j := sub.lower[1]; j < sub.upper[1]; j++ {
+			for k := sub.lower[2]; k < sub.upper[2]; k++ {
+				values = append(values, c.sample(i, j, k))
+			}
+		}
+	}
+	return toBytes(values)
+}
+
+/* The axis of dimension d, limited to the lines of the subcube */
+func (c *Cube) sliceAxis(d int, sub subcube) core.Axis {
+	line := c.lines()[d]
+	unit := "unitless"
+	if d == 2 {
+		unit = c.SampleUnit
+	}
+
+	step := stepsize(line)
+	coordinate := func(index int) float64 {
+		if line.Count == 1 {
+			return float64(float32(line.Min))
+		}
+		return float64(float32(line.Min) + step*float32(index))
+	}
+	if line.Count == 1 {
+		step = 0
+	}
+
+	return core.Axis{
+		Annotation: c.annotations()[d],
+		Min:        coordinate(sub.lower[d]),
+		Max:        coordinate(sub.upper[d] - 1),
+		Samples:    sub.upper[d] - sub.lower[d],
+		StepSize:   float64(step),
+		Regular:    line.Count > 1 && step != 0,
+		Unit:       core.NormalizeUnit(unit),
+		RawUnit:    unit,
+	}
+}
+
+func (c *Cube) sliceMetadata(
+	direction int,
+	sub subcube,
+	fillValue *float32,
+) ([]byte, error) {
+	d, err := dimension(direction)
+	if err != nil {
+		return nil, err
+	}
+
+	x, y := sliceDimensions[d][0], sliceDimensions[d][1]
+
+	lower := c.world(float64(sub.lower[0]), float64(sub.lower[1]))
+	upper := c.world(float64(sub.upper[0]-1), float64(sub.upper[1]-1))
+	geospatial := [][]float64{lower[:], upper[:]}
+	if d == 2 {
+		right := c.world(float64(sub.upper[0]-1), float64(sub.lower[1]))
+		left := c.world(float64(sub.lower[0]), float64(sub.upper[1]-1))
+		geospatial = [][]float64{lower[:], right[:], upper[:], left[:]}
+	}
+
+	metadata := core.SliceMetadata{
+		Array: core.Array{Format: "<f4"},
+		X:     c.sliceAxis(x, sub),
+		Y:     c.sliceAxis(y, sub),
+		Shape: []int{
+			sub.upper[y] - sub.lower[y],
+			sub.upper[x] - sub.lower[x],
+		},
+		Geospatial: geospatial,
+		FillValue:  (*core.FillValue)(fillValue),
+	}
+
+	buf, err := json.Marshal(metadata)
+	if err != nil {
+		return nil, core.NewInternalError(err.Error())
+	}
+	return buf, nil
+}
+
+/* The cube has no absent data, so fillValue only shows in the metadata */
+func (c *Cube) GetSlice(
+	lineno int,
+	direction int,
+	linenoSystem int,
+	bounds []core.Bound,
+	fillValue *float32,
+) ([]byte, error) {
+	if err := c.validateSlice(direction, bounds); err != nil {
+		return nil, err
+	}
+	sub, err := c.sliceBounds(lineno, direction, linenoSystem, bounds)
+	if err != nil {
+		return nil, err
+	}
+	return c.read(sub), nil
+}
+
+func (c *Cube) GetSliceMetadata(
+	lineno int,
+	direction int,
+	linenoSystem int,
+	bounds []core.Bound,
+	fillValue *float32,
+) ([]byte, error) {
+	sub, err := c.sliceBounds(lineno, direction, linenoSystem, bounds)
+	if err != nil {
+		return nil, err
+	}
+	return c.sliceMetadata(direction, sub, fillValue)
+}
+
+func (c *Cube) GetSliceWithMetadata(
+	lineno int,
+	direction int,
+	linenoSystem int,
+	bounds []core.Bound,
+	fillValue *float32,
+) (data []byte, metadata []byte, err error) {
+	sub, err := c.sliceBounds(lineno, direction, linenoSystem, bounds)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := c.validateSlice(direction, bounds); err != nil {
+		return nil, nil, err
+	}
+
+	metadata, err = c.sliceMetadata(direction, sub, fillValue)
+	if err != nil {
+		return nil, nil, err
+	}
+	return c.read(sub), metadata, nil
+}
+
+/* The cube is stored as float32, so stored slices are the slices themselves */
+func (c *Cube) GetStoredSliceMetadata(
+	lineno int,
+	direction int,
+	linenoSystem int,
+	bounds []core.Bound,
+) ([]byte, error) {
+	return c.GetSliceMetadata(lineno, direction, linenoSystem, bounds, nil)
+}
+
+func (c *Cube) GetStoredSliceWithMetadata(
+	lineno int,
+	direction int,
+	linenoSystem int,
+	bounds []core.Bound,
+) (data []byte, metadata []byte, err error) {
+	return c.GetSliceWithMetadata(lineno, direction, linenoSystem, bounds, nil)
+}
+
+/* Every stride'th row of the slice, with the rows along the y of its shape */
+func (c *Cube) GetSliceRows(
+	lineno int,
+	direction int,
+	linenoSystem int,
+	bounds []core.Bound,
+	fillValue *float32,
+	first int,
+	stride int,
+) ([]byte, error) {
+	if err := c.validateSlice(direction, bounds); err != nil {
+		return nil, err
+	}
+	sub, err := c.sliceBounds(lineno, direction, linenoSystem, bounds)
+	if err != nil {
+		return nil, err
+	}
+	if first < 0 || stride < 1 {
+		msg := fmt.Sprintf("Invalid rows: first row %d, stride %d", first, stride)
+		return nil, core.NewInvalidArgument(msg)
+	}
+
+	d, err := dimension(direction)
+	if err != nil {
+		return nil, err
+	}
+	y := sliceDimensions[d][1]
+
+	data := []byte{}
+	for row := sub.lower[y] + first; row < sub.upper[y]; row += stride {
+		line := sub
+		line.lower[y], line.upper[y] = row, row+1
+		data = append(data, c.read(line)...)
+	}
+	return data, nil
+}
+
+func (c *Cube) GetStoredSliceRows(
+	lineno int,
+	direction int,
+	linenoSystem int,
+	bounds []core.Bound,
+	first int,
+	stride int,
+) ([]byte, error) {
+	return c.GetSliceRows(lineno, direction, linenoSystem, bounds, nil, first, stride)
+}